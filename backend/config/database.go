@@ -90,6 +90,15 @@ func AutoMigrate() error {
 		&models.User{},
 		&models.IPTablesRule{},
 		&models.OperationLog{},
+		&models.CounterSeries{},
+		&models.CounterSample{},
+		&models.TopologySnapshot{},
+		&models.ManagedRuleGroup{},
+		&models.ManagedRule{},
+		&models.PortForward{},
+		&models.ConnectivityChangeSet{},
+		&models.ConnectivityChange{},
+		&models.PacketLog{},
 	)
 }
 
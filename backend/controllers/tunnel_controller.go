@@ -1,22 +1,37 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"iptables-management-backend/models"
 	"iptables-management-backend/services"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type TunnelController struct {
-	networkService *services.NetworkService
+	networkService    *services.NetworkService
+	counterService    *services.CounterService
+	dockerUserService *services.DockerUserService
+	changeService     *services.ConnectivityChangeService
+	tunnelRuleService *services.TunnelRuleService
+	logService        *services.LogService
 }
 
 // NewTunnelController 创建隧道控制器实例
-func NewTunnelController() *TunnelController {
+func NewTunnelController(networkService *services.NetworkService, counterService *services.CounterService, dockerUserService *services.DockerUserService, changeService *services.ConnectivityChangeService, tunnelRuleService *services.TunnelRuleService, logService *services.LogService) *TunnelController {
 	return &TunnelController{
-		networkService: services.NewNetworkService(),
+		networkService:    networkService,
+		counterService:    counterService,
+		dockerUserService: dockerUserService,
+		changeService:     changeService,
+		tunnelRuleService: tunnelRuleService,
+		logService:        logService,
 	}
 }
 
@@ -193,17 +208,17 @@ func (tc *TunnelController) GetDockerBridges(c *gin.Context) {
 
 // GenerateTunnelDockerRules 生成隧道与Docker通信规则
 // @Summary 生成隧道与Docker通信规则
-// @Description 根据指定参数生成隧道接口与Docker网桥通信的iptables规则
+// @Description 按网桥实际子网生成隧道接口与Docker网桥通信的iptables规则；apply 为 true 时作为托管规则组应用，任一规则失败时整体撤销
 // @Tags tunnel
 // @Accept json
 // @Produce json
-// @Param request body TunnelDockerRuleRequest true "规则生成请求"
+// @Param request body services.TunnelRuleRequest true "规则生成请求"
 // @Success 200 {object} map[string]interface{} "成功返回生成的规则"
 // @Failure 400 {object} map[string]interface{} "请求参数错误"
 // @Failure 500 {object} map[string]interface{} "服务器内部错误"
 // @Router /api/tunnel/generate-rules [post]
 func (tc *TunnelController) GenerateTunnelDockerRules(c *gin.Context) {
-	var request TunnelDockerRuleRequest
+	var request services.TunnelRuleRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "请求参数格式错误: " + err.Error(),
@@ -211,108 +226,41 @@ func (tc *TunnelController) GenerateTunnelDockerRules(c *gin.Context) {
 		return
 	}
 
-	if request.TunnelInterface == "" || request.DockerBridge == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "隧道接口和Docker网桥名称不能为空",
+	username, _ := c.Get("username")
+	result, err := tc.tunnelRuleService.GenerateRules(request, fmt.Sprint(username))
+	if err != nil {
+		// 请求参数无效或引用的网桥不存在都属于请求错误
+		status := http.StatusInternalServerError
+		var validation *services.ValidationError
+		var notFound *services.NotFoundError
+		if errors.As(err, &validation) || errors.As(err, &notFound) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error": "生成规则失败: " + err.Error(),
 		})
 		return
 	}
 
-	rules := tc.generateCommunicationRules(request)
+	if result.Applied {
+		tc.logService.LogOperation(
+			fmt.Sprint(username),
+			"应用生成的隧道规则",
+			fmt.Sprintf("规则组 %s: %s <-> %s\n%s", result.GroupName, result.TunnelInterface, result.DockerBridge, strings.Join(result.Commands, "\n")),
+			c.ClientIP(),
+		)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":          true,
-		"generated_rules":  rules,
-		"rules_count":      len(rules),
-		"tunnel_interface": request.TunnelInterface,
-		"docker_bridge":    request.DockerBridge,
+		"generated_rules":  result.Commands,
+		"rules_count":      len(result.Commands),
+		"tunnel_interface": result.TunnelInterface,
+		"docker_bridge":    result.DockerBridge,
+		"result":           result,
 	})
 }
 
-// TunnelDockerRuleRequest 隧道Docker规则生成请求
-type TunnelDockerRuleRequest struct {
-	TunnelInterface string `json:"tunnel_interface" binding:"required"`
-	DockerBridge    string `json:"docker_bridge" binding:"required"`
-	Direction       string `json:"direction"` // bidirectional, inbound, outbound
-	Protocol        string `json:"protocol"`  // tcp, udp, icmp, all
-	SourcePort      string `json:"source_port"`
-	DestPort        string `json:"dest_port"`
-	Action          string `json:"action"` // ACCEPT, DROP, REJECT
-	EnableNAT       bool   `json:"enable_nat"`
-	EnableLogging   bool   `json:"enable_logging"`
-}
-
-// generateCommunicationRules 生成通信规则
-func (tc *TunnelController) generateCommunicationRules(request TunnelDockerRuleRequest) []string {
-	var rules []string
-
-	// 设置默认值
-	if request.Direction == "" {
-		request.Direction = "bidirectional"
-	}
-	if request.Protocol == "" {
-		request.Protocol = "all"
-	}
-	if request.Action == "" {
-		request.Action = "ACCEPT"
-	}
-
-	// 生成基础转发规则
-	if request.Direction == "bidirectional" || request.Direction == "inbound" {
-		rule := "iptables -A FORWARD -i " + request.TunnelInterface + " -o " + request.DockerBridge
-		if request.Protocol != "all" {
-			rule += " -p " + request.Protocol
-		}
-		if request.DestPort != "" {
-			rule += " --dport " + request.DestPort
-		}
-		if request.EnableLogging {
-			rule += " -j LOG --log-prefix \"TUNNEL-TO-DOCKER: \""
-			rules = append(rules, rule)
-			rule = "iptables -A FORWARD -i " + request.TunnelInterface + " -o " + request.DockerBridge
-			if request.Protocol != "all" {
-				rule += " -p " + request.Protocol
-			}
-			if request.DestPort != "" {
-				rule += " --dport " + request.DestPort
-			}
-		}
-		rule += " -j " + request.Action
-		rules = append(rules, rule)
-	}
-
-	if request.Direction == "bidirectional" || request.Direction == "outbound" {
-		rule := "iptables -A FORWARD -i " + request.DockerBridge + " -o " + request.TunnelInterface + " -m conntrack --ctstate RELATED,ESTABLISHED"
-		if request.EnableLogging {
-			rule += " -j LOG --log-prefix \"DOCKER-TO-TUNNEL: \""
-			rules = append(rules, rule)
-			rule = "iptables -A FORWARD -i " + request.DockerBridge + " -o " + request.TunnelInterface + " -m conntrack --ctstate RELATED,ESTABLISHED"
-		}
-		rule += " -j " + request.Action
-		rules = append(rules, rule)
-	}
-
-	// 生成NAT规则
-	if request.EnableNAT {
-		natRule := "iptables -t nat -A POSTROUTING -o " + request.TunnelInterface + " -j MASQUERADE"
-		rules = append(rules, natRule)
-
-		// Docker网络的MASQUERADE规则
-		dockerNatRule := "iptables -t nat -A POSTROUTING -s 172.17.0.0/16 -o " + request.TunnelInterface + " -j MASQUERADE"
-		rules = append(rules, dockerNatRule)
-	}
-
-	// 生成INPUT规则（允许隧道接口的基本通信）
-	inputRule := "iptables -A INPUT -i " + request.TunnelInterface + " -j " + request.Action
-	rules = append(rules, inputRule)
-
-	// 生成OUTPUT规则
-	outputRule := "iptables -A OUTPUT -o " + request.TunnelInterface + " -j " + request.Action
-	rules = append(rules, outputRule)
-
-	return rules
-}
-
 // GetTunnelStatistics 获取隧道接口统计信息
 // @Summary 获取隧道接口统计信息
 // @Description 获取指定隧道接口的流量统计和性能指标
@@ -321,6 +269,7 @@ func (tc *TunnelController) generateCommunicationRules(request TunnelDockerRuleR
 // @Produce json
 // @Param interface_name path string true "隧道接口名称"
 // @Param hours query int false "统计时间范围(小时)" default(24)
+// @Param docker_bridge query string false "Docker网桥名称，提供时返回隧道与网桥之间的流量历史"
 // @Success 200 {object} map[string]interface{} "成功返回统计信息"
 // @Failure 400 {object} map[string]interface{} "请求参数错误"
 // @Failure 500 {object} map[string]interface{} "服务器内部错误"
@@ -335,10 +284,12 @@ func (tc *TunnelController) GetTunnelStatistics(c *gin.Context) {
 	}
 
 	hoursStr := c.DefaultQuery("hours", "24")
-	hours, err := strconv.Atoi(hoursStr)
-	if err != nil {
+	hours, err := strconv.ParseFloat(hoursStr, 64)
+	if err != nil || hours <= 0 {
 		hours = 24
 	}
+	to := time.Now()
+	from := to.Add(-time.Duration(hours * float64(time.Hour)))
 
 	// 获取接口基础统计
 	interfaces, err := tc.networkService.GetAllInterfaces()
@@ -385,6 +336,19 @@ func (tc *TunnelController) GetTunnelStatistics(c *gin.Context) {
 		"ip_addresses":       targetInterface.IPAddresses,
 	}
 
+	// 时间窗口内的历史数据（来自计数器采样）
+	if history, err := tc.counterService.GetInterfaceHistory(interfaceName, from, to); err == nil {
+		statistics["interface_history"] = history
+	}
+	if ruleHistory, err := tc.counterService.GetInterfaceRuleHistory(interfaceName, from, to); err == nil {
+		statistics["rule_history"] = ruleHistory
+	}
+	if dockerBridge := c.Query("docker_bridge"); dockerBridge != "" {
+		if pairHistory, err := tc.counterService.GetPairHistory(interfaceName, dockerBridge, from, to); err == nil {
+			statistics["pair_history"] = pairHistory
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success":    true,
 		"statistics": statistics,
@@ -393,7 +357,7 @@ func (tc *TunnelController) GetTunnelStatistics(c *gin.Context) {
 
 // FixConnectivity 修复隧道接口与Docker网桥的连通性问题
 // @Summary 修复隧道接口与Docker网桥的连通性问题
-// @Description 自动检测并修复隧道接口与Docker网桥之间的连通性问题
+// @Description 自动检测并修复隧道接口与Docker网桥之间的连通性问题；plan 为 true 时只返回待审核的执行计划
 // @Tags tunnel
 // @Accept json
 // @Produce json
@@ -418,7 +382,38 @@ func (tc *TunnelController) FixConnectivity(c *gin.Context) {
 		return
 	}
 
-	result, err := tc.networkService.FixConnectivity(request.TunnelInterface, request.DockerBridge)
+	if request.Plan {
+		var plan *services.ConnectivityPlan
+		var err error
+		if request.UsePersistent() {
+			username, _ := c.Get("username")
+			plan, err = tc.dockerUserService.PlanConnectivityFix(request.TunnelInterface, request.DockerBridge, fmt.Sprint(username))
+		} else {
+			plan, err = tc.networkService.PlanConnectivityFix(request.TunnelInterface, request.DockerBridge)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "生成修复计划失败: " + err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"plan":    services.StoreConnectivityPlan(plan),
+		})
+		return
+	}
+
+	var result *models.ConnectivityFixResult
+	var err error
+	username, _ := c.Get("username")
+	if request.UsePersistent() {
+		// 写入DOCKER-USER托管规则组，Docker重启后由监视器自动恢复
+		result, err = tc.dockerUserService.FixConnectivity(request.TunnelInterface, request.DockerBridge, fmt.Sprint(username))
+	} else {
+		result, err = tc.networkService.FixConnectivity(request.TunnelInterface, request.DockerBridge, fmt.Sprint(username))
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "修复连通性失败: " + err.Error(),
@@ -432,8 +427,161 @@ func (tc *TunnelController) FixConnectivity(c *gin.Context) {
 	})
 }
 
+// GetConnectivityPlan 获取待审核的连通性修复计划
+// @Summary 获取连通性修复计划
+// @Tags tunnel
+// @Produce json
+// @Param id path string true "计划ID"
+// @Success 200 {object} map[string]interface{} "成功返回计划"
+// @Failure 404 {object} map[string]interface{} "计划不存在或已过期"
+// @Router /api/tunnel/fix-connectivity/plans/{id} [get]
+func (tc *TunnelController) GetConnectivityPlan(c *gin.Context) {
+	plan, err := services.GetConnectivityPlan(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "修复计划不存在或已过期",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"plan":    plan,
+	})
+}
+
+// ExecuteConnectivityPlan 执行已审核的连通性修复计划
+// @Summary 执行连通性修复计划
+// @Description 按计划顺序执行命令；计划只能执行一次，filter表在计划生成后被改动时拒绝执行
+// @Tags tunnel
+// @Produce json
+// @Param id path string true "计划ID"
+// @Success 200 {object} map[string]interface{} "成功返回执行结果"
+// @Failure 404 {object} map[string]interface{} "计划不存在或已过期"
+// @Failure 409 {object} map[string]interface{} "规则已变化，需要重新生成计划"
+// @Router /api/tunnel/fix-connectivity/plans/{id}/execute [post]
+func (tc *TunnelController) ExecuteConnectivityPlan(c *gin.Context) {
+	username, _ := c.Get("username")
+	plan, result, err := services.ExecuteConnectivityPlan(c.Param("id"), fmt.Sprint(username))
+	if err != nil {
+		if plan == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "修复计划不存在或已过期",
+			})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "规则在生成计划后已被修改，请重新生成计划",
+		})
+		return
+	}
+
+	commands := make([]string, 0, len(plan.Steps))
+	for _, step := range plan.Steps {
+		commands = append(commands, fmt.Sprintf("[%s] %s", step.Status, step.Command))
+	}
+	tc.logService.LogOperation(
+		fmt.Sprint(username),
+		"执行连通性修复计划",
+		fmt.Sprintf("计划 %s: %s <-> %s\n%s", plan.ID, plan.TunnelInterface, plan.DockerBridge, strings.Join(commands, "\n")),
+		c.ClientIP(),
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": result.Success,
+		"plan":    plan,
+		"result":  result,
+	})
+}
+
+// GetConnectivityHistory 获取连通性修复历史（变更集）
+// @Summary 获取连通性修复历史
+// @Description 按隧道接口和网桥过滤历史修复，每次修复记录插入/删除的规则、接口和sysctl变更
+// @Tags tunnel
+// @Produce json
+// @Param tunnel_interface query string false "隧道接口名称"
+// @Param docker_bridge query string false "Docker网桥名称"
+// @Success 200 {object} map[string]interface{} "成功返回修复历史"
+// @Failure 500 {object} map[string]interface{} "服务器内部错误"
+// @Router /api/tunnel/fix-connectivity/history [get]
+func (tc *TunnelController) GetConnectivityHistory(c *gin.Context) {
+	changeSets, err := tc.changeService.ListChangeSets(c.Query("tunnel_interface"), c.Query("docker_bridge"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "获取修复历史失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"change_sets": changeSets,
+	})
+}
+
+// RollbackConnectivityFix 回滚一次连通性修复
+// @Summary 回滚连通性修复
+// @Description 倒序撤销变更集中的变更：删除插入的规则、按原位置恢复删除的规则、恢复接口状态和sysctl
+// @Tags tunnel
+// @Produce json
+// @Param id path int true "变更集ID"
+// @Success 200 {object} map[string]interface{} "成功返回回滚结果"
+// @Failure 404 {object} map[string]interface{} "变更集不存在"
+// @Failure 409 {object} map[string]interface{} "变更集已回滚"
+// @Router /api/tunnel/fix-connectivity/history/{id}/rollback [post]
+func (tc *TunnelController) RollbackConnectivityFix(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的变更集ID",
+		})
+		return
+	}
+
+	username, _ := c.Get("username")
+	changeSet, err := tc.changeService.RollbackChangeSet(uint(id), fmt.Sprint(username))
+	if err != nil {
+		switch {
+		case err == gorm.ErrRecordNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "变更集不存在"})
+		case changeSet != nil && changeSet.Status == "rolled_back":
+			c.JSON(http.StatusConflict, gin.H{"error": "该修复已回滚"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "回滚连通性修复失败: " + err.Error()})
+		}
+		return
+	}
+
+	lines := make([]string, 0, len(changeSet.Changes))
+	for _, change := range changeSet.Changes {
+		status := "reverted"
+		if !change.Reverted {
+			status = "failed: " + change.RevertError
+		}
+		lines = append(lines, fmt.Sprintf("[%s] %s", status, change.Command))
+	}
+	tc.logService.LogOperation(
+		fmt.Sprint(username),
+		"回滚连通性修复",
+		fmt.Sprintf("变更集 %d: %s <-> %s\n%s", changeSet.ID, changeSet.TunnelInterface, changeSet.DockerBridge, strings.Join(lines, "\n")),
+		c.ClientIP(),
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    changeSet.Status == "rolled_back",
+		"change_set": changeSet,
+	})
+}
+
 // FixConnectivityRequest 修复连通性请求
 type FixConnectivityRequest struct {
 	TunnelInterface string `json:"tunnel_interface" binding:"required"`
 	DockerBridge    string `json:"docker_bridge" binding:"required"`
+	Persistent      *bool  `json:"persistent"` // 使用DOCKER-USER托管规则，Docker重启后自动恢复；未指定时默认为 true
+	Plan            bool   `json:"plan"`       // 只生成待审核的执行计划，不修改系统
+}
+
+// UsePersistent 是否写入DOCKER-USER托管规则；只有显式传入 false 时才直接修改 FORWARD 等Docker管理的链
+func (r FixConnectivityRequest) UsePersistent() bool {
+	return r.Persistent == nil || *r.Persistent
 }
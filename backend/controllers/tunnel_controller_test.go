@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFixConnectivityRequestPersistentDefault(t *testing.T) {
+	cases := map[string]bool{
+		`{"tunnel_interface":"wg0","docker_bridge":"docker0"}`:                    true,
+		`{"tunnel_interface":"wg0","docker_bridge":"docker0","persistent":true}`:  true,
+		`{"tunnel_interface":"wg0","docker_bridge":"docker0","persistent":false}`: false,
+	}
+	for body, want := range cases {
+		var request FixConnectivityRequest
+		if err := json.Unmarshal([]byte(body), &request); err != nil {
+			t.Fatalf("unmarshal %s: %v", body, err)
+		}
+		if got := request.UsePersistent(); got != want {
+			t.Errorf("%s: UsePersistent() = %v, want %v", body, got, want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"iptables-management-backend/services"
+)
+
+type ConntrackHandler struct {
+	conntrackService *services.ConntrackService
+	logService       *services.LogService
+}
+
+// NewConntrackHandler 创建连接跟踪处理器实例
+func NewConntrackHandler(conntrackService *services.ConntrackService, logService *services.LogService) *ConntrackHandler {
+	return &ConntrackHandler{
+		conntrackService: conntrackService,
+		logService:       logService,
+	}
+}
+
+// conntrackFilterRequest 连接跟踪过滤条件（查询参数和删除请求体共用）
+type conntrackFilterRequest struct {
+	Protocol  string `json:"protocol" form:"protocol"`
+	State     string `json:"state" form:"state"`
+	Interface string `json:"interface" form:"interface"`
+	Subnet    string `json:"subnet" form:"subnet"`
+	OrigSrc   string `json:"orig_src" form:"orig_src"`
+	OrigDst   string `json:"orig_dst" form:"orig_dst"`
+	ReplySrc  string `json:"reply_src" form:"reply_src"`
+	ReplyDst  string `json:"reply_dst" form:"reply_dst"`
+	Port      int    `json:"port" form:"port"`
+	NAT       string `json:"nat" form:"nat"`
+	Limit     int    `json:"limit" form:"limit"`
+}
+
+// toFilter 转换为服务层过滤条件
+func (r conntrackFilterRequest) toFilter() services.ConntrackFilter {
+	return services.ConntrackFilter{
+		Protocol:  r.Protocol,
+		State:     r.State,
+		Interface: r.Interface,
+		Subnet:    r.Subnet,
+		OrigSrc:   r.OrigSrc,
+		OrigDst:   r.OrigDst,
+		ReplySrc:  r.ReplySrc,
+		ReplyDst:  r.ReplyDst,
+		Port:      r.Port,
+		NAT:       r.NAT,
+		Limit:     r.Limit,
+	}
+}
+
+// ListEntries 查询连接跟踪表
+func (h *ConntrackHandler) ListEntries(c *gin.Context) {
+	var request conntrackFilterRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "查询参数无效: " + err.Error()})
+		return
+	}
+	if request.Limit == 0 {
+		request.Limit = 500
+	}
+
+	result, err := h.conntrackService.ListEntries(request.toFilter())
+	if err != nil {
+		log.Printf("[ERROR] Failed to list conntrack entries: %v", err)
+		var validation *services.ValidationError
+		if errors.As(err, &validation) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "过滤条件无效: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取连接跟踪表失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// DeleteEntries 删除选中的连接（entries）或所有满足过滤条件的连接（filter）
+func (h *ConntrackHandler) DeleteEntries(c *gin.Context) {
+	var request struct {
+		Entries []services.ConntrackSelector `json:"entries"`
+		Filter  *conntrackFilterRequest      `json:"filter"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数格式错误: " + err.Error()})
+		return
+	}
+
+	var filter *services.ConntrackFilter
+	if request.Filter != nil {
+		converted := request.Filter.toFilter()
+		filter = &converted
+	}
+	result, err := h.conntrackService.DeleteEntries(request.Entries, filter)
+	if err != nil {
+		if errors.Is(err, services.ErrConntrackNoSelector) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "必须指定要删除的连接或非空的过滤条件"})
+			return
+		}
+		var validation *services.ValidationError
+		if errors.As(err, &validation) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "过滤条件无效: " + err.Error()})
+			return
+		}
+		log.Printf("[ERROR] Failed to delete conntrack entries: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除连接失败: " + err.Error()})
+		return
+	}
+
+	username, _ := c.Get("username")
+	h.logService.LogOperation(
+		fmt.Sprint(username),
+		"删除连接跟踪条目",
+		"删除 "+strconv.Itoa(result.Deleted)+" 条连接:\n"+strings.Join(result.Commands, "\n"),
+		c.ClientIP(),
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": len(result.Errors) == 0,
+		"result":  result,
+	})
+}
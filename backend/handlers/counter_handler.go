@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"iptables-management-backend/services"
+)
+
+type CounterHandler struct {
+	counterService *services.CounterService
+}
+
+// NewCounterHandler 创建计数器历史处理器实例
+func NewCounterHandler(counterService *services.CounterService) *CounterHandler {
+	return &CounterHandler{
+		counterService: counterService,
+	}
+}
+
+// parseWindow 解析 hours / from / to 查询参数，失败时直接返回400
+func parseWindow(c *gin.Context) (time.Time, time.Time, bool) {
+	from, to, err := services.ParseCounterWindow(c.Query("hours"), c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "时间范围参数无效: " + err.Error()})
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// GetInterfaceHistory 获取接口在时间窗口内的收发量和速率
+func (h *CounterHandler) GetInterfaceHistory(c *gin.Context) {
+	name := c.Param("name")
+	from, to, ok := parseWindow(c)
+	if !ok {
+		return
+	}
+
+	window, err := h.counterService.GetInterfaceHistory(name, from, to)
+	if err != nil {
+		if errors.Is(err, services.ErrNoCounterHistory) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "没有该接口的历史数据: " + name})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取接口历史失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"interface": name,
+		"window":    window,
+	})
+}
+
+// GetRuleTotals 获取规则在时间窗口内的计数汇总
+func (h *CounterHandler) GetRuleTotals(c *gin.Context) {
+	from, to, ok := parseWindow(c)
+	if !ok {
+		return
+	}
+
+	totals, err := h.counterService.GetRuleTotals(c.Query("table"), c.Query("chain"), from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取规则计数汇总失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":  from,
+		"to":    to,
+		"rules": totals,
+	})
+}
+
+// GetRuleHistory 获取单条规则的计数历史
+func (h *CounterHandler) GetRuleHistory(c *gin.Context) {
+	key := c.Param("key")
+	from, to, ok := parseWindow(c)
+	if !ok {
+		return
+	}
+
+	history, err := h.counterService.GetRuleHistory(key, from, to)
+	if err != nil {
+		if errors.Is(err, services.ErrNoCounterHistory) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "没有该规则的历史数据: " + key})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取规则历史失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// GetPairHistory 获取隧道接口与Docker网桥之间的流量历史
+func (h *CounterHandler) GetPairHistory(c *gin.Context) {
+	tunnelInterface := c.Query("tunnel_interface")
+	dockerBridge := c.Query("docker_bridge")
+	if tunnelInterface == "" || dockerBridge == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "隧道接口名称和Docker网桥名称都不能为空"})
+		return
+	}
+
+	from, to, ok := parseWindow(c)
+	if !ok {
+		return
+	}
+
+	history, err := h.counterService.GetPairHistory(tunnelInterface, dockerBridge, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取通信流量历史失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// GetRuleUsageReport 根据计数器历史报告未使用、已冷却和最热的规则
+func (h *CounterHandler) GetRuleUsageReport(c *gin.Context) {
+	query, err := services.ParseRuleUsageQuery(c.Query("since"), c.Query("days"), c.Query("recent_hours"), c.Query("top"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "报告参数无效: " + err.Error()})
+		return
+	}
+	query.Table = c.Query("table")
+	query.Chain = c.Query("chain")
+
+	report, err := h.counterService.GetRuleUsageReport(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成规则使用报告失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"iptables-management-backend/services"
+)
+
+type DockerPortHandler struct {
+	dockerPortService *services.DockerPortService
+}
+
+// NewDockerPortHandler 创建Docker端口映射处理器实例
+func NewDockerPortHandler(dockerPortService *services.DockerPortService) *DockerPortHandler {
+	return &DockerPortHandler{
+		dockerPortService: dockerPortService,
+	}
+}
+
+// GetPortMappings 获取Docker发布端口映射（主机IP:端口 → 容器:端口）
+func (h *DockerPortHandler) GetPortMappings(c *gin.Context) {
+	log.Println("[DEBUG] GetPortMappings API called")
+
+	filter := services.DockerPortFilter{
+		Protocol:  c.Query("protocol"),
+		Container: c.Query("container"),
+		Bridge:    c.Query("bridge"),
+		HostPort:  c.Query("host_port"),
+	}
+	if filter.HostPort != "" {
+		if _, err := strconv.Atoi(filter.HostPort); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "host_port 必须是端口号"})
+			return
+		}
+	}
+
+	mappings, err := h.dockerPortService.GetPortMappings(filter)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get docker port mappings: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取Docker端口映射失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ports": mappings,
+		"total": len(mappings),
+	})
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iptables-management-backend/services"
+)
+
+type DockerUserHandler struct {
+	dockerUserService *services.DockerUserService
+	logService        *services.LogService
+}
+
+// NewDockerUserHandler 创建DOCKER-USER托管规则处理器实例
+func NewDockerUserHandler(dockerUserService *services.DockerUserService, logService *services.LogService) *DockerUserHandler {
+	return &DockerUserHandler{
+		dockerUserService: dockerUserService,
+		logService:        logService,
+	}
+}
+
+// GetStatus 获取托管规则组、规则存在状态和监视器状态
+func (h *DockerUserHandler) GetStatus(c *gin.Context) {
+	groups, err := h.dockerUserService.GetStatus()
+	if err != nil {
+		log.Printf("[ERROR] Failed to get managed rule status: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取托管规则状态失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"chain":   services.DockerUserChain,
+		"groups":  groups,
+		"watcher": h.dockerUserService.GetWatcherState(),
+	})
+}
+
+// Reconcile 立即检查并重新应用缺失的托管规则
+func (h *DockerUserHandler) Reconcile(c *gin.Context) {
+	username, _ := c.Get("username")
+	result, err := h.dockerUserService.Reconcile("manual:" + fmt.Sprint(username))
+	if err != nil {
+		log.Printf("[ERROR] Failed to reconcile managed rules: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查托管规则失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": len(result.Errors) == 0,
+		"result":  result,
+	})
+}
+
+// ListGroups 获取托管规则组及规则存在状态，可按来源过滤（fix_connectivity, generate_rules, port_forward, manual）
+func (h *DockerUserHandler) ListGroups(c *gin.Context) {
+	statuses, err := h.dockerUserService.GetStatus()
+	if err != nil {
+		log.Printf("[ERROR] Failed to list managed rule groups: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取托管规则组失败"})
+		return
+	}
+
+	source := c.Query("source")
+	groups := make([]services.ManagedGroupStatus, 0, len(statuses))
+	for _, status := range statuses {
+		if source == "" || status.Source == source {
+			groups = append(groups, status)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"groups": groups})
+}
+
+// GetGroup 获取单个托管规则组及其规则存在状态
+func (h *DockerUserHandler) GetGroup(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的规则组ID"})
+		return
+	}
+
+	group, err := h.dockerUserService.GetGroupStatus(uint(id))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "托管规则组不存在"})
+			return
+		}
+		log.Printf("[ERROR] Failed to get managed rule group %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取托管规则组失败"})
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// DeleteGroup 删除托管规则组并从系统中移除其规则
+func (h *DockerUserHandler) DeleteGroup(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的规则组ID"})
+		return
+	}
+
+	group, err := h.dockerUserService.DeleteGroup(uint(id))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "托管规则组不存在"})
+			return
+		}
+		log.Printf("[ERROR] Failed to delete managed rule group %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除托管规则组失败"})
+		return
+	}
+
+	username, _ := c.Get("username")
+	specs := make([]string, 0, len(group.Rules))
+	for _, rule := range group.Rules {
+		specs = append(specs, fmt.Sprintf("-t %s -A %s %s", rule.Table, rule.Chain, rule.Spec))
+	}
+	h.logService.LogOperation(
+		fmt.Sprint(username),
+		"删除托管规则组",
+		fmt.Sprintf("规则组: %s\n%s", group.Name, strings.Join(specs, "\n")),
+		c.ClientIP(),
+	)
+
+	c.JSON(http.StatusOK, gin.H{"message": "托管规则组已删除"})
+}
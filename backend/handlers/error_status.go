@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"iptables-management-backend/services"
+)
+
+// serviceErrorStatus 按服务层返回的错误类型选择HTTP状态码，未识别的错误为500
+func serviceErrorStatus(err error) int {
+	var validation *services.ValidationError
+	var notFound *services.NotFoundError
+	var conflict *services.ConflictError
+	switch {
+	case errors.As(err, &validation):
+		return http.StatusBadRequest
+	case errors.As(err, &notFound):
+		return http.StatusNotFound
+	case errors.As(err, &conflict):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"iptables-management-backend/services"
+)
+
+type ExposureHandler struct {
+	exposureService *services.ExposureService
+}
+
+// NewExposureHandler 创建暴露面分析处理器实例
+func NewExposureHandler(exposureService *services.ExposureService) *ExposureHandler {
+	return &ExposureHandler{
+		exposureService: exposureService,
+	}
+}
+
+// GetExposureReport 获取监听服务从各接口的可达性报告
+func (h *ExposureHandler) GetExposureReport(c *gin.Context) {
+	log.Println("[DEBUG] GetExposureReport API called")
+
+	var query struct {
+		Interface string `form:"interface"`
+		Kind      string `form:"kind"`
+		Protocol  string `form:"protocol"`
+		Port      int    `form:"port"`
+		Source    string `form:"source"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "查询参数无效: " + err.Error()})
+		return
+	}
+	if query.Kind != "" && query.Kind != "external" && query.Kind != "tunnel" && query.Kind != "docker" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind 必须是 external、tunnel 或 docker"})
+		return
+	}
+
+	report, err := h.exposureService.GetReport(services.ExposureFilter{
+		Interface: query.Interface,
+		Kind:      query.Kind,
+		Protocol:  query.Protocol,
+		Port:      query.Port,
+		Source:    query.Source,
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to build exposure report: %v", err)
+		var validation *services.ValidationError
+		if errors.As(err, &validation) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "过滤条件无效: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成暴露面报告失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"iptables-management-backend/models"
+	"iptables-management-backend/services"
+)
+
+type IPSetHandler struct {
+	ipsetService *services.IPSetService
+	logService   *services.LogService
+}
+
+// NewIPSetHandler 创建ipset处理器实例
+func NewIPSetHandler(ipsetService *services.IPSetService, logService *services.LogService) *IPSetHandler {
+	return &IPSetHandler{
+		ipsetService: ipsetService,
+		logService:   logService,
+	}
+}
+
+// logOperation 记录ipset操作日志
+func (h *IPSetHandler) logOperation(c *gin.Context, action, details string) {
+	username, _ := c.Get("username")
+	h.logService.LogOperation(fmt.Sprint(username), action, details, c.ClientIP())
+}
+
+// ListSets 列出所有ipset及条目数、引用规则
+func (h *IPSetHandler) ListSets(c *gin.Context) {
+	sets, err := h.ipsetService.ListSets()
+	if err != nil {
+		log.Printf("[ERROR] Failed to list ipsets: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取ipset列表失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sets": sets})
+}
+
+// GetSet 获取ipset详情和条目（limit 默认1000）
+func (h *IPSetHandler) GetSet(c *gin.Context) {
+	limit := 1000
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的limit参数"})
+			return
+		}
+		limit = parsed
+	}
+
+	set, err := h.ipsetService.GetSet(c.Param("name"), limit)
+	if err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": "获取ipset失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, set)
+}
+
+// CreateSet 创建ipset
+func (h *IPSetHandler) CreateSet(c *gin.Context) {
+	var request services.IPSetCreateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数格式错误: " + err.Error()})
+		return
+	}
+
+	set, err := h.ipsetService.CreateSet(request)
+	if err != nil {
+		log.Printf("[ERROR] Failed to create ipset %s: %v", request.Name, err)
+		c.JSON(serviceErrorStatus(err), gin.H{"error": "创建ipset失败: " + err.Error()})
+		return
+	}
+
+	h.logOperation(c, "创建ipset", fmt.Sprintf("创建ipset %s (%s)", set.Name, set.Type))
+	c.JSON(http.StatusCreated, set)
+}
+
+// ImportEntries 从文本或CSV批量导入条目，replace 为 true 时原子替换
+func (h *IPSetHandler) ImportEntries(c *gin.Context) {
+	var request services.IPSetImportRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数格式错误: " + err.Error()})
+		return
+	}
+
+	name := c.Param("name")
+	result, err := h.ipsetService.ImportEntries(name, request)
+	if err != nil {
+		log.Printf("[ERROR] Failed to import entries into ipset %s: %v", name, err)
+		c.JSON(serviceErrorStatus(err), gin.H{"error": "导入ipset条目失败: " + err.Error()})
+		return
+	}
+
+	action := "导入"
+	if result.Replaced {
+		action = "替换为"
+	}
+	h.logOperation(c, "导入ipset条目", fmt.Sprintf("ipset %s %s %d 个条目，跳过 %d 行无效内容", name, action, result.Imported, len(result.Invalid)))
+	c.JSON(http.StatusOK, result)
+}
+
+// AddEntry 添加单个条目
+func (h *IPSetHandler) AddEntry(c *gin.Context) {
+	var entry models.IPSetEntry
+	if err := c.ShouldBindJSON(&entry); err != nil || entry.Entry == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数格式错误，需要 entry 字段"})
+		return
+	}
+
+	name := c.Param("name")
+	if err := h.ipsetService.AddEntry(name, entry); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": "添加ipset条目失败: " + err.Error()})
+		return
+	}
+
+	h.logOperation(c, "添加ipset条目", fmt.Sprintf("ipset %s 添加 %s", name, entry.Entry))
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// DeleteEntry 删除单个条目（条目通过查询参数 entry 指定）
+func (h *IPSetHandler) DeleteEntry(c *gin.Context) {
+	entry := c.Query("entry")
+	if entry == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少 entry 参数"})
+		return
+	}
+
+	name := c.Param("name")
+	if err := h.ipsetService.DeleteEntry(name, entry); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": "删除ipset条目失败: " + err.Error()})
+		return
+	}
+
+	h.logOperation(c, "删除ipset条目", fmt.Sprintf("ipset %s 删除 %s", name, entry))
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// SwapSets 交换两个ipset的内容
+func (h *IPSetHandler) SwapSets(c *gin.Context) {
+	var request struct {
+		First  string `json:"first" binding:"required"`
+		Second string `json:"second" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数格式错误: " + err.Error()})
+		return
+	}
+
+	if err := h.ipsetService.SwapSets(request.First, request.Second); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": "交换ipset失败: " + err.Error()})
+		return
+	}
+
+	h.logOperation(c, "交换ipset", fmt.Sprintf("交换 ipset %s 和 %s", request.First, request.Second))
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// DestroySet 删除ipset，仍被规则引用时返回409
+func (h *IPSetHandler) DestroySet(c *gin.Context) {
+	name := c.Param("name")
+	if err := h.ipsetService.DestroySet(name); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": "删除ipset失败: " + err.Error()})
+		return
+	}
+
+	h.logOperation(c, "删除ipset", "删除 ipset "+name)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
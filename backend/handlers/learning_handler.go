@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"iptables-management-backend/services"
+)
+
+type LearningHandler struct {
+	learningService *services.LearningService
+	logService      *services.LogService
+}
+
+// NewLearningHandler 创建学习模式处理器实例
+func NewLearningHandler(learningService *services.LearningService, logService *services.LogService) *LearningHandler {
+	return &LearningHandler{
+		learningService: learningService,
+		logService:      logService,
+	}
+}
+
+// StartSession 创建学习会话，在所选链的默认策略之前临时记录新建连接
+func (h *LearningHandler) StartSession(c *gin.Context) {
+	var request services.LearningRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数格式错误: " + err.Error()})
+		return
+	}
+
+	username, _ := c.Get("username")
+	session, err := h.learningService.StartSession(request, fmt.Sprint(username))
+	if err != nil {
+		log.Printf("[ERROR] Failed to start learning session: %v", err)
+		var validation *services.ValidationError
+		var conflict *services.ConflictError
+		switch {
+		case errors.As(err, &validation):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "学习参数无效: " + err.Error()})
+		case errors.As(err, &conflict):
+			c.JSON(http.StatusConflict, gin.H{"error": "已有学习会话正在运行: " + err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "启动学习模式失败: " + err.Error()})
+		}
+		return
+	}
+
+	h.logService.LogOperation(
+		fmt.Sprint(username),
+		"启动学习模式",
+		fmt.Sprintf("会话 %s，规则: %s", session.ID, strings.Join(session.Rules, "; ")),
+		c.ClientIP(),
+	)
+	c.JSON(http.StatusOK, session)
+}
+
+// ListSessions 获取最近的学习会话
+func (h *LearningHandler) ListSessions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"sessions": h.learningService.ListSessions()})
+}
+
+// GetSession 获取学习会话观察到的流量和建议规则
+func (h *LearningHandler) GetSession(c *gin.Context) {
+	session, err := h.learningService.GetSession(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "学习会话不存在: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// StopSession 提前结束学习会话并删除记录规则
+func (h *LearningHandler) StopSession(c *gin.Context) {
+	session, err := h.learningService.StopSession(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "学习会话不存在: " + err.Error()})
+		return
+	}
+
+	username, _ := c.Get("username")
+	h.logService.LogOperation(fmt.Sprint(username), "停止学习模式", "会话 "+session.ID, c.ClientIP())
+	c.JSON(http.StatusOK, session)
+}
+
+// ApplyProposals 通过规则服务创建选中的建议规则，未指定时创建全部尚未创建的建议
+func (h *LearningHandler) ApplyProposals(c *gin.Context) {
+	var request struct {
+		ProposalIDs []int `json:"proposal_ids"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数格式错误: " + err.Error()})
+			return
+		}
+	}
+
+	id := c.Param("id")
+	rules, err := h.learningService.ApplyProposals(id, request.ProposalIDs)
+	if len(rules) > 0 {
+		texts := make([]string, 0, len(rules))
+		for _, rule := range rules {
+			texts = append(texts, rule.RuleText)
+		}
+		username, _ := c.Get("username")
+		h.logService.LogOperation(
+			fmt.Sprint(username),
+			"应用学习模式建议",
+			fmt.Sprintf("会话 %s，创建 %d 条规则: %s", id, len(rules), strings.Join(texts, "; ")),
+			c.ClientIP(),
+		)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to apply learning proposals: %v", err)
+		var notFound *services.NotFoundError
+		var validation *services.ValidationError
+		var conflict *services.ConflictError
+		switch {
+		case errors.As(err, &notFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "学习会话不存在: " + err.Error()})
+		case errors.As(err, &validation):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "建议规则无效: " + err.Error()})
+		case errors.As(err, &conflict):
+			c.JSON(http.StatusConflict, gin.H{"error": "学习会话尚未结束: " + err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "创建建议规则失败: " + err.Error(), "rules": rules})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
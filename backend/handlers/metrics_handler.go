@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"iptables-management-backend/services"
+)
+
+type MetricsHandler struct {
+	metricsService *services.MetricsService
+}
+
+// NewMetricsHandler 创建指标处理器实例
+func NewMetricsHandler(metricsService *services.MetricsService) *MetricsHandler {
+	return &MetricsHandler{
+		metricsService: metricsService,
+	}
+}
+
+// GetMetrics 以Prometheus文本格式输出指标（包含全部规则内容和注释）
+// 设置了 METRICS_TOKEN 环境变量时需要携带 Bearer token，未设置时只允许本机访问
+func (h *MetricsHandler) GetMetrics(c *gin.Context) {
+	if token := os.Getenv("METRICS_TOKEN"); token != "" {
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的指标访问令牌"})
+			return
+		}
+	} else if !isLoopbackRequest(c.Request) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "未设置 METRICS_TOKEN 时只允许本机访问指标"})
+		return
+	}
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(h.metricsService.RenderPrometheus()))
+}
+
+// isLoopbackRequest 请求是否来自本机（使用连接地址，不信任 X-Forwarded-For 等请求头）
+func isLoopbackRequest(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
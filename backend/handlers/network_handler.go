@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"iptables-management-backend/services"
@@ -71,7 +73,33 @@ func (h *NetworkHandler) GetBridgeRules(c *gin.Context) {
 func (h *NetworkHandler) GetNetworkConnections(c *gin.Context) {
 	log.Println("[DEBUG] GetNetworkConnections API called")
 
-	connections, err := h.networkService.GetNetworkConnections()
+	var query struct {
+		Protocol  string `form:"protocol"`
+		State     string `form:"state"`
+		Port      int    `form:"port"`
+		Address   string `form:"address"`
+		PID       int    `form:"pid"`
+		Process   string `form:"process"`
+		Container string `form:"container"`
+		NetNS     string `form:"netns"`
+		Listening bool   `form:"listening"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "查询参数无效: " + err.Error()})
+		return
+	}
+
+	connections, err := h.networkService.GetNetworkConnections(services.ConnectionFilter{
+		Protocol:  query.Protocol,
+		State:     query.State,
+		Port:      query.Port,
+		Address:   query.Address,
+		PID:       query.PID,
+		Process:   query.Process,
+		Container: query.Container,
+		NetNS:     query.NetNS,
+		Listening: query.Listening,
+	})
 	if err != nil {
 		log.Printf("[ERROR] Failed to get network connections: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取网络连接失败"})
@@ -82,13 +110,21 @@ func (h *NetworkHandler) GetNetworkConnections(c *gin.Context) {
 	c.JSON(http.StatusOK, connections)
 }
 
-// GetRouteTable 获取路由表信息
+// GetRouteTable 获取路由表信息（所有表，family=inet|inet6，table=表名或编号）
 func (h *NetworkHandler) GetRouteTable(c *gin.Context) {
 	log.Println("[DEBUG] GetRouteTable API called")
 
-	routes, err := h.networkService.GetRouteTable()
+	routes, err := h.networkService.GetRouteTable(services.RouteFilter{
+		Family: c.Query("family"),
+		Table:  c.Query("table"),
+	})
 	if err != nil {
 		log.Printf("[ERROR] Failed to get route table: %v", err)
+		var validation *services.ValidationError
+		if errors.As(err, &validation) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "family 必须是 inet 或 inet6"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取路由表失败"})
 		return
 	}
@@ -96,3 +132,116 @@ func (h *NetworkHandler) GetRouteTable(c *gin.Context) {
 	log.Printf("[DEBUG] Retrieved %d route entries", len(routes))
 	c.JSON(http.StatusOK, routes)
 }
+
+// GetRoutingRules 获取策略路由规则（ip rule）
+func (h *NetworkHandler) GetRoutingRules(c *gin.Context) {
+	log.Println("[DEBUG] GetRoutingRules API called")
+
+	rules, err := h.networkService.GetRoutingRules(c.Query("family"))
+	if err != nil {
+		log.Printf("[ERROR] Failed to get routing rules: %v", err)
+		var validation *services.ValidationError
+		if errors.As(err, &validation) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "family 必须是 inet 或 inet6"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取策略路由规则失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// ResolveRoute 解析指定数据包会使用的路由
+func (h *NetworkHandler) ResolveRoute(c *gin.Context) {
+	log.Println("[DEBUG] ResolveRoute API called")
+
+	var request struct {
+		Destination string `form:"dst" binding:"required"`
+		Source      string `form:"src"`
+		Mark        string `form:"mark"`
+		IIf         string `form:"iif"`
+		OIf         string `form:"oif"`
+		UID         *int   `form:"uid"`
+		IPProto     string `form:"ipproto"`
+		SourcePort  int    `form:"sport"`
+		DestPort    int    `form:"dport"`
+	}
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "查询参数无效: " + err.Error()})
+		return
+	}
+
+	query := services.RouteQuery{
+		Destination: request.Destination,
+		Source:      request.Source,
+		IIf:         request.IIf,
+		OIf:         request.OIf,
+		UID:         -1,
+		IPProto:     request.IPProto,
+		SourcePort:  request.SourcePort,
+		DestPort:    request.DestPort,
+	}
+	if request.UID != nil {
+		query.UID = *request.UID
+	}
+	if request.Mark != "" {
+		mark, err := strconv.ParseUint(request.Mark, 0, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mark 必须是十进制或 0x 开头的十六进制数"})
+			return
+		}
+		query.Mark = uint32(mark)
+	}
+
+	resolution, err := h.networkService.ResolveRoute(query)
+	if err != nil {
+		log.Printf("[ERROR] Failed to resolve route: %v", err)
+		var validation *services.ValidationError
+		if errors.As(err, &validation) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "地址无效: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "解析路由失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resolution)
+}
+
+// AnalyzePath 分析两个接口、地址或网段之间双向的链路径和结果
+func (h *NetworkHandler) AnalyzePath(c *gin.Context) {
+	log.Println("[DEBUG] AnalyzePath API called")
+
+	var request struct {
+		From       string `form:"from" binding:"required"`
+		To         string `form:"to" binding:"required"`
+		Protocol   string `form:"protocol"`
+		SourcePort int    `form:"sport"`
+		DestPort   int    `form:"dport"`
+	}
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "查询参数无效: " + err.Error()})
+		return
+	}
+
+	analysis, err := h.networkService.AnalyzePath(services.PathQuery{
+		From:       request.From,
+		To:         request.To,
+		Protocol:   request.Protocol,
+		SourcePort: request.SourcePort,
+		DestPort:   request.DestPort,
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to analyze path: %v", err)
+		var validation *services.ValidationError
+		if errors.As(err, &validation) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数无效: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "路径分析失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, analysis)
+}
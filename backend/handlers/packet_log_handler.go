@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"iptables-management-backend/services"
+)
+
+type PacketLogHandler struct {
+	packetLogService *services.PacketLogService
+}
+
+// NewPacketLogHandler 创建数据包日志处理器实例
+func NewPacketLogHandler(packetLogService *services.PacketLogService) *PacketLogHandler {
+	return &PacketLogHandler{
+		packetLogService: packetLogService,
+	}
+}
+
+// ListLogs 搜索 LOG/NFLOG 产生的数据包日志（最新在前）
+func (h *PacketLogHandler) ListLogs(c *gin.Context) {
+	query := services.PacketLogQuery{
+		Action:      c.Query("action"),
+		Prefix:      c.Query("prefix"),
+		Chain:       c.Query("chain"),
+		Interface:   c.Query("interface"),
+		Protocol:    c.Query("protocol"),
+		Source:      c.Query("src"),
+		Destination: c.Query("dst"),
+		Search:      c.Query("q"),
+	}
+	var err error
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"port", &query.Port}, {"limit", &query.Limit}, {"offset", &query.Offset}} {
+		if value := c.Query(param.name); value != "" {
+			if *param.value, err = strconv.Atoi(value); err != nil || *param.value < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "查询参数无效: " + param.name})
+				return
+			}
+		}
+	}
+	for _, param := range []struct {
+		name  string
+		value *time.Time
+	}{{"from", &query.From}, {"to", &query.To}} {
+		if value := c.Query(param.name); value != "" {
+			if *param.value, err = time.Parse(time.RFC3339, value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "时间格式无效（需要 RFC3339）: " + param.name})
+				return
+			}
+		}
+	}
+
+	logs, total, err := h.packetLogService.Query(query)
+	if err != nil {
+		log.Printf("[ERROR] Failed to query packet logs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询数据包日志失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"logs": logs, "total": total})
+}
+
+// GetSummary 汇总最近一段时间（hours，默认24）的日志和采集器状态
+func (h *PacketLogHandler) GetSummary(c *gin.Context) {
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	if err != nil || hours <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "查询参数无效: hours"})
+		return
+	}
+
+	summary, err := h.packetLogService.GetSummary(time.Now().Add(-time.Duration(hours) * time.Hour))
+	if err != nil {
+		log.Printf("[ERROR] Failed to summarize packet logs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "汇总数据包日志失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
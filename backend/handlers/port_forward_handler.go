@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"iptables-management-backend/models"
+	"iptables-management-backend/services"
+)
+
+type PortForwardHandler struct {
+	portForwardService *services.PortForwardService
+	logService         *services.LogService
+}
+
+// NewPortForwardHandler 创建端口转发处理器实例
+func NewPortForwardHandler(portForwardService *services.PortForwardService, logService *services.LogService) *PortForwardHandler {
+	return &PortForwardHandler{
+		portForwardService: portForwardService,
+		logService:         logService,
+	}
+}
+
+// portForwardErrorStatus 记录不存在返回404，其余按服务层错误类型选择状态码
+func portForwardErrorStatus(err error) int {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusNotFound
+	}
+	return serviceErrorStatus(err)
+}
+
+// bindPortForward 解析请求体，未指定 enabled 时默认启用
+func bindPortForward(c *gin.Context) (*models.PortForward, bool) {
+	forward := &models.PortForward{Enabled: true}
+	if err := c.ShouldBindJSON(forward); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数格式错误: " + err.Error()})
+		return nil, false
+	}
+	return forward, true
+}
+
+// ListForwards 获取端口转发列表及规则存在状态
+func (h *PortForwardHandler) ListForwards(c *gin.Context) {
+	forwards, err := h.portForwardService.ListForwards()
+	if err != nil {
+		log.Printf("[ERROR] Failed to list port forwards: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取端口转发列表失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"forwards": forwards})
+}
+
+// PreviewForward 预览端口转发将生成的规则
+func (h *PortForwardHandler) PreviewForward(c *gin.Context) {
+	forward, ok := bindPortForward(c)
+	if !ok {
+		return
+	}
+	commands, err := h.portForwardService.PreviewForward(*forward)
+	if err != nil {
+		c.JSON(portForwardErrorStatus(err), gin.H{"error": "端口转发配置无效: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"commands": commands})
+}
+
+// CreateForward 创建端口转发并应用规则
+func (h *PortForwardHandler) CreateForward(c *gin.Context) {
+	forward, ok := bindPortForward(c)
+	if !ok {
+		return
+	}
+
+	username, _ := c.Get("username")
+	status, err := h.portForwardService.CreateForward(forward, fmt.Sprint(username))
+	if err != nil {
+		log.Printf("[ERROR] Failed to create port forward %s: %v", forward.Name, err)
+		c.JSON(portForwardErrorStatus(err), gin.H{"error": "创建端口转发失败: " + err.Error()})
+		return
+	}
+
+	h.logOperation(c, "创建端口转发", status)
+	c.JSON(http.StatusCreated, status)
+}
+
+// UpdateForward 修改端口转发并重新应用规则
+func (h *PortForwardHandler) UpdateForward(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的端口转发ID"})
+		return
+	}
+	forward, ok := bindPortForward(c)
+	if !ok {
+		return
+	}
+
+	username, _ := c.Get("username")
+	status, err := h.portForwardService.UpdateForward(uint(id), forward, fmt.Sprint(username))
+	if err != nil {
+		log.Printf("[ERROR] Failed to update port forward %d: %v", id, err)
+		c.JSON(portForwardErrorStatus(err), gin.H{"error": "修改端口转发失败: " + err.Error()})
+		return
+	}
+
+	h.logOperation(c, "修改端口转发", status)
+	c.JSON(http.StatusOK, status)
+}
+
+// DeleteForward 删除端口转发及其全部规则
+func (h *PortForwardHandler) DeleteForward(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的端口转发ID"})
+		return
+	}
+
+	forward, err := h.portForwardService.DeleteForward(uint(id))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "端口转发不存在"})
+			return
+		}
+		log.Printf("[ERROR] Failed to delete port forward %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除端口转发失败: " + err.Error()})
+		return
+	}
+
+	username, _ := c.Get("username")
+	h.logService.LogOperation(fmt.Sprint(username), "删除端口转发", "删除端口转发 "+forward.Name+" 及其规则组 "+forward.GroupName, c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{"message": "端口转发已删除"})
+}
+
+// logOperation 记录端口转发变更及生成的规则
+func (h *PortForwardHandler) logOperation(c *gin.Context, operation string, status *services.PortForwardStatus) {
+	commands := make([]string, 0, len(status.Rules))
+	for _, rule := range status.Rules {
+		commands = append(commands, rule.Command)
+	}
+	username, _ := c.Get("username")
+	h.logService.LogOperation(
+		fmt.Sprint(username),
+		operation,
+		fmt.Sprintf("端口转发 %s（启用: %v）:\n%s", status.Name, status.Enabled, strings.Join(commands, "\n")),
+		c.ClientIP(),
+	)
+}
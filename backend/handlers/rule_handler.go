@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"log"
 	"net/http"
 	"strconv"
@@ -75,7 +76,7 @@ func (h *RuleHandler) CreateRule(c *gin.Context) {
 	}
 
 	if err := h.ruleService.CreateRule(&rule); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建规则失败"})
+		c.JSON(serviceErrorStatus(err), gin.H{"error": "创建规则失败: " + err.Error()})
 		return
 	}
 
@@ -100,14 +101,26 @@ func (h *RuleHandler) UpdateRule(c *gin.Context) {
 		return
 	}
 
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	var rule models.IPTablesRule
-	if err := c.ShouldBindJSON(&rule); err != nil {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := h.ruleService.UpdateRule(uint(id), &rule); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新规则失败"})
+	// 请求中带有 match_set 时整体覆盖集合匹配字段（空字符串表示清除引用）
+	_, replaceMatchSet := fields["match_set"]
+	if err := h.ruleService.UpdateRule(uint(id), &rule, replaceMatchSet); err != nil {
+		c.JSON(serviceErrorStatus(err), gin.H{"error": "更新规则失败: " + err.Error()})
 		return
 	}
 
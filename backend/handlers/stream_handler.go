@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"iptables-management-backend/services"
+)
+
+type StreamHandler struct {
+	hub *services.EventHub
+}
+
+// NewStreamHandler 创建实时事件流处理器实例
+func NewStreamHandler(hub *services.EventHub) *StreamHandler {
+	return &StreamHandler{
+		hub: hub,
+	}
+}
+
+// IssueTicket 签发一次性的事件流票据，EventSource 通过 ticket 查询参数使用
+func (h *StreamHandler) IssueTicket(c *gin.Context) {
+	username, _ := c.Get("username")
+	role, _ := c.Get("role")
+	ticket, err := services.IssueStreamTicket(fmt.Sprint(username), fmt.Sprint(role))
+	if err != nil {
+		log.Printf("[ERROR] Failed to issue stream ticket: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "签发事件流票据失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, ticket)
+}
+
+// StreamEvents 以SSE推送规则计数增量、接口速率、规则集变化和操作日志
+// 查询参数: types（逗号分隔）、table、chain、interface
+func (h *StreamHandler) StreamEvents(c *gin.Context) {
+	filter := services.EventFilter{
+		Table:     c.Query("table"),
+		Chain:     c.Query("chain"),
+		Interface: c.Query("interface"),
+	}
+	if types := c.Query("types"); types != "" {
+		for _, eventType := range strings.Split(types, ",") {
+			if eventType = strings.TrimSpace(eventType); eventType != "" {
+				filter.Types = append(filter.Types, eventType)
+			}
+		}
+	}
+
+	sub := h.hub.Subscribe(filter)
+	defer h.hub.Unsubscribe(sub)
+	log.Printf("[DEBUG] Event stream opened: %+v", filter)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	c.SSEvent("ready", gin.H{"filter": filter})
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-sub.Events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("heartbeat", gin.H{"timestamp": time.Now(), "dropped": sub.Dropped()})
+			return true
+		}
+	})
+	log.Printf("[DEBUG] Event stream closed")
+}
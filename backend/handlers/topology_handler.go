@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"iptables-management-backend/services"
 	"log"
@@ -20,11 +21,8 @@ func NewTopologyHandler(topologyService *services.TopologyService) *TopologyHand
 	}
 }
 
-// GetTopology 获取拓扑图数据（支持查询参数）
-func (h *TopologyHandler) GetTopology(c *gin.Context) {
-	log.Println("[DEBUG] GetTopology API called")
-
-	// 解析查询参数
+// parseTopologyOptions 解析拓扑查询参数（过滤和分页）
+func parseTopologyOptions(c *gin.Context) services.TopologyOptions {
 	options := services.TopologyOptions{
 		ProtocolFilter:  c.Query("protocol"),
 		ChainFilter:     c.Query("chain"),
@@ -50,6 +48,15 @@ func (h *TopologyHandler) GetTopology(c *gin.Context) {
 		}
 	}
 
+	return options
+}
+
+// GetTopology 获取拓扑图数据（支持查询参数）
+func (h *TopologyHandler) GetTopology(c *gin.Context) {
+	log.Println("[DEBUG] GetTopology API called")
+
+	options := parseTopologyOptions(c)
+
 	var topology interface{}
 	var err error
 
@@ -119,8 +126,7 @@ func (h *TopologyHandler) GetTopologyStats(c *gin.Context) {
 func (h *TopologyHandler) RefreshTopology(c *gin.Context) {
 	log.Println("[DEBUG] RefreshTopology API called")
 
-	// 这里可以添加缓存失效逻辑
-	// h.topologyService.InvalidateCache()
+	h.topologyService.InvalidateCache()
 
 	topology, err := h.topologyService.GetTopologyData()
 	if err != nil {
@@ -143,13 +149,13 @@ func (h *TopologyHandler) RefreshTopology(c *gin.Context) {
 	})
 }
 
-// ExportTopology 导出拓扑数据
+// ExportTopology 导出拓扑数据（json、csv、graphml、dot），支持与 GetTopology 相同的过滤参数
 func (h *TopologyHandler) ExportTopology(c *gin.Context) {
 	log.Println("[DEBUG] ExportTopology API called")
 
 	format := c.DefaultQuery("format", "json")
 
-	topology, err := h.topologyService.GetTopologyData()
+	topology, err := h.topologyService.GetTopologyDataWithOptions(parseTopologyOptions(c))
 	if err != nil {
 		log.Printf("[ERROR] Failed to export topology data: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -166,14 +172,37 @@ func (h *TopologyHandler) ExportTopology(c *gin.Context) {
 		c.Header("Content-Disposition", "attachment; filename=topology.json")
 		c.JSON(http.StatusOK, topology)
 	case "csv":
-		// 这里可以实现CSV导出逻辑
-		c.Header("Content-Type", "text/csv")
-		c.Header("Content-Disposition", "attachment; filename=topology.csv")
-		// 实现CSV转换逻辑
-		c.String(http.StatusOK, "CSV export not implemented yet")
+		data, err := services.ExportTopologyCSV(topology)
+		if err != nil {
+			log.Printf("[ERROR] Failed to export topology as CSV: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to export topology data",
+				"details": err.Error(),
+				"code":    "EXPORT_ERROR",
+			})
+			return
+		}
+		c.Header("Content-Disposition", "attachment; filename=topology-csv.zip")
+		c.Data(http.StatusOK, "application/zip", data)
+	case "graphml":
+		data, err := services.ExportTopologyGraphML(topology)
+		if err != nil {
+			log.Printf("[ERROR] Failed to export topology as GraphML: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to export topology data",
+				"details": err.Error(),
+				"code":    "EXPORT_ERROR",
+			})
+			return
+		}
+		c.Header("Content-Disposition", "attachment; filename=topology.graphml")
+		c.Data(http.StatusOK, "application/graphml+xml", data)
+	case "dot":
+		c.Header("Content-Disposition", "attachment; filename=topology.dot")
+		c.Data(http.StatusOK, "text/vnd.graphviz", services.ExportTopologyDOT(topology))
 	default:
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Unsupported format. Use 'json' or 'csv'",
+			"error": "Unsupported format. Use 'json', 'csv', 'graphml' or 'dot'",
 			"code":  "INVALID_FORMAT",
 		})
 	}
@@ -209,3 +238,140 @@ func (h *TopologyHandler) GetTopologyHealth(c *gin.Context) {
 		"data":    health,
 	})
 }
+
+// CreateSnapshot 手动保存拓扑快照
+func (h *TopologyHandler) CreateSnapshot(c *gin.Context) {
+	var request struct {
+		Note string `json:"note"`
+	}
+	c.ShouldBindJSON(&request)
+
+	username, _ := c.Get("username")
+	snapshot, err := h.topologyService.CreateSnapshot(services.SnapshotTriggerManual, request.Note, fmt.Sprint(username))
+	if err != nil {
+		log.Printf("[ERROR] Failed to create topology snapshot: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to create topology snapshot",
+			"details": err.Error(),
+			"code":    "SNAPSHOT_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    snapshot,
+	})
+}
+
+// ListSnapshots 列出拓扑快照（支持 from/to RFC3339 时间范围和 limit）
+func (h *TopologyHandler) ListSnapshots(c *gin.Context) {
+	var from, to time.Time
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = time.Parse(time.RFC3339, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from time", "code": "INVALID_PARAMS"})
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = time.Parse(time.RFC3339, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to time", "code": "INVALID_PARAMS"})
+			return
+		}
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	snapshots, err := h.topologyService.ListSnapshots(from, to, limit)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list topology snapshots: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list topology snapshots",
+			"details": err.Error(),
+			"code":    "SNAPSHOT_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    snapshots,
+	})
+}
+
+// GetSnapshot 获取单个拓扑快照
+func (h *TopologyHandler) GetSnapshot(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid snapshot id", "code": "INVALID_PARAMS"})
+		return
+	}
+
+	snapshot, err := h.topologyService.GetSnapshot(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Topology snapshot not found",
+			"details": err.Error(),
+			"code":    "SNAPSHOT_NOT_FOUND",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    snapshot,
+	})
+}
+
+// DeleteSnapshot 删除拓扑快照
+func (h *TopologyHandler) DeleteSnapshot(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid snapshot id", "code": "INVALID_PARAMS"})
+		return
+	}
+
+	if err := h.topologyService.DeleteSnapshot(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Topology snapshot not found",
+			"details": err.Error(),
+			"code":    "SNAPSHOT_NOT_FOUND",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// DiffSnapshots 比较两个拓扑快照；未提供 to 时与当前拓扑比较
+func (h *TopologyHandler) DiffSnapshots(c *gin.Context) {
+	fromID, err := strconv.ParseUint(c.Query("from"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from snapshot id", "code": "INVALID_PARAMS"})
+		return
+	}
+
+	var toID uint64
+	if value := c.Query("to"); value != "" && value != "current" {
+		if toID, err = strconv.ParseUint(value, 10, 32); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to snapshot id", "code": "INVALID_PARAMS"})
+			return
+		}
+	}
+
+	diff, err := h.topologyService.DiffSnapshots(uint(fromID), uint(toID))
+	if err != nil {
+		log.Printf("[ERROR] Failed to diff topology snapshots: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to diff topology snapshots",
+			"details": err.Error(),
+			"code":    "SNAPSHOT_DIFF_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    diff,
+	})
+}
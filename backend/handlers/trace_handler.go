@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"iptables-management-backend/services"
+)
+
+type TraceHandler struct {
+	traceService *services.TraceService
+	logService   *services.LogService
+}
+
+// NewTraceHandler 创建数据包跟踪处理器实例
+func NewTraceHandler(traceService *services.TraceService, logService *services.LogService) *TraceHandler {
+	return &TraceHandler{
+		traceService: traceService,
+		logService:   logService,
+	}
+}
+
+// StartSession 创建跟踪会话；wait=true 时等待会话结束后返回完整路径
+func (h *TraceHandler) StartSession(c *gin.Context) {
+	var request services.TraceRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数格式错误: " + err.Error()})
+		return
+	}
+
+	username, _ := c.Get("username")
+	session, err := h.traceService.StartSession(request, fmt.Sprint(username))
+	if err != nil {
+		log.Printf("[ERROR] Failed to start trace session: %v", err)
+		var validation *services.ValidationError
+		var conflict *services.ConflictError
+		switch {
+		case errors.As(err, &validation):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "跟踪条件无效: " + err.Error()})
+		case errors.As(err, &conflict):
+			c.JSON(http.StatusConflict, gin.H{"error": "已有跟踪会话正在运行: " + err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "启动跟踪失败: " + err.Error()})
+		}
+		return
+	}
+
+	h.logService.LogOperation(
+		fmt.Sprint(username),
+		"启动数据包跟踪",
+		fmt.Sprintf("会话 %s，规则: %s", session.ID, strings.Join(session.Rules, "; ")),
+		c.ClientIP(),
+	)
+
+	if c.Query("wait") == "true" {
+		if session, err = h.traceService.WaitSession(session.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取跟踪结果失败: " + err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// ListSessions 获取最近的跟踪会话
+func (h *TraceHandler) ListSessions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"sessions": h.traceService.ListSessions()})
+}
+
+// GetSession 获取跟踪会话及已收集的记录；wait=true 时等待会话结束
+func (h *TraceHandler) GetSession(c *gin.Context) {
+	get := h.traceService.GetSession
+	if c.Query("wait") == "true" {
+		get = h.traceService.WaitSession
+	}
+	session, err := get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "跟踪会话不存在: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// StopSession 提前结束跟踪会话并删除 TRACE 规则
+func (h *TraceHandler) StopSession(c *gin.Context) {
+	session, err := h.traceService.StopSession(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "跟踪会话不存在: " + err.Error()})
+		return
+	}
+
+	username, _ := c.Get("username")
+	h.logService.LogOperation(fmt.Sprint(username), "停止数据包跟踪", "会话 "+session.ID, c.ClientIP())
+	c.JSON(http.StatusOK, session)
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"iptables-management-backend/config"
 	"iptables-management-backend/controllers"
@@ -34,12 +36,65 @@ func main() {
 	tableService := services.NewTableService()
 	topologyService := services.NewTopologyService()
 	networkService := services.NewNetworkService()
+	metricsService := services.NewMetricsService(networkService)
+	counterService := services.NewCounterService(networkService)
+	streamService := services.NewStreamService(networkService, services.DefaultEventHub)
+	dockerPortService := services.NewDockerPortService(networkService)
+	dockerUserService := services.NewDockerUserService(networkService, logService)
+	changeService := services.NewConnectivityChangeService(dockerUserService)
+	tunnelRuleService := services.NewTunnelRuleService(networkService, dockerUserService)
+	conntrackService := services.NewConntrackService()
+	exposureService := services.NewExposureService(networkService)
+	ipsetService := services.NewIPSetService()
+	portForwardService := services.NewPortForwardService(dockerUserService)
+	traceService := services.NewTraceService()
+	learningService := services.NewLearningService(ruleService)
+	packetLogService := services.NewPacketLogService()
 
 	// 创建默认用户
 	if err := authService.CreateDefaultUsers(); err != nil {
 		log.Printf("Failed to create default users: %v", err)
 	}
 
+	// 启动计数器采样（默认每60秒）
+	sampleInterval := 60 * time.Second
+	if value, err := strconv.Atoi(os.Getenv("COUNTER_SAMPLE_INTERVAL")); err == nil && value > 0 {
+		sampleInterval = time.Duration(value) * time.Second
+	}
+	counterService.StartSampler(sampleInterval)
+
+	// 启动实时事件轮询（仅在有订阅者时读取计数器）
+	streamService.Start(2 * time.Second)
+
+	// 定期保存拓扑快照（默认每15分钟，保留7天）
+	snapshotInterval := 15 * time.Minute
+	if value, err := strconv.Atoi(os.Getenv("TOPOLOGY_SNAPSHOT_INTERVAL")); err == nil && value > 0 {
+		snapshotInterval = time.Duration(value) * time.Second
+	}
+	topologyService.StartSnapshotScheduler(snapshotInterval, 7*24*time.Hour)
+
+	// 监视DOCKER-USER托管规则，Docker重建链后自动重新应用（默认每30秒检查）
+	dockerUserInterval := 30 * time.Second
+	if value, err := strconv.Atoi(os.Getenv("DOCKER_USER_CHECK_INTERVAL")); err == nil && value > 0 {
+		dockerUserInterval = time.Duration(value) * time.Second
+	}
+	dockerUserService.StartWatcher(dockerUserInterval)
+
+	// 删除上次进程退出时遗留的 TRACE 规则
+	traceService.CleanupStaleRules()
+	learningService.CleanupStaleRules()
+
+	// 采集 LOG/NFLOG 数据包日志（默认读取 /dev/kmsg，保留72小时）
+	packetLogSource := os.Getenv("PACKET_LOG_SOURCE")
+	if packetLogSource == "" {
+		packetLogSource = "kmsg"
+	}
+	packetLogRetention := 72 * time.Hour
+	if value, err := strconv.Atoi(os.Getenv("PACKET_LOG_RETENTION_HOURS")); err == nil && value > 0 {
+		packetLogRetention = time.Duration(value) * time.Hour
+	}
+	packetLogService.Start(packetLogSource, packetLogRetention)
+
 	// 创建处理器实例
 	authHandler := handlers.NewAuthHandler(authService, logService)
 	ruleHandler := handlers.NewRuleHandler(ruleService, logService)
@@ -48,10 +103,23 @@ func main() {
 	topologyHandler := handlers.NewTopologyHandler(topologyService)
 	networkHandler := handlers.NewNetworkHandler(networkService, logService)
 	chainTableHandler := handlers.NewChainTableHandler(tableService, networkService, ruleService, logService)
-	tunnelController := controllers.NewTunnelController()
+	metricsHandler := handlers.NewMetricsHandler(metricsService)
+	counterHandler := handlers.NewCounterHandler(counterService)
+	streamHandler := handlers.NewStreamHandler(services.DefaultEventHub)
+	dockerPortHandler := handlers.NewDockerPortHandler(dockerPortService)
+	dockerUserHandler := handlers.NewDockerUserHandler(dockerUserService, logService)
+	conntrackHandler := handlers.NewConntrackHandler(conntrackService, logService)
+	exposureHandler := handlers.NewExposureHandler(exposureService)
+	ipsetHandler := handlers.NewIPSetHandler(ipsetService, logService)
+	portForwardHandler := handlers.NewPortForwardHandler(portForwardService, logService)
+	traceHandler := handlers.NewTraceHandler(traceService, logService)
+	learningHandler := handlers.NewLearningHandler(learningService, logService)
+	packetLogHandler := handlers.NewPacketLogHandler(packetLogService)
+	tunnelController := controllers.NewTunnelController(networkService, counterService, dockerUserService, changeService, tunnelRuleService, logService)
 
 	// 创建Gin路由器
-	r := gin.Default()
+	r := gin.New()
+	r.Use(middleware.RequestLogger(), gin.Recovery())
 
 	// 配置CORS
 	config := cors.DefaultConfig()
@@ -88,6 +156,9 @@ func main() {
 		// 认证路由
 		api.POST("/login", authHandler.Login)
 
+		// 实时事件流（SSE，EventSource 通过一次性 ticket 查询参数认证）
+		api.GET("/stream/events", middleware.StreamTicketAuth(), streamHandler.StreamEvents)
+
 		// 需要认证的路由
 		auth := api.Group("/")
 		auth.Use(middleware.AuthMiddleware())
@@ -103,6 +174,13 @@ func main() {
 			auth.GET("/rules/compare", ruleHandler.CompareSystemAndDatabaseRules)
 			auth.POST("/rules/sync", ruleHandler.SyncSystemRules)
 
+			// 学习模式
+			auth.GET("/rules/learning", learningHandler.ListSessions)
+			auth.POST("/rules/learning", learningHandler.StartSession)
+			auth.GET("/rules/learning/:id", learningHandler.GetSession)
+			auth.POST("/rules/learning/:id/stop", learningHandler.StopSession)
+			auth.POST("/rules/learning/:id/apply", learningHandler.ApplyProposals)
+
 			// 统计信息
 			auth.GET("/statistics", ruleHandler.GetStatistics)
 
@@ -121,13 +199,41 @@ func main() {
 			auth.POST("/topology/refresh", topologyHandler.RefreshTopology)
 			auth.GET("/topology/export", topologyHandler.ExportTopology)
 			auth.GET("/topology/health", topologyHandler.GetTopologyHealth)
+			auth.GET("/topology/snapshots", topologyHandler.ListSnapshots)
+			auth.POST("/topology/snapshots", topologyHandler.CreateSnapshot)
+			auth.GET("/topology/snapshots/diff", topologyHandler.DiffSnapshots)
+			auth.GET("/topology/snapshots/:id", topologyHandler.GetSnapshot)
+			auth.DELETE("/topology/snapshots/:id", topologyHandler.DeleteSnapshot)
 
 			// 网络接口管理
 			auth.GET("/network/interfaces", networkHandler.GetInterfaces)
 			auth.GET("/docker/bridges", networkHandler.GetDockerBridges)
+			auth.GET("/docker/ports", dockerPortHandler.GetPortMappings)
 			auth.GET("/bridges/:name/rules", networkHandler.GetBridgeRules)
 			auth.GET("/network/connections", networkHandler.GetNetworkConnections)
 			auth.GET("/network/routes", networkHandler.GetRouteTable)
+			auth.GET("/network/routes/rules", networkHandler.GetRoutingRules)
+			auth.GET("/network/routes/resolve", networkHandler.ResolveRoute)
+			auth.GET("/network/path", networkHandler.AnalyzePath)
+			auth.GET("/network/trace", traceHandler.ListSessions)
+			auth.POST("/network/trace", traceHandler.StartSession)
+			auth.GET("/network/trace/:id", traceHandler.GetSession)
+			auth.POST("/network/trace/:id/stop", traceHandler.StopSession)
+			auth.GET("/packet-logs", packetLogHandler.ListLogs)
+			auth.GET("/packet-logs/summary", packetLogHandler.GetSummary)
+			auth.GET("/network/conntrack", conntrackHandler.ListEntries)
+			auth.POST("/network/conntrack/delete", conntrackHandler.DeleteEntries)
+			auth.GET("/network/exposure", exposureHandler.GetExposureReport)
+
+			// ipset 管理
+			auth.GET("/ipsets", ipsetHandler.ListSets)
+			auth.POST("/ipsets", ipsetHandler.CreateSet)
+			auth.POST("/ipsets/swap", ipsetHandler.SwapSets)
+			auth.GET("/ipsets/:name", ipsetHandler.GetSet)
+			auth.DELETE("/ipsets/:name", ipsetHandler.DestroySet)
+			auth.POST("/ipsets/:name/import", ipsetHandler.ImportEntries)
+			auth.POST("/ipsets/:name/entries", ipsetHandler.AddEntry)
+			auth.DELETE("/ipsets/:name/entries", ipsetHandler.DeleteEntry)
 
 			// 五链四表可视化
 			auth.GET("/chain-table-data", chainTableHandler.GetChainTableData)
@@ -142,6 +248,35 @@ func main() {
 			auth.GET("/tunnel/analyze-communication", tunnelController.AnalyzeTunnelDockerCommunication)
 			auth.POST("/tunnel/generate-rules", tunnelController.GenerateTunnelDockerRules)
 			auth.POST("/tunnel/fix-connectivity", tunnelController.FixConnectivity)
+			auth.GET("/tunnel/fix-connectivity/plans/:id", tunnelController.GetConnectivityPlan)
+			auth.POST("/tunnel/fix-connectivity/plans/:id/execute", tunnelController.ExecuteConnectivityPlan)
+			auth.GET("/tunnel/fix-connectivity/history", tunnelController.GetConnectivityHistory)
+			auth.POST("/tunnel/fix-connectivity/history/:id/rollback", tunnelController.RollbackConnectivityFix)
+
+			// DOCKER-USER 托管规则
+			auth.GET("/docker-user/status", dockerUserHandler.GetStatus)
+			auth.POST("/docker-user/reconcile", dockerUserHandler.Reconcile)
+			auth.DELETE("/docker-user/groups/:id", dockerUserHandler.DeleteGroup)
+			auth.GET("/rule-groups", dockerUserHandler.ListGroups)
+			auth.GET("/rule-groups/:id", dockerUserHandler.GetGroup)
+			auth.DELETE("/rule-groups/:id", dockerUserHandler.DeleteGroup)
+
+			// 端口转发（DNAT）
+			auth.GET("/port-forwards", portForwardHandler.ListForwards)
+			auth.POST("/port-forwards", portForwardHandler.CreateForward)
+			auth.POST("/port-forwards/preview", portForwardHandler.PreviewForward)
+			auth.PUT("/port-forwards/:id", portForwardHandler.UpdateForward)
+			auth.DELETE("/port-forwards/:id", portForwardHandler.DeleteForward)
+
+			// 计数器历史（速率与总量）
+			auth.GET("/counters/interfaces/:name", counterHandler.GetInterfaceHistory)
+			auth.GET("/counters/rules", counterHandler.GetRuleTotals)
+			auth.GET("/counters/rules/:key", counterHandler.GetRuleHistory)
+			auth.GET("/counters/usage", counterHandler.GetRuleUsageReport)
+			auth.GET("/counters/pairs", counterHandler.GetPairHistory)
+
+			// 实时事件流票据
+			auth.POST("/stream/ticket", streamHandler.IssueTicket)
 
 			// 测试规则（模拟）
 			auth.POST("/test-rule", func(c *gin.Context) {
@@ -180,6 +315,9 @@ func main() {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// Prometheus指标
+	r.GET("/metrics", metricsHandler.GetMetrics)
+
 	// 获取端口
 	port := os.Getenv("PORT")
 	if port == "" {
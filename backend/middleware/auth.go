@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
+	"iptables-management-backend/services"
 )
 
 // Claims JWT声明结构
@@ -70,4 +71,27 @@ func AdminRequired() gin.HandlerFunc {
 		}
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// StreamTicketAuth 事件流认证：EventSource 无法设置请求头，使用 ticket 查询参数传递一次性票据，
+// 会话JWT不会出现在URL中；没有票据时按 Authorization 请求头认证
+func StreamTicketAuth() gin.HandlerFunc {
+	authenticate := AuthMiddleware()
+	return func(c *gin.Context) {
+		id := c.Query("ticket")
+		if id == "" {
+			authenticate(c)
+			return
+		}
+
+		ticket, err := services.ConsumeStreamTicket(id)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid stream ticket"})
+			c.Abort()
+			return
+		}
+		c.Set("username", ticket.Username)
+		c.Set("role", ticket.Role)
+		c.Next()
+	}
+}
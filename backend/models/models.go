@@ -28,6 +28,9 @@ type IPTablesRule struct {
 	Packets       int64     `json:"packets" gorm:"default:0"`
 	Bytes         int64     `json:"bytes" gorm:"default:0"`
 	Policy        string    `json:"policy" gorm:"size:20"`
+	MatchSet      string    `json:"match_set" gorm:"size:31"`       // -m set --match-set 引用的ipset
+	MatchSetFlags string    `json:"match_set_flags" gorm:"size:20"` // src、dst 或 src,dst（维度与集合类型一致）
+	MatchSetNot   bool      `json:"match_set_not"`                  // 取反匹配（! --match-set）
 	RuleText      string    `json:"rule_text" gorm:"type:text"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
@@ -36,14 +39,26 @@ type IPTablesRule struct {
 // NetworkInterface 网络接口模型
 type NetworkInterface struct {
 	Name        string         `json:"name"`
+	Index       int            `json:"index"`
 	Type        string         `json:"type"`
+	Kind        string         `json:"kind,omitempty"` // 内核链路类型（IFLA_INFO_KIND）：veth、bridge、vlan、vxlan、tun、wireguard 等
 	State       string         `json:"state"`
+	OperState   string         `json:"oper_state"`
 	IPAddresses []string       `json:"ip_addresses"`
+	PeerAddress string         `json:"peer_address,omitempty"` // 点对点接口的对端地址
 	MACAddress  string         `json:"mac_address"`
 	MTU         int            `json:"mtu"`
+	TxQueueLen  int            `json:"tx_queue_len"`
 	IsUp        bool           `json:"is_up"`
 	IsDocker    bool           `json:"is_docker"`
 	DockerType  string         `json:"docker_type,omitempty"`
+	Master      string         `json:"master,omitempty"`       // 所属网桥/bond
+	ParentIndex int            `json:"parent_index,omitempty"` // IFLA_LINK：VLAN的下层接口或veth对端的ifindex
+	Parent      string         `json:"parent,omitempty"`       // 同一命名空间内的下层接口/对端名称
+	LinkNetNSID *int           `json:"link_netnsid,omitempty"` // 对端所在网络命名空间的nsid
+	VLAN        *VLANInfo      `json:"vlan,omitempty"`
+	VXLAN       *VXLANInfo     `json:"vxlan,omitempty"`
+	Tun         *TunInfo       `json:"tun,omitempty"`
 	Statistics  InterfaceStats `json:"statistics"`
 }
 
@@ -55,6 +70,35 @@ type InterfaceStats struct {
 	TxPackets int64 `json:"tx_packets"`
 	RxErrors  int64 `json:"rx_errors"`
 	TxErrors  int64 `json:"tx_errors"`
+	RxDropped int64 `json:"rx_dropped"`
+	TxDropped int64 `json:"tx_dropped"`
+	Multicast int64 `json:"multicast"`
+}
+
+// VLANInfo VLAN子接口信息
+type VLANInfo struct {
+	ID       int    `json:"id"`
+	Protocol string `json:"protocol"` // 802.1Q 或 802.1ad
+}
+
+// VXLANInfo VXLAN隧道信息
+type VXLANInfo struct {
+	VNI     int    `json:"vni"`
+	Group   string `json:"group,omitempty"` // 组播组或单播远端地址
+	Local   string `json:"local,omitempty"`
+	Device  string `json:"device,omitempty"` // 承载接口
+	DstPort int    `json:"dst_port"`
+}
+
+// TunInfo tun/tap设备信息
+type TunInfo struct {
+	Mode       string `json:"mode"`  // tun 或 tap
+	Owner      int    `json:"owner"` // -1 表示未设置
+	Group      int    `json:"group"`
+	Persist    bool   `json:"persist"`
+	MultiQueue bool   `json:"multi_queue"`
+	VnetHdr    bool   `json:"vnet_hdr"`
+	Queues     int    `json:"queues,omitempty"`
 }
 
 // DockerBridge Docker网桥信息
@@ -68,6 +112,9 @@ type DockerBridge struct {
 	Containers []DockerContainer `json:"containers"`
 	Rules      []IPTablesRule    `json:"rules"`
 	Interface  NetworkInterface  `json:"interface"`
+	Internal   bool              `json:"internal"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Source     string            `json:"source"` // docker_api: 来自Docker Engine API, system: 系统命令推断
 }
 
 // ConnectivityFixResult 连通性修复结果
@@ -78,6 +125,7 @@ type ConnectivityFixResult struct {
 	FixedIssues     []string `json:"fixed_issues"`
 	AppliedRules    []string `json:"applied_rules"`
 	Message         string   `json:"message,omitempty"`
+	ChangeSetID     uint     `json:"change_set_id,omitempty"` // 可用于回滚本次修复的变更集
 }
 
 // DockerIPAMConfig Docker IPAM配置
@@ -95,10 +143,85 @@ type DockerSubnet struct {
 
 // DockerContainer Docker容器信息
 type DockerContainer struct {
-	ID         string `json:"id"`
-	Name       string `json:"name"`
-	IPAddress  string `json:"ip_address"`
-	MACAddress string `json:"mac_address"`
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	IPAddress   string            `json:"ip_address"`
+	IPv6Address string            `json:"ipv6_address,omitempty"`
+	MACAddress  string            `json:"mac_address"`
+	Gateway     string            `json:"gateway,omitempty"`
+	Image       string            `json:"image,omitempty"`
+	State       string            `json:"state,omitempty"`
+	Status      string            `json:"status,omitempty"`
+	Ports       []DockerPort      `json:"ports,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	// 以下字段来自 ifindex/iflink 与网络命名空间解析
+	PID           int    `json:"pid,omitempty"`
+	Process       string `json:"process,omitempty"`
+	Cgroup        string `json:"cgroup,omitempty"`
+	NetNS         string `json:"netns,omitempty"`
+	Runtime       string `json:"runtime,omitempty"`
+	HostInterface string `json:"host_interface,omitempty"`
+	PeerInterface string `json:"peer_interface,omitempty"`
+	PeerIndex     int    `json:"peer_index,omitempty"`
+}
+
+// DockerPort 容器发布的端口
+type DockerPort struct {
+	HostIP        string `json:"host_ip,omitempty"`
+	HostPort      int    `json:"host_port,omitempty"`
+	ContainerPort int    `json:"container_port"`
+	Protocol      string `json:"protocol"`
+}
+
+// DockerPortMapping Docker发布端口映射（由nat/filter表DOCKER链推导）
+type DockerPortMapping struct {
+	HostIP        string `json:"host_ip"`
+	HostPort      string `json:"host_port"` // 单个端口或范围，如 8000:8010
+	Protocol      string `json:"protocol"`
+	ContainerIP   string `json:"container_ip"`
+	ContainerPort string `json:"container_port"`
+	Bridge        string `json:"bridge,omitempty"`
+	// ExposedOn 接收流量的接口："*" 表示除网桥外的所有接口，"!"前缀表示排除
+	ExposedOn     string `json:"exposed_on"`
+	ContainerID   string `json:"container_id,omitempty"`
+	ContainerName string `json:"container_name,omitempty"`
+	Image         string `json:"image,omitempty"`
+	// DNAT规则只匹配连接的首个包，计数即新建连接数
+	DNATRuleKey   string `json:"dnat_rule_key"`
+	DNATPackets   uint64 `json:"dnat_packets"`
+	DNATBytes     uint64 `json:"dnat_bytes"`
+	FilterRuleKey string `json:"filter_rule_key,omitempty"`
+	FilterPackets uint64 `json:"filter_packets"`
+	FilterBytes   uint64 `json:"filter_bytes"`
+	Allowed       bool   `json:"allowed"` // filter表DOCKER链中存在对应的ACCEPT规则
+}
+
+// ConntrackTuple 连接跟踪的单向元组
+type ConntrackTuple struct {
+	Src      string `json:"src"`
+	Dst      string `json:"dst"`
+	SrcPort  int    `json:"sport,omitempty"`
+	DstPort  int    `json:"dport,omitempty"`
+	ICMPType *int   `json:"icmp_type,omitempty"`
+	ICMPCode *int   `json:"icmp_code,omitempty"`
+	ICMPID   *int   `json:"icmp_id,omitempty"`
+	Packets  uint64 `json:"packets"` // 仅在开启 nf_conntrack_acct 时有值
+	Bytes    uint64 `json:"bytes"`
+}
+
+// ConntrackEntry 内核连接跟踪表中的一条连接
+type ConntrackEntry struct {
+	Family   string         `json:"family"` // ipv4, ipv6
+	Protocol string         `json:"protocol"`
+	ProtoNum int            `json:"proto_num"`
+	Timeout  int            `json:"timeout"` // 剩余秒数
+	State    string         `json:"state,omitempty"`
+	Original ConntrackTuple `json:"original"`
+	Reply    ConntrackTuple `json:"reply"`
+	Flags    []string       `json:"flags,omitempty"` // ASSURED, UNREPLIED 等
+	Mark     uint32         `json:"mark"`
+	Zone     int            `json:"zone"`
+	NAT      string         `json:"nat"` // none, snat, dnat, both
 }
 
 // OperationLog 操作日志模型
@@ -131,19 +254,69 @@ type Statistics struct {
 
 // NetworkConnection 网络连接信息
 type NetworkConnection struct {
-	Protocol       string `json:"protocol"`
+	Protocol       string `json:"protocol"` // tcp, tcp6, udp, udp6, unix
 	LocalAddress   string `json:"local_address"`
 	ForeignAddress string `json:"foreign_address"`
 	State          string `json:"state"`
+	LocalIP        string `json:"local_ip,omitempty"`
+	LocalPort      int    `json:"local_port,omitempty"`
+	RemoteIP       string `json:"remote_ip,omitempty"`
+	RemotePort     int    `json:"remote_port,omitempty"`
+	TxQueue        uint64 `json:"tx_queue"`
+	RxQueue        uint64 `json:"rx_queue"`
+	UID            int    `json:"uid"`
+	Inode          uint64 `json:"inode"`
+	SocketType     string `json:"socket_type,omitempty"` // unix: stream, dgram, seqpacket
+	Path           string `json:"path,omitempty"`        // unix套接字路径
+	PID            int    `json:"pid,omitempty"`
+	Process        string `json:"process,omitempty"`
+	ContainerID    string `json:"container_id,omitempty"`
+	Runtime        string `json:"runtime,omitempty"`
+	NetNS          string `json:"netns"` // host 或 net:[inode]
 }
 
 // RouteEntry 路由表条目
 type RouteEntry struct {
-	Destination string `json:"destination"`
-	Gateway     string `json:"gateway"`
-	Interface   string `json:"interface"`
-	Source      string `json:"source"`
-	Metric      int    `json:"metric"`
+	Destination string         `json:"destination"`
+	Gateway     string         `json:"gateway"`
+	Interface   string         `json:"interface"`
+	Source      string         `json:"source"` // 首选源地址（prefsrc）
+	Metric      int            `json:"metric"`
+	Family      string         `json:"family"` // inet, inet6
+	Table       string         `json:"table"`
+	Type        string         `json:"type"`               // unicast, local, broadcast, multicast, blackhole, unreachable, prohibit, throw
+	Protocol    string         `json:"protocol,omitempty"` // kernel, boot, static, dhcp...
+	Scope       string         `json:"scope,omitempty"`
+	Flags       []string       `json:"flags,omitempty"`
+	Nexthops    []RouteNexthop `json:"nexthops,omitempty"` // 多路径路由
+}
+
+// RouteNexthop 多路径路由的下一跳
+type RouteNexthop struct {
+	Gateway   string `json:"gateway,omitempty"`
+	Interface string `json:"interface"`
+	Weight    int    `json:"weight"`
+}
+
+// RoutingRule 策略路由规则（ip rule）
+type RoutingRule struct {
+	Priority             int    `json:"priority"`
+	Family               string `json:"family"`
+	Not                  bool   `json:"not,omitempty"`
+	Source               string `json:"source"`      // all 或 CIDR
+	Destination          string `json:"destination"` // all 或 CIDR
+	FWMark               string `json:"fwmark,omitempty"`
+	FWMask               string `json:"fwmask,omitempty"`
+	IIf                  string `json:"iif,omitempty"`
+	OIf                  string `json:"oif,omitempty"`
+	IPProto              string `json:"ipproto,omitempty"`
+	SourcePort           string `json:"source_port,omitempty"` // 端口或 "起-止"
+	DestPort             string `json:"dest_port,omitempty"`
+	UIDRange             string `json:"uid_range,omitempty"`
+	Action               string `json:"action"` // lookup, goto, nop, blackhole, unreachable, prohibit
+	Table                string `json:"table,omitempty"`
+	Goto                 int    `json:"goto,omitempty"`
+	SuppressPrefixLength int    `json:"suppress_prefixlength"` // -1 表示未设置
 }
 
 // TableName 设置表名
@@ -194,12 +367,44 @@ type TunnelDockerStats struct {
 // TunnelInterfaceInfo 隧道接口详细信息
 type TunnelInterfaceInfo struct {
 	NetworkInterface
-	TunnelType       string         `json:"tunnel_type"`       // tun, tap, vpn
-	PeerAddress      string         `json:"peer_address"`      // 对端地址
-	LocalAddress     string         `json:"local_address"`     // 本地地址
-	EncryptionType   string         `json:"encryption_type"`   // 加密类型
-	ConnectedBridges []string       `json:"connected_bridges"` // 连接的网桥
-	RelatedRules     []IPTablesRule `json:"related_rules"`     // 相关规则
+	TunnelType       string           `json:"tunnel_type"`         // tun, tap, wireguard, gre, gretap, ipip, sit, ip6tnl, vxlan, geneve, xfrm, vti
+	PeerAddress      string           `json:"peer_address"`        // 对端地址（点对点地址的对端，没有时为外层远端地址）
+	LocalAddress     string           `json:"local_address"`       // 本地地址
+	EncryptionType   string           `json:"encryption_type"`     // wireguard、ipsec、none；tun/tap 由用户态程序加密，为 unknown
+	Underlay         *TunnelUnderlay  `json:"underlay,omitempty"`  // 外层封装参数
+	WireGuard        *WireGuardDevice `json:"wireguard,omitempty"` // WireGuard 设备与对端
+	ConnectedBridges []string         `json:"connected_bridges"`   // 连接的网桥
+	RelatedRules     []IPTablesRule   `json:"related_rules"`       // 相关规则
+}
+
+// TunnelUnderlay 隧道外层封装参数（来自内核链路信息）
+type TunnelUnderlay struct {
+	Local  string `json:"local,omitempty"`
+	Remote string `json:"remote,omitempty"` // 远端地址（VXLAN 为组播组或单播远端）
+	Key    uint32 `json:"key,omitempty"`    // GRE/VTI key、VXLAN/GENEVE VNI 或 xfrm if_id
+	Device string `json:"device,omitempty"` // 承载接口
+	Port   int    `json:"port,omitempty"`   // UDP 封装的目的端口
+	TTL    int    `json:"ttl,omitempty"`
+}
+
+// WireGuardDevice WireGuard 设备信息（不包含私钥）
+type WireGuardDevice struct {
+	PublicKey  string          `json:"public_key"`
+	ListenPort int             `json:"listen_port"`
+	FWMark     uint32          `json:"fwmark,omitempty"`
+	Peers      []WireGuardPeer `json:"peers"`
+}
+
+// WireGuardPeer WireGuard 对端
+type WireGuardPeer struct {
+	PublicKey           string     `json:"public_key"`
+	PresharedKey        bool       `json:"preshared_key"` // 是否配置了预共享密钥
+	Endpoint            string     `json:"endpoint,omitempty"`
+	AllowedIPs          []string   `json:"allowed_ips"`
+	PersistentKeepalive int        `json:"persistent_keepalive,omitempty"` // 秒
+	LastHandshake       *time.Time `json:"last_handshake,omitempty"`
+	RxBytes             int64      `json:"rx_bytes"`
+	TxBytes             int64      `json:"tx_bytes"`
 }
 
 // DockerBridgeInfo Docker网桥详细信息
@@ -232,3 +437,224 @@ type NetworkCommunicationRule struct {
 func (NetworkCommunicationRule) TableName() string {
 	return "network_communication_rules"
 }
+
+// CounterSeries 计数器时间序列（接口或规则），记录上一次采样的原始计数
+type CounterSeries struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	Kind          string     `json:"kind" gorm:"size:20;not null;uniqueIndex:idx_counter_series_kind_key"` // interface, rule
+	Key           string     `json:"key" gorm:"size:100;not null;uniqueIndex:idx_counter_series_kind_key"` // 接口名或规则标识
+	Table         string     `json:"table" gorm:"size:20"`
+	ChainName     string     `json:"chain_name" gorm:"size:50;index"`
+	InInterface   string     `json:"in_interface" gorm:"size:50;index"`
+	OutInterface  string     `json:"out_interface" gorm:"size:50;index"`
+	Target        string     `json:"target" gorm:"size:50"`
+	Comment       string     `json:"comment" gorm:"size:255"`
+	Spec          string     `json:"spec" gorm:"type:text"`
+	LastPackets   uint64     `json:"last_packets"`    // 规则计数 / 接口接收
+	LastBytes     uint64     `json:"last_bytes"`      // 规则计数 / 接口接收
+	LastTxPackets uint64     `json:"last_tx_packets"` // 仅接口
+	LastTxBytes   uint64     `json:"last_tx_bytes"`   // 仅接口
+	Resets        int        `json:"resets"`          // 检测到的计数器重置次数（规则重建、iptables-restore、重启）
+	LastResetAt   *time.Time `json:"last_reset_at,omitempty"`
+	LastSeenAt    time.Time  `json:"last_seen_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// CounterSample 计数器增量样本，按分辨率（秒）聚合到时间桶
+type CounterSample struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	SeriesID    uint   `json:"series_id" gorm:"not null;uniqueIndex:idx_counter_sample_bucket"`
+	Resolution  int    `json:"resolution" gorm:"not null;uniqueIndex:idx_counter_sample_bucket"`
+	BucketStart int64  `json:"bucket_start" gorm:"not null;uniqueIndex:idx_counter_sample_bucket;index"` // Unix时间戳（秒）
+	Packets     uint64 `json:"packets"`
+	Bytes       uint64 `json:"bytes"`
+	TxPackets   uint64 `json:"tx_packets"`
+	TxBytes     uint64 `json:"tx_bytes"`
+}
+
+func (CounterSeries) TableName() string {
+	return "counter_series"
+}
+
+func (CounterSample) TableName() string {
+	return "counter_samples"
+}
+
+// TopologySnapshot 拓扑快照（定期或手动保存的 TopologyData）
+type TopologySnapshot struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Trigger   string    `json:"trigger" gorm:"column:trigger_type;size:20;not null;index"` // periodic, manual
+	Note      string    `json:"note" gorm:"size:255"`
+	CreatedBy string    `json:"created_by" gorm:"size:50"`
+	NodeCount int       `json:"node_count"`
+	LinkCount int       `json:"link_count"`
+	Data      string    `json:"-" gorm:"type:text"` // TopologyData JSON
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+func (TopologySnapshot) TableName() string {
+	return "topology_snapshots"
+}
+
+// ManagedRuleGroup 托管规则组（规则带注释标记，被外部重建清除后会自动重新应用）
+type ManagedRuleGroup struct {
+	ID              uint          `json:"id" gorm:"primaryKey"`
+	Name            string        `json:"name" gorm:"size:100;not null;uniqueIndex"`
+	Description     string        `json:"description" gorm:"size:255"`
+	Source          string        `json:"source" gorm:"size:30;not null"` // fix_connectivity, generate_rules, port_forward, manual
+	TunnelInterface string        `json:"tunnel_interface,omitempty" gorm:"size:50"`
+	DockerBridge    string        `json:"docker_bridge,omitempty" gorm:"size:50"`
+	Enabled         bool          `json:"enabled"`
+	CreatedBy       string        `json:"created_by" gorm:"size:50"`
+	LastAppliedAt   *time.Time    `json:"last_applied_at,omitempty"`
+	Rules           []ManagedRule `json:"rules" gorm:"foreignKey:GroupID"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+func (ManagedRuleGroup) TableName() string {
+	return "managed_rule_groups"
+}
+
+// ManagedRule 托管规则组中的单条规则
+type ManagedRule struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	GroupID   uint      `json:"group_id" gorm:"not null;index"`
+	Position  int       `json:"position"` // 组内顺序，从1开始
+	Table     string    `json:"table" gorm:"column:table_name;size:20;not null"`
+	Chain     string    `json:"chain" gorm:"size:50;not null"`
+	Spec      string    `json:"spec" gorm:"type:text;not null"` // 不含 -A <chain> 和托管注释的规则参数
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (ManagedRule) TableName() string {
+	return "managed_rules"
+}
+
+// PortForward 端口转发（DNAT），对应一个托管规则组：PREROUTING DNAT、本机 OUTPUT DNAT、FORWARD 放行和可选的回流 SNAT
+type PortForward struct {
+	ID                uint      `json:"id" gorm:"primaryKey"`
+	Name              string    `json:"name" gorm:"size:60;not null;uniqueIndex"`
+	Description       string    `json:"description" gorm:"size:255"`
+	Protocol          string    `json:"protocol" gorm:"size:10;not null"` // tcp, udp, tcp+udp
+	ExternalInterface string    `json:"external_interface" gorm:"size:50"`
+	ExternalIP        string    `json:"external_ip" gorm:"size:45"`
+	ExternalPortStart int       `json:"external_port_start" gorm:"not null"`
+	ExternalPortEnd   int       `json:"external_port_end"` // 0 表示单个端口
+	InternalIP        string    `json:"internal_ip" gorm:"size:45;not null"`
+	InternalPort      int       `json:"internal_port"`                           // 0 表示与外部端口相同；端口范围只支持保持端口不变
+	AllowedSources    string    `json:"allowed_sources" gorm:"type:text"`        // 逗号分隔的IP/CIDR，空表示不限制
+	LocalTraffic      bool      `json:"local_traffic"`                           // 同时为本机发起的连接生成 OUTPUT DNAT
+	Hairpin           bool      `json:"hairpin"`                                 // 内网客户端通过外部地址访问时做回流 SNAT
+	HairpinSubnet     string    `json:"hairpin_subnet,omitempty" gorm:"size:50"` // 为空时自动使用内部地址所在的直连网段
+	Enabled           bool      `json:"enabled"`
+	GroupName         string    `json:"group_name" gorm:"size:100"`
+	CreatedBy         string    `json:"created_by" gorm:"size:50"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+func (PortForward) TableName() string {
+	return "port_forwards"
+}
+
+// IPSet ipset集合
+type IPSet struct {
+	Name         string       `json:"name"`
+	Type         string       `json:"type"` // hash:ip、hash:net、hash:ip,port 等
+	Family       string       `json:"family"`
+	Revision     int          `json:"revision"`
+	HashSize     int          `json:"hash_size"`
+	MaxElem      int          `json:"max_elem"`
+	Timeout      int          `json:"timeout"`     // 默认超时（秒）
+	HasTimeout   bool         `json:"has_timeout"` // 创建时带 timeout 选项，条目可单独设置超时
+	Counters     bool         `json:"counters"`
+	Comment      bool         `json:"comment"`
+	SizeInMemory int          `json:"size_in_memory"`
+	References   int          `json:"references"` // 内核中引用该集合的规则数
+	EntryCount   int          `json:"entry_count"`
+	ReferencedBy []string     `json:"referenced_by"` // 引用该集合的规则（表/链#序号）
+	Entries      []IPSetEntry `json:"entries,omitempty"`
+}
+
+// IPSetEntry ipset集合中的条目
+type IPSetEntry struct {
+	Entry   string `json:"entry"`
+	Timeout int    `json:"timeout,omitempty"` // 剩余超时（秒）
+	Packets int64  `json:"packets,omitempty"`
+	Bytes   int64  `json:"bytes,omitempty"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// ConnectivityChangeSet 一次连通性修复实际做出的变更，可整体回滚
+type ConnectivityChangeSet struct {
+	ID              uint                 `json:"id" gorm:"primaryKey"`
+	TunnelInterface string               `json:"tunnel_interface" gorm:"size:50;not null;index:idx_change_set_pair"`
+	DockerBridge    string               `json:"docker_bridge" gorm:"size:50;not null;index:idx_change_set_pair"`
+	Persistent      bool                 `json:"persistent"`
+	PlanID          string               `json:"plan_id,omitempty" gorm:"size:32"`
+	Success         bool                 `json:"success"`
+	Status          string               `json:"status" gorm:"size:20;not null"` // applied, rolled_back, partially_rolled_back
+	CreatedBy       string               `json:"created_by" gorm:"size:50"`
+	RolledBackBy    string               `json:"rolled_back_by,omitempty" gorm:"size:50"`
+	RolledBackAt    *time.Time           `json:"rolled_back_at,omitempty"`
+	Changes         []ConnectivityChange `json:"changes" gorm:"foreignKey:ChangeSetID"`
+	CreatedAt       time.Time            `json:"created_at"`
+	UpdatedAt       time.Time            `json:"updated_at"`
+}
+
+func (ConnectivityChangeSet) TableName() string {
+	return "connectivity_change_sets"
+}
+
+// ConnectivityChange 变更集中的单项变更及回滚所需的原始状态
+type ConnectivityChange struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	ChangeSetID uint   `json:"change_set_id" gorm:"not null;index"`
+	Order       int    `json:"order" gorm:"column:step_order"` // 执行顺序，回滚时倒序撤销
+	Kind        string `json:"kind" gorm:"size:20;not null"`   // inserted_rule, deleted_rule, managed_group, link_up, sysctl
+	Table       string `json:"table,omitempty" gorm:"column:table_name;size:20"`
+	Chain       string `json:"chain,omitempty" gorm:"size:50"`
+	Position    int    `json:"position,omitempty"`                // 规则在链中的位置（删除的规则按此位置恢复）
+	Spec        string `json:"spec,omitempty" gorm:"type:text"`   // 不含 -I/-A <chain> 的规则参数
+	Target      string `json:"target,omitempty" gorm:"size:100"`  // 接口名、sysctl键或托管规则组名
+	Previous    string `json:"previous,omitempty" gorm:"size:50"` // 修改前的值：sysctl原值、接口原状态、规则组是否已存在
+	Command     string `json:"command" gorm:"type:text"`
+	Reverted    bool   `json:"reverted"`
+	RevertError string `json:"revert_error,omitempty" gorm:"type:text"`
+}
+
+func (ConnectivityChange) TableName() string {
+	return "connectivity_changes"
+}
+
+// PacketLog 从内核日志或NFLOG收集的一条 LOG/NFLOG 记录
+type PacketLog struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	Timestamp    time.Time `json:"timestamp" gorm:"not null;index"`
+	Source       string    `json:"source" gorm:"size:100"`      // 采集来源：kmsg、file:<path>、nflog:<group>
+	Prefix       string    `json:"prefix" gorm:"size:64;index"` // 日志前缀（去掉首尾空白）
+	Action       string    `json:"action" gorm:"size:20;index"` // 产生日志的规则之后的处理：drop, reject, accept, log（无法判断）
+	RuleTable    string    `json:"rule_table,omitempty" gorm:"size:20"`
+	RuleChain    string    `json:"rule_chain,omitempty" gorm:"size:50;index"`
+	RulePosition int       `json:"rule_position,omitempty"`
+	RuleSpec     string    `json:"rule_spec,omitempty" gorm:"type:text"`
+	RuleKey      string    `json:"rule_key,omitempty" gorm:"size:20;index"`
+	InInterface  string    `json:"in_interface,omitempty" gorm:"size:50;index"`
+	OutInterface string    `json:"out_interface,omitempty" gorm:"size:50;index"`
+	MAC          string    `json:"mac,omitempty" gorm:"size:120"`
+	Protocol     string    `json:"protocol" gorm:"size:20;index"`
+	SrcIP        string    `json:"src_ip" gorm:"size:50;index"`
+	DstIP        string    `json:"dst_ip" gorm:"size:50;index"`
+	SrcPort      int       `json:"src_port,omitempty"`
+	DstPort      int       `json:"dst_port,omitempty" gorm:"index"`
+	Length       int       `json:"length,omitempty"`
+	TTL          int       `json:"ttl,omitempty"`
+	Flags        string    `json:"flags,omitempty" gorm:"size:50"` // TCP标志，或ICMP类型/代码
+	Raw          string    `json:"raw" gorm:"type:text"`
+}
+
+func (PacketLog) TableName() string {
+	return "packet_logs"
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CommandStats 外部命令执行统计（用于后端健康监控）
+type CommandStats struct {
+	Command       string    `json:"command"`
+	Count         int64     `json:"count"`
+	Errors        int64     `json:"errors"`
+	TotalSeconds  float64   `json:"total_seconds"`
+	LastSeconds   float64   `json:"last_seconds"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastRunAt     time.Time `json:"last_run_at"`
+	LastSuccessAt time.Time `json:"last_success_at,omitempty"`
+}
+
+var (
+	commandStatsMutex sync.Mutex
+	commandStats      = make(map[string]*CommandStats)
+)
+
+// runCommand 执行外部命令并记录耗时和错误次数
+func runCommand(name string, args ...string) ([]byte, error) {
+	start := time.Now()
+	output, err := exec.Command(name, args...).Output()
+	recordCommand(name, time.Since(start), err)
+	return output, err
+}
+
+// recordCommand 记录一次命令执行结果
+func recordCommand(name string, duration time.Duration, err error) {
+	commandStatsMutex.Lock()
+	defer commandStatsMutex.Unlock()
+
+	stats, ok := commandStats[name]
+	if !ok {
+		stats = &CommandStats{Command: name}
+		commandStats[name] = stats
+	}
+
+	stats.Count++
+	stats.TotalSeconds += duration.Seconds()
+	stats.LastSeconds = duration.Seconds()
+	stats.LastRunAt = time.Now()
+	if err != nil {
+		stats.Errors++
+		stats.LastError = err.Error()
+	} else {
+		stats.LastSuccessAt = stats.LastRunAt
+	}
+}
+
+// GetCommandStats 获取所有外部命令的执行统计
+func GetCommandStats() []CommandStats {
+	commandStatsMutex.Lock()
+	defer commandStatsMutex.Unlock()
+
+	result := make([]CommandStats, 0, len(commandStats))
+	for _, stats := range commandStats {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Command < result[j].Command
+	})
+	return result
+}
+
+// runCommandInput 执行外部命令并通过标准输入传入数据（如 ipset restore）
+func runCommandInput(input, name string, args ...string) ([]byte, error) {
+	start := time.Now()
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(input)
+	output, err := cmd.Output()
+	recordCommand(name, time.Since(start), err)
+	return output, err
+}
+
+// commandError 取外部命令失败时的标准错误输出，没有时返回原始错误
+func commandError(err error) string {
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return strings.TrimSpace(string(exitErr.Stderr))
+	}
+	return err.Error()
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"iptables-management-backend/config"
+	"iptables-management-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ConnectivityChangeService 连通性修复变更集服务：查询历史修复并整体回滚
+type ConnectivityChangeService struct {
+	dockerUserService *DockerUserService
+}
+
+// NewConnectivityChangeService 创建连通性修复变更集服务实例
+func NewConnectivityChangeService(dockerUserService *DockerUserService) *ConnectivityChangeService {
+	return &ConnectivityChangeService{
+		dockerUserService: dockerUserService,
+	}
+}
+
+// ListChangeSets 获取历史修复（最新在前），可按隧道接口和网桥过滤
+func (s *ConnectivityChangeService) ListChangeSets(tunnelInterface, dockerBridge string) ([]models.ConnectivityChangeSet, error) {
+	query := config.DB.Preload("Changes", func(db *gorm.DB) *gorm.DB {
+		return db.Order("step_order")
+	})
+	if tunnelInterface != "" {
+		query = query.Where("tunnel_interface = ?", tunnelInterface)
+	}
+	if dockerBridge != "" {
+		query = query.Where("docker_bridge = ?", dockerBridge)
+	}
+
+	var changeSets []models.ConnectivityChangeSet
+	if err := query.Order("id DESC").Find(&changeSets).Error; err != nil {
+		return nil, fmt.Errorf("failed to list connectivity change sets: %v", err)
+	}
+	return changeSets, nil
+}
+
+// GetChangeSet 获取单个变更集
+func (s *ConnectivityChangeService) GetChangeSet(id uint) (*models.ConnectivityChangeSet, error) {
+	var changeSet models.ConnectivityChangeSet
+	err := config.DB.Preload("Changes", func(db *gorm.DB) *gorm.DB {
+		return db.Order("step_order")
+	}).First(&changeSet, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &changeSet, nil
+}
+
+// RollbackChangeSet 倒序撤销变更集中尚未撤销的变更；单项失败时继续撤销其余项，可再次调用重试失败项
+func (s *ConnectivityChangeService) RollbackChangeSet(id uint, username string) (*models.ConnectivityChangeSet, error) {
+	changeSet, err := s.GetChangeSet(id)
+	if err != nil {
+		return nil, err
+	}
+	if changeSet.Status == "rolled_back" {
+		return changeSet, fmt.Errorf("change set %d is already rolled back", id)
+	}
+
+	failed := 0
+	for _, index := range rollbackOrder(changeSet.Changes) {
+		change := &changeSet.Changes[index]
+		if err := s.revertChange(*change); err != nil {
+			failed++
+			change.RevertError = err.Error()
+			log.Printf("[WARN] Failed to revert connectivity change %d (%s): %v", change.ID, change.Command, err)
+		} else {
+			change.Reverted = true
+			change.RevertError = ""
+		}
+		if err := config.DB.Model(change).Select("reverted", "revert_error").Updates(change).Error; err != nil {
+			return changeSet, fmt.Errorf("failed to update connectivity change %d: %v", change.ID, err)
+		}
+	}
+
+	now := time.Now()
+	changeSet.RolledBackAt = &now
+	changeSet.RolledBackBy = username
+	changeSet.Status = "rolled_back"
+	if failed > 0 {
+		changeSet.Status = "partially_rolled_back"
+	}
+	if err := config.DB.Model(changeSet).Select("status", "rolled_back_at", "rolled_back_by").Updates(changeSet).Error; err != nil {
+		return changeSet, fmt.Errorf("failed to update connectivity change set %d: %v", id, err)
+	}
+
+	log.Printf("[INFO] Connectivity change set %d (%s <-> %s) rolled back by %s: %d failed", id, changeSet.TunnelInterface, changeSet.DockerBridge, username, failed)
+	return changeSet, nil
+}
+
+// revertChange 撤销单项变更；目标状态已满足时视为成功
+func (s *ConnectivityChangeService) revertChange(change models.ConnectivityChange) error {
+	switch change.Kind {
+	case "inserted_rule":
+		if !iptablesRuleExists(change.Table, change.Chain, change.Spec) {
+			return nil
+		}
+	case "deleted_rule":
+		if iptablesRuleExists(change.Table, change.Chain, change.Spec) {
+			return nil
+		}
+	case "managed_group":
+		if change.Previous == "existed" {
+			return nil
+		}
+		return s.dockerUserService.deleteGroupByName(change.Target)
+	}
+
+	name, args, err := connectivityRevertArgs(change)
+	if err != nil {
+		return err
+	}
+	if _, err := runCommand(name, args...); err != nil {
+		// 链中规则变少时原位置可能超出范围，追加到链尾
+		if change.Kind == "deleted_rule" {
+			appendArgs := append([]string{"-t", change.Table, "-A", change.Chain}, SplitRuleArgs(change.Spec)...)
+			if _, appendErr := runCommand("iptables", appendArgs...); appendErr == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s", commandError(err))
+	}
+	return nil
+}
+
+// iptablesRuleExists 规则是否存在于指定链中
+func iptablesRuleExists(table, chain, spec string) bool {
+	args := append([]string{"-t", table, "-C", chain}, SplitRuleArgs(spec)...)
+	_, err := runCommand("iptables", args...)
+	return err == nil
+}
+
+// connectivityRevertArgs 撤销单项变更的命令
+func connectivityRevertArgs(change models.ConnectivityChange) (string, []string, error) {
+	switch change.Kind {
+	case "inserted_rule":
+		return "iptables", append([]string{"-t", change.Table, "-D", change.Chain}, SplitRuleArgs(change.Spec)...), nil
+	case "deleted_rule":
+		position := change.Position
+		if position < 1 {
+			position = 1
+		}
+		return "iptables", append([]string{"-t", change.Table, "-I", change.Chain, strconv.Itoa(position)}, SplitRuleArgs(change.Spec)...), nil
+	case "link_up":
+		return "ip", []string{"link", "set", change.Target, change.Previous}, nil
+	case "sysctl":
+		return "sysctl", []string{"-w", change.Target + "=" + change.Previous}, nil
+	}
+	return "", nil, fmt.Errorf("unsupported change kind %s", change.Kind)
+}
+
+// rollbackOrder 尚未撤销的变更下标，按执行顺序倒序排列
+func rollbackOrder(changes []models.ConnectivityChange) []int {
+	var indexes []int
+	for i, change := range changes {
+		if !change.Reverted {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return changes[indexes[i]].Order > changes[indexes[j]].Order
+	})
+	return indexes
+}
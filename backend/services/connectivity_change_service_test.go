@@ -0,0 +1,60 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"iptables-management-backend/models"
+)
+
+func TestConnectivityRevertArgs(t *testing.T) {
+	tests := []struct {
+		change models.ConnectivityChange
+		want   string
+	}{
+		{
+			models.ConnectivityChange{Kind: "inserted_rule", Table: "filter", Chain: "FORWARD", Position: 1, Spec: "-i wg0 -o docker0 -j ACCEPT"},
+			"iptables -t filter -D FORWARD -i wg0 -o docker0 -j ACCEPT",
+		},
+		{
+			models.ConnectivityChange{Kind: "deleted_rule", Table: "filter", Chain: "FORWARD", Position: 4, Spec: `-i wg0 -o docker0 -m comment --comment "block vpn" -j DROP`},
+			`iptables -t filter -I FORWARD 4 -i wg0 -o docker0 -m comment --comment "block vpn" -j DROP`,
+		},
+		{
+			models.ConnectivityChange{Kind: "deleted_rule", Table: "filter", Chain: "FORWARD", Spec: "-i wg0 -j DROP"},
+			"iptables -t filter -I FORWARD 1 -i wg0 -j DROP",
+		},
+		{models.ConnectivityChange{Kind: "link_up", Target: "wg0", Previous: "down"}, "ip link set wg0 down"},
+		{models.ConnectivityChange{Kind: "sysctl", Target: "net.ipv4.ip_forward", Previous: "0"}, "sysctl -w net.ipv4.ip_forward=0"},
+	}
+	for _, tt := range tests {
+		name, args, err := connectivityRevertArgs(tt.change)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.change.Kind, err)
+			continue
+		}
+		if got := name + " " + JoinRuleArgs(args); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.change.Kind, got, tt.want)
+		}
+	}
+
+	if _, _, err := connectivityRevertArgs(models.ConnectivityChange{Kind: "managed_group"}); err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("expected unsupported error for managed_group, got %v", err)
+	}
+}
+
+func TestRollbackOrder(t *testing.T) {
+	changes := []models.ConnectivityChange{
+		{Order: 1},
+		{Order: 2, Reverted: true},
+		{Order: 5},
+		{Order: 3},
+	}
+	if got := rollbackOrder(changes); !reflect.DeepEqual(got, []int{2, 3, 0}) {
+		t.Errorf("rollbackOrder() = %v, want [2 3 0]", got)
+	}
+	if got := rollbackOrder(nil); len(got) != 0 {
+		t.Errorf("expected empty order, got %v", got)
+	}
+}
@@ -0,0 +1,423 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"iptables-management-backend/config"
+	"iptables-management-backend/models"
+)
+
+// connectivityPlanTTL 修复计划的有效期，过期后需要重新生成
+const connectivityPlanTTL = 15 * time.Minute
+
+// dockerIsolationChain Docker网桥间隔离链
+const dockerIsolationChain = "DOCKER-ISOLATION-STAGE-2"
+
+// ConnectivityPlanStep 修复计划中的一步
+type ConnectivityPlanStep struct {
+	Order   int    `json:"order,omitempty"`
+	Action  string `json:"action"` // insert_rule, delete_rule, managed_group, link_up, sysctl
+	Command string `json:"command"`
+	Reason  string `json:"reason"`
+	Status  string `json:"status,omitempty"` // 执行后为 applied 或 failed；跳过的步骤为 skipped
+	Error   string `json:"error,omitempty"`
+
+	run    func() error
+	change *models.ConnectivityChange // 执行成功后记入变更集，用于回滚
+}
+
+// ConnectivityPlan 隧道与网桥连通性修复计划：按顺序列出将执行的命令及原因，审核后再执行
+type ConnectivityPlan struct {
+	ID              string                 `json:"id"`
+	TunnelInterface string                 `json:"tunnel_interface"`
+	DockerBridge    string                 `json:"docker_bridge"`
+	Persistent      bool                   `json:"persistent"`
+	Steps           []ConnectivityPlanStep `json:"steps"`
+	Skipped         []ConnectivityPlanStep `json:"skipped"` // 已满足、无需执行的检查项
+	Fingerprint     string                 `json:"fingerprint"`
+	CreatedAt       time.Time              `json:"created_at"`
+	ExpiresAt       time.Time              `json:"expires_at"`
+	ExecutedAt      *time.Time             `json:"executed_at,omitempty"`
+
+	changes []models.ConnectivityChange // 已成功执行的步骤对应的变更
+}
+
+// connectivityPlans 待审核的修复计划（各服务实例共用）
+var connectivityPlans = struct {
+	sync.Mutex
+	plans map[string]*ConnectivityPlan
+}{plans: make(map[string]*ConnectivityPlan)}
+
+// addStep 追加待执行的步骤
+func (p *ConnectivityPlan) addStep(action, command, reason string, run func() error) {
+	p.Steps = append(p.Steps, ConnectivityPlanStep{
+		Order:   len(p.Steps) + 1,
+		Action:  action,
+		Command: command,
+		Reason:  reason,
+		run:     run,
+	})
+}
+
+// addSkipped 记录无需执行的检查项
+func (p *ConnectivityPlan) addSkipped(action, command, reason string) {
+	p.Skipped = append(p.Skipped, ConnectivityPlanStep{Action: action, Command: command, Reason: reason, Status: "skipped"})
+}
+
+// addChangeStep 追加带回滚信息的步骤
+func (p *ConnectivityPlan) addChangeStep(action, command, reason string, change *models.ConnectivityChange, run func() error) {
+	p.addStep(action, command, reason, run)
+	p.Steps[len(p.Steps)-1].change = change
+}
+
+// addInsertRuleStep 追加一条插入规则的步骤
+func (p *ConnectivityPlan) addInsertRuleStep(reason, chain string, position int, spec ...string) {
+	args := append([]string{"-I", chain, strconv.Itoa(position)}, spec...)
+	change := &models.ConnectivityChange{Kind: "inserted_rule", Table: "filter", Chain: chain, Position: position, Spec: JoinRuleArgs(spec)}
+	p.addChangeStep("insert_rule", "iptables "+JoinRuleArgs(args), reason, change, func() error {
+		if _, err := runCommand("iptables", args...); err != nil {
+			return fmt.Errorf("%s", commandError(err))
+		}
+		return nil
+	})
+}
+
+// addDeleteRuleStep 追加一条按规则内容删除规则的步骤，执行前记录规则当时的位置以便按原位置恢复
+func (p *ConnectivityPlan) addDeleteRuleStep(reason string, rule SavedRule) {
+	args := append([]string{"-t", rule.Table, "-D", rule.Chain}, SplitRuleArgs(rule.Spec)...)
+	change := &models.ConnectivityChange{Kind: "deleted_rule", Table: rule.Table, Chain: rule.Chain, Position: rule.Position, Spec: rule.Spec}
+	p.addChangeStep("delete_rule", "iptables "+JoinRuleArgs(args), reason, change, func() error {
+		change.Position = currentRulePosition(rule.Table, rule.Chain, rule.Spec, rule.Position)
+		if _, err := runCommand("iptables", args...); err != nil {
+			return fmt.Errorf("%s", commandError(err))
+		}
+		return nil
+	})
+}
+
+// PlanConnectivityFix 生成非持久化修复的计划（检查已满足的项），不修改系统
+func (s *NetworkService) PlanConnectivityFix(tunnelInterface, dockerBridge string) (*ConnectivityPlan, error) {
+	plan, err := newConnectivityPlan(s, tunnelInterface, dockerBridge, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. 在FORWARD链首放行隧道到网桥的流量及回包
+	if s.checkForwardRuleExists(tunnelInterface, dockerBridge) {
+		plan.addSkipped("insert_rule", fmt.Sprintf("iptables -C FORWARD -i %s -o %s -j ACCEPT", tunnelInterface, dockerBridge), "FORWARD放行规则已存在")
+	} else {
+		plan.addInsertRuleStep(fmt.Sprintf("允许隧道 %s 到网桥 %s 的转发（插入FORWARD链首，先于Docker的链）", tunnelInterface, dockerBridge),
+			"FORWARD", 1, "-i", tunnelInterface, "-o", dockerBridge, "-j", "ACCEPT")
+	}
+	if s.checkConntrackRuleExists(dockerBridge, tunnelInterface) {
+		plan.addSkipped("insert_rule", fmt.Sprintf("iptables -C FORWARD -i %s -o %s -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT", dockerBridge, tunnelInterface), "回包放行规则已存在")
+	} else {
+		plan.addInsertRuleStep(fmt.Sprintf("允许网桥 %s 回到隧道 %s 的已建立连接的回包", dockerBridge, tunnelInterface),
+			"FORWARD", 2, "-i", dockerBridge, "-o", tunnelInterface, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT")
+	}
+
+	// 2. 绕过Docker网桥隔离
+	if _, err := runCommand("iptables", "-n", "-L", dockerIsolationChain); err != nil {
+		plan.addSkipped("insert_rule", "iptables -n -L "+dockerIsolationChain, dockerIsolationChain+" 链不存在，无需绕过Docker隔离")
+	} else if s.checkIsolationRuleExists(tunnelInterface, dockerBridge, "RETURN") {
+		plan.addSkipped("insert_rule", fmt.Sprintf("iptables -C %s -i %s -o %s -j RETURN", dockerIsolationChain, tunnelInterface, dockerBridge), "Docker隔离绕过规则已存在")
+	} else {
+		plan.addInsertRuleStep(fmt.Sprintf("在 %s 链首让 %s -> %s 的流量返回，避免被Docker网桥隔离规则丢弃", dockerIsolationChain, tunnelInterface, dockerBridge),
+			dockerIsolationChain, 1, "-i", tunnelInterface, "-o", dockerBridge, "-j", "RETURN")
+	}
+
+	// 3. 接口和转发开关
+	s.planInterfaceState(plan)
+
+	// 4. 删除阻断两个接口间流量的FORWARD规则（按规则内容删除，不依赖行号）
+	ruleset, err := LoadSavedRuleset()
+	if err != nil {
+		log.Printf("[WARN] Failed to load ruleset for blocking rules: %v", err)
+		return plan, nil
+	}
+	for _, rule := range findBlockingForwardRules(ruleset, tunnelInterface, dockerBridge) {
+		plan.addDeleteRuleStep(
+			fmt.Sprintf("FORWARD第%d条规则 %s 了 %s -> %s 的流量（已命中 %d 个包）", rule.Position, rule.Match.Target, rule.Match.InInterface, rule.Match.OutInterface, rule.Packets),
+			rule)
+	}
+	return plan, nil
+}
+
+// PlanConnectivityFix 生成持久化修复（DOCKER-USER托管规则组）的计划，不修改系统
+func (s *DockerUserService) PlanConnectivityFix(tunnelInterface, dockerBridge, username string) (*ConnectivityPlan, error) {
+	plan, err := newConnectivityPlan(s.networkService, tunnelInterface, dockerBridge, true)
+	if err != nil {
+		return nil, err
+	}
+
+	group := connectivityGroup(tunnelInterface, dockerBridge, username)
+	var commands []string
+	for i := range group.Rules {
+		rule := group.Rules[i]
+		rule.Table, rule.Chain = "filter", DockerUserChain
+		commands = append(commands, managedRuleCommand("-I", rule.Table, rule.Chain, i+1, managedRuleArgs(group.Name, rule)))
+	}
+	// 规则组已存在时回滚不删除它（修复前就由它放行）
+	change := &models.ConnectivityChange{Kind: "managed_group", Target: group.Name}
+	if s.groupExists(group.Name) {
+		change.Previous = "existed"
+	}
+	plan.addChangeStep("managed_group", strings.Join(commands, "\n"),
+		fmt.Sprintf("保存托管规则组 %s 并插入 %s 链首：该链先于Docker隔离链，Docker重启不会改写，规则缺失时自动恢复", group.Name, DockerUserChain),
+		change, func() error {
+			result, err := s.SaveGroup(group)
+			if err != nil {
+				return err
+			}
+			if len(result.Errors) > 0 {
+				return fmt.Errorf("%s", strings.Join(result.Errors, "; "))
+			}
+			return nil
+		})
+
+	s.networkService.planInterfaceState(plan)
+	return plan, nil
+}
+
+// newConnectivityPlan 校验接口并创建空计划
+func newConnectivityPlan(s *NetworkService, tunnelInterface, dockerBridge string, persistent bool) (*ConnectivityPlan, error) {
+	for _, name := range []string{tunnelInterface, dockerBridge} {
+		exists, err := s.checkInterfaceExists(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check interface %s: %v", name, err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("interface %s does not exist", name)
+		}
+	}
+
+	now := time.Now()
+	return &ConnectivityPlan{
+		TunnelInterface: tunnelInterface,
+		DockerBridge:    dockerBridge,
+		Persistent:      persistent,
+		Steps:           []ConnectivityPlanStep{},
+		Skipped:         []ConnectivityPlanStep{},
+		Fingerprint:     filterRulesetFingerprint(),
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(connectivityPlanTTL),
+	}, nil
+}
+
+// planInterfaceState 接口未启用或未开启IPv4转发时追加对应步骤
+func (s *NetworkService) planInterfaceState(plan *ConnectivityPlan) {
+	for _, name := range []string{plan.TunnelInterface, plan.DockerBridge} {
+		name := name
+		iface, err := net.InterfaceByName(name)
+		if err == nil && iface.Flags&net.FlagUp != 0 {
+			plan.addSkipped("link_up", "ip link set "+name+" up", "接口 "+name+" 已启用")
+			continue
+		}
+		change := &models.ConnectivityChange{Kind: "link_up", Target: name, Previous: "down"}
+		plan.addChangeStep("link_up", "ip link set "+name+" up", "接口 "+name+" 处于关闭状态", change, func() error {
+			if _, err := runCommand("ip", "link", "set", name, "up"); err != nil {
+				return fmt.Errorf("%s", commandError(err))
+			}
+			return nil
+		})
+	}
+
+	if ipForwardEnabled() {
+		plan.addSkipped("sysctl", "sysctl -w net.ipv4.ip_forward=1", "IPv4转发已开启")
+		return
+	}
+	change := &models.ConnectivityChange{Kind: "sysctl", Target: "net.ipv4.ip_forward", Previous: "0"}
+	plan.addChangeStep("sysctl", "sysctl -w net.ipv4.ip_forward=1", "IPv4转发未开启，内核不会在隧道和网桥之间转发数据包", change, func() error {
+		if _, err := runCommand("sysctl", "-w", "net.ipv4.ip_forward=1"); err != nil {
+			return fmt.Errorf("%s", commandError(err))
+		}
+		return nil
+	})
+}
+
+// findBlockingForwardRules 查找FORWARD链中在两个接口间（任一方向）DROP或REJECT的规则
+func findBlockingForwardRules(ruleset *SavedRuleset, first, second string) []SavedRule {
+	var blocking []SavedRule
+	table := ruleset.Table("filter")
+	if table == nil {
+		return blocking
+	}
+	for _, rule := range table.ChainRules("FORWARD") {
+		if rule.Match.Target != "DROP" && rule.Match.Target != "REJECT" {
+			continue
+		}
+		in, out := rule.Match.InInterface, rule.Match.OutInterface
+		if (in == first && out == second) || (in == second && out == first) {
+			blocking = append(blocking, rule)
+		}
+	}
+	return blocking
+}
+
+// currentRulePosition 规则当前在链中的位置，找不到时返回 fallback
+func currentRulePosition(tableName, chain, spec string, fallback int) int {
+	ruleset, err := LoadSavedRuleset()
+	if err != nil {
+		return fallback
+	}
+	table := ruleset.Table(tableName)
+	if table == nil {
+		return fallback
+	}
+	for _, rule := range table.ChainRules(chain) {
+		if rule.Spec == spec {
+			return rule.Position
+		}
+	}
+	return fallback
+}
+
+// filterRulesetFingerprint filter表规则内容的摘要（不含计数器），用于判断计划生成后规则是否被改动
+func filterRulesetFingerprint() string {
+	ruleset, err := LoadSavedRuleset()
+	if err != nil {
+		return ""
+	}
+	return rulesetFingerprint(ruleset, "filter")
+}
+
+// rulesetFingerprint 计算指定表中链和规则（按顺序）的摘要
+func rulesetFingerprint(ruleset *SavedRuleset, tableName string) string {
+	table := ruleset.Table(tableName)
+	if table == nil {
+		return ""
+	}
+	hash := sha256.New()
+	for _, chain := range table.Chains {
+		fmt.Fprintf(hash, ":%s %s\n", chain.Name, chain.Policy)
+	}
+	for _, rule := range table.Rules {
+		fmt.Fprintf(hash, "-A %s %s\n", rule.Chain, rule.Spec)
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// StoreConnectivityPlan 保存待审核的计划并清理过期计划
+func StoreConnectivityPlan(plan *ConnectivityPlan) *ConnectivityPlan {
+	id := make([]byte, 8)
+	rand.Read(id)
+	plan.ID = hex.EncodeToString(id)
+
+	connectivityPlans.Lock()
+	defer connectivityPlans.Unlock()
+	now := time.Now()
+	for key, existing := range connectivityPlans.plans {
+		if now.After(existing.ExpiresAt) {
+			delete(connectivityPlans.plans, key)
+		}
+	}
+	connectivityPlans.plans[plan.ID] = plan
+	return plan
+}
+
+// GetConnectivityPlan 获取待审核的修复计划
+func GetConnectivityPlan(id string) (*ConnectivityPlan, error) {
+	connectivityPlans.Lock()
+	defer connectivityPlans.Unlock()
+	plan, ok := connectivityPlans.plans[id]
+	if !ok || time.Now().After(plan.ExpiresAt) {
+		return nil, fmt.Errorf("plan %s not found or expired", id)
+	}
+	return plan, nil
+}
+
+// ExecuteConnectivityPlan 执行已审核的计划并记录变更集；计划只能执行一次，filter表在计划生成后被改动时拒绝执行
+func ExecuteConnectivityPlan(id, username string) (*ConnectivityPlan, *models.ConnectivityFixResult, error) {
+	connectivityPlans.Lock()
+	plan, ok := connectivityPlans.plans[id]
+	if ok {
+		delete(connectivityPlans.plans, id)
+	}
+	connectivityPlans.Unlock()
+
+	if !ok || time.Now().After(plan.ExpiresAt) {
+		return nil, nil, fmt.Errorf("plan %s not found or expired", id)
+	}
+	if current := filterRulesetFingerprint(); current != plan.Fingerprint {
+		return plan, nil, fmt.Errorf("ruleset changed since plan %s was created, create a new plan", id)
+	}
+	result := runConnectivityPlan(plan)
+	recordConnectivityChangeSet(plan, result, username)
+	return plan, result, nil
+}
+
+// runConnectivityPlan 按顺序执行计划中的步骤（失败的步骤记录错误后继续）
+func runConnectivityPlan(plan *ConnectivityPlan) *models.ConnectivityFixResult {
+	result := &models.ConnectivityFixResult{
+		TunnelInterface: plan.TunnelInterface,
+		DockerBridge:    plan.DockerBridge,
+		FixedIssues:     []string{},
+		AppliedRules:    []string{},
+	}
+
+	failed := 0
+	for i := range plan.Steps {
+		step := &plan.Steps[i]
+		if err := step.run(); err != nil {
+			failed++
+			step.Status = "failed"
+			step.Error = err.Error()
+			log.Printf("[WARN] Connectivity fix step %d failed: %s: %v", step.Order, step.Command, err)
+			continue
+		}
+		step.Status = "applied"
+		if step.change != nil {
+			change := *step.change
+			change.Order = step.Order
+			change.Command = step.Command
+			plan.changes = append(plan.changes, change)
+		}
+		result.FixedIssues = append(result.FixedIssues, step.Reason)
+		if step.Action != "link_up" && step.Action != "sysctl" {
+			result.AppliedRules = append(result.AppliedRules, strings.Split(step.Command, "\n")...)
+		}
+	}
+
+	now := time.Now()
+	plan.ExecutedAt = &now
+	result.Success = failed == 0
+	switch {
+	case len(plan.Steps) == 0:
+		result.Message = "无需修改，连通性所需的规则和设置均已存在"
+	case failed > 0:
+		result.Message = fmt.Sprintf("%d/%d 个步骤执行失败", failed, len(plan.Steps))
+	}
+	log.Printf("[INFO] Connectivity fix %s <-> %s executed: %d steps, %d failed", plan.TunnelInterface, plan.DockerBridge, len(plan.Steps), failed)
+	return result
+}
+
+// recordConnectivityChangeSet 保存计划实际做出的变更，供之后回滚；没有变更时不记录
+func recordConnectivityChangeSet(plan *ConnectivityPlan, result *models.ConnectivityFixResult, username string) {
+	if len(plan.changes) == 0 {
+		return
+	}
+	changeSet := models.ConnectivityChangeSet{
+		TunnelInterface: plan.TunnelInterface,
+		DockerBridge:    plan.DockerBridge,
+		Persistent:      plan.Persistent,
+		PlanID:          plan.ID,
+		Success:         result.Success,
+		Status:          "applied",
+		CreatedBy:       username,
+		Changes:         plan.changes,
+	}
+	if err := config.DB.Create(&changeSet).Error; err != nil {
+		log.Printf("[ERROR] Failed to record connectivity change set for %s <-> %s: %v", plan.TunnelInterface, plan.DockerBridge, err)
+		return
+	}
+	result.ChangeSetID = changeSet.ID
+}
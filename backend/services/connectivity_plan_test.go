@@ -0,0 +1,147 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"iptables-management-backend/models"
+)
+
+const sampleForwardSave = `*filter
+:INPUT ACCEPT [0:0]
+:FORWARD DROP [0:0]
+[10:600] -A FORWARD -i wg0 -o docker0 -j DROP
+[0:0] -A FORWARD -i docker0 -o wg0 -p tcp -j REJECT --reject-with tcp-reset
+[0:0] -A FORWARD -i wg0 -o br-1234 -j DROP
+[0:0] -A FORWARD -i wg0 -o docker0 -j ACCEPT
+[0:0] -A FORWARD ! -i wg0 -o docker0 -j DROP
+COMMIT
+`
+
+func TestFindBlockingForwardRules(t *testing.T) {
+	ruleset, err := ParseIPTablesSave(sampleForwardSave)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rules := findBlockingForwardRules(ruleset, "wg0", "docker0")
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 blocking rules, got %+v", rules)
+	}
+	if rules[0].Position != 1 || rules[0].Spec != "-i wg0 -o docker0 -j DROP" || rules[0].Packets != 10 {
+		t.Errorf("unexpected first blocking rule: %+v", rules[0])
+	}
+	if rules[1].Position != 2 || rules[1].Match.Target != "REJECT" {
+		t.Errorf("unexpected second blocking rule: %+v", rules[1])
+	}
+
+	if rules := findBlockingForwardRules(&SavedRuleset{}, "wg0", "docker0"); len(rules) != 0 {
+		t.Errorf("expected no rules without filter table, got %+v", rules)
+	}
+}
+
+func TestRulesetFingerprint(t *testing.T) {
+	ruleset, _ := ParseIPTablesSave(sampleForwardSave)
+	fingerprint := rulesetFingerprint(ruleset, "filter")
+	if fingerprint == "" {
+		t.Fatal("expected fingerprint")
+	}
+
+	// 计数器变化不影响摘要
+	counters, _ := ParseIPTablesSave(`*filter
+:INPUT ACCEPT [5:300]
+:FORWARD DROP [9:900]
+[99:6000] -A FORWARD -i wg0 -o docker0 -j DROP
+[1:60] -A FORWARD -i docker0 -o wg0 -p tcp -j REJECT --reject-with tcp-reset
+[0:0] -A FORWARD -i wg0 -o br-1234 -j DROP
+[0:0] -A FORWARD -i wg0 -o docker0 -j ACCEPT
+[0:0] -A FORWARD ! -i wg0 -o docker0 -j DROP
+COMMIT
+`)
+	if got := rulesetFingerprint(counters, "filter"); got != fingerprint {
+		t.Errorf("fingerprint changed with counters: %s != %s", got, fingerprint)
+	}
+
+	// 删除一条规则后摘要改变
+	changed, _ := ParseIPTablesSave(`*filter
+:INPUT ACCEPT [0:0]
+:FORWARD DROP [0:0]
+[0:0] -A FORWARD -i docker0 -o wg0 -p tcp -j REJECT --reject-with tcp-reset
+COMMIT
+`)
+	if got := rulesetFingerprint(changed, "filter"); got == fingerprint {
+		t.Error("fingerprint should change when rules change")
+	}
+	if got := rulesetFingerprint(changed, "nat"); got != "" {
+		t.Errorf("expected empty fingerprint for missing table, got %s", got)
+	}
+}
+
+func TestRunConnectivityPlan(t *testing.T) {
+	var executed []int
+	plan := &ConnectivityPlan{TunnelInterface: "wg0", DockerBridge: "docker0"}
+	plan.addStep("insert_rule", "iptables -I FORWARD 1 -i wg0 -o docker0 -j ACCEPT", "allow", func() error {
+		executed = append(executed, 1)
+		return nil
+	})
+	plan.addStep("link_up", "ip link set wg0 up", "down", func() error {
+		executed = append(executed, 2)
+		return errors.New("RTNETLINK answers: Operation not permitted")
+	})
+	plan.addStep("delete_rule", "iptables -t filter -D FORWARD -i wg0 -o docker0 -j DROP", "blocking", func() error {
+		executed = append(executed, 3)
+		return nil
+	})
+
+	result := runConnectivityPlan(plan)
+	if len(executed) != 3 || executed[0] != 1 || executed[2] != 3 {
+		t.Errorf("steps should run in order and continue after failures, got %v", executed)
+	}
+	if result.Success || plan.Steps[1].Status != "failed" || plan.Steps[1].Error == "" || plan.Steps[2].Status != "applied" {
+		t.Errorf("unexpected step status: %+v", plan.Steps)
+	}
+	if len(result.AppliedRules) != 2 || len(result.FixedIssues) != 2 || plan.ExecutedAt == nil {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	// 只记录执行成功且带回滚信息的步骤
+	plan = &ConnectivityPlan{}
+	plan.addChangeStep("insert_rule", "iptables -I FORWARD 1 -i wg0 -o docker0 -j ACCEPT", "allow",
+		&models.ConnectivityChange{Kind: "inserted_rule", Table: "filter", Chain: "FORWARD", Spec: "-i wg0 -o docker0 -j ACCEPT"},
+		func() error { return nil })
+	plan.addChangeStep("link_up", "ip link set wg0 up", "down",
+		&models.ConnectivityChange{Kind: "link_up", Target: "wg0", Previous: "down"},
+		func() error { return errors.New("failed") })
+	plan.addStep("managed_group", "iptables -I DOCKER-USER 1 -j RETURN", "group", func() error { return nil })
+	runConnectivityPlan(plan)
+	if len(plan.changes) != 1 || plan.changes[0].Order != 1 || plan.changes[0].Command != plan.Steps[0].Command {
+		t.Errorf("unexpected recorded changes: %+v", plan.changes)
+	}
+
+	empty := runConnectivityPlan(&ConnectivityPlan{})
+	if !empty.Success || empty.Message == "" {
+		t.Errorf("empty plan should succeed with a message: %+v", empty)
+	}
+}
+
+func TestConnectivityPlanStore(t *testing.T) {
+	plan := StoreConnectivityPlan(&ConnectivityPlan{ExpiresAt: time.Now().Add(time.Minute)})
+	if plan.ID == "" {
+		t.Fatal("expected plan ID")
+	}
+	if got, err := GetConnectivityPlan(plan.ID); err != nil || got != plan {
+		t.Errorf("GetConnectivityPlan() = %v, %v", got, err)
+	}
+
+	expired := StoreConnectivityPlan(&ConnectivityPlan{ExpiresAt: time.Now().Add(-time.Second)})
+	if _, err := GetConnectivityPlan(expired.ID); err == nil {
+		t.Error("expected error for expired plan")
+	}
+	if _, _, err := ExecuteConnectivityPlan(expired.ID, "admin"); err == nil {
+		t.Error("expected error when executing expired plan")
+	}
+	if _, _, err := ExecuteConnectivityPlan("missing", "admin"); err == nil {
+		t.Error("expected error for unknown plan")
+	}
+}
@@ -0,0 +1,460 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"iptables-management-backend/models"
+)
+
+const procConntrackPath = "/proc/net/nf_conntrack"
+
+// ErrConntrackNoSelector 删除时既没有选中连接也没有过滤条件（拒绝清空整张表）
+var ErrConntrackNoSelector = errors.New("refusing to delete conntrack entries without selectors or filter")
+
+// ConntrackService 连接跟踪表查询与管理服务
+type ConntrackService struct{}
+
+// ConntrackFilter 连接跟踪查询条件
+type ConntrackFilter struct {
+	Protocol  string // tcp, udp, icmp ...
+	State     string // ESTABLISHED, TIME_WAIT ...
+	Interface string // 按接口地址所在子网匹配任一方向的地址
+	Subnet    string // CIDR，匹配任一方向的地址
+	OrigSrc   string
+	OrigDst   string
+	ReplySrc  string
+	ReplyDst  string
+	Port      int    // 匹配原始方向的源或目的端口
+	NAT       string // none, snat, dnat, any
+	Limit     int
+}
+
+// ConntrackSelector 要删除的连接（以原始方向元组定位）
+type ConntrackSelector struct {
+	Family   string                `json:"family"`
+	Protocol string                `json:"protocol" binding:"required"`
+	Zone     int                   `json:"zone"`
+	Original models.ConntrackTuple `json:"original" binding:"required"`
+}
+
+// ConntrackListResult 连接跟踪查询结果
+type ConntrackListResult struct {
+	Entries           []models.ConntrackEntry `json:"entries"`
+	Matched           int                     `json:"matched"`
+	Total             int                     `json:"total"`
+	TableCount        int                     `json:"table_count"` // nf_conntrack_count
+	TableMax          int                     `json:"table_max"`   // nf_conntrack_max
+	AccountingEnabled bool                    `json:"accounting_enabled"`
+	Source            string                  `json:"source"` // proc 或 conntrack
+}
+
+// ConntrackDeleteResult 删除结果
+type ConntrackDeleteResult struct {
+	Deleted  int      `json:"deleted"`
+	Commands []string `json:"commands"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// compiledConntrackFilter 预先解析的过滤条件
+type compiledConntrackFilter struct {
+	ConntrackFilter
+	subnet            *net.IPNet
+	interfaceNetworks []*net.IPNet
+}
+
+// NewConntrackService 创建连接跟踪服务实例
+func NewConntrackService() *ConntrackService {
+	return &ConntrackService{}
+}
+
+// ListEntries 读取连接跟踪表并按条件过滤
+func (s *ConntrackService) ListEntries(filter ConntrackFilter) (*ConntrackListResult, error) {
+	log.Printf("[DEBUG] ConntrackService.ListEntries called with filter: %+v", filter)
+
+	entries, source, err := s.readEntries()
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := compileConntrackFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ConntrackListResult{
+		Entries:           []models.ConntrackEntry{},
+		Total:             len(entries),
+		TableCount:        readSysctlInt("/proc/sys/net/netfilter/nf_conntrack_count"),
+		TableMax:          readSysctlInt("/proc/sys/net/netfilter/nf_conntrack_max"),
+		AccountingEnabled: readSysctlInt("/proc/sys/net/netfilter/nf_conntrack_acct") == 1,
+		Source:            source,
+	}
+	for _, entry := range entries {
+		if !compiled.matches(entry) {
+			continue
+		}
+		result.Matched++
+		if filter.Limit <= 0 || len(result.Entries) < filter.Limit {
+			result.Entries = append(result.Entries, entry)
+		}
+	}
+	return result, nil
+}
+
+// DeleteEntries 删除指定连接；selectors 为空时删除所有满足过滤条件的连接（过滤条件不能为空）
+func (s *ConntrackService) DeleteEntries(selectors []ConntrackSelector, filter *ConntrackFilter) (*ConntrackDeleteResult, error) {
+	if len(selectors) == 0 {
+		if filter == nil || filter.isEmpty() {
+			return nil, ErrConntrackNoSelector
+		}
+		listFilter := *filter
+		listFilter.Limit = 0
+		listed, err := s.ListEntries(listFilter)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range listed.Entries {
+			selectors = append(selectors, ConntrackSelector{
+				Family:   entry.Family,
+				Protocol: entry.Protocol,
+				Zone:     entry.Zone,
+				Original: entry.Original,
+			})
+		}
+	}
+
+	result := &ConntrackDeleteResult{Commands: []string{}}
+	for _, selector := range selectors {
+		args, err := conntrackDeleteArgs(selector)
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			continue
+		}
+		command := "conntrack " + strings.Join(args, " ")
+		if _, err := runCommand("conntrack", args...); err != nil {
+			// 连接可能已自然过期
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", command, err))
+			continue
+		}
+		result.Deleted++
+		result.Commands = append(result.Commands, command)
+	}
+
+	log.Printf("[DEBUG] Deleted %d conntrack entries (%d errors)", result.Deleted, len(result.Errors))
+	return result, nil
+}
+
+// readEntries 优先读取 /proc/net/nf_conntrack，不存在时使用 conntrack -L
+func (s *ConntrackService) readEntries() ([]models.ConntrackEntry, string, error) {
+	if data, err := os.ReadFile(procConntrackPath); err == nil {
+		return ParseConntrack(string(data)), "proc", nil
+	}
+
+	var entries []models.ConntrackEntry
+	for _, family := range []string{"ipv4", "ipv6"} {
+		output, err := runCommand("conntrack", "-L", "-f", family, "-o", "extended")
+		if err != nil {
+			if family == "ipv4" {
+				return nil, "", fmt.Errorf("failed to read conntrack table (no %s and conntrack -L failed): %v", procConntrackPath, err)
+			}
+			continue
+		}
+		entries = append(entries, ParseConntrack(string(output))...)
+	}
+	return entries, "conntrack", nil
+}
+
+// ParseConntrack 解析 /proc/net/nf_conntrack 或 conntrack -L -o extended 的输出
+func ParseConntrack(content string) []models.ConntrackEntry {
+	var entries []models.ConntrackEntry
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if entry, ok := ParseConntrackLine(scanner.Text()); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// ParseConntrackLine 解析单条连接，格式如：
+// ipv4 2 tcp 6 431999 ESTABLISHED src=10.0.0.1 dst=10.0.0.2 sport=5000 dport=22 packets=3 bytes=180 src=10.0.0.2 dst=10.0.0.1 sport=22 dport=5000 packets=2 bytes=120 [ASSURED] mark=0 zone=0 use=2
+func ParseConntrackLine(line string) (models.ConntrackEntry, bool) {
+	entry := models.ConntrackEntry{}
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return entry, false
+	}
+
+	entry.Family = fields[0]
+	entry.Protocol = fields[2]
+	entry.ProtoNum, _ = strconv.Atoi(fields[3])
+	timeout, err := strconv.Atoi(fields[4])
+	if err != nil {
+		return entry, false
+	}
+	entry.Timeout = timeout
+
+	// 第一组 src= 之前的非键值字段为连接状态（仅TCP/SCTP/DCCP等有）
+	tupleIndex := 0
+	tuplesDone := false // 遇到 mark= 等连接级字段后，后续的 id= 为连接ID而非ICMP id
+	for _, field := range fields[5:] {
+		switch {
+		case strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]"):
+			entry.Flags = append(entry.Flags, strings.Trim(field, "[]"))
+			continue
+		case !strings.Contains(field, "="):
+			if tupleIndex == 0 {
+				entry.State = field
+			}
+			continue
+		}
+
+		parts := strings.SplitN(field, "=", 2)
+		key, value := parts[0], parts[1]
+		if key == "src" {
+			tupleIndex++
+		}
+
+		var tuple *models.ConntrackTuple
+		switch tupleIndex {
+		case 1:
+			tuple = &entry.Original
+		case 2:
+			tuple = &entry.Reply
+		}
+
+		switch key {
+		case "mark":
+			mark, _ := strconv.ParseUint(value, 10, 32)
+			entry.Mark = uint32(mark)
+			tuplesDone = true
+		case "zone":
+			entry.Zone, _ = strconv.Atoi(value)
+			tuplesDone = true
+		case "use", "secctx", "delta-time", "helper":
+			tuplesDone = true
+		default:
+			if tuple != nil && !tuplesDone {
+				setConntrackTupleField(tuple, key, value)
+			}
+		}
+	}
+
+	if entry.Original.Src == "" || entry.Reply.Src == "" {
+		return entry, false
+	}
+	entry.NAT = conntrackNATType(entry)
+	return entry, true
+}
+
+// setConntrackTupleField 设置元组字段
+func setConntrackTupleField(tuple *models.ConntrackTuple, key, value string) {
+	number := func() int {
+		v, _ := strconv.Atoi(value)
+		return v
+	}
+	switch key {
+	case "src":
+		tuple.Src = value
+	case "dst":
+		tuple.Dst = value
+	case "sport":
+		tuple.SrcPort = number()
+	case "dport":
+		tuple.DstPort = number()
+	case "type":
+		v := number()
+		tuple.ICMPType = &v
+	case "code":
+		v := number()
+		tuple.ICMPCode = &v
+	case "id":
+		v := number()
+		tuple.ICMPID = &v
+	case "packets":
+		tuple.Packets, _ = strconv.ParseUint(value, 10, 64)
+	case "bytes":
+		tuple.Bytes, _ = strconv.ParseUint(value, 10, 64)
+	}
+}
+
+// conntrackNATType 通过比较原始与应答元组判断NAT类型
+// 应答方向的源与原始目的不同为DNAT，应答方向的目的与原始源不同为SNAT
+func conntrackNATType(entry models.ConntrackEntry) string {
+	dnat := entry.Reply.Src != entry.Original.Dst ||
+		(entry.Original.DstPort != 0 && entry.Reply.SrcPort != entry.Original.DstPort)
+	snat := entry.Reply.Dst != entry.Original.Src ||
+		(entry.Original.SrcPort != 0 && entry.Reply.DstPort != entry.Original.SrcPort)
+
+	switch {
+	case dnat && snat:
+		return "both"
+	case dnat:
+		return "dnat"
+	case snat:
+		return "snat"
+	default:
+		return "none"
+	}
+}
+
+// conntrackDeleteArgs 生成 conntrack -D 参数，按原始方向元组精确定位
+func conntrackDeleteArgs(selector ConntrackSelector) ([]string, error) {
+	original := selector.Original
+	if net.ParseIP(original.Src) == nil || net.ParseIP(original.Dst) == nil {
+		return nil, invalidf("invalid original tuple %s -> %s", original.Src, original.Dst)
+	}
+
+	family := selector.Family
+	if family == "" {
+		family = "ipv4"
+		if net.ParseIP(original.Src).To4() == nil {
+			family = "ipv6"
+		}
+	}
+
+	args := []string{"-D", "-f", family, "-p", strings.ToLower(selector.Protocol),
+		"--orig-src", original.Src, "--orig-dst", original.Dst}
+	if original.SrcPort > 0 {
+		args = append(args, "--orig-port-src", strconv.Itoa(original.SrcPort))
+	}
+	if original.DstPort > 0 {
+		args = append(args, "--orig-port-dst", strconv.Itoa(original.DstPort))
+	}
+	if original.ICMPType != nil {
+		args = append(args, "--icmp-type", strconv.Itoa(*original.ICMPType))
+	}
+	if original.ICMPCode != nil {
+		args = append(args, "--icmp-code", strconv.Itoa(*original.ICMPCode))
+	}
+	if original.ICMPID != nil {
+		args = append(args, "--icmp-id", strconv.Itoa(*original.ICMPID))
+	}
+	if selector.Zone != 0 {
+		args = append(args, "-w", strconv.Itoa(selector.Zone))
+	}
+	return args, nil
+}
+
+// compileConntrackFilter 解析子网和接口地址
+func compileConntrackFilter(filter ConntrackFilter) (*compiledConntrackFilter, error) {
+	compiled := &compiledConntrackFilter{ConntrackFilter: filter}
+
+	if filter.Subnet != "" {
+		_, network, err := net.ParseCIDR(filter.Subnet)
+		if err != nil {
+			return nil, invalidf("invalid subnet %s: %v", filter.Subnet, err)
+		}
+		compiled.subnet = network
+	}
+
+	if filter.Interface != "" {
+		iface, err := net.InterfaceByName(filter.Interface)
+		if err != nil {
+			return nil, invalidf("failed to find interface %s: %v", filter.Interface, err)
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get addresses of %s: %v", filter.Interface, err)
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLinkLocalUnicast() {
+				compiled.interfaceNetworks = append(compiled.interfaceNetworks,
+					&net.IPNet{IP: ipNet.IP.Mask(ipNet.Mask), Mask: ipNet.Mask})
+			}
+		}
+		if len(compiled.interfaceNetworks) == 0 {
+			return nil, invalidf("interface %s has no addresses", filter.Interface)
+		}
+	}
+	return compiled, nil
+}
+
+// matches 判断连接是否满足过滤条件
+func (f *compiledConntrackFilter) matches(entry models.ConntrackEntry) bool {
+	if f.Protocol != "" && !strings.EqualFold(f.Protocol, entry.Protocol) {
+		return false
+	}
+	if f.State != "" && !strings.EqualFold(f.State, entry.State) {
+		return false
+	}
+	if f.OrigSrc != "" && f.OrigSrc != entry.Original.Src {
+		return false
+	}
+	if f.OrigDst != "" && f.OrigDst != entry.Original.Dst {
+		return false
+	}
+	if f.ReplySrc != "" && f.ReplySrc != entry.Reply.Src {
+		return false
+	}
+	if f.ReplyDst != "" && f.ReplyDst != entry.Reply.Dst {
+		return false
+	}
+	if f.Port != 0 && f.Port != entry.Original.SrcPort && f.Port != entry.Original.DstPort {
+		return false
+	}
+	switch strings.ToLower(f.NAT) {
+	case "", "all":
+	case "any":
+		if entry.NAT == "none" {
+			return false
+		}
+	case "snat", "dnat":
+		if entry.NAT != strings.ToLower(f.NAT) && entry.NAT != "both" {
+			return false
+		}
+	default:
+		if entry.NAT != strings.ToLower(f.NAT) {
+			return false
+		}
+	}
+
+	addresses := []string{entry.Original.Src, entry.Original.Dst, entry.Reply.Src, entry.Reply.Dst}
+	if f.subnet != nil && !anyAddressInNetworks(addresses, []*net.IPNet{f.subnet}) {
+		return false
+	}
+	if len(f.interfaceNetworks) > 0 && !anyAddressInNetworks(addresses, f.interfaceNetworks) {
+		return false
+	}
+	return true
+}
+
+// isEmpty 过滤条件是否为空（用于防止误删全部连接）
+func (f ConntrackFilter) isEmpty() bool {
+	return f.Protocol == "" && f.State == "" && f.Interface == "" && f.Subnet == "" &&
+		f.OrigSrc == "" && f.OrigDst == "" && f.ReplySrc == "" && f.ReplyDst == "" &&
+		f.Port == 0 && f.NAT == ""
+}
+
+// anyAddressInNetworks 判断是否有地址落在任一网段内
+func anyAddressInNetworks(addresses []string, networks []*net.IPNet) bool {
+	for _, address := range addresses {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			continue
+		}
+		for _, network := range networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readSysctlInt 读取整数型 sysctl 文件，失败时返回0
+func readSysctlInt(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	value, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return value
+}
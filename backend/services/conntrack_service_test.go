@@ -0,0 +1,136 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"iptables-management-backend/models"
+)
+
+const sampleConntrack = `ipv4     2 tcp      6 431999 ESTABLISHED src=10.8.0.2 dst=192.168.1.10 sport=51000 dport=8080 packets=12 bytes=1500 src=172.17.0.2 dst=10.8.0.2 sport=80 dport=51000 packets=10 bytes=9000 [ASSURED] mark=0 zone=0 use=2
+ipv4     2 udp      17 25 src=172.17.0.3 dst=8.8.8.8 sport=40000 dport=53 [UNREPLIED] src=8.8.8.8 dst=192.168.1.10 sport=53 dport=40000 mark=0 zone=0 use=2
+ipv4     2 icmp     1 29 src=10.8.0.2 dst=10.8.0.1 type=8 code=0 id=4242 src=10.8.0.1 dst=10.8.0.2 type=0 code=0 id=4242 mark=0 zone=0 use=2 id=3735928559
+ipv6     10 tcp      6 117 TIME_WAIT src=fd00::2 dst=fd00::1 sport=40100 dport=22 src=fd00::1 dst=fd00::2 sport=22 dport=40100 [ASSURED] mark=0 zone=0 use=2
+garbage line
+`
+
+func TestParseConntrack(t *testing.T) {
+	entries := ParseConntrack(sampleConntrack)
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+
+	tcp := entries[0]
+	if tcp.Protocol != "tcp" || tcp.State != "ESTABLISHED" || tcp.Timeout != 431999 {
+		t.Errorf("unexpected tcp header: %+v", tcp)
+	}
+	if tcp.Original.Packets != 12 || tcp.Reply.Bytes != 9000 || tcp.Reply.SrcPort != 80 {
+		t.Errorf("unexpected tcp tuples: %+v / %+v", tcp.Original, tcp.Reply)
+	}
+	if tcp.NAT != "dnat" || !reflect.DeepEqual(tcp.Flags, []string{"ASSURED"}) {
+		t.Errorf("expected dnat with ASSURED flag, got %s %v", tcp.NAT, tcp.Flags)
+	}
+
+	udp := entries[1]
+	if udp.State != "" || udp.NAT != "snat" || !reflect.DeepEqual(udp.Flags, []string{"UNREPLIED"}) {
+		t.Errorf("unexpected udp entry: %+v", udp)
+	}
+
+	icmp := entries[2]
+	if icmp.NAT != "none" || icmp.Original.ICMPID == nil || *icmp.Original.ICMPID != 4242 || *icmp.Reply.ICMPType != 0 {
+		t.Errorf("unexpected icmp entry: %+v", icmp)
+	}
+
+	if entries[3].Family != "ipv6" || entries[3].State != "TIME_WAIT" {
+		t.Errorf("unexpected ipv6 entry: %+v", entries[3])
+	}
+}
+
+func TestConntrackFilterMatches(t *testing.T) {
+	entries := ParseConntrack(sampleConntrack)
+
+	tests := []struct {
+		name   string
+		filter ConntrackFilter
+		want   int
+	}{
+		{"no filter", ConntrackFilter{}, 4},
+		{"protocol", ConntrackFilter{Protocol: "TCP"}, 2},
+		{"state", ConntrackFilter{State: "established"}, 1},
+		{"subnet matches reply tuple", ConntrackFilter{Subnet: "172.17.0.0/16"}, 2},
+		{"original destination", ConntrackFilter{OrigDst: "8.8.8.8"}, 1},
+		{"reply source", ConntrackFilter{ReplySrc: "172.17.0.2"}, 1},
+		{"port", ConntrackFilter{Port: 22}, 1},
+		{"any nat", ConntrackFilter{NAT: "any"}, 2},
+		{"no nat", ConntrackFilter{NAT: "none"}, 2},
+		{"snat", ConntrackFilter{NAT: "snat"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compiled, err := compileConntrackFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("compileConntrackFilter returned error: %v", err)
+			}
+			got := 0
+			for _, entry := range entries {
+				if compiled.matches(entry) {
+					got++
+				}
+			}
+			if got != tt.want {
+				t.Errorf("matched %d entries, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConntrackDeleteArgs(t *testing.T) {
+	icmpType, icmpCode, icmpID := 8, 0, 4242
+	tests := []struct {
+		name     string
+		selector ConntrackSelector
+		want     []string
+	}{
+		{
+			name: "tcp",
+			selector: ConntrackSelector{Protocol: "tcp", Original: models.ConntrackTuple{
+				Src: "10.8.0.2", Dst: "192.168.1.10", SrcPort: 51000, DstPort: 8080}},
+			want: []string{"-D", "-f", "ipv4", "-p", "tcp", "--orig-src", "10.8.0.2", "--orig-dst", "192.168.1.10",
+				"--orig-port-src", "51000", "--orig-port-dst", "8080"},
+		},
+		{
+			name: "icmp in zone",
+			selector: ConntrackSelector{Protocol: "icmp", Zone: 3, Original: models.ConntrackTuple{
+				Src: "10.8.0.2", Dst: "10.8.0.1", ICMPType: &icmpType, ICMPCode: &icmpCode, ICMPID: &icmpID}},
+			want: []string{"-D", "-f", "ipv4", "-p", "icmp", "--orig-src", "10.8.0.2", "--orig-dst", "10.8.0.1",
+				"--icmp-type", "8", "--icmp-code", "0", "--icmp-id", "4242", "-w", "3"},
+		},
+		{
+			name: "ipv6 family detected",
+			selector: ConntrackSelector{Protocol: "tcp", Original: models.ConntrackTuple{
+				Src: "fd00::2", Dst: "fd00::1", SrcPort: 40100, DstPort: 22}},
+			want: []string{"-D", "-f", "ipv6", "-p", "tcp", "--orig-src", "fd00::2", "--orig-dst", "fd00::1",
+				"--orig-port-src", "40100", "--orig-port-dst", "22"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := conntrackDeleteArgs(tt.selector)
+			if err != nil {
+				t.Fatalf("conntrackDeleteArgs returned error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("conntrackDeleteArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	var validation *ValidationError
+	if _, err := conntrackDeleteArgs(ConntrackSelector{Protocol: "tcp"}); !errors.As(err, &validation) {
+		t.Errorf("expected validation error for empty tuple, got %v", err)
+	}
+	if _, err := (&ConntrackService{}).DeleteEntries(nil, &ConntrackFilter{}); !errors.Is(err, ErrConntrackNoSelector) {
+		t.Errorf("expected ErrConntrackNoSelector for an empty filter, got %v", err)
+	}
+}
@@ -0,0 +1,705 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"iptables-management-backend/config"
+	"iptables-management-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	CounterKindInterface = "interface"
+	CounterKindRule      = "rule"
+)
+
+// ErrNoCounterHistory 请求的接口或规则没有任何采样记录
+var ErrNoCounterHistory = errors.New("no counter history")
+
+// counterResolution 采样分辨率及其保留时长
+type counterResolution struct {
+	Seconds   int
+	Retention time.Duration
+}
+
+// counterResolutions 从细到粗排列：1分钟保留1天，5分钟保留7天，1小时保留90天
+var counterResolutions = []counterResolution{
+	{Seconds: 60, Retention: 24 * time.Hour},
+	{Seconds: 300, Retention: 7 * 24 * time.Hour},
+	{Seconds: 3600, Retention: 90 * 24 * time.Hour},
+}
+
+// counterHistoryRetention 最粗分辨率的保留时长，即可查询的最长计数历史
+func counterHistoryRetention() time.Duration {
+	longest := time.Duration(0)
+	for _, res := range counterResolutions {
+		if res.Retention > longest {
+			longest = res.Retention
+		}
+	}
+	return longest
+}
+
+// maxCounterPoints 单次查询返回的最大数据点数，超过时使用更粗的分辨率
+const maxCounterPoints = 1500
+
+type CounterService struct {
+	networkService *NetworkService
+	mutex          sync.Mutex
+	series         map[string]*models.CounterSeries // kind|key -> 序列
+	bootTime       time.Time                        // 系统启动时间，用于识别重启造成的计数器重置
+}
+
+// NewCounterService 创建计数器时间序列服务实例
+func NewCounterService(networkService *NetworkService) *CounterService {
+	return &CounterService{
+		networkService: networkService,
+	}
+}
+
+// CounterWindow 时间窗口内的计数器汇总
+type CounterWindow struct {
+	From               time.Time      `json:"from"`
+	To                 time.Time      `json:"to"`
+	Resolution         int            `json:"resolution"` // 数据点间隔（秒）
+	Packets            uint64         `json:"packets"`    // 规则计数 / 接口接收
+	Bytes              uint64         `json:"bytes"`      // 规则计数 / 接口接收
+	TxPackets          uint64         `json:"tx_packets"` // 仅接口
+	TxBytes            uint64         `json:"tx_bytes"`   // 仅接口
+	PacketsPerSecond   float64        `json:"packets_per_second"`
+	BytesPerSecond     float64        `json:"bytes_per_second"`
+	TxPacketsPerSecond float64        `json:"tx_packets_per_second"`
+	TxBytesPerSecond   float64        `json:"tx_bytes_per_second"`
+	Points             []CounterPoint `json:"points"`
+}
+
+// CounterPoint 单个时间桶的增量
+type CounterPoint struct {
+	Timestamp        time.Time `json:"timestamp"`
+	Packets          uint64    `json:"packets"`
+	Bytes            uint64    `json:"bytes"`
+	TxPackets        uint64    `json:"tx_packets"`
+	TxBytes          uint64    `json:"tx_bytes"`
+	BytesPerSecond   float64   `json:"bytes_per_second"`
+	TxBytesPerSecond float64   `json:"tx_bytes_per_second"`
+}
+
+// RuleCounterTotal 单条规则在时间窗口内的汇总
+type RuleCounterTotal struct {
+	Series           models.CounterSeries `json:"series"`
+	Packets          uint64               `json:"packets"`
+	Bytes            uint64               `json:"bytes"`
+	PacketsPerSecond float64              `json:"packets_per_second"`
+	BytesPerSecond   float64              `json:"bytes_per_second"`
+}
+
+// RuleCounterHistory 单条规则的历史
+type RuleCounterHistory struct {
+	Series models.CounterSeries `json:"series"`
+	Window *CounterWindow       `json:"window"`
+}
+
+// PairCounterHistory 隧道与网桥之间的流量历史
+type PairCounterHistory struct {
+	TunnelInterface string         `json:"tunnel_interface"`
+	DockerBridge    string         `json:"docker_bridge"`
+	TunnelToBridge  *CounterWindow `json:"tunnel_to_bridge"` // 匹配 -i 隧道 -o 网桥 的ACCEPT规则
+	BridgeToTunnel  *CounterWindow `json:"bridge_to_tunnel"` // 匹配 -i 网桥 -o 隧道 的ACCEPT规则
+	Dropped         *CounterWindow `json:"dropped"`          // 两个方向上的DROP/REJECT规则
+	TunnelStats     *CounterWindow `json:"tunnel_stats,omitempty"`
+	BridgeStats     *CounterWindow `json:"bridge_stats,omitempty"`
+	RuleCount       int            `json:"rule_count"`
+}
+
+// counterObservation 一次采样得到的原始计数
+type counterObservation struct {
+	series    models.CounterSeries
+	packets   uint64
+	bytes     uint64
+	txPackets uint64
+	txBytes   uint64
+}
+
+// StartSampler 启动后台采样，定期记录规则和接口计数器
+func (s *CounterService) StartSampler(interval time.Duration) {
+	go func() {
+		log.Printf("[INFO] Counter sampler started, interval %v", interval)
+		lastPrune := time.Now()
+
+		if err := s.SampleOnce(); err != nil {
+			log.Printf("[ERROR] Counter sampling failed: %v", err)
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := s.SampleOnce(); err != nil {
+				log.Printf("[ERROR] Counter sampling failed: %v", err)
+			}
+			if time.Since(lastPrune) >= time.Hour {
+				if err := s.PruneSamples(); err != nil {
+					log.Printf("[ERROR] Counter pruning failed: %v", err)
+				}
+				lastPrune = time.Now()
+			}
+		}
+	}()
+}
+
+// SampleOnce 采集一次计数器并写入各分辨率的时间桶
+func (s *CounterService) SampleOnce() error {
+	observations := s.collectObservations()
+	if len(observations) == 0 {
+		return fmt.Errorf("no counters collected")
+	}
+	return s.recordObservations(observations, time.Now())
+}
+
+// recordObservations 将一次采样的原始计数转换为增量写入数据库。
+// 计数未变化的序列只刷新 last_seen_at，空闲但仍存在的规则不会被当作已删除而清理
+func (s *CounterService) recordObservations(observations []counterObservation, now time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.series == nil {
+		if err := s.loadSeries(); err != nil {
+			return err
+		}
+	}
+	if s.bootTime.IsZero() {
+		bootTime, err := systemBootTime()
+		if err != nil {
+			log.Printf("[WARN] Failed to read system boot time, counter resets are only detected by decreasing values: %v", err)
+		}
+		s.bootTime = bootTime
+	}
+	bootTime := s.bootTime
+
+	var idle []*models.CounterSeries
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, obs := range observations {
+			cacheKey := obs.series.Kind + "|" + obs.series.Key
+			series, ok := s.series[cacheKey]
+			if !ok {
+				// 新序列以当前值作为基线，不产生增量
+				created := obs.series
+				created.LastPackets = obs.packets
+				created.LastBytes = obs.bytes
+				created.LastTxPackets = obs.txPackets
+				created.LastTxBytes = obs.txBytes
+				created.LastSeenAt = now
+				if err := tx.Create(&created).Error; err != nil {
+					return fmt.Errorf("failed to create counter series %s: %v", cacheKey, err)
+				}
+				s.series[cacheKey] = &created
+				continue
+			}
+
+			reset := counterReset(series, obs, bootTime)
+			if !reset && series.LastPackets == obs.packets && series.LastBytes == obs.bytes &&
+				series.LastTxPackets == obs.txPackets && series.LastTxBytes == obs.txBytes {
+				idle = append(idle, series)
+				continue
+			}
+
+			// 重置后的计数都是重置之后产生的
+			last := *series
+			if reset {
+				last.LastPackets, last.LastBytes, last.LastTxPackets, last.LastTxBytes = 0, 0, 0, 0
+			}
+			sample := models.CounterSample{
+				SeriesID:  series.ID,
+				Packets:   counterDelta(last.LastPackets, obs.packets),
+				Bytes:     counterDelta(last.LastBytes, obs.bytes),
+				TxPackets: counterDelta(last.LastTxPackets, obs.txPackets),
+				TxBytes:   counterDelta(last.LastTxBytes, obs.txBytes),
+			}
+
+			updates := map[string]interface{}{
+				"last_packets":    obs.packets,
+				"last_bytes":      obs.bytes,
+				"last_tx_packets": obs.txPackets,
+				"last_tx_bytes":   obs.txBytes,
+				"last_seen_at":    now,
+			}
+			if reset {
+				updates["resets"] = series.Resets + 1
+				updates["last_reset_at"] = now
+			}
+			if err := tx.Model(series).Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to update counter series %s: %v", cacheKey, err)
+			}
+			if reset {
+				series.Resets++
+				series.LastResetAt = &now
+			}
+			series.LastPackets = obs.packets
+			series.LastBytes = obs.bytes
+			series.LastTxPackets = obs.txPackets
+			series.LastTxBytes = obs.txBytes
+			series.LastSeenAt = now
+
+			if sample.Packets == 0 && sample.Bytes == 0 && sample.TxPackets == 0 && sample.TxBytes == 0 {
+				continue
+			}
+			for _, res := range counterResolutions {
+				if err := addCounterSample(tx, sample, res.Seconds, now); err != nil {
+					return fmt.Errorf("failed to store counter sample for %s: %v", cacheKey, err)
+				}
+			}
+		}
+		return touchCounterSeries(tx, idle, now)
+	})
+	if err != nil {
+		// 事务已回滚，下次采样时重新加载序列缓存
+		s.series = nil
+	}
+	return err
+}
+
+// touchCounterSeriesBatch 单条 UPDATE 语句中的最大序列数，避免超过 SQLite 参数上限
+const touchCounterSeriesBatch = 500
+
+// touchCounterSeries 批量刷新计数未变化的序列的 last_seen_at
+func touchCounterSeries(tx *gorm.DB, idle []*models.CounterSeries, now time.Time) error {
+	for start := 0; start < len(idle); start += touchCounterSeriesBatch {
+		end := min(start+touchCounterSeriesBatch, len(idle))
+		ids := make([]uint, 0, end-start)
+		for _, series := range idle[start:end] {
+			ids = append(ids, series.ID)
+		}
+		if err := tx.Model(&models.CounterSeries{}).Where("id IN ?", ids).
+			Update("last_seen_at", now).Error; err != nil {
+			return fmt.Errorf("failed to refresh counter series: %v", err)
+		}
+	}
+	for _, series := range idle {
+		series.LastSeenAt = now
+	}
+	return nil
+}
+
+// collectObservations 读取当前接口统计和规则计数器
+func (s *CounterService) collectObservations() []counterObservation {
+	var observations []counterObservation
+
+	interfaceStats, err := s.networkService.GetAllInterfaceStats()
+	if err != nil {
+		log.Printf("[WARN] Failed to read interface stats for sampling: %v", err)
+	}
+	for name, stats := range interfaceStats {
+		observations = append(observations, counterObservation{
+			series:    models.CounterSeries{Kind: CounterKindInterface, Key: name},
+			packets:   uint64(stats.RxPackets),
+			bytes:     uint64(stats.RxBytes),
+			txPackets: uint64(stats.TxPackets),
+			txBytes:   uint64(stats.TxBytes),
+		})
+	}
+
+	ruleset, err := LoadSavedRuleset()
+	if err != nil {
+		log.Printf("[WARN] Failed to read rule counters for sampling: %v", err)
+		return observations
+	}
+	for _, table := range ruleset.Tables {
+		for _, rule := range table.Rules {
+			observations = append(observations, counterObservation{
+				series: models.CounterSeries{
+					Kind:         CounterKindRule,
+					Key:          rule.Key,
+					Table:        rule.Table,
+					ChainName:    rule.Chain,
+					InInterface:  rule.Match.InInterface,
+					OutInterface: rule.Match.OutInterface,
+					Target:       rule.Match.Target,
+					Comment:      rule.Match.Comment,
+					Spec:         rule.Spec,
+				},
+				packets: rule.Packets,
+				bytes:   rule.Bytes,
+			})
+		}
+	}
+
+	return observations
+}
+
+// loadSeries 从数据库加载已有序列（含上次采样的原始计数，重启后继续计算增量）
+func (s *CounterService) loadSeries() error {
+	var all []models.CounterSeries
+	if err := config.DB.Find(&all).Error; err != nil {
+		return fmt.Errorf("failed to load counter series: %v", err)
+	}
+
+	s.series = make(map[string]*models.CounterSeries, len(all))
+	for i := range all {
+		s.series[all[i].Kind+"|"+all[i].Key] = &all[i]
+	}
+	return nil
+}
+
+// addCounterSample 将增量累加到指定分辨率的时间桶
+func addCounterSample(tx *gorm.DB, sample models.CounterSample, resolution int, now time.Time) error {
+	sample.Resolution = resolution
+	sample.BucketStart = now.Unix() / int64(resolution) * int64(resolution)
+
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "series_id"}, {Name: "resolution"}, {Name: "bucket_start"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"packets":    gorm.Expr("packets + ?", sample.Packets),
+			"bytes":      gorm.Expr("bytes + ?", sample.Bytes),
+			"tx_packets": gorm.Expr("tx_packets + ?", sample.TxPackets),
+			"tx_bytes":   gorm.Expr("tx_bytes + ?", sample.TxBytes),
+		}),
+	}).Create(&sample).Error
+}
+
+// counterDelta 计算两次采样间的增量，计数器被重置（规则重建、重启）时以当前值为增量
+func counterDelta(last, current uint64) uint64 {
+	if current >= last {
+		return current - last
+	}
+	return current
+}
+
+// counterReset 判断上次采样后计数器是否被重置：计数变小（规则重建、iptables-restore、接口重建），
+// 或者上次采样发生在系统启动之前（重启后即使计数已超过上次的值也属于重置后的计数）
+func counterReset(series *models.CounterSeries, obs counterObservation, bootTime time.Time) bool {
+	if obs.packets < series.LastPackets || obs.bytes < series.LastBytes ||
+		obs.txPackets < series.LastTxPackets || obs.txBytes < series.LastTxBytes {
+		return true
+	}
+	return !bootTime.IsZero() && !series.LastSeenAt.IsZero() && series.LastSeenAt.Before(bootTime)
+}
+
+// systemBootTime 从 /proc/stat 的 btime 读取系统启动时间
+func systemBootTime() (time.Time, error) {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "btime "); ok {
+			seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid btime in /proc/stat: %v", err)
+			}
+			return time.Unix(seconds, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("btime not found in /proc/stat")
+}
+
+// PruneSamples 按各分辨率的保留时长清理旧样本和不再出现的序列
+func (s *CounterService) PruneSamples() error {
+	return s.pruneSamples(time.Now())
+}
+
+// pruneSamples 以 now 为基准清理；每次采样都会刷新仍存在的序列的 last_seen_at，
+// 因此只有超过最长保留时长未出现的序列（规则已删除、接口已移除）会被删除
+func (s *CounterService) pruneSamples(now time.Time) error {
+	for _, res := range counterResolutions {
+		cutoff := now.Add(-res.Retention).Unix()
+		if err := config.DB.Where("resolution = ? AND bucket_start < ?", res.Seconds, cutoff).
+			Delete(&models.CounterSample{}).Error; err != nil {
+			return fmt.Errorf("failed to prune counter samples: %v", err)
+		}
+	}
+	longest := counterHistoryRetention()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := config.DB.Where("last_seen_at < ? AND id NOT IN (?)", now.Add(-longest),
+		config.DB.Model(&models.CounterSample{}).Distinct("series_id")).
+		Delete(&models.CounterSeries{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to prune counter series: %v", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		// 重新加载缓存，避免继续引用已删除的序列
+		s.series = nil
+		log.Printf("[INFO] Pruned %d stale counter series", result.RowsAffected)
+	}
+	return nil
+}
+
+// chooseResolution 选择能覆盖窗口起点且数据点数不超过上限的最细分辨率
+func chooseResolution(from, to time.Time) int {
+	age := time.Since(from)
+	span := to.Sub(from)
+	for _, res := range counterResolutions {
+		if age <= res.Retention && span/(time.Duration(res.Seconds)*time.Second) <= maxCounterPoints {
+			return res.Seconds
+		}
+	}
+	return counterResolutions[len(counterResolutions)-1].Seconds
+}
+
+// queryWindow 汇总一组序列在时间窗口内的增量
+func queryWindow(seriesIDs []uint, from, to time.Time) (*CounterWindow, error) {
+	resolution := chooseResolution(from, to)
+	window := &CounterWindow{
+		From:       from,
+		To:         to,
+		Resolution: resolution,
+		Points:     []CounterPoint{},
+	}
+	if len(seriesIDs) == 0 {
+		return window, nil
+	}
+
+	var rows []struct {
+		BucketStart int64
+		Packets     uint64
+		Bytes       uint64
+		TxPackets   uint64
+		TxBytes     uint64
+	}
+	start := from.Unix() / int64(resolution) * int64(resolution)
+	err := config.DB.Model(&models.CounterSample{}).
+		Select("bucket_start, SUM(packets) AS packets, SUM(bytes) AS bytes, SUM(tx_packets) AS tx_packets, SUM(tx_bytes) AS tx_bytes").
+		Where("series_id IN ? AND resolution = ? AND bucket_start >= ? AND bucket_start < ?", seriesIDs, resolution, start, to.Unix()).
+		Group("bucket_start").
+		Order("bucket_start").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query counter samples: %v", err)
+	}
+
+	for _, row := range rows {
+		window.Packets += row.Packets
+		window.Bytes += row.Bytes
+		window.TxPackets += row.TxPackets
+		window.TxBytes += row.TxBytes
+		window.Points = append(window.Points, CounterPoint{
+			Timestamp:        time.Unix(row.BucketStart, 0),
+			Packets:          row.Packets,
+			Bytes:            row.Bytes,
+			TxPackets:        row.TxPackets,
+			TxBytes:          row.TxBytes,
+			BytesPerSecond:   float64(row.Bytes) / float64(resolution),
+			TxBytesPerSecond: float64(row.TxBytes) / float64(resolution),
+		})
+	}
+
+	if seconds := to.Sub(from).Seconds(); seconds > 0 {
+		window.PacketsPerSecond = float64(window.Packets) / seconds
+		window.BytesPerSecond = float64(window.Bytes) / seconds
+		window.TxPacketsPerSecond = float64(window.TxPackets) / seconds
+		window.TxBytesPerSecond = float64(window.TxBytes) / seconds
+	}
+	return window, nil
+}
+
+// GetInterfaceHistory 获取接口在时间窗口内的收发量和速率
+func (s *CounterService) GetInterfaceHistory(name string, from, to time.Time) (*CounterWindow, error) {
+	var series models.CounterSeries
+	if err := config.DB.Where(&models.CounterSeries{Kind: CounterKindInterface, Key: name}).First(&series).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w for interface %s", ErrNoCounterHistory, name)
+		}
+		return nil, fmt.Errorf("failed to query counter series: %v", err)
+	}
+	return queryWindow([]uint{series.ID}, from, to)
+}
+
+// GetRuleHistory 获取单条规则（按规则标识）在时间窗口内的计数和速率
+func (s *CounterService) GetRuleHistory(key string, from, to time.Time) (*RuleCounterHistory, error) {
+	var series models.CounterSeries
+	if err := config.DB.Where(&models.CounterSeries{Kind: CounterKindRule, Key: key}).First(&series).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w for rule %s", ErrNoCounterHistory, key)
+		}
+		return nil, fmt.Errorf("failed to query counter series: %v", err)
+	}
+
+	window, err := queryWindow([]uint{series.ID}, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &RuleCounterHistory{Series: series, Window: window}, nil
+}
+
+// GetRuleTotals 获取规则在时间窗口内的汇总（可按表、链过滤），按字节数降序
+func (s *CounterService) GetRuleTotals(table, chain string, from, to time.Time) ([]RuleCounterTotal, error) {
+	var seriesList []models.CounterSeries
+	if err := config.DB.Where(&models.CounterSeries{Kind: CounterKindRule, Table: table, ChainName: chain}).
+		Find(&seriesList).Error; err != nil {
+		return nil, fmt.Errorf("failed to query counter series: %v", err)
+	}
+	if len(seriesList) == 0 {
+		return []RuleCounterTotal{}, nil
+	}
+
+	ids := make([]uint, len(seriesList))
+	for i, series := range seriesList {
+		ids[i] = series.ID
+	}
+
+	sums, err := sumSeriesSamples(ids, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	totalsByID := make(map[uint]RuleCounterTotal, len(sums))
+	seconds := to.Sub(from).Seconds()
+	for id, sum := range sums {
+		total := RuleCounterTotal{Packets: sum.Packets, Bytes: sum.Bytes}
+		if seconds > 0 {
+			total.PacketsPerSecond = float64(sum.Packets) / seconds
+			total.BytesPerSecond = float64(sum.Bytes) / seconds
+		}
+		totalsByID[id] = total
+	}
+
+	totals := make([]RuleCounterTotal, 0, len(seriesList))
+	for _, series := range seriesList {
+		total := totalsByID[series.ID]
+		total.Series = series
+		totals = append(totals, total)
+	}
+	sort.SliceStable(totals, func(i, j int) bool {
+		return totals[i].Bytes > totals[j].Bytes
+	})
+	return totals, nil
+}
+
+// seriesSum 单个序列在时间窗口内的增量合计
+type seriesSum struct {
+	Packets uint64
+	Bytes   uint64
+}
+
+// sumSeriesSamples 按序列汇总时间窗口内的增量，没有样本的序列不出现在结果中
+func sumSeriesSamples(ids []uint, from, to time.Time) (map[uint]seriesSum, error) {
+	resolution := chooseResolution(from, to)
+	var rows []struct {
+		SeriesID uint
+		Packets  uint64
+		Bytes    uint64
+	}
+	start := from.Unix() / int64(resolution) * int64(resolution)
+	err := config.DB.Model(&models.CounterSample{}).
+		Select("series_id, SUM(packets) AS packets, SUM(bytes) AS bytes").
+		Where("series_id IN ? AND resolution = ? AND bucket_start >= ? AND bucket_start < ?", ids, resolution, start, to.Unix()).
+		Group("series_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query counter samples: %v", err)
+	}
+
+	sums := make(map[uint]seriesSum, len(rows))
+	for _, row := range rows {
+		sums[row.SeriesID] = seriesSum{Packets: row.Packets, Bytes: row.Bytes}
+	}
+	return sums, nil
+}
+
+// GetInterfaceRuleHistory 汇总所有以该接口为入/出接口的规则的计数
+func (s *CounterService) GetInterfaceRuleHistory(name string, from, to time.Time) (*CounterWindow, error) {
+	var ids []uint
+	if err := config.DB.Model(&models.CounterSeries{}).
+		Where("kind = ? AND (in_interface = ? OR out_interface = ?)", CounterKindRule, name, name).
+		Pluck("id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to query counter series: %v", err)
+	}
+	return queryWindow(ids, from, to)
+}
+
+// GetPairHistory 获取隧道接口与Docker网桥之间两个方向的转发量和丢弃量
+func (s *CounterService) GetPairHistory(tunnelInterface, dockerBridge string, from, to time.Time) (*PairCounterHistory, error) {
+	var seriesList []models.CounterSeries
+	if err := config.DB.Where("kind = ? AND ((in_interface = ? AND out_interface = ?) OR (in_interface = ? AND out_interface = ?))",
+		CounterKindRule, tunnelInterface, dockerBridge, dockerBridge, tunnelInterface).
+		Find(&seriesList).Error; err != nil {
+		return nil, fmt.Errorf("failed to query counter series: %v", err)
+	}
+
+	var toBridge, toTunnel, dropped []uint
+	for _, series := range seriesList {
+		if series.Table != "filter" {
+			continue
+		}
+		switch series.Target {
+		case "ACCEPT":
+			if series.InInterface == tunnelInterface {
+				toBridge = append(toBridge, series.ID)
+			} else {
+				toTunnel = append(toTunnel, series.ID)
+			}
+		case "DROP", "REJECT":
+			dropped = append(dropped, series.ID)
+		}
+	}
+
+	history := &PairCounterHistory{
+		TunnelInterface: tunnelInterface,
+		DockerBridge:    dockerBridge,
+		RuleCount:       len(seriesList),
+	}
+
+	var err error
+	if history.TunnelToBridge, err = queryWindow(toBridge, from, to); err != nil {
+		return nil, err
+	}
+	if history.BridgeToTunnel, err = queryWindow(toTunnel, from, to); err != nil {
+		return nil, err
+	}
+	if history.Dropped, err = queryWindow(dropped, from, to); err != nil {
+		return nil, err
+	}
+
+	// 接口本身的历史可能尚未采集，忽略错误
+	history.TunnelStats, _ = s.GetInterfaceHistory(tunnelInterface, from, to)
+	history.BridgeStats, _ = s.GetInterfaceHistory(dockerBridge, from, to)
+
+	return history, nil
+}
+
+// ParseCounterWindow 解析查询窗口：from/to 为RFC3339时间，未提供时取最近 hours 小时
+func ParseCounterWindow(hoursStr, fromStr, toStr string) (time.Time, time.Time, error) {
+	to := time.Now()
+	if toStr != "" {
+		parsed, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to time: %v", err)
+		}
+		to = parsed
+	}
+
+	var from time.Time
+	if fromStr != "" {
+		parsed, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from time: %v", err)
+		}
+		from = parsed
+	} else {
+		hours := 24.0
+		if hoursStr != "" {
+			parsed, err := strconv.ParseFloat(hoursStr, 64)
+			if err != nil || parsed <= 0 {
+				return time.Time{}, time.Time{}, fmt.Errorf("invalid hours: %s", hoursStr)
+			}
+			hours = parsed
+		}
+		from = to.Add(-time.Duration(hours * float64(time.Hour)))
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must be before to")
+	}
+	return from, to, nil
+}
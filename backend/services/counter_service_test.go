@@ -0,0 +1,194 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"iptables-management-backend/config"
+	"iptables-management-backend/models"
+)
+
+func TestCounterDelta(t *testing.T) {
+	testCases := []struct {
+		name     string
+		last     uint64
+		current  uint64
+		expected uint64
+	}{
+		{"increase", 100, 250, 150},
+		{"unchanged", 100, 100, 0},
+		{"counter reset", 1000, 40, 40},
+		{"reset to zero", 1000, 0, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := counterDelta(tc.last, tc.current); result != tc.expected {
+				t.Errorf("counterDelta(%d, %d) = %d, expected %d", tc.last, tc.current, result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestCounterReset(t *testing.T) {
+	boot := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name     string
+		lastSeen time.Time
+		packets  uint64
+		bytes    uint64
+		bootTime time.Time
+		expected bool
+	}{
+		{"increase after boot", boot.Add(time.Hour), 150, 15000, boot, false},
+		{"decrease", boot.Add(time.Hour), 40, 4000, boot, true},
+		{"bytes decrease", boot.Add(time.Hour), 100, 500, boot, true},
+		{"rebooted and already above last value", boot.Add(-time.Hour), 500, 50000, boot, true},
+		{"rebooted and still zero", boot.Add(-time.Hour), 0, 0, boot, true},
+		{"unknown boot time", boot.Add(-time.Hour), 150, 15000, time.Time{}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			series := &models.CounterSeries{LastPackets: 100, LastBytes: 10000, LastSeenAt: tc.lastSeen}
+			obs := counterObservation{packets: tc.packets, bytes: tc.bytes}
+			if result := counterReset(series, obs, tc.bootTime); result != tc.expected {
+				t.Errorf("counterReset() = %v, expected %v", result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestChooseResolution(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		name     string
+		from     time.Time
+		to       time.Time
+		expected int
+	}{
+		{"last hour", now.Add(-time.Hour), now, 60},
+		{"last day", now.Add(-23 * time.Hour), now, 60},
+		{"last three days", now.Add(-72 * time.Hour), now, 300},
+		{"last month", now.Add(-30 * 24 * time.Hour), now, 3600},
+		{"old short window", now.Add(-48 * time.Hour), now.Add(-47 * time.Hour), 300},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := chooseResolution(tc.from, tc.to); result != tc.expected {
+				t.Errorf("chooseResolution() = %d, expected %d", result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestParseCounterWindow(t *testing.T) {
+	from, to, err := ParseCounterWindow("6", "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if span := to.Sub(from); span != 6*time.Hour {
+		t.Errorf("expected 6h window, got %v", span)
+	}
+
+	from, to, err = ParseCounterWindow("", "2024-01-01T00:00:00Z", "2024-01-01T12:00:00Z")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if span := to.Sub(from); span != 12*time.Hour {
+		t.Errorf("expected 12h window, got %v", span)
+	}
+
+	invalid := [][3]string{
+		{"abc", "", ""},
+		{"-1", "", ""},
+		{"", "yesterday", ""},
+		{"", "2024-01-02T00:00:00Z", "2024-01-01T00:00:00Z"},
+	}
+	for _, args := range invalid {
+		if _, _, err := ParseCounterWindow(args[0], args[1], args[2]); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+}
+
+func TestIdleRuleSurvivesPrune(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	previous := config.DB
+	config.DB = db
+	defer func() { config.DB = previous }()
+	if err := config.AutoMigrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	rule := func(key string, packets uint64) counterObservation {
+		return counterObservation{
+			series:  models.CounterSeries{Kind: CounterKindRule, Key: key, Table: "filter", ChainName: "INPUT"},
+			packets: packets,
+			bytes:   packets * 60,
+		}
+	}
+	start := time.Now().Add(-100 * 24 * time.Hour)
+	s := &CounterService{bootTime: start.Add(-time.Hour)}
+	if err := s.recordObservations([]counterObservation{rule("idle", 100), rule("deleted", 5)}, start); err != nil {
+		t.Fatalf("first sample: %v", err)
+	}
+	if err := s.recordObservations([]counterObservation{rule("idle", 150)}, start.Add(time.Hour)); err != nil {
+		t.Fatalf("second sample: %v", err)
+	}
+	// 之后规则一直存在但没有流量，样本已超过保留时长
+	if err := s.recordObservations([]counterObservation{rule("idle", 150)}, time.Now()); err != nil {
+		t.Fatalf("idle sample: %v", err)
+	}
+
+	var before models.CounterSeries
+	if err := db.Where("key = ?", "idle").First(&before).Error; err != nil {
+		t.Fatalf("load idle series: %v", err)
+	}
+	if err := s.pruneSamples(time.Now()); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+
+	var after models.CounterSeries
+	if err := db.Where("key = ?", "idle").First(&after).Error; err != nil {
+		t.Fatalf("idle rule series was pruned: %v", err)
+	}
+	if after.ID != before.ID || !after.CreatedAt.Equal(before.CreatedAt) {
+		t.Errorf("idle rule series was recreated: %+v -> %+v", before, after)
+	}
+	var deleted int64
+	db.Model(&models.CounterSeries{}).Where("key = ?", "deleted").Count(&deleted)
+	if deleted != 0 {
+		t.Errorf("expected the series of a deleted rule to be pruned")
+	}
+}
+
+func TestHistoryWithoutSeries(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	previous := config.DB
+	config.DB = db
+	defer func() { config.DB = previous }()
+	if err := config.AutoMigrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	service := NewCounterService(nil)
+	now := time.Now()
+	if _, err := service.GetInterfaceHistory("eth9", now.Add(-time.Hour), now); !errors.Is(err, ErrNoCounterHistory) {
+		t.Errorf("GetInterfaceHistory: expected ErrNoCounterHistory, got %v", err)
+	}
+	if _, err := service.GetRuleHistory("missing", now.Add(-time.Hour), now); !errors.Is(err, ErrNoCounterHistory) {
+		t.Errorf("GetRuleHistory: expected ErrNoCounterHistory, got %v", err)
+	}
+}
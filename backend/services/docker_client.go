@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultDockerSocket = "/var/run/docker.sock"
+
+// DockerClient Docker Engine API 客户端（通过unix socket访问）
+type DockerClient struct {
+	socketPath   string
+	httpClient   *http.Client
+	streamClient *http.Client // 无超时，用于 /events 长连接
+}
+
+// DockerNetwork Docker网络（/networks 返回的结构）
+type DockerNetwork struct {
+	ID       string            `json:"Id"`
+	Name     string            `json:"Name"`
+	Driver   string            `json:"Driver"`
+	Scope    string            `json:"Scope"`
+	Internal bool              `json:"Internal"`
+	IPAM     DockerNetworkIPAM `json:"IPAM"`
+	Options  map[string]string `json:"Options"`
+	Labels   map[string]string `json:"Labels"`
+}
+
+// DockerNetworkIPAM Docker网络的IPAM配置
+type DockerNetworkIPAM struct {
+	Driver  string            `json:"Driver"`
+	Options map[string]string `json:"Options"`
+	Config  []struct {
+		Subnet  string `json:"Subnet"`
+		Gateway string `json:"Gateway"`
+	} `json:"Config"`
+}
+
+// DockerContainerSummary 容器摘要（/containers/json 返回的结构）
+type DockerContainerSummary struct {
+	ID      string            `json:"Id"`
+	Names   []string          `json:"Names"`
+	Image   string            `json:"Image"`
+	State   string            `json:"State"`
+	Status  string            `json:"Status"`
+	Labels  map[string]string `json:"Labels"`
+	Ports   []DockerAPIPort   `json:"Ports"`
+	Network struct {
+		Networks map[string]DockerEndpoint `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// DockerAPIPort 容器发布的端口
+type DockerAPIPort struct {
+	IP          string `json:"IP"`
+	PrivatePort int    `json:"PrivatePort"`
+	PublicPort  int    `json:"PublicPort"`
+	Type        string `json:"Type"`
+}
+
+// DockerEndpoint 容器在某个网络中的端点
+type DockerEndpoint struct {
+	NetworkID         string `json:"NetworkID"`
+	IPAddress         string `json:"IPAddress"`
+	IPPrefixLen       int    `json:"IPPrefixLen"`
+	Gateway           string `json:"Gateway"`
+	MacAddress        string `json:"MacAddress"`
+	GlobalIPv6Address string `json:"GlobalIPv6Address"`
+}
+
+// DockerEvent Docker事件（/events 返回的结构）
+type DockerEvent struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+	Time int64 `json:"time"`
+}
+
+// Name 容器名称（去掉前导 "/"）
+func (c DockerContainerSummary) Name() string {
+	if len(c.Names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// BridgeName Docker网络对应的Linux网桥名称
+func (n DockerNetwork) BridgeName() string {
+	if name := n.Options["com.docker.network.bridge.name"]; name != "" {
+		return name
+	}
+	if len(n.ID) >= 12 {
+		return "br-" + n.ID[:12]
+	}
+	return ""
+}
+
+// NewDockerClient 创建Docker客户端，socket路径取自 DOCKER_HOST（unix://...），默认 /var/run/docker.sock
+func NewDockerClient() *DockerClient {
+	socketPath := defaultDockerSocket
+	if host := os.Getenv("DOCKER_HOST"); strings.HasPrefix(host, "unix://") {
+		socketPath = strings.TrimPrefix(host, "unix://")
+	}
+	return NewDockerClientWithSocket(socketPath)
+}
+
+// NewDockerClientWithSocket 使用指定socket路径创建Docker客户端
+func NewDockerClientWithSocket(socketPath string) *DockerClient {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	return &DockerClient{
+		socketPath:   socketPath,
+		httpClient:   &http.Client{Transport: transport, Timeout: 5 * time.Second},
+		streamClient: &http.Client{Transport: transport},
+	}
+}
+
+// Available 检查Docker守护进程是否可用
+func (c *DockerClient) Available() bool {
+	if _, err := os.Stat(c.socketPath); err != nil {
+		return false
+	}
+	return c.Ping() == nil
+}
+
+// Ping 调用 /_ping
+func (c *DockerClient) Ping() error {
+	body, err := c.get("/_ping", nil)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(body)) != "OK" {
+		return fmt.Errorf("unexpected docker ping response: %s", string(body))
+	}
+	return nil
+}
+
+// ListNetworks 获取所有Docker网络
+func (c *DockerClient) ListNetworks() ([]DockerNetwork, error) {
+	var networks []DockerNetwork
+	if err := c.getJSON("/networks", nil, &networks); err != nil {
+		return nil, fmt.Errorf("failed to list docker networks: %v", err)
+	}
+	return networks, nil
+}
+
+// ListContainers 获取运行中的容器
+func (c *DockerClient) ListContainers() ([]DockerContainerSummary, error) {
+	var containers []DockerContainerSummary
+	if err := c.getJSON("/containers/json", nil, &containers); err != nil {
+		return nil, fmt.Errorf("failed to list docker containers: %v", err)
+	}
+	return containers, nil
+}
+
+// StreamEvents 订阅Docker事件流，直到ctx取消或连接断开（如Docker重启）时返回
+func (c *DockerClient) StreamEvents(ctx context.Context, filters map[string][]string, handle func(DockerEvent)) error {
+	query := url.Values{}
+	if len(filters) > 0 {
+		encoded, err := json.Marshal(filters)
+		if err != nil {
+			return fmt.Errorf("failed to encode event filters: %v", err)
+		}
+		query.Set("filters", string(encoded))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/events?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.streamClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe docker events: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("docker API /events returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event DockerEvent
+		if err := decoder.Decode(&event); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("docker event stream closed: %v", err)
+		}
+		handle(event)
+	}
+}
+
+// getJSON 发送GET请求并解析JSON响应
+func (c *DockerClient) getJSON(path string, query url.Values, out interface{}) error {
+	body, err := c.get(path, query)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, out)
+}
+
+// get 发送GET请求
+func (c *DockerClient) get(path string, query url.Values) ([]byte, error) {
+	target := "http://docker" + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	start := time.Now()
+	resp, err := c.httpClient.Get(target)
+	if err != nil {
+		recordCommand("docker-api", time.Since(start), err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err == nil && resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("docker API %s returned %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	recordCommand("docker-api", time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+const fakeDockerNetworks = `[
+  {"Name": "bridge", "Id": "f2de39df4171b0dc801e8002d1d999b77256983dfc63041c0f34030aa3977566", "Scope": "local", "Driver": "bridge",
+   "IPAM": {"Driver": "default", "Config": [{"Subnet": "172.17.0.0/16", "Gateway": "172.17.0.1"}]},
+   "Options": {"com.docker.network.bridge.name": "docker0"}},
+  {"Name": "app", "Id": "5c1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f8", "Scope": "local", "Driver": "bridge",
+   "IPAM": {"Driver": "default", "Config": [{"Subnet": "10.10.0.0/24", "Gateway": "10.10.0.1"}]},
+   "Labels": {"com.docker.compose.project": "demo"}},
+  {"Name": "host", "Id": "9a8b7c6d5e4f", "Scope": "local", "Driver": "host"}
+]`
+
+const fakeDockerContainers = `[
+  {"Id": "abc123", "Names": ["/web"], "Image": "nginx:latest", "State": "running", "Status": "Up 2 hours",
+   "Labels": {"role": "frontend"},
+   "Ports": [{"IP": "0.0.0.0", "PrivatePort": 80, "PublicPort": 8080, "Type": "tcp"}],
+   "NetworkSettings": {"Networks": {"app": {"NetworkID": "5c1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f8",
+     "IPAddress": "10.10.0.2", "IPPrefixLen": 24, "Gateway": "10.10.0.1", "MacAddress": "02:42:0a:0a:00:02"}}}}
+]`
+
+// startFakeDocker 在临时unix socket上启动模拟的Docker Engine API
+func startFakeDocker(t *testing.T) string {
+	socketPath := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("failed to listen on unix socket: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/_ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+	mux.HandleFunc("/networks", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fakeDockerNetworks))
+	})
+	mux.HandleFunc("/containers/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fakeDockerContainers))
+	})
+
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return socketPath
+}
+
+func TestDockerClientAgainstFakeSocket(t *testing.T) {
+	client := NewDockerClientWithSocket(startFakeDocker(t))
+
+	if !client.Available() {
+		t.Fatalf("expected fake docker to be available")
+	}
+
+	networks, err := client.ListNetworks()
+	if err != nil {
+		t.Fatalf("ListNetworks returned error: %v", err)
+	}
+	if len(networks) != 3 {
+		t.Fatalf("expected 3 networks, got %d", len(networks))
+	}
+
+	bridgeNames := []string{"docker0", "br-5c1a2b3c4d5e"}
+	for i, expected := range bridgeNames {
+		if got := networks[i].BridgeName(); got != expected {
+			t.Errorf("network %s: expected bridge name %s, got %s", networks[i].Name, expected, got)
+		}
+	}
+
+	containers, err := client.ListContainers()
+	if err != nil {
+		t.Fatalf("ListContainers returned error: %v", err)
+	}
+	if len(containers) != 1 || containers[0].Name() != "web" {
+		t.Fatalf("unexpected containers: %+v", containers)
+	}
+
+	onApp := dockerContainersOnNetwork(networks[1].ID, containers)
+	if len(onApp) != 1 {
+		t.Fatalf("expected 1 container on app network, got %d", len(onApp))
+	}
+	web := onApp[0]
+	if web.IPAddress != "10.10.0.2" || web.Image != "nginx:latest" || web.Labels["role"] != "frontend" {
+		t.Errorf("unexpected container metadata: %+v", web)
+	}
+	if len(web.Ports) != 1 || web.Ports[0].HostPort != 8080 || web.Ports[0].ContainerPort != 80 {
+		t.Errorf("unexpected published ports: %+v", web.Ports)
+	}
+	if onDefault := dockerContainersOnNetwork(networks[0].ID, containers); len(onDefault) != 0 {
+		t.Errorf("expected no containers on default bridge, got %d", len(onDefault))
+	}
+}
+
+func TestDockerClientUnavailable(t *testing.T) {
+	client := NewDockerClientWithSocket(filepath.Join(t.TempDir(), "missing.sock"))
+	if client.Available() {
+		t.Errorf("expected missing socket to be unavailable")
+	}
+	if _, err := client.ListNetworks(); err == nil {
+		t.Errorf("expected error listing networks without docker")
+	}
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"iptables-management-backend/models"
+)
+
+// DockerPortService Docker发布端口映射服务
+type DockerPortService struct {
+	networkService *NetworkService
+}
+
+// DockerPortFilter 端口映射查询条件
+type DockerPortFilter struct {
+	Protocol  string
+	Container string // 容器名称或ID前缀
+	Bridge    string
+	HostPort  string // 端口号，落在端口范围内也算匹配
+}
+
+// NewDockerPortService 创建Docker端口映射服务实例
+func NewDockerPortService(networkService *NetworkService) *DockerPortService {
+	return &DockerPortService{
+		networkService: networkService,
+	}
+}
+
+// GetPortMappings 获取主机端口到容器端口的映射表
+func (s *DockerPortService) GetPortMappings(filter DockerPortFilter) ([]models.DockerPortMapping, error) {
+	log.Printf("[DEBUG] DockerPortService.GetPortMappings called with filter: %+v", filter)
+
+	ruleset, err := LoadSavedRuleset()
+	if err != nil {
+		return nil, err
+	}
+	mappings := ParseDockerPortMappings(ruleset)
+
+	bridges, err := s.networkService.GetDockerBridges()
+	if err != nil {
+		log.Printf("[WARN] Failed to get docker bridges for port mapping: %v", err)
+	} else {
+		joinDockerPortContainers(mappings, bridges)
+	}
+
+	result := []models.DockerPortMapping{}
+	for _, mapping := range mappings {
+		if filter.matches(mapping) {
+			result = append(result, mapping)
+		}
+	}
+
+	log.Printf("[DEBUG] Found %d docker port mappings", len(result))
+	return result, nil
+}
+
+// ParseDockerPortMappings 从nat表DOCKER链的DNAT规则推导端口映射，并关联filter表DOCKER链的放行规则
+func ParseDockerPortMappings(ruleset *SavedRuleset) []models.DockerPortMapping {
+	mappings := []models.DockerPortMapping{}
+
+	nat := ruleset.Table("nat")
+	if nat == nil {
+		return mappings
+	}
+	var filterRules []SavedRule
+	if filter := ruleset.Table("filter"); filter != nil {
+		filterRules = filter.ChainRules("DOCKER")
+	}
+
+	for _, rule := range nat.ChainRules("DOCKER") {
+		match := rule.Match
+		if match.Target != "DNAT" {
+			continue
+		}
+		containerIP, containerPort, err := splitDNATDestination(match.TargetOption("--to-destination"))
+		if err != nil {
+			log.Printf("[WARN] Skipping DOCKER DNAT rule %s: %v", rule.Key, err)
+			continue
+		}
+
+		mapping := models.DockerPortMapping{
+			HostIP:        stripHostPrefix(match.Destination),
+			HostPort:      match.DestPort,
+			Protocol:      match.Protocol,
+			ContainerIP:   containerIP,
+			ContainerPort: containerPort,
+			ExposedOn:     "*",
+			DNATRuleKey:   rule.Key,
+			DNATPackets:   rule.Packets,
+			DNATBytes:     rule.Bytes,
+		}
+		if mapping.HostIP == "" {
+			mapping.HostIP = "0.0.0.0"
+		}
+		if mapping.ContainerPort == "" {
+			mapping.ContainerPort = mapping.HostPort
+		}
+		// Docker 以 "! -i <bridge>" 排除来自网桥自身的流量，网桥名即容器所在网桥
+		if strings.HasPrefix(match.InInterface, "!") {
+			mapping.Bridge = strings.TrimPrefix(match.InInterface, "!")
+		} else if match.InInterface != "" {
+			mapping.ExposedOn = match.InInterface
+		}
+
+		for _, filterRule := range filterRules {
+			if dockerFilterRuleMatches(filterRule.Match, mapping) {
+				mapping.Allowed = true
+				mapping.FilterRuleKey = filterRule.Key
+				mapping.FilterPackets = filterRule.Packets
+				mapping.FilterBytes = filterRule.Bytes
+				if mapping.Bridge == "" {
+					mapping.Bridge = filterRule.Match.OutInterface
+				}
+				break
+			}
+		}
+
+		mappings = append(mappings, mapping)
+	}
+
+	sort.SliceStable(mappings, func(i, j int) bool {
+		left, right := firstPort(mappings[i].HostPort), firstPort(mappings[j].HostPort)
+		if left != right {
+			return left < right
+		}
+		if mappings[i].Protocol != mappings[j].Protocol {
+			return mappings[i].Protocol < mappings[j].Protocol
+		}
+		return mappings[i].HostIP < mappings[j].HostIP
+	})
+	return mappings
+}
+
+// dockerFilterRuleMatches 判断filter表DOCKER链规则是否放行该映射的流量
+func dockerFilterRuleMatches(match RuleMatch, mapping models.DockerPortMapping) bool {
+	if match.Target != "ACCEPT" {
+		return false
+	}
+	if stripHostPrefix(match.Destination) != mapping.ContainerIP {
+		return false
+	}
+	if match.Protocol != mapping.Protocol || match.DestPort != mapping.ContainerPort {
+		return false
+	}
+	return mapping.Bridge == "" || match.OutInterface == mapping.Bridge
+}
+
+// splitDNATDestination 解析 --to-destination，如 172.17.0.2:80、172.17.0.2:8000-8010、[fd00::2]:80
+// 端口范围统一为 iptables 匹配使用的 "起:止" 格式
+func splitDNATDestination(destination string) (string, string, error) {
+	if destination == "" {
+		return "", "", fmt.Errorf("missing --to-destination")
+	}
+
+	host, port := destination, ""
+	if strings.HasPrefix(destination, "[") {
+		end := strings.Index(destination, "]")
+		if end == -1 {
+			return "", "", fmt.Errorf("invalid destination: %s", destination)
+		}
+		host = destination[1:end]
+		port = strings.TrimPrefix(destination[end+1:], ":")
+	} else if strings.Count(destination, ":") == 1 {
+		parts := strings.SplitN(destination, ":", 2)
+		host, port = parts[0], parts[1]
+	}
+
+	if net.ParseIP(host) == nil {
+		return "", "", fmt.Errorf("invalid destination address: %s", destination)
+	}
+	return host, strings.Replace(port, "-", ":", 1), nil
+}
+
+// stripHostPrefix 去掉单主机地址的 /32 或 /128 前缀长度
+func stripHostPrefix(address string) string {
+	address = strings.TrimSuffix(address, "/32")
+	return strings.TrimSuffix(address, "/128")
+}
+
+// firstPort 端口或端口范围的起始端口，无法解析时返回0
+func firstPort(port string) int {
+	value, _ := strconv.Atoi(strings.SplitN(port, ":", 2)[0])
+	return value
+}
+
+// portInRange 判断端口是否等于指定端口或落在 "起:止" 范围内
+func portInRange(port int, portRange string) bool {
+	parts := strings.SplitN(portRange, ":", 2)
+	start, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	end := start
+	if len(parts) == 2 {
+		if end, err = strconv.Atoi(parts[1]); err != nil {
+			return false
+		}
+	}
+	return port >= start && port <= end
+}
+
+// joinDockerPortContainers 按容器IP关联网桥上的容器信息
+func joinDockerPortContainers(mappings []models.DockerPortMapping, bridges []models.DockerBridge) {
+	type bridgeContainer struct {
+		bridge    string
+		container models.DockerContainer
+	}
+	byIP := make(map[string]bridgeContainer)
+	for _, bridge := range bridges {
+		for _, container := range bridge.Containers {
+			if container.IPAddress != "" {
+				byIP[container.IPAddress] = bridgeContainer{bridge: bridge.Name, container: container}
+			}
+			if container.IPv6Address != "" {
+				byIP[container.IPv6Address] = bridgeContainer{bridge: bridge.Name, container: container}
+			}
+		}
+	}
+
+	for i := range mappings {
+		found, ok := byIP[mappings[i].ContainerIP]
+		if !ok {
+			continue
+		}
+		mappings[i].ContainerID = found.container.ID
+		mappings[i].ContainerName = found.container.Name
+		mappings[i].Image = found.container.Image
+		if mappings[i].Bridge == "" {
+			mappings[i].Bridge = found.bridge
+		}
+	}
+}
+
+// matches 判断映射是否满足查询条件
+func (f DockerPortFilter) matches(mapping models.DockerPortMapping) bool {
+	if f.Protocol != "" && !strings.EqualFold(f.Protocol, mapping.Protocol) {
+		return false
+	}
+	if f.Bridge != "" && f.Bridge != mapping.Bridge {
+		return false
+	}
+	if f.Container != "" && f.Container != mapping.ContainerName &&
+		(mapping.ContainerID == "" || !strings.HasPrefix(mapping.ContainerID, f.Container)) {
+		return false
+	}
+	if f.HostPort != "" {
+		port, err := strconv.Atoi(f.HostPort)
+		if err != nil || !portInRange(port, mapping.HostPort) {
+			return false
+		}
+	}
+	return true
+}
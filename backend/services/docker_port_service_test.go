@@ -0,0 +1,106 @@
+package services
+
+import (
+	"testing"
+
+	"iptables-management-backend/models"
+)
+
+const dockerPortsSave = `*nat
+:PREROUTING ACCEPT [0:0]
+:DOCKER - [0:0]
+[12:720] -A DOCKER -i docker0 -j RETURN
+[5:300] -A DOCKER ! -i docker0 -p tcp -m tcp --dport 8080 -j DNAT --to-destination 172.17.0.2:80
+[0:0] -A DOCKER -d 127.0.0.1/32 ! -i br-5c1a2b3c4d5e -p udp -m udp --dport 5353 -j DNAT --to-destination 10.10.0.3:53
+[2:120] -A DOCKER ! -i docker0 -p tcp -m tcp --dport 9000:9002 -j DNAT --to-destination 172.17.0.4:7000-7002
+COMMIT
+*filter
+:FORWARD DROP [0:0]
+:DOCKER - [0:0]
+[40:2400] -A DOCKER -d 172.17.0.2/32 ! -i docker0 -o docker0 -p tcp -m tcp --dport 80 -j ACCEPT
+[1:60] -A DOCKER -d 10.10.0.3/32 ! -i br-5c1a2b3c4d5e -o br-5c1a2b3c4d5e -p udp -m udp --dport 53 -j ACCEPT
+COMMIT
+`
+
+func TestParseDockerPortMappings(t *testing.T) {
+	ruleset, err := ParseIPTablesSave(dockerPortsSave)
+	if err != nil {
+		t.Fatalf("ParseIPTablesSave returned error: %v", err)
+	}
+
+	mappings := ParseDockerPortMappings(ruleset)
+	if len(mappings) != 3 {
+		t.Fatalf("expected 3 mappings, got %d: %+v", len(mappings), mappings)
+	}
+
+	tests := []struct {
+		hostIP, hostPort, protocol, containerIP, containerPort, bridge string
+		dnatPackets, filterPackets                                     uint64
+		allowed                                                        bool
+	}{
+		{"127.0.0.1", "5353", "udp", "10.10.0.3", "53", "br-5c1a2b3c4d5e", 0, 1, true},
+		{"0.0.0.0", "8080", "tcp", "172.17.0.2", "80", "docker0", 5, 40, true},
+		{"0.0.0.0", "9000:9002", "tcp", "172.17.0.4", "7000:7002", "docker0", 2, 0, false},
+	}
+	for i, tt := range tests {
+		got := mappings[i]
+		if got.HostIP != tt.hostIP || got.HostPort != tt.hostPort || got.Protocol != tt.protocol ||
+			got.ContainerIP != tt.containerIP || got.ContainerPort != tt.containerPort || got.Bridge != tt.bridge {
+			t.Errorf("mapping %d: unexpected fields %+v", i, got)
+		}
+		if got.DNATPackets != tt.dnatPackets || got.FilterPackets != tt.filterPackets || got.Allowed != tt.allowed {
+			t.Errorf("mapping %d: unexpected counters %+v", i, got)
+		}
+		if got.ExposedOn != "*" {
+			t.Errorf("mapping %d: expected exposure on all interfaces, got %q", i, got.ExposedOn)
+		}
+	}
+}
+
+func TestDockerPortFilter(t *testing.T) {
+	mapping := models.DockerPortMapping{
+		HostPort: "9000:9002", Protocol: "tcp", Bridge: "docker0",
+		ContainerID: "abc123def456", ContainerName: "web",
+	}
+
+	tests := []struct {
+		name   string
+		filter DockerPortFilter
+		want   bool
+	}{
+		{"empty filter", DockerPortFilter{}, true},
+		{"port in range", DockerPortFilter{HostPort: "9001"}, true},
+		{"port outside range", DockerPortFilter{HostPort: "9003"}, false},
+		{"protocol case-insensitive", DockerPortFilter{Protocol: "TCP"}, true},
+		{"container by name", DockerPortFilter{Container: "web"}, true},
+		{"container by id prefix", DockerPortFilter{Container: "abc123"}, true},
+		{"other bridge", DockerPortFilter{Bridge: "br-5c1a2b3c4d5e"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.matches(mapping); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitDNATDestination(t *testing.T) {
+	tests := []struct {
+		input, ip, port string
+		wantErr         bool
+	}{
+		{"172.17.0.2:80", "172.17.0.2", "80", false},
+		{"172.17.0.2", "172.17.0.2", "", false},
+		{"[fd00::2]:8080", "fd00::2", "8080", false},
+		{"fd00::2", "fd00::2", "", false},
+		{"not-an-ip:80", "", "", true},
+		{"", "", "", true},
+	}
+	for _, tt := range tests {
+		ip, port, err := splitDNATDestination(tt.input)
+		if (err != nil) != tt.wantErr || ip != tt.ip || port != tt.port {
+			t.Errorf("splitDNATDestination(%q) = (%q, %q, %v)", tt.input, ip, port, err)
+		}
+	}
+}
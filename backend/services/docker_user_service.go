@@ -0,0 +1,554 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"iptables-management-backend/config"
+	"iptables-management-backend/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DockerUserChain Docker为用户规则预留的链，Docker重启时不会改写其中的规则
+	DockerUserChain = "DOCKER-USER"
+	// managedCommentPrefix 托管规则的注释前缀，用于识别由本系统维护的规则
+	managedCommentPrefix = "iptables-manager:"
+	// reconcileDebounce Docker事件触发后等待其完成链重建再检查
+	reconcileDebounce = 3 * time.Second
+)
+
+// managedRulesMutex 串行化托管规则的写入，避免多个服务实例同时插入
+var managedRulesMutex sync.Mutex
+
+// DockerUserService DOCKER-USER 托管规则服务
+type DockerUserService struct {
+	networkService *NetworkService
+	logService     *LogService
+	docker         *DockerClient
+	mutex          sync.RWMutex
+	state          DockerUserWatcherState
+	triggers       chan string
+}
+
+// DockerUserWatcherState 托管规则监视器状态
+type DockerUserWatcherState struct {
+	Running         bool       `json:"running"`
+	IntervalSeconds int        `json:"interval_seconds"`
+	EventsConnected bool       `json:"events_connected"`
+	LastCheckAt     *time.Time `json:"last_check_at,omitempty"`
+	LastReapplyAt   *time.Time `json:"last_reapply_at,omitempty"`
+	ReapplyCount    int        `json:"reapply_count"`
+	LastError       string     `json:"last_error,omitempty"`
+}
+
+// ManagedRuleStatus 托管规则及其在系统中的存在状态
+type ManagedRuleStatus struct {
+	models.ManagedRule
+	Command string `json:"command"`
+	Present bool   `json:"present"`
+}
+
+// ManagedGroupStatus 托管规则组状态
+type ManagedGroupStatus struct {
+	models.ManagedRuleGroup
+	Rules   []ManagedRuleStatus `json:"rules"`
+	Missing int                 `json:"missing"`
+}
+
+// ReconcileResult 一次托管规则检查的结果
+type ReconcileResult struct {
+	Trigger   string   `json:"trigger"`
+	Checked   int      `json:"checked"`
+	Reapplied []string `json:"reapplied"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// managedRuleEntry 按链展开后的托管规则（带链内期望位置）
+type managedRuleEntry struct {
+	group    string
+	groupID  uint
+	rule     models.ManagedRule
+	args     []string
+	position int // 在链中的期望位置：托管规则位于链首，按组ID和组内顺序排列
+}
+
+// NewDockerUserService 创建DOCKER-USER托管规则服务实例
+func NewDockerUserService(networkService *NetworkService, logService *LogService) *DockerUserService {
+	return &DockerUserService{
+		networkService: networkService,
+		logService:     logService,
+		docker:         NewDockerClient(),
+		triggers:       make(chan string, 1),
+	}
+}
+
+// StartWatcher 启动监视器：定期检查托管规则，并在Docker网络事件或Docker重启后立即检查
+func (s *DockerUserService) StartWatcher(interval time.Duration) {
+	s.mutex.Lock()
+	s.state.Running = true
+	s.state.IntervalSeconds = int(interval.Seconds())
+	s.mutex.Unlock()
+
+	go func() {
+		log.Printf("[INFO] DOCKER-USER watcher started, interval %v", interval)
+		s.runReconcile("startup")
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.runReconcile("periodic")
+			case trigger := <-s.triggers:
+				time.Sleep(reconcileDebounce)
+				s.runReconcile(trigger)
+			}
+		}
+	}()
+
+	go s.watchDockerEvents()
+}
+
+// watchDockerEvents 订阅Docker事件；事件流断开通常意味着Docker重启，重连后触发检查
+func (s *DockerUserService) watchDockerEvents() {
+	filters := map[string][]string{"type": {"network", "daemon"}}
+	backoff := 5 * time.Second
+	connectedBefore := false
+
+	for {
+		if !s.docker.Available() {
+			time.Sleep(backoff)
+			if backoff < time.Minute {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = 5 * time.Second
+
+		s.setEventsConnected(true)
+		if connectedBefore {
+			s.requestReconcile("docker_restart")
+		}
+		connectedBefore = true
+
+		err := s.docker.StreamEvents(context.Background(), filters, func(event DockerEvent) {
+			switch event.Action {
+			case "create", "connect", "destroy", "reload":
+				s.requestReconcile("docker_event:" + event.Type + "/" + event.Action)
+			}
+		})
+		s.setEventsConnected(false)
+		log.Printf("[WARN] Docker event stream ended: %v", err)
+		time.Sleep(backoff)
+	}
+}
+
+// requestReconcile 请求一次检查（已有待处理请求时合并）
+func (s *DockerUserService) requestReconcile(trigger string) {
+	select {
+	case s.triggers <- trigger:
+	default:
+	}
+}
+
+// setEventsConnected 更新事件流连接状态
+func (s *DockerUserService) setEventsConnected(connected bool) {
+	s.mutex.Lock()
+	s.state.EventsConnected = connected
+	s.mutex.Unlock()
+}
+
+// runReconcile 执行检查并记录错误
+func (s *DockerUserService) runReconcile(trigger string) {
+	if _, err := s.Reconcile(trigger); err != nil {
+		log.Printf("[ERROR] Managed rule reconcile (%s) failed: %v", trigger, err)
+	}
+}
+
+// GetWatcherState 获取监视器状态
+func (s *DockerUserService) GetWatcherState() DockerUserWatcherState {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.state
+}
+
+// ListGroups 获取所有托管规则组（含规则）
+func (s *DockerUserService) ListGroups() ([]models.ManagedRuleGroup, error) {
+	var groups []models.ManagedRuleGroup
+	err := config.DB.Preload("Rules", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC")
+	}).Order("id ASC").Find(&groups).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list managed rule groups: %v", err)
+	}
+	return groups, nil
+}
+
+// GetStatus 获取各托管规则组及规则在系统中的存在状态
+func (s *DockerUserService) GetStatus() ([]ManagedGroupStatus, error) {
+	groups, err := s.ListGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]ManagedGroupStatus, 0, len(groups))
+	for _, group := range groups {
+		statuses = append(statuses, managedGroupStatus(group))
+	}
+	return statuses, nil
+}
+
+// GetGroupStatus 获取单个托管规则组及其规则在系统中的存在状态
+func (s *DockerUserService) GetGroupStatus(id uint) (*ManagedGroupStatus, error) {
+	var group models.ManagedRuleGroup
+	err := config.DB.Preload("Rules", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC")
+	}).First(&group, id).Error
+	if err != nil {
+		return nil, err
+	}
+	status := managedGroupStatus(group)
+	return &status, nil
+}
+
+// managedGroupStatus 检查组内每条规则是否存在于系统中
+func managedGroupStatus(group models.ManagedRuleGroup) ManagedGroupStatus {
+	status := ManagedGroupStatus{ManagedRuleGroup: group, Rules: []ManagedRuleStatus{}}
+	for _, rule := range group.Rules {
+		args := managedRuleArgs(group.Name, rule)
+		present := managedRuleExists(rule.Table, rule.Chain, args)
+		status.Rules = append(status.Rules, ManagedRuleStatus{
+			ManagedRule: rule,
+			Command:     managedRuleCommand("-A", rule.Table, rule.Chain, 0, args),
+			Present:     present,
+		})
+		if !present && group.Enabled {
+			status.Missing++
+		}
+	}
+	status.ManagedRuleGroup.Rules = nil
+	return status
+}
+
+// SaveGroup 按名称创建或替换托管规则组，并立即应用到系统
+func (s *DockerUserService) SaveGroup(group *models.ManagedRuleGroup) (*ReconcileResult, error) {
+	for i := range group.Rules {
+		rule := &group.Rules[i]
+		rule.Position = i + 1
+		if rule.Table == "" {
+			rule.Table = "filter"
+		}
+		if rule.Chain == "" {
+			rule.Chain = DockerUserChain
+		}
+		if strings.TrimSpace(rule.Spec) == "" {
+			return nil, fmt.Errorf("rule %d of group %s has empty spec", i+1, group.Name)
+		}
+	}
+
+	var existing models.ManagedRuleGroup
+	err := config.DB.Preload("Rules").Where("name = ?", group.Name).First(&existing).Error
+	if err == nil {
+		// 先从系统中移除旧版本的规则，避免残留
+		s.removeGroupRules(existing)
+		group.ID = existing.ID
+		group.CreatedAt = existing.CreatedAt
+	} else if err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to load managed rule group: %v", err)
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if group.ID != 0 {
+			if err := tx.Where("group_id = ?", group.ID).Delete(&models.ManagedRule{}).Error; err != nil {
+				return err
+			}
+		}
+		rules := group.Rules
+		group.Rules = nil
+		if err := tx.Save(group).Error; err != nil {
+			return err
+		}
+		for i := range rules {
+			rules[i].ID = 0
+			rules[i].GroupID = group.ID
+		}
+		if len(rules) > 0 {
+			if err := tx.Create(&rules).Error; err != nil {
+				return err
+			}
+		}
+		group.Rules = rules
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save managed rule group: %v", err)
+	}
+
+	return s.Reconcile("group_saved:" + group.Name)
+}
+
+// groupExists 指定名称的托管规则组是否已存在
+func (s *DockerUserService) groupExists(name string) bool {
+	var count int64
+	config.DB.Model(&models.ManagedRuleGroup{}).Where("name = ?", name).Count(&count)
+	return count > 0
+}
+
+// DeleteGroup 删除托管规则组，并从系统中移除其规则
+func (s *DockerUserService) DeleteGroup(id uint) (*models.ManagedRuleGroup, error) {
+	var group models.ManagedRuleGroup
+	if err := config.DB.Preload("Rules").First(&group, id).Error; err != nil {
+		return nil, err
+	}
+
+	s.removeGroupRules(group)
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", group.ID).Delete(&models.ManagedRule{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&group).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete managed rule group: %v", err)
+	}
+	return &group, nil
+}
+
+// deleteGroupByName 按名称删除托管规则组（同时从系统中移除规则）；组不存在时忽略
+func (s *DockerUserService) deleteGroupByName(name string) error {
+	var group models.ManagedRuleGroup
+	err := config.DB.Where("name = ?", name).First(&group).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load managed rule group %s: %v", name, err)
+	}
+	_, err = s.DeleteGroup(group.ID)
+	return err
+}
+
+// removeGroupRules 从系统中删除组内的规则（规则不存在时忽略）
+func (s *DockerUserService) removeGroupRules(group models.ManagedRuleGroup) {
+	managedRulesMutex.Lock()
+	defer managedRulesMutex.Unlock()
+
+	for _, rule := range group.Rules {
+		args := managedRuleArgs(group.Name, rule)
+		for managedRuleExists(rule.Table, rule.Chain, args) {
+			cmdArgs := append([]string{"-t", rule.Table, "-D", rule.Chain}, args...)
+			if _, err := runCommand("iptables", cmdArgs...); err != nil {
+				log.Printf("[WARN] Failed to remove managed rule %s: %v", managedRuleCommand("-D", rule.Table, rule.Chain, 0, args), err)
+				break
+			}
+		}
+	}
+}
+
+// Reconcile 检查所有启用的托管规则，重新插入缺失的规则并记录审计日志
+func (s *DockerUserService) Reconcile(trigger string) (*ReconcileResult, error) {
+	managedRulesMutex.Lock()
+	defer managedRulesMutex.Unlock()
+
+	result := &ReconcileResult{Trigger: trigger, Reapplied: []string{}}
+	now := time.Now()
+	defer func() {
+		s.mutex.Lock()
+		s.state.LastCheckAt = &now
+		if len(result.Errors) > 0 {
+			s.state.LastError = strings.Join(result.Errors, "; ")
+		} else {
+			s.state.LastError = ""
+		}
+		if len(result.Reapplied) > 0 {
+			s.state.LastReapplyAt = &now
+			s.state.ReapplyCount++
+		}
+		s.mutex.Unlock()
+	}()
+
+	var groups []models.ManagedRuleGroup
+	err := config.DB.Preload("Rules", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC")
+	}).Where("enabled = ?", true).Order("id ASC").Find(&groups).Error
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return result, fmt.Errorf("failed to load managed rule groups: %v", err)
+	}
+
+	entries := planManagedRules(groups)
+	if len(entries) == 0 {
+		return result, nil
+	}
+
+	for _, entry := range entries {
+		if entry.rule.Table == "filter" && entry.rule.Chain == DockerUserChain {
+			if err := ensureDockerUserChain(); err != nil {
+				result.Errors = append(result.Errors, err.Error())
+				return result, err
+			}
+			break
+		}
+	}
+
+	appliedGroups := make(map[uint]bool)
+	for _, entry := range entries {
+		result.Checked++
+		if managedRuleExists(entry.rule.Table, entry.rule.Chain, entry.args) {
+			continue
+		}
+
+		command := managedRuleCommand("-I", entry.rule.Table, entry.rule.Chain, entry.position, entry.args)
+		cmdArgs := append([]string{"-t", entry.rule.Table, "-I", entry.rule.Chain, fmt.Sprintf("%d", entry.position)}, entry.args...)
+		if _, err := runCommand("iptables", cmdArgs...); err != nil {
+			// 链中规则不足时按位置插入会失败，退回到追加
+			cmdArgs = append([]string{"-t", entry.rule.Table, "-A", entry.rule.Chain}, entry.args...)
+			if _, appendErr := runCommand("iptables", cmdArgs...); appendErr != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", command, err))
+				continue
+			}
+			command = managedRuleCommand("-A", entry.rule.Table, entry.rule.Chain, 0, entry.args)
+		}
+		result.Reapplied = append(result.Reapplied, command)
+		appliedGroups[entry.groupID] = true
+	}
+
+	if len(result.Reapplied) > 0 {
+		ids := make([]uint, 0, len(appliedGroups))
+		for id := range appliedGroups {
+			ids = append(ids, id)
+		}
+		config.DB.Model(&models.ManagedRuleGroup{}).Where("id IN ?", ids).Update("last_applied_at", now)
+
+		log.Printf("[INFO] Reapplied %d managed rules (trigger: %s)", len(result.Reapplied), trigger)
+		details := fmt.Sprintf("触发: %s，应用 %d 条缺失的托管规则:\n%s", trigger, len(result.Reapplied), strings.Join(result.Reapplied, "\n"))
+		operation := "重新应用托管规则"
+		if strings.HasPrefix(trigger, "group_saved:") {
+			operation = "应用托管规则"
+		}
+		if err := s.logService.LogOperation("system", operation, details, ""); err != nil {
+			log.Printf("[WARN] Failed to write audit log for managed rules: %v", err)
+		}
+	}
+
+	return result, nil
+}
+
+// FixConnectivity 以托管规则组的方式在 DOCKER-USER 链中放行隧道与网桥间的流量（生成计划后立即执行）
+// DOCKER-USER 位于 FORWARD 链首且先于Docker隔离链，Docker重启不会改写它
+func (s *DockerUserService) FixConnectivity(tunnelInterface, dockerBridge, username string) (*models.ConnectivityFixResult, error) {
+	log.Printf("[DEBUG] DockerUserService.FixConnectivity called with tunnel: %s, bridge: %s", tunnelInterface, dockerBridge)
+
+	plan, err := s.PlanConnectivityFix(tunnelInterface, dockerBridge, username)
+	if err != nil {
+		return &models.ConnectivityFixResult{
+			TunnelInterface: tunnelInterface,
+			DockerBridge:    dockerBridge,
+			FixedIssues:     []string{},
+			AppliedRules:    []string{},
+		}, err
+	}
+
+	result := runConnectivityPlan(plan)
+	recordConnectivityChangeSet(plan, result, username)
+	if step := plan.Steps[0]; step.Status == "failed" {
+		return result, fmt.Errorf("failed to apply managed rules: %s", step.Error)
+	}
+	return result, nil
+}
+
+// connectivityGroup 隧道与网桥连通性的托管规则组
+func connectivityGroup(tunnelInterface, dockerBridge, username string) *models.ManagedRuleGroup {
+	return &models.ManagedRuleGroup{
+		Name:            fmt.Sprintf("fix-connectivity:%s:%s", tunnelInterface, dockerBridge),
+		Description:     fmt.Sprintf("隧道 %s 与Docker网桥 %s 的持久连通性规则", tunnelInterface, dockerBridge),
+		Source:          "fix_connectivity",
+		TunnelInterface: tunnelInterface,
+		DockerBridge:    dockerBridge,
+		Enabled:         true,
+		CreatedBy:       username,
+		Rules: []models.ManagedRule{
+			{Spec: fmt.Sprintf("-i %s -o %s -j ACCEPT", tunnelInterface, dockerBridge)},
+			{Spec: fmt.Sprintf("-i %s -o %s -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT", dockerBridge, tunnelInterface)},
+		},
+	}
+}
+
+// planManagedRules 按表和链展开托管规则并计算期望位置
+func planManagedRules(groups []models.ManagedRuleGroup) []managedRuleEntry {
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+
+	var entries []managedRuleEntry
+	positions := make(map[string]int)
+	for _, group := range groups {
+		rules := append([]models.ManagedRule{}, group.Rules...)
+		sort.SliceStable(rules, func(i, j int) bool { return rules[i].Position < rules[j].Position })
+		for _, rule := range rules {
+			chainKey := rule.Table + "/" + rule.Chain
+			positions[chainKey]++
+			entries = append(entries, managedRuleEntry{
+				group:    group.Name,
+				groupID:  group.ID,
+				rule:     rule,
+				args:     managedRuleArgs(group.Name, rule),
+				position: positions[chainKey],
+			})
+		}
+	}
+	return entries
+}
+
+// managedRuleArgs 规则参数加上托管注释，注释放在目标之前
+func managedRuleArgs(groupName string, rule models.ManagedRule) []string {
+	args := SplitRuleArgs(rule.Spec)
+	comment := []string{"-m", "comment", "--comment", managedCommentPrefix + groupName}
+
+	for i, arg := range args {
+		if arg == "-j" || arg == "--jump" || arg == "-g" || arg == "--goto" {
+			withComment := append([]string{}, args[:i]...)
+			withComment = append(withComment, comment...)
+			return append(withComment, args[i:]...)
+		}
+	}
+	return append(args, comment...)
+}
+
+// managedRuleCommand 生成便于阅读和审计的iptables命令
+func managedRuleCommand(action, table, chain string, position int, args []string) string {
+	command := fmt.Sprintf("iptables -t %s %s %s", table, action, chain)
+	if position > 0 {
+		command += fmt.Sprintf(" %d", position)
+	}
+	return command + " " + JoinRuleArgs(args)
+}
+
+// managedRuleExists 使用 iptables -C 检查规则是否存在
+func managedRuleExists(table, chain string, args []string) bool {
+	cmdArgs := append([]string{"-t", table, "-C", chain}, args...)
+	_, err := runCommand("iptables", cmdArgs...)
+	return err == nil
+}
+
+// ensureDockerUserChain 确保 DOCKER-USER 链存在且 FORWARD 链跳转到它（Docker未运行时自行创建）
+func ensureDockerUserChain() error {
+	if _, err := runCommand("iptables", "-t", "filter", "-n", "-L", DockerUserChain); err != nil {
+		if _, err := runCommand("iptables", "-t", "filter", "-N", DockerUserChain); err != nil {
+			return fmt.Errorf("failed to create %s chain: %v", DockerUserChain, err)
+		}
+		log.Printf("[INFO] Created %s chain", DockerUserChain)
+	}
+	if _, err := runCommand("iptables", "-t", "filter", "-C", "FORWARD", "-j", DockerUserChain); err != nil {
+		if _, err := runCommand("iptables", "-t", "filter", "-I", "FORWARD", "1", "-j", DockerUserChain); err != nil {
+			return fmt.Errorf("failed to jump from FORWARD to %s: %v", DockerUserChain, err)
+		}
+		log.Printf("[INFO] Inserted FORWARD jump to %s", DockerUserChain)
+	}
+	return nil
+}
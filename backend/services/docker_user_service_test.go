@@ -0,0 +1,70 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"iptables-management-backend/models"
+)
+
+func TestManagedRuleArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		want []string
+	}{
+		{
+			name: "comment before jump",
+			spec: "-i tun0 -o docker0 -j ACCEPT",
+			want: []string{"-i", "tun0", "-o", "docker0", "-m", "comment", "--comment", "iptables-manager:g1", "-j", "ACCEPT"},
+		},
+		{
+			name: "no target",
+			spec: "-i tun0",
+			want: []string{"-i", "tun0", "-m", "comment", "--comment", "iptables-manager:g1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := managedRuleArgs("g1", models.ManagedRule{Spec: tt.spec})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("managedRuleArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanManagedRules(t *testing.T) {
+	groups := []models.ManagedRuleGroup{
+		{ID: 2, Name: "second", Rules: []models.ManagedRule{
+			{Position: 1, Table: "filter", Chain: DockerUserChain, Spec: "-i wg0 -j ACCEPT"},
+		}},
+		{ID: 1, Name: "first", Rules: []models.ManagedRule{
+			{Position: 2, Table: "filter", Chain: DockerUserChain, Spec: "-i tun0 -o docker0 -j ACCEPT"},
+			{Position: 1, Table: "nat", Chain: "POSTROUTING", Spec: "-o tun0 -j MASQUERADE"},
+			{Position: 3, Table: "filter", Chain: DockerUserChain, Spec: "-i docker0 -o tun0 -j ACCEPT"},
+		}},
+	}
+
+	entries := planManagedRules(groups)
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+
+	expected := []struct {
+		group    string
+		chain    string
+		position int
+	}{
+		{"first", "POSTROUTING", 1},
+		{"first", DockerUserChain, 1},
+		{"first", DockerUserChain, 2},
+		{"second", DockerUserChain, 3},
+	}
+	for i, want := range expected {
+		got := entries[i]
+		if got.group != want.group || got.rule.Chain != want.chain || got.position != want.position {
+			t.Errorf("entry %d: got group=%s chain=%s position=%d, want %+v", i, got.group, got.rule.Chain, got.position, want)
+		}
+	}
+}
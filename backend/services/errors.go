@@ -0,0 +1,45 @@
+package services
+
+import "fmt"
+
+// ValidationError 请求参数不合法（处理器返回400）
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// NotFoundError 请求的对象不存在（处理器返回404）
+type NotFoundError struct {
+	Message string
+}
+
+func (e *NotFoundError) Error() string {
+	return e.Message
+}
+
+// ConflictError 请求与当前状态冲突，如对象已存在或仍被引用（处理器返回409）
+type ConflictError struct {
+	Message string
+}
+
+func (e *ConflictError) Error() string {
+	return e.Message
+}
+
+// invalidf 构造参数校验错误
+func invalidf(format string, args ...interface{}) error {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
+
+// notFoundf 构造对象不存在错误
+func notFoundf(format string, args ...interface{}) error {
+	return &NotFoundError{Message: fmt.Sprintf(format, args...)}
+}
+
+// conflictf 构造状态冲突错误
+func conflictf(format string, args ...interface{}) error {
+	return &ConflictError{Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	EventTypeRuleCounter   = "rule_counter"
+	EventTypeInterfaceRate = "interface_rate"
+	EventTypeRulesetChange = "ruleset_change"
+	EventTypeOperationLog  = "operation_log"
+)
+
+// StreamEvent 推送给订阅者的实时事件
+type StreamEvent struct {
+	Type      string      `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Table     string      `json:"table,omitempty"`
+	Chain     string      `json:"chain,omitempty"`
+	Interface []string    `json:"interface,omitempty"` // 事件涉及的接口（规则的入/出接口）
+	Data      interface{} `json:"data"`
+}
+
+// EventFilter 订阅过滤条件，空字段表示不过滤
+type EventFilter struct {
+	Types     []string `json:"types,omitempty"`
+	Table     string   `json:"table,omitempty"`
+	Chain     string   `json:"chain,omitempty"`
+	Interface string   `json:"interface,omitempty"`
+}
+
+// Matches 判断事件是否满足过滤条件
+func (f EventFilter) Matches(event StreamEvent) bool {
+	if len(f.Types) > 0 {
+		matched := false
+		for _, eventType := range f.Types {
+			if eventType == event.Type {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	// 操作日志不属于任何表、链或接口，不受这些条件限制
+	if event.Type == EventTypeOperationLog {
+		return true
+	}
+
+	// 表和链条件只作用于规则相关事件
+	if event.Type != EventTypeInterfaceRate {
+		if f.Table != "" && event.Table != f.Table {
+			return false
+		}
+		if f.Chain != "" && event.Chain != f.Chain {
+			return false
+		}
+	}
+
+	if f.Interface != "" {
+		for _, name := range event.Interface {
+			if strings.TrimPrefix(name, "!") == f.Interface {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// EventSubscription 一个订阅者
+type EventSubscription struct {
+	Events  chan StreamEvent
+	filter  EventFilter
+	dropped int64 // 因缓冲区满而丢弃的事件数
+}
+
+// Dropped 获取因订阅者处理过慢而丢弃的事件数
+func (s *EventSubscription) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// EventHub 事件分发中心，慢订阅者的事件会被丢弃而不阻塞发布者
+type EventHub struct {
+	mutex       sync.RWMutex
+	subscribers map[*EventSubscription]struct{}
+}
+
+// NewEventHub 创建事件分发中心
+func NewEventHub() *EventHub {
+	return &EventHub{
+		subscribers: make(map[*EventSubscription]struct{}),
+	}
+}
+
+// DefaultEventHub 全局事件分发中心，供各服务发布事件
+var DefaultEventHub = NewEventHub()
+
+// Subscribe 订阅事件
+func (h *EventHub) Subscribe(filter EventFilter) *EventSubscription {
+	sub := &EventSubscription{
+		Events: make(chan StreamEvent, 256),
+		filter: filter,
+	}
+
+	h.mutex.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.mutex.Unlock()
+	return sub
+}
+
+// Unsubscribe 取消订阅
+func (h *EventHub) Unsubscribe(sub *EventSubscription) {
+	h.mutex.Lock()
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.Events)
+	}
+	h.mutex.Unlock()
+}
+
+// SubscriberCount 当前订阅者数量
+func (h *EventHub) SubscriberCount() int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return len(h.subscribers)
+}
+
+// Publish 发布事件给所有匹配的订阅者
+func (h *EventHub) Publish(event StreamEvent) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	for sub := range h.subscribers {
+		if !sub.filter.Matches(event) {
+			continue
+		}
+		select {
+		case sub.Events <- event:
+		default:
+			atomic.AddInt64(&sub.dropped, 1)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"testing"
+)
+
+func TestEventFilterMatches(t *testing.T) {
+	ruleEvent := StreamEvent{Type: EventTypeRuleCounter, Table: "filter", Chain: "FORWARD", Interface: []string{"!docker0", "tun0"}}
+	rateEvent := StreamEvent{Type: EventTypeInterfaceRate, Interface: []string{"eth0"}}
+	logEvent := StreamEvent{Type: EventTypeOperationLog}
+
+	testCases := []struct {
+		name     string
+		filter   EventFilter
+		event    StreamEvent
+		expected bool
+	}{
+		{"empty filter", EventFilter{}, ruleEvent, true},
+		{"type match", EventFilter{Types: []string{EventTypeRuleCounter}}, ruleEvent, true},
+		{"type mismatch", EventFilter{Types: []string{EventTypeInterfaceRate}}, ruleEvent, false},
+		{"table match", EventFilter{Table: "filter"}, ruleEvent, true},
+		{"table mismatch", EventFilter{Table: "nat"}, ruleEvent, false},
+		{"chain mismatch", EventFilter{Chain: "INPUT"}, ruleEvent, false},
+		{"negated interface match", EventFilter{Interface: "docker0"}, ruleEvent, true},
+		{"interface mismatch", EventFilter{Interface: "eth0"}, ruleEvent, false},
+		{"table filter ignored for interface rates", EventFilter{Table: "nat"}, rateEvent, true},
+		{"interface rate match", EventFilter{Interface: "eth0"}, rateEvent, true},
+		{"operation log ignores scope", EventFilter{Table: "nat", Interface: "eth0"}, logEvent, true},
+		{"operation log type mismatch", EventFilter{Types: []string{EventTypeRuleCounter}}, logEvent, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := tc.filter.Matches(tc.event); result != tc.expected {
+				t.Errorf("Matches() = %v, expected %v", result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestEventHubDropsForSlowSubscriber(t *testing.T) {
+	hub := NewEventHub()
+	sub := hub.Subscribe(EventFilter{})
+	defer hub.Unsubscribe(sub)
+
+	total := cap(sub.Events) + 10
+	for i := 0; i < total; i++ {
+		hub.Publish(StreamEvent{Type: EventTypeRuleCounter})
+	}
+
+	if len(sub.Events) != cap(sub.Events) {
+		t.Errorf("expected buffer to be full, got %d/%d", len(sub.Events), cap(sub.Events))
+	}
+	if sub.Dropped() != 10 {
+		t.Errorf("expected 10 dropped events, got %d", sub.Dropped())
+	}
+}
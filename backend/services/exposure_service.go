@@ -0,0 +1,390 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"iptables-management-backend/models"
+)
+
+// 暴露状态
+const (
+	ExposureReachable = "reachable" // 直接访问监听端口可达
+	ExposureNATOnly   = "nat_only"  // 只能通过DNAT/REDIRECT的入口端口访问
+	ExposureBlocked   = "blocked"
+)
+
+// ExposureService 监听服务暴露面分析服务
+type ExposureService struct {
+	networkService *NetworkService
+}
+
+// ExposureFilter 暴露面分析条件
+type ExposureFilter struct {
+	Interface string
+	Kind      string // external, tunnel, docker
+	Protocol  string // tcp, udp
+	Port      int
+	Source    string // 模拟的来源地址，为空时带 -s 条件的规则视为无法判断
+}
+
+// ExposureInterface 参与分析的入口接口
+type ExposureInterface struct {
+	Name    string `json:"name"`
+	Kind    string `json:"kind"` // external, tunnel, docker
+	Address string `json:"address"`
+}
+
+// ListeningService 去重后的监听服务（同一协议、地址、端口的多个套接字合并）
+type ListeningService struct {
+	Protocol string `json:"protocol"` // tcp, udp
+	Address  string `json:"address"`  // 0.0.0.0 表示所有地址
+	Port     int    `json:"port"`
+	PID      int    `json:"pid,omitempty"`
+	Process  string `json:"process,omitempty"`
+}
+
+// NATEntry 通过DNAT/REDIRECT到达服务的入口
+type NATEntry struct {
+	Port         int      `json:"port"` // 外部访问的端口
+	Status       string   `json:"status"`
+	NATRule      SimStep  `json:"nat_rule"`
+	DecidingRule *SimStep `json:"deciding_rule"`
+}
+
+// InterfaceExposure 服务在某个入口接口上的可达性
+type InterfaceExposure struct {
+	Interface    string     `json:"interface"`
+	Kind         string     `json:"kind"`
+	Status       string     `json:"status"`
+	Reason       string     `json:"reason"`
+	DecidingRule *SimStep   `json:"deciding_rule,omitempty"`
+	NATEntries   []NATEntry `json:"nat_entries,omitempty"`
+	Uncertain    []SimStep  `json:"uncertain,omitempty"` // 条件无法判断的规则，实际结果可能不同
+}
+
+// ServiceExposure 单个监听服务的暴露情况
+type ServiceExposure struct {
+	ListeningService
+	Interfaces []InterfaceExposure `json:"interfaces"`
+}
+
+// ExposureReport 暴露面报告
+type ExposureReport struct {
+	GeneratedAt time.Time           `json:"generated_at"`
+	Source      string              `json:"source,omitempty"`
+	Interfaces  []ExposureInterface `json:"interfaces"`
+	Services    []ServiceExposure   `json:"services"`
+	Summary     map[string]int      `json:"summary"` // 各状态的 服务×接口 数量
+}
+
+// NewExposureService 创建暴露面分析服务实例
+func NewExposureService(networkService *NetworkService) *ExposureService {
+	return &ExposureService{networkService: networkService}
+}
+
+// GetReport 结合宿主机监听套接字和规则集，分析每个服务从各接口是否可达
+func (s *ExposureService) GetReport(filter ExposureFilter) (*ExposureReport, error) {
+	log.Printf("[DEBUG] ExposureService.GetReport called with filter: %+v", filter)
+
+	if filter.Source != "" && net.ParseIP(filter.Source) == nil {
+		return nil, invalidf("invalid source address: %s", filter.Source)
+	}
+
+	connections, err := s.networkService.GetNetworkConnections(ConnectionFilter{
+		Protocol:  filter.Protocol,
+		Listening: true,
+		NetNS:     "host",
+	})
+	if err != nil {
+		return nil, err
+	}
+	interfaces, err := s.networkService.GetAllInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	ruleset, err := LoadSavedRuleset()
+	if err != nil {
+		return nil, err
+	}
+
+	var localAddrs []string
+	for _, iface := range interfaces {
+		localAddrs = append(localAddrs, iface.IPAddresses...)
+	}
+	entries := s.exposureInterfaces(interfaces)
+	var selected []ExposureInterface
+	for _, entry := range entries {
+		if (filter.Interface == "" || filter.Interface == entry.Name) && (filter.Kind == "" || filter.Kind == entry.Kind) {
+			selected = append(selected, entry)
+		}
+	}
+	if filter.Interface != "" && len(selected) == 0 {
+		return nil, invalidf("interface %s not found or has no IPv4 address", filter.Interface)
+	}
+
+	listening := groupListeningServices(connections)
+	if filter.Port != 0 {
+		var byPort []ListeningService
+		for _, service := range listening {
+			if service.Port == filter.Port {
+				byPort = append(byPort, service)
+			}
+		}
+		listening = byPort
+	}
+
+	report := AnalyzeExposure(NewPacketSimulator(ruleset, localAddrs), listening, selected, filter.Source)
+	log.Printf("[DEBUG] Exposure report: %d services, %d interfaces, summary %v", len(report.Services), len(report.Interfaces), report.Summary)
+	return report, nil
+}
+
+// exposureInterfaces 选出作为入口的接口（排除回环、veth、未启用和没有IPv4地址的接口）
+func (s *ExposureService) exposureInterfaces(interfaces []models.NetworkInterface) []ExposureInterface {
+	var result []ExposureInterface
+	for _, iface := range interfaces {
+		interfaceType := iface.Type
+		if !iface.IsUp || interfaceType == "loopback" || interfaceType == "veth" {
+			continue
+		}
+
+		address := ""
+		for _, cidr := range iface.IPAddresses {
+			if ip := net.ParseIP(strings.SplitN(cidr, "/", 2)[0]); ip != nil && ip.To4() != nil {
+				address = ip.String()
+				break
+			}
+		}
+		if address == "" {
+			continue
+		}
+
+		kind := "external"
+		if iface.IsDocker {
+			kind = "docker"
+		} else if interfaceType == "tunnel" {
+			kind = "tunnel"
+		}
+		result = append(result, ExposureInterface{Name: iface.Name, Kind: kind, Address: address})
+	}
+	return result
+}
+
+// groupListeningServices 将监听套接字合并为服务；IPv6通配地址按双栈处理，其余IPv6地址不在iptables规则范围内
+func groupListeningServices(connections []models.NetworkConnection) []ListeningService {
+	seen := make(map[string]bool)
+	var services []ListeningService
+	for _, connection := range connections {
+		protocol := strings.TrimSuffix(connection.Protocol, "6")
+		if protocol != "tcp" && protocol != "udp" {
+			continue
+		}
+		address := connection.LocalIP
+		if address == "::" {
+			address = "0.0.0.0"
+		}
+		if ip := net.ParseIP(address); ip == nil || ip.To4() == nil {
+			continue
+		}
+
+		key := protocol + "|" + address + "|" + strconv.Itoa(connection.LocalPort)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		services = append(services, ListeningService{
+			Protocol: protocol,
+			Address:  address,
+			Port:     connection.LocalPort,
+			PID:      connection.PID,
+			Process:  connection.Process,
+		})
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Port != services[j].Port {
+			return services[i].Port < services[j].Port
+		}
+		if services[i].Protocol != services[j].Protocol {
+			return services[i].Protocol < services[j].Protocol
+		}
+		return services[i].Address < services[j].Address
+	})
+	return services
+}
+
+// AnalyzeExposure 对每个服务和入口接口模拟 PREROUTING/INPUT 路径
+func AnalyzeExposure(simulator *PacketSimulator, services []ListeningService, interfaces []ExposureInterface, source string) *ExposureReport {
+	report := &ExposureReport{
+		GeneratedAt: time.Now(),
+		Source:      source,
+		Interfaces:  interfaces,
+		Services:    []ServiceExposure{},
+		Summary:     map[string]int{ExposureReachable: 0, ExposureNATOnly: 0, ExposureBlocked: 0},
+	}
+	if report.Interfaces == nil {
+		report.Interfaces = []ExposureInterface{}
+	}
+
+	natRules := dnatRules(simulator.ruleset)
+	for _, service := range services {
+		exposure := ServiceExposure{ListeningService: service, Interfaces: []InterfaceExposure{}}
+		for _, iface := range interfaces {
+			result := simulator.analyzeInterfaceExposure(service, iface, source, natRules)
+			report.Summary[result.Status]++
+			exposure.Interfaces = append(exposure.Interfaces, result)
+		}
+		report.Services = append(report.Services, exposure)
+	}
+	return report
+}
+
+// analyzeInterfaceExposure 分析服务从指定接口是否可达：先模拟直接访问，再模拟指向该服务的DNAT/REDIRECT入口
+func (s *PacketSimulator) analyzeInterfaceExposure(service ListeningService, iface ExposureInterface, source string, natRules []SavedRule) InterfaceExposure {
+	result := InterfaceExposure{Interface: iface.Name, Kind: iface.Kind, Status: ExposureBlocked}
+
+	if ip := net.ParseIP(service.Address); ip != nil && ip.IsLoopback() {
+		result.Reason = "仅监听回环地址"
+		return result
+	}
+	destination := service.Address
+	if destination == "0.0.0.0" {
+		destination = iface.Address
+	}
+
+	direct := s.SimulateInput(SimPacket{
+		InInterface: iface.Name,
+		Protocol:    service.Protocol,
+		Source:      source,
+		Destination: destination,
+		DestPort:    service.Port,
+		CtState:     "NEW",
+	})
+	result.Uncertain = append(result.Uncertain, direct.Uncertain...)
+	result.DecidingRule = direct.Rule
+
+	switch {
+	case direct.Verdict == "ACCEPT" && reachesService(direct.Packet, service):
+		result.Status = ExposureReachable
+		result.Reason = "直接访问 " + net.JoinHostPort(destination, strconv.Itoa(service.Port)) + " 被接受"
+	case direct.Verdict == "ACCEPT" || direct.Verdict == "FORWARD":
+		result.DecidingRule = direct.NAT
+		result.Reason = "直接访问被NAT改写到 " + net.JoinHostPort(direct.Packet.Destination, strconv.Itoa(direct.Packet.DestPort))
+	default:
+		result.Reason = "直接访问被 " + direct.Verdict
+	}
+
+	for _, rule := range natRules {
+		entry, ok := s.simulateNATEntry(rule, service, iface, source)
+		if !ok {
+			continue
+		}
+		result.Uncertain = append(result.Uncertain, entry.uncertain...)
+		result.NATEntries = append(result.NATEntries, entry.NATEntry)
+		if entry.Status == ExposureReachable && result.Status == ExposureBlocked {
+			result.Status = ExposureNATOnly
+			result.DecidingRule = entry.DecidingRule
+			result.Reason += fmt.Sprintf("；可通过端口 %d 经 %s 到达", entry.Port, entry.NATRule.Target)
+		}
+	}
+	return result
+}
+
+// natEntryResult NAT入口模拟结果
+type natEntryResult struct {
+	NATEntry
+	uncertain []SimStep
+}
+
+// simulateNATEntry 用NAT规则的入口端口构造数据包，确认它确实被改写到该服务后再判断是否被接受
+func (s *PacketSimulator) simulateNATEntry(rule SavedRule, service ListeningService, iface ExposureInterface, source string) (natEntryResult, bool) {
+	var result natEntryResult
+	if !natRuleTargetsService(rule, service, s) {
+		return result, false
+	}
+
+	port := 0
+	if dport := rule.Match.DestPort; dport != "" && !strings.HasPrefix(dport, "!") {
+		port = firstPort(strings.SplitN(dport, ",", 2)[0])
+	}
+	// 没有端口条件或入口端口与服务端口相同时，与直接访问等价
+	if port == 0 || port == service.Port {
+		return result, false
+	}
+
+	destination := iface.Address
+	if d := stripHostPrefix(rule.Match.Destination); d != "" && !strings.HasPrefix(d, "!") && net.ParseIP(d) != nil {
+		destination = d
+	}
+	path := s.SimulateInput(SimPacket{
+		InInterface: iface.Name,
+		Protocol:    service.Protocol,
+		Source:      source,
+		Destination: destination,
+		DestPort:    port,
+		CtState:     "NEW",
+	})
+	if path.NAT == nil || path.NAT.Key != rule.Key || !reachesService(path.Packet, service) {
+		// 该入口规则从此接口无法命中
+		return result, false
+	}
+
+	result.Port = port
+	result.NATRule = *path.NAT
+	result.DecidingRule = path.Rule
+	result.Status = ExposureBlocked
+	if path.Verdict == "ACCEPT" {
+		result.Status = ExposureReachable
+	}
+	result.uncertain = path.Uncertain
+	return result, true
+}
+
+// dnatRules nat表中所有 DNAT 和 REDIRECT 规则
+func dnatRules(ruleset *SavedRuleset) []SavedRule {
+	var rules []SavedRule
+	if table := ruleset.Table("nat"); table != nil {
+		for _, rule := range table.Rules {
+			if rule.Match.Target == "DNAT" || rule.Match.Target == "REDIRECT" {
+				rules = append(rules, rule)
+			}
+		}
+	}
+	return rules
+}
+
+// natRuleTargetsService 判断NAT规则改写后的目的地址是否为该服务
+func natRuleTargetsService(rule SavedRule, service ListeningService, simulator *PacketSimulator) bool {
+	if protocol := rule.Match.Protocol; protocol != "" && !strings.EqualFold(protocol, service.Protocol) {
+		return false
+	}
+
+	switch rule.Match.Target {
+	case "REDIRECT":
+		ports := strings.Replace(rule.Match.TargetOption("--to-ports"), "-", ":", 1)
+		return ports != "" && portInRange(service.Port, ports)
+	case "DNAT":
+		host, port, err := splitDNATDestination(rule.Match.TargetOption("--to-destination"))
+		if err != nil || port == "" || !portInRange(service.Port, port) {
+			return false
+		}
+		if service.Address == "0.0.0.0" {
+			return simulator.isLocal(host)
+		}
+		return host == service.Address
+	}
+	return false
+}
+
+// reachesService 判断（NAT后的）数据包是否发往该服务
+func reachesService(packet SimPacket, service ListeningService) bool {
+	if packet.DestPort != service.Port {
+		return false
+	}
+	return service.Address == "0.0.0.0" || packet.Destination == service.Address
+}
@@ -0,0 +1,477 @@
+package services
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"syscall"
+
+	"iptables-management-backend/models"
+)
+
+// IFLA_INFO_DATA 中各类型链路的属性（见 include/uapi/linux/if_link.h、if_tun.h）
+const (
+	iflaVLANID       = 1
+	iflaVLANProtocol = 5
+
+	iflaVXLANID     = 1
+	iflaVXLANGroup  = 2
+	iflaVXLANLink   = 3
+	iflaVXLANLocal  = 4
+	iflaVXLANPort   = 15
+	iflaVXLANGroup6 = 16
+	iflaVXLANLocal6 = 17
+
+	iflaTunOwner      = 1
+	iflaTunGroup      = 2
+	iflaTunType       = 3
+	iflaTunVnetHdr    = 5
+	iflaTunPersist    = 6
+	iflaTunMultiQueue = 7
+	iflaTunNumQueues  = 8
+
+	iflaGRELink   = 1
+	iflaGREOKey   = 5
+	iflaGRELocal  = 6
+	iflaGRERemote = 7
+	iflaGRETTL    = 8
+
+	iflaIPTunLink   = 1
+	iflaIPTunLocal  = 2
+	iflaIPTunRemote = 3
+	iflaIPTunTTL    = 4
+
+	iflaVTILink   = 1
+	iflaVTIOKey   = 3
+	iflaVTILocal  = 4
+	iflaVTIRemote = 5
+
+	iflaGeneveID      = 1
+	iflaGeneveRemote  = 2
+	iflaGeneveTTL     = 3
+	iflaGenevePort    = 5
+	iflaGeneveRemote6 = 7
+
+	iflaXfrmLink = 1
+	iflaXfrmIfID = 2
+)
+
+// operStateNames IFLA_OPERSTATE 取值（RFC 2863）
+var operStateNames = map[uint8]string{
+	0: "UNKNOWN",
+	1: "NOTPRESENT",
+	2: "DOWN",
+	3: "LOWERLAYERDOWN",
+	4: "TESTING",
+	5: "DORMANT",
+	6: "UP",
+}
+
+// tunnelKinds 归类为隧道接口的内核链路类型
+var tunnelKinds = map[string]bool{
+	"tun":       true,
+	"wireguard": true,
+	"gre":       true,
+	"gretap":    true,
+	"ip6gre":    true,
+	"ip6gretap": true,
+	"erspan":    true,
+	"ip6erspan": true,
+	"ipip":      true,
+	"sit":       true,
+	"ip6tnl":    true,
+	"vti":       true,
+	"vti6":      true,
+	"xfrm":      true,
+	"vxlan":     true,
+	"geneve":    true,
+}
+
+// interfaceInventory 一次 RTM_GETLINK/RTM_GETADDR 转储得到的接口清单
+type interfaceInventory struct {
+	links   []netlinkLink
+	byIndex map[int]*netlinkLink
+	byName  map[string]*netlinkLink
+	addrs   map[int][]netlinkAddr
+}
+
+// loadInterfaceInventory 从当前网络命名空间加载接口清单
+func loadInterfaceInventory() (*interfaceInventory, error) {
+	links, err := listNetlinkLinks()
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := listNetlinkAddrs()
+	if err != nil {
+		return nil, err
+	}
+	return newInterfaceInventory(links, addrs), nil
+}
+
+// newInterfaceInventory 按ifindex和名称索引链路与地址
+func newInterfaceInventory(links []netlinkLink, addrs []netlinkAddr) *interfaceInventory {
+	sort.Slice(links, func(i, j int) bool { return links[i].Index < links[j].Index })
+
+	inventory := &interfaceInventory{
+		links:   links,
+		byIndex: make(map[int]*netlinkLink, len(links)),
+		byName:  make(map[string]*netlinkLink, len(links)),
+		addrs:   make(map[int][]netlinkAddr),
+	}
+	for i := range inventory.links {
+		link := &inventory.links[i]
+		inventory.byIndex[link.Index] = link
+		inventory.byName[link.Name] = link
+	}
+	for _, addr := range addrs {
+		inventory.addrs[addr.Index] = append(inventory.addrs[addr.Index], addr)
+	}
+	return inventory
+}
+
+// link 按名称查找链路
+func (inv *interfaceInventory) link(name string) (*netlinkLink, bool) {
+	link, ok := inv.byName[name]
+	return link, ok
+}
+
+// ipv4Addresses 接口的IPv4地址（不含前缀长度）
+func (inv *interfaceInventory) ipv4Addresses(name string) []string {
+	link, ok := inv.byName[name]
+	if !ok {
+		return nil
+	}
+	var ips []string
+	for _, addr := range inv.addrs[link.Index] {
+		if addr.Family == syscall.AF_INET {
+			ips = append(ips, addr.IP.String())
+		}
+	}
+	return ips
+}
+
+// peerAddress 点对点接口第一个带对端的IPv4地址，返回本地地址和对端地址
+func (inv *interfaceInventory) peerAddress(name string) (string, string) {
+	link, ok := inv.byName[name]
+	if !ok {
+		return "", ""
+	}
+	for _, addr := range inv.addrs[link.Index] {
+		if addr.Family == syscall.AF_INET && addr.Peer != nil {
+			return addr.IP.String(), addr.Peer.String()
+		}
+	}
+	return "", ""
+}
+
+// stats 接口的 IFLA_STATS64 计数器
+func (inv *interfaceInventory) stats(name string) (models.InterfaceStats, bool) {
+	link, ok := inv.byName[name]
+	if !ok || link.Stats == nil {
+		return models.InterfaceStats{}, false
+	}
+	return toInterfaceStats(link.Stats), true
+}
+
+// allStats 全部接口的计数器
+func (inv *interfaceInventory) allStats() map[string]models.InterfaceStats {
+	result := make(map[string]models.InterfaceStats, len(inv.links))
+	for _, link := range inv.links {
+		if link.Stats != nil {
+			result[link.Name] = toInterfaceStats(link.Stats)
+		}
+	}
+	return result
+}
+
+// buildInterface 由清单中的链路构造接口模型
+func (s *NetworkService) buildInterface(inv *interfaceInventory, link *netlinkLink) models.NetworkInterface {
+	netInterface := models.NetworkInterface{
+		Name:        link.Name,
+		Index:       link.Index,
+		Kind:        link.Kind,
+		MACAddress:  link.MAC,
+		MTU:         link.MTU,
+		TxQueueLen:  link.TxQueueLen,
+		IsUp:        link.IsUp(),
+		IsDocker:    s.isDockerInterface(link.Name),
+		OperState:   operStateNames[link.OperState],
+		IPAddresses: []string{},
+	}
+
+	netInterface.Type = interfaceTypeFromKind(link.Kind, link.Flags)
+	if netInterface.Type == "" {
+		netInterface.Type = s.getInterfaceType(link.Name)
+	}
+	if netInterface.IsDocker {
+		netInterface.DockerType = s.getDockerInterfaceType(link.Name)
+	}
+	if netInterface.IsUp {
+		netInterface.State = "UP"
+	} else {
+		netInterface.State = "DOWN"
+	}
+
+	if master, ok := inv.byIndex[link.Master]; ok && link.Master != 0 {
+		netInterface.Master = master.Name
+	}
+	if link.Link != 0 && link.Link != link.Index {
+		netInterface.ParentIndex = link.Link
+		if link.LinkNetNSID >= 0 {
+			nsid := link.LinkNetNSID
+			netInterface.LinkNetNSID = &nsid
+		} else if parent, ok := inv.byIndex[link.Link]; ok {
+			netInterface.Parent = parent.Name
+		}
+	}
+
+	for _, addr := range inv.addrs[link.Index] {
+		netInterface.IPAddresses = append(netInterface.IPAddresses, addr.CIDR())
+		if addr.Peer != nil && netInterface.PeerAddress == "" {
+			netInterface.PeerAddress = addr.Peer.String()
+		}
+	}
+	if link.Stats != nil {
+		netInterface.Statistics = toInterfaceStats(link.Stats)
+	}
+
+	switch link.Kind {
+	case "vlan":
+		netInterface.VLAN = parseVLANInfo(link.InfoData)
+	case "vxlan":
+		netInterface.VXLAN = parseVXLANInfo(link.InfoData, inv.byIndex)
+	case "tun":
+		netInterface.Tun = parseTunInfo(link.InfoData)
+	}
+	return netInterface
+}
+
+// interfaceTypeFromKind 根据内核链路类型确定接口类型，无法确定时返回空串由名称推断
+func interfaceTypeFromKind(kind string, flags uint32) string {
+	if flags&syscall.IFF_LOOPBACK != 0 {
+		return "loopback"
+	}
+	switch {
+	case kind == "bridge", kind == "veth", kind == "vlan", kind == "bond":
+		return kind
+	case tunnelKinds[kind]:
+		return "tunnel"
+	}
+	return ""
+}
+
+// toInterfaceStats 转换 IFLA_STATS64 计数器
+func toInterfaceStats(stats *netlinkStats) models.InterfaceStats {
+	return models.InterfaceStats{
+		RxBytes:   int64(stats.RxBytes),
+		TxBytes:   int64(stats.TxBytes),
+		RxPackets: int64(stats.RxPackets),
+		TxPackets: int64(stats.TxPackets),
+		RxErrors:  int64(stats.RxErrors),
+		TxErrors:  int64(stats.TxErrors),
+		RxDropped: int64(stats.RxDropped),
+		TxDropped: int64(stats.TxDropped),
+		Multicast: int64(stats.Multicast),
+	}
+}
+
+// parseVLANInfo 解析vlan类型的 IFLA_INFO_DATA
+func parseVLANInfo(data []byte) *models.VLANInfo {
+	attrs := parseNestedAttrs(data)
+	id, ok := attrs[iflaVLANID]
+	if !ok || len(id) < 2 {
+		return nil
+	}
+	info := &models.VLANInfo{
+		ID:       int(nativeEndian.Uint16(id)),
+		Protocol: "802.1Q",
+	}
+	// IFLA_VLAN_PROTOCOL 为网络字节序
+	if protocol := attrs[iflaVLANProtocol]; len(protocol) >= 2 && binary.BigEndian.Uint16(protocol) == 0x88a8 {
+		info.Protocol = "802.1ad"
+	}
+	return info
+}
+
+// parseVXLANInfo 解析vxlan类型的 IFLA_INFO_DATA
+func parseVXLANInfo(data []byte, links map[int]*netlinkLink) *models.VXLANInfo {
+	attrs := parseNestedAttrs(data)
+	id, ok := attrs[iflaVXLANID]
+	if !ok || len(id) < 4 {
+		return nil
+	}
+	info := &models.VXLANInfo{VNI: int(nativeEndian.Uint32(id))}
+
+	for _, attrType := range []uint16{iflaVXLANGroup, iflaVXLANGroup6} {
+		if value := attrs[attrType]; len(value) == net.IPv4len || len(value) == net.IPv6len {
+			info.Group = net.IP(value).String()
+		}
+	}
+	for _, attrType := range []uint16{iflaVXLANLocal, iflaVXLANLocal6} {
+		if value := attrs[attrType]; len(value) == net.IPv4len || len(value) == net.IPv6len {
+			info.Local = net.IP(value).String()
+		}
+	}
+	if value := attrs[iflaVXLANLink]; len(value) >= 4 {
+		index := int(nativeEndian.Uint32(value))
+		if link, ok := links[index]; ok {
+			info.Device = link.Name
+		} else if index != 0 {
+			info.Device = fmt.Sprintf("if%d", index)
+		}
+	}
+	// IFLA_VXLAN_PORT 为网络字节序
+	if value := attrs[iflaVXLANPort]; len(value) >= 2 {
+		info.DstPort = int(binary.BigEndian.Uint16(value))
+	}
+	return info
+}
+
+// parseTunInfo 解析tun类型的 IFLA_INFO_DATA
+func parseTunInfo(data []byte) *models.TunInfo {
+	attrs := parseNestedAttrs(data)
+	if len(attrs) == 0 {
+		return nil
+	}
+	flag := func(attrType uint16) bool {
+		value := attrs[attrType]
+		return len(value) >= 1 && value[0] != 0
+	}
+	id := func(attrType uint16) int {
+		value := attrs[attrType]
+		if len(value) < 4 {
+			return -1
+		}
+		return int(int32(nativeEndian.Uint32(value)))
+	}
+
+	info := &models.TunInfo{
+		Mode:       "tun",
+		Owner:      id(iflaTunOwner),
+		Group:      id(iflaTunGroup),
+		Persist:    flag(iflaTunPersist),
+		MultiQueue: flag(iflaTunMultiQueue),
+		VnetHdr:    flag(iflaTunVnetHdr),
+	}
+	// IFF_TUN = 1, IFF_TAP = 2
+	if value := attrs[iflaTunType]; len(value) >= 1 && value[0] == 2 {
+		info.Mode = "tap"
+	}
+	if value := attrs[iflaTunNumQueues]; len(value) >= 4 {
+		info.Queues = int(nativeEndian.Uint32(value))
+	}
+	return info
+}
+
+// tunnelEncryption 按内核链路类型判断隧道的加密方式
+func tunnelEncryption(kind string) string {
+	switch kind {
+	case "wireguard":
+		return "wireguard"
+	case "xfrm", "vti", "vti6":
+		return "ipsec"
+	case "tun":
+		// tun/tap 由用户态程序（OpenVPN 等）决定是否加密
+		return "unknown"
+	}
+	return "none"
+}
+
+// parseTunnelUnderlay 解析隧道类链路 IFLA_INFO_DATA 中的外层封装参数
+func parseTunnelUnderlay(kind string, data []byte, links map[int]*netlinkLink) *models.TunnelUnderlay {
+	attrs := parseNestedAttrs(data)
+	underlay := &models.TunnelUnderlay{}
+
+	address := func(attrTypes ...uint16) string {
+		for _, attrType := range attrTypes {
+			if value := attrs[attrType]; len(value) == net.IPv4len || len(value) == net.IPv6len {
+				if ip := net.IP(value); !ip.IsUnspecified() {
+					return ip.String()
+				}
+			}
+		}
+		return ""
+	}
+	device := func(attrType uint16) string {
+		value := attrs[attrType]
+		if len(value) < 4 {
+			return ""
+		}
+		index := int(nativeEndian.Uint32(value))
+		if link, ok := links[index]; ok {
+			return link.Name
+		} else if index != 0 {
+			return fmt.Sprintf("if%d", index)
+		}
+		return ""
+	}
+	u8 := func(attrType uint16) int {
+		if value := attrs[attrType]; len(value) >= 1 {
+			return int(value[0])
+		}
+		return 0
+	}
+	be32 := func(attrType uint16) uint32 {
+		if value := attrs[attrType]; len(value) >= 4 {
+			return binary.BigEndian.Uint32(value)
+		}
+		return 0
+	}
+
+	switch kind {
+	case "gre", "gretap", "ip6gre", "ip6gretap", "erspan", "ip6erspan":
+		underlay.Local = address(iflaGRELocal)
+		underlay.Remote = address(iflaGRERemote)
+		underlay.Key = be32(iflaGREOKey)
+		underlay.Device = device(iflaGRELink)
+		underlay.TTL = u8(iflaGRETTL)
+	case "ipip", "sit", "ip6tnl":
+		underlay.Local = address(iflaIPTunLocal)
+		underlay.Remote = address(iflaIPTunRemote)
+		underlay.Device = device(iflaIPTunLink)
+		underlay.TTL = u8(iflaIPTunTTL)
+	case "vti", "vti6":
+		underlay.Local = address(iflaVTILocal)
+		underlay.Remote = address(iflaVTIRemote)
+		underlay.Key = be32(iflaVTIOKey)
+		underlay.Device = device(iflaVTILink)
+	case "vxlan":
+		info := parseVXLANInfo(data, links)
+		if info == nil {
+			return nil
+		}
+		underlay.Local = info.Local
+		underlay.Remote = info.Group
+		underlay.Key = uint32(info.VNI)
+		underlay.Device = info.Device
+		underlay.Port = info.DstPort
+	case "geneve":
+		if value := attrs[iflaGeneveID]; len(value) >= 4 {
+			underlay.Key = nativeEndian.Uint32(value)
+		}
+		underlay.Remote = address(iflaGeneveRemote, iflaGeneveRemote6)
+		underlay.TTL = u8(iflaGeneveTTL)
+		if value := attrs[iflaGenevePort]; len(value) >= 2 {
+			underlay.Port = int(binary.BigEndian.Uint16(value))
+		}
+	case "xfrm":
+		// xfrm 接口本身没有端点，流量按 if_id 匹配 IPsec 策略和SA
+		if value := attrs[iflaXfrmIfID]; len(value) >= 4 {
+			underlay.Key = nativeEndian.Uint32(value)
+		}
+		underlay.Device = device(iflaXfrmLink)
+	default:
+		return nil
+	}
+	return underlay
+}
+
+// findInterface 在清单中查找接口并构造模型
+func (s *NetworkService) findInterface(inv *interfaceInventory, name string) (models.NetworkInterface, error) {
+	link, ok := inv.link(name)
+	if !ok {
+		return models.NetworkInterface{}, fmt.Errorf("interface %s not found", name)
+	}
+	return s.buildInterface(inv, link), nil
+}
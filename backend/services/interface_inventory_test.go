@@ -0,0 +1,208 @@
+package services
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+	"testing"
+
+	"iptables-management-backend/models"
+)
+
+func testU16(value uint16) []byte {
+	buf := make([]byte, 2)
+	nativeEndian.PutUint16(buf, value)
+	return buf
+}
+
+func testU32(value uint32) []byte {
+	buf := make([]byte, 4)
+	nativeEndian.PutUint32(buf, value)
+	return buf
+}
+
+func testBE16(value uint16) []byte {
+	buf := make([]byte, 2)
+	binary.BigEndian.PutUint16(buf, value)
+	return buf
+}
+
+func TestParseLinkStats64(t *testing.T) {
+	data := make([]byte, 24*8)
+	for i := 0; i < 24; i++ {
+		nativeEndian.PutUint64(data[i*8:], uint64(i+1)*10)
+	}
+	stats := parseLinkStats64(data)
+	if stats == nil || stats.RxPackets != 10 || stats.TxBytes != 40 || stats.RxDropped != 70 || stats.Multicast != 90 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if parseLinkStats64(data[:16]) != nil {
+		t.Error("expected nil for truncated stats")
+	}
+}
+
+func TestParseLinkInfoData(t *testing.T) {
+	vlan := parseVLANInfo(encodeNetlinkAttr(iflaVLANID, testU16(100)))
+	if vlan == nil || vlan.ID != 100 || vlan.Protocol != "802.1Q" {
+		t.Errorf("unexpected vlan info: %+v", vlan)
+	}
+	qinq := parseVLANInfo(append(encodeNetlinkAttr(iflaVLANID, testU16(20)), encodeNetlinkAttr(iflaVLANProtocol, testBE16(0x88a8))...))
+	if qinq == nil || qinq.Protocol != "802.1ad" {
+		t.Errorf("unexpected 802.1ad info: %+v", qinq)
+	}
+
+	var vxlanData []byte
+	vxlanData = append(vxlanData, encodeNetlinkAttr(iflaVXLANID, testU32(42))...)
+	vxlanData = append(vxlanData, encodeNetlinkAttr(iflaVXLANGroup, net.ParseIP("239.1.1.1").To4())...)
+	vxlanData = append(vxlanData, encodeNetlinkAttr(iflaVXLANLocal, net.ParseIP("10.0.0.1").To4())...)
+	vxlanData = append(vxlanData, encodeNetlinkAttr(iflaVXLANLink, testU32(2))...)
+	vxlanData = append(vxlanData, encodeNetlinkAttr(iflaVXLANPort, testBE16(4789))...)
+	vxlan := parseVXLANInfo(vxlanData, map[int]*netlinkLink{2: {Index: 2, Name: "eth0"}})
+	if vxlan == nil || vxlan.VNI != 42 || vxlan.Group != "239.1.1.1" || vxlan.Local != "10.0.0.1" || vxlan.Device != "eth0" || vxlan.DstPort != 4789 {
+		t.Errorf("unexpected vxlan info: %+v", vxlan)
+	}
+
+	var tunData []byte
+	tunData = append(tunData, encodeNetlinkAttr(iflaTunType, []byte{2})...)
+	tunData = append(tunData, encodeNetlinkAttr(iflaTunOwner, testU32(0xffffffff))...)
+	tunData = append(tunData, encodeNetlinkAttr(iflaTunGroup, testU32(100))...)
+	tunData = append(tunData, encodeNetlinkAttr(iflaTunPersist, []byte{1})...)
+	tunData = append(tunData, encodeNetlinkAttr(iflaTunMultiQueue, []byte{0})...)
+	tun := parseTunInfo(tunData)
+	if tun == nil || tun.Mode != "tap" || tun.Owner != -1 || tun.Group != 100 || !tun.Persist || tun.MultiQueue {
+		t.Errorf("unexpected tun info: %+v", tun)
+	}
+}
+
+func TestInterfaceTypeFromKind(t *testing.T) {
+	tests := []struct {
+		kind  string
+		flags uint32
+		want  string
+	}{
+		{"", syscall.IFF_LOOPBACK | syscall.IFF_UP, "loopback"},
+		{"", syscall.IFF_UP, ""},
+		{"bridge", 0, "bridge"},
+		{"veth", 0, "veth"},
+		{"vlan", 0, "vlan"},
+		{"wireguard", 0, "tunnel"},
+		{"tun", 0, "tunnel"},
+		{"vxlan", 0, "tunnel"},
+		{"xfrm", 0, "tunnel"},
+		{"macvlan", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := interfaceTypeFromKind(tt.kind, tt.flags); got != tt.want {
+			t.Errorf("interfaceTypeFromKind(%q, %#x) = %q, want %q", tt.kind, tt.flags, got, tt.want)
+		}
+	}
+}
+
+func TestBuildInterface(t *testing.T) {
+	links := []netlinkLink{
+		{Index: 5, Name: "wg0", Kind: "wireguard", Flags: syscall.IFF_UP, LinkNetNSID: -1, OperState: 0},
+		{Index: 3, Name: "docker0", Kind: "bridge", Flags: syscall.IFF_UP, LinkNetNSID: -1, OperState: 6},
+		{Index: 4, Name: "veth1a2b3c", Kind: "veth", Flags: syscall.IFF_UP, Master: 3, Link: 9, LinkNetNSID: 0,
+			Stats: &netlinkStats{RxBytes: 100, TxBytes: 200, TxDropped: 3}},
+		{Index: 2, Name: "eth0", Flags: syscall.IFF_UP, LinkNetNSID: -1, OperState: 6},
+		{Index: 6, Name: "eth0.100", Kind: "vlan", Flags: syscall.IFF_UP, Link: 2, LinkNetNSID: -1},
+	}
+	addrs := []netlinkAddr{
+		{Index: 5, Family: syscall.AF_INET, IP: net.ParseIP("10.8.0.1"), PrefixLen: 32, Peer: net.ParseIP("10.8.0.2")},
+		{Index: 3, Family: syscall.AF_INET, IP: net.ParseIP("172.17.0.1"), PrefixLen: 16},
+		{Index: 3, Family: syscall.AF_INET6, IP: net.ParseIP("fe80::1"), PrefixLen: 64},
+	}
+	inventory := newInterfaceInventory(links, addrs)
+	service := &NetworkService{}
+
+	if inventory.links[0].Name != "eth0" {
+		t.Errorf("expected links sorted by index, got %s first", inventory.links[0].Name)
+	}
+	if ips := inventory.ipv4Addresses("docker0"); len(ips) != 1 || ips[0] != "172.17.0.1" {
+		t.Errorf("unexpected docker0 IPv4 addresses: %v", ips)
+	}
+	if local, peer := inventory.peerAddress("wg0"); local != "10.8.0.1" || peer != "10.8.0.2" {
+		t.Errorf("unexpected wg0 peer: %s -> %s", local, peer)
+	}
+
+	wg, _ := service.findInterface(inventory, "wg0")
+	if wg.Type != "tunnel" || wg.PeerAddress != "10.8.0.2" || wg.OperState != "UNKNOWN" {
+		t.Errorf("unexpected wg0: %+v", wg)
+	}
+	bridge, _ := service.findInterface(inventory, "docker0")
+	if bridge.Type != "bridge" || !bridge.IsDocker || len(bridge.IPAddresses) != 2 || bridge.IPAddresses[1] != "fe80::1/64" {
+		t.Errorf("unexpected docker0: %+v", bridge)
+	}
+	veth, _ := service.findInterface(inventory, "veth1a2b3c")
+	if veth.Master != "docker0" || veth.ParentIndex != 9 || veth.Parent != "" || veth.LinkNetNSID == nil || *veth.LinkNetNSID != 0 {
+		t.Errorf("unexpected veth: %+v", veth)
+	}
+	if veth.Statistics.TxBytes != 200 || veth.Statistics.TxDropped != 3 {
+		t.Errorf("unexpected veth stats: %+v", veth.Statistics)
+	}
+	vlan, _ := service.findInterface(inventory, "eth0.100")
+	if vlan.Type != "vlan" || vlan.Parent != "eth0" || vlan.LinkNetNSID != nil {
+		t.Errorf("unexpected vlan: %+v", vlan)
+	}
+	eth, _ := service.findInterface(inventory, "eth0")
+	if eth.Type != "ethernet" || eth.OperState != "UP" {
+		t.Errorf("unexpected eth0: %+v", eth)
+	}
+	if _, err := service.findInterface(inventory, "missing0"); err == nil {
+		t.Error("expected error for missing interface")
+	}
+}
+
+func TestParseTunnelUnderlay(t *testing.T) {
+	links := map[int]*netlinkLink{2: {Index: 2, Name: "eth0"}}
+	be32 := func(value uint32) []byte {
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, value)
+		return buf
+	}
+
+	var greData []byte
+	greData = append(greData, encodeNetlinkAttr(iflaGRELink, testU32(2))...)
+	greData = append(greData, encodeNetlinkAttr(iflaGREOKey, be32(7))...)
+	greData = append(greData, encodeNetlinkAttr(iflaGRELocal, net.ParseIP("10.0.0.1").To4())...)
+	greData = append(greData, encodeNetlinkAttr(iflaGRERemote, net.ParseIP("10.0.0.2").To4())...)
+	greData = append(greData, encodeNetlinkAttr(iflaGRETTL, []byte{64})...)
+
+	var ipipData []byte
+	ipipData = append(ipipData, encodeNetlinkAttr(iflaIPTunLocal, net.IPv4zero.To4())...)
+	ipipData = append(ipipData, encodeNetlinkAttr(iflaIPTunRemote, net.ParseIP("203.0.113.9").To4())...)
+
+	var geneveData []byte
+	geneveData = append(geneveData, encodeNetlinkAttr(iflaGeneveID, testU32(5))...)
+	geneveData = append(geneveData, encodeNetlinkAttr(iflaGeneveRemote6, net.ParseIP("2001:db8::1"))...)
+	geneveData = append(geneveData, encodeNetlinkAttr(iflaGenevePort, testBE16(6081))...)
+
+	var vtiData []byte
+	vtiData = append(vtiData, encodeNetlinkAttr(iflaVTIOKey, be32(3))...)
+	vtiData = append(vtiData, encodeNetlinkAttr(iflaVTILocal, net.ParseIP("10.0.0.1").To4())...)
+	vtiData = append(vtiData, encodeNetlinkAttr(iflaVTIRemote, net.ParseIP("10.0.0.5").To4())...)
+
+	tests := []struct {
+		kind       string
+		data       []byte
+		want       *models.TunnelUnderlay
+		encryption string
+	}{
+		{"gre", greData, &models.TunnelUnderlay{Local: "10.0.0.1", Remote: "10.0.0.2", Key: 7, Device: "eth0", TTL: 64}, "none"},
+		{"ipip", ipipData, &models.TunnelUnderlay{Remote: "203.0.113.9"}, "none"},
+		{"geneve", geneveData, &models.TunnelUnderlay{Remote: "2001:db8::1", Key: 5, Port: 6081}, "none"},
+		{"vti", vtiData, &models.TunnelUnderlay{Local: "10.0.0.1", Remote: "10.0.0.5", Key: 3}, "ipsec"},
+		{"xfrm", encodeNetlinkAttr(iflaXfrmIfID, testU32(9)), &models.TunnelUnderlay{Key: 9}, "ipsec"},
+		{"wireguard", nil, nil, "wireguard"},
+		{"tun", nil, nil, "unknown"},
+	}
+	for _, tt := range tests {
+		got := parseTunnelUnderlay(tt.kind, tt.data, links)
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("%s: underlay = %+v, want %+v", tt.kind, got, tt.want)
+		}
+		if encryption := tunnelEncryption(tt.kind); encryption != tt.encryption {
+			t.Errorf("%s: encryption = %s, want %s", tt.kind, encryption, tt.encryption)
+		}
+	}
+}
@@ -0,0 +1,737 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"iptables-management-backend/models"
+	"log"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ipsetTypeDimensions 支持的集合类型及其维度（--match-set 需要的方向标志个数）
+var ipsetTypeDimensions = map[string]int{
+	"hash:ip":       1,
+	"hash:net":      1,
+	"hash:ip,port":  2,
+	"hash:net,port": 2,
+}
+
+// ipsetNamePattern ipset名称（内核限制31个字符）
+var ipsetNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]{1,31}$`)
+
+// ipsetTempPrefix 原子替换导入时临时集合的名称前缀（加16位十六进制随机后缀共26个字符）
+const ipsetTempPrefix = "iptm-swap-"
+
+// matchSetFlagsPattern --match-set 方向标志
+var matchSetFlagsPattern = regexp.MustCompile(`^(src|dst)(,(src|dst)){0,5}$`)
+
+type IPSetService struct{}
+
+// NewIPSetService 创建ipset服务实例
+func NewIPSetService() *IPSetService {
+	return &IPSetService{}
+}
+
+// IPSetCreateRequest 创建集合的参数
+type IPSetCreateRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Type     string `json:"type" binding:"required"`
+	Family   string `json:"family"`  // inet（默认）或 inet6
+	Timeout  *int   `json:"timeout"` // 设置后条目支持超时，0 表示默认不过期
+	HashSize int    `json:"hash_size"`
+	MaxElem  int    `json:"max_elem"`
+	Comment  bool   `json:"comment"`
+	Counters bool   `json:"counters"`
+}
+
+// IPSetImportRequest 批量导入条目的参数
+type IPSetImportRequest struct {
+	Content string `json:"content" binding:"required"`
+	Format  string `json:"format"`  // text（默认，每行一个条目）或 csv
+	Timeout int    `json:"timeout"` // 未单独指定超时的条目使用该值（仅对带 timeout 的集合有效）
+	Replace bool   `json:"replace"` // 通过临时集合 + swap 原子替换全部内容
+}
+
+// IPSetImportError 无法导入的行
+type IPSetImportError struct {
+	Line  int    `json:"line"`
+	Value string `json:"value"`
+	Error string `json:"error"`
+}
+
+// IPSetImportResult 批量导入结果
+type IPSetImportResult struct {
+	Set      string             `json:"set"`
+	Imported int                `json:"imported"`
+	Replaced bool               `json:"replaced"`
+	Invalid  []IPSetImportError `json:"invalid"`
+}
+
+// ListSets 列出所有集合（不含条目），并标注引用它们的iptables规则
+func (s *IPSetService) ListSets() ([]models.IPSet, error) {
+	output, err := runCommand("ipset", "list", "-t")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ipsets: %s", commandError(err))
+	}
+	sets := ParseIPSetList(string(output))
+	sortIPSets(sets)
+	s.attachReferences(sets)
+	return sets, nil
+}
+
+// GetSet 获取集合详情及条目；limit 大于0时只返回前 limit 个条目
+func (s *IPSetService) GetSet(name string, limit int) (*models.IPSet, error) {
+	if !ipsetNamePattern.MatchString(name) {
+		return nil, invalidf("invalid set name %q", name)
+	}
+	output, err := runCommand("ipset", "list", name)
+	if err != nil {
+		return nil, ipsetListError(name, err)
+	}
+	sets := ParseIPSetList(string(output))
+	if len(sets) == 0 {
+		return nil, notFoundf("set %s does not exist", name)
+	}
+	s.attachReferences(sets)
+
+	set := sets[0]
+	if limit > 0 && len(set.Entries) > limit {
+		set.Entries = set.Entries[:limit]
+	}
+	return &set, nil
+}
+
+// setHeader 只读取集合头部信息（ipset list -t），避免大集合时列出全部条目
+func (s *IPSetService) setHeader(name string) (*models.IPSet, error) {
+	if !ipsetNamePattern.MatchString(name) {
+		return nil, invalidf("invalid set name %q", name)
+	}
+	output, err := runCommand("ipset", "list", "-t", name)
+	if err != nil {
+		return nil, ipsetListError(name, err)
+	}
+	sets := ParseIPSetList(string(output))
+	if len(sets) == 0 {
+		return nil, notFoundf("set %s does not exist", name)
+	}
+	return &sets[0], nil
+}
+
+// ipsetListError 转换 ipset list 的失败，集合不存在时返回 NotFoundError
+func ipsetListError(name string, err error) error {
+	message := commandError(err)
+	if strings.Contains(message, "does not exist") {
+		return notFoundf("set %s does not exist", name)
+	}
+	return fmt.Errorf("failed to list ipset %s: %s", name, message)
+}
+
+// CreateSet 创建集合
+func (s *IPSetService) CreateSet(request IPSetCreateRequest) (*models.IPSet, error) {
+	args, err := ipsetCreateArgs(request)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := runCommand("ipset", args...); err != nil {
+		return nil, fmt.Errorf("failed to create ipset %s: %s", request.Name, commandError(err))
+	}
+	log.Printf("[DEBUG] Created ipset: ipset %s", strings.Join(args, " "))
+	return s.GetSet(request.Name, 0)
+}
+
+// ImportEntries 从文本或CSV批量导入条目；无效行跳过并在结果中列出
+func (s *IPSetService) ImportEntries(name string, request IPSetImportRequest) (*IPSetImportResult, error) {
+	set, err := s.setHeader(name)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, invalid, err := ParseIPSetImport(request.Content, request.Format, *set)
+	if err != nil {
+		return nil, err
+	}
+	result := &IPSetImportResult{Set: name, Invalid: invalid, Replaced: request.Replace}
+	if len(entries) == 0 && !request.Replace {
+		return result, nil
+	}
+
+	target := name
+	var script strings.Builder
+	if request.Replace {
+		// 以同样的创建参数建立临时集合，填充后与原集合交换，保证引用它的规则不会看到半满的集合
+		header, err := s.saveHeader(name)
+		if err != nil {
+			return nil, err
+		}
+		// 只使用本次新建的临时集合，失败时也只删除它，不会误删同名的用户集合
+		target = ipsetTempName()
+		names, err := listIPSetNames()
+		if err != nil {
+			return nil, err
+		}
+		if names[target] {
+			return nil, fmt.Errorf("temporary ipset %s already exists", target)
+		}
+		if _, err := runCommand("ipset", append([]string{"create", target}, strings.Fields(header)...)...); err != nil {
+			return nil, fmt.Errorf("failed to create temporary ipset %s: %s", target, commandError(err))
+		}
+	}
+	for _, entry := range entries {
+		if entry.Timeout == 0 && request.Timeout > 0 && set.HasTimeout {
+			entry.Timeout = request.Timeout
+		}
+		script.WriteString(ipsetAddLine(target, entry, *set) + "\n")
+	}
+	if request.Replace {
+		script.WriteString(fmt.Sprintf("swap %s %s\ndestroy %s\n", target, name, target))
+	}
+
+	if _, err := runCommandInput(script.String(), "ipset", "restore", "-exist"); err != nil {
+		if request.Replace {
+			runCommand("ipset", "destroy", target)
+		}
+		return nil, fmt.Errorf("failed to import entries into ipset %s: %s", name, commandError(err))
+	}
+	result.Imported = len(entries)
+	log.Printf("[DEBUG] Imported %d entries into ipset %s (replace=%v, invalid=%d)", result.Imported, name, request.Replace, len(invalid))
+	return result, nil
+}
+
+// AddEntry 添加单个条目
+func (s *IPSetService) AddEntry(name string, entry models.IPSetEntry) error {
+	set, err := s.setHeader(name)
+	if err != nil {
+		return err
+	}
+	normalized, err := validateIPSetEntry(entry.Entry, *set)
+	if err != nil {
+		return err
+	}
+	entry.Entry = normalized
+	if entry.Timeout > 0 && !set.HasTimeout {
+		return invalidf("set %s was created without timeout support", name)
+	}
+
+	args := append([]string{"-exist"}, strings.Fields(ipsetAddLine(name, entry, models.IPSet{HasTimeout: set.HasTimeout}))...)
+	if entry.Comment != "" && set.Comment {
+		args = append(args, "comment", entry.Comment)
+	}
+	if _, err := runCommand("ipset", args...); err != nil {
+		return fmt.Errorf("failed to add %s to ipset %s: %s", entry.Entry, name, commandError(err))
+	}
+	return nil
+}
+
+// DeleteEntry 删除单个条目
+func (s *IPSetService) DeleteEntry(name, entry string) error {
+	if !ipsetNamePattern.MatchString(name) {
+		return invalidf("invalid set name %q", name)
+	}
+	if _, err := runCommand("ipset", "del", name, entry); err != nil {
+		return fmt.Errorf("failed to delete %s from ipset %s: %s", entry, name, commandError(err))
+	}
+	return nil
+}
+
+// SwapSets 交换两个集合的内容（类型需一致），引用它们的规则立即生效
+func (s *IPSetService) SwapSets(first, second string) error {
+	for _, name := range []string{first, second} {
+		if !ipsetNamePattern.MatchString(name) {
+			return invalidf("invalid set name %q", name)
+		}
+	}
+	if _, err := runCommand("ipset", "swap", first, second); err != nil {
+		return fmt.Errorf("failed to swap ipsets %s and %s: %s", first, second, commandError(err))
+	}
+	return nil
+}
+
+// DestroySet 删除集合；仍被iptables规则引用时拒绝
+func (s *IPSetService) DestroySet(name string) error {
+	set, err := s.setHeader(name)
+	if err != nil {
+		return err
+	}
+	if set.References > 0 {
+		sets := []models.IPSet{*set}
+		s.attachReferences(sets)
+		set = &sets[0]
+		return conflictf("set %s is still referenced by %d rules: %s", name, set.References, strings.Join(set.ReferencedBy, ", "))
+	}
+	if _, err := runCommand("ipset", "destroy", name); err != nil {
+		return fmt.Errorf("failed to destroy ipset %s: %s", name, commandError(err))
+	}
+	return nil
+}
+
+// saveHeader 读取 ipset save 中集合的创建参数（类型之后的部分）
+func (s *IPSetService) saveHeader(name string) (string, error) {
+	output, err := runCommand("ipset", "save", name)
+	if err != nil {
+		return "", fmt.Errorf("failed to save ipset %s: %s", name, commandError(err))
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[0] == "create" && fields[1] == name {
+			return strings.Join(fields[2:], " "), nil
+		}
+	}
+	return "", fmt.Errorf("failed to find create line for ipset %s", name)
+}
+
+// attachReferences 根据 iptables-save 标注引用每个集合的规则
+func (s *IPSetService) attachReferences(sets []models.IPSet) {
+	references := make(map[string][]string)
+	if ruleset, err := LoadSavedRuleset(); err != nil {
+		log.Printf("[WARN] Failed to load ruleset for ipset references: %v", err)
+	} else {
+		references = ipsetReferences(ruleset)
+	}
+	for i := range sets {
+		sets[i].ReferencedBy = references[sets[i].Name]
+		if sets[i].ReferencedBy == nil {
+			sets[i].ReferencedBy = []string{}
+		}
+	}
+}
+
+// ipsetReferences 统计规则集中每个集合被哪些规则引用（表/链#序号）
+func ipsetReferences(ruleset *SavedRuleset) map[string][]string {
+	references := make(map[string][]string)
+	for _, table := range ruleset.Tables {
+		for _, rule := range table.Rules {
+			for _, set := range rule.Match.Sets {
+				references[set.Name] = append(references[set.Name], fmt.Sprintf("%s/%s#%d", rule.Table, rule.Chain, rule.Position))
+			}
+		}
+	}
+	return references
+}
+
+// ipsetCreateArgs 校验创建参数并生成 ipset create 命令参数
+func ipsetCreateArgs(request IPSetCreateRequest) ([]string, error) {
+	if !ipsetNamePattern.MatchString(request.Name) {
+		return nil, invalidf("invalid set name %q", request.Name)
+	}
+	if _, ok := ipsetTypeDimensions[request.Type]; !ok {
+		return nil, invalidf("unsupported set type %q", request.Type)
+	}
+	family := request.Family
+	if family == "" {
+		family = "inet"
+	}
+	if family != "inet" && family != "inet6" {
+		return nil, invalidf("invalid family %q", request.Family)
+	}
+
+	args := []string{"create", request.Name, request.Type, "family", family}
+	if request.HashSize > 0 {
+		args = append(args, "hashsize", strconv.Itoa(request.HashSize))
+	}
+	if request.MaxElem > 0 {
+		args = append(args, "maxelem", strconv.Itoa(request.MaxElem))
+	}
+	if request.Timeout != nil {
+		if *request.Timeout < 0 {
+			return nil, invalidf("invalid timeout %d", *request.Timeout)
+		}
+		args = append(args, "timeout", strconv.Itoa(*request.Timeout))
+	}
+	if request.Counters {
+		args = append(args, "counters")
+	}
+	if request.Comment {
+		args = append(args, "comment")
+	}
+	return args, nil
+}
+
+// ipsetAddLine 生成 ipset restore 的 add 行
+func ipsetAddLine(name string, entry models.IPSetEntry, set models.IPSet) string {
+	line := "add " + name + " " + entry.Entry
+	if entry.Timeout > 0 && set.HasTimeout {
+		line += " timeout " + strconv.Itoa(entry.Timeout)
+	}
+	if entry.Comment != "" && set.Comment {
+		line += " comment " + strconv.Quote(entry.Comment)
+	}
+	return line
+}
+
+// ipsetTempName 原子替换时使用的临时集合名称：固定前缀加随机后缀，不从原集合名称派生（截断后可能重名）
+func ipsetTempName() string {
+	suffix := make([]byte, 8)
+	rand.Read(suffix)
+	return ipsetTempPrefix + hex.EncodeToString(suffix)
+}
+
+// listIPSetNames 读取当前所有集合名称
+func listIPSetNames() (map[string]bool, error) {
+	output, err := runCommand("ipset", "list", "-n")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ipsets: %s", commandError(err))
+	}
+	names := make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			names[name] = true
+		}
+	}
+	return names, nil
+}
+
+// ParseIPSetList 解析 ipset list（含 -t）输出
+func ParseIPSetList(output string) []models.IPSet {
+	var sets []models.IPSet
+	var current *models.IPSet
+	inMembers := false
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "Name: ") {
+			sets = append(sets, models.IPSet{Name: strings.TrimPrefix(line, "Name: "), Entries: []models.IPSetEntry{}})
+			current = &sets[len(sets)-1]
+			inMembers = false
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if inMembers {
+			if strings.TrimSpace(line) != "" {
+				current.Entries = append(current.Entries, parseIPSetMember(line))
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Type":
+			current.Type = value
+		case "Revision":
+			current.Revision, _ = strconv.Atoi(value)
+		case "Header":
+			parseIPSetHeader(value, current)
+		case "Size in memory":
+			current.SizeInMemory, _ = strconv.Atoi(value)
+		case "References":
+			current.References, _ = strconv.Atoi(value)
+		case "Number of entries":
+			current.EntryCount, _ = strconv.Atoi(value)
+		case "Members":
+			inMembers = true
+		}
+	}
+
+	// 旧版本 ipset 没有 "Number of entries" 行
+	for i := range sets {
+		if sets[i].EntryCount == 0 && len(sets[i].Entries) > 0 {
+			sets[i].EntryCount = len(sets[i].Entries)
+		}
+	}
+	return sets
+}
+
+// parseIPSetHeader 解析 Header 行，如 "family inet hashsize 1024 maxelem 65536 timeout 300 comment"
+func parseIPSetHeader(header string, set *models.IPSet) {
+	fields := strings.Fields(header)
+	for i := 0; i < len(fields); i++ {
+		next := ""
+		if i+1 < len(fields) {
+			next = fields[i+1]
+		}
+		switch fields[i] {
+		case "family":
+			set.Family = next
+			i++
+		case "hashsize":
+			set.HashSize, _ = strconv.Atoi(next)
+			i++
+		case "maxelem":
+			set.MaxElem, _ = strconv.Atoi(next)
+			i++
+		case "timeout":
+			set.HasTimeout = true
+			set.Timeout, _ = strconv.Atoi(next)
+			i++
+		case "counters":
+			set.Counters = true
+		case "comment":
+			set.Comment = true
+		case "bucketsize", "initval", "netmask", "markmask", "size":
+			i++
+		}
+	}
+}
+
+// parseIPSetMember 解析成员行，如 `1.2.3.4 timeout 297 packets 3 bytes 180 comment "bad host"`
+func parseIPSetMember(line string) models.IPSetEntry {
+	line = strings.TrimSpace(line)
+	entryText, rest, _ := strings.Cut(line, " ")
+	entry := models.IPSetEntry{Entry: entryText}
+
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		var key string
+		key, rest, _ = strings.Cut(rest, " ")
+		rest = strings.TrimSpace(rest)
+
+		var value string
+		if key == "comment" && strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end == -1 {
+				entry.Comment = strings.Trim(rest, `"`)
+				break
+			}
+			entry.Comment = rest[1 : end+1]
+			rest = rest[end+2:]
+			continue
+		}
+		value, rest, _ = strings.Cut(rest, " ")
+		switch key {
+		case "timeout":
+			entry.Timeout, _ = strconv.Atoi(value)
+		case "packets":
+			entry.Packets, _ = strconv.ParseInt(value, 10, 64)
+		case "bytes":
+			entry.Bytes, _ = strconv.ParseInt(value, 10, 64)
+		case "nomatch":
+			// 无参数的标志，value 属于下一个键
+			rest = strings.TrimSpace(value + " " + rest)
+		}
+	}
+	return entry
+}
+
+// ParseIPSetImport 解析导入内容；text 每行第一列为条目（可跟 "timeout N"），
+// csv 的列为 条目[,超时[,注释]]，ip,port 类型的条目占两列。首行无法解析为地址时视为表头
+func ParseIPSetImport(content, format string, set models.IPSet) ([]models.IPSetEntry, []IPSetImportError, error) {
+	dimension, ok := ipsetTypeDimensions[set.Type]
+	if !ok {
+		return nil, nil, invalidf("unsupported set type %q", set.Type)
+	}
+
+	type row struct {
+		line   int
+		fields []string
+	}
+	var rows []row
+	switch format {
+	case "", "text":
+		for i, line := range strings.Split(content, "\n") {
+			if hash := strings.Index(line, "#"); hash != -1 {
+				line = line[:hash]
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			values := []string{fields[0]}
+			if len(fields) >= 3 && fields[1] == "timeout" {
+				values = append(values, fields[2])
+			}
+			rows = append(rows, row{line: i + 1, fields: values})
+		}
+	case "csv":
+		reader := csv.NewReader(strings.NewReader(content))
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		reader.Comment = '#'
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			line, _ := reader.FieldPos(0)
+			if err != nil {
+				return nil, nil, invalidf("invalid csv at line %d: %v", line, err)
+			}
+			if len(record) < dimension {
+				rows = append(rows, row{line: line, fields: []string{strings.Join(record, ",")}})
+				continue
+			}
+			// 多维条目（如 ip,port）合并为ipset格式
+			values := []string{strings.Join(record[:dimension], ",")}
+			values = append(values, record[dimension:]...)
+			rows = append(rows, row{line: line, fields: values})
+		}
+	default:
+		return nil, nil, invalidf("unsupported import format %q", format)
+	}
+
+	var entries []models.IPSetEntry
+	var invalid []IPSetImportError
+	seen := make(map[string]bool)
+	for i, r := range rows {
+		normalized, err := validateIPSetEntry(r.fields[0], set)
+		if err != nil {
+			first := strings.SplitN(r.fields[0], ",", 2)[0]
+			if i == 0 && net.ParseIP(first) == nil && !strings.Contains(first, "/") {
+				continue
+			}
+			invalid = append(invalid, IPSetImportError{Line: r.line, Value: r.fields[0], Error: err.Error()})
+			continue
+		}
+
+		entry := models.IPSetEntry{Entry: normalized}
+		if len(r.fields) > 1 && strings.TrimSpace(r.fields[1]) != "" {
+			timeout, err := strconv.Atoi(strings.TrimSpace(r.fields[1]))
+			if err != nil || timeout < 0 {
+				invalid = append(invalid, IPSetImportError{Line: r.line, Value: r.fields[1], Error: "invalid timeout"})
+				continue
+			}
+			entry.Timeout = timeout
+		}
+		if len(r.fields) > 2 {
+			entry.Comment = strings.TrimSpace(r.fields[2])
+		}
+		if seen[entry.Entry] {
+			continue
+		}
+		seen[entry.Entry] = true
+		entries = append(entries, entry)
+	}
+	return entries, invalid, nil
+}
+
+// validateIPSetEntry 按集合类型和地址族校验条目，返回规范化后的条目
+func validateIPSetEntry(value string, set models.IPSet) (string, error) {
+	value = strings.TrimSpace(value)
+	parts := strings.Split(value, ",")
+	dimension := ipsetTypeDimensions[set.Type]
+	if len(parts) != dimension {
+		return "", invalidf("entry %q does not match set type %s", value, set.Type)
+	}
+
+	address, err := normalizeIPSetAddress(parts[0], strings.HasPrefix(set.Type, "hash:net"), set.Family)
+	if err != nil {
+		return "", err
+	}
+	if dimension == 1 {
+		return address, nil
+	}
+
+	port, err := normalizeIPSetPort(parts[1])
+	if err != nil {
+		return "", err
+	}
+	return address + "," + port, nil
+}
+
+// normalizeIPSetAddress 校验地址（hash:net 允许CIDR），检查地址族
+func normalizeIPSetAddress(value string, allowNet bool, family string) (string, error) {
+	var ip net.IP
+	result := value
+	if strings.Contains(value, "/") {
+		if !allowNet {
+			return "", invalidf("%q: CIDR is only allowed in hash:net sets", value)
+		}
+		parsed, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return "", invalidf("%q: invalid CIDR", value)
+		}
+		ip = parsed
+		result = network.String()
+	} else {
+		ip = net.ParseIP(value)
+		if ip == nil {
+			return "", invalidf("%q: invalid IP address", value)
+		}
+		result = ip.String()
+	}
+
+	isIPv4 := ip.To4() != nil
+	if family == "inet6" && isIPv4 || family != "inet6" && !isIPv4 {
+		return "", invalidf("%q: address family does not match set family %s", value, family)
+	}
+	return result, nil
+}
+
+// normalizeIPSetPort 校验 [proto:]port[-port]，协议缺省为tcp
+func normalizeIPSetPort(value string) (string, error) {
+	protocol, portRange := "tcp", value
+	if before, after, ok := strings.Cut(value, ":"); ok {
+		protocol, portRange = strings.ToLower(before), after
+	}
+	switch protocol {
+	case "tcp", "udp", "sctp", "udplite":
+	default:
+		return "", invalidf("%q: unsupported protocol %s", value, protocol)
+	}
+
+	bounds := strings.SplitN(portRange, "-", 2)
+	for _, bound := range bounds {
+		port, err := strconv.Atoi(bound)
+		if err != nil || port < 0 || port > 65535 {
+			return "", invalidf("%q: invalid port", value)
+		}
+	}
+	if len(bounds) == 2 {
+		low, _ := strconv.Atoi(bounds[0])
+		high, _ := strconv.Atoi(bounds[1])
+		if low > high {
+			return "", invalidf("%q: invalid port range", value)
+		}
+	}
+	return protocol + ":" + portRange, nil
+}
+
+// ValidateMatchSet 校验规则中的集合引用；集合存在时检查方向标志个数与集合维度一致
+func (s *IPSetService) ValidateMatchSet(name, flags string) error {
+	if !ipsetNamePattern.MatchString(name) {
+		return invalidf("invalid set name %q", name)
+	}
+	if !matchSetFlagsPattern.MatchString(flags) {
+		return invalidf("invalid match-set flags %q", flags)
+	}
+
+	output, err := runCommand("ipset", "list", "-t", name)
+	if err != nil {
+		message := commandError(err)
+		if strings.Contains(message, "does not exist") {
+			return invalidf("set %s does not exist", name)
+		}
+		// ipset 不可用时只做格式校验
+		log.Printf("[WARN] Unable to verify ipset %s: %s", name, message)
+		return nil
+	}
+	sets := ParseIPSetList(string(output))
+	if len(sets) == 1 {
+		if dimension, ok := ipsetTypeDimensions[sets[0].Type]; ok && len(strings.Split(flags, ",")) != dimension {
+			return invalidf("set %s of type %s needs %d direction flags, got %q", name, sets[0].Type, dimension, flags)
+		}
+	}
+	return nil
+}
+
+// parseSetReferences 从规则文本中提取集合引用，支持 iptables -S（--match-set）和 -L（match-set）两种格式
+func parseSetReferences(ruleText string) []SetMatch {
+	fields := strings.Fields(ruleText)
+	var references []SetMatch
+	for i, field := range fields {
+		if (field != "--match-set" && field != "match-set") || i+2 >= len(fields) {
+			continue
+		}
+		references = append(references, SetMatch{
+			Name:   fields[i+1],
+			Flags:  fields[i+2],
+			Negate: i > 0 && fields[i-1] == "!",
+		})
+	}
+	return references
+}
+
+// sortIPSets 按名称排序
+func sortIPSets(sets []models.IPSet) {
+	sort.Slice(sets, func(i, j int) bool { return sets[i].Name < sets[j].Name })
+}
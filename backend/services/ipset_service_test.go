@@ -0,0 +1,207 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"iptables-management-backend/models"
+)
+
+const sampleIPSetList = `Name: blocklist
+Type: hash:net
+Revision: 7
+Header: family inet hashsize 1024 maxelem 65536 timeout 600 counters comment bucketsize 12 initval 0x5f1b2c3d
+Size in memory: 1432
+References: 2
+Number of entries: 3
+Members:
+10.0.0.0/8 timeout 597 packets 0 bytes 0 comment "rfc1918"
+203.0.113.7 timeout 12 packets 4 bytes 240
+198.51.100.0/24 timeout 0 packets 0 bytes 0 nomatch comment "allow, then \"deny\""
+Name: web6
+Type: hash:ip,port
+Revision: 6
+Header: family inet6 hashsize 1024 maxelem 65536
+Size in memory: 200
+References: 0
+Number of entries: 1
+Members:
+2001:db8::1,tcp:443
+`
+
+func TestParseIPSetList(t *testing.T) {
+	sets := ParseIPSetList(sampleIPSetList)
+	if len(sets) != 2 {
+		t.Fatalf("expected 2 sets, got %d", len(sets))
+	}
+
+	block := sets[0]
+	if block.Type != "hash:net" || block.Family != "inet" || block.Revision != 7 || block.HashSize != 1024 ||
+		block.MaxElem != 65536 || !block.HasTimeout || block.Timeout != 600 || !block.Counters || !block.Comment ||
+		block.SizeInMemory != 1432 || block.References != 2 || block.EntryCount != 3 {
+		t.Errorf("unexpected header: %+v", block)
+	}
+	if len(block.Entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(block.Entries))
+	}
+	first := block.Entries[0]
+	if first.Entry != "10.0.0.0/8" || first.Timeout != 597 || first.Comment != "rfc1918" {
+		t.Errorf("unexpected first entry: %+v", first)
+	}
+	second := block.Entries[1]
+	if second.Entry != "203.0.113.7" || second.Packets != 4 || second.Bytes != 240 || second.Comment != "" {
+		t.Errorf("unexpected second entry: %+v", second)
+	}
+	if third := block.Entries[2]; third.Entry != "198.51.100.0/24" || third.Comment == "" {
+		t.Errorf("unexpected third entry: %+v", third)
+	}
+
+	web := sets[1]
+	if web.Family != "inet6" || web.HasTimeout || len(web.Entries) != 1 || web.Entries[0].Entry != "2001:db8::1,tcp:443" {
+		t.Errorf("unexpected web6 set: %+v", web)
+	}
+}
+
+func TestValidateIPSetEntry(t *testing.T) {
+	ipSet := models.IPSet{Type: "hash:ip", Family: "inet"}
+	netSet := models.IPSet{Type: "hash:net", Family: "inet"}
+	portSet := models.IPSet{Type: "hash:ip,port", Family: "inet"}
+	ip6Set := models.IPSet{Type: "hash:ip", Family: "inet6"}
+
+	tests := []struct {
+		set     models.IPSet
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{ipSet, "192.0.2.1", "192.0.2.1", false},
+		{ipSet, "192.0.2.0/24", "", true},
+		{ipSet, "2001:db8::1", "", true},
+		{netSet, "192.0.2.9/24", "192.0.2.0/24", false},
+		{netSet, "not-an-ip", "", true},
+		{portSet, "192.0.2.1,80", "192.0.2.1,tcp:80", false},
+		{portSet, "192.0.2.1,UDP:53", "192.0.2.1,udp:53", false},
+		{portSet, "192.0.2.1,tcp:1000-2000", "192.0.2.1,tcp:1000-2000", false},
+		{portSet, "192.0.2.1,tcp:2000-1000", "", true},
+		{portSet, "192.0.2.1,icmp:8", "", true},
+		{portSet, "192.0.2.1", "", true},
+		{ip6Set, "2001:DB8::1", "2001:db8::1", false},
+	}
+	for _, tt := range tests {
+		got, err := validateIPSetEntry(tt.value, tt.set)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("validateIPSetEntry(%q, %s) = %q, %v; want %q, err=%v", tt.value, tt.set.Type, got, err, tt.want, tt.wantErr)
+		}
+		var validation *ValidationError
+		if err != nil && !errors.As(err, &validation) {
+			t.Errorf("validateIPSetEntry(%q) returned %T, want *ValidationError", tt.value, err)
+		}
+	}
+}
+
+func TestParseIPSetImport(t *testing.T) {
+	set := models.IPSet{Type: "hash:net", Family: "inet", HasTimeout: true, Comment: true}
+	text := "# blocklist export\n10.0.0.0/8\n192.0.2.1 timeout 60\nbogus\n10.0.0.0/8 # duplicate\n"
+	entries, invalid, err := ParseIPSetImport(text, "text", set)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 2 || entries[1].Entry != "192.0.2.1" || entries[1].Timeout != 60 {
+		t.Errorf("unexpected text entries: %+v", entries)
+	}
+	if len(invalid) != 1 || invalid[0].Line != 4 || invalid[0].Value != "bogus" {
+		t.Errorf("unexpected text invalid lines: %+v", invalid)
+	}
+
+	portSet := models.IPSet{Type: "hash:ip,port", Family: "inet", HasTimeout: true, Comment: true}
+	csvContent := "address,port,timeout,comment\n192.0.2.1,443,300,web\n192.0.2.2,udp:53,,dns\n192.0.2.3,70000\n"
+	entries, invalid, err = ParseIPSetImport(csvContent, "csv", portSet)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 csv entries, got %+v", entries)
+	}
+	if entries[0].Entry != "192.0.2.1,tcp:443" || entries[0].Timeout != 300 || entries[0].Comment != "web" {
+		t.Errorf("unexpected first csv entry: %+v", entries[0])
+	}
+	if entries[1].Entry != "192.0.2.2,udp:53" || entries[1].Timeout != 0 || entries[1].Comment != "dns" {
+		t.Errorf("unexpected second csv entry: %+v", entries[1])
+	}
+	if len(invalid) != 1 || invalid[0].Line != 4 {
+		t.Errorf("unexpected csv invalid lines: %+v", invalid)
+	}
+
+	if _, _, err := ParseIPSetImport("1.2.3.4", "json", set); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
+
+func TestIPSetCreateArgs(t *testing.T) {
+	timeout := 300
+	args, err := ipsetCreateArgs(IPSetCreateRequest{Name: "web", Type: "hash:ip,port", Timeout: &timeout, Counters: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "create web hash:ip,port family inet timeout 300 counters"
+	if got := strings.Join(args, " "); got != want {
+		t.Errorf("args = %q, want %q", got, want)
+	}
+
+	for _, request := range []IPSetCreateRequest{
+		{Name: "bad name", Type: "hash:ip"},
+		{Name: "this-name-is-way-too-long-for-ipset", Type: "hash:ip"},
+		{Name: "web", Type: "list:set"},
+		{Name: "web", Type: "hash:ip", Family: "inet4"},
+	} {
+		var validation *ValidationError
+		if _, err := ipsetCreateArgs(request); !errors.As(err, &validation) {
+			t.Errorf("expected validation error for %+v, got %v", request, err)
+		}
+	}
+
+	first, second := ipsetTempName(), ipsetTempName()
+	if len(first) > 31 || !ipsetNamePattern.MatchString(first) || !strings.HasPrefix(first, ipsetTempPrefix) {
+		t.Errorf("invalid temporary name: %s", first)
+	}
+	if first == second {
+		t.Errorf("expected unique temporary names, got %s twice", first)
+	}
+}
+
+func TestParseSetReferences(t *testing.T) {
+	tests := []struct {
+		text string
+		want []SetMatch
+	}{
+		{"-A INPUT -m set --match-set blocklist src -j DROP", []SetMatch{{Name: "blocklist", Flags: "src"}}},
+		{"-A FORWARD -m set ! --match-set allow dst,dst -j REJECT", []SetMatch{{Name: "allow", Flags: "dst,dst", Negate: true}}},
+		{"match-set blocklist src ! match-set web6 dst", []SetMatch{{Name: "blocklist", Flags: "src"}, {Name: "web6", Flags: "dst", Negate: true}}},
+		{"tcp dpt:22", nil},
+	}
+	for _, tt := range tests {
+		got := parseSetReferences(tt.text)
+		if len(got) != len(tt.want) {
+			t.Errorf("parseSetReferences(%q) = %+v, want %+v", tt.text, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("parseSetReferences(%q)[%d] = %+v, want %+v", tt.text, i, got[i], tt.want[i])
+			}
+		}
+	}
+
+	match := ParseRuleMatch(SplitRuleArgs("-p tcp -m set ! --match-set blocklist src,dst -j DROP"))
+	if len(match.Sets) != 1 || match.Sets[0] != (SetMatch{Name: "blocklist", Flags: "src,dst", Negate: true}) {
+		t.Errorf("unexpected rule match sets: %+v", match.Sets)
+	}
+
+	ruleset := &SavedRuleset{Tables: []SavedTable{{Name: "filter", Rules: []SavedRule{
+		{Table: "filter", Chain: "INPUT", Position: 3, Match: RuleMatch{Sets: []SetMatch{{Name: "blocklist", Flags: "src"}}}},
+	}}}}
+	if refs := ipsetReferences(ruleset); len(refs["blocklist"]) != 1 || refs["blocklist"][0] != "filter/INPUT#3" {
+		t.Errorf("unexpected references: %v", refs)
+	}
+}
@@ -0,0 +1,364 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SavedRuleset iptables-save -c 输出的完整规则集
+type SavedRuleset struct {
+	Tables []SavedTable `json:"tables"`
+}
+
+// SavedTable iptables-save 中的一张表
+type SavedTable struct {
+	Name   string       `json:"name"`
+	Chains []SavedChain `json:"chains"`
+	Rules  []SavedRule  `json:"rules"`
+}
+
+// SavedChain 链及其默认策略计数
+type SavedChain struct {
+	Table   string `json:"table"`
+	Name    string `json:"name"`
+	Policy  string `json:"policy"` // 自定义链为 "-"
+	BuiltIn bool   `json:"built_in"`
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+// SavedRule iptables-save 中的一条规则（带计数器）
+type SavedRule struct {
+	Table    string    `json:"table"`
+	Chain    string    `json:"chain"`
+	Position int       `json:"position"` // 在链中的序号，从1开始
+	Spec     string    `json:"spec"`     // 去掉 -A <chain> 之后的规则参数
+	Key      string    `json:"key"`      // 稳定的规则标识（表、链、规则内容的哈希）
+	Packets  uint64    `json:"packets"`
+	Bytes    uint64    `json:"bytes"`
+	Match    RuleMatch `json:"match"`
+}
+
+// RuleMatch 从规则参数中解析出的常用匹配条件
+type RuleMatch struct {
+	InInterface  string     `json:"in_interface,omitempty"`  // 取反时带 "!" 前缀
+	OutInterface string     `json:"out_interface,omitempty"` // 取反时带 "!" 前缀
+	Protocol     string     `json:"protocol,omitempty"`
+	Source       string     `json:"source,omitempty"`
+	Destination  string     `json:"destination,omitempty"`
+	SourcePort   string     `json:"source_port,omitempty"`
+	DestPort     string     `json:"dest_port,omitempty"`
+	Target       string     `json:"target,omitempty"`
+	TargetArgs   []string   `json:"target_args,omitempty"`
+	Comment      string     `json:"comment,omitempty"`
+	Modules      []string   `json:"modules,omitempty"`
+	CtState      string     `json:"ct_state,omitempty"`
+	Sets         []SetMatch `json:"sets,omitempty"` // -m set --match-set 引用的ipset
+	Goto         bool       `json:"goto,omitempty"` // 使用 -g 而不是 -j
+	Args         []string   `json:"args"`
+}
+
+// SetMatch 规则中的一个 --match-set 条件
+type SetMatch struct {
+	Name   string `json:"name"`
+	Flags  string `json:"flags"` // src、dst 或 src,dst 等
+	Negate bool   `json:"negate,omitempty"`
+}
+
+// Table 按名称获取表
+func (r *SavedRuleset) Table(name string) *SavedTable {
+	for i := range r.Tables {
+		if r.Tables[i].Name == name {
+			return &r.Tables[i]
+		}
+	}
+	return nil
+}
+
+// Chain 按名称获取链
+func (t *SavedTable) Chain(name string) *SavedChain {
+	for i := range t.Chains {
+		if t.Chains[i].Name == name {
+			return &t.Chains[i]
+		}
+	}
+	return nil
+}
+
+// ChainRules 获取链中的规则（按顺序）
+func (t *SavedTable) ChainRules(chain string) []SavedRule {
+	var rules []SavedRule
+	for _, rule := range t.Rules {
+		if rule.Chain == chain {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// LoadSavedRuleset 执行 iptables-save -c 并解析结果
+func LoadSavedRuleset() (*SavedRuleset, error) {
+	output, err := runCommand("iptables-save", "-c")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute iptables-save: %v", err)
+	}
+	return ParseIPTablesSave(string(output))
+}
+
+// ParseIPTablesSave 解析 iptables-save（可带 -c 计数器）的输出
+func ParseIPTablesSave(output string) (*SavedRuleset, error) {
+	ruleset := &SavedRuleset{}
+	var current *SavedTable
+	positions := make(map[string]int)
+	occurrences := make(map[string]int)
+
+	for lineNum, rawLine := range strings.Split(output, "\n") {
+		line := strings.TrimSpace(rawLine)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "*"):
+			ruleset.Tables = append(ruleset.Tables, SavedTable{Name: line[1:]})
+			current = &ruleset.Tables[len(ruleset.Tables)-1]
+			positions = make(map[string]int)
+			occurrences = make(map[string]int)
+
+		case line == "COMMIT":
+			current = nil
+
+		case strings.HasPrefix(line, ":"):
+			if current == nil {
+				return nil, fmt.Errorf("line %d: chain declared outside of table", lineNum+1)
+			}
+			chain, err := parseSavedChain(current.Name, line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNum+1, err)
+			}
+			current.Chains = append(current.Chains, chain)
+
+		default:
+			if current == nil {
+				return nil, fmt.Errorf("line %d: rule outside of table", lineNum+1)
+			}
+			rule, err := parseSavedRule(current.Name, line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNum+1, err)
+			}
+			positions[rule.Chain]++
+			rule.Position = positions[rule.Chain]
+
+			// 同一链中完全相同的规则用出现次数区分
+			baseKey := RuleKey(rule.Table, rule.Chain, rule.Spec)
+			occurrences[baseKey]++
+			rule.Key = baseKey
+			if occurrences[baseKey] > 1 {
+				rule.Key = fmt.Sprintf("%s-%d", baseKey, occurrences[baseKey])
+			}
+			current.Rules = append(current.Rules, rule)
+		}
+	}
+
+	return ruleset, nil
+}
+
+// parseSavedChain 解析链声明行，如 ":INPUT ACCEPT [12:345]"
+func parseSavedChain(table, line string) (SavedChain, error) {
+	fields := strings.Fields(line[1:])
+	if len(fields) < 2 {
+		return SavedChain{}, fmt.Errorf("invalid chain declaration: %s", line)
+	}
+
+	chain := SavedChain{
+		Table:   table,
+		Name:    fields[0],
+		Policy:  fields[1],
+		BuiltIn: fields[1] != "-",
+	}
+	if len(fields) >= 3 {
+		chain.Packets, chain.Bytes = parseSavedCounters(fields[2])
+	}
+	return chain, nil
+}
+
+// parseSavedRule 解析规则行，如 "[10:600] -A INPUT -i lo -j ACCEPT"
+func parseSavedRule(table, line string) (SavedRule, error) {
+	rule := SavedRule{Table: table}
+
+	if strings.HasPrefix(line, "[") {
+		end := strings.Index(line, "]")
+		if end == -1 {
+			return rule, fmt.Errorf("invalid counters: %s", line)
+		}
+		rule.Packets, rule.Bytes = parseSavedCounters(line[:end+1])
+		line = strings.TrimSpace(line[end+1:])
+	}
+
+	if !strings.HasPrefix(line, "-A ") {
+		return rule, fmt.Errorf("unsupported rule line: %s", line)
+	}
+	rest := strings.TrimSpace(line[3:])
+	chainEnd := strings.IndexAny(rest, " \t")
+	if chainEnd == -1 {
+		rule.Chain = rest
+	} else {
+		rule.Chain = rest[:chainEnd]
+		rule.Spec = strings.TrimSpace(rest[chainEnd:])
+	}
+
+	rule.Match = ParseRuleMatch(SplitRuleArgs(rule.Spec))
+	return rule, nil
+}
+
+// parseSavedCounters 解析 "[packets:bytes]" 计数器
+func parseSavedCounters(field string) (uint64, uint64) {
+	field = strings.TrimSuffix(strings.TrimPrefix(field, "["), "]")
+	parts := strings.SplitN(field, ":", 2)
+	if len(parts) != 2 {
+		return 0, 0
+	}
+	packets, _ := strconv.ParseUint(parts[0], 10, 64)
+	bytes, _ := strconv.ParseUint(parts[1], 10, 64)
+	return packets, bytes
+}
+
+// RuleKey 计算规则的稳定标识，规则位置变化时保持不变
+func RuleKey(table, chain, spec string) string {
+	sum := sha1.Sum([]byte(table + "|" + chain + "|" + spec))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// SplitRuleArgs 按 shell 规则拆分规则参数（支持双引号和转义）
+func SplitRuleArgs(spec string) []string {
+	var args []string
+	var current strings.Builder
+	inQuotes := false
+	hasToken := false
+
+	for i := 0; i < len(spec); i++ {
+		ch := spec[i]
+		switch {
+		case ch == '\\' && i+1 < len(spec):
+			i++
+			current.WriteByte(spec[i])
+			hasToken = true
+		case ch == '"':
+			inQuotes = !inQuotes
+			hasToken = true
+		case (ch == ' ' || ch == '\t') && !inQuotes:
+			if hasToken {
+				args = append(args, current.String())
+				current.Reset()
+				hasToken = false
+			}
+		default:
+			current.WriteByte(ch)
+			hasToken = true
+		}
+	}
+	if hasToken {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// JoinRuleArgs 将规则参数拼接为可读的命令行（必要时加引号）
+func JoinRuleArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"\\") {
+			arg = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// ParseRuleMatch 从规则参数中提取常用匹配条件
+func ParseRuleMatch(args []string) RuleMatch {
+	match := RuleMatch{Args: args}
+	negate := false
+
+	value := func(i int) string {
+		if i+1 < len(args) {
+			v := args[i+1]
+			if negate {
+				v = "!" + v
+			}
+			return v
+		}
+		return ""
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "!" {
+			negate = true
+			continue
+		}
+
+		consumed := true
+		switch arg {
+		case "-i", "--in-interface":
+			match.InInterface = value(i)
+		case "-o", "--out-interface":
+			match.OutInterface = value(i)
+		case "-p", "--protocol":
+			match.Protocol = value(i)
+		case "-s", "--source":
+			match.Source = value(i)
+		case "-d", "--destination":
+			match.Destination = value(i)
+		case "--sport", "--source-port", "--sports", "--source-ports":
+			match.SourcePort = value(i)
+		case "--dport", "--destination-port", "--dports", "--destination-ports":
+			match.DestPort = value(i)
+		case "-m", "--match":
+			if i+1 < len(args) {
+				match.Modules = append(match.Modules, args[i+1])
+			}
+		case "--comment":
+			if i+1 < len(args) {
+				match.Comment = args[i+1]
+			}
+		case "--ctstate", "--state":
+			match.CtState = value(i)
+		case "--match-set":
+			if i+2 < len(args) {
+				match.Sets = append(match.Sets, SetMatch{Name: args[i+1], Flags: args[i+2], Negate: negate})
+				i++
+			}
+		case "-j", "--jump", "-g", "--goto":
+			if i+1 < len(args) {
+				match.Target = args[i+1]
+				match.Goto = arg == "-g" || arg == "--goto"
+				match.TargetArgs = append([]string{}, args[i+2:]...)
+			}
+			i = len(args)
+			continue
+		default:
+			consumed = false
+		}
+
+		if consumed {
+			i++
+		}
+		negate = false
+	}
+
+	return match
+}
+
+// TargetOption 获取目标参数中指定选项的值，如 --to-destination
+func (m RuleMatch) TargetOption(name string) string {
+	for i, arg := range m.TargetArgs {
+		if arg == name && i+1 < len(m.TargetArgs) {
+			return m.TargetArgs[i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+const sampleIPTablesSave = `# Generated by iptables-save v1.8.7 on Mon Jan  1 00:00:00 2024
+*filter
+:INPUT ACCEPT [120:9600]
+:FORWARD DROP [3:180]
+:OUTPUT ACCEPT [80:6400]
+:DOCKER - [0:0]
+[10:600] -A INPUT -i lo -j ACCEPT
+[5:300] -A INPUT -p tcp -m tcp --dport 22 -m comment --comment "allow ssh" -j ACCEPT
+[0:0] -A INPUT -p tcp -m tcp --dport 22 -m comment --comment "allow ssh" -j ACCEPT
+[7:420] -A FORWARD ! -i docker0 -o docker0 -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT
+COMMIT
+*nat
+:PREROUTING ACCEPT [0:0]
+:DOCKER - [0:0]
+[2:120] -A DOCKER ! -i docker0 -p tcp -m tcp --dport 8080 -j DNAT --to-destination 172.17.0.2:80
+COMMIT
+`
+
+func TestParseIPTablesSave(t *testing.T) {
+	ruleset, err := ParseIPTablesSave(sampleIPTablesSave)
+	if err != nil {
+		t.Fatalf("ParseIPTablesSave returned error: %v", err)
+	}
+	if len(ruleset.Tables) != 2 {
+		t.Fatalf("expected 2 tables, got %d", len(ruleset.Tables))
+	}
+
+	filter := ruleset.Table("filter")
+	if filter == nil {
+		t.Fatalf("filter table not found")
+	}
+
+	forward := filter.Chain("FORWARD")
+	if forward == nil || forward.Policy != "DROP" || forward.Packets != 3 || forward.Bytes != 180 || !forward.BuiltIn {
+		t.Errorf("unexpected FORWARD chain: %+v", forward)
+	}
+	if docker := filter.Chain("DOCKER"); docker == nil || docker.BuiltIn {
+		t.Errorf("unexpected DOCKER chain: %+v", docker)
+	}
+
+	input := filter.ChainRules("INPUT")
+	if len(input) != 3 {
+		t.Fatalf("expected 3 INPUT rules, got %d", len(input))
+	}
+	if input[1].Position != 2 || input[1].Packets != 5 || input[1].Bytes != 300 {
+		t.Errorf("unexpected counters for INPUT rule 2: %+v", input[1])
+	}
+	if input[1].Match.Comment != "allow ssh" {
+		t.Errorf("expected comment %q, got %q", "allow ssh", input[1].Match.Comment)
+	}
+	if input[1].Key == input[2].Key {
+		t.Errorf("duplicate rules should have distinct keys, both got %s", input[1].Key)
+	}
+	if input[1].Key != RuleKey("filter", "INPUT", input[1].Spec) {
+		t.Errorf("first occurrence key should equal RuleKey, got %s", input[1].Key)
+	}
+
+	nat := ruleset.Table("nat")
+	dnat := nat.ChainRules("DOCKER")[0]
+	if dnat.Match.InInterface != "!docker0" {
+		t.Errorf("expected negated in-interface, got %q", dnat.Match.InInterface)
+	}
+	if got := dnat.Match.TargetOption("--to-destination"); got != "172.17.0.2:80" {
+		t.Errorf("expected DNAT destination 172.17.0.2:80, got %q", got)
+	}
+}
+
+func TestParseIPTablesSaveErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{"rule outside table", "-A INPUT -j ACCEPT\n"},
+		{"chain outside table", ":INPUT ACCEPT [0:0]\n"},
+		{"unsupported rule line", "*filter\n-I INPUT -j ACCEPT\nCOMMIT\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseIPTablesSave(tc.input); err == nil {
+				t.Errorf("expected error for %q", tc.input)
+			}
+		})
+	}
+}
+
+func TestParseRuleMatch(t *testing.T) {
+	testCases := []struct {
+		name     string
+		spec     string
+		expected RuleMatch
+	}{
+		{
+			name: "tcp accept with ports",
+			spec: "-s 10.0.0.0/8 -p tcp -m tcp --sport 1024:65535 --dport 443 -j ACCEPT",
+			expected: RuleMatch{
+				Source:     "10.0.0.0/8",
+				Protocol:   "tcp",
+				SourcePort: "1024:65535",
+				DestPort:   "443",
+				Modules:    []string{"tcp"},
+				Target:     "ACCEPT",
+				TargetArgs: []string{},
+			},
+		},
+		{
+			name: "negated destination with goto",
+			spec: "! -d 192.168.1.0/24 -o eth0 -g CUSTOM",
+			expected: RuleMatch{
+				Destination:  "!192.168.1.0/24",
+				OutInterface: "eth0",
+				Target:       "CUSTOM",
+				Goto:         true,
+				TargetArgs:   []string{},
+			},
+		},
+		{
+			name: "masquerade with state",
+			spec: "-m state --state NEW -j MASQUERADE --random",
+			expected: RuleMatch{
+				Modules:    []string{"state"},
+				CtState:    "NEW",
+				Target:     "MASQUERADE",
+				TargetArgs: []string{"--random"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			args := SplitRuleArgs(tc.spec)
+			tc.expected.Args = args
+			result := ParseRuleMatch(args)
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("ParseRuleMatch(%q) = %+v, expected %+v", tc.spec, result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestSplitRuleArgs(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{`-j ACCEPT`, []string{"-j", "ACCEPT"}},
+		{`-m comment --comment "allow web traffic" -j ACCEPT`, []string{"-m", "comment", "--comment", "allow web traffic", "-j", "ACCEPT"}},
+		{`--comment "say \"hi\""`, []string{"--comment", `say "hi"`}},
+		{`--comment ""`, []string{"--comment", ""}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			result := SplitRuleArgs(tc.input)
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("SplitRuleArgs(%q) = %q, expected %q", tc.input, result, tc.expected)
+			}
+			if roundTrip := SplitRuleArgs(JoinRuleArgs(result)); !reflect.DeepEqual(roundTrip, tc.expected) {
+				t.Errorf("JoinRuleArgs round trip = %q, expected %q", roundTrip, tc.expected)
+			}
+		})
+	}
+}
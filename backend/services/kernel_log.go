@@ -0,0 +1,111 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// followKernelLog 从 /dev/kmsg 读取此后产生的内核日志记录（每条记录一行，含 "级别,序号,时间,-;" 头），ctx 结束时关闭
+func followKernelLog(ctx context.Context) (<-chan string, error) {
+	kmsg, err := os.Open("/dev/kmsg")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open kernel log: %v", err)
+	}
+	if _, err := kmsg.Seek(0, io.SeekEnd); err != nil {
+		kmsg.Close()
+		return nil, fmt.Errorf("failed to seek kernel log: %v", err)
+	}
+
+	lines := make(chan string, 256)
+	go func() {
+		<-ctx.Done()
+		kmsg.Close()
+	}()
+	go func() {
+		defer close(lines)
+		// /dev/kmsg 每次读取返回一条完整记录
+		buffer := make([]byte, 8192)
+		for {
+			n, err := kmsg.Read(buffer)
+			if err != nil {
+				// 读取速度跟不上时记录被覆盖，返回 EPIPE 后可以继续读取
+				if ctx.Err() == nil && strings.Contains(err.Error(), "broken pipe") {
+					continue
+				}
+				return
+			}
+			select {
+			case lines <- string(buffer[:n]):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return lines, nil
+}
+
+// followLogFile 从文件末尾开始读取新写入的行（类似 tail -F），文件被轮转或截断后从头读取新文件，ctx 结束时关闭
+func followLogFile(ctx context.Context, path string, pollInterval time.Duration) (<-chan string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file %s: %v", path, err)
+	}
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to seek log file %s: %v", path, err)
+	}
+
+	lines := make(chan string, 256)
+	go func() {
+		defer close(lines)
+		defer func() { file.Close() }()
+
+		reader := bufio.NewReader(file)
+		partial := ""
+		for {
+			chunk, err := reader.ReadString('\n')
+			offset += int64(len(chunk))
+			if err == nil {
+				select {
+				case lines <- partial + strings.TrimRight(chunk, "\r\n"):
+				case <-ctx.Done():
+					return
+				}
+				partial = ""
+				continue
+			}
+			partial += chunk
+			if err != io.EOF {
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollInterval):
+			}
+
+			// 路径指向新文件（轮转）或文件变短（截断）时重新打开
+			current, statErr := os.Stat(path)
+			opened, openedErr := file.Stat()
+			if statErr != nil || openedErr != nil {
+				continue
+			}
+			if !os.SameFile(current, opened) || current.Size() < offset {
+				next, err := os.Open(path)
+				if err != nil {
+					continue
+				}
+				file.Close()
+				file, reader, offset, partial = next, bufio.NewReader(next), 0, ""
+			}
+		}
+	}()
+	return lines, nil
+}
@@ -0,0 +1,727 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"iptables-management-backend/models"
+)
+
+const (
+	// learningCommentPrefix 学习会话安装的记录规则注释前缀，用于删除和启动时清理残留规则
+	learningCommentPrefix = "iptables-manager-learn:"
+	// learningLogPrefix 记录规则的日志前缀，后接链名，例如 "IPTM-LEARN:INPUT: "
+	learningLogPrefix = "IPTM-LEARN:"
+
+	defaultLearningDuration       = 10 * time.Minute
+	minLearningDuration           = 10 * time.Second
+	maxLearningDuration           = 24 * time.Hour
+	defaultLearningSubnetPrefix   = 24
+	defaultLearningSubnetMinHosts = 2
+	maxLearningFlows              = 10000
+	maxLearningSessions           = 10
+)
+
+// LearningRequest 创建学习会话的请求
+type LearningRequest struct {
+	Chains         []string `json:"chains"`                // filter 表的内置链 INPUT、FORWARD、OUTPUT，默认 INPUT
+	Duration       int      `json:"duration"`              // 秒，默认600，最长86400
+	NFLOGGroup     int      `json:"nflog_group,omitempty"` // 大于0时使用 NFLOG 目标并订阅该组，否则使用 LOG 目标读取内核日志
+	SubnetPrefix   int      `json:"subnet_prefix"`         // 合并来源地址使用的前缀长度，默认24
+	SubnetMinHosts int      `json:"subnet_min_hosts"`      // 同一子网中至少出现多少个地址时合并为子网规则，默认2
+}
+
+// LearningFlow 观察到的一类新建连接（同一链、接口、协议、地址和目标端口）
+type LearningFlow struct {
+	Chain        string    `json:"chain"`
+	InInterface  string    `json:"in_interface,omitempty"`
+	OutInterface string    `json:"out_interface,omitempty"`
+	Protocol     string    `json:"protocol"`
+	Source       string    `json:"source"`
+	Destination  string    `json:"destination"`
+	DestPort     int       `json:"dest_port,omitempty"`
+	Packets      int       `json:"packets"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+// LearningProposal 一条建议的放行规则：一个服务对一个子网或地址
+type LearningProposal struct {
+	ID        int                 `json:"id"`   // 会话内的建议编号，应用时引用
+	Peer      string              `json:"peer"` // 合并后的对端子网或地址（OUTPUT 链为目标，其余为来源）
+	Hosts     []string            `json:"hosts"`
+	Packets   int                 `json:"packets"`
+	FirstSeen time.Time           `json:"first_seen"`
+	LastSeen  time.Time           `json:"last_seen"`
+	Rule      models.IPTablesRule `json:"rule"`
+	RuleID    uint                `json:"rule_id,omitempty"` // 已通过规则服务创建时的规则ID
+}
+
+// LearningServiceGroup 按服务（链、接口、协议、端口）分组的建议
+type LearningServiceGroup struct {
+	Service      string             `json:"service"` // 例如 tcp/22、icmp
+	Chain        string             `json:"chain"`
+	InInterface  string             `json:"in_interface,omitempty"`
+	OutInterface string             `json:"out_interface,omitempty"`
+	Protocol     string             `json:"protocol"`
+	DestPort     int                `json:"dest_port,omitempty"`
+	Destination  string             `json:"destination,omitempty"` // FORWARD 链按目标主机区分服务
+	Packets      int                `json:"packets"`
+	Proposals    []LearningProposal `json:"proposals"`
+}
+
+// LearningSession 一次有时限的学习会话
+type LearningSession struct {
+	ID           string                 `json:"id"`
+	Request      LearningRequest        `json:"request"`
+	Target       string                 `json:"target"` // LOG 或 NFLOG
+	Rules        []string               `json:"rules"`  // 安装的记录规则
+	Status       string                 `json:"status"` // running, completed, stopped, failed
+	Error        string                 `json:"error,omitempty"`
+	Warnings     []string               `json:"warnings,omitempty"`
+	Observed     int                    `json:"observed"`      // 收到的记录数
+	DroppedFlows int                    `json:"dropped_flows"` // 超过流数量上限未统计的记录数
+	Flows        []LearningFlow         `json:"flows"`
+	Services     []LearningServiceGroup `json:"services"`
+	CreatedBy    string                 `json:"created_by"`
+	StartedAt    time.Time              `json:"started_at"`
+	EndsAt       time.Time              `json:"ends_at"`
+	FinishedAt   *time.Time             `json:"finished_at,omitempty"`
+
+	flows    map[learningFlowKey]*LearningFlow
+	applied  map[int]uint // 建议编号 -> 创建的规则ID
+	ruleArgs [][]string
+	cancel   context.CancelFunc
+	stopped  bool
+	done     chan struct{}
+}
+
+// LearningService 学习模式：临时在链的默认策略之前记录新建连接，汇总后生成最小放行规则建议
+type LearningService struct {
+	mutex       sync.Mutex
+	ruleService *RuleService
+	sessions    map[string]*LearningSession
+	order       []string
+}
+
+// NewLearningService 创建学习模式服务实例，建议规则通过规则服务创建
+func NewLearningService(ruleService *RuleService) *LearningService {
+	return &LearningService{
+		ruleService: ruleService,
+		sessions:    make(map[string]*LearningSession),
+	}
+}
+
+// StartSession 安装记录规则并在后台汇总观察到的流量，到期或被停止时自动删除规则
+func (s *LearningService) StartSession(request LearningRequest, username string) (*LearningSession, error) {
+	log.Printf("[DEBUG] LearningService.StartSession called with request: %+v", request)
+
+	duration, err := normalizeLearningRequest(&request)
+	if err != nil {
+		return nil, err
+	}
+	ruleset, err := LoadSavedRuleset()
+	if err != nil {
+		return nil, err
+	}
+	filter := ruleset.Table("filter")
+	if filter == nil {
+		return nil, fmt.Errorf("filter table not found")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, existing := range s.sessions {
+		if existing.Status == "running" {
+			return nil, conflictf("learning session %s is already running", existing.ID)
+		}
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	session := &LearningSession{
+		ID:        hex.EncodeToString(id),
+		Request:   request,
+		Target:    "LOG",
+		Status:    "running",
+		Flows:     []LearningFlow{},
+		Services:  []LearningServiceGroup{},
+		CreatedBy: username,
+		flows:     make(map[learningFlowKey]*LearningFlow),
+		applied:   make(map[int]uint),
+		done:      make(chan struct{}),
+	}
+	source := "kmsg"
+	if request.NFLOGGroup > 0 {
+		session.Target = "NFLOG"
+		source = fmt.Sprintf("nflog:%d", request.NFLOGGroup)
+	}
+	for _, chain := range request.Chains {
+		session.ruleArgs = append(session.ruleArgs, learningRuleArgs(filter.ChainRules(chain), chain, request.NFLOGGroup, learningCommentPrefix+session.ID))
+		session.Warnings = append(session.Warnings, learningChainWarnings(filter, chain)...)
+	}
+	for _, args := range session.ruleArgs {
+		session.Rules = append(session.Rules, "iptables "+JoinRuleArgs(args))
+	}
+
+	// 先开始读取再安装规则，避免丢失最早的记录
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	entries, err := openPacketLogSource(ctx, source)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	installed, err := installLearningRules(session.ruleArgs)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	session.cancel = cancel
+	session.StartedAt = time.Now()
+	session.EndsAt = session.StartedAt.Add(duration)
+	s.store(session)
+
+	go s.collect(ctx, session, entries, func() {
+		removeLearningRules(installed)
+	})
+
+	log.Printf("[INFO] Learning session %s started by %s (%s, %s): %v", session.ID, username, session.Target, duration, session.Rules)
+	return s.snapshot(session), nil
+}
+
+// GetSession 获取会话当前状态，运行中的会话返回截至目前的建议
+func (s *LearningService) GetSession(id string) (*LearningSession, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, notFoundf("learning session %s not found", id)
+	}
+	return s.snapshot(session), nil
+}
+
+// ListSessions 获取最近的会话（最新在前，不含流和建议明细）
+func (s *LearningService) ListSessions() []LearningSession {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	sessions := make([]LearningSession, 0, len(s.order))
+	for i := len(s.order) - 1; i >= 0; i-- {
+		session := *s.sessions[s.order[i]]
+		session.Flows = nil
+		session.Services = nil
+		session.Warnings = append([]string(nil), session.Warnings...)
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// StopSession 提前结束运行中的会话并等待规则删除
+func (s *LearningService) StopSession(id string) (*LearningSession, error) {
+	s.mutex.Lock()
+	session, ok := s.sessions[id]
+	if ok && session.Status == "running" {
+		session.stopped = true
+		session.cancel()
+	}
+	s.mutex.Unlock()
+	if !ok {
+		return nil, notFoundf("learning session %s not found", id)
+	}
+	<-session.done
+	return s.GetSession(id)
+}
+
+// ApplyProposals 通过规则服务创建选中的建议规则，ids 为空时创建全部尚未创建的建议
+func (s *LearningService) ApplyProposals(id string, ids []int) ([]models.IPTablesRule, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, notFoundf("learning session %s not found", id)
+	}
+	if session.Status == "running" {
+		return nil, conflictf("learning session %s is still running", id)
+	}
+
+	proposals := make(map[int]LearningProposal)
+	var all []int
+	for _, group := range s.snapshot(session).Services {
+		for _, proposal := range group.Proposals {
+			proposals[proposal.ID] = proposal
+			if proposal.RuleID == 0 {
+				all = append(all, proposal.ID)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		ids = all
+	}
+	for _, proposalID := range ids {
+		proposal, ok := proposals[proposalID]
+		if !ok {
+			return nil, invalidf("invalid proposal id %d", proposalID)
+		}
+		if proposal.RuleID != 0 {
+			return nil, invalidf("invalid proposal id %d: already applied as rule %d", proposalID, proposal.RuleID)
+		}
+	}
+
+	created := make([]models.IPTablesRule, 0, len(ids))
+	for _, proposalID := range ids {
+		rule := proposals[proposalID].Rule
+		rule.ID = 0
+		if err := s.ruleService.CreateRule(&rule); err != nil {
+			return created, fmt.Errorf("failed to create rule for proposal %d: %v", proposalID, err)
+		}
+		session.applied[proposalID] = rule.ID
+		created = append(created, rule)
+	}
+	log.Printf("[INFO] Learning session %s: created %d proposed rules", id, len(created))
+	return created, nil
+}
+
+// CleanupStaleRules 删除之前进程退出时遗留的记录规则
+func (s *LearningService) CleanupStaleRules() {
+	ruleset, err := LoadSavedRuleset()
+	if err != nil {
+		log.Printf("[WARN] Failed to check for stale learning rules: %v", err)
+		return
+	}
+	table := ruleset.Table("filter")
+	if table == nil {
+		return
+	}
+	var stale [][]string
+	for _, rule := range table.Rules {
+		if strings.HasPrefix(rule.Match.Comment, learningCommentPrefix) {
+			stale = append(stale, append([]string{"-t", "filter", "-A", rule.Chain}, SplitRuleArgs(rule.Spec)...))
+		}
+	}
+	if len(stale) > 0 {
+		log.Printf("[INFO] Removing %d stale learning rules", len(stale))
+		removeLearningRules(stale)
+	}
+}
+
+// store 保存会话，只保留最近的会话
+func (s *LearningService) store(session *LearningSession) {
+	s.sessions[session.ID] = session
+	s.order = append(s.order, session.ID)
+	for len(s.order) > maxLearningSessions {
+		if oldest := s.sessions[s.order[0]]; oldest.Status == "running" {
+			break
+		}
+		delete(s.sessions, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// collect 汇总记录直到会话结束，随后删除记录规则
+func (s *LearningService) collect(ctx context.Context, session *LearningSession, entries <-chan models.PacketLog, cleanup func()) {
+	defer close(session.done)
+
+	for {
+		entry, ok := models.PacketLog{}, false
+		select {
+		case <-ctx.Done():
+		case entry, ok = <-entries:
+		}
+		if !ok {
+			break
+		}
+		s.mutex.Lock()
+		switch recordLearningFlow(session.flows, entry, session.Request.Chains, maxLearningFlows) {
+		case learningFlowRecorded:
+			session.Observed++
+		case learningFlowDropped:
+			session.Observed++
+			session.DroppedFlows++
+		}
+		s.mutex.Unlock()
+	}
+	// 未到期且未停止时来源提前关闭，视为失败
+	sourceClosed := ctx.Err() == nil
+	session.cancel()
+	cleanup()
+	// 读取剩余记录，使来源在 ctx 结束后能够退出
+	go func() {
+		for range entries {
+		}
+	}()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	session.FinishedAt = &now
+	switch {
+	case session.stopped:
+		session.Status = "stopped"
+	case sourceClosed:
+		session.Status = "failed"
+		session.Error = "log source closed unexpectedly"
+	default:
+		session.Status = "completed"
+	}
+	log.Printf("[INFO] Learning session %s %s: %d packets, %d flows", session.ID, session.Status, session.Observed, len(session.flows))
+}
+
+// snapshot 会话的副本，包含按当前流生成的建议（调用方需持有锁）
+func (s *LearningService) snapshot(session *LearningSession) *LearningSession {
+	copied := *session
+	copied.Warnings = append([]string(nil), session.Warnings...)
+	copied.Flows = sortedLearningFlows(session.flows)
+	copied.Services = proposeLearningRules(copied.Flows, session.Request.SubnetPrefix, session.Request.SubnetMinHosts)
+	for i := range copied.Services {
+		for j := range copied.Services[i].Proposals {
+			proposal := &copied.Services[i].Proposals[j]
+			proposal.Rule.RuleText = s.ruleService.generateRuleText(&proposal.Rule)
+			proposal.RuleID = session.applied[proposal.ID]
+		}
+	}
+	return &copied
+}
+
+// normalizeLearningRequest 校验请求并填充默认值，返回会话时长
+func normalizeLearningRequest(request *LearningRequest) (time.Duration, error) {
+	if len(request.Chains) == 0 {
+		request.Chains = []string{"INPUT"}
+	}
+	seen := make(map[string]bool)
+	var chains []string
+	for _, chain := range request.Chains {
+		chain = strings.ToUpper(strings.TrimSpace(chain))
+		if chain != "INPUT" && chain != "FORWARD" && chain != "OUTPUT" {
+			return 0, invalidf("invalid chain %q: expected INPUT, FORWARD or OUTPUT", chain)
+		}
+		if !seen[chain] {
+			seen[chain] = true
+			chains = append(chains, chain)
+		}
+	}
+	request.Chains = chains
+
+	duration := defaultLearningDuration
+	if request.Duration != 0 {
+		duration = time.Duration(request.Duration) * time.Second
+		if duration < minLearningDuration || duration > maxLearningDuration {
+			return 0, invalidf("invalid duration %d: must be between %d and %d seconds", request.Duration, int(minLearningDuration.Seconds()), int(maxLearningDuration.Seconds()))
+		}
+	}
+	request.Duration = int(duration.Seconds())
+
+	if request.NFLOGGroup < 0 || request.NFLOGGroup > 65535 {
+		return 0, invalidf("invalid nflog group %d", request.NFLOGGroup)
+	}
+	if request.SubnetPrefix == 0 {
+		request.SubnetPrefix = defaultLearningSubnetPrefix
+	}
+	if request.SubnetPrefix < 8 || request.SubnetPrefix > 32 {
+		return 0, invalidf("invalid subnet prefix %d: must be between 8 and 32", request.SubnetPrefix)
+	}
+	if request.SubnetMinHosts == 0 {
+		request.SubnetMinHosts = defaultLearningSubnetMinHosts
+	}
+	if request.SubnetMinHosts < 1 || request.SubnetMinHosts > 256 {
+		return 0, invalidf("invalid subnet min hosts %d: must be between 1 and 256", request.SubnetMinHosts)
+	}
+	return duration, nil
+}
+
+// learningRuleArgs 生成记录规则：只记录新建连接并限速；链末尾是无条件的终结规则时插入到它之前，否则追加到默认策略之前
+func learningRuleArgs(chainRules []SavedRule, chain string, nflogGroup int, comment string) []string {
+	args := []string{"-t", "filter", "-A", chain}
+	if n := len(chainRules); n > 0 {
+		last := chainRules[n-1]
+		switch last.Match.Target {
+		case "ACCEPT", "DROP", "REJECT":
+			if ruleConditions(last) == "" {
+				args = []string{"-t", "filter", "-I", chain, strconv.Itoa(last.Position)}
+			}
+		}
+	}
+	args = append(args,
+		"-m", "conntrack", "--ctstate", "NEW",
+		"-m", "limit", "--limit", "50/second", "--limit-burst", "100",
+		"-m", "comment", "--comment", comment)
+	prefix := learningLogPrefix + chain + ": "
+	if nflogGroup > 0 {
+		return append(args, "-j", "NFLOG", "--nflog-group", strconv.Itoa(nflogGroup), "--nflog-prefix", prefix)
+	}
+	return append(args, "-j", "LOG", "--log-prefix", prefix)
+}
+
+// learningChainWarnings 检查链是否放行已建立的连接：记录规则只记录新建连接，建议规则依赖这类规则放行回应流量
+func learningChainWarnings(table *SavedTable, chain string) []string {
+	for _, rule := range table.ChainRules(chain) {
+		if rule.Match.Target == "ACCEPT" && strings.Contains(rule.Match.CtState, "ESTABLISHED") {
+			return nil
+		}
+	}
+	if info := table.Chain(chain); info != nil && info.Policy == "ACCEPT" {
+		return []string{fmt.Sprintf("链 %s 没有放行 ESTABLISHED 连接的规则，收紧默认策略前需要先添加该规则，否则建议规则无法放行回应流量", chain)}
+	}
+	return []string{fmt.Sprintf("链 %s 没有放行 ESTABLISHED 连接的规则，建议规则只覆盖新建连接", chain)}
+}
+
+// installLearningRules 安装记录规则，失败时撤销已安装的规则；返回用于删除的参数
+func installLearningRules(rules [][]string) ([][]string, error) {
+	var installed [][]string
+	for _, args := range rules {
+		if _, err := runCommand("iptables", args...); err != nil {
+			removeLearningRules(installed)
+			return nil, fmt.Errorf("failed to install learning rule: %s", commandError(err))
+		}
+		installed = append(installed, args)
+	}
+	return installed, nil
+}
+
+// removeLearningRules 删除记录规则
+func removeLearningRules(rules [][]string) {
+	for _, args := range rules {
+		deleteArgs := traceDeleteArgs(args)
+		if _, err := runCommand("iptables", deleteArgs...); err != nil {
+			log.Printf("[ERROR] Failed to remove learning rule %v: %s", deleteArgs, commandError(err))
+		}
+	}
+}
+
+// 记录一条日志的结果
+const (
+	learningFlowIgnored = iota
+	learningFlowRecorded
+	learningFlowDropped
+)
+
+// learningFlowKey 流的汇总键
+type learningFlowKey struct {
+	chain, in, out, protocol, source, destination string
+	destPort                                      int
+}
+
+// recordLearningFlow 把一条学习规则产生的日志计入对应的流；流数量达到上限后新的流不再统计
+func recordLearningFlow(flows map[learningFlowKey]*LearningFlow, entry models.PacketLog, chains []string, limit int) int {
+	if !strings.HasPrefix(entry.Prefix, learningLogPrefix) || entry.SrcIP == "" || entry.DstIP == "" {
+		return learningFlowIgnored
+	}
+	chain := strings.TrimSuffix(strings.TrimPrefix(entry.Prefix, learningLogPrefix), ":")
+	known := false
+	for _, name := range chains {
+		known = known || name == chain
+	}
+	if !known {
+		return learningFlowIgnored
+	}
+
+	key := learningFlowKey{chain, entry.InInterface, entry.OutInterface, entry.Protocol, entry.SrcIP, entry.DstIP, 0}
+	if entry.Protocol == "tcp" || entry.Protocol == "udp" {
+		key.destPort = entry.DstPort
+	}
+	flow, ok := flows[key]
+	if !ok {
+		if len(flows) >= limit {
+			return learningFlowDropped
+		}
+		flow = &LearningFlow{
+			Chain:        key.chain,
+			InInterface:  key.in,
+			OutInterface: key.out,
+			Protocol:     key.protocol,
+			Source:       key.source,
+			Destination:  key.destination,
+			DestPort:     key.destPort,
+			FirstSeen:    entry.Timestamp,
+		}
+		flows[key] = flow
+	}
+	flow.Packets++
+	flow.LastSeen = entry.Timestamp
+	return learningFlowRecorded
+}
+
+// sortedLearningFlows 按记录数从多到少排列的流
+func sortedLearningFlows(flows map[learningFlowKey]*LearningFlow) []LearningFlow {
+	sorted := make([]LearningFlow, 0, len(flows))
+	for _, flow := range flows {
+		sorted = append(sorted, *flow)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Packets != sorted[j].Packets {
+			return sorted[i].Packets > sorted[j].Packets
+		}
+		return learningFlowLabel(sorted[i]) < learningFlowLabel(sorted[j])
+	})
+	return sorted
+}
+
+// learningFlowLabel 流的可读描述，用于稳定排序
+func learningFlowLabel(flow LearningFlow) string {
+	return fmt.Sprintf("%s %s>%s %s %s>%s:%d", flow.Chain, flow.InInterface, flow.OutInterface, flow.Protocol, flow.Source, flow.Destination, flow.DestPort)
+}
+
+// proposeLearningRules 按服务分组流量并合并对端地址：INPUT 和 FORWARD 合并来源地址，OUTPUT 合并目标地址；
+// 同一子网中出现的地址数达到 minHosts 时生成子网规则，否则每个地址一条规则
+func proposeLearningRules(flows []LearningFlow, subnetPrefix, minHosts int) []LearningServiceGroup {
+	type peerStat struct {
+		packets   int
+		firstSeen time.Time
+		lastSeen  time.Time
+	}
+	type serviceStat struct {
+		group LearningServiceGroup
+		peers map[string]*peerStat
+	}
+
+	// 服务键：链、接口、协议、端口，FORWARD 链还包括目标地址；对端地址不属于服务键
+	services := make(map[learningFlowKey]*serviceStat)
+	var order []learningFlowKey
+	for _, flow := range flows {
+		key := learningFlowKey{chain: flow.Chain, protocol: flow.Protocol, destPort: flow.DestPort}
+		peer := flow.Source
+		switch flow.Chain {
+		case "INPUT":
+			key.in = flow.InInterface
+		case "OUTPUT":
+			key.out = flow.OutInterface
+			peer = flow.Destination
+		default:
+			key.in = flow.InInterface
+			key.out = flow.OutInterface
+			key.destination = flow.Destination
+		}
+		stat, ok := services[key]
+		if !ok {
+			stat = &serviceStat{peers: make(map[string]*peerStat)}
+			stat.group = LearningServiceGroup{
+				Service:      flow.Protocol,
+				Chain:        key.chain,
+				InInterface:  key.in,
+				OutInterface: key.out,
+				Protocol:     key.protocol,
+				DestPort:     key.destPort,
+				Destination:  key.destination,
+			}
+			if flow.DestPort > 0 {
+				stat.group.Service = fmt.Sprintf("%s/%d", flow.Protocol, flow.DestPort)
+			}
+			services[key] = stat
+			order = append(order, key)
+		}
+		stat.group.Packets += flow.Packets
+		p, ok := stat.peers[peer]
+		if !ok {
+			p = &peerStat{firstSeen: flow.FirstSeen}
+			stat.peers[peer] = p
+		}
+		p.packets += flow.Packets
+		if flow.FirstSeen.Before(p.firstSeen) {
+			p.firstSeen = flow.FirstSeen
+		}
+		if flow.LastSeen.After(p.lastSeen) {
+			p.lastSeen = flow.LastSeen
+		}
+	}
+
+	groups := make([]LearningServiceGroup, 0, len(order))
+	for _, key := range order {
+		stat := services[key]
+
+		// 按子网归并对端地址
+		subnets := make(map[string][]string)
+		var subnetOrder []string
+		for peer := range stat.peers {
+			subnet := peer
+			if ip := net.ParseIP(peer).To4(); ip != nil {
+				subnet = (&net.IPNet{IP: ip.Mask(net.CIDRMask(subnetPrefix, 32)), Mask: net.CIDRMask(subnetPrefix, 32)}).String()
+			}
+			if _, ok := subnets[subnet]; !ok {
+				subnetOrder = append(subnetOrder, subnet)
+			}
+			subnets[subnet] = append(subnets[subnet], peer)
+		}
+
+		group := stat.group
+		for _, subnet := range subnetOrder {
+			hosts := subnets[subnet]
+			sort.Strings(hosts)
+			peers := [][]string{hosts}
+			names := []string{subnet}
+			if len(hosts) < minHosts || subnetPrefix == 32 {
+				peers, names = nil, nil
+				for _, host := range hosts {
+					peers = append(peers, []string{host})
+					names = append(names, host)
+				}
+			}
+			for i, members := range peers {
+				proposal := LearningProposal{Peer: names[i], Hosts: members}
+				for _, host := range members {
+					p := stat.peers[host]
+					proposal.Packets += p.packets
+					if proposal.FirstSeen.IsZero() || p.firstSeen.Before(proposal.FirstSeen) {
+						proposal.FirstSeen = p.firstSeen
+					}
+					if p.lastSeen.After(proposal.LastSeen) {
+						proposal.LastSeen = p.lastSeen
+					}
+				}
+				proposal.Rule = learningProposalRule(group, proposal.Peer)
+				group.Proposals = append(group.Proposals, proposal)
+			}
+		}
+		sort.Slice(group.Proposals, func(i, j int) bool {
+			if group.Proposals[i].Packets != group.Proposals[j].Packets {
+				return group.Proposals[i].Packets > group.Proposals[j].Packets
+			}
+			return group.Proposals[i].Peer < group.Proposals[j].Peer
+		})
+		groups = append(groups, group)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Chain != groups[j].Chain {
+			return groups[i].Chain < groups[j].Chain
+		}
+		return groups[i].Packets > groups[j].Packets
+	})
+	id := 0
+	for i := range groups {
+		for j := range groups[i].Proposals {
+			id++
+			groups[i].Proposals[j].ID = id
+		}
+	}
+	return groups
+}
+
+// learningProposalRule 生成建议的放行规则
+func learningProposalRule(group LearningServiceGroup, peer string) models.IPTablesRule {
+	rule := models.IPTablesRule{
+		Table:         "filter",
+		ChainName:     group.Chain,
+		Target:        "ACCEPT",
+		Protocol:      group.Protocol,
+		DestinationIP: group.Destination,
+		InterfaceIn:   group.InInterface,
+		InterfaceOut:  group.OutInterface,
+	}
+	if group.Chain == "OUTPUT" {
+		rule.DestinationIP = peer
+	} else {
+		rule.SourceIP = peer
+	}
+	if group.DestPort > 0 {
+		rule.DestPort = strconv.Itoa(group.DestPort)
+	}
+	return rule
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"iptables-management-backend/models"
+)
+
+func TestNormalizeLearningRequest(t *testing.T) {
+	request := LearningRequest{Chains: []string{"input", "FORWARD", "INPUT"}}
+	duration, err := normalizeLearningRequest(&request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if duration != defaultLearningDuration || request.Duration != 600 {
+		t.Errorf("duration = %s, request.Duration = %d", duration, request.Duration)
+	}
+	if !reflect.DeepEqual(request.Chains, []string{"INPUT", "FORWARD"}) || request.SubnetPrefix != 24 || request.SubnetMinHosts != 2 {
+		t.Errorf("unexpected normalized request: %+v", request)
+	}
+
+	request = LearningRequest{}
+	if _, err := normalizeLearningRequest(&request); err != nil || !reflect.DeepEqual(request.Chains, []string{"INPUT"}) {
+		t.Errorf("default chains = %v, err = %v", request.Chains, err)
+	}
+
+	invalid := []LearningRequest{
+		{Chains: []string{"DOCKER-USER"}},
+		{Duration: 5},
+		{Duration: 100000},
+		{NFLOGGroup: 70000},
+		{SubnetPrefix: 4},
+		{SubnetMinHosts: 1000},
+	}
+	for _, request := range invalid {
+		var validation *ValidationError
+		if _, err := normalizeLearningRequest(&request); !errors.As(err, &validation) {
+			t.Errorf("expected validation error for %+v, got %v", request, err)
+		}
+	}
+}
+
+func TestApplyProposalsErrors(t *testing.T) {
+	service := NewLearningService(NewRuleService())
+	service.sessions["running"] = &LearningSession{ID: "running", Status: "running"}
+	service.sessions["done"] = &LearningSession{ID: "done", Status: "completed"}
+
+	var notFound *NotFoundError
+	if _, err := service.ApplyProposals("missing", nil); !errors.As(err, &notFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	var conflict *ConflictError
+	if _, err := service.ApplyProposals("running", nil); !errors.As(err, &conflict) {
+		t.Errorf("expected conflict error, got %v", err)
+	}
+	var validation *ValidationError
+	if _, err := service.ApplyProposals("done", []int{42}); !errors.As(err, &validation) {
+		t.Errorf("expected validation error, got %v", err)
+	}
+}
+
+func TestLearningRuleArgs(t *testing.T) {
+	ruleset, err := ParseIPTablesSave(`*filter
+:INPUT ACCEPT [0:0]
+:FORWARD DROP [0:0]
+:OUTPUT ACCEPT [0:0]
+-A INPUT -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT
+-A INPUT -i lo -j ACCEPT
+-A INPUT -j DROP
+-A FORWARD -i docker0 -j ACCEPT
+COMMIT
+`)
+	if err != nil {
+		t.Fatalf("ParseIPTablesSave returned error: %v", err)
+	}
+	filter := ruleset.Table("filter")
+
+	got := learningRuleArgs(filter.ChainRules("INPUT"), "INPUT", 0, "iptables-manager-learn:abc")
+	want := []string{"-t", "filter", "-I", "INPUT", "3",
+		"-m", "conntrack", "--ctstate", "NEW", "-m", "limit", "--limit", "50/second", "--limit-burst", "100",
+		"-m", "comment", "--comment", "iptables-manager-learn:abc", "-j", "LOG", "--log-prefix", "IPTM-LEARN:INPUT: "}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("INPUT args = %v, want %v", got, want)
+	}
+
+	got = learningRuleArgs(filter.ChainRules("FORWARD"), "FORWARD", 5, "iptables-manager-learn:abc")
+	if got[2] != "-A" || got[3] != "FORWARD" || !reflect.DeepEqual(got[len(got)-6:], []string{"-j", "NFLOG", "--nflog-group", "5", "--nflog-prefix", "IPTM-LEARN:FORWARD: "}) {
+		t.Errorf("FORWARD args = %v", got)
+	}
+	if deleteArgs := traceDeleteArgs(learningRuleArgs(filter.ChainRules("INPUT"), "INPUT", 0, "c")); deleteArgs[2] != "-D" || deleteArgs[4] != "-m" {
+		t.Errorf("delete args = %v", deleteArgs)
+	}
+
+	if warnings := learningChainWarnings(filter, "INPUT"); len(warnings) != 0 {
+		t.Errorf("unexpected INPUT warnings: %v", warnings)
+	}
+	if warnings := learningChainWarnings(filter, "FORWARD"); len(warnings) != 1 {
+		t.Errorf("expected FORWARD warning, got %v", warnings)
+	}
+}
+
+func TestRecordLearningFlow(t *testing.T) {
+	flows := make(map[learningFlowKey]*LearningFlow)
+	now := time.Now()
+	entry := models.PacketLog{Timestamp: now, Prefix: "IPTM-LEARN:INPUT:", InInterface: "eth0", Protocol: "tcp", SrcIP: "10.0.0.5", DstIP: "10.0.0.1", SrcPort: 40000, DstPort: 22}
+
+	chains := []string{"INPUT"}
+	if result := recordLearningFlow(flows, entry, chains, 2); result != learningFlowRecorded {
+		t.Fatalf("result = %d", result)
+	}
+	entry.SrcPort = 40001
+	entry.Timestamp = now.Add(time.Second)
+	recordLearningFlow(flows, entry, chains, 2)
+	if len(flows) != 1 {
+		t.Fatalf("expected source port to be ignored, got %d flows", len(flows))
+	}
+	for _, flow := range flows {
+		if flow.Packets != 2 || !flow.FirstSeen.Equal(now) || !flow.LastSeen.Equal(now.Add(time.Second)) || flow.DestPort != 22 {
+			t.Errorf("unexpected flow: %+v", flow)
+		}
+	}
+
+	other := entry
+	other.SrcIP = "10.0.0.6"
+	recordLearningFlow(flows, other, chains, 2)
+	other.SrcIP = "10.0.0.7"
+	if result := recordLearningFlow(flows, other, chains, 2); result != learningFlowDropped {
+		t.Errorf("expected flow limit to drop new flow, got %d", result)
+	}
+
+	ignored := []models.PacketLog{
+		{Prefix: "DROP-INPUT:", SrcIP: "1.1.1.1", DstIP: "2.2.2.2"},
+		{Prefix: "IPTM-LEARN:OUTPUT:", SrcIP: "1.1.1.1", DstIP: "2.2.2.2"},
+		{Prefix: "IPTM-LEARN:INPUT:"},
+	}
+	for _, entry := range ignored {
+		if result := recordLearningFlow(flows, entry, chains, 10); result != learningFlowIgnored {
+			t.Errorf("expected %+v to be ignored, got %d", entry, result)
+		}
+	}
+}
+
+func TestProposeLearningRules(t *testing.T) {
+	now := time.Now()
+	flow := func(chain, in, out, protocol, src, dst string, port, packets int) LearningFlow {
+		return LearningFlow{Chain: chain, InInterface: in, OutInterface: out, Protocol: protocol, Source: src, Destination: dst,
+			DestPort: port, Packets: packets, FirstSeen: now, LastSeen: now}
+	}
+	flows := []LearningFlow{
+		flow("INPUT", "eth0", "", "tcp", "192.168.1.10", "192.168.1.1", 22, 10),
+		flow("INPUT", "eth0", "", "tcp", "192.168.1.11", "192.168.1.1", 22, 5),
+		flow("INPUT", "eth0", "", "tcp", "203.0.113.7", "192.168.1.1", 22, 1),
+		flow("INPUT", "eth0", "", "tcp", "192.168.1.10", "192.168.1.2", 22, 1), // 另一个本机地址，同一服务
+		flow("INPUT", "eth0", "", "icmp", "192.168.1.10", "192.168.1.1", 0, 3),
+		flow("FORWARD", "wg0", "docker0", "tcp", "10.8.0.2", "172.17.0.2", 80, 4),
+		flow("OUTPUT", "", "eth0", "udp", "192.168.1.1", "1.1.1.1", 53, 8),
+	}
+
+	groups := proposeLearningRules(flows, 24, 2)
+	if len(groups) != 4 {
+		t.Fatalf("got %d service groups, want 4: %+v", len(groups), groups)
+	}
+	if groups[0].Chain != "FORWARD" || groups[1].Service != "tcp/22" || groups[2].Service != "icmp" || groups[3].Chain != "OUTPUT" {
+		t.Errorf("unexpected group order: %s %s, %s, %s", groups[0].Chain, groups[0].Service, groups[1].Service, groups[2].Service)
+	}
+
+	ssh := groups[1]
+	if ssh.Packets != 17 || len(ssh.Proposals) != 2 {
+		t.Fatalf("unexpected ssh group: %+v", ssh)
+	}
+	if ssh.Proposals[0].Peer != "192.168.1.0/24" || ssh.Proposals[0].Packets != 16 || !reflect.DeepEqual(ssh.Proposals[0].Hosts, []string{"192.168.1.10", "192.168.1.11"}) {
+		t.Errorf("unexpected subnet proposal: %+v", ssh.Proposals[0])
+	}
+	want := models.IPTablesRule{Table: "filter", ChainName: "INPUT", Target: "ACCEPT", Protocol: "tcp", SourceIP: "192.168.1.0/24", InterfaceIn: "eth0", DestPort: "22"}
+	if !reflect.DeepEqual(ssh.Proposals[0].Rule, want) {
+		t.Errorf("rule = %+v, want %+v", ssh.Proposals[0].Rule, want)
+	}
+	if ssh.Proposals[1].Peer != "203.0.113.7" || ssh.Proposals[1].Rule.SourceIP != "203.0.113.7" {
+		t.Errorf("expected single host proposal, got %+v", ssh.Proposals[1])
+	}
+
+	forward := groups[0].Proposals[0].Rule
+	if forward.InterfaceIn != "wg0" || forward.InterfaceOut != "docker0" || forward.DestinationIP != "172.17.0.2" || forward.SourceIP != "10.8.0.2" {
+		t.Errorf("unexpected FORWARD rule: %+v", forward)
+	}
+	output := groups[3].Proposals[0].Rule
+	if output.InterfaceOut != "eth0" || output.DestinationIP != "1.1.1.1" || output.SourceIP != "" || output.DestPort != "53" {
+		t.Errorf("unexpected OUTPUT rule: %+v", output)
+	}
+
+	var ids []int
+	for _, group := range groups {
+		for _, proposal := range group.Proposals {
+			ids = append(ids, proposal.ID)
+		}
+	}
+	if !reflect.DeepEqual(ids, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("proposal ids = %v", ids)
+	}
+
+	if hosts := proposeLearningRules(flows, 32, 2)[1].Proposals; len(hosts) != 3 {
+		t.Errorf("expected one proposal per host with /32, got %+v", hosts)
+	}
+}
@@ -28,7 +28,17 @@ func (s *LogService) GetLogsByUser(username string) ([]models.OperationLog, erro
 
 // CreateLog 创建操作日志
 func (s *LogService) CreateLog(log *models.OperationLog) error {
-	return config.DB.Create(log).Error
+	if err := config.DB.Create(log).Error; err != nil {
+		return err
+	}
+
+	// 推送给实时事件订阅者
+	DefaultEventHub.Publish(StreamEvent{
+		Type:      EventTypeOperationLog,
+		Timestamp: log.Timestamp,
+		Data:      log,
+	})
+	return nil
 }
 
 // LogOperation 记录操作日志的便捷方法
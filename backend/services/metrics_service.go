@@ -0,0 +1,226 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+type MetricsService struct {
+	networkService *NetworkService
+}
+
+// NewMetricsService 创建指标服务实例
+func NewMetricsService(networkService *NetworkService) *MetricsService {
+	return &MetricsService{
+		networkService: networkService,
+	}
+}
+
+// metricFamily Prometheus指标族
+type metricFamily struct {
+	name    string
+	help    string
+	kind    string // counter, gauge, summary
+	samples []metricSample
+}
+
+// metricSample 单个指标样本（summary的 _sum/_count 样本通过suffix区分）
+type metricSample struct {
+	suffix string
+	labels [][2]string
+	value  float64
+}
+
+func (f *metricFamily) add(value float64, labels ...string) {
+	f.addSuffixed("", value, labels...)
+}
+
+// addSuffixed 添加带名称后缀的样本，如 summary 的 _sum 和 _count
+func (f *metricFamily) addSuffixed(suffix string, value float64, labels ...string) {
+	sample := metricSample{suffix: suffix, value: value}
+	for i := 0; i+1 < len(labels); i += 2 {
+		sample.labels = append(sample.labels, [2]string{labels[i], labels[i+1]})
+	}
+	f.samples = append(f.samples, sample)
+}
+
+// RenderPrometheus 以Prometheus文本格式输出所有指标
+func (s *MetricsService) RenderPrometheus() string {
+	start := time.Now()
+	var families []*metricFamily
+
+	families = append(families, s.collectRuleMetrics()...)
+	families = append(families, s.collectInterfaceMetrics()...)
+	families = append(families, s.collectCommandMetrics()...)
+
+	scrape := &metricFamily{
+		name: "iptables_manager_scrape_duration_seconds",
+		help: "Time spent collecting metrics.",
+		kind: "gauge",
+	}
+	scrape.add(time.Since(start).Seconds())
+	families = append(families, scrape)
+
+	var builder strings.Builder
+	for _, family := range families {
+		writeMetricFamily(&builder, family)
+	}
+	return builder.String()
+}
+
+// collectRuleMetrics 收集规则计数器和链策略计数器
+func (s *MetricsService) collectRuleMetrics() []*metricFamily {
+	up := &metricFamily{
+		name: "iptables_manager_iptables_up",
+		help: "Whether the last iptables-save call succeeded.",
+		kind: "gauge",
+	}
+	rulePackets := &metricFamily{
+		name: "iptables_rule_packets_total",
+		help: "Packets matched by an iptables rule.",
+		kind: "counter",
+	}
+	ruleBytes := &metricFamily{
+		name: "iptables_rule_bytes_total",
+		help: "Bytes matched by an iptables rule.",
+		kind: "counter",
+	}
+	policyPackets := &metricFamily{
+		name: "iptables_chain_policy_packets_total",
+		help: "Packets handled by the default policy of a built-in chain.",
+		kind: "counter",
+	}
+	policyBytes := &metricFamily{
+		name: "iptables_chain_policy_bytes_total",
+		help: "Bytes handled by the default policy of a built-in chain.",
+		kind: "counter",
+	}
+	chainRules := &metricFamily{
+		name: "iptables_chain_rules",
+		help: "Number of rules in a chain.",
+		kind: "gauge",
+	}
+
+	ruleset, err := LoadSavedRuleset()
+	if err != nil {
+		log.Printf("[WARN] Failed to load ruleset for metrics: %v", err)
+		up.add(0)
+		return []*metricFamily{up}
+	}
+	up.add(1)
+
+	for _, table := range ruleset.Tables {
+		ruleCounts := make(map[string]int)
+		for _, rule := range table.Rules {
+			ruleCounts[rule.Chain]++
+
+			ruleLabel := rule.Match.Comment
+			if ruleLabel == "" {
+				ruleLabel = rule.Key
+			}
+			labels := []string{
+				"table", table.Name,
+				"chain", rule.Chain,
+				"rule", ruleLabel,
+				"rule_hash", rule.Key,
+				"target", rule.Match.Target,
+			}
+			rulePackets.add(float64(rule.Packets), labels...)
+			ruleBytes.add(float64(rule.Bytes), labels...)
+		}
+
+		for _, chain := range table.Chains {
+			chainRules.add(float64(ruleCounts[chain.Name]), "table", table.Name, "chain", chain.Name)
+			if !chain.BuiltIn {
+				continue
+			}
+			policyPackets.add(float64(chain.Packets), "table", table.Name, "chain", chain.Name, "policy", chain.Policy)
+			policyBytes.add(float64(chain.Bytes), "table", table.Name, "chain", chain.Name, "policy", chain.Policy)
+		}
+	}
+
+	return []*metricFamily{up, rulePackets, ruleBytes, policyPackets, policyBytes, chainRules}
+}
+
+// collectInterfaceMetrics 收集 /proc/net/dev 接口统计
+func (s *MetricsService) collectInterfaceMetrics() []*metricFamily {
+	rxBytes := &metricFamily{name: "network_interface_receive_bytes_total", help: "Bytes received by a network interface.", kind: "counter"}
+	txBytes := &metricFamily{name: "network_interface_transmit_bytes_total", help: "Bytes transmitted by a network interface.", kind: "counter"}
+	rxPackets := &metricFamily{name: "network_interface_receive_packets_total", help: "Packets received by a network interface.", kind: "counter"}
+	txPackets := &metricFamily{name: "network_interface_transmit_packets_total", help: "Packets transmitted by a network interface.", kind: "counter"}
+	rxErrors := &metricFamily{name: "network_interface_receive_errors_total", help: "Receive errors on a network interface.", kind: "counter"}
+	txErrors := &metricFamily{name: "network_interface_transmit_errors_total", help: "Transmit errors on a network interface.", kind: "counter"}
+
+	allStats, err := s.networkService.GetAllInterfaceStats()
+	if err != nil {
+		log.Printf("[WARN] Failed to load interface stats for metrics: %v", err)
+		return nil
+	}
+
+	names := make([]string, 0, len(allStats))
+	for name := range allStats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		stats := allStats[name]
+		labels := []string{"interface", name, "type", s.networkService.getInterfaceType(name)}
+		rxBytes.add(float64(stats.RxBytes), labels...)
+		txBytes.add(float64(stats.TxBytes), labels...)
+		rxPackets.add(float64(stats.RxPackets), labels...)
+		txPackets.add(float64(stats.TxPackets), labels...)
+		rxErrors.add(float64(stats.RxErrors), labels...)
+		txErrors.add(float64(stats.TxErrors), labels...)
+	}
+
+	return []*metricFamily{rxBytes, txBytes, rxPackets, txPackets, rxErrors, txErrors}
+}
+
+// collectCommandMetrics 收集外部命令（iptables等）的耗时和错误统计
+func (s *MetricsService) collectCommandMetrics() []*metricFamily {
+	duration := &metricFamily{name: "iptables_manager_command_duration_seconds", help: "Time spent running external commands.", kind: "summary"}
+	lastDuration := &metricFamily{name: "iptables_manager_command_last_duration_seconds", help: "Duration of the most recent external command invocation.", kind: "gauge"}
+	errors := &metricFamily{name: "iptables_manager_command_errors_total", help: "Number of failed external command invocations.", kind: "counter"}
+
+	for _, stats := range GetCommandStats() {
+		duration.addSuffixed("_sum", stats.TotalSeconds, "command", stats.Command)
+		duration.addSuffixed("_count", float64(stats.Count), "command", stats.Command)
+		lastDuration.add(stats.LastSeconds, "command", stats.Command)
+		errors.add(float64(stats.Errors), "command", stats.Command)
+	}
+
+	return []*metricFamily{duration, lastDuration, errors}
+}
+
+// writeMetricFamily 写出一个指标族
+func writeMetricFamily(builder *strings.Builder, family *metricFamily) {
+	if len(family.samples) == 0 {
+		return
+	}
+
+	fmt.Fprintf(builder, "# HELP %s %s\n", family.name, family.help)
+	fmt.Fprintf(builder, "# TYPE %s %s\n", family.name, family.kind)
+	for _, sample := range family.samples {
+		builder.WriteString(family.name + sample.suffix)
+		if len(sample.labels) > 0 {
+			builder.WriteString("{")
+			for i, label := range sample.labels {
+				if i > 0 {
+					builder.WriteString(",")
+				}
+				fmt.Fprintf(builder, "%s=\"%s\"", label[0], escapeLabelValue(label[1]))
+			}
+			builder.WriteString("}")
+		}
+		fmt.Fprintf(builder, " %v\n", sample.value)
+	}
+}
+
+// escapeLabelValue 转义Prometheus标签值
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderPrometheusCommandSummary(t *testing.T) {
+	name := `test"cmd\`
+	recordCommand(name, 250*time.Millisecond, nil)
+	recordCommand(name, 750*time.Millisecond, nil)
+	defer func() {
+		commandStatsMutex.Lock()
+		delete(commandStats, name)
+		commandStatsMutex.Unlock()
+	}()
+
+	output := NewMetricsService(NewNetworkService()).RenderPrometheus()
+
+	for _, want := range []string{
+		"# TYPE iptables_manager_command_duration_seconds summary\n",
+		`iptables_manager_command_duration_seconds_sum{command="test\"cmd\\"} 1` + "\n",
+		`iptables_manager_command_duration_seconds_count{command="test\"cmd\\"} 2` + "\n",
+		"# TYPE iptables_manager_scrape_duration_seconds gauge\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+	for _, unwanted := range []string{
+		"# TYPE iptables_manager_command_duration_seconds_sum",
+		"# TYPE iptables_manager_command_duration_seconds_count",
+	} {
+		if strings.Contains(output, unwanted) {
+			t.Errorf("expected no separate family %q", unwanted)
+		}
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "INPUT", want: "INPUT"},
+		{value: `allow "web"`, want: `allow \"web\"`},
+		{value: `C:\path`, want: `C:\\path`},
+		{value: "line1\nline2", want: `line1\nline2`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeLabelValue(tt.value); got != tt.want {
+			t.Errorf("escapeLabelValue(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// rtnetlink 属性类型和常量（syscall 包中缺少，或只在Linux上定义的部分）
+const (
+	iflaLinkInfo    = 18
+	iflaInfoKind    = 1
+	iflaInfoData    = 2
+	iflaStats64     = 23
+	iflaLinkNetNSID = 37
+	nlaTypeMask     = 0x3fff
+	nlaFNested      = 0x8000
+	ifInfoMsgSize   = 16
+	ifAddrMsgSize   = 8
+	nlmFDump        = 0x300 // NLM_F_ROOT | NLM_F_MATCH
+	rtTableMain     = 254
+	rtnUnicast      = 1
+)
+
+// generic netlink 控制器
+const (
+	genlHeaderSize     = 4
+	genlIDCtrl         = 0x10
+	ctrlCmdGetFamily   = 3
+	ctrlAttrFamilyID   = 1
+	ctrlAttrFamilyName = 2
+)
+
+// nativeEndian 本机字节序（netlink 消息使用本机字节序）
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	value := uint16(1)
+	if *(*byte)(unsafe.Pointer(&value)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// netlinkLink rtnetlink 返回的链路信息
+type netlinkLink struct {
+	Index       int
+	Name        string
+	MAC         string
+	MTU         int
+	Flags       uint32
+	Link        int // IFLA_LINK：veth等成对接口的对端ifindex（可能位于其他命名空间），0表示无
+	Master      int // 所属网桥/bond的ifindex
+	OperState   uint8
+	Kind        string // IFLA_INFO_KIND：veth、bridge、wireguard、vxlan 等
+	InfoData    []byte // IFLA_INFO_DATA 原始数据（按类型解析）
+	TxQueueLen  int
+	LinkNetNSID int // IFLA_LINK_NETNSID：IFLA_LINK 所在命名空间的nsid，-1表示同一命名空间
+	Stats       *netlinkStats
+}
+
+// netlinkStats IFLA_STATS64 中的计数器（struct rtnl_link_stats64 的前若干字段）
+type netlinkStats struct {
+	RxPackets uint64
+	TxPackets uint64
+	RxBytes   uint64
+	TxBytes   uint64
+	RxErrors  uint64
+	TxErrors  uint64
+	RxDropped uint64
+	TxDropped uint64
+	Multicast uint64
+}
+
+// netlinkAddr rtnetlink 返回的地址信息
+type netlinkAddr struct {
+	Index     int
+	Family    int
+	IP        net.IP
+	PrefixLen int
+	Scope     int
+	Label     string
+	Peer      net.IP // 点对点接口的对端地址（IFA_ADDRESS 与 IFA_LOCAL 不同时）
+}
+
+// netlinkRoute rtnetlink 返回的路由信息（仅包含接口分析所需字段）
+type netlinkRoute struct {
+	Family int
+	Table  int
+	Dst    net.IP
+	DstLen int
+	OIF    int
+	Scope  int
+	Type   int
+}
+
+// CIDR 地址的CIDR表示
+func (a netlinkAddr) CIDR() string {
+	return fmt.Sprintf("%s/%d", a.IP.String(), a.PrefixLen)
+}
+
+// IsUp 接口是否处于UP状态
+func (l netlinkLink) IsUp() bool {
+	return l.Flags&syscall.IFF_UP != 0
+}
+
+// parseLinkStats64 解析 IFLA_STATS64（按本机字节序排列的u64数组）
+func parseLinkStats64(data []byte) *netlinkStats {
+	if len(data) < 9*8 {
+		return nil
+	}
+	field := func(i int) uint64 {
+		return nativeEndian.Uint64(data[i*8 : i*8+8])
+	}
+	return &netlinkStats{
+		RxPackets: field(0),
+		TxPackets: field(1),
+		RxBytes:   field(2),
+		TxBytes:   field(3),
+		RxErrors:  field(4),
+		TxErrors:  field(5),
+		RxDropped: field(6),
+		TxDropped: field(7),
+		Multicast: field(8),
+	}
+}
+
+// netlinkAttr 保持顺序的netlink属性（用于类型即数组下标的嵌套数组）
+type netlinkAttr struct {
+	Type  uint16
+	Value []byte
+}
+
+// parseAttrList 按顺序解析netlink属性
+func parseAttrList(data []byte) []netlinkAttr {
+	var attrs []netlinkAttr
+	for len(data) >= 4 {
+		length := int(nativeEndian.Uint16(data[0:2]))
+		attrType := nativeEndian.Uint16(data[2:4]) & nlaTypeMask
+		if length < 4 || length > len(data) {
+			break
+		}
+		attrs = append(attrs, netlinkAttr{Type: attrType, Value: data[4:length]})
+
+		aligned := (length + 3) &^ 3
+		if aligned > len(data) {
+			break
+		}
+		data = data[aligned:]
+	}
+	return attrs
+}
+
+// encodeNetlinkAttr 编码一个netlink属性（含4字节对齐填充）
+func encodeNetlinkAttr(attrType uint16, value []byte) []byte {
+	buf := make([]byte, 4, 4+len(value)+3)
+	nativeEndian.PutUint16(buf[0:2], uint16(4+len(value)))
+	nativeEndian.PutUint16(buf[2:4], attrType)
+	buf = append(buf, value...)
+	for len(buf)%4 != 0 {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+// genetlinkFamilyID 通过 CTRL_CMD_GETFAMILY 查询generic netlink族的ID
+func genetlinkFamilyID(name string) (uint16, error) {
+	payloads, err := genetlinkRequest(genlIDCtrl, ctrlCmdGetFamily, 1, 0, encodeNetlinkAttr(ctrlAttrFamilyName, append([]byte(name), 0)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve generic netlink family %s: %v", name, err)
+	}
+	for _, payload := range payloads {
+		if id := parseNestedAttrs(payload)[ctrlAttrFamilyID]; len(id) >= 2 {
+			return nativeEndian.Uint16(id), nil
+		}
+	}
+	return 0, fmt.Errorf("generic netlink family %s not found", name)
+}
+
+// parseNestedAttrs 解析嵌套的netlink属性
+func parseNestedAttrs(data []byte) map[uint16][]byte {
+	attrs := make(map[uint16][]byte)
+	for len(data) >= 4 {
+		length := int(nativeEndian.Uint16(data[0:2]))
+		attrType := nativeEndian.Uint16(data[2:4]) & nlaTypeMask
+		if length < 4 || length > len(data) {
+			break
+		}
+		attrs[attrType] = data[4:length]
+
+		aligned := (length + 3) &^ 3
+		if aligned > len(data) {
+			break
+		}
+		data = data[aligned:]
+	}
+	return attrs
+}
+
+// cString 去掉结尾的NUL字符
+func cString(value []byte) string {
+	for i, b := range value {
+		if b == 0 {
+			return string(value[:i])
+		}
+	}
+	return string(value)
+}
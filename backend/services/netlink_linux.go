@@ -0,0 +1,252 @@
+//go:build linux
+
+package services
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// listNetlinkLinks 通过 RTM_GETLINK 获取当前线程所在网络命名空间的全部链路
+func listNetlinkLinks() ([]netlinkLink, error) {
+	data, err := syscall.NetlinkRIB(syscall.RTM_GETLINK, syscall.AF_UNSPEC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump links: %v", err)
+	}
+	messages, err := syscall.ParseNetlinkMessage(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse link messages: %v", err)
+	}
+
+	var links []netlinkLink
+	for i := range messages {
+		message := &messages[i]
+		if message.Header.Type == syscall.NLMSG_DONE {
+			break
+		}
+		if message.Header.Type != syscall.RTM_NEWLINK || len(message.Data) < ifInfoMsgSize {
+			continue
+		}
+
+		link := netlinkLink{
+			Index:       int(int32(nativeEndian.Uint32(message.Data[4:8]))),
+			Flags:       nativeEndian.Uint32(message.Data[8:12]),
+			LinkNetNSID: -1,
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(message)
+		if err != nil {
+			continue
+		}
+		for _, attr := range attrs {
+			switch attr.Attr.Type {
+			case syscall.IFLA_IFNAME:
+				link.Name = cString(attr.Value)
+			case syscall.IFLA_ADDRESS:
+				if len(attr.Value) == 6 {
+					link.MAC = net.HardwareAddr(attr.Value).String()
+				}
+			case syscall.IFLA_MTU:
+				if len(attr.Value) >= 4 {
+					link.MTU = int(nativeEndian.Uint32(attr.Value))
+				}
+			case syscall.IFLA_LINK:
+				if len(attr.Value) >= 4 {
+					link.Link = int(int32(nativeEndian.Uint32(attr.Value)))
+				}
+			case syscall.IFLA_MASTER:
+				if len(attr.Value) >= 4 {
+					link.Master = int(nativeEndian.Uint32(attr.Value))
+				}
+			case syscall.IFLA_OPERSTATE:
+				if len(attr.Value) >= 1 {
+					link.OperState = attr.Value[0]
+				}
+			case syscall.IFLA_TXQLEN:
+				if len(attr.Value) >= 4 {
+					link.TxQueueLen = int(nativeEndian.Uint32(attr.Value))
+				}
+			case iflaLinkNetNSID:
+				if len(attr.Value) >= 4 {
+					link.LinkNetNSID = int(int32(nativeEndian.Uint32(attr.Value)))
+				}
+			case iflaStats64:
+				link.Stats = parseLinkStats64(attr.Value)
+			case iflaLinkInfo:
+				info := parseNestedAttrs(attr.Value)
+				link.Kind = cString(info[iflaInfoKind])
+				link.InfoData = info[iflaInfoData]
+			}
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// listNetlinkAddrs 通过 RTM_GETADDR 获取当前线程所在网络命名空间的全部地址
+func listNetlinkAddrs() ([]netlinkAddr, error) {
+	data, err := syscall.NetlinkRIB(syscall.RTM_GETADDR, syscall.AF_UNSPEC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump addresses: %v", err)
+	}
+	messages, err := syscall.ParseNetlinkMessage(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse address messages: %v", err)
+	}
+
+	var addrs []netlinkAddr
+	for i := range messages {
+		message := &messages[i]
+		if message.Header.Type == syscall.NLMSG_DONE {
+			break
+		}
+		if message.Header.Type != syscall.RTM_NEWADDR || len(message.Data) < ifAddrMsgSize {
+			continue
+		}
+
+		addr := netlinkAddr{
+			Family:    int(message.Data[0]),
+			PrefixLen: int(message.Data[1]),
+			Scope:     int(message.Data[3]),
+			Index:     int(nativeEndian.Uint32(message.Data[4:8])),
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(message)
+		if err != nil {
+			continue
+		}
+		var address, local net.IP
+		for _, attr := range attrs {
+			switch attr.Attr.Type {
+			case syscall.IFA_ADDRESS:
+				address = net.IP(append([]byte{}, attr.Value...))
+			case syscall.IFA_LOCAL:
+				local = net.IP(append([]byte{}, attr.Value...))
+			case syscall.IFA_LABEL:
+				addr.Label = cString(attr.Value)
+			}
+		}
+		// 点对点接口的 IFA_ADDRESS 是对端地址，本地地址在 IFA_LOCAL
+		addr.IP = address
+		if local != nil {
+			addr.IP = local
+			if address != nil && !address.Equal(local) {
+				addr.Peer = address
+			}
+		}
+		if addr.IP == nil {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// listNetlinkRoutes 通过 RTM_GETROUTE 获取当前线程所在网络命名空间指定地址族的全部路由
+func listNetlinkRoutes(family int) ([]netlinkRoute, error) {
+	data, err := syscall.NetlinkRIB(syscall.RTM_GETROUTE, family)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump routes: %v", err)
+	}
+	messages, err := syscall.ParseNetlinkMessage(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse route messages: %v", err)
+	}
+
+	var routes []netlinkRoute
+	for i := range messages {
+		message := &messages[i]
+		if message.Header.Type == syscall.NLMSG_DONE {
+			break
+		}
+		if message.Header.Type != syscall.RTM_NEWROUTE || len(message.Data) < syscall.SizeofRtMsg {
+			continue
+		}
+
+		route := netlinkRoute{
+			Family: int(message.Data[0]),
+			DstLen: int(message.Data[1]),
+			Table:  int(message.Data[4]),
+			Scope:  int(message.Data[6]),
+			Type:   int(message.Data[7]),
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(message)
+		if err != nil {
+			continue
+		}
+		for _, attr := range attrs {
+			switch attr.Attr.Type {
+			case syscall.RTA_DST:
+				route.Dst = net.IP(append([]byte{}, attr.Value...))
+			case syscall.RTA_OIF:
+				if len(attr.Value) >= 4 {
+					route.OIF = int(nativeEndian.Uint32(attr.Value))
+				}
+			case syscall.RTA_TABLE:
+				if len(attr.Value) >= 4 {
+					route.Table = int(nativeEndian.Uint32(attr.Value))
+				}
+			}
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// genetlinkRequest 发送一条generic netlink请求并收集全部应答的负载（不含genl头）
+func genetlinkRequest(family uint16, cmd, version uint8, flags uint16, attrs []byte) ([][]byte, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_GENERIC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open generic netlink socket: %v", err)
+	}
+	defer syscall.Close(fd)
+
+	local := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}
+	if err := syscall.Bind(fd, local); err != nil {
+		return nil, fmt.Errorf("failed to bind generic netlink socket: %v", err)
+	}
+
+	request := make([]byte, syscall.NLMSG_HDRLEN+genlHeaderSize, syscall.NLMSG_HDRLEN+genlHeaderSize+len(attrs))
+	request = append(request, attrs...)
+	nativeEndian.PutUint32(request[0:4], uint32(len(request)))
+	nativeEndian.PutUint16(request[4:6], family)
+	nativeEndian.PutUint16(request[6:8], syscall.NLM_F_REQUEST|flags)
+	nativeEndian.PutUint32(request[8:12], 1)
+	request[syscall.NLMSG_HDRLEN] = cmd
+	request[syscall.NLMSG_HDRLEN+1] = version
+	if err := syscall.Sendto(fd, request, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("failed to send generic netlink request: %v", err)
+	}
+
+	var payloads [][]byte
+	buf := make([]byte, 1<<16)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to receive generic netlink response: %v", err)
+		}
+		messages, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse generic netlink response: %v", err)
+		}
+		for _, message := range messages {
+			switch message.Header.Type {
+			case syscall.NLMSG_DONE:
+				return payloads, nil
+			case syscall.NLMSG_ERROR:
+				if len(message.Data) < 4 {
+					return nil, fmt.Errorf("truncated netlink error message")
+				}
+				if errno := int32(nativeEndian.Uint32(message.Data[0:4])); errno != 0 {
+					return nil, syscall.Errno(-errno)
+				}
+				return payloads, nil
+			}
+			if len(message.Data) >= genlHeaderSize {
+				payloads = append(payloads, append([]byte{}, message.Data[genlHeaderSize:]...))
+			}
+			if message.Header.Flags&syscall.NLM_F_MULTI == 0 {
+				return payloads, nil
+			}
+		}
+	}
+}
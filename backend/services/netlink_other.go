@@ -0,0 +1,31 @@
+//go:build !linux
+
+package services
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// errUnsupportedPlatform netlink、网络命名空间和 NFLOG 只在Linux上可用
+var errUnsupportedPlatform = fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+
+// listNetlinkLinks 非Linux平台不支持 rtnetlink
+func listNetlinkLinks() ([]netlinkLink, error) {
+	return nil, errUnsupportedPlatform
+}
+
+// listNetlinkAddrs 非Linux平台不支持 rtnetlink
+func listNetlinkAddrs() ([]netlinkAddr, error) {
+	return nil, errUnsupportedPlatform
+}
+
+// listNetlinkRoutes 非Linux平台不支持 rtnetlink
+func listNetlinkRoutes(family int) ([]netlinkRoute, error) {
+	return nil, errUnsupportedPlatform
+}
+
+// genetlinkRequest 非Linux平台不支持 generic netlink
+func genetlinkRequest(family uint16, cmd, version uint8, flags uint16, attrs []byte) ([][]byte, error) {
+	return nil, errUnsupportedPlatform
+}
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// netNamespace 一个网络命名空间
+type netNamespace struct {
+	Inode uint64
+	Path  string // 用于setns的路径：/var/run/netns/<name> 或 /proc/<pid>/ns/net
+	Name  string // 命名空间名称（ip netns），匿名命名空间为空
+	PIDs  []int  // 位于该命名空间的进程（升序）
+}
+
+// vethEndpoint 主机veth在对端命名空间中的端点
+type vethEndpoint struct {
+	HostInterface string
+	HostIndex     int
+	PeerIndex     int
+	PeerName      string
+	PeerMAC       string
+	Addresses     []string // 对端接口上的地址（CIDR）
+	NetNS         string   // 命名空间标识：名称或 net:[inode]
+	PID           int      // 命名空间中PID最小的进程（通常是容器的init进程）
+	Process       string
+	Cgroup        string
+	ContainerID   string
+	Runtime       string // docker, podman, kubernetes, lxc, netns
+	Verified      bool   // 对端的iflink已确认指向主机接口
+}
+
+// namespaceInterfaces 命名空间内的链路和地址
+type namespaceInterfaces struct {
+	links []netlinkLink
+	addrs []netlinkAddr
+}
+
+// netnsResolver 根据 ifindex/iflink 在各网络命名空间中查找veth对端
+type netnsResolver struct {
+	hostInode  uint64
+	namespaces []*netNamespace
+	interfaces map[uint64]*namespaceInterfaces
+}
+
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// newNetnsResolver 扫描 /proc 和 /var/run/netns 建立命名空间列表
+func newNetnsResolver() (*netnsResolver, error) {
+	hostInode, err := netnsInode("/proc/self/ns/net")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read host network namespace: %v", err)
+	}
+
+	namespaces, err := listNetNamespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	resolver := &netnsResolver{
+		hostInode:  hostInode,
+		interfaces: make(map[uint64]*namespaceInterfaces),
+	}
+	for _, ns := range namespaces {
+		if ns.Inode != hostInode {
+			resolver.namespaces = append(resolver.namespaces, ns)
+		}
+	}
+	return resolver, nil
+}
+
+// listNetNamespaces 枚举所有进程和命名的网络命名空间（按inode去重）
+func listNetNamespaces() ([]*netNamespace, error) {
+	byInode := make(map[uint64]*netNamespace)
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /proc: %v", err)
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		path := fmt.Sprintf("/proc/%d/ns/net", pid)
+		inode, err := netnsInode(path)
+		if err != nil {
+			continue
+		}
+		ns, ok := byInode[inode]
+		if !ok {
+			ns = &netNamespace{Inode: inode, Path: path}
+			byInode[inode] = ns
+		}
+		ns.PIDs = append(ns.PIDs, pid)
+	}
+
+	// ip netns 创建的命名空间可能没有进程
+	if named, err := os.ReadDir("/var/run/netns"); err == nil {
+		for _, entry := range named {
+			path := filepath.Join("/var/run/netns", entry.Name())
+			inode, err := fileInode(path)
+			if err != nil {
+				continue
+			}
+			ns, ok := byInode[inode]
+			if !ok {
+				ns = &netNamespace{Inode: inode}
+				byInode[inode] = ns
+			}
+			ns.Name = entry.Name()
+			ns.Path = path
+		}
+	}
+
+	namespaces := make([]*netNamespace, 0, len(byInode))
+	for _, ns := range byInode {
+		sort.Ints(ns.PIDs)
+		if ns.Name == "" && len(ns.PIDs) > 0 {
+			ns.Path = fmt.Sprintf("/proc/%d/ns/net", ns.PIDs[0])
+		}
+		namespaces = append(namespaces, ns)
+	}
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i].Inode < namespaces[j].Inode })
+	return namespaces, nil
+}
+
+// netnsInode 读取命名空间链接 "net:[4026531993]" 中的inode
+func netnsInode(path string) (uint64, error) {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return 0, err
+	}
+	start := strings.Index(target, "[")
+	end := strings.Index(target, "]")
+	if start == -1 || end <= start {
+		return 0, fmt.Errorf("unexpected namespace link: %s", target)
+	}
+	return strconv.ParseUint(target[start+1:end], 10, 64)
+}
+
+// namespaceInterfaces 获取命名空间内的接口（带缓存）
+// 优先进入命名空间通过netlink读取；权限不足时回退到 /proc/<pid>/net/igmp（仅有ifindex和名称）
+func (r *netnsResolver) namespaceInterfaces(ns *netNamespace) *namespaceInterfaces {
+	if cached, ok := r.interfaces[ns.Inode]; ok {
+		return cached
+	}
+
+	result := &namespaceInterfaces{}
+	err := withNetNamespace(ns.Path, func() error {
+		links, err := listNetlinkLinks()
+		if err != nil {
+			return err
+		}
+		addrs, err := listNetlinkAddrs()
+		if err != nil {
+			return err
+		}
+		result.links = links
+		result.addrs = addrs
+		return nil
+	})
+	if err != nil && len(ns.PIDs) > 0 {
+		result.links = readIGMPInterfaces(fmt.Sprintf("/proc/%d/net/igmp", ns.PIDs[0]))
+	}
+
+	r.interfaces[ns.Inode] = result
+	return result
+}
+
+// readIGMPInterfaces 从 /proc/<pid>/net/igmp 读取命名空间内接口的ifindex和名称
+func readIGMPInterfaces(path string) []netlinkLink {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var links []netlinkLink
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// 接口行以ifindex开头，组播组行以制表符开头
+		if line == "" || line[0] == '\t' || line[0] == ' ' || strings.HasPrefix(line, "Idx") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		index, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		links = append(links, netlinkLink{Index: index, Name: strings.TrimSuffix(fields[1], ":")})
+	}
+	return links
+}
+
+// resolvePeer 查找主机veth（hostIndex，其iflink为peerIndex）的对端
+// 先匹配对端iflink指回主机接口的结果；无法读取iflink时退回仅按ifindex匹配
+func (r *netnsResolver) resolvePeer(hostName string, hostIndex, peerIndex int) *vethEndpoint {
+	var fallback *vethEndpoint
+	for _, ns := range r.namespaces {
+		nsInterfaces := r.namespaceInterfaces(ns)
+		for _, link := range nsInterfaces.links {
+			if link.Index != peerIndex {
+				continue
+			}
+			if link.Link != 0 && link.Link != hostIndex {
+				continue
+			}
+
+			endpoint := r.buildEndpoint(ns, nsInterfaces, link)
+			endpoint.HostInterface = hostName
+			endpoint.HostIndex = hostIndex
+			if link.Link == hostIndex {
+				endpoint.Verified = true
+				return endpoint
+			}
+			if fallback == nil {
+				fallback = endpoint
+			}
+		}
+	}
+	return fallback
+}
+
+// buildEndpoint 根据命名空间和对端接口构造端点信息
+func (r *netnsResolver) buildEndpoint(ns *netNamespace, nsInterfaces *namespaceInterfaces, link netlinkLink) *vethEndpoint {
+	endpoint := &vethEndpoint{
+		PeerIndex: link.Index,
+		PeerName:  link.Name,
+		PeerMAC:   link.MAC,
+		NetNS:     fmt.Sprintf("net:[%d]", ns.Inode),
+	}
+	for _, addr := range nsInterfaces.addrs {
+		if addr.Index == link.Index {
+			endpoint.Addresses = append(endpoint.Addresses, addr.CIDR())
+		}
+	}
+
+	if ns.Name != "" {
+		endpoint.NetNS = ns.Name
+		endpoint.Runtime = "netns"
+	}
+	if len(ns.PIDs) > 0 {
+		pid := ns.PIDs[0]
+		endpoint.PID = pid
+		if comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid)); err == nil {
+			endpoint.Process = strings.TrimSpace(string(comm))
+		}
+		if cgroup, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid)); err == nil {
+			endpoint.Cgroup = primaryCgroup(string(cgroup))
+			if id, runtimeName := containerFromCgroup(string(cgroup)); id != "" || runtimeName != "" {
+				endpoint.ContainerID = id
+				endpoint.Runtime = runtimeName
+			}
+		}
+	}
+	return endpoint
+}
+
+// primaryCgroup 取 /proc/<pid>/cgroup 中的cgroup路径（cgroup v2 的统一层级优先）
+func primaryCgroup(content string) string {
+	var first string
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			return parts[2]
+		}
+		if first == "" {
+			first = parts[2]
+		}
+	}
+	return first
+}
+
+// containerFromCgroup 从cgroup路径识别容器ID和运行时
+func containerFromCgroup(content string) (string, string) {
+	runtimeName := ""
+	switch {
+	case strings.Contains(content, "libpod"):
+		runtimeName = "podman"
+	case strings.Contains(content, "kubepods"):
+		runtimeName = "kubernetes"
+	case strings.Contains(content, "docker"):
+		runtimeName = "docker"
+	case strings.Contains(content, "lxc"):
+		runtimeName = "lxc"
+	}
+
+	id := containerIDPattern.FindString(content)
+	if id == "" && runtimeName != "lxc" {
+		runtimeName = ""
+	}
+	return id, runtimeName
+}
+
+// readSysNetInt 读取 /sys/class/net/<iface>/<name> 中的整数
+func readSysNetInt(iface, name string) (int, error) {
+	data, err := os.ReadFile(filepath.Join("/sys/class/net", iface, name))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// bridgePorts 获取网桥的端口接口名（/sys/class/net/<bridge>/brif）
+func bridgePorts(bridgeName string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join("/sys/class/net", bridgeName, "brif"))
+	if err != nil {
+		return nil, err
+	}
+	ports := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ports = append(ports, entry.Name())
+	}
+	return ports, nil
+}
+
+// resolveBridgeEndpoints 解析网桥每个端口的对端端点
+func (r *netnsResolver) resolveBridgeEndpoints(bridgeName string) ([]*vethEndpoint, error) {
+	ports, err := bridgePorts(bridgeName)
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints []*vethEndpoint
+	for _, port := range ports {
+		endpoint := r.resolveInterface(port)
+		if endpoint != nil {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints, nil
+}
+
+// resolveInterface 解析单个主机接口的对端端点，非成对接口返回nil
+func (r *netnsResolver) resolveInterface(name string) *vethEndpoint {
+	index, err := readSysNetInt(name, "ifindex")
+	if err != nil {
+		return nil
+	}
+	peerIndex, err := readSysNetInt(name, "iflink")
+	if err != nil || peerIndex == index || peerIndex == 0 {
+		return nil
+	}
+	return r.resolvePeer(name, index, peerIndex)
+}
@@ -0,0 +1,69 @@
+//go:build linux
+
+package services
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// fileInode 读取文件的inode（ip netns 挂载的命名空间文件的inode即命名空间inode）
+func fileInode(path string) (uint64, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Ino, nil
+}
+
+// withNetNamespace 在指定网络命名空间中执行fn
+// 在独立goroutine中锁定OS线程执行；若无法切回原命名空间，则不解锁线程使其随goroutine退出而销毁
+func withNetNamespace(nsPath string, fn func() error) error {
+	result := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		origin, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", syscall.Gettid()))
+		if err != nil {
+			runtime.UnlockOSThread()
+			result <- fmt.Errorf("failed to open current network namespace: %v", err)
+			return
+		}
+		defer origin.Close()
+
+		target, err := os.Open(nsPath)
+		if err != nil {
+			runtime.UnlockOSThread()
+			result <- fmt.Errorf("failed to open network namespace %s: %v", nsPath, err)
+			return
+		}
+		defer target.Close()
+
+		if err := setns(target.Fd()); err != nil {
+			runtime.UnlockOSThread()
+			result <- fmt.Errorf("failed to enter network namespace %s: %v", nsPath, err)
+			return
+		}
+
+		fnErr := fn()
+
+		if err := setns(origin.Fd()); err != nil {
+			// 线程仍在目标命名空间，保持锁定让运行时丢弃该线程
+			result <- fmt.Errorf("failed to restore network namespace: %v", err)
+			return
+		}
+		runtime.UnlockOSThread()
+		result <- fnErr
+	}()
+	return <-result
+}
+
+// setns 将当前线程切换到fd指向的网络命名空间
+func setns(fd uintptr) error {
+	if _, _, errno := syscall.RawSyscall(sysSetns, fd, syscall.CLONE_NEWNET, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
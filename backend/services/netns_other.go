@@ -0,0 +1,13 @@
+//go:build !linux
+
+package services
+
+// withNetNamespace 非Linux平台没有网络命名空间
+func withNetNamespace(nsPath string, fn func() error) error {
+	return errUnsupportedPlatform
+}
+
+// fileInode 非Linux平台没有网络命名空间文件
+func fileInode(path string) (uint64, error) {
+	return 0, errUnsupportedPlatform
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestContainerFromCgroup(t *testing.T) {
+	const id = "3f4e8c0b2a1d9e7f6c5b4a392817160504f3e2d1c0b9a8f7e6d5c4b3a2918070"
+	tests := []struct {
+		name        string
+		content     string
+		wantID      string
+		wantRuntime string
+	}{
+		{"docker cgroup v2", "0::/system.slice/docker-" + id + ".scope\n", id, "docker"},
+		{"docker cgroup v1", "12:pids:/docker/" + id + "\n11:memory:/docker/" + id + "\n", id, "docker"},
+		{"podman", "0::/machine.slice/libpod-" + id + ".scope/container\n", id, "podman"},
+		{"kubernetes", "0::/kubepods.slice/kubepods-besteffort.slice/cri-containerd-" + id + ".scope\n", id, "kubernetes"},
+		{"host process", "0::/user.slice/user-1000.slice/session-2.scope\n", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotID, gotRuntime := containerFromCgroup(tt.content)
+			if gotID != tt.wantID || gotRuntime != tt.wantRuntime {
+				t.Errorf("containerFromCgroup() = (%q, %q), want (%q, %q)", gotID, gotRuntime, tt.wantID, tt.wantRuntime)
+			}
+		})
+	}
+}
+
+func TestPrimaryCgroup(t *testing.T) {
+	content := "12:pids:/docker/abc\n0::/system.slice/docker-abc.scope\n"
+	if got := primaryCgroup(content); got != "/system.slice/docker-abc.scope" {
+		t.Errorf("expected unified hierarchy path, got %q", got)
+	}
+	if got := primaryCgroup("12:pids:/docker/abc\n"); got != "/docker/abc" {
+		t.Errorf("expected first v1 path, got %q", got)
+	}
+}
+
+func TestReadIGMPInterfaces(t *testing.T) {
+	content := "Idx\tDevice    : Count Querier\tGroup    Users Timer\tReporter\n" +
+		"1\tlo        :     1      V3\n" +
+		"\t\t\t\t010000E0     1 0:00000000\t\t0\n" +
+		"23\teth0      :     1      V3\n" +
+		"\t\t\t\t010000E0     1 0:00000000\t\t0\n"
+	path := filepath.Join(t.TempDir(), "igmp")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write igmp fixture: %v", err)
+	}
+
+	links := readIGMPInterfaces(path)
+	if len(links) != 2 {
+		t.Fatalf("expected 2 interfaces, got %+v", links)
+	}
+	if links[1].Index != 23 || links[1].Name != "eth0" {
+		t.Errorf("unexpected interface: %+v", links[1])
+	}
+}
+
+func TestParseNestedAttrs(t *testing.T) {
+	// IFLA_INFO_KIND="veth"（长度9，填充到12）后跟 IFLA_INFO_DATA（长度4，空负载）
+	data := []byte{9, 0, 1, 0, 'v', 'e', 't', 'h', 0, 0, 0, 0, 4, 0, 2, 0}
+	if nativeEndian.Uint16([]byte{1, 0}) != 1 {
+		data = []byte{0, 9, 0, 1, 'v', 'e', 't', 'h', 0, 0, 0, 0, 0, 4, 0, 2}
+	}
+
+	attrs := parseNestedAttrs(data)
+	if kind := cString(attrs[iflaInfoKind]); kind != "veth" {
+		t.Errorf("expected kind veth, got %q", kind)
+	}
+	if payload, ok := attrs[iflaInfoData]; !ok || len(payload) != 0 {
+		t.Errorf("expected empty info data attribute, got %v (present=%v)", payload, ok)
+	}
+}
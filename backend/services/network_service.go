@@ -13,89 +13,40 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
-type NetworkService struct{}
+type NetworkService struct {
+	docker *DockerClient
+}
 
 // NewNetworkService 创建网络服务实例
 func NewNetworkService() *NetworkService {
-	return &NetworkService{}
+	return &NetworkService{
+		docker: NewDockerClient(),
+	}
 }
 
 // GetAllInterfaces 获取所有网络接口
+// 链路、地址和统计信息来自同一次rtnetlink转储，不再为每个接口启动子进程
 func (s *NetworkService) GetAllInterfaces() ([]models.NetworkInterface, error) {
 	log.Println("[DEBUG] NetworkService.GetAllInterfaces called")
 
-	interfaces, err := net.Interfaces()
+	inventory, err := loadInterfaceInventory()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get network interfaces: %v", err)
 	}
 
-	var result []models.NetworkInterface
-	for _, iface := range interfaces {
-		netInterface, err := s.parseInterface(iface)
-		if err != nil {
-			log.Printf("[WARN] Failed to parse interface %s: %v", iface.Name, err)
-			continue
-		}
-		result = append(result, netInterface)
+	result := make([]models.NetworkInterface, 0, len(inventory.links))
+	for i := range inventory.links {
+		result = append(result, s.buildInterface(inventory, &inventory.links[i]))
 	}
 
 	log.Printf("[DEBUG] Retrieved %d network interfaces", len(result))
 	return result, nil
 }
 
-// parseInterface 解析网络接口信息
-func (s *NetworkService) parseInterface(iface net.Interface) (models.NetworkInterface, error) {
-	netInterface := models.NetworkInterface{
-		Name:       iface.Name,
-		MACAddress: iface.HardwareAddr.String(),
-		MTU:        iface.MTU,
-		IsUp:       iface.Flags&net.FlagUp != 0,
-		IsDocker:   s.isDockerInterface(iface.Name),
-	}
-
-	// 设置接口类型和Docker类型
-	netInterface.Type = s.getInterfaceType(iface.Name)
-	if netInterface.IsDocker {
-		netInterface.DockerType = s.getDockerInterfaceType(iface.Name)
-	}
-
-	// 设置状态
-	if netInterface.IsUp {
-		netInterface.State = "UP"
-	} else {
-		netInterface.State = "DOWN"
-	}
-
-	// 获取IP地址
-	addrs, err := iface.Addrs()
-	if err == nil {
-		for _, addr := range addrs {
-			netInterface.IPAddresses = append(netInterface.IPAddresses, addr.String())
-		}
-	}
-
-	// 获取统计信息
-	stats, err := s.getInterfaceStats(iface.Name)
-	if err == nil {
-		netInterface.Statistics = stats
-	} else {
-		// 如果获取统计信息失败，使用默认值
-		netInterface.Statistics = models.InterfaceStats{
-			RxBytes:   0,
-			TxBytes:   0,
-			RxPackets: 0,
-			TxPackets: 0,
-			RxErrors:  0,
-			TxErrors:  0,
-		}
-	}
-
-	return netInterface, nil
-}
-
 // isDockerInterface 判断是否为Docker接口
 func (s *NetworkService) isDockerInterface(name string) bool {
 	dockerPatterns := []string{
@@ -151,34 +102,49 @@ func (s *NetworkService) getDockerInterfaceType(name string) string {
 	return "unknown"
 }
 
-// getInterfaceStats 获取接口统计信息
-func (s *NetworkService) getInterfaceStats(name string) (models.InterfaceStats, error) {
-	var stats models.InterfaceStats
+// GetAllInterfaceStats 一次性获取所有接口的统计信息
+// 优先使用rtnetlink的 IFLA_STATS64，失败时回退到 /proc/net/dev
+func (s *NetworkService) GetAllInterfaceStats() (map[string]models.InterfaceStats, error) {
+	inventory, err := loadInterfaceInventory()
+	if err == nil {
+		return inventory.allStats(), nil
+	}
+	log.Printf("[WARN] Failed to load interface stats via netlink, falling back to /proc/net/dev: %v", err)
 
-	// 从 /proc/net/dev 读取统计信息
-	cmd := exec.Command("cat", "/proc/net/dev")
-	output, err := cmd.Output()
+	data, err := os.ReadFile("/proc/net/dev")
 	if err != nil {
-		return stats, err
+		return nil, fmt.Errorf("failed to read /proc/net/dev: %v", err)
 	}
+	return parseProcNetDev(string(data)), nil
+}
 
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.Contains(line, name+":") {
-			fields := strings.Fields(line)
-			if len(fields) >= 17 {
-				stats.RxBytes, _ = strconv.ParseInt(fields[1], 10, 64)
-				stats.RxPackets, _ = strconv.ParseInt(fields[2], 10, 64)
-				stats.RxErrors, _ = strconv.ParseInt(fields[3], 10, 64)
-				stats.TxBytes, _ = strconv.ParseInt(fields[9], 10, 64)
-				stats.TxPackets, _ = strconv.ParseInt(fields[10], 10, 64)
-				stats.TxErrors, _ = strconv.ParseInt(fields[11], 10, 64)
-			}
-			break
+// parseProcNetDev 解析 /proc/net/dev 内容
+func parseProcNetDev(content string) map[string]models.InterfaceStats {
+	result := make(map[string]models.InterfaceStats)
+	for _, line := range strings.Split(content, "\n") {
+		colon := strings.Index(line, ":")
+		if colon == -1 {
+			continue
+		}
+		name := strings.TrimSpace(line[:colon])
+		fields := strings.Fields(line[colon+1:])
+		if name == "" || len(fields) < 16 {
+			continue
 		}
-	}
 
-	return stats, nil
+		var stats models.InterfaceStats
+		stats.RxBytes, _ = strconv.ParseInt(fields[0], 10, 64)
+		stats.RxPackets, _ = strconv.ParseInt(fields[1], 10, 64)
+		stats.RxErrors, _ = strconv.ParseInt(fields[2], 10, 64)
+		stats.RxDropped, _ = strconv.ParseInt(fields[3], 10, 64)
+		stats.Multicast, _ = strconv.ParseInt(fields[7], 10, 64)
+		stats.TxBytes, _ = strconv.ParseInt(fields[8], 10, 64)
+		stats.TxPackets, _ = strconv.ParseInt(fields[9], 10, 64)
+		stats.TxErrors, _ = strconv.ParseInt(fields[10], 10, 64)
+		stats.TxDropped, _ = strconv.ParseInt(fields[11], 10, 64)
+		result[name] = stats
+	}
+	return result
 }
 
 // GetTunnelInterfaceRules 获取与隧道接口相关的iptables规则
@@ -189,8 +155,7 @@ func (s *NetworkService) GetTunnelInterfaceRules(interfaceName string) ([]models
 	tables := []string{"raw", "mangle", "nat", "filter"}
 
 	for _, tableName := range tables {
-		cmd := exec.Command("iptables", "-t", tableName, "-L", "-n", "-v", "--line-numbers")
-		output, err := cmd.Output()
+		output, err := runCommand("iptables", "-t", tableName, "-L", "-n", "-v", "--line-numbers")
 		if err != nil {
 			log.Printf("[WARN] Failed to get rules from table %s: %v", tableName, err)
 			continue
@@ -286,54 +251,43 @@ func (s *NetworkService) AnalyzeTunnelDockerCommunication(tunnelInterface, docke
 		DockerBridge:    dockerBridge,
 	}
 
+	// 一次加载接口清单，后续的存在性检查、地址和统计都从中读取
+	inventory, err := loadInterfaceInventory()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load interfaces: %v", err)
+	}
+
 	// 首先检查接口是否存在
-	tunnelExists, err := s.checkInterfaceExists(tunnelInterface)
-	if err != nil || !tunnelExists {
+	tunnelLink, ok := inventory.link(tunnelInterface)
+	if !ok {
 		return nil, fmt.Errorf("tunnel interface %s not found or not accessible", tunnelInterface)
 	}
-
-	bridgeExists, err := s.checkInterfaceExists(dockerBridge)
-	if err != nil || !bridgeExists {
+	if _, ok := inventory.link(dockerBridge); !ok {
 		return nil, fmt.Errorf("docker bridge %s not found or not accessible", dockerBridge)
 	}
 
 	// 获取接口IP信息
 	var tunnelIPs []string
 
-	// 对于tunnel接口，优先获取destination IP
-	if strings.HasPrefix(tunnelInterface, "tun") || strings.HasPrefix(tunnelInterface, "tap") {
-		log.Printf("[DEBUG] Detected tunnel interface %s, getting destination IP", tunnelInterface)
+	// 对于隧道接口，优先使用点对点对端地址（按内核链路类型判断，名称前缀作为兜底）
+	if interfaceTypeFromKind(tunnelLink.Kind, tunnelLink.Flags) == "tunnel" || strings.HasPrefix(tunnelInterface, "tun") || strings.HasPrefix(tunnelInterface, "tap") {
+		log.Printf("[DEBUG] Detected tunnel interface %s (kind %q), getting destination IP", tunnelInterface, tunnelLink.Kind)
 
-		// 首先尝试获取destination IP
-		if destIP := s.getTunnelDestinationIP(tunnelInterface); destIP != "" {
+		if destIP := s.getTunnelDestinationIP(inventory, tunnelInterface); destIP != "" {
 			tunnelIPs = append(tunnelIPs, destIP)
 			log.Printf("[DEBUG] Using tunnel destination IP: %s", destIP)
 		}
 
-		// 如果没有获取到destination IP，再尝试获取本地IP作为备选
+		// 如果没有获取到destination IP，再使用本地IP作为备选
 		if len(tunnelIPs) == 0 {
-			log.Printf("[DEBUG] No destination IP found, trying to get local IPs for %s", tunnelInterface)
-			localIPs, err := s.getInterfaceIPs(tunnelInterface)
-			if err != nil {
-				log.Printf("[WARN] Failed to get tunnel interface local IPs: %v", err)
-			} else {
-				tunnelIPs = localIPs
-				log.Printf("[DEBUG] Using tunnel local IPs: %v", tunnelIPs)
-			}
+			tunnelIPs = inventory.ipv4Addresses(tunnelInterface)
+			log.Printf("[DEBUG] No destination IP found, using tunnel local IPs: %v", tunnelIPs)
 		}
 	} else {
-		// 对于非tunnel接口，使用常规方法获取IP
-		var err error
-		tunnelIPs, err = s.getInterfaceIPs(tunnelInterface)
-		if err != nil {
-			log.Printf("[WARN] Failed to get interface IPs for %s: %v", tunnelInterface, err)
-		}
+		tunnelIPs = inventory.ipv4Addresses(tunnelInterface)
 	}
 
-	bridgeIPs, err := s.getInterfaceIPs(dockerBridge)
-	if err != nil {
-		log.Printf("[WARN] Failed to get bridge interface IPs: %v", err)
-	}
+	bridgeIPs := inventory.ipv4Addresses(dockerBridge)
 
 	log.Printf("[DEBUG] Final IP addresses - Tunnel (%s): %v, Bridge (%s): %v",
 		tunnelInterface, tunnelIPs, dockerBridge, bridgeIPs)
@@ -362,11 +316,16 @@ func (s *NetworkService) AnalyzeTunnelDockerCommunication(tunnelInterface, docke
 	// 实际连通性测试
 	connectivityResult := s.testConnectivity(tunnelInterface, dockerBridge, tunnelIPs, bridgeIPs)
 
-	// 生成通信路径（基于实际测试结果）
-	analysis.CommunicationPath = s.generateCommunicationPathWithIsolation(tunnelInterface, dockerBridge, connectivityResult, isolationRules)
+	// 生成通信路径：优先使用通用路径分析，规则集或路由不可用时回退到基于测试结果的路径
+	if path, err := s.AnalyzePath(PathQuery{From: tunnelInterface, To: dockerBridge, Protocol: "icmp"}); err == nil {
+		analysis.CommunicationPath = path.Forward.Path
+	} else {
+		log.Printf("[WARN] Generic path analysis unavailable for %s -> %s: %v", tunnelInterface, dockerBridge, err)
+		analysis.CommunicationPath = s.generateCommunicationPathWithIsolation(tunnelInterface, dockerBridge, connectivityResult, isolationRules)
+	}
 
 	// 计算统计信息（精确计算）
-	analysis.Statistics = s.calculateTunnelDockerStatsExact(tunnelInterface, dockerBridge, forwardRules, natRules)
+	analysis.Statistics = s.calculateTunnelDockerStatsExact(inventory, tunnelInterface, dockerBridge, forwardRules, natRules)
 
 	// 生成建议（基于实际测试结果）
 	analysis.Recommendations = s.generateRecommendationsWithTest(analysis, connectivityResult)
@@ -376,8 +335,7 @@ func (s *NetworkService) AnalyzeTunnelDockerCommunication(tunnelInterface, docke
 
 // getForwardRules 获取FORWARD链中相关的规则
 func (s *NetworkService) getForwardRulesExact(tunnelInterface, dockerBridge string) ([]models.IPTablesRule, error) {
-	cmd := exec.Command("iptables", "-t", "filter", "-L", "FORWARD", "-n", "-v", "--line-numbers")
-	output, err := cmd.Output()
+	output, err := runCommand("iptables", "-t", "filter", "-L", "FORWARD", "-n", "-v", "--line-numbers")
 	if err != nil {
 		return nil, err
 	}
@@ -636,7 +594,7 @@ func (s *NetworkService) generateCommunicationPathWithIsolation(tunnelInterface,
 }
 
 // calculateTunnelDockerStats 计算统计信息
-func (s *NetworkService) calculateTunnelDockerStatsExact(tunnelInterface, dockerBridge string, forwardRules, natRules []models.IPTablesRule) models.TunnelDockerStats {
+func (s *NetworkService) calculateTunnelDockerStatsExact(inventory *interfaceInventory, tunnelInterface, dockerBridge string, forwardRules, natRules []models.IPTablesRule) models.TunnelDockerStats {
 	var stats models.TunnelDockerStats
 
 	// 精确计算特定接口间的统计信息
@@ -667,15 +625,13 @@ func (s *NetworkService) calculateTunnelDockerStatsExact(tunnelInterface, docker
 	// 如果没有精确匹配的规则，尝试从接口统计中获取数据
 	if stats.TunnelToDockerPackets == 0 && stats.DockerToTunnelPackets == 0 {
 		log.Printf("[DEBUG] No exact rule matches found, attempting to get interface statistics")
-		tunnelStats, err := s.getInterfaceStatistics(tunnelInterface)
-		if err == nil {
+		if tunnelStats, ok := inventory.stats(tunnelInterface); ok {
 			// 使用接口统计作为参考（这是估算值）
 			stats.TunnelToDockerPackets = tunnelStats.TxPackets / 10 // 估算10%流量到Docker
 			stats.TunnelToDockerBytes = tunnelStats.TxBytes / 10
 		}
 
-		bridgeStats, err := s.getInterfaceStatistics(dockerBridge)
-		if err == nil {
+		if bridgeStats, ok := inventory.stats(dockerBridge); ok {
 			stats.DockerToTunnelPackets = bridgeStats.TxPackets / 10
 			stats.DockerToTunnelBytes = bridgeStats.TxBytes / 10
 		}
@@ -684,32 +640,12 @@ func (s *NetworkService) calculateTunnelDockerStatsExact(tunnelInterface, docker
 	return stats
 }
 
-// generateRecommendations 生成优化建议
-// 检查接口是否存在
+// checkInterfaceExists 检查接口是否存在（通过rtnetlink按名称查询）
 func (s *NetworkService) checkInterfaceExists(interfaceName string) (bool, error) {
-	cmd := exec.Command("ip", "link", "show", interfaceName)
-	err := cmd.Run()
-	return err == nil, nil
-}
-
-// 获取接口IP地址
-func (s *NetworkService) getInterfaceIPs(interfaceName string) ([]string, error) {
-	cmd := exec.Command("ip", "addr", "show", interfaceName)
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-
-	var ips []string
-	ipRegex := regexp.MustCompile(`inet\s+([0-9.]+/[0-9]+)`)
-	matches := ipRegex.FindAllStringSubmatch(string(output), -1)
-	for _, match := range matches {
-		if len(match) > 1 {
-			ips = append(ips, strings.Split(match[1], "/")[0])
-		}
+	if _, err := net.InterfaceByName(interfaceName); err != nil {
+		return false, nil
 	}
-
-	return ips, nil
+	return true, nil
 }
 
 // 连通性测试结果
@@ -890,60 +826,13 @@ func (s *NetworkService) testConnectivity(tunnelInterface, dockerBridge string,
 	return result
 }
 
-// 获取接口统计信息
-type InterfaceStats struct {
-	RxPackets int64
-	TxPackets int64
-	RxBytes   int64
-	TxBytes   int64
-}
-
-func (s *NetworkService) getInterfaceStatistics(interfaceName string) (*InterfaceStats, error) {
-	cmd := exec.Command("cat", fmt.Sprintf("/sys/class/net/%s/statistics/rx_packets", interfaceName))
-	rxPacketsOutput, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-
-	cmd = exec.Command("cat", fmt.Sprintf("/sys/class/net/%s/statistics/tx_packets", interfaceName))
-	txPacketsOutput, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-
-	cmd = exec.Command("cat", fmt.Sprintf("/sys/class/net/%s/statistics/rx_bytes", interfaceName))
-	rxBytesOutput, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-
-	cmd = exec.Command("cat", fmt.Sprintf("/sys/class/net/%s/statistics/tx_bytes", interfaceName))
-	txBytesOutput, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-
-	rxPackets, _ := strconv.ParseInt(strings.TrimSpace(string(rxPacketsOutput)), 10, 64)
-	txPackets, _ := strconv.ParseInt(strings.TrimSpace(string(txPacketsOutput)), 10, 64)
-	rxBytes, _ := strconv.ParseInt(strings.TrimSpace(string(rxBytesOutput)), 10, 64)
-	txBytes, _ := strconv.ParseInt(strings.TrimSpace(string(txBytesOutput)), 10, 64)
-
-	return &InterfaceStats{
-		RxPackets: rxPackets,
-		TxPackets: txPackets,
-		RxBytes:   rxBytes,
-		TxBytes:   txBytes,
-	}, nil
-}
-
 // 精确获取NAT规则
 func (s *NetworkService) getNATRulesExact(tunnelInterface, dockerBridge string) ([]models.IPTablesRule, error) {
 	var allRules []models.IPTablesRule
 	chains := []string{"PREROUTING", "POSTROUTING", "OUTPUT"}
 
 	for _, chain := range chains {
-		cmd := exec.Command("iptables", "-t", "nat", "-L", chain, "-n", "-v", "--line-numbers")
-		output, err := cmd.Output()
+		output, err := runCommand("iptables", "-t", "nat", "-L", chain, "-n", "-v", "--line-numbers")
 		if err != nil {
 			log.Printf("[WARN] Failed to get NAT rules from chain %s: %v", chain, err)
 			continue
@@ -958,8 +847,7 @@ func (s *NetworkService) getNATRulesExact(tunnelInterface, dockerBridge string)
 
 // getDockerIsolationRules 获取DOCKER-ISOLATION-STAGE-2链规则
 func (s *NetworkService) getDockerIsolationRules(tunnelInterface, dockerBridge string) ([]models.IPTablesRule, error) {
-	cmd := exec.Command("iptables", "-t", "filter", "-L", "DOCKER-ISOLATION-STAGE-2", "-n", "-v", "--line-numbers")
-	output, err := cmd.Output()
+	output, err := runCommand("iptables", "-t", "filter", "-L", "DOCKER-ISOLATION-STAGE-2", "-n", "-v", "--line-numbers")
 	if err != nil {
 		log.Printf("[WARN] Failed to get DOCKER-ISOLATION-STAGE-2 rules: %v", err)
 		// 如果链不存在，返回空规则列表而不是错误
@@ -1560,86 +1448,33 @@ func (s *NetworkService) checkHpingAvailability() bool {
 }
 
 // getTunnelDestinationIP 获取tunnel接口的destination IP
-func (s *NetworkService) getTunnelDestinationIP(tunnelInterface string) string {
+func (s *NetworkService) getTunnelDestinationIP(inventory *interfaceInventory, tunnelInterface string) string {
 	log.Printf("[DEBUG] getTunnelDestinationIP: Getting destination IP for %s", tunnelInterface)
 
-	// 方法1: 尝试使用 ip addr show 命令
-	cmd := exec.Command("ip", "addr", "show", tunnelInterface)
-	output, err := cmd.Output()
-	if err != nil {
-		log.Printf("[DEBUG] getTunnelDestinationIP: Failed to get interface info with ip command: %v", err)
-	} else {
-		log.Printf("[DEBUG] getTunnelDestinationIP: ip addr output: %s", string(output))
-
-		lines := strings.Split(string(output), "\n")
-		for _, line := range lines {
-			if strings.Contains(line, "inet ") && strings.Contains(line, "peer") {
-				// 解析 "inet 192.168.252.1 peer 192.168.252.2/32" 格式
-				parts := strings.Fields(line)
-				for i, part := range parts {
-					if part == "peer" && i+1 < len(parts) {
-						peerAddr := strings.Split(parts[i+1], "/")[0]
-						log.Printf("[DEBUG] getTunnelDestinationIP: Found peer destination IP: %s", peerAddr)
-						return peerAddr
-					}
-				}
-			}
-		}
+	// 方法1: 点对点地址的对端（"inet 192.168.252.1 peer 192.168.252.2/32"）
+	if _, peer := inventory.peerAddress(tunnelInterface); peer != "" {
+		log.Printf("[DEBUG] getTunnelDestinationIP: Found peer destination IP: %s", peer)
+		return peer
 	}
 
-	// 方法2: 尝试使用 ifconfig 命令 (适用于显示destination的情况)
-	log.Printf("[DEBUG] getTunnelDestinationIP: Trying ifconfig command for %s", tunnelInterface)
-	ifconfigCmd := exec.Command("ifconfig", tunnelInterface)
-	ifconfigOutput, ifconfigErr := ifconfigCmd.Output()
-	if ifconfigErr != nil {
-		log.Printf("[DEBUG] getTunnelDestinationIP: Failed to get interface info with ifconfig: %v", ifconfigErr)
-	} else {
-		log.Printf("[DEBUG] getTunnelDestinationIP: ifconfig output: %s", string(ifconfigOutput))
-
-		lines := strings.Split(string(ifconfigOutput), "\n")
-		for _, line := range lines {
-			// 解析 "inet 192.168.252.1  netmask 255.255.255.255  destination 192.168.252.2" 格式
-			if strings.Contains(line, "inet ") && strings.Contains(line, "destination") {
-				parts := strings.Fields(line)
-				for i, part := range parts {
-					if part == "destination" && i+1 < len(parts) {
-						destAddr := parts[i+1]
-						log.Printf("[DEBUG] getTunnelDestinationIP: Found destination IP: %s", destAddr)
-						return destAddr
-					}
-				}
-			}
-		}
+	// 方法2: 主路由表中经该接口的主机路由（"192.168.252.2 dev tun0 proto kernel scope link"）
+	link, ok := inventory.link(tunnelInterface)
+	if !ok {
+		return ""
 	}
-
-	// 方法3: 尝试从 /proc/net/route 获取点对点接口的目标地址
-	log.Printf("[DEBUG] getTunnelDestinationIP: Trying to get destination from route table")
-	routeCmd := exec.Command("ip", "route", "show", "dev", tunnelInterface)
-	routeOutput, routeErr := routeCmd.Output()
-	if routeErr != nil {
-		log.Printf("[DEBUG] getTunnelDestinationIP: Failed to get route info: %v", routeErr)
-	} else {
-		log.Printf("[DEBUG] getTunnelDestinationIP: route output: %s", string(routeOutput))
-
-		lines := strings.Split(string(routeOutput), "\n")
-		for _, line := range lines {
-			// 查找点对点路由，格式类似: "192.168.252.2 dev tun0 proto kernel scope link src 192.168.252.1"
-			if strings.Contains(line, tunnelInterface) && !strings.Contains(line, "0.0.0.0") {
-				parts := strings.Fields(line)
-				if len(parts) > 0 {
-					// 第一个字段通常是目标地址
-					destAddr := parts[0]
-					// 验证是否为有效IP地址
-					if net.ParseIP(destAddr) != nil {
-						log.Printf("[DEBUG] getTunnelDestinationIP: Found route destination IP: %s", destAddr)
-						return destAddr
-					}
-				}
-			}
+	routes, err := listNetlinkRoutes(syscall.AF_INET)
+	if err != nil {
+		log.Printf("[DEBUG] getTunnelDestinationIP: Failed to get route info: %v", err)
+		return ""
+	}
+	for _, route := range routes {
+		if route.OIF == link.Index && route.Table == rtTableMain && route.Type == rtnUnicast && route.DstLen == 32 && route.Dst != nil {
+			log.Printf("[DEBUG] getTunnelDestinationIP: Found route destination IP: %s", route.Dst)
+			return route.Dst.String()
 		}
 	}
 
-	log.Printf("[DEBUG] getTunnelDestinationIP: No destination IP found for %s using any method", tunnelInterface)
+	log.Printf("[DEBUG] getTunnelDestinationIP: No destination IP found for %s", tunnelInterface)
 	return ""
 }
 
@@ -1650,40 +1485,59 @@ func (s *NetworkService) GetTunnelInterfaceInfo(interfaceName string) (*models.T
 	log.Printf("[DEBUG] Getting tunnel interface info for: %s", interfaceName)
 
 	// 获取基础网络接口信息
-	interfaces, err := s.GetAllInterfaces()
+	inventory, err := loadInterfaceInventory()
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to load interfaces: %v", err)
 	}
-
-	var baseInterface models.NetworkInterface
-	found := false
-	for _, iface := range interfaces {
-		if iface.Name == interfaceName {
-			baseInterface = iface
-			found = true
-			break
-		}
-	}
-
-	if !found {
-		return nil, fmt.Errorf("interface %s not found", interfaceName)
+	baseInterface, err := s.findInterface(inventory, interfaceName)
+	if err != nil {
+		return nil, err
 	}
 
 	tunnelInfo := &models.TunnelInterfaceInfo{
 		NetworkInterface: baseInterface,
 	}
 
-	// 确定隧道类型
-	if strings.HasPrefix(interfaceName, "tun") {
+	// 确定隧道类型：按内核链路类型识别，tun设备按 IFLA_TUN_TYPE 区分tun/tap，无类型信息时按名称推断
+	switch {
+	case baseInterface.Tun != nil:
+		tunnelInfo.TunnelType = baseInterface.Tun.Mode
+	case baseInterface.Kind != "":
+		tunnelInfo.TunnelType = baseInterface.Kind
+	case strings.HasPrefix(interfaceName, "tun"):
 		tunnelInfo.TunnelType = "tun"
-	} else if strings.HasPrefix(interfaceName, "tap") {
+	case strings.HasPrefix(interfaceName, "tap"):
 		tunnelInfo.TunnelType = "tap"
-	} else {
+	default:
 		tunnelInfo.TunnelType = "unknown"
 	}
+	tunnelInfo.EncryptionType = "unknown"
+	if baseInterface.Kind != "" {
+		tunnelInfo.EncryptionType = tunnelEncryption(baseInterface.Kind)
+	}
 
-	// 获取隧道配置信息
-	tunnelInfo.LocalAddress, tunnelInfo.PeerAddress = s.getTunnelAddresses(interfaceName)
+	// 获取隧道配置信息：优先点对点地址，否则使用外层封装的端点
+	if link, ok := inventory.link(interfaceName); ok {
+		tunnelInfo.Underlay = parseTunnelUnderlay(link.Kind, link.InfoData, inventory.byIndex)
+	}
+	tunnelInfo.LocalAddress, tunnelInfo.PeerAddress = inventory.peerAddress(interfaceName)
+	if tunnelInfo.PeerAddress == "" && tunnelInfo.Underlay != nil {
+		tunnelInfo.LocalAddress, tunnelInfo.PeerAddress = tunnelInfo.Underlay.Local, tunnelInfo.Underlay.Remote
+	}
+
+	if baseInterface.Kind == "wireguard" {
+		device, err := getWireGuardDevice(interfaceName)
+		if err != nil {
+			log.Printf("[WARN] Failed to get WireGuard peers for %s: %v", interfaceName, err)
+		} else {
+			tunnelInfo.WireGuard = device
+			// 单一对端时以其端点作为对端地址
+			if tunnelInfo.PeerAddress == "" && len(device.Peers) == 1 && device.Peers[0].Endpoint != "" {
+				host, _, _ := net.SplitHostPort(device.Peers[0].Endpoint)
+				tunnelInfo.PeerAddress = host
+			}
+		}
+	}
 
 	// 获取相关规则
 	tunnelInfo.RelatedRules, _ = s.GetTunnelInterfaceRules(interfaceName)
@@ -1694,34 +1548,6 @@ func (s *NetworkService) GetTunnelInterfaceInfo(interfaceName string) (*models.T
 	return tunnelInfo, nil
 }
 
-// getTunnelAddresses 获取隧道地址信息
-func (s *NetworkService) getTunnelAddresses(interfaceName string) (string, string) {
-	cmd := exec.Command("ip", "addr", "show", interfaceName)
-	output, err := cmd.Output()
-	if err != nil {
-		return "", ""
-	}
-
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "inet ") && strings.Contains(line, "peer") {
-			// 解析 "inet 192.168.252.1 peer 192.168.252.2/32" 格式
-			parts := strings.Fields(line)
-			for i, part := range parts {
-				if part == "inet" && i+1 < len(parts) {
-					localAddr := parts[i+1]
-					if i+3 < len(parts) && parts[i+2] == "peer" {
-						peerAddr := strings.Split(parts[i+3], "/")[0]
-						return localAddr, peerAddr
-					}
-				}
-			}
-		}
-	}
-
-	return "", ""
-}
-
 // getConnectedBridges 获取连接的网桥列表
 func (s *NetworkService) getConnectedBridges(interfaceName string) []string {
 	var bridges []string
@@ -1755,7 +1581,7 @@ func (s *NetworkService) getConnectedBridges(interfaceName string) []string {
 	return bridges
 }
 
-// GetDockerBridges 获取Docker网桥信息（使用系统原生命令）
+// GetDockerBridges 获取Docker网桥信息（优先使用Docker Engine API，不可用时回退到系统命令）
 func (s *NetworkService) GetDockerBridges() ([]models.DockerBridge, error) {
 	log.Println("[DEBUG] NetworkService.GetDockerBridges called")
 
@@ -1767,8 +1593,19 @@ func (s *NetworkService) GetDockerBridges() ([]models.DockerBridge, error) {
 		return nil, fmt.Errorf("failed to get network interfaces: %v", err)
 	}
 
+	inventory, err := s.loadDockerInventory()
+	if err != nil {
+		log.Printf("[WARN] Docker API unavailable, falling back to system commands: %v", err)
+	}
+
 	// 筛选Docker相关的网桥接口
 	for _, iface := range interfaces {
+		if inventory != nil {
+			if network, ok := inventory.networks[iface.Name]; ok {
+				bridges = append(bridges, s.createBridgeFromDockerNetwork(iface, network, inventory.containers))
+				continue
+			}
+		}
 		if s.isDockerBridge(iface.Name) {
 			bridge, err := s.createBridgeFromInterface(iface)
 			if err != nil {
@@ -1783,6 +1620,117 @@ func (s *NetworkService) GetDockerBridges() ([]models.DockerBridge, error) {
 	return bridges, nil
 }
 
+// dockerInventory 从Docker Engine API读取的网络和容器
+type dockerInventory struct {
+	networks   map[string]DockerNetwork // 网桥名 -> bridge驱动的网络
+	containers []DockerContainerSummary
+}
+
+// loadDockerInventory 读取Docker网络和容器，Docker不可用时返回错误
+func (s *NetworkService) loadDockerInventory() (*dockerInventory, error) {
+	if !s.docker.Available() {
+		return nil, fmt.Errorf("docker socket %s not reachable", s.docker.socketPath)
+	}
+
+	networks, err := s.docker.ListNetworks()
+	if err != nil {
+		return nil, err
+	}
+	containers, err := s.docker.ListContainers()
+	if err != nil {
+		return nil, err
+	}
+
+	inventory := &dockerInventory{
+		networks:   make(map[string]DockerNetwork),
+		containers: containers,
+	}
+	for _, network := range networks {
+		if network.Driver != "bridge" {
+			continue
+		}
+		if name := network.BridgeName(); name != "" {
+			inventory.networks[name] = network
+		}
+	}
+	return inventory, nil
+}
+
+// createBridgeFromDockerNetwork 根据Docker网络信息创建网桥信息
+func (s *NetworkService) createBridgeFromDockerNetwork(iface models.NetworkInterface, network DockerNetwork, containers []DockerContainerSummary) models.DockerBridge {
+	bridge := models.DockerBridge{
+		Name:      iface.Name,
+		NetworkID: network.ID,
+		Driver:    network.Driver,
+		Scope:     network.Scope,
+		Interface: iface,
+		Internal:  network.Internal,
+		Labels:    network.Labels,
+		Source:    "docker_api",
+		IPAMConfig: models.DockerIPAMConfig{
+			Driver:  network.IPAM.Driver,
+			Config:  []models.DockerSubnet{},
+			Options: network.IPAM.Options,
+		},
+		Containers: dockerContainersOnNetwork(network.ID, containers),
+	}
+	if endpoints, err := s.getBridgeEndpoints(iface.Name); err == nil {
+		enrichContainersWithEndpoints(bridge.Containers, endpoints)
+	} else {
+		log.Printf("[WARN] Failed to resolve veth peers for %s: %v", iface.Name, err)
+	}
+	if bridge.IPAMConfig.Options == nil {
+		bridge.IPAMConfig.Options = make(map[string]string)
+	}
+
+	if len(iface.IPAddresses) > 0 {
+		bridge.IPAddress = iface.IPAddresses[0]
+	}
+	for _, config := range network.IPAM.Config {
+		bridge.IPAMConfig.Config = append(bridge.IPAMConfig.Config, models.DockerSubnet{
+			Subnet:  config.Subnet,
+			Gateway: config.Gateway,
+		})
+	}
+
+	return bridge
+}
+
+// dockerContainersOnNetwork 获取连接到指定网络的容器
+func dockerContainersOnNetwork(networkID string, containers []DockerContainerSummary) []models.DockerContainer {
+	result := []models.DockerContainer{}
+	for _, summary := range containers {
+		for _, endpoint := range summary.Network.Networks {
+			if endpoint.NetworkID != networkID {
+				continue
+			}
+
+			container := models.DockerContainer{
+				ID:          summary.ID,
+				Name:        summary.Name(),
+				IPAddress:   endpoint.IPAddress,
+				IPv6Address: endpoint.GlobalIPv6Address,
+				MACAddress:  endpoint.MacAddress,
+				Gateway:     endpoint.Gateway,
+				Image:       summary.Image,
+				State:       summary.State,
+				Status:      summary.Status,
+				Labels:      summary.Labels,
+			}
+			for _, port := range summary.Ports {
+				container.Ports = append(container.Ports, models.DockerPort{
+					HostIP:        port.IP,
+					HostPort:      port.PublicPort,
+					ContainerPort: port.PrivatePort,
+					Protocol:      port.Type,
+				})
+			}
+			result = append(result, container)
+		}
+	}
+	return result
+}
+
 // isDockerBridge 判断是否为Docker网桥
 func (s *NetworkService) isDockerBridge(name string) bool {
 	// Docker默认网桥
@@ -1803,6 +1751,7 @@ func (s *NetworkService) createBridgeFromInterface(iface models.NetworkInterface
 		Driver:    "bridge",
 		Scope:     "local",
 		Interface: iface,
+		Source:    "system",
 	}
 
 	// 设置主要IP地址（取第一个IP地址）
@@ -1845,101 +1794,144 @@ func (s *NetworkService) createBridgeFromInterface(iface models.NetworkInterface
 	return bridge, nil
 }
 
-// getBridgeIPAMConfig 获取网桥的IPAM配置（使用系统原生命令）
+// getBridgeIPAMConfig 根据网桥上配置的IPv4地址推断IPAM配置
 func (s *NetworkService) getBridgeIPAMConfig(bridgeName string) (models.DockerIPAMConfig, error) {
 	config := models.DockerIPAMConfig{
 		Driver: "default",
 	}
 
-	// 使用ip命令获取网桥的IP配置
-	cmd := exec.Command("ip", "addr", "show", bridgeName)
-	output, err := cmd.Output()
+	inventory, err := loadInterfaceInventory()
 	if err != nil {
 		return config, err
 	}
+	link, ok := inventory.link(bridgeName)
+	if !ok {
+		return config, fmt.Errorf("interface %s not found", bridgeName)
+	}
 
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "inet ") {
-			// 解析inet行，格式如: inet 172.17.0.1/16 brd 172.17.255.255 scope global docker0
-			fields := strings.Fields(line)
-			if len(fields) >= 2 {
-				cidr := fields[1]
-				if ip, ipNet, err := net.ParseCIDR(cidr); err == nil {
-					subnet := models.DockerSubnet{
-						Subnet:  ipNet.String(),
-						Gateway: ip.String(),
-					}
-					config.Config = append(config.Config, subnet)
-				}
-			}
+	for _, addr := range inventory.addrs[link.Index] {
+		if addr.Family != syscall.AF_INET {
+			continue
+		}
+		// 如 172.17.0.1/16 -> 子网 172.17.0.0/16，网关 172.17.0.1
+		if ip, ipNet, err := net.ParseCIDR(addr.CIDR()); err == nil {
+			config.Config = append(config.Config, models.DockerSubnet{
+				Subnet:  ipNet.String(),
+				Gateway: ip.String(),
+			})
 		}
 	}
 
 	return config, nil
 }
 
-// getBridgeContainers 获取连接到网桥的容器信息（使用系统原生命令）
+// getBridgeContainers 获取连接到网桥的端点信息
+// 通过 /sys/class/net/<bridge>/brif 枚举端口，再按 ifindex/iflink 在各网络命名空间中查找对端，
+// 适用于Docker、Podman以及 ip netns 等不依赖Docker守护进程的场景
 func (s *NetworkService) getBridgeContainers(bridgeName string) ([]models.DockerContainer, error) {
-	var containers []models.DockerContainer
+	containers := []models.DockerContainer{}
 
-	// 使用ip命令获取连接到网桥的veth接口
-	cmd := exec.Command("ip", "link", "show", "master", bridgeName)
-	output, err := cmd.Output()
+	endpoints, err := s.getBridgeEndpoints(bridgeName)
 	if err != nil {
-		// 如果命令失败，返回空列表而不是错误
-		return containers, nil
+		return containers, err
 	}
-
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "veth") {
-			// 解析veth接口信息
-			fields := strings.Fields(line)
-			if len(fields) >= 2 {
-				vethName := strings.TrimSuffix(fields[1], ":")
-
-				// 尝试获取veth接口的对端信息
-				container, err := s.getContainerFromVeth(vethName)
-				if err == nil {
-					containers = append(containers, container)
-				}
-			}
-		}
+	for _, endpoint := range endpoints {
+		containers = append(containers, s.getContainerFromVeth(endpoint))
 	}
 
 	return containers, nil
 }
 
-// getContainerFromVeth 从veth接口获取容器信息
-func (s *NetworkService) getContainerFromVeth(vethName string) (models.DockerContainer, error) {
-	container := models.DockerContainer{}
-
-	// 获取veth接口的IP地址
-	cmd := exec.Command("ip", "addr", "show", vethName)
-	output, err := cmd.Output()
+// getBridgeEndpoints 解析网桥各端口在对端命名空间中的端点
+func (s *NetworkService) getBridgeEndpoints(bridgeName string) ([]*vethEndpoint, error) {
+	resolver, err := newNetnsResolver()
 	if err != nil {
-		return container, err
+		return nil, err
+	}
+	endpoints, err := resolver.resolveBridgeEndpoints(bridgeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve ports of bridge %s: %v", bridgeName, err)
+	}
+	return endpoints, nil
+}
+
+// getContainerFromVeth 根据veth对端端点构造容器信息
+func (s *NetworkService) getContainerFromVeth(endpoint *vethEndpoint) models.DockerContainer {
+	container := models.DockerContainer{
+		ID:            endpoint.ContainerID,
+		MACAddress:    endpoint.PeerMAC,
+		PID:           endpoint.PID,
+		Process:       endpoint.Process,
+		Cgroup:        endpoint.Cgroup,
+		NetNS:         endpoint.NetNS,
+		Runtime:       endpoint.Runtime,
+		HostInterface: endpoint.HostInterface,
+		PeerInterface: endpoint.PeerName,
+		PeerIndex:     endpoint.PeerIndex,
+	}
+	if container.ID == "" {
+		container.ID = endpoint.NetNS
+	}
+
+	// 名称优先级：容器ID短格式 > 命名空间名称 > 进程名
+	switch {
+	case len(endpoint.ContainerID) >= 12:
+		container.Name = endpoint.ContainerID[:12]
+	case endpoint.Runtime == "netns":
+		container.Name = endpoint.NetNS
+	case endpoint.Process != "":
+		container.Name = fmt.Sprintf("%s-%d", endpoint.Process, endpoint.PID)
+	default:
+		container.Name = endpoint.NetNS
+	}
+	if endpoint.PID > 0 {
+		container.State = "running"
 	}
 
-	// 解析IP地址（虽然veth接口通常没有IP，但我们可以尝试）
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "link/ether ") {
-			fields := strings.Fields(line)
-			if len(fields) >= 2 {
-				container.MACAddress = fields[1]
+	applyEndpointAddresses(&container, endpoint.Addresses)
+	return container
+}
+
+// applyEndpointAddresses 从对端接口地址中取IPv4和全局IPv6地址
+func applyEndpointAddresses(container *models.DockerContainer, addresses []string) {
+	for _, cidr := range addresses {
+		ip, _, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		if ip.To4() != nil {
+			if container.IPAddress == "" {
+				container.IPAddress = ip.String()
 			}
+		} else if ip.IsGlobalUnicast() && container.IPv6Address == "" {
+			container.IPv6Address = ip.String()
 		}
 	}
+}
 
-	// 设置基本信息
-	container.ID = vethName
-	container.Name = "container-" + vethName[4:] // 移除veth前缀
+// enrichContainersWithEndpoints 按MAC地址将命名空间端点信息合并到Docker API返回的容器中
+func enrichContainersWithEndpoints(containers []models.DockerContainer, endpoints []*vethEndpoint) {
+	byMAC := make(map[string]*vethEndpoint, len(endpoints))
+	for _, endpoint := range endpoints {
+		if endpoint.PeerMAC != "" {
+			byMAC[strings.ToLower(endpoint.PeerMAC)] = endpoint
+		}
+	}
 
-	return container, nil
+	for i := range containers {
+		endpoint, ok := byMAC[strings.ToLower(containers[i].MACAddress)]
+		if !ok {
+			continue
+		}
+		containers[i].PID = endpoint.PID
+		containers[i].Process = endpoint.Process
+		containers[i].Cgroup = endpoint.Cgroup
+		containers[i].NetNS = endpoint.NetNS
+		containers[i].Runtime = "docker"
+		containers[i].HostInterface = endpoint.HostInterface
+		containers[i].PeerInterface = endpoint.PeerName
+		containers[i].PeerIndex = endpoint.PeerIndex
+	}
 }
 
 // GetBridgeRules 获取指定网桥的iptables规则
@@ -1964,113 +1956,60 @@ func (s *NetworkService) GetBridgeRules(bridgeName string) ([]models.IPTablesRul
 	return bridgeRules, nil
 }
 
-// GetNetworkConnections 获取网络连接信息（使用netstat命令）
-func (s *NetworkService) GetNetworkConnections() ([]models.NetworkConnection, error) {
-	log.Println("[DEBUG] Getting network connections using netstat")
+// GetNetworkConnections 获取所有网络命名空间中的套接字（解析 /proc/net），附带所属进程、容器和命名空间
+func (s *NetworkService) GetNetworkConnections(filter ConnectionFilter) ([]models.NetworkConnection, error) {
+	log.Println("[DEBUG] Getting network connections from /proc/net")
 
-	cmd := exec.Command("netstat", "-tuln")
-	output, err := cmd.Output()
+	hostInode, err := netnsInode("/proc/self/ns/net")
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute netstat: %v", err)
+		return nil, fmt.Errorf("failed to read host network namespace: %v", err)
 	}
-
-	var connections []models.NetworkConnection
-	lines := strings.Split(string(output), "\n")
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "tcp") || strings.HasPrefix(line, "udp") {
-			fields := strings.Fields(line)
-			if len(fields) >= 4 {
-				connection := models.NetworkConnection{
-					Protocol:     fields[0],
-					LocalAddress: fields[3],
-				}
-				if len(fields) >= 5 {
-					connection.ForeignAddress = fields[4]
-				}
-				if len(fields) >= 6 {
-					connection.State = fields[5]
-				}
-				connections = append(connections, connection)
-			}
-		}
-	}
-
-	log.Printf("[DEBUG] Found %d network connections", len(connections))
-	return connections, nil
-}
-
-// GetRouteTable 获取路由表信息（使用ip route命令）
-func (s *NetworkService) GetRouteTable() ([]models.RouteEntry, error) {
-	log.Println("[DEBUG] Getting route table using ip route")
-
-	cmd := exec.Command("ip", "route", "show")
-	output, err := cmd.Output()
+	namespaces, err := listNetNamespaces()
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute ip route: %v", err)
+		return nil, err
 	}
 
-	var routes []models.RouteEntry
-	lines := strings.Split(string(output), "\n")
+	owners := socketOwners()
+	cgroups := make(map[int]string)
+	connections := []models.NetworkConnection{}
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
+	for _, ns := range namespaces {
+		nsLabel := fmt.Sprintf("net:[%d]", ns.Inode)
+		if ns.Inode == hostInode {
+			nsLabel = "host"
+		}
+		if filter.NetNS != "" && filter.NetNS != nsLabel {
 			continue
 		}
 
-		route := s.parseRouteEntry(line)
-		if route.Destination != "" {
-			routes = append(routes, route)
+		nsConnections, err := readNamespaceSockets(ns)
+		if err != nil {
+			log.Printf("[WARN] Failed to read sockets in %s: %v", nsLabel, err)
+			continue
 		}
-	}
-
-	log.Printf("[DEBUG] Found %d route entries", len(routes))
-	return routes, nil
-}
-
-// parseRouteEntry 解析路由条目
-func (s *NetworkService) parseRouteEntry(line string) models.RouteEntry {
-	fields := strings.Fields(line)
-	route := models.RouteEntry{}
-
-	if len(fields) == 0 {
-		return route
-	}
 
-	// 解析目标网络
-	if fields[0] == "default" {
-		route.Destination = "0.0.0.0/0"
-	} else {
-		route.Destination = fields[0]
-	}
-
-	// 解析其他字段
-	for i := 1; i < len(fields)-1; i++ {
-		switch fields[i] {
-		case "via":
-			if i+1 < len(fields) {
-				route.Gateway = fields[i+1]
-			}
-		case "dev":
-			if i+1 < len(fields) {
-				route.Interface = fields[i+1]
-			}
-		case "src":
-			if i+1 < len(fields) {
-				route.Source = fields[i+1]
-			}
-		case "metric":
-			if i+1 < len(fields) {
-				if metric, err := strconv.Atoi(fields[i+1]); err == nil {
-					route.Metric = metric
+		for _, connection := range nsConnections {
+			connection.NetNS = nsLabel
+			if owner, ok := owners[connection.Inode]; ok && connection.Inode != 0 {
+				connection.PID = owner.pid
+				connection.Process = owner.process
+				content, cached := cgroups[owner.pid]
+				if !cached {
+					if data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", owner.pid)); err == nil {
+						content = string(data)
+					}
+					cgroups[owner.pid] = content
 				}
+				connection.ContainerID, connection.Runtime = containerFromCgroup(content)
+			}
+			if filter.matches(connection) {
+				connections = append(connections, connection)
 			}
 		}
 	}
 
-	return route
+	log.Printf("[DEBUG] Found %d network connections", len(connections))
+	return connections, nil
 }
 
 // isRuleRelatedToBridge 判断规则是否与网桥相关
@@ -2097,95 +2036,21 @@ func (s *NetworkService) isRuleRelatedToBridge(rule models.IPTablesRule, bridgeN
 	return false
 }
 
-// FixConnectivity 修复隧道接口与Docker网桥之间的连通性问题
-func (s *NetworkService) FixConnectivity(tunnelInterface, dockerBridge string) (*models.ConnectivityFixResult, error) {
+// FixConnectivity 修复隧道接口与Docker网桥之间的连通性问题（生成计划后立即执行）
+func (s *NetworkService) FixConnectivity(tunnelInterface, dockerBridge, username string) (*models.ConnectivityFixResult, error) {
 	log.Printf("[DEBUG] NetworkService.FixConnectivity called with tunnel: %s, bridge: %s", tunnelInterface, dockerBridge)
 
-	result := &models.ConnectivityFixResult{
-		TunnelInterface: tunnelInterface,
-		DockerBridge:    dockerBridge,
-		FixedIssues:     []string{},
-		AppliedRules:    []string{},
-		Success:         false,
-	}
-
-	// 1. 检查接口是否存在
-	tunnelExists, err := s.checkInterfaceExists(tunnelInterface)
+	plan, err := s.PlanConnectivityFix(tunnelInterface, dockerBridge)
 	if err != nil {
-		return result, fmt.Errorf("failed to check tunnel interface: %v", err)
-	}
-	if !tunnelExists {
-		return result, fmt.Errorf("tunnel interface %s does not exist", tunnelInterface)
-	}
-
-	bridgeExists, err := s.checkInterfaceExists(dockerBridge)
-	if err != nil {
-		return result, fmt.Errorf("failed to check docker bridge: %v", err)
-	}
-	if !bridgeExists {
-		return result, fmt.Errorf("docker bridge %s does not exist", dockerBridge)
-	}
-
-	// 2. 应用修复规则（按手动脚本的顺序执行）
-	fixedCount := 0
-
-	log.Printf("[DEBUG] Starting connectivity fix with script-compatible order")
-
-	// 3.1 首先添加FORWARD规则（对应脚本中的步骤1和2）
-	if err := s.ensureForwardRulesOptimized(tunnelInterface, dockerBridge, result); err != nil {
-		log.Printf("[WARN] Failed to ensure forward rules: %v", err)
-	} else {
-		fixedCount++
-		log.Printf("[DEBUG] ✓ FORWARD rules applied")
-	}
-
-	// 3.2 然后处理Docker隔离规则（对应脚本中的步骤3）
-	if err := s.fixDockerIsolationRulesOptimized(tunnelInterface, dockerBridge, result); err != nil {
-		log.Printf("[WARN] Failed to fix Docker isolation rules: %v", err)
-	} else {
-		fixedCount++
-		log.Printf("[DEBUG] ✓ Docker isolation rules applied")
-	}
-
-	// 3.3 确保接口状态正常
-	if err := s.ensureInterfaceState(tunnelInterface, dockerBridge, result); err != nil {
-		log.Printf("[WARN] Failed to ensure interface state: %v", err)
-	} else {
-		fixedCount++
-	}
-
-	// 3.4 清理可能阻塞的规则（最后执行，避免干扰前面的规则）
-	if err := s.cleanupBlockingRulesOptimized(tunnelInterface, dockerBridge, result); err != nil {
-		log.Printf("[WARN] Failed to cleanup blocking rules: %v", err)
-	} else {
-		fixedCount++
-	}
-
-	// 4. 验证修复结果
-	if fixedCount > 0 {
-		// 等待规则生效
-		time.Sleep(2 * time.Second)
-		result.Success = true
-		result.FixedIssues = append(result.FixedIssues, fmt.Sprintf("成功应用 %d 项修复", fixedCount))
-
-		// 记录详细的修复信息
-		log.Printf("[DEBUG] === Connectivity Fix Summary ===")
-		log.Printf("[DEBUG] Tunnel Interface: %s", tunnelInterface)
-		log.Printf("[DEBUG] Docker Bridge: %s", dockerBridge)
-		log.Printf("[DEBUG] Applied Rules Count: %d", len(result.AppliedRules))
-		for i, rule := range result.AppliedRules {
-			log.Printf("[DEBUG] Rule %d: %s", i+1, rule)
-		}
-		log.Printf("[DEBUG] Fixed Issues Count: %d", len(result.FixedIssues))
-		for i, issue := range result.FixedIssues {
-			log.Printf("[DEBUG] Issue %d: %s", i+1, issue)
-		}
-		log.Printf("[DEBUG] === End Summary ===")
-	} else {
-		log.Printf("[DEBUG] No rules were applied during connectivity fix")
-	}
-
-	log.Printf("[DEBUG] Connectivity fix completed. Success: %v, Fixed issues: %d", result.Success, len(result.FixedIssues))
+		return &models.ConnectivityFixResult{
+			TunnelInterface: tunnelInterface,
+			DockerBridge:    dockerBridge,
+			FixedIssues:     []string{},
+			AppliedRules:    []string{},
+		}, err
+	}
+	result := runConnectivityPlan(plan)
+	recordConnectivityChangeSet(plan, result, username)
 	return result, nil
 }
 
@@ -2222,16 +2087,12 @@ func (s *NetworkService) fixDockerIsolationRules(tunnelInterface, dockerBridge s
 
 	// 尝试在DOCKER-ISOLATION-STAGE-2链的开头添加允许规则
 	// 这样可以在DROP规则之前允许特定的隧道接口通信
-	allowCmd := exec.Command("iptables", "-I", "DOCKER-ISOLATION-STAGE-2", "1",
-		"-i", tunnelInterface, "-o", dockerBridge, "-j", "ACCEPT")
-
-	if err := allowCmd.Run(); err != nil {
+	if _, err := runCommand("iptables", "-I", "DOCKER-ISOLATION-STAGE-2", "1",
+		"-i", tunnelInterface, "-o", dockerBridge, "-j", "ACCEPT"); err != nil {
 		log.Printf("[WARN] Failed to add isolation bypass rule: %v", err)
 		// 尝试另一种方法：添加返回规则
-		returnCmd := exec.Command("iptables", "-I", "DOCKER-ISOLATION-STAGE-2", "1",
-			"-i", dockerBridge, "-o", tunnelInterface, "-j", "ACCEPT")
-
-		if err := returnCmd.Run(); err != nil {
+		if _, err := runCommand("iptables", "-I", "DOCKER-ISOLATION-STAGE-2", "1",
+			"-i", dockerBridge, "-o", tunnelInterface, "-j", "ACCEPT"); err != nil {
 			log.Printf("[WARN] Failed to add isolation return rule: %v", err)
 			return err
 		} else {
@@ -2247,10 +2108,8 @@ func (s *NetworkService) fixDockerIsolationRules(tunnelInterface, dockerBridge s
 			fmt.Sprintf("iptables -I DOCKER-ISOLATION-STAGE-2 1 -i %s -o %s -j ACCEPT", tunnelInterface, dockerBridge))
 
 		// 同时添加返回路径规则
-		returnCmd := exec.Command("iptables", "-I", "DOCKER-ISOLATION-STAGE-2", "2",
-			"-i", dockerBridge, "-o", tunnelInterface, "-j", "ACCEPT")
-
-		if err := returnCmd.Run(); err == nil {
+		if _, err := runCommand("iptables", "-I", "DOCKER-ISOLATION-STAGE-2", "2",
+			"-i", dockerBridge, "-o", tunnelInterface, "-j", "ACCEPT"); err == nil {
 			result.FixedIssues = append(result.FixedIssues,
 				fmt.Sprintf("添加Docker隔离返回规则: %s -> %s", dockerBridge, tunnelInterface))
 			result.AppliedRules = append(result.AppliedRules,
@@ -2265,8 +2124,7 @@ func (s *NetworkService) fixDockerIsolationRules(tunnelInterface, dockerBridge s
 // checkIsolationRuleExists 检查隔离规则是否已存在
 func (s *NetworkService) checkIsolationRuleExists(inInterface, outInterface, target string) bool {
 	// 使用 iptables -C 命令精确检查Docker隔离规则是否存在
-	cmd := exec.Command("iptables", "-C", "DOCKER-ISOLATION-STAGE-2", "-i", inInterface, "-o", outInterface, "-j", target)
-	err := cmd.Run()
+	_, err := runCommand("iptables", "-C", "DOCKER-ISOLATION-STAGE-2", "-i", inInterface, "-o", outInterface, "-j", target)
 
 	if err == nil {
 		log.Printf("[DEBUG] Found existing isolation rule: %s -> %s (%s)", inInterface, outInterface, target)
@@ -2281,8 +2139,7 @@ func (s *NetworkService) checkIsolationRuleExists(inInterface, outInterface, tar
 func (s *NetworkService) checkForwardRuleExists(inInterface, outInterface string) bool {
 	// 使用 iptables -C 命令精确检查规则是否存在
 	// 这是检查iptables规则的标准方法
-	cmd := exec.Command("iptables", "-C", "FORWARD", "-i", inInterface, "-o", outInterface, "-j", "ACCEPT")
-	err := cmd.Run()
+	_, err := runCommand("iptables", "-C", "FORWARD", "-i", inInterface, "-o", outInterface, "-j", "ACCEPT")
 
 	if err == nil {
 		log.Printf("[DEBUG] Found existing FORWARD rule: %s -> %s", inInterface, outInterface)
@@ -2296,9 +2153,8 @@ func (s *NetworkService) checkForwardRuleExists(inInterface, outInterface string
 // checkConntrackRuleExists 检查是否存在conntrack状态跟踪规则
 func (s *NetworkService) checkConntrackRuleExists(inInterface, outInterface string) bool {
 	// 使用 iptables -C 命令精确检查conntrack规则是否存在
-	cmd := exec.Command("iptables", "-C", "FORWARD", "-i", inInterface, "-o", outInterface,
+	_, err := runCommand("iptables", "-C", "FORWARD", "-i", inInterface, "-o", outInterface,
 		"-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT")
-	err := cmd.Run()
 
 	if err == nil {
 		log.Printf("[DEBUG] Found existing conntrack rule: %s -> %s", inInterface, outInterface)
@@ -2311,8 +2167,7 @@ func (s *NetworkService) checkConntrackRuleExists(inInterface, outInterface stri
 
 // checkNATRuleExists 检查NAT规则是否已存在
 func (s *NetworkService) checkNATRuleExists(rulePattern string) bool {
-	cmd := exec.Command("iptables", "-t", "nat", "-L", "POSTROUTING", "-n", "--line-numbers")
-	output, err := cmd.Output()
+	output, err := runCommand("iptables", "-t", "nat", "-L", "POSTROUTING", "-n", "--line-numbers")
 	if err != nil {
 		return false
 	}
@@ -2320,88 +2175,6 @@ func (s *NetworkService) checkNATRuleExists(rulePattern string) bool {
 	return strings.Contains(string(output), rulePattern)
 }
 
-// fixDockerIsolationRulesOptimized 优化的Docker隔离规则修复
-func (s *NetworkService) fixDockerIsolationRulesOptimized(tunnelInterface, dockerBridge string, result *models.ConnectivityFixResult) error {
-	log.Printf("[DEBUG] Fixing Docker isolation rules for %s <-> %s", tunnelInterface, dockerBridge)
-
-	// 检查DOCKER-ISOLATION-STAGE-2链是否存在
-	checkCmd := exec.Command("iptables", "-L", "DOCKER-ISOLATION-STAGE-2", "-n")
-	if err := checkCmd.Run(); err != nil {
-		log.Printf("[INFO] DOCKER-ISOLATION-STAGE-2 chain does not exist, skipping isolation rules")
-		return nil
-	}
-
-	// 3. 绕过 Docker 隔离规则 (与手动脚本保持一致)
-	// 添加隧道接口到网桥的RETURN规则
-	log.Printf("[DEBUG] Checking if isolation RETURN rule exists: %s -> %s", tunnelInterface, dockerBridge)
-	if !s.checkIsolationRuleExists(tunnelInterface, dockerBridge, "RETURN") {
-		log.Printf("[DEBUG] Adding isolation RETURN rule: %s -> %s", tunnelInterface, dockerBridge)
-		returnCmd1 := exec.Command("iptables", "-I", "DOCKER-ISOLATION-STAGE-2", "1",
-			"-i", tunnelInterface, "-o", dockerBridge, "-j", "RETURN")
-
-		if err := returnCmd1.Run(); err != nil {
-			log.Printf("[ERROR] Failed to add isolation RETURN rule: %v", err)
-			return fmt.Errorf("failed to add isolation RETURN rule: %v", err)
-		} else {
-			result.FixedIssues = append(result.FixedIssues,
-				fmt.Sprintf("绕过Docker隔离: %s -> %s", tunnelInterface, dockerBridge))
-			result.AppliedRules = append(result.AppliedRules,
-				fmt.Sprintf("iptables -I DOCKER-ISOLATION-STAGE-2 1 -i %s -o %s -j RETURN", tunnelInterface, dockerBridge))
-			log.Printf("[SUCCESS] ✓ Added isolation bypass rule: %s -> %s", tunnelInterface, dockerBridge)
-		}
-	} else {
-		log.Printf("[INFO] Isolation RETURN rule %s -> %s already exists, skipping", tunnelInterface, dockerBridge)
-	}
-
-	log.Printf("[DEBUG] Docker isolation rules fix completed for %s <-> %s", tunnelInterface, dockerBridge)
-	return nil
-}
-
-// ensureForwardRulesOptimized 优化的FORWARD规则确保方法
-func (s *NetworkService) ensureForwardRulesOptimized(tunnelInterface, dockerBridge string, result *models.ConnectivityFixResult) error {
-	log.Printf("[DEBUG] Ensuring FORWARD rules for %s <-> %s", tunnelInterface, dockerBridge)
-
-	// 1. 允许 tun0 → 容器网桥的转发 (插入到FORWARD链的第1位)
-	log.Printf("[DEBUG] Checking if FORWARD rule exists: %s -> %s", tunnelInterface, dockerBridge)
-	if !s.checkForwardRuleExists(tunnelInterface, dockerBridge) {
-		log.Printf("[DEBUG] Adding FORWARD rule: %s -> %s", tunnelInterface, dockerBridge)
-		cmd1 := exec.Command("iptables", "-I", "FORWARD", "1", "-i", tunnelInterface, "-o", dockerBridge, "-j", "ACCEPT")
-		if err := cmd1.Run(); err != nil {
-			log.Printf("[ERROR] Failed to add forward rule %s -> %s: %v", tunnelInterface, dockerBridge, err)
-			return fmt.Errorf("failed to add forward rule: %v", err)
-		} else {
-			rule1 := fmt.Sprintf("iptables -I FORWARD 1 -i %s -o %s -j ACCEPT", tunnelInterface, dockerBridge)
-			result.AppliedRules = append(result.AppliedRules, rule1)
-			result.FixedIssues = append(result.FixedIssues, fmt.Sprintf("添加FORWARD规则: %s -> %s", tunnelInterface, dockerBridge))
-			log.Printf("[SUCCESS] ✓ Added forward rule: %s -> %s", tunnelInterface, dockerBridge)
-		}
-	} else {
-		log.Printf("[INFO] FORWARD rule %s -> %s already exists, skipping", tunnelInterface, dockerBridge)
-	}
-
-	// 2. 允许回包 (插入到FORWARD链的第2位，使用conntrack模块)
-	log.Printf("[DEBUG] Checking if conntrack rule exists: %s -> %s", dockerBridge, tunnelInterface)
-	if !s.checkConntrackRuleExists(dockerBridge, tunnelInterface) {
-		log.Printf("[DEBUG] Adding conntrack rule: %s -> %s", dockerBridge, tunnelInterface)
-		cmd2 := exec.Command("iptables", "-I", "FORWARD", "2", "-i", dockerBridge, "-o", tunnelInterface,
-			"-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT")
-		if err := cmd2.Run(); err != nil {
-			log.Printf("[ERROR] Failed to add conntrack rule %s -> %s: %v", dockerBridge, tunnelInterface, err)
-			return fmt.Errorf("failed to add conntrack rule: %v", err)
-		} else {
-			rule2 := fmt.Sprintf("iptables -I FORWARD 2 -i %s -o %s -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT", dockerBridge, tunnelInterface)
-			result.AppliedRules = append(result.AppliedRules, rule2)
-			result.FixedIssues = append(result.FixedIssues, fmt.Sprintf("添加回包规则: %s -> %s", dockerBridge, tunnelInterface))
-			log.Printf("[SUCCESS] ✓ Added conntrack rule: %s -> %s", dockerBridge, tunnelInterface)
-		}
-	} else {
-		log.Printf("[INFO] Conntrack rule %s -> %s already exists, skipping", dockerBridge, tunnelInterface)
-	}
-
-	log.Printf("[DEBUG] FORWARD rules optimization completed for %s <-> %s", tunnelInterface, dockerBridge)
-	return nil
-}
-
 // ensureNATRulesOptimized 优化的NAT规则确保方法
 func (s *NetworkService) ensureNATRulesOptimized(tunnelInterface, dockerBridge string, tunnelIPs, bridgeIPs []string, result *models.ConnectivityFixResult) error {
 	if len(bridgeIPs) == 0 {
@@ -2441,43 +2214,6 @@ func (s *NetworkService) ensureNATRulesOptimized(tunnelInterface, dockerBridge s
 	return nil
 }
 
-// cleanupBlockingRulesOptimized 优化的阻塞规则清理方法
-func (s *NetworkService) cleanupBlockingRulesOptimized(tunnelInterface, dockerBridge string, result *models.ConnectivityFixResult) error {
-	// 查找并删除可能阻塞的DROP或REJECT规则
-	cmd := exec.Command("iptables", "-L", "FORWARD", "-n", "--line-numbers")
-	output, err := cmd.Output()
-	if err != nil {
-		return err
-	}
-
-	lines := strings.Split(string(output), "\n")
-	deletedCount := 0
-
-	for _, line := range lines {
-		if strings.Contains(line, tunnelInterface) && strings.Contains(line, dockerBridge) {
-			if strings.Contains(line, "DROP") || strings.Contains(line, "REJECT") {
-				// 提取行号并删除规则
-				fields := strings.Fields(line)
-				if len(fields) > 0 {
-					lineNum := fields[0]
-					deleteCmd := exec.Command("iptables", "-D", "FORWARD", lineNum)
-					if err := deleteCmd.Run(); err == nil {
-						deletedCount++
-						result.FixedIssues = append(result.FixedIssues, fmt.Sprintf("删除阻塞规则: %s", line))
-						result.AppliedRules = append(result.AppliedRules, fmt.Sprintf("iptables -D FORWARD %s", lineNum))
-					}
-				}
-			}
-		}
-	}
-
-	if deletedCount > 0 {
-		log.Printf("[DEBUG] Cleaned up %d blocking rules", deletedCount)
-	}
-
-	return nil
-}
-
 // ensureForwardRules 确保FORWARD链有正确的规则
 func (s *NetworkService) ensureForwardRules(tunnelInterface, dockerBridge string, result *models.ConnectivityFixResult) error {
 	// 允许从隧道接口到Docker网桥的流量
@@ -2533,33 +2269,10 @@ func (s *NetworkService) ensureNATRules(tunnelInterface, dockerBridge string, tu
 	return nil
 }
 
-// ensureInterfaceState 确保接口状态正常
-func (s *NetworkService) ensureInterfaceState(tunnelInterface, dockerBridge string, result *models.ConnectivityFixResult) error {
-	// 确保接口启用
-	cmd1 := exec.Command("ip", "link", "set", tunnelInterface, "up")
-	if err := cmd1.Run(); err == nil {
-		result.FixedIssues = append(result.FixedIssues, fmt.Sprintf("启用接口: %s", tunnelInterface))
-	}
-
-	cmd2 := exec.Command("ip", "link", "set", dockerBridge, "up")
-	if err := cmd2.Run(); err == nil {
-		result.FixedIssues = append(result.FixedIssues, fmt.Sprintf("启用接口: %s", dockerBridge))
-	}
-
-	// 启用IP转发
-	cmd3 := exec.Command("sysctl", "-w", "net.ipv4.ip_forward=1")
-	if err := cmd3.Run(); err == nil {
-		result.FixedIssues = append(result.FixedIssues, "启用IP转发")
-	}
-
-	return nil
-}
-
 // cleanupBlockingRules 清理可能阻塞的规则
 func (s *NetworkService) cleanupBlockingRules(tunnelInterface, dockerBridge string, result *models.ConnectivityFixResult) error {
 	// 查找并删除可能阻塞的DROP或REJECT规则
-	cmd := exec.Command("iptables", "-L", "FORWARD", "-n", "--line-numbers")
-	output, err := cmd.Output()
+	output, err := runCommand("iptables", "-L", "FORWARD", "-n", "--line-numbers")
 	if err != nil {
 		return err
 	}
@@ -2572,8 +2285,7 @@ func (s *NetworkService) cleanupBlockingRules(tunnelInterface, dockerBridge stri
 				fields := strings.Fields(line)
 				if len(fields) > 0 {
 					lineNum := fields[0]
-					deleteCmd := exec.Command("iptables", "-D", "FORWARD", lineNum)
-					if err := deleteCmd.Run(); err == nil {
+					if _, err := runCommand("iptables", "-D", "FORWARD", lineNum); err == nil {
 						result.FixedIssues = append(result.FixedIssues, fmt.Sprintf("删除阻塞规则: %s", line))
 					}
 				}
@@ -2589,8 +2301,8 @@ func (s *NetworkService) applyIPTablesRule(table, rule string) error {
 	args := []string{"-t", table}
 	args = append(args, strings.Fields(rule)...)
 
-	cmd := exec.Command("iptables", args...)
-	return cmd.Run()
+	_, err := runCommand("iptables", args...)
+	return err
 }
 
 // getNetworkFromIP 从IP地址获取网络地址
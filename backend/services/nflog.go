@@ -0,0 +1,71 @@
+package services
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+)
+
+// nfnetlink_log 消息和属性类型（linux/netfilter/nfnetlink_log.h）
+const (
+	nfnlSubsysULOG      = 4
+	nfulnlMsgPacket     = 0
+	nfulnlMsgConfig     = 1
+	nfulaCfgCmd         = 1
+	nfulaCfgMode        = 2
+	nfulnlCfgCmdBind    = 1
+	nfulnlCopyPacket    = 2
+	nfulaTimestamp      = 3
+	nfulaIfindexIndev   = 4
+	nfulaIfindexOutdev  = 5
+	nfulaHwaddr         = 8
+	nfulaPayload        = 9
+	nfulaPrefix         = 10
+	nfgenMsgSize        = 4
+	nflogCopyRange      = 256 // 只需要IP和传输层头
+	nflogReceiveTimeout = time.Second
+)
+
+// nflogPacket NFLOG 组收到的一个数据包
+type nflogPacket struct {
+	Timestamp time.Time
+	Prefix    string
+	InIndex   int
+	OutIndex  int
+	HWAddr    net.HardwareAddr
+	Payload   []byte // 从网络层开始的数据包内容
+}
+
+// parseNFLOGMessage 解析 NFULNL_MSG_PACKET 消息（msgType 为netlink消息类型，data 为消息负载，属性中的整数为网络字节序）
+func parseNFLOGMessage(msgType uint16, data []byte) (nflogPacket, bool) {
+	if msgType != nfnlSubsysULOG<<8|nfulnlMsgPacket || len(data) < nfgenMsgSize {
+		return nflogPacket{}, false
+	}
+	attrs := parseNestedAttrs(data[nfgenMsgSize:])
+
+	packet := nflogPacket{
+		Timestamp: time.Now(),
+		Prefix:    cString(attrs[nfulaPrefix]),
+		Payload:   attrs[nfulaPayload],
+	}
+	if value := attrs[nfulaTimestamp]; len(value) >= 16 {
+		packet.Timestamp = time.Unix(int64(binary.BigEndian.Uint64(value[0:8])), int64(binary.BigEndian.Uint64(value[8:16]))*1000)
+	}
+	if value := attrs[nfulaIfindexIndev]; len(value) >= 4 {
+		packet.InIndex = int(binary.BigEndian.Uint32(value))
+	}
+	if value := attrs[nfulaIfindexOutdev]; len(value) >= 4 {
+		packet.OutIndex = int(binary.BigEndian.Uint32(value))
+	}
+	// struct nfulnl_msg_packet_hw { __be16 hw_addrlen; __u16 _pad; __u8 hw_addr[8]; }
+	if value := attrs[nfulaHwaddr]; len(value) >= 4 {
+		length := int(binary.BigEndian.Uint16(value[0:2]))
+		if length > 0 && 4+length <= len(value) {
+			packet.HWAddr = net.HardwareAddr(append([]byte{}, value[4:4+length]...))
+		}
+	}
+	if len(packet.Payload) > 0 {
+		packet.Payload = append([]byte{}, packet.Payload...)
+	}
+	return packet, true
+}
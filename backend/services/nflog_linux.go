@@ -0,0 +1,109 @@
+//go:build linux
+
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"syscall"
+)
+
+// subscribeNFLOG 绑定 NFLOG 组并接收数据包，ctx 结束时解绑关闭；同一组只能被一个进程绑定（如 ulogd 已绑定时返回错误）
+func subscribeNFLOG(ctx context.Context, group uint16) (<-chan nflogPacket, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_NETFILTER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open netfilter netlink socket: %v", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to bind netfilter netlink socket: %v", err)
+	}
+
+	mode := make([]byte, 6)
+	binary.BigEndian.PutUint32(mode[0:4], nflogCopyRange)
+	mode[4] = nfulnlCopyPacket
+	for seq, attr := range [][]byte{
+		encodeNetlinkAttr(nfulaCfgCmd, []byte{nfulnlCfgCmdBind}),
+		encodeNetlinkAttr(nfulaCfgMode, mode),
+	} {
+		if err := nflogConfigRequest(fd, uint32(seq+1), group, attr); err != nil {
+			syscall.Close(fd)
+			return nil, fmt.Errorf("failed to bind NFLOG group %d: %v", group, err)
+		}
+	}
+
+	// 设置接收超时，以便定期检查 ctx（关闭套接字不会唤醒阻塞中的 recvfrom）
+	timeout := syscall.NsecToTimeval(nflogReceiveTimeout.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &timeout); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to set NFLOG socket timeout: %v", err)
+	}
+
+	packets := make(chan nflogPacket, 256)
+	go func() {
+		defer close(packets)
+		defer syscall.Close(fd)
+
+		buf := make([]byte, 1<<16)
+		for ctx.Err() == nil {
+			n, _, err := syscall.Recvfrom(fd, buf, 0)
+			if err != nil {
+				// 超时或接收缓冲区溢出（ENOBUFS，部分数据包丢失）后继续接收
+				if err == syscall.EAGAIN || err == syscall.EINTR || err == syscall.ENOBUFS {
+					continue
+				}
+				return
+			}
+			messages, err := syscall.ParseNetlinkMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+			for _, message := range messages {
+				packet, ok := parseNFLOGMessage(message.Header.Type, message.Data)
+				if !ok {
+					continue
+				}
+				select {
+				case packets <- packet:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return packets, nil
+}
+
+// nflogConfigRequest 发送一条 NFULNL_MSG_CONFIG 请求并等待确认
+func nflogConfigRequest(fd int, seq uint32, group uint16, attrs []byte) error {
+	request := make([]byte, syscall.NLMSG_HDRLEN+nfgenMsgSize, syscall.NLMSG_HDRLEN+nfgenMsgSize+len(attrs))
+	request = append(request, attrs...)
+	nativeEndian.PutUint32(request[0:4], uint32(len(request)))
+	nativeEndian.PutUint16(request[4:6], nfnlSubsysULOG<<8|nfulnlMsgConfig)
+	nativeEndian.PutUint16(request[6:8], syscall.NLM_F_REQUEST|syscall.NLM_F_ACK)
+	nativeEndian.PutUint32(request[8:12], seq)
+	request[syscall.NLMSG_HDRLEN] = syscall.AF_UNSPEC
+	binary.BigEndian.PutUint16(request[syscall.NLMSG_HDRLEN+2:], group)
+	if err := syscall.Sendto(fd, request, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4096)
+	n, _, err := syscall.Recvfrom(fd, buf, 0)
+	if err != nil {
+		return err
+	}
+	messages, err := syscall.ParseNetlinkMessage(buf[:n])
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		if message.Header.Type == syscall.NLMSG_ERROR && len(message.Data) >= 4 {
+			if errno := int32(nativeEndian.Uint32(message.Data[0:4])); errno != 0 {
+				return syscall.Errno(-errno)
+			}
+		}
+	}
+	return nil
+}
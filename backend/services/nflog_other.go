@@ -0,0 +1,10 @@
+//go:build !linux
+
+package services
+
+import "context"
+
+// subscribeNFLOG 非Linux平台不支持 NFLOG
+func subscribeNFLOG(ctx context.Context, group uint16) (<-chan nflogPacket, error) {
+	return nil, errUnsupportedPlatform
+}
@@ -0,0 +1,679 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"iptables-management-backend/config"
+	"iptables-management-backend/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	packetLogFlushInterval   = time.Second
+	packetLogFlushSize       = 200
+	packetLogMaxBuffered     = 5000 // 数据库写入跟不上时最多缓存的记录数，超出的记录丢弃
+	packetLogRuleRefresh     = time.Minute
+	packetLogRuleMissRefresh = 10 * time.Second
+	packetLogRetryInterval   = 30 * time.Second
+)
+
+// IP协议号（syscall 包并非在所有平台上都定义）
+const (
+	ipProtoICMP = 1
+	ipProtoTCP  = 6
+	ipProtoUDP  = 17
+)
+
+// PacketLogStatus 采集器状态
+type PacketLogStatus struct {
+	Source      string     `json:"source"` // kmsg, file:<path>, nflog:<group>, none
+	Running     bool       `json:"running"`
+	Retention   string     `json:"retention"`
+	Received    int64      `json:"received"` // 解析出的日志记录数
+	Stored      int64      `json:"stored"`
+	Dropped     int64      `json:"dropped"` // 缓存已满或写入失败丢弃的记录数
+	LastError   string     `json:"last_error,omitempty"`
+	LastEntryAt *time.Time `json:"last_entry_at,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+}
+
+// PacketLogQuery 日志查询条件，空字段表示不过滤
+type PacketLogQuery struct {
+	Action      string
+	Prefix      string
+	Chain       string
+	Interface   string // 匹配入接口或出接口
+	Protocol    string
+	Source      string
+	Destination string
+	Port        int // 匹配源端口或目的端口
+	Search      string
+	From        time.Time
+	To          time.Time
+	Limit       int
+	Offset      int
+}
+
+// PacketLogPrefixStat 按前缀和产生日志的规则汇总
+type PacketLogPrefixStat struct {
+	Prefix       string    `json:"prefix"`
+	Action       string    `json:"action"`
+	RuleTable    string    `json:"rule_table"`
+	RuleChain    string    `json:"rule_chain"`
+	RulePosition int       `json:"rule_position"`
+	RuleSpec     string    `json:"rule_spec"`
+	Count        int64     `json:"count"`
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+// PacketLogSourceStat 按来源地址汇总
+type PacketLogSourceStat struct {
+	SrcIP string `json:"src_ip"`
+	Count int64  `json:"count"`
+}
+
+// PacketLogSummary 一段时间内的日志汇总
+type PacketLogSummary struct {
+	From       time.Time             `json:"from"`
+	Total      int64                 `json:"total"`
+	ByAction   map[string]int64      `json:"by_action"`
+	ByPrefix   []PacketLogPrefixStat `json:"by_prefix"`
+	TopSources []PacketLogSourceStat `json:"top_sources"`
+	Status     PacketLogStatus       `json:"status"`
+}
+
+// packetLogRule 产生日志的 LOG/NFLOG 规则及推断的处理结果
+type packetLogRule struct {
+	Rule   SavedRule
+	Action string
+}
+
+// PacketLogService 采集 LOG/NFLOG 目标产生的数据包日志，关联到产生日志的规则并按保留期存储
+type PacketLogService struct {
+	mutex         sync.Mutex
+	status        PacketLogStatus
+	retention     time.Duration
+	rules         map[string][]packetLogRule // 前缀（去掉首尾空白）-> 规则
+	rulesLoadedAt time.Time
+}
+
+// NewPacketLogService 创建数据包日志服务实例
+func NewPacketLogService() *PacketLogService {
+	return &PacketLogService{}
+}
+
+// Start 在后台从指定来源采集日志：kmsg（/dev/kmsg）、file:<path>（如 /var/log/kern.log）、nflog:<group>，none 表示不采集
+func (s *PacketLogService) Start(source string, retention time.Duration) {
+	s.mutex.Lock()
+	s.status.Source = source
+	s.status.Retention = retention.String()
+	s.retention = retention
+	s.mutex.Unlock()
+
+	if source == "" || source == "none" {
+		log.Printf("[INFO] Packet log collector disabled")
+		return
+	}
+
+	// 每小时清理超过保留期的日志
+	go func() {
+		if err := s.Prune(); err != nil {
+			log.Printf("[ERROR] Packet log pruning failed: %v", err)
+		}
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := s.Prune(); err != nil {
+				log.Printf("[ERROR] Packet log pruning failed: %v", err)
+			}
+		}
+	}()
+
+	go func() {
+		log.Printf("[INFO] Packet log collector started, source %s, retention %v", source, retention)
+		for {
+			err := s.collect(source)
+			s.mutex.Lock()
+			s.status.Running = false
+			s.status.LastError = err.Error()
+			s.mutex.Unlock()
+			log.Printf("[ERROR] Packet log collector (%s) stopped: %v, retrying in %v", source, err, packetLogRetryInterval)
+			time.Sleep(packetLogRetryInterval)
+		}
+	}()
+}
+
+// collect 打开来源并持续写入日志，来源关闭或出错时返回
+func (s *PacketLogService) collect(source string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	entries, err := openPacketLogSource(ctx, source)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	s.mutex.Lock()
+	s.status.Running = true
+	s.status.StartedAt = &now
+	s.status.LastError = ""
+	s.mutex.Unlock()
+
+	ticker := time.NewTicker(packetLogFlushInterval)
+	defer ticker.Stop()
+
+	var buffer []models.PacketLog
+	for {
+		select {
+		case entry, ok := <-entries:
+			if !ok {
+				s.flush(buffer)
+				return fmt.Errorf("log source closed")
+			}
+			s.mutex.Lock()
+			s.status.Received++
+			s.status.LastEntryAt = &entry.Timestamp
+			if len(buffer) >= packetLogMaxBuffered {
+				s.status.Dropped++
+				s.mutex.Unlock()
+				continue
+			}
+			s.mutex.Unlock()
+			buffer = append(buffer, entry)
+			if len(buffer) >= packetLogFlushSize {
+				buffer = s.flush(buffer)
+			}
+		case <-ticker.C:
+			buffer = s.flush(buffer)
+		}
+	}
+}
+
+// flush 关联规则后批量写入，写入失败时保留缓存等待下次重试（超过上限的部分丢弃）
+func (s *PacketLogService) flush(buffer []models.PacketLog) []models.PacketLog {
+	if len(buffer) == 0 {
+		return buffer
+	}
+	for i := range buffer {
+		s.attachRule(&buffer[i])
+	}
+	if err := config.DB.CreateInBatches(buffer, 100).Error; err != nil {
+		log.Printf("[ERROR] Failed to store %d packet logs: %v", len(buffer), err)
+		s.mutex.Lock()
+		s.status.LastError = err.Error()
+		s.mutex.Unlock()
+		return buffer
+	}
+	s.mutex.Lock()
+	s.status.Stored += int64(len(buffer))
+	s.mutex.Unlock()
+	return buffer[:0]
+}
+
+// attachRule 按前缀找到产生日志的规则；规则集定期刷新，遇到未知前缀时提前刷新
+func (s *PacketLogService) attachRule(entry *models.PacketLog) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	age := time.Since(s.rulesLoadedAt)
+	_, known := s.rules[entry.Prefix]
+	if s.rules == nil || age >= packetLogRuleRefresh || (!known && age >= packetLogRuleMissRefresh) {
+		s.rulesLoadedAt = time.Now()
+		if ruleset, err := LoadSavedRuleset(); err == nil {
+			s.rules = buildPacketLogRules(ruleset)
+		} else {
+			log.Printf("[WARN] Failed to load ruleset for packet log mapping: %v", err)
+		}
+	}
+
+	entry.Action = "log"
+	if match := selectPacketLogRule(s.rules[entry.Prefix], entry.InInterface, entry.OutInterface); match != nil {
+		entry.Action = match.Action
+		entry.RuleTable = match.Rule.Table
+		entry.RuleChain = match.Rule.Chain
+		entry.RulePosition = match.Rule.Position
+		entry.RuleSpec = match.Rule.Spec
+		entry.RuleKey = match.Rule.Key
+	}
+}
+
+// Prune 删除超过保留期的日志
+func (s *PacketLogService) Prune() error {
+	s.mutex.Lock()
+	retention := s.retention
+	s.mutex.Unlock()
+	if retention <= 0 {
+		return nil
+	}
+	result := config.DB.Where("timestamp < ?", time.Now().Add(-retention)).Delete(&models.PacketLog{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to prune packet logs: %v", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("[DEBUG] Pruned %d packet logs older than %v", result.RowsAffected, retention)
+	}
+	return nil
+}
+
+// GetStatus 获取采集器状态
+func (s *PacketLogService) GetStatus() PacketLogStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.status
+}
+
+// Query 按条件查询日志（最新在前），返回本页记录和总数
+func (s *PacketLogService) Query(query PacketLogQuery) ([]models.PacketLog, int64, error) {
+	db := config.DB.Model(&models.PacketLog{})
+	if query.Action != "" {
+		db = db.Where("action = ?", query.Action)
+	}
+	if query.Prefix != "" {
+		db = db.Where("prefix = ?", query.Prefix)
+	}
+	if query.Chain != "" {
+		db = db.Where("rule_chain = ?", query.Chain)
+	}
+	if query.Interface != "" {
+		db = db.Where("in_interface = ? OR out_interface = ?", query.Interface, query.Interface)
+	}
+	if query.Protocol != "" {
+		db = db.Where("protocol = ?", strings.ToLower(query.Protocol))
+	}
+	if query.Source != "" {
+		db = db.Where("src_ip = ?", query.Source)
+	}
+	if query.Destination != "" {
+		db = db.Where("dst_ip = ?", query.Destination)
+	}
+	if query.Port > 0 {
+		db = db.Where("src_port = ? OR dst_port = ?", query.Port, query.Port)
+	}
+	if query.Search != "" {
+		db = db.Where("raw LIKE ?", "%"+query.Search+"%")
+	}
+	if !query.From.IsZero() {
+		db = db.Where("timestamp >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		db = db.Where("timestamp <= ?", query.To)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count packet logs: %v", err)
+	}
+	limit := query.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	var logs []models.PacketLog
+	if err := db.Order("timestamp DESC, id DESC").Limit(limit).Offset(query.Offset).Find(&logs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to query packet logs: %v", err)
+	}
+	return logs, total, nil
+}
+
+// GetSummary 汇总指定时间之后的日志：按处理结果、按前缀和规则、来源地址排行
+func (s *PacketLogService) GetSummary(from time.Time) (*PacketLogSummary, error) {
+	summary := &PacketLogSummary{From: from, ByAction: make(map[string]int64), Status: s.GetStatus()}
+	base := func() *gorm.DB {
+		return config.DB.Model(&models.PacketLog{}).Where("timestamp >= ?", from)
+	}
+
+	var actions []struct {
+		Action string
+		Count  int64
+	}
+	if err := base().Select("action, COUNT(*) AS count").Group("action").Scan(&actions).Error; err != nil {
+		return nil, fmt.Errorf("failed to summarize packet logs: %v", err)
+	}
+	for _, action := range actions {
+		summary.ByAction[action.Action] = action.Count
+		summary.Total += action.Count
+	}
+
+	var prefixes []struct {
+		PacketLogPrefixStat
+		LastSeenText string
+	}
+	err := base().
+		Select("prefix, action, rule_table, rule_chain, rule_position, rule_spec, COUNT(*) AS count, MAX(timestamp) AS last_seen_text").
+		Group("prefix, action, rule_table, rule_chain, rule_position, rule_spec").
+		Order("count DESC").Limit(50).Scan(&prefixes).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize packet logs: %v", err)
+	}
+	summary.ByPrefix = make([]PacketLogPrefixStat, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		prefix.PacketLogPrefixStat.LastSeen = parseSQLiteTime(prefix.LastSeenText)
+		summary.ByPrefix = append(summary.ByPrefix, prefix.PacketLogPrefixStat)
+	}
+
+	summary.TopSources = []PacketLogSourceStat{}
+	err = base().Select("src_ip, COUNT(*) AS count").Group("src_ip").Order("count DESC").Limit(20).Scan(&summary.TopSources).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize packet logs: %v", err)
+	}
+	return summary, nil
+}
+
+// parseSQLiteTime 解析 SQLite 聚合函数返回的时间文本
+func parseSQLiteTime(value string) time.Time {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999", time.RFC3339Nano} {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return parsed
+		}
+	}
+	return time.Time{}
+}
+
+// openPacketLogSource 打开日志来源并把记录转换为日志条目
+func openPacketLogSource(ctx context.Context, source string) (<-chan models.PacketLog, error) {
+	entries := make(chan models.PacketLog, 256)
+
+	if strings.HasPrefix(source, "nflog:") {
+		group, err := strconv.ParseUint(strings.TrimPrefix(source, "nflog:"), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid NFLOG group in source %s", source)
+		}
+		packets, err := subscribeNFLOG(ctx, uint16(group))
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			defer close(entries)
+			for packet := range packets {
+				entry := packetLogFromNFLOG(packet)
+				entry.Source = source
+				entries <- entry
+			}
+		}()
+		return entries, nil
+	}
+
+	var lines <-chan string
+	var err error
+	switch {
+	case source == "kmsg":
+		lines, err = followKernelLog(ctx)
+	case strings.HasPrefix(source, "file:"):
+		lines, err = followLogFile(ctx, strings.TrimPrefix(source, "file:"), time.Second)
+	default:
+		return nil, fmt.Errorf("invalid packet log source %s: expected kmsg, file:<path> or nflog:<group>", source)
+	}
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer close(entries)
+		for line := range lines {
+			if entry, ok := parsePacketLogLine(line); ok {
+				entry.Timestamp = time.Now()
+				entry.Source = source
+				entries <- entry
+			}
+		}
+	}()
+	return entries, nil
+}
+
+// parsePacketLogLine 解析内核日志中 LOG 目标输出的一行，支持 /dev/kmsg 记录和 syslog 文件行，例如
+// "4,1001,5000,-;TUNNEL-TO-DOCKER: IN=wg0 OUT=docker0 MAC= SRC=10.8.0.2 DST=172.17.0.2 LEN=60 ... PROTO=TCP SPT=40000 DPT=80 ... SYN URGP=0"
+// "Oct 19 12:00:00 host kernel: [ 1234.567890] DROP-INPUT: IN=eth0 OUT= ..."
+func parsePacketLogLine(line string) (models.PacketLog, bool) {
+	line = strings.TrimSpace(strings.SplitN(line, "\n", 2)[0])
+	if header, rest, ok := strings.Cut(line, ";"); ok && !strings.Contains(header, " ") && strings.Count(header, ",") >= 2 {
+		line = rest
+	}
+	if index := strings.Index(line, "kernel: "); index >= 0 {
+		line = line[index+len("kernel: "):]
+	}
+	if strings.HasPrefix(line, "[") {
+		if end := strings.Index(line, "] "); end > 0 {
+			line = line[end+2:]
+		}
+	}
+
+	index := strings.Index(line, "IN=")
+	if index < 0 || (index > 0 && line[index-1] != ' ') || !strings.Contains(line[index:], " OUT=") {
+		return models.PacketLog{}, false
+	}
+	entry := models.PacketLog{Prefix: strings.TrimSpace(line[:index]), Raw: line}
+	// TRACE 目标的记录由跟踪会话处理
+	if strings.HasPrefix(entry.Prefix, "TRACE:") {
+		return models.PacketLog{}, false
+	}
+
+	var flags []string
+	for _, field := range strings.Fields(line[index:]) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			switch field {
+			case "CWR", "ECE", "URG", "ACK", "PSH", "RST", "SYN", "FIN":
+				flags = append(flags, field)
+			}
+			continue
+		}
+		switch key {
+		case "IN":
+			entry.InInterface = value
+		case "OUT":
+			entry.OutInterface = value
+		case "MAC":
+			entry.MAC = value
+		case "SRC":
+			entry.SrcIP = value
+		case "DST":
+			entry.DstIP = value
+		case "LEN":
+			if entry.Length == 0 {
+				entry.Length, _ = strconv.Atoi(value)
+			}
+		case "TTL", "HOPLIMIT":
+			entry.TTL, _ = strconv.Atoi(value)
+		case "PROTO":
+			entry.Protocol = strings.ToLower(value)
+		case "SPT":
+			entry.SrcPort, _ = strconv.Atoi(value)
+		case "DPT":
+			entry.DstPort, _ = strconv.Atoi(value)
+		case "TYPE", "CODE":
+			if entry.Protocol == "icmp" || entry.Protocol == "icmpv6" {
+				flags = append(flags, strings.ToLower(key)+"="+value)
+			}
+		}
+	}
+	entry.Flags = strings.Join(flags, " ")
+	return entry, true
+}
+
+// packetLogFromNFLOG 把 NFLOG 数据包转换为日志条目（解析IPv4头和TCP/UDP/ICMP头）
+func packetLogFromNFLOG(packet nflogPacket) models.PacketLog {
+	entry := models.PacketLog{Timestamp: packet.Timestamp, Prefix: strings.TrimSpace(packet.Prefix)}
+	if packet.InIndex > 0 {
+		if iface, err := net.InterfaceByIndex(packet.InIndex); err == nil {
+			entry.InInterface = iface.Name
+		}
+	}
+	if packet.OutIndex > 0 {
+		if iface, err := net.InterfaceByIndex(packet.OutIndex); err == nil {
+			entry.OutInterface = iface.Name
+		}
+	}
+	if len(packet.HWAddr) > 0 {
+		entry.MAC = packet.HWAddr.String()
+	}
+	applyIPv4Header(&entry, packet.Payload)
+
+	entry.Raw = fmt.Sprintf("%sIN=%s OUT=%s SRC=%s DST=%s LEN=%d TTL=%d PROTO=%s",
+		packet.Prefix, entry.InInterface, entry.OutInterface, entry.SrcIP, entry.DstIP, entry.Length, entry.TTL, strings.ToUpper(entry.Protocol))
+	if entry.SrcPort > 0 || entry.DstPort > 0 {
+		entry.Raw += fmt.Sprintf(" SPT=%d DPT=%d", entry.SrcPort, entry.DstPort)
+	}
+	if entry.Flags != "" {
+		entry.Raw += " " + entry.Flags
+	}
+	return entry
+}
+
+// applyIPv4Header 从IPv4数据包中提取地址、协议、端口和TCP标志
+func applyIPv4Header(entry *models.PacketLog, payload []byte) {
+	if len(payload) < 20 || payload[0]>>4 != 4 {
+		return
+	}
+	headerLength := int(payload[0]&0x0f) * 4
+	entry.Length = int(binary.BigEndian.Uint16(payload[2:4]))
+	entry.TTL = int(payload[8])
+	entry.SrcIP = net.IP(payload[12:16]).String()
+	entry.DstIP = net.IP(payload[16:20]).String()
+
+	protocol := payload[9]
+	switch protocol {
+	case ipProtoTCP:
+		entry.Protocol = "tcp"
+	case ipProtoUDP:
+		entry.Protocol = "udp"
+	case ipProtoICMP:
+		entry.Protocol = "icmp"
+	default:
+		entry.Protocol = strconv.Itoa(int(protocol))
+	}
+
+	// 分片的后续片段没有传输层头
+	if binary.BigEndian.Uint16(payload[6:8])&0x1fff != 0 || headerLength < 20 || len(payload) < headerLength+4 {
+		return
+	}
+	transport := payload[headerLength:]
+	switch entry.Protocol {
+	case "tcp", "udp":
+		entry.SrcPort = int(binary.BigEndian.Uint16(transport[0:2]))
+		entry.DstPort = int(binary.BigEndian.Uint16(transport[2:4]))
+		if entry.Protocol == "tcp" && len(transport) >= 14 {
+			var flags []string
+			for i, name := range []string{"FIN", "SYN", "RST", "PSH", "ACK", "URG", "ECE", "CWR"} {
+				if transport[13]&(1<<i) != 0 {
+					flags = append(flags, name)
+				}
+			}
+			entry.Flags = strings.Join(flags, " ")
+		}
+	case "icmp":
+		entry.Flags = fmt.Sprintf("type=%d code=%d", transport[0], transport[1])
+	}
+}
+
+// buildPacketLogRules 按前缀索引 LOG/NFLOG 规则
+func buildPacketLogRules(ruleset *SavedRuleset) map[string][]packetLogRule {
+	rules := make(map[string][]packetLogRule)
+	for _, table := range ruleset.Tables {
+		chains := make(map[string][]SavedRule)
+		for _, rule := range table.Rules {
+			chains[rule.Chain] = append(chains[rule.Chain], rule)
+		}
+		for chainName, chainRules := range chains {
+			policy := ""
+			if chain := table.Chain(chainName); chain != nil && chain.BuiltIn {
+				policy = chain.Policy
+			}
+			for i, rule := range chainRules {
+				var prefix string
+				switch rule.Match.Target {
+				case "LOG":
+					prefix = rule.Match.TargetOption("--log-prefix")
+				case "NFLOG":
+					prefix = rule.Match.TargetOption("--nflog-prefix")
+				default:
+					continue
+				}
+				key := strings.TrimSpace(prefix)
+				rules[key] = append(rules[key], packetLogRule{Rule: rule, Action: inferLogAction(chainRules, i, policy)})
+			}
+		}
+	}
+	// 同一前缀的多条规则按表、链和位置排序，使选择结果稳定
+	for _, candidates := range rules {
+		sort.Slice(candidates, func(i, j int) bool {
+			a, b := candidates[i].Rule, candidates[j].Rule
+			if a.Table != b.Table {
+				return a.Table < b.Table
+			}
+			if a.Chain != b.Chain {
+				return a.Chain < b.Chain
+			}
+			return a.Position < b.Position
+		})
+	}
+	return rules
+}
+
+// inferLogAction 推断日志规则对应的处理结果：紧随其后、匹配条件相同的规则的目标（跳过其他日志规则），
+// 日志规则位于内置链末尾时为链的默认策略，无法判断时为 log
+func inferLogAction(chainRules []SavedRule, index int, policy string) string {
+	conditions := ruleConditions(chainRules[index])
+	for _, next := range chainRules[index+1:] {
+		if next.Match.Target == "LOG" || next.Match.Target == "NFLOG" {
+			continue
+		}
+		if ruleConditions(next) != conditions {
+			return "log"
+		}
+		switch next.Match.Target {
+		case "ACCEPT", "DROP", "REJECT":
+			return strings.ToLower(next.Match.Target)
+		}
+		return "log"
+	}
+	switch policy {
+	case "ACCEPT", "DROP":
+		return strings.ToLower(policy)
+	}
+	return "log"
+}
+
+// ruleConditions 规则中目标之前的匹配条件
+func ruleConditions(rule SavedRule) string {
+	args := rule.Match.Args
+	for i, arg := range args {
+		if arg == "-j" || arg == "--jump" || arg == "-g" || arg == "--goto" {
+			args = args[:i]
+			break
+		}
+	}
+	return JoinRuleArgs(args)
+}
+
+// selectPacketLogRule 选择入/出接口与日志相符的第一条规则
+func selectPacketLogRule(candidates []packetLogRule, in, out string) *packetLogRule {
+	for i := range candidates {
+		match := candidates[i].Rule.Match
+		if logInterfaceMatches(match.InInterface, in) && logInterfaceMatches(match.OutInterface, out) {
+			return &candidates[i]
+		}
+	}
+	return nil
+}
+
+// logInterfaceMatches 规则的接口条件（可能取反或带 + 通配）是否与日志中的接口相符
+func logInterfaceMatches(pattern, name string) bool {
+	if pattern == "" {
+		return true
+	}
+	negate := strings.HasPrefix(pattern, "!")
+	result, _ := matchInterface(strings.TrimPrefix(pattern, "!"), name, "")
+	if name == "" {
+		return negate
+	}
+	return (result == matchYes) != negate
+}
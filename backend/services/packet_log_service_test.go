@@ -0,0 +1,175 @@
+package services
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParsePacketLogLine(t *testing.T) {
+	tests := []struct {
+		name         string
+		line         string
+		wantOK       bool
+		wantPrefix   string
+		wantIn       string
+		wantOut      string
+		wantProtocol string
+		wantSrc      string
+		wantDstPort  int
+		wantLength   int
+		wantFlags    string
+	}{
+		{
+			"kmsg record",
+			"4,1001,5000,-;TUNNEL-TO-DOCKER: IN=wg0 OUT=docker0 MAC= SRC=10.8.0.2 DST=172.17.0.2 LEN=60 TOS=0x00 PREC=0x00 TTL=63 ID=4321 DF PROTO=TCP SPT=40000 DPT=80 WINDOW=64240 RES=0x00 SYN URGP=0 \n SUBSYSTEM=net",
+			true, "TUNNEL-TO-DOCKER:", "wg0", "docker0", "tcp", "10.8.0.2", 80, 60, "SYN",
+		},
+		{
+			"syslog line",
+			"Oct 19 12:00:00 host kernel: [ 1234.567890] DROP-INPUT: IN=eth0 OUT= MAC=00:11:22:33:44:55:66:77:88:99:aa:bb:08:00 SRC=203.0.113.5 DST=192.168.1.10 LEN=40 TOS=0x00 PREC=0x00 TTL=244 ID=54321 PROTO=UDP SPT=5353 DPT=53 LEN=20",
+			true, "DROP-INPUT:", "eth0", "", "udp", "203.0.113.5", 53, 40, "",
+		},
+		{
+			"icmp without prefix",
+			"6,1002,5001,-;IN=eth0 OUT= SRC=203.0.113.5 DST=192.168.1.10 LEN=84 TTL=64 ID=1 PROTO=ICMP TYPE=8 CODE=0 ID=7 SEQ=1",
+			true, "", "eth0", "", "icmp", "203.0.113.5", 0, 84, "type=8 code=0",
+		},
+		{"trace record", "4,1003,5002,-;TRACE: filter:INPUT:rule:2 IN=eth0 OUT= SRC=1.1.1.1 DST=2.2.2.2", false, "", "", "", "", "", 0, 0, ""},
+		{"other kernel message", "6,1004,5003,-;eth0: link becomes ready", false, "", "", "", "", "", 0, 0, ""},
+		{"LOGIN= is not IN=", "6,1005,5004,-;audit: LOGIN=root OUT=x", false, "", "", "", "", "", 0, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, ok := parsePacketLogLine(tt.line)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v (%+v)", ok, tt.wantOK, entry)
+			}
+			if !ok {
+				return
+			}
+			if entry.Prefix != tt.wantPrefix || entry.InInterface != tt.wantIn || entry.OutInterface != tt.wantOut ||
+				entry.Protocol != tt.wantProtocol || entry.SrcIP != tt.wantSrc || entry.DstPort != tt.wantDstPort ||
+				entry.Length != tt.wantLength || entry.Flags != tt.wantFlags {
+				t.Errorf("unexpected entry: %+v", entry)
+			}
+		})
+	}
+}
+
+func TestPacketLogFromNFLOG(t *testing.T) {
+	payload := make([]byte, 40)
+	payload[0] = 0x45
+	binary.BigEndian.PutUint16(payload[2:4], 40)
+	payload[8] = 64
+	payload[9] = syscall.IPPROTO_TCP
+	copy(payload[12:16], net.ParseIP("10.8.0.2").To4())
+	copy(payload[16:20], net.ParseIP("172.17.0.2").To4())
+	binary.BigEndian.PutUint16(payload[20:22], 40000)
+	binary.BigEndian.PutUint16(payload[22:24], 443)
+	payload[33] = 0x12 // SYN ACK
+
+	attrs := append(encodeNetlinkAttr(nfulaPrefix, append([]byte("NFLOG-DROP "), 0)), encodeNetlinkAttr(nfulaPayload, payload)...)
+	timestamp := make([]byte, 16)
+	binary.BigEndian.PutUint64(timestamp[0:8], 1700000000)
+	binary.BigEndian.PutUint64(timestamp[8:16], 500)
+	attrs = append(attrs, encodeNetlinkAttr(nfulaTimestamp, timestamp)...)
+	packet, ok := parseNFLOGMessage(nfnlSubsysULOG<<8|nfulnlMsgPacket, append([]byte{syscall.AF_INET, 0, 0, 5}, attrs...))
+	if !ok {
+		t.Fatal("expected NFLOG packet")
+	}
+	if packet.Prefix != "NFLOG-DROP " || !packet.Timestamp.Equal(time.Unix(1700000000, 500000)) {
+		t.Errorf("unexpected packet: %+v", packet)
+	}
+
+	entry := packetLogFromNFLOG(packet)
+	if entry.Prefix != "NFLOG-DROP" || entry.Protocol != "tcp" || entry.SrcIP != "10.8.0.2" || entry.DstIP != "172.17.0.2" ||
+		entry.SrcPort != 40000 || entry.DstPort != 443 || entry.TTL != 64 || entry.Length != 40 || entry.Flags != "SYN ACK" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+
+	if _, ok := parseNFLOGMessage(nfnlSubsysULOG<<8|nfulnlMsgConfig, make([]byte, nfgenMsgSize)); ok {
+		t.Error("expected non-packet message to be ignored")
+	}
+}
+
+func TestBuildPacketLogRules(t *testing.T) {
+	ruleset, err := ParseIPTablesSave(`*filter
+:INPUT DROP [0:0]
+:FORWARD ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:DOCKER-USER - [0:0]
+-A INPUT -i lo -j ACCEPT
+-A INPUT -p tcp -m tcp --dport 23 -j LOG --log-prefix "TELNET: "
+-A INPUT -p tcp -m tcp --dport 23 -j REJECT --reject-with tcp-reset
+-A INPUT -j LOG --log-prefix "INPUT-DROP: "
+-A DOCKER-USER -i wg0 -o docker0 -j LOG --log-prefix "TUNNEL-TO-DOCKER: "
+-A DOCKER-USER -i wg0 -o docker0 -j ACCEPT
+-A DOCKER-USER -i docker0 -o wg0 -j LOG --log-prefix "TUNNEL-TO-DOCKER: "
+-A DOCKER-USER -i docker0 -o wg0 -j NFLOG --nflog-group 5 --nflog-prefix "TUNNEL-TO-DOCKER: "
+-A DOCKER-USER -i docker0 -o wg0 -j DROP
+-A DOCKER-USER -j LOG --log-prefix "USER-END: "
+-A DOCKER-USER -j RETURN
+COMMIT
+`)
+	if err != nil {
+		t.Fatalf("ParseIPTablesSave returned error: %v", err)
+	}
+	rules := buildPacketLogRules(ruleset)
+
+	tests := []struct {
+		prefix       string
+		in, out      string
+		wantChain    string
+		wantPosition int
+		wantAction   string
+	}{
+		{"TELNET:", "eth0", "", "INPUT", 2, "reject"},
+		{"INPUT-DROP:", "eth0", "", "INPUT", 4, "drop"},
+		{"TUNNEL-TO-DOCKER:", "wg0", "docker0", "DOCKER-USER", 1, "accept"},
+		{"TUNNEL-TO-DOCKER:", "docker0", "wg0", "DOCKER-USER", 3, "drop"},
+		{"USER-END:", "eth0", "docker0", "DOCKER-USER", 6, "log"},
+	}
+	for _, tt := range tests {
+		match := selectPacketLogRule(rules[tt.prefix], tt.in, tt.out)
+		if match == nil {
+			t.Errorf("%s %s->%s: no rule", tt.prefix, tt.in, tt.out)
+			continue
+		}
+		if match.Rule.Chain != tt.wantChain || match.Rule.Position != tt.wantPosition || match.Action != tt.wantAction {
+			t.Errorf("%s %s->%s: got %s/%d %s, want %s/%d %s", tt.prefix, tt.in, tt.out,
+				match.Rule.Chain, match.Rule.Position, match.Action, tt.wantChain, tt.wantPosition, tt.wantAction)
+		}
+	}
+
+	if len(rules["TUNNEL-TO-DOCKER:"]) != 3 {
+		t.Errorf("expected LOG and NFLOG rules indexed under the same prefix, got %d", len(rules["TUNNEL-TO-DOCKER:"]))
+	}
+	if match := selectPacketLogRule(rules["TUNNEL-TO-DOCKER:"], "eth0", "docker0"); match != nil {
+		t.Errorf("expected no rule for mismatched interfaces, got %+v", match.Rule)
+	}
+}
+
+func TestLogInterfaceMatches(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"", "eth0", true},
+		{"eth0", "eth0", true},
+		{"eth+", "eth1", true},
+		{"eth0", "wg0", false},
+		{"!docker0", "eth0", true},
+		{"!docker0", "docker0", false},
+		{"eth0", "", false},
+		{"!eth0", "", true},
+	}
+	for _, tt := range tests {
+		if got := logInterfaceMatches(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("logInterfaceMatches(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
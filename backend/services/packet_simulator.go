@@ -0,0 +1,479 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// maxSimulationDepth 自定义链嵌套跳转的最大深度，防止规则成环时无限递归
+const maxSimulationDepth = 32
+
+// SimPacket 模拟的数据包
+type SimPacket struct {
+	InInterface  string `json:"in_interface,omitempty"`
+	OutInterface string `json:"out_interface,omitempty"`
+	Protocol     string `json:"protocol"`
+	Source       string `json:"source,omitempty"` // 为空表示任意来源，带 -s 条件的规则视为无法判断
+	Destination  string `json:"destination"`
+	SourcePort   int    `json:"source_port,omitempty"`
+	DestPort     int    `json:"dest_port,omitempty"`
+	CtState      string `json:"ct_state"`       // NEW, ESTABLISHED, RELATED...
+	DNAT         bool   `json:"dnat,omitempty"` // 已被DNAT/REDIRECT改写，匹配 --ctstate DNAT
+}
+
+// SimStep 模拟过程中命中（或无法判断）的规则，Position 为0表示链的默认策略
+type SimStep struct {
+	Table    string `json:"table"`
+	Chain    string `json:"chain"`
+	Position int    `json:"position"`
+	Key      string `json:"key,omitempty"`
+	Spec     string `json:"spec,omitempty"`
+	Target   string `json:"target"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// ChainVerdict 数据包遍历一条内置链的结果
+type ChainVerdict struct {
+	Verdict   string    `json:"verdict"` // ACCEPT, DROP, REJECT, DNAT, REDIRECT, SNAT, MASQUERADE
+	Rule      *SimStep  `json:"rule"`    // 决定结果的规则或链策略
+	Packet    SimPacket `json:"packet"`  // NAT改写后的数据包
+	Trace     []SimStep `json:"trace"`
+	Uncertain []SimStep `json:"uncertain,omitempty"` // 条件无法判断而按不匹配处理的规则
+}
+
+// PathResult 数据包经过一组内置链后的结果
+type PathResult struct {
+	Verdict   string    `json:"verdict"` // ACCEPT, DROP, REJECT, FORWARD（被DNAT到非本机地址）
+	Rule      *SimStep  `json:"rule"`
+	NAT       *SimStep  `json:"nat,omitempty"` // 改写目的地址的规则
+	Packet    SimPacket `json:"packet"`
+	Trace     []SimStep `json:"trace"`
+	Uncertain []SimStep `json:"uncertain,omitempty"`
+}
+
+// simHook 数据包路径上依次经过的表和内置链
+type simHook struct {
+	table string
+	chain string
+}
+
+// inputHooks 发往本机的数据包经过的链
+var inputHooks = []simHook{
+	{"raw", "PREROUTING"},
+	{"mangle", "PREROUTING"},
+	{"nat", "PREROUTING"},
+	{"mangle", "INPUT"},
+	{"filter", "INPUT"},
+}
+
+// PacketSimulator 基于 iptables-save 规则集离线模拟数据包的处理结果
+type PacketSimulator struct {
+	ruleset    *SavedRuleset
+	localAddrs map[string]bool
+}
+
+// NewPacketSimulator 创建模拟器，localAddrs 为本机地址（用于 addrtype LOCAL 和判断DNAT后是否仍发往本机）
+func NewPacketSimulator(ruleset *SavedRuleset, localAddrs []string) *PacketSimulator {
+	simulator := &PacketSimulator{
+		ruleset:    ruleset,
+		localAddrs: make(map[string]bool),
+	}
+	for _, addr := range localAddrs {
+		if ip := net.ParseIP(strings.SplitN(addr, "/", 2)[0]); ip != nil {
+			simulator.localAddrs[ip.String()] = true
+		}
+	}
+	return simulator
+}
+
+// isLocal 判断地址是否为本机地址
+func (s *PacketSimulator) isLocal(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	return ip.IsLoopback() || s.localAddrs[ip.String()]
+}
+
+// SimulateInput 模拟从 InInterface 进入、发往本机的数据包
+func (s *PacketSimulator) SimulateInput(packet SimPacket) PathResult {
+	result := PathResult{Packet: packet}
+
+	for _, hook := range inputHooks {
+		verdict := s.Traverse(hook.table, hook.chain, result.Packet)
+		result.Trace = append(result.Trace, verdict.Trace...)
+		result.Uncertain = append(result.Uncertain, verdict.Uncertain...)
+		result.Packet = verdict.Packet
+		result.Rule = verdict.Rule
+
+		switch verdict.Verdict {
+		case "DROP", "REJECT":
+			result.Verdict = verdict.Verdict
+			return result
+		case "DNAT", "REDIRECT":
+			result.NAT = verdict.Rule
+			if !s.isLocal(result.Packet.Destination) {
+				result.Verdict = "FORWARD"
+				return result
+			}
+		}
+	}
+
+	result.Verdict = "ACCEPT"
+	return result
+}
+
+// Traverse 模拟数据包遍历指定表的内置链
+func (s *PacketSimulator) Traverse(table, chain string, packet SimPacket) ChainVerdict {
+	verdict := ChainVerdict{Packet: packet}
+	if packet.CtState == "" {
+		verdict.Packet.CtState = "NEW"
+	}
+
+	savedTable := s.ruleset.Table(table)
+	if savedTable == nil {
+		// 表未加载（如没有nat规则）时不影响数据包
+		verdict.Verdict = "ACCEPT"
+		verdict.Rule = &SimStep{Table: table, Chain: chain, Target: "ACCEPT", Reason: "表未加载"}
+		return verdict
+	}
+
+	if !s.walkChain(savedTable, chain, &verdict, 0) {
+		policy := "ACCEPT"
+		if savedChain := savedTable.Chain(chain); savedChain != nil && savedChain.BuiltIn {
+			policy = savedChain.Policy
+		}
+		verdict.Verdict = policy
+		verdict.Rule = &SimStep{Table: table, Chain: chain, Target: policy, Reason: "链默认策略"}
+		verdict.Trace = append(verdict.Trace, *verdict.Rule)
+	}
+	return verdict
+}
+
+// walkChain 依次匹配链中的规则，得到最终结果时返回 true；链走完或遇到 RETURN 时返回 false
+func (s *PacketSimulator) walkChain(table *SavedTable, chain string, verdict *ChainVerdict, depth int) bool {
+	for _, rule := range table.ChainRules(chain) {
+		step := SimStep{
+			Table:    rule.Table,
+			Chain:    rule.Chain,
+			Position: rule.Position,
+			Key:      rule.Key,
+			Spec:     rule.Spec,
+			Target:   rule.Match.Target,
+		}
+
+		result, reason := s.matchRule(rule.Match.Args, verdict.Packet)
+		if result == matchNo {
+			continue
+		}
+		if result == matchUnknown {
+			step.Reason = reason
+			verdict.Uncertain = append(verdict.Uncertain, step)
+			continue
+		}
+		verdict.Trace = append(verdict.Trace, step)
+
+		target := rule.Match.Target
+		if userChain := table.Chain(target); userChain != nil && !userChain.BuiltIn {
+			if depth >= maxSimulationDepth {
+				step.Reason = "自定义链嵌套过深"
+				verdict.Uncertain = append(verdict.Uncertain, step)
+				continue
+			}
+			if s.walkChain(table, target, verdict, depth+1) {
+				return true
+			}
+			if rule.Match.Goto {
+				// -g 跳转的链走完后直接返回到上一级调用者
+				return false
+			}
+			continue
+		}
+
+		switch target {
+		case "RETURN":
+			return false
+		case "ACCEPT", "DROP", "REJECT":
+			verdict.Verdict = target
+		case "DNAT":
+			if err := applyDNAT(&verdict.Packet, rule.Match.TargetOption("--to-destination")); err != nil {
+				step.Reason = err.Error()
+			}
+			verdict.Packet.DNAT = true
+			verdict.Verdict = target
+		case "REDIRECT":
+			if port := firstPort(strings.Replace(rule.Match.TargetOption("--to-ports"), "-", ":", 1)); port != 0 {
+				verdict.Packet.DestPort = port
+			}
+			verdict.Packet.DNAT = true
+			verdict.Verdict = target
+		case "SNAT":
+			if host, port, err := splitDNATDestination(rule.Match.TargetOption("--to-source")); err == nil {
+				verdict.Packet.Source = host
+				if port != "" {
+					verdict.Packet.SourcePort = firstPort(port)
+				}
+			}
+			verdict.Verdict = target
+		case "MASQUERADE":
+			verdict.Verdict = target
+		default:
+			// LOG、MARK、NFLOG 等非终结目标，继续匹配下一条规则
+			continue
+		}
+
+		verdict.Rule = &step
+		return true
+	}
+	return false
+}
+
+// applyDNAT 按 --to-destination 改写数据包的目的地址和端口
+func applyDNAT(packet *SimPacket, destination string) error {
+	host, port, err := splitDNATDestination(destination)
+	if err != nil {
+		return fmt.Errorf("failed to parse DNAT destination: %v", err)
+	}
+	packet.Destination = host
+	if port != "" {
+		packet.DestPort = firstPort(port)
+	}
+	return nil
+}
+
+// matchResult 规则条件的匹配结果
+type matchResult int
+
+const (
+	matchNo matchResult = iota
+	matchYes
+	matchUnknown
+)
+
+// matchRule 判断数据包是否满足规则的全部匹配条件；任一条件不满足即为不匹配，无法判断的条件使结果变为未知
+func (s *PacketSimulator) matchRule(args []string, packet SimPacket) (matchResult, string) {
+	result := matchYes
+	var reason string
+	negate := false
+
+	// 选项的值：下一个参数
+	value := func(i int) string {
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+		return ""
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "!" {
+			negate = true
+			continue
+		}
+		if arg == "-j" || arg == "--jump" || arg == "-g" || arg == "--goto" {
+			break
+		}
+
+		var current matchResult
+		var currentReason string
+		consumed := 1
+
+		switch arg {
+		case "-i", "--in-interface":
+			current, currentReason = matchInterface(value(i), packet.InInterface, "入接口")
+		case "-o", "--out-interface":
+			current, currentReason = matchInterface(value(i), packet.OutInterface, "出接口")
+		case "-p", "--protocol":
+			current, currentReason = matchProtocol(value(i), packet.Protocol)
+		case "-s", "--source":
+			current, currentReason = matchAddress(value(i), packet.Source, "源地址")
+		case "-d", "--destination":
+			current, currentReason = matchAddress(value(i), packet.Destination, "目的地址")
+		case "-m", "--match", "--comment":
+			current = matchYes
+		case "--sport", "--source-port", "--sports", "--source-ports":
+			current, currentReason = matchPorts(value(i), packet.SourcePort, "源端口")
+		case "--dport", "--destination-port", "--dports", "--destination-ports":
+			current, currentReason = matchPorts(value(i), packet.DestPort, "目的端口")
+		case "--ports":
+			current, currentReason = matchPorts(value(i), packet.SourcePort, "源端口")
+			if current != matchYes {
+				current, currentReason = matchPorts(value(i), packet.DestPort, "目的端口")
+			}
+		case "--ctstate", "--state":
+			current = matchNo
+			for _, state := range strings.Split(value(i), ",") {
+				if strings.EqualFold(state, packet.CtState) || (packet.DNAT && strings.EqualFold(state, "DNAT")) {
+					current = matchYes
+				}
+			}
+		case "--dst-type", "--src-type":
+			address := packet.Destination
+			if arg == "--src-type" {
+				address = packet.Source
+			}
+			current, currentReason = s.matchAddrType(value(i), address)
+		case "--syn":
+			consumed = 0
+			current = matchNo
+			if strings.EqualFold(packet.CtState, "NEW") {
+				current = matchYes
+			}
+		case "--tcp-flags":
+			consumed = 2
+			current = matchTCPFlags(value(i), value(i+1), packet.CtState)
+		default:
+			// 不支持的匹配条件：跳过其参数
+			consumed = 0
+			for i+consumed+1 < len(args) && !strings.HasPrefix(args[i+consumed+1], "-") && args[i+consumed+1] != "!" {
+				consumed++
+			}
+			current, currentReason = matchUnknown, "不支持的匹配条件 "+arg
+		}
+
+		if negate && current != matchUnknown {
+			if current == matchYes {
+				current = matchNo
+			} else {
+				current = matchYes
+			}
+		}
+		negate = false
+		i += consumed
+
+		switch current {
+		case matchNo:
+			return matchNo, ""
+		case matchUnknown:
+			if result != matchUnknown {
+				reason = currentReason
+			}
+			result = matchUnknown
+		}
+	}
+	return result, reason
+}
+
+// matchInterface 接口匹配，支持 "+" 通配后缀
+func matchInterface(pattern, name, label string) (matchResult, string) {
+	if name == "" {
+		return matchUnknown, label + "未知"
+	}
+	if strings.HasSuffix(pattern, "+") {
+		if strings.HasPrefix(name, strings.TrimSuffix(pattern, "+")) {
+			return matchYes, ""
+		}
+		return matchNo, ""
+	}
+	if pattern == name {
+		return matchYes, ""
+	}
+	return matchNo, ""
+}
+
+// matchProtocol 协议匹配，支持协议号
+func matchProtocol(protocol, packetProtocol string) (matchResult, string) {
+	protocol = strings.ToLower(protocol)
+	switch protocol {
+	case "all", "0":
+		return matchYes, ""
+	case "6":
+		protocol = "tcp"
+	case "17":
+		protocol = "udp"
+	case "1":
+		protocol = "icmp"
+	}
+	if packetProtocol == "" {
+		return matchUnknown, "协议未知"
+	}
+	if protocol == strings.ToLower(packetProtocol) {
+		return matchYes, ""
+	}
+	return matchNo, ""
+}
+
+// matchAddress 地址或网段匹配
+func matchAddress(value, address, label string) (matchResult, string) {
+	if address == "" {
+		return matchUnknown, label + "未指定"
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return matchUnknown, label + "无效"
+	}
+	if !strings.Contains(value, "/") {
+		if other := net.ParseIP(value); other != nil && other.Equal(ip) {
+			return matchYes, ""
+		}
+		return matchNo, ""
+	}
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return matchUnknown, label + "条件无法解析: " + value
+	}
+	if network.Contains(ip) {
+		return matchYes, ""
+	}
+	return matchNo, ""
+}
+
+// matchPorts 端口匹配，支持 "起:止" 范围和 multiport 的逗号列表
+func matchPorts(value string, port int, label string) (matchResult, string) {
+	if port == 0 {
+		return matchUnknown, label + "未指定"
+	}
+	for _, part := range strings.Split(value, ",") {
+		if _, err := strconv.Atoi(strings.SplitN(part, ":", 2)[0]); err != nil {
+			return matchUnknown, label + "条件无法解析: " + value
+		}
+		if portInRange(port, part) {
+			return matchYes, ""
+		}
+	}
+	return matchNo, ""
+}
+
+// matchAddrType addrtype 模块匹配，仅支持 LOCAL
+func (s *PacketSimulator) matchAddrType(value, address string) (matchResult, string) {
+	if !strings.EqualFold(value, "LOCAL") {
+		return matchUnknown, "不支持的地址类型 " + value
+	}
+	if address == "" {
+		return matchUnknown, "地址未指定"
+	}
+	if s.isLocal(address) {
+		return matchYes, ""
+	}
+	return matchNo, ""
+}
+
+// matchTCPFlags --tcp-flags 匹配；NEW 连接按 SYN 包处理，其余状态无法判断
+func matchTCPFlags(mask, comparison, ctState string) matchResult {
+	if !strings.EqualFold(ctState, "NEW") {
+		return matchUnknown
+	}
+	flags := func(list string) map[string]bool {
+		set := make(map[string]bool)
+		for _, flag := range strings.Split(strings.ToUpper(list), ",") {
+			if flag == "ALL" {
+				for _, f := range []string{"SYN", "ACK", "FIN", "RST", "URG", "PSH"} {
+					set[f] = true
+				}
+				continue
+			}
+			set[flag] = true
+		}
+		return set
+	}
+	maskSet, comparisonSet := flags(mask), flags(comparison)
+	for flag := range maskSet {
+		// SYN 包只设置了 SYN 标志
+		if (flag == "SYN") != comparisonSet[flag] {
+			return matchNo
+		}
+	}
+	return matchYes
+}
@@ -0,0 +1,141 @@
+package services
+
+import "testing"
+
+const sampleSimulationRuleset = `*nat
+:PREROUTING ACCEPT [0:0]
+:INPUT ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:POSTROUTING ACCEPT [0:0]
+:DOCKER - [0:0]
+-A PREROUTING -m addrtype --dst-type LOCAL -j DOCKER
+-A PREROUTING -i eth0 -p tcp -m tcp --dport 80 -j REDIRECT --to-ports 3000
+-A POSTROUTING -s 172.17.0.0/16 ! -o docker0 -j MASQUERADE
+-A DOCKER -i docker0 -j RETURN
+-A DOCKER ! -i docker0 -p tcp -m tcp --dport 8443 -j DNAT --to-destination 172.17.0.2:443
+COMMIT
+*filter
+:INPUT DROP [0:0]
+:FORWARD DROP [0:0]
+:OUTPUT ACCEPT [0:0]
+:SERVICES - [0:0]
+-A INPUT -i lo -j ACCEPT
+-A INPUT -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT
+-A INPUT -m set --match-set blocklist src -j DROP
+-A INPUT -i wg0 -j ACCEPT
+-A INPUT -s 10.0.0.0/8 -p tcp -m tcp --dport 5432 -j ACCEPT
+-A INPUT -p tcp -m multiport --dports 22,8000:8100 -j SERVICES
+-A INPUT -p tcp -m tcp --tcp-flags FIN,SYN,RST,ACK SYN -m tcp --dport 9000 -j LOG
+-A INPUT -i eth0 -p udp -m udp --dport 53 -j REJECT --reject-with icmp-port-unreachable
+-A INPUT -i eth0 -p tcp -m conntrack --ctstate DNAT -m tcp --dport 3000 -j ACCEPT
+-A SERVICES -p tcp -m tcp --dport 8081 -j RETURN
+-A SERVICES -j ACCEPT
+COMMIT
+`
+
+func newTestSimulator(t *testing.T) *PacketSimulator {
+	ruleset, err := ParseIPTablesSave(sampleSimulationRuleset)
+	if err != nil {
+		t.Fatalf("ParseIPTablesSave returned error: %v", err)
+	}
+	return NewPacketSimulator(ruleset, []string{"192.168.1.10/24", "172.17.0.1/16", "10.8.0.1/24"})
+}
+
+func TestPacketSimulatorTraverse(t *testing.T) {
+	simulator := newTestSimulator(t)
+
+	tests := []struct {
+		name         string
+		packet       SimPacket
+		wantVerdict  string
+		wantChain    string
+		wantPosition int
+		wantUnknown  int
+	}{
+		{"loopback", SimPacket{InInterface: "lo", Protocol: "tcp", Destination: "127.0.0.1", DestPort: 5432}, "ACCEPT", "INPUT", 1, 0},
+		{"established", SimPacket{InInterface: "eth0", Protocol: "tcp", Destination: "192.168.1.10", DestPort: 5432, CtState: "ESTABLISHED"}, "ACCEPT", "INPUT", 2, 0},
+		{"tunnel", SimPacket{InInterface: "wg0", Protocol: "udp", Destination: "10.8.0.1", DestPort: 161}, "ACCEPT", "INPUT", 4, 1},
+		{"source unknown falls to policy", SimPacket{InInterface: "eth0", Protocol: "tcp", Destination: "192.168.1.10", DestPort: 5432}, "DROP", "INPUT", 0, 2},
+		{"source matches", SimPacket{InInterface: "eth0", Protocol: "tcp", Source: "10.1.2.3", Destination: "192.168.1.10", DestPort: 5432}, "ACCEPT", "INPUT", 5, 1},
+		{"multiport into user chain", SimPacket{InInterface: "eth0", Protocol: "tcp", Destination: "192.168.1.10", DestPort: 8050}, "ACCEPT", "SERVICES", 2, 1},
+		{"return from user chain", SimPacket{InInterface: "eth0", Protocol: "tcp", Destination: "192.168.1.10", DestPort: 8081}, "DROP", "INPUT", 0, 1},
+		{"log is not terminal", SimPacket{InInterface: "eth0", Protocol: "tcp", Destination: "192.168.1.10", DestPort: 9000}, "DROP", "INPUT", 0, 1},
+		{"reject", SimPacket{InInterface: "eth0", Protocol: "udp", Destination: "192.168.1.10", DestPort: 53}, "REJECT", "INPUT", 8, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict := simulator.Traverse("filter", "INPUT", tt.packet)
+			if verdict.Verdict != tt.wantVerdict {
+				t.Fatalf("verdict = %s, want %s (trace %+v)", verdict.Verdict, tt.wantVerdict, verdict.Trace)
+			}
+			if verdict.Rule.Chain != tt.wantChain || verdict.Rule.Position != tt.wantPosition {
+				t.Errorf("deciding rule = %s#%d, want %s#%d", verdict.Rule.Chain, verdict.Rule.Position, tt.wantChain, tt.wantPosition)
+			}
+			if len(verdict.Uncertain) != tt.wantUnknown {
+				t.Errorf("uncertain rules = %+v, want %d", verdict.Uncertain, tt.wantUnknown)
+			}
+		})
+	}
+}
+
+func TestPacketSimulatorInputNAT(t *testing.T) {
+	simulator := newTestSimulator(t)
+
+	redirected := simulator.SimulateInput(SimPacket{InInterface: "eth0", Protocol: "tcp", Destination: "192.168.1.10", DestPort: 80})
+	if redirected.Verdict != "ACCEPT" || redirected.Packet.DestPort != 3000 || redirected.NAT == nil || redirected.NAT.Target != "REDIRECT" {
+		t.Errorf("unexpected redirect result: %+v", redirected)
+	}
+
+	forwarded := simulator.SimulateInput(SimPacket{InInterface: "eth0", Protocol: "tcp", Destination: "192.168.1.10", DestPort: 8443})
+	if forwarded.Verdict != "FORWARD" || forwarded.Packet.Destination != "172.17.0.2" || forwarded.Packet.DestPort != 443 {
+		t.Errorf("unexpected dnat result: %+v", forwarded)
+	}
+
+	// docker0 进入的数据包在 DOCKER 链中 RETURN，不做DNAT
+	fromBridge := simulator.SimulateInput(SimPacket{InInterface: "docker0", Protocol: "tcp", Destination: "172.17.0.1", DestPort: 8443})
+	if fromBridge.NAT != nil || fromBridge.Verdict != "DROP" {
+		t.Errorf("unexpected bridge result: %+v", fromBridge)
+	}
+}
+
+func TestAnalyzeExposure(t *testing.T) {
+	simulator := newTestSimulator(t)
+	services := []ListeningService{
+		{Protocol: "tcp", Address: "0.0.0.0", Port: 22, Process: "sshd"},
+		{Protocol: "tcp", Address: "0.0.0.0", Port: 3000, Process: "nginx"},
+		{Protocol: "udp", Address: "0.0.0.0", Port: 53, Process: "dnsmasq"},
+		{Protocol: "tcp", Address: "127.0.0.1", Port: 6379, Process: "redis-server"},
+	}
+	interfaces := []ExposureInterface{
+		{Name: "eth0", Kind: "external", Address: "192.168.1.10"},
+		{Name: "wg0", Kind: "tunnel", Address: "10.8.0.1"},
+	}
+
+	report := AnalyzeExposure(simulator, services, interfaces, "")
+
+	want := map[string]map[string]string{
+		"sshd":         {"eth0": ExposureReachable, "wg0": ExposureReachable},
+		"nginx":        {"eth0": ExposureNATOnly, "wg0": ExposureReachable},
+		"dnsmasq":      {"eth0": ExposureBlocked, "wg0": ExposureReachable},
+		"redis-server": {"eth0": ExposureBlocked, "wg0": ExposureBlocked},
+	}
+	for _, service := range report.Services {
+		for _, exposure := range service.Interfaces {
+			if got := exposure.Status; got != want[service.Process][exposure.Interface] {
+				t.Errorf("%s on %s: status %s (%s), want %s", service.Process, exposure.Interface, got, exposure.Reason, want[service.Process][exposure.Interface])
+			}
+		}
+	}
+
+	nginx := report.Services[1].Interfaces[0]
+	if len(nginx.NATEntries) != 1 || nginx.NATEntries[0].Port != 80 || nginx.NATEntries[0].NATRule.Target != "REDIRECT" {
+		t.Errorf("unexpected nginx NAT entries: %+v", nginx.NATEntries)
+	}
+	dns := report.Services[2].Interfaces[0]
+	if dns.DecidingRule == nil || dns.DecidingRule.Target != "REJECT" {
+		t.Errorf("expected REJECT deciding rule for dns on eth0, got %+v", dns.DecidingRule)
+	}
+	if report.Summary[ExposureReachable] != 4 || report.Summary[ExposureNATOnly] != 1 || report.Summary[ExposureBlocked] != 3 {
+		t.Errorf("unexpected summary: %v", report.Summary)
+	}
+}
@@ -0,0 +1,531 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"syscall"
+
+	"iptables-management-backend/models"
+)
+
+// PathQuery 两个端点间的路径分析请求，端点可以是接口名、地址或网段
+type PathQuery struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Protocol   string `json:"protocol"` // tcp, udp, icmp，默认 tcp
+	SourcePort int    `json:"source_port,omitempty"`
+	DestPort   int    `json:"dest_port,omitempty"`
+}
+
+// PathEndpoint 解析后的端点：接口端点取接口网段内的一个对端地址，本机地址和 lo 视为本机收发
+type PathEndpoint struct {
+	Input     string `json:"input"`
+	Interface string `json:"interface,omitempty"`
+	Kind      string `json:"kind,omitempty"` // physical, vlan, bridge, veth, bond, tunnel, loopback...
+	Address   string `json:"address"`        // 模拟使用的地址
+	Subnet    string `json:"subnet,omitempty"`
+	Local     bool   `json:"local"`
+}
+
+// PathRoute 路由决策结果
+type PathRoute struct {
+	Destination string `json:"destination"`
+	Result      string `json:"result"` // route, local, blackhole, unreachable, prohibit, no_route
+	Interface   string `json:"interface,omitempty"`
+	Gateway     string `json:"gateway,omitempty"`
+	Table       string `json:"table,omitempty"`
+}
+
+// PathReply 已放行连接的回包（ESTABLISHED）经过的链和结果
+type PathReply struct {
+	Verdict string                     `json:"verdict"`
+	Rule    *SimStep                   `json:"rule,omitempty"`
+	Path    []models.CommunicationStep `json:"path"`
+}
+
+// PathDirection 一个方向上新建连接的路径分析结果
+type PathDirection struct {
+	From      string                     `json:"from"`
+	To        string                     `json:"to"`
+	Packet    SimPacket                  `json:"packet"`       // 初始数据包
+	Final     SimPacket                  `json:"final_packet"` // 经NAT改写后的数据包
+	Verdict   string                     `json:"verdict"`      // ACCEPT, DROP, REJECT，或路由失败时的 NO_ROUTE、BLACKHOLE 等
+	Rule      *SimStep                   `json:"rule,omitempty"`
+	NAT       []SimStep                  `json:"nat,omitempty"`
+	Route     *PathRoute                 `json:"route,omitempty"`
+	Path      []models.CommunicationStep `json:"path"`
+	Reply     *PathReply                 `json:"reply,omitempty"`
+	Uncertain []SimStep                  `json:"uncertain,omitempty"`
+	Warnings  []string                   `json:"warnings,omitempty"`
+}
+
+// PathAnalysis 两个端点间双向的路径分析
+type PathAnalysis struct {
+	Query   PathQuery     `json:"query"`
+	From    PathEndpoint  `json:"from"`
+	To      PathEndpoint  `json:"to"`
+	Forward PathDirection `json:"forward"`
+	Reverse PathDirection `json:"reverse"`
+}
+
+// pathHookDescriptions 各表和链在路径中的作用
+var pathHookDescriptions = map[string]string{
+	"raw/PREROUTING":     "连接跟踪前处理",
+	"mangle/PREROUTING":  "路由前包标记和修改",
+	"nat/PREROUTING":     "目的地址转换（DNAT）",
+	"mangle/INPUT":       "本机接收前包处理",
+	"filter/INPUT":       "本机入站过滤",
+	"mangle/FORWARD":     "转发前包处理",
+	"filter/FORWARD":     "转发过滤",
+	"raw/OUTPUT":         "本机发出的连接跟踪前处理",
+	"mangle/OUTPUT":      "本机发出的包标记和修改",
+	"nat/OUTPUT":         "本机发出的目的地址转换（DNAT）",
+	"filter/OUTPUT":      "本机出站过滤",
+	"mangle/POSTROUTING": "发出前包处理",
+	"nat/POSTROUTING":    "源地址转换（SNAT/MASQUERADE）",
+}
+
+// pathAnalyzer 基于规则集模拟和路由决策分析端点间的路径
+type pathAnalyzer struct {
+	simulator *PacketSimulator
+	route     func(destination, source, iif string) PathRoute
+	addresses map[string]string // 接口 -> 第一个IPv4地址，用于 MASQUERADE 和 REDIRECT
+}
+
+// pathWalk 数据包沿路径经过各链的累计结果
+type pathWalk struct {
+	analyzer  *pathAnalyzer
+	packet    SimPacket
+	steps     []models.CommunicationStep
+	nat       []SimStep
+	rule      *SimStep
+	verdict   string
+	uncertain []SimStep
+}
+
+// AnalyzePath 分析任意两个接口、地址或网段之间双向的链路径、NAT、路由决策和结果（仅IPv4）
+func (s *NetworkService) AnalyzePath(query PathQuery) (*PathAnalysis, error) {
+	log.Printf("[DEBUG] NetworkService.AnalyzePath called with query: %+v", query)
+
+	if err := normalizePathQuery(&query); err != nil {
+		return nil, err
+	}
+	inventory, err := loadInterfaceInventory()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load interfaces: %v", err)
+	}
+	ruleset, err := LoadSavedRuleset()
+	if err != nil {
+		return nil, err
+	}
+	rules, err := s.GetRoutingRules("inet")
+	if err != nil {
+		return nil, err
+	}
+	routes, err := s.GetRouteTable(RouteFilter{Family: "inet"})
+	if err != nil {
+		return nil, err
+	}
+
+	var localAddrs []string
+	addresses := make(map[string]string)
+	for _, link := range inventory.links {
+		ips := inventory.ipv4Addresses(link.Name)
+		localAddrs = append(localAddrs, ips...)
+		if len(ips) > 0 {
+			addresses[link.Name] = ips[0]
+		}
+	}
+
+	analyzer := &pathAnalyzer{
+		simulator: NewPacketSimulator(ruleset, localAddrs),
+		addresses: addresses,
+		route: func(destination, source, iif string) PathRoute {
+			resolution := resolveRoute(rules, routes, RouteQuery{Destination: destination, Source: source, IIf: iif, UID: -1})
+			return pathRouteFromResolution(destination, resolution)
+		},
+	}
+
+	from, err := analyzer.endpoint(inventory, query.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := analyzer.endpoint(inventory, query.To)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PathAnalysis{
+		Query:   query,
+		From:    from,
+		To:      to,
+		Forward: analyzer.analyze(from, to, query),
+		Reverse: analyzer.analyze(to, from, query),
+	}, nil
+}
+
+// normalizePathQuery 校验端点、协议和端口
+func normalizePathQuery(query *PathQuery) error {
+	query.From, query.To = strings.TrimSpace(query.From), strings.TrimSpace(query.To)
+	if query.From == "" || query.To == "" {
+		return invalidf("invalid query: from and to are required")
+	}
+	query.Protocol = strings.ToLower(query.Protocol)
+	if query.Protocol == "" {
+		query.Protocol = "tcp"
+	}
+	switch query.Protocol {
+	case "tcp", "udp":
+	case "icmp":
+		if query.SourcePort != 0 || query.DestPort != 0 {
+			return invalidf("invalid query: ports require protocol tcp or udp")
+		}
+	default:
+		return invalidf("invalid protocol %s", query.Protocol)
+	}
+	for _, port := range []int{query.SourcePort, query.DestPort} {
+		if port < 0 || port > 65535 {
+			return invalidf("invalid port %d", port)
+		}
+	}
+	return nil
+}
+
+// pathRouteFromResolution 转换路由解析结果
+func pathRouteFromResolution(destination string, resolution *RouteResolution) PathRoute {
+	route := PathRoute{Destination: destination, Result: resolution.Result, Table: resolution.Table}
+	if resolution.Route == nil {
+		return route
+	}
+	route.Interface, route.Gateway = resolution.Route.Interface, resolution.Route.Gateway
+	if route.Interface == "" && len(resolution.Route.Nexthops) > 0 {
+		route.Interface, route.Gateway = resolution.Route.Nexthops[0].Interface, resolution.Route.Nexthops[0].Gateway
+	}
+	if resolution.Route.Type == "local" {
+		route.Result = "local"
+	}
+	return route
+}
+
+// endpoint 解析端点：接口名、本机地址或远端地址/网段（远端按路由确定所在接口）
+func (a *pathAnalyzer) endpoint(inventory *interfaceInventory, input string) (PathEndpoint, error) {
+	endpoint := PathEndpoint{Input: input}
+
+	if link, ok := inventory.link(input); ok {
+		endpoint.Interface = input
+		endpoint.Kind = pathInterfaceKind(link)
+		if link.Flags&syscall.IFF_LOOPBACK != 0 {
+			endpoint.Address, endpoint.Subnet, endpoint.Local = "127.0.0.1", "127.0.0.0/8", true
+			return endpoint, nil
+		}
+		endpoint.Address, endpoint.Subnet = representativeAddress(inventory.addrs[link.Index])
+		if endpoint.Address == "" {
+			return endpoint, invalidf("invalid endpoint %s: interface has no IPv4 subnet to pick an address from, specify an address or subnet instead", input)
+		}
+		return endpoint, nil
+	}
+
+	ip := net.ParseIP(input)
+	if ip == nil {
+		parsed, network, err := net.ParseCIDR(input)
+		if err != nil {
+			return endpoint, invalidf("invalid endpoint %s: not an interface, address or subnet", input)
+		}
+		endpoint.Subnet = network.String()
+		ip = parsed
+		if ones, bits := network.Mask.Size(); bits-ones >= 2 {
+			ip = nextIP(network.IP)
+		}
+	}
+	if ip.To4() == nil {
+		return endpoint, invalidf("invalid endpoint %s: only IPv4 is supported", input)
+	}
+	endpoint.Address = ip.To4().String()
+
+	if a.simulator.isLocal(endpoint.Address) {
+		endpoint.Local = true
+		endpoint.Interface = "lo"
+		for name, address := range a.addresses {
+			if address == endpoint.Address {
+				endpoint.Interface = name
+			}
+		}
+	} else {
+		endpoint.Interface = a.route(endpoint.Address, "", "").Interface
+	}
+	if link, ok := inventory.link(endpoint.Interface); ok {
+		endpoint.Kind = pathInterfaceKind(link)
+	}
+	return endpoint, nil
+}
+
+// pathInterfaceKind 接口类型，未归类的链路类型直接使用内核类型，无类型时视为物理接口
+func pathInterfaceKind(link *netlinkLink) string {
+	if kind := interfaceTypeFromKind(link.Kind, link.Flags); kind != "" {
+		return kind
+	}
+	if link.Kind != "" {
+		return link.Kind
+	}
+	return "physical"
+}
+
+// representativeAddress 接口网段内代表对端主机的地址：点对点接口取对端，否则取网段内第一个非本机地址
+func representativeAddress(addrs []netlinkAddr) (string, string) {
+	for _, addr := range addrs {
+		if addr.Family != syscall.AF_INET {
+			continue
+		}
+		if addr.Peer != nil {
+			return addr.Peer.String(), addr.Peer.String() + "/32"
+		}
+		if addr.PrefixLen > 30 {
+			continue
+		}
+		network := &net.IPNet{IP: addr.IP.Mask(net.CIDRMask(addr.PrefixLen, 32)), Mask: net.CIDRMask(addr.PrefixLen, 32)}
+		host := nextIP(network.IP)
+		if host.Equal(addr.IP) {
+			host = nextIP(host)
+		}
+		return host.String(), network.String()
+	}
+	return "", ""
+}
+
+// nextIP 下一个IPv4地址
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, 4)
+	copy(next, ip.To4())
+	for i := 3; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+// analyze 模拟从 from 到 to 的新建连接，并在放行时模拟回包
+func (a *pathAnalyzer) analyze(from, to PathEndpoint, query PathQuery) PathDirection {
+	packet := SimPacket{
+		Protocol:    query.Protocol,
+		Source:      from.Address,
+		Destination: to.Address,
+		SourcePort:  query.SourcePort,
+		DestPort:    query.DestPort,
+		CtState:     "NEW",
+	}
+	direction := PathDirection{From: from.Input, To: to.Input, Packet: packet}
+	walk := &pathWalk{analyzer: a, packet: packet}
+
+	var route PathRoute
+	if from.Local {
+		// 本机发出：先路由，再经过 OUTPUT 各表，DNAT 改写目的地址后重新路由
+		route = walk.routeDecision("", "")
+		if walk.verdict == "" && walk.hooks("raw/OUTPUT", "mangle/OUTPUT", "nat/OUTPUT") {
+			if walk.packet.Destination != route.Destination {
+				route = walk.routeDecision("", "")
+			}
+			walk.hooks("filter/OUTPUT")
+		}
+		if walk.verdict == "" && walk.hooks("mangle/POSTROUTING", "nat/POSTROUTING") && route.Result == "local" {
+			walk.packet.InInterface = "lo"
+			walk.hooks("mangle/INPUT", "filter/INPUT")
+		}
+	} else {
+		walk.packet.InInterface = from.Interface
+		if walk.hooks("raw/PREROUTING", "mangle/PREROUTING", "nat/PREROUTING") {
+			route = walk.routeDecision(walk.packet.Source, from.Interface)
+			if walk.verdict == "" {
+				if route.Result == "local" {
+					walk.hooks("mangle/INPUT", "filter/INPUT")
+				} else {
+					walk.hooks("mangle/FORWARD", "filter/FORWARD", "mangle/POSTROUTING", "nat/POSTROUTING")
+				}
+			}
+		}
+	}
+
+	if route.Destination != "" {
+		direction.Route = &route
+		switch {
+		case to.Local && route.Result == "route":
+			direction.Warnings = append(direction.Warnings, fmt.Sprintf("目的地址 %s 不是本机地址，路由决策将其经 %s 转发", route.Destination, route.Interface))
+		case !to.Local && route.Result == "route" && to.Interface != "" && route.Interface != to.Interface:
+			direction.Warnings = append(direction.Warnings, fmt.Sprintf("路由决策将数据包经 %s 发出，而不是 %s", route.Interface, to.Interface))
+		}
+	}
+	if walk.verdict == "" {
+		walk.verdict = "ACCEPT"
+	}
+
+	direction.Final = walk.packet
+	direction.Verdict = walk.verdict
+	direction.Rule = walk.rule
+	direction.NAT = walk.nat
+	direction.Path = walk.steps
+	direction.Uncertain = walk.uncertain
+	if direction.Verdict == "ACCEPT" {
+		direction.Reply = a.reply(from, to, walk.packet)
+	}
+	return direction
+}
+
+// reply 模拟已放行连接的回包：回包由连接跟踪处理，不再经过 nat 表
+func (a *pathAnalyzer) reply(from, to PathEndpoint, final SimPacket) *PathReply {
+	packet := SimPacket{
+		Protocol:     final.Protocol,
+		Source:       final.Destination,
+		Destination:  final.Source,
+		SourcePort:   final.DestPort,
+		DestPort:     final.SourcePort,
+		InInterface:  final.OutInterface,
+		OutInterface: final.InInterface,
+		CtState:      "ESTABLISHED",
+	}
+	walk := &pathWalk{analyzer: a, packet: packet}
+
+	switch {
+	case from.Local && to.Local:
+		walk.packet.InInterface, walk.packet.OutInterface = "lo", "lo"
+		walk.hooks("raw/OUTPUT", "mangle/OUTPUT", "filter/OUTPUT", "mangle/POSTROUTING", "mangle/INPUT", "filter/INPUT")
+	case to.Local:
+		// 本机应答发往 from 所在接口
+		walk.packet.InInterface = ""
+		walk.packet.OutInterface = from.Interface
+		walk.hooks("raw/OUTPUT", "mangle/OUTPUT", "filter/OUTPUT", "mangle/POSTROUTING")
+	case from.Local:
+		walk.packet.OutInterface = ""
+		walk.hooks("raw/PREROUTING", "mangle/PREROUTING", "mangle/INPUT", "filter/INPUT")
+	default:
+		walk.hooks("raw/PREROUTING", "mangle/PREROUTING", "mangle/FORWARD", "filter/FORWARD", "mangle/POSTROUTING")
+	}
+
+	if walk.verdict == "" {
+		walk.verdict = "ACCEPT"
+	}
+	return &PathReply{Verdict: walk.verdict, Rule: walk.rule, Path: walk.steps}
+}
+
+// hooks 依次经过各表的链（"表/链"），数据包被丢弃时返回 false
+func (w *pathWalk) hooks(hooks ...string) bool {
+	for _, hook := range hooks {
+		parts := strings.SplitN(hook, "/", 2)
+		if !w.hook(parts[0], parts[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// hook 模拟经过一张表的内置链并记录为路径中的一步
+func (w *pathWalk) hook(table, chain string) bool {
+	result := w.analyzer.simulator.Traverse(table, chain, w.packet)
+	w.uncertain = append(w.uncertain, result.Uncertain...)
+	w.packet = result.Packet
+
+	description := pathHookDescriptions[table+"/"+chain]
+	action := result.Verdict
+	switch {
+	case result.Rule == nil:
+	case result.Rule.Position > 0:
+		description += fmt.Sprintf(" - 命中 %s 链第%d条规则: %s", result.Rule.Chain, result.Rule.Position, result.Rule.Spec)
+	case result.Rule.Reason != "":
+		description += " - " + result.Rule.Reason
+	}
+
+	proceed := true
+	switch result.Verdict {
+	case "DROP", "REJECT":
+		w.verdict, w.rule, proceed = result.Verdict, result.Rule, false
+	case "DNAT", "REDIRECT":
+		if result.Verdict == "REDIRECT" {
+			// REDIRECT 改写为入接口的地址（本机发出时为 127.0.0.1）
+			w.packet.Destination = "127.0.0.1"
+			if address := w.analyzer.addresses[w.packet.InInterface]; address != "" {
+				w.packet.Destination = address
+			}
+		}
+		w.nat = append(w.nat, *result.Rule)
+		action = fmt.Sprintf("%s -> %s", result.Verdict, pathAddress(w.packet.Destination, w.packet.DestPort))
+	case "SNAT", "MASQUERADE":
+		if result.Verdict == "MASQUERADE" {
+			if address := w.analyzer.addresses[w.packet.OutInterface]; address != "" {
+				w.packet.Source = address
+			}
+		}
+		w.nat = append(w.nat, *result.Rule)
+		action = fmt.Sprintf("%s -> %s", result.Verdict, pathAddress(w.packet.Source, w.packet.SourcePort))
+	case "ACCEPT":
+		if table == "filter" {
+			w.rule = result.Rule
+		}
+	}
+
+	w.steps = append(w.steps, models.CommunicationStep{
+		Step:        len(w.steps) + 1,
+		Description: description,
+		Table:       table,
+		Chain:       chain,
+		Action:      action,
+		Interface:   pathStepInterface(chain, w.packet),
+	})
+	return proceed
+}
+
+// routeDecision 按当前目的地址做路由决策并记录为路径中的一步；无可用路由时结束路径
+func (w *pathWalk) routeDecision(source, iif string) PathRoute {
+	route := w.analyzer.route(w.packet.Destination, source, iif)
+
+	var description, action string
+	switch route.Result {
+	case "local":
+		description = fmt.Sprintf("路由决策 - 目的地址 %s 为本机地址", route.Destination)
+		action = "本机接收"
+	case "route":
+		description = fmt.Sprintf("路由决策 - 目的地址 %s 经 %s 发出", route.Destination, route.Interface)
+		if route.Gateway != "" {
+			description += "，网关 " + route.Gateway
+		}
+		action = "转发至 " + route.Interface
+		w.packet.OutInterface = route.Interface
+	default:
+		description = fmt.Sprintf("路由决策 - 目的地址 %s 无可用路由（%s）", route.Destination, route.Result)
+		action = "丢弃"
+		w.verdict = strings.ToUpper(route.Result)
+	}
+	if route.Table != "" {
+		description += "（路由表 " + route.Table + "）"
+	}
+
+	w.steps = append(w.steps, models.CommunicationStep{
+		Step:        len(w.steps) + 1,
+		Description: description,
+		Table:       "routing",
+		Chain:       "ROUTING",
+		Action:      action,
+		Interface:   route.Interface,
+	})
+	return route
+}
+
+// pathStepInterface 步骤中显示的接口：入方向的链为入接口，出方向为出接口，FORWARD为两者
+func pathStepInterface(chain string, packet SimPacket) string {
+	switch chain {
+	case "PREROUTING", "INPUT":
+		return packet.InInterface
+	case "FORWARD":
+		return packet.InInterface + "->" + packet.OutInterface
+	}
+	return packet.OutInterface
+}
+
+// pathAddress 地址和端口
+func pathAddress(address string, port int) string {
+	if port == 0 {
+		return address
+	}
+	return fmt.Sprintf("%s:%d", address, port)
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+const samplePathRuleset = `*nat
+:PREROUTING ACCEPT [0:0]
+:INPUT ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:POSTROUTING ACCEPT [0:0]
+-A PREROUTING -i eth0 -p tcp -m tcp --dport 8080 -j DNAT --to-destination 172.17.0.2:80
+-A POSTROUTING -s 172.17.0.0/16 -o eth0 -j MASQUERADE
+COMMIT
+*filter
+:INPUT DROP [0:0]
+:FORWARD DROP [0:0]
+:OUTPUT ACCEPT [0:0]
+-A INPUT -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT
+-A INPUT -i wg0 -p tcp -m tcp --dport 22 -j ACCEPT
+-A FORWARD -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT
+-A FORWARD -i wg0 -o docker0 -j ACCEPT
+-A FORWARD -i docker0 -o eth0 -j ACCEPT
+-A FORWARD -i eth0 -o docker0 -m conntrack --ctstate DNAT -j ACCEPT
+COMMIT
+`
+
+func newTestPathAnalyzer(t *testing.T) *pathAnalyzer {
+	ruleset, err := ParseIPTablesSave(samplePathRuleset)
+	if err != nil {
+		t.Fatalf("ParseIPTablesSave returned error: %v", err)
+	}
+	addresses := map[string]string{"eth0": "192.168.1.10", "docker0": "172.17.0.1", "wg0": "10.8.0.1"}
+	localAddrs := []string{"192.168.1.10", "172.17.0.1", "10.8.0.1"}
+
+	route := func(destination, source, iif string) PathRoute {
+		ip := net.ParseIP(destination)
+		result := PathRoute{Destination: destination, Result: "route", Table: "main"}
+		switch {
+		case ip.IsLoopback() || destination == "192.168.1.10" || destination == "172.17.0.1" || destination == "10.8.0.1":
+			result.Result, result.Interface, result.Table = "local", "lo", "local"
+		case strings.HasPrefix(destination, "172.17."):
+			result.Interface = "docker0"
+		case strings.HasPrefix(destination, "10.8.0."):
+			result.Interface = "wg0"
+		case strings.HasPrefix(destination, "198.51.100."):
+			result.Result, result.Interface = "blackhole", ""
+		default:
+			result.Interface, result.Gateway = "eth0", "192.168.1.1"
+		}
+		return result
+	}
+	return &pathAnalyzer{simulator: NewPacketSimulator(ruleset, localAddrs), route: route, addresses: addresses}
+}
+
+func TestPathAnalyzerAnalyze(t *testing.T) {
+	analyzer := newTestPathAnalyzer(t)
+
+	tunnel := PathEndpoint{Input: "wg0", Interface: "wg0", Address: "10.8.0.2"}
+	bridge := PathEndpoint{Input: "docker0", Interface: "docker0", Address: "172.17.0.2"}
+	uplink := PathEndpoint{Input: "eth0", Interface: "eth0", Address: "203.0.113.5"}
+	internet := PathEndpoint{Input: "8.8.8.8", Interface: "eth0", Address: "8.8.8.8"}
+	hostOnEth0 := PathEndpoint{Input: "192.168.1.10", Interface: "eth0", Address: "192.168.1.10", Local: true}
+	hostOnTunnel := PathEndpoint{Input: "10.8.0.1", Interface: "wg0", Address: "10.8.0.1", Local: true}
+	loopback := PathEndpoint{Input: "lo", Interface: "lo", Address: "127.0.0.1", Local: true}
+	blackholed := PathEndpoint{Input: "198.51.100.7", Address: "198.51.100.7"}
+	wrongInterface := PathEndpoint{Input: "eth1", Interface: "eth1", Address: "203.0.113.9"}
+
+	tests := []struct {
+		name          string
+		from, to      PathEndpoint
+		query         PathQuery
+		wantVerdict   string
+		wantChain     string
+		wantPosition  int
+		wantReply     string
+		wantNAT       int
+		wantFinalSrc  string
+		wantFinalDst  string
+		wantWarnings  int
+		wantLastChain string
+	}{
+		{"tunnel to bridge", tunnel, bridge, PathQuery{Protocol: "tcp", DestPort: 80}, "ACCEPT", "FORWARD", 2, "ACCEPT", 0, "10.8.0.2", "172.17.0.2", 0, "POSTROUTING"},
+		{"bridge to tunnel hits policy", bridge, tunnel, PathQuery{Protocol: "tcp", DestPort: 80}, "DROP", "FORWARD", 0, "", 0, "172.17.0.2", "10.8.0.2", 0, "FORWARD"},
+		{"published port via DNAT", uplink, hostOnEth0, PathQuery{Protocol: "tcp", DestPort: 8080}, "ACCEPT", "FORWARD", 4, "ACCEPT", 1, "203.0.113.5", "172.17.0.2", 1, "POSTROUTING"},
+		{"bridge to internet masqueraded", bridge, internet, PathQuery{Protocol: "icmp"}, "ACCEPT", "FORWARD", 3, "ACCEPT", 1, "192.168.1.10", "8.8.8.8", 0, "POSTROUTING"},
+		{"tunnel to host service", tunnel, hostOnTunnel, PathQuery{Protocol: "tcp", DestPort: 22}, "ACCEPT", "INPUT", 2, "ACCEPT", 0, "10.8.0.2", "10.8.0.1", 0, "INPUT"},
+		{"uplink to host dropped", uplink, hostOnEth0, PathQuery{Protocol: "tcp", DestPort: 22}, "DROP", "INPUT", 0, "", 0, "203.0.113.5", "192.168.1.10", 0, "INPUT"},
+		{"host to tunnel", loopback, tunnel, PathQuery{Protocol: "tcp", DestPort: 22}, "ACCEPT", "", 0, "ACCEPT", 0, "127.0.0.1", "10.8.0.2", 0, "POSTROUTING"},
+		{"blackhole route", tunnel, blackholed, PathQuery{Protocol: "icmp"}, "BLACKHOLE", "", 0, "", 0, "10.8.0.2", "198.51.100.7", 0, "ROUTING"},
+		{"routed via other interface", tunnel, wrongInterface, PathQuery{Protocol: "icmp"}, "DROP", "FORWARD", 0, "", 0, "10.8.0.2", "203.0.113.9", 1, "FORWARD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			direction := analyzer.analyze(tt.from, tt.to, tt.query)
+			if direction.Verdict != tt.wantVerdict {
+				t.Fatalf("verdict = %s, want %s (path %+v)", direction.Verdict, tt.wantVerdict, direction.Path)
+			}
+			if tt.wantChain != "" {
+				if direction.Rule == nil {
+					t.Fatalf("expected deciding rule in %s", tt.wantChain)
+				}
+				if direction.Rule.Chain != tt.wantChain || direction.Rule.Position != tt.wantPosition {
+					t.Errorf("rule = %s/%d, want %s/%d", direction.Rule.Chain, direction.Rule.Position, tt.wantChain, tt.wantPosition)
+				}
+			}
+			if len(direction.NAT) != tt.wantNAT {
+				t.Errorf("nat = %+v, want %d entries", direction.NAT, tt.wantNAT)
+			}
+			if direction.Final.Source != tt.wantFinalSrc || direction.Final.Destination != tt.wantFinalDst {
+				t.Errorf("final packet = %s -> %s, want %s -> %s", direction.Final.Source, direction.Final.Destination, tt.wantFinalSrc, tt.wantFinalDst)
+			}
+			if len(direction.Warnings) != tt.wantWarnings {
+				t.Errorf("warnings = %v, want %d", direction.Warnings, tt.wantWarnings)
+			}
+			if last := direction.Path[len(direction.Path)-1]; last.Chain != tt.wantLastChain {
+				t.Errorf("last step chain = %s, want %s", last.Chain, tt.wantLastChain)
+			}
+			for i, step := range direction.Path {
+				if step.Step != i+1 {
+					t.Errorf("step %d numbered %d", i+1, step.Step)
+				}
+			}
+			switch {
+			case tt.wantReply == "" && direction.Reply != nil:
+				t.Errorf("unexpected reply for verdict %s", direction.Verdict)
+			case tt.wantReply != "" && (direction.Reply == nil || direction.Reply.Verdict != tt.wantReply):
+				t.Errorf("reply = %+v, want %s", direction.Reply, tt.wantReply)
+			}
+		})
+	}
+}
+
+func TestPathAnalyzerEndpoint(t *testing.T) {
+	analyzer := newTestPathAnalyzer(t)
+	inventory := newInterfaceInventory(
+		[]netlinkLink{
+			{Index: 1, Name: "lo", Flags: syscall.IFF_LOOPBACK},
+			{Index: 2, Name: "eth0"},
+			{Index: 3, Name: "docker0", Kind: "bridge"},
+			{Index: 4, Name: "wg0", Kind: "wireguard"},
+		},
+		[]netlinkAddr{
+			{Index: 2, Family: syscall.AF_INET, IP: net.ParseIP("192.168.1.10").To4(), PrefixLen: 24},
+			{Index: 3, Family: syscall.AF_INET, IP: net.ParseIP("172.17.0.1").To4(), PrefixLen: 16},
+			{Index: 4, Family: syscall.AF_INET, IP: net.ParseIP("10.8.0.1").To4(), PrefixLen: 32},
+		},
+	)
+
+	tests := []struct {
+		input       string
+		wantIface   string
+		wantKind    string
+		wantAddress string
+		wantLocal   bool
+		wantErr     bool
+	}{
+		{"lo", "lo", "loopback", "127.0.0.1", true, false},
+		{"eth0", "eth0", "physical", "192.168.1.1", false, false},
+		{"docker0", "docker0", "bridge", "172.17.0.2", false, false},
+		{"wg0", "", "", "", false, true},
+		{"192.168.1.10", "eth0", "physical", "192.168.1.10", true, false},
+		{"172.17.5.0/24", "docker0", "bridge", "172.17.5.1", false, false},
+		{"8.8.8.8", "eth0", "physical", "8.8.8.8", false, false},
+		{"10.8.0.5/32", "wg0", "tunnel", "10.8.0.5", false, false},
+		{"fd00::1", "", "", "", false, true},
+		{"not-an-interface", "", "", "", false, true},
+	}
+
+	for _, tt := range tests {
+		endpoint, err := analyzer.endpoint(inventory, tt.input)
+		if tt.wantErr {
+			var validation *ValidationError
+			if !errors.As(err, &validation) {
+				t.Errorf("%s: expected validation error, got %+v, %v", tt.input, endpoint, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.input, err)
+			continue
+		}
+		if endpoint.Interface != tt.wantIface || endpoint.Kind != tt.wantKind || endpoint.Address != tt.wantAddress || endpoint.Local != tt.wantLocal {
+			t.Errorf("%s: endpoint = %+v", tt.input, endpoint)
+		}
+	}
+}
+
+func TestRepresentativeAddress(t *testing.T) {
+	tests := []struct {
+		addr        netlinkAddr
+		wantAddress string
+		wantSubnet  string
+	}{
+		{netlinkAddr{Family: syscall.AF_INET, IP: net.ParseIP("192.168.1.10").To4(), PrefixLen: 24}, "192.168.1.1", "192.168.1.0/24"},
+		{netlinkAddr{Family: syscall.AF_INET, IP: net.ParseIP("192.168.1.1").To4(), PrefixLen: 24}, "192.168.1.2", "192.168.1.0/24"},
+		{netlinkAddr{Family: syscall.AF_INET, IP: net.ParseIP("10.0.0.1").To4(), PrefixLen: 32, Peer: net.ParseIP("10.0.0.2").To4()}, "10.0.0.2", "10.0.0.2/32"},
+		{netlinkAddr{Family: syscall.AF_INET, IP: net.ParseIP("10.8.0.1").To4(), PrefixLen: 32}, "", ""},
+		{netlinkAddr{Family: syscall.AF_INET6, IP: net.ParseIP("fd00::1"), PrefixLen: 64}, "", ""},
+	}
+
+	for _, tt := range tests {
+		address, subnet := representativeAddress([]netlinkAddr{tt.addr})
+		if address != tt.wantAddress || subnet != tt.wantSubnet {
+			t.Errorf("representativeAddress(%s/%d) = %s, %s; want %s, %s", tt.addr.IP, tt.addr.PrefixLen, address, subnet, tt.wantAddress, tt.wantSubnet)
+		}
+	}
+}
+
+func TestNormalizePathQuery(t *testing.T) {
+	query := PathQuery{From: " wg0 ", To: "docker0", Protocol: "UDP", DestPort: 53}
+	if err := normalizePathQuery(&query); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if query.From != "wg0" || query.Protocol != "udp" {
+		t.Errorf("unexpected normalized query: %+v", query)
+	}
+
+	defaulted := PathQuery{From: "wg0", To: "docker0"}
+	if err := normalizePathQuery(&defaulted); err != nil || defaulted.Protocol != "tcp" {
+		t.Errorf("expected default protocol tcp, got %+v (%v)", defaulted, err)
+	}
+
+	invalid := []PathQuery{
+		{From: "wg0"},
+		{From: "wg0", To: "docker0", Protocol: "gre"},
+		{From: "wg0", To: "docker0", Protocol: "icmp", DestPort: 80},
+		{From: "wg0", To: "docker0", DestPort: 70000},
+	}
+	for _, query := range invalid {
+		var validation *ValidationError
+		if err := normalizePathQuery(&query); !errors.As(err, &validation) {
+			t.Errorf("expected validation error for %+v, got %v", query, err)
+		}
+	}
+}
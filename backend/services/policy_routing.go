@@ -0,0 +1,545 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"iptables-management-backend/models"
+)
+
+// RouteFilter 路由表查询条件
+type RouteFilter struct {
+	Family string // inet, inet6，为空时两者都查询
+	Table  string // 表名或编号，为空或 all 表示所有表
+}
+
+// RouteQuery 路由解析的数据包特征
+type RouteQuery struct {
+	Destination string `json:"destination"`
+	Source      string `json:"source,omitempty"`
+	Mark        uint32 `json:"mark,omitempty"`
+	IIf         string `json:"iif,omitempty"` // 为空表示本机发出的数据包
+	OIf         string `json:"oif,omitempty"`
+	UID         int    `json:"uid"` // -1 表示未指定
+	IPProto     string `json:"ipproto,omitempty"`
+	SourcePort  int    `json:"source_port,omitempty"`
+	DestPort    int    `json:"dest_port,omitempty"`
+}
+
+// RouteLookupStep 解析过程中对一条策略路由规则的处理
+type RouteLookupStep struct {
+	Rule   models.RoutingRule `json:"rule"`
+	Result string             `json:"result"` // no_match, no_route, throw, suppressed, goto, nop, matched
+	Route  *models.RouteEntry `json:"route,omitempty"`
+}
+
+// RouteResolution 路由解析结果
+type RouteResolution struct {
+	Query       RouteQuery          `json:"query"`
+	Family      string              `json:"family"`
+	Result      string              `json:"result"` // route, blackhole, unreachable, prohibit, no_route
+	Rule        *models.RoutingRule `json:"rule,omitempty"`
+	Table       string              `json:"table,omitempty"`
+	Route       *models.RouteEntry  `json:"route,omitempty"`
+	Steps       []RouteLookupStep   `json:"steps"`
+	Kernel      *models.RouteEntry  `json:"kernel,omitempty"` // ip route get 的结果，用于核对
+	KernelError string              `json:"kernel_error,omitempty"`
+}
+
+// ipRouteJSON ip -j route 输出中的一条路由
+type ipRouteJSON struct {
+	Type     string   `json:"type"`
+	Dst      string   `json:"dst"`
+	Gateway  string   `json:"gateway"`
+	Dev      string   `json:"dev"`
+	Table    string   `json:"table"`
+	Protocol string   `json:"protocol"`
+	Scope    string   `json:"scope"`
+	PrefSrc  string   `json:"prefsrc"`
+	Metric   int      `json:"metric"`
+	Flags    []string `json:"flags"`
+	Nexthops []struct {
+		Gateway string `json:"gateway"`
+		Dev     string `json:"dev"`
+		Weight  int    `json:"weight"`
+	} `json:"nexthops"`
+}
+
+// ipRuleJSON ip -j rule 输出中的一条规则
+type ipRuleJSON struct {
+	Priority          int             `json:"priority"`
+	Not               json.RawMessage `json:"not"` // 取反时输出 "not": null
+	Src               string          `json:"src"`
+	SrcLen            *int            `json:"srclen"`
+	Dst               string          `json:"dst"`
+	DstLen            *int            `json:"dstlen"`
+	FWMark            string          `json:"fwmark"`
+	FWMask            string          `json:"fwmask"`
+	IIf               string          `json:"iif"`
+	OIf               string          `json:"oif"`
+	IPProto           string          `json:"ipproto"`
+	SPort             *int            `json:"sport"`
+	SPortStart        *int            `json:"sport_start"`
+	SPortEnd          *int            `json:"sport_end"`
+	DPort             *int            `json:"dport"`
+	DPortStart        *int            `json:"dport_start"`
+	DPortEnd          *int            `json:"dport_end"`
+	UIDStart          *int            `json:"uid_start"`
+	UIDEnd            *int            `json:"uid_end"`
+	Table             string          `json:"table"`
+	Action            string          `json:"action"`
+	Goto              *int            `json:"goto"`
+	SuppressPrefixLen *int            `json:"suppress_prefixlen"`
+}
+
+// routeFamilies 按查询条件确定要读取的地址族
+func routeFamilies(family string) ([]string, error) {
+	switch family {
+	case "":
+		return []string{"inet", "inet6"}, nil
+	case "inet", "inet6":
+		return []string{family}, nil
+	}
+	return nil, invalidf("invalid family: %s", family)
+}
+
+// familyFlag ip 命令的地址族参数
+func familyFlag(family string) string {
+	if family == "inet6" {
+		return "-6"
+	}
+	return "-4"
+}
+
+// GetRouteTable 获取所有路由表（ip -j route show table all）
+func (s *NetworkService) GetRouteTable(filter RouteFilter) ([]models.RouteEntry, error) {
+	log.Printf("[DEBUG] Getting route tables with filter: %+v", filter)
+
+	families, err := routeFamilies(filter.Family)
+	if err != nil {
+		return nil, err
+	}
+	table := filter.Table
+	if table == "" {
+		table = "all"
+	}
+
+	routes := []models.RouteEntry{}
+	for _, family := range families {
+		output, err := runCommand("ip", "-j", familyFlag(family), "route", "show", "table", table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute ip route: %v", err)
+		}
+		parsed, err := parseIPRoutes(family, output)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, parsed...)
+	}
+
+	log.Printf("[DEBUG] Found %d route entries", len(routes))
+	return routes, nil
+}
+
+// GetRoutingRules 获取策略路由规则（ip -j rule show）
+func (s *NetworkService) GetRoutingRules(family string) ([]models.RoutingRule, error) {
+	families, err := routeFamilies(family)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []models.RoutingRule{}
+	for _, family := range families {
+		output, err := runCommand("ip", "-j", familyFlag(family), "rule", "show")
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute ip rule: %v", err)
+		}
+		parsed, err := parseIPRules(family, output)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, parsed...)
+	}
+	return rules, nil
+}
+
+// ResolveRoute 按策略路由规则和路由表解析数据包会使用的路由，并与内核 ip route get 的结果对照
+func (s *NetworkService) ResolveRoute(query RouteQuery) (*RouteResolution, error) {
+	log.Printf("[DEBUG] Resolving route for query: %+v", query)
+
+	destination := net.ParseIP(query.Destination)
+	if destination == nil {
+		return nil, invalidf("invalid destination address: %s", query.Destination)
+	}
+	if query.Source != "" && net.ParseIP(query.Source) == nil {
+		return nil, invalidf("invalid source address: %s", query.Source)
+	}
+	family := "inet6"
+	if destination.To4() != nil {
+		family = "inet"
+	}
+
+	rules, err := s.GetRoutingRules(family)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := s.GetRouteTable(RouteFilter{Family: family})
+	if err != nil {
+		return nil, err
+	}
+
+	resolution := resolveRoute(rules, routes, query)
+	resolution.Kernel, err = kernelRouteGet(family, query)
+	if err != nil {
+		resolution.KernelError = err.Error()
+	}
+	return resolution, nil
+}
+
+// parseIPRoutes 解析 ip -j route show 的输出
+func parseIPRoutes(family string, output []byte) ([]models.RouteEntry, error) {
+	var raw []ipRouteJSON
+	if err := json.Unmarshal(output, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse ip route output: %v", err)
+	}
+
+	routes := make([]models.RouteEntry, 0, len(raw))
+	for _, item := range raw {
+		route := models.RouteEntry{
+			Destination: item.Dst,
+			Gateway:     item.Gateway,
+			Interface:   item.Dev,
+			Source:      item.PrefSrc,
+			Metric:      item.Metric,
+			Family:      family,
+			Table:       item.Table,
+			Type:        item.Type,
+			Protocol:    item.Protocol,
+			Scope:       item.Scope,
+			Flags:       item.Flags,
+		}
+		if route.Destination == "default" {
+			route.Destination = "0.0.0.0/0"
+			if family == "inet6" {
+				route.Destination = "::/0"
+			}
+		}
+		if route.Table == "" {
+			route.Table = "main"
+		}
+		if route.Type == "" {
+			route.Type = "unicast"
+		}
+		for _, nexthop := range item.Nexthops {
+			route.Nexthops = append(route.Nexthops, models.RouteNexthop{
+				Gateway:   nexthop.Gateway,
+				Interface: nexthop.Dev,
+				Weight:    nexthop.Weight,
+			})
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// parseIPRules 解析 ip -j rule show 的输出
+func parseIPRules(family string, output []byte) ([]models.RoutingRule, error) {
+	var raw []ipRuleJSON
+	if err := json.Unmarshal(output, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse ip rule output: %v", err)
+	}
+
+	rules := make([]models.RoutingRule, 0, len(raw))
+	for _, item := range raw {
+		rule := models.RoutingRule{
+			Priority:             item.Priority,
+			Family:               family,
+			Not:                  len(item.Not) > 0,
+			Source:               rulePrefix(item.Src, item.SrcLen),
+			Destination:          rulePrefix(item.Dst, item.DstLen),
+			FWMark:               item.FWMark,
+			FWMask:               item.FWMask,
+			IIf:                  item.IIf,
+			OIf:                  item.OIf,
+			IPProto:              item.IPProto,
+			SourcePort:           rulePortRange(item.SPort, item.SPortStart, item.SPortEnd),
+			DestPort:             rulePortRange(item.DPort, item.DPortStart, item.DPortEnd),
+			Action:               item.Action,
+			Table:                item.Table,
+			SuppressPrefixLength: -1,
+		}
+		if item.UIDStart != nil && item.UIDEnd != nil {
+			rule.UIDRange = fmt.Sprintf("%d-%d", *item.UIDStart, *item.UIDEnd)
+		}
+		if item.SuppressPrefixLen != nil {
+			rule.SuppressPrefixLength = *item.SuppressPrefixLen
+		}
+		switch {
+		case item.Goto != nil:
+			rule.Action = "goto"
+			rule.Goto = *item.Goto
+		case rule.Action == "" && rule.Table != "":
+			rule.Action = "lookup"
+		case rule.Action == "":
+			rule.Action = "nop"
+		}
+		rules = append(rules, rule)
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority < rules[j].Priority
+	})
+	return rules, nil
+}
+
+// rulePrefix 组合规则中的地址和前缀长度，未设置时为 all
+func rulePrefix(address string, length *int) string {
+	if address == "" || address == "all" {
+		return "all"
+	}
+	if length == nil {
+		return address
+	}
+	return fmt.Sprintf("%s/%d", address, *length)
+}
+
+// rulePortRange 组合规则中的端口或端口范围
+func rulePortRange(port, start, end *int) string {
+	if port != nil {
+		return strconv.Itoa(*port)
+	}
+	if start != nil && end != nil {
+		return fmt.Sprintf("%d-%d", *start, *end)
+	}
+	return ""
+}
+
+// resolveRoute 按优先级遍历策略路由规则，在命中的表中做最长前缀匹配
+func resolveRoute(rules []models.RoutingRule, routes []models.RouteEntry, query RouteQuery) *RouteResolution {
+	family := "inet6"
+	if ip := net.ParseIP(query.Destination); ip != nil && ip.To4() != nil {
+		family = "inet"
+	}
+	resolution := &RouteResolution{Query: query, Family: family, Result: "no_route", Steps: []RouteLookupStep{}}
+
+	gotoPriority := -1
+	for i := range rules {
+		rule := rules[i]
+		if rule.Family != family || rule.Priority < gotoPriority {
+			continue
+		}
+		step := RouteLookupStep{Rule: rule}
+
+		if !routingRuleMatches(rule, query) {
+			step.Result = "no_match"
+			resolution.Steps = append(resolution.Steps, step)
+			continue
+		}
+
+		switch rule.Action {
+		case "goto":
+			step.Result = "goto"
+			gotoPriority = rule.Goto
+			resolution.Steps = append(resolution.Steps, step)
+			continue
+		case "nop":
+			step.Result = "nop"
+			resolution.Steps = append(resolution.Steps, step)
+			continue
+		case "blackhole", "unreachable", "prohibit":
+			step.Result = "matched"
+			resolution.Steps = append(resolution.Steps, step)
+			resolution.Result = rule.Action
+			resolution.Rule = &rule
+			return resolution
+		}
+
+		route, prefixLength := lookupRouteTable(routes, family, rule.Table, query.Destination)
+		switch {
+		case route == nil:
+			step.Result = "no_route"
+		case route.Type == "throw":
+			step.Result = "throw"
+			step.Route = route
+		case rule.SuppressPrefixLength >= 0 && prefixLength <= rule.SuppressPrefixLength:
+			step.Result = "suppressed"
+			step.Route = route
+		default:
+			step.Result = "matched"
+			step.Route = route
+			resolution.Steps = append(resolution.Steps, step)
+			resolution.Rule = &rule
+			resolution.Table = rule.Table
+			resolution.Route = route
+			resolution.Result = "route"
+			if route.Type == "blackhole" || route.Type == "unreachable" || route.Type == "prohibit" {
+				resolution.Result = route.Type
+			}
+			return resolution
+		}
+		resolution.Steps = append(resolution.Steps, step)
+	}
+	return resolution
+}
+
+// routingRuleMatches 判断数据包是否满足规则的选择条件
+func routingRuleMatches(rule models.RoutingRule, query RouteQuery) bool {
+	matches := func() bool {
+		if !prefixMatches(rule.Source, query.Source) || !prefixMatches(rule.Destination, query.Destination) {
+			return false
+		}
+		if rule.FWMark != "" {
+			mark, err := strconv.ParseUint(rule.FWMark, 0, 32)
+			if err != nil {
+				return false
+			}
+			mask := uint64(0xffffffff)
+			if rule.FWMask != "" {
+				if mask, err = strconv.ParseUint(rule.FWMask, 0, 32); err != nil {
+					return false
+				}
+			}
+			if uint64(query.Mark)&mask != mark {
+				return false
+			}
+		}
+		if rule.IIf != "" {
+			// 本机发出的数据包按 iif lo 匹配
+			iif := query.IIf
+			if iif == "" {
+				iif = "lo"
+			}
+			if rule.IIf != iif {
+				return false
+			}
+		}
+		if rule.OIf != "" && rule.OIf != query.OIf {
+			return false
+		}
+		if rule.IPProto != "" && !strings.EqualFold(rule.IPProto, query.IPProto) {
+			return false
+		}
+		if rule.SourcePort != "" && !portInRange(query.SourcePort, strings.Replace(rule.SourcePort, "-", ":", 1)) {
+			return false
+		}
+		if rule.DestPort != "" && !portInRange(query.DestPort, strings.Replace(rule.DestPort, "-", ":", 1)) {
+			return false
+		}
+		if rule.UIDRange != "" && (query.UID < 0 || !portInRange(query.UID, strings.Replace(rule.UIDRange, "-", ":", 1))) {
+			return false
+		}
+		return true
+	}()
+	return matches != rule.Not
+}
+
+// prefixMatches 判断地址是否落在规则前缀内；未指定源地址时只匹配 all
+func prefixMatches(prefix, address string) bool {
+	if prefix == "all" || prefix == "" {
+		return true
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	network, _, ok := parseRoutePrefix(prefix)
+	return ok && network.Contains(ip)
+}
+
+// parseRoutePrefix 解析路由目的地址，不带前缀长度的视为主机路由
+func parseRoutePrefix(prefix string) (*net.IPNet, int, bool) {
+	if !strings.Contains(prefix, "/") {
+		ip := net.ParseIP(prefix)
+		if ip == nil {
+			return nil, 0, false
+		}
+		if ip.To4() != nil {
+			prefix += "/32"
+		} else {
+			prefix += "/128"
+		}
+	}
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, 0, false
+	}
+	length, _ := network.Mask.Size()
+	return network, length, true
+}
+
+// lookupRouteTable 在指定表中做最长前缀匹配，前缀相同时取 metric 最小的路由
+func lookupRouteTable(routes []models.RouteEntry, family, table, destination string) (*models.RouteEntry, int) {
+	ip := net.ParseIP(destination)
+	if ip == nil {
+		return nil, 0
+	}
+
+	var best *models.RouteEntry
+	bestLength := -1
+	for i := range routes {
+		route := &routes[i]
+		if route.Family != family || route.Table != table {
+			continue
+		}
+		network, length, ok := parseRoutePrefix(route.Destination)
+		if !ok || !network.Contains(ip) {
+			continue
+		}
+		if length > bestLength || (length == bestLength && route.Metric < best.Metric) {
+			best = route
+			bestLength = length
+		}
+	}
+	return best, bestLength
+}
+
+// kernelRouteGet 使用 ip route get 查询内核实际选择的路由
+func kernelRouteGet(family string, query RouteQuery) (*models.RouteEntry, error) {
+	args := []string{"-j", familyFlag(family), "route", "get", query.Destination}
+	if query.Source != "" {
+		args = append(args, "from", query.Source)
+	}
+	if query.IIf != "" {
+		args = append(args, "iif", query.IIf)
+	}
+	if query.OIf != "" {
+		args = append(args, "oif", query.OIf)
+	}
+	if query.Mark != 0 {
+		args = append(args, "mark", strconv.FormatUint(uint64(query.Mark), 10))
+	}
+	if query.UID >= 0 {
+		args = append(args, "uid", strconv.Itoa(query.UID))
+	}
+	if query.IPProto != "" {
+		args = append(args, "ipproto", query.IPProto)
+		if query.SourcePort != 0 {
+			args = append(args, "sport", strconv.Itoa(query.SourcePort))
+		}
+		if query.DestPort != 0 {
+			args = append(args, "dport", strconv.Itoa(query.DestPort))
+		}
+	}
+
+	output, err := runCommand("ip", args...)
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("ip route get failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("ip route get failed: %v", err)
+	}
+	routes, err := parseIPRoutes(family, output)
+	if err != nil {
+		return nil, err
+	}
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("ip route get returned no route")
+	}
+	return &routes[0], nil
+}
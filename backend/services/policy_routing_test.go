@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"iptables-management-backend/models"
+)
+
+const sampleIPRules = `[{"priority":0,"src":"all","table":"local"},{"priority":50,"src":"all","table":"main","suppress_prefixlen":0},{"priority":100,"src":"all","fwmark":"0x1","fwmask":"0xff","table":"100"},{"priority":200,"src":"10.1.0.0","srclen":24,"dst":"8.8.8.0","dstlen":24,"iif":"v0","table":"200"},{"priority":300,"not":null,"src":"10.9.0.0","srclen":16,"action":"prohibit"},{"priority":400,"src":"all","uid_start":1000,"uid_end":2000,"ipproto":"tcp","dport":443,"table":"300"},{"priority":500,"src":"all","fwmark":"0x2","goto":32766},{"priority":600,"src":"all","ipproto":"udp","sport_start":1000,"sport_end":2000,"table":"300"},{"priority":32766,"src":"all","table":"main"},{"priority":32767,"src":"all","table":"default"}]`
+
+const sampleIPRoutes = `[{"dst":"default","gateway":"10.1.0.254","dev":"v0","table":"100","flags":[]},{"type":"blackhole","dst":"8.8.8.0/24","table":"200","flags":[]},{"type":"throw","dst":"10.98.0.0/16","table":"300","flags":[]},{"dst":"10.1.0.0/24","dev":"v0","protocol":"kernel","scope":"link","prefsrc":"10.1.0.1","flags":[]},{"dst":"default","gateway":"10.1.0.1","dev":"v0","metric":100,"flags":[]},{"dst":"default","gateway":"10.1.0.2","dev":"v0","metric":50,"flags":[]},{"dst":"10.5.0.0/16","flags":[],"nexthops":[{"gateway":"10.1.0.2","dev":"v0","weight":1,"flags":[]},{"gateway":"10.1.0.3","dev":"v0","weight":2,"flags":[]}]},{"type":"local","dst":"10.1.0.1","dev":"v0","table":"local","protocol":"kernel","scope":"host","prefsrc":"10.1.0.1","flags":[]}]`
+
+func TestParseIPRules(t *testing.T) {
+	rules, err := parseIPRules("inet", []byte(sampleIPRules))
+	if err != nil {
+		t.Fatalf("parseIPRules returned error: %v", err)
+	}
+	if len(rules) != 10 {
+		t.Fatalf("expected 10 rules, got %d", len(rules))
+	}
+
+	tests := []struct {
+		index int
+		check func(rule models.RoutingRule) bool
+	}{
+		{1, func(r models.RoutingRule) bool {
+			return r.Action == "lookup" && r.Table == "main" && r.SuppressPrefixLength == 0
+		}},
+		{2, func(r models.RoutingRule) bool {
+			return r.FWMark == "0x1" && r.FWMask == "0xff" && r.SuppressPrefixLength == -1
+		}},
+		{3, func(r models.RoutingRule) bool {
+			return r.Source == "10.1.0.0/24" && r.Destination == "8.8.8.0/24" && r.IIf == "v0"
+		}},
+		{4, func(r models.RoutingRule) bool { return r.Not && r.Action == "prohibit" && r.Source == "10.9.0.0/16" }},
+		{5, func(r models.RoutingRule) bool {
+			return r.UIDRange == "1000-2000" && r.IPProto == "tcp" && r.DestPort == "443"
+		}},
+		{6, func(r models.RoutingRule) bool { return r.Action == "goto" && r.Goto == 32766 }},
+		{7, func(r models.RoutingRule) bool { return r.SourcePort == "1000-2000" }},
+	}
+	for _, tt := range tests {
+		if !tt.check(rules[tt.index]) {
+			t.Errorf("unexpected rule %d: %+v", tt.index, rules[tt.index])
+		}
+	}
+}
+
+func TestParseIPRoutes(t *testing.T) {
+	routes, err := parseIPRoutes("inet", []byte(sampleIPRoutes))
+	if err != nil {
+		t.Fatalf("parseIPRoutes returned error: %v", err)
+	}
+	if routes[0].Destination != "0.0.0.0/0" || routes[0].Table != "100" || routes[0].Type != "unicast" {
+		t.Errorf("unexpected default route: %+v", routes[0])
+	}
+	if routes[3].Table != "main" || routes[3].Protocol != "kernel" || routes[3].Scope != "link" || routes[3].Source != "10.1.0.1" {
+		t.Errorf("unexpected connected route: %+v", routes[3])
+	}
+	if len(routes[6].Nexthops) != 2 || routes[6].Nexthops[1].Gateway != "10.1.0.3" || routes[6].Nexthops[1].Weight != 2 {
+		t.Errorf("unexpected multipath route: %+v", routes[6])
+	}
+
+	ipv6, err := parseIPRoutes("inet6", []byte(`[{"dst":"default","gateway":"fd00::1","dev":"eth0","metric":1024,"flags":[],"pref":"medium"}]`))
+	if err != nil || ipv6[0].Destination != "::/0" || ipv6[0].Family != "inet6" {
+		t.Errorf("unexpected ipv6 route: %+v (%v)", ipv6, err)
+	}
+}
+
+func TestResolveRoute(t *testing.T) {
+	rules, _ := parseIPRules("inet", []byte(sampleIPRules))
+	routes, _ := parseIPRoutes("inet", []byte(sampleIPRoutes))
+	// 使用 10.9.0.0/16 内的源地址以避开 "not from 10.9.0.0/16 prohibit" 规则
+	const allowed = "10.9.0.5"
+
+	tests := []struct {
+		name         string
+		query        RouteQuery
+		wantResult   string
+		wantPriority int
+		wantGateway  string
+	}{
+		{"local address", RouteQuery{Destination: "10.1.0.1", UID: -1}, "route", 0, ""},
+		{"suppressed default falls to prohibit", RouteQuery{Destination: "1.1.1.1", UID: -1}, "prohibit", 300, ""},
+		{"suppress keeps specific route", RouteQuery{Destination: "10.5.1.1", UID: -1}, "route", 50, ""},
+		{"fwmark with mask", RouteQuery{Destination: "1.1.1.1", Source: allowed, Mark: 0x301, UID: -1}, "route", 100, "10.1.0.254"},
+		{"source, destination and iif", RouteQuery{Destination: "8.8.8.8", Source: "10.1.0.5", IIf: "v0", UID: -1}, "blackhole", 200, ""},
+		{"iif does not match locally generated", RouteQuery{Destination: "8.8.8.8", Source: allowed, UID: -1}, "route", 32766, "10.1.0.2"},
+		{"uid range table without route falls through", RouteQuery{Destination: "8.8.8.8", Source: allowed, UID: 1500, IPProto: "tcp", DestPort: 443}, "route", 32766, "10.1.0.2"},
+		{"goto skips to main", RouteQuery{Destination: "8.8.4.4", Source: allowed, Mark: 2, UID: -1}, "route", 32766, "10.1.0.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolution := resolveRoute(rules, routes, tt.query)
+			if resolution.Result != tt.wantResult {
+				t.Fatalf("result = %s, want %s (steps %+v)", resolution.Result, tt.wantResult, resolution.Steps)
+			}
+			priority := -1
+			if resolution.Rule != nil {
+				priority = resolution.Rule.Priority
+			}
+			if priority != tt.wantPriority {
+				t.Errorf("rule priority = %d, want %d", priority, tt.wantPriority)
+			}
+			if tt.wantGateway != "" && (resolution.Route == nil || resolution.Route.Gateway != tt.wantGateway) {
+				t.Errorf("route = %+v, want gateway %s", resolution.Route, tt.wantGateway)
+			}
+		})
+	}
+
+	throw := resolveRoute(rules, routes, RouteQuery{Destination: "10.98.1.1", Source: allowed, UID: 1500, IPProto: "tcp", DestPort: 443})
+	thrown := false
+	for _, step := range throw.Steps {
+		if step.Rule.Priority == 400 && step.Result == "throw" {
+			thrown = true
+		}
+	}
+	if !thrown || throw.Rule == nil || throw.Rule.Priority != 32766 {
+		t.Errorf("expected throw in table 300 and fallback to main, got %+v", throw)
+	}
+}
+
+func TestRouteQueryValidationErrors(t *testing.T) {
+	service := NewNetworkService()
+	var validation *ValidationError
+
+	if _, err := routeFamilies("ipx"); !errors.As(err, &validation) {
+		t.Errorf("routeFamilies: expected validation error, got %v", err)
+	}
+	if _, err := service.ResolveRoute(RouteQuery{Destination: "not-an-ip", UID: -1}); !errors.As(err, &validation) {
+		t.Errorf("ResolveRoute destination: expected validation error, got %v", err)
+	}
+	if _, err := service.ResolveRoute(RouteQuery{Destination: "192.0.2.1", Source: "bogus", UID: -1}); !errors.As(err, &validation) {
+		t.Errorf("ResolveRoute source: expected validation error, got %v", err)
+	}
+}
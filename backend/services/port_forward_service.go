@@ -0,0 +1,530 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"iptables-management-backend/config"
+	"iptables-management-backend/models"
+)
+
+// portForwardGroupPrefix 端口转发对应的托管规则组名称前缀
+const portForwardGroupPrefix = "port-forward:"
+
+// portForwardNamePattern 端口转发名称
+var portForwardNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]{1,60}$`)
+
+// PortForwardService 端口转发（DNAT）服务，每个转发的全部规则作为一个托管规则组应用和删除
+type PortForwardService struct {
+	dockerUserService *DockerUserService
+}
+
+// PortForwardStatus 端口转发及其规则在系统中的存在状态
+type PortForwardStatus struct {
+	models.PortForward
+	Rules    []ManagedRuleStatus `json:"rules"`
+	Missing  int                 `json:"missing"`
+	Warnings []string            `json:"warnings,omitempty"`
+}
+
+// NewPortForwardService 创建端口转发服务实例
+func NewPortForwardService(dockerUserService *DockerUserService) *PortForwardService {
+	return &PortForwardService{
+		dockerUserService: dockerUserService,
+	}
+}
+
+// ListForwards 获取所有端口转发及规则状态
+func (s *PortForwardService) ListForwards() ([]PortForwardStatus, error) {
+	var forwards []models.PortForward
+	if err := config.DB.Order("id ASC").Find(&forwards).Error; err != nil {
+		return nil, fmt.Errorf("failed to list port forwards: %v", err)
+	}
+
+	groups, err := s.groupStatuses()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]PortForwardStatus, 0, len(forwards))
+	for _, forward := range forwards {
+		statuses = append(statuses, portForwardStatus(forward, groups))
+	}
+	return statuses, nil
+}
+
+// GetForward 获取单个端口转发及规则状态
+func (s *PortForwardService) GetForward(id uint) (*PortForwardStatus, error) {
+	var forward models.PortForward
+	if err := config.DB.First(&forward, id).Error; err != nil {
+		return nil, err
+	}
+	groups, err := s.groupStatuses()
+	if err != nil {
+		return nil, err
+	}
+	status := portForwardStatus(forward, groups)
+	return &status, nil
+}
+
+// PreviewForward 校验端口转发并返回将要应用的iptables命令，不修改系统
+func (s *PortForwardService) PreviewForward(forward models.PortForward) ([]string, error) {
+	rules, err := s.prepare(&forward, 0)
+	if err != nil {
+		return nil, err
+	}
+	return managedGroupCommands(forward.GroupName, rules), nil
+}
+
+// CreateForward 创建端口转发并立即应用其全部规则；应用失败时撤销
+func (s *PortForwardService) CreateForward(forward *models.PortForward, username string) (*PortForwardStatus, error) {
+	forward.ID = 0
+	forward.CreatedBy = username
+	rules, err := s.prepare(forward, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.DB.Create(forward).Error; err != nil {
+		return nil, fmt.Errorf("failed to save port forward: %v", err)
+	}
+	if err := s.applyGroup(forward, rules, username); err != nil {
+		s.removeGroup(forward.GroupName)
+		config.DB.Delete(&models.PortForward{}, forward.ID)
+		return nil, err
+	}
+
+	log.Printf("[INFO] Created port forward %s: %s", forward.Name, describePortForward(*forward))
+	return s.GetForward(forward.ID)
+}
+
+// UpdateForward 修改端口转发：先移除旧规则组，再按新配置应用；失败时恢复原配置和原规则组
+func (s *PortForwardService) UpdateForward(id uint, forward *models.PortForward, username string) (*PortForwardStatus, error) {
+	var existing models.PortForward
+	if err := config.DB.First(&existing, id).Error; err != nil {
+		return nil, err
+	}
+
+	forward.ID = existing.ID
+	forward.CreatedBy = existing.CreatedBy
+	forward.CreatedAt = existing.CreatedAt
+	rules, err := s.prepare(forward, existing.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing.GroupName != forward.GroupName {
+		s.removeGroup(existing.GroupName)
+	}
+	if err := config.DB.Save(forward).Error; err != nil {
+		s.restoreForward(&existing, forward, username)
+		return nil, fmt.Errorf("failed to save port forward: %v", err)
+	}
+	if err := s.applyGroup(forward, rules, username); err != nil {
+		s.restoreForward(&existing, forward, username)
+		return nil, err
+	}
+
+	log.Printf("[INFO] Updated port forward %s: %s", forward.Name, describePortForward(*forward))
+	return s.GetForward(forward.ID)
+}
+
+// restoreForward 修改失败后恢复原记录，删除新名称的规则组并重新应用原规则组
+func (s *PortForwardService) restoreForward(existing, failed *models.PortForward, username string) {
+	if failed.GroupName != existing.GroupName {
+		s.removeGroup(failed.GroupName)
+	}
+	if err := config.DB.Save(existing).Error; err != nil {
+		log.Printf("[ERROR] Failed to restore port forward %s: %v", existing.Name, err)
+	}
+
+	rules, err := buildPortForwardRules(*existing)
+	if err == nil {
+		err = s.applyGroup(existing, rules, username)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to restore rules of port forward %s: %v", existing.Name, err)
+		return
+	}
+	log.Printf("[INFO] Restored port forward %s after failed update", existing.Name)
+}
+
+// DeleteForward 删除端口转发及其全部规则
+func (s *PortForwardService) DeleteForward(id uint) (*models.PortForward, error) {
+	var forward models.PortForward
+	if err := config.DB.First(&forward, id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.removeGroup(forward.GroupName); err != nil {
+		return nil, err
+	}
+	if err := config.DB.Delete(&forward).Error; err != nil {
+		return nil, fmt.Errorf("failed to delete port forward: %v", err)
+	}
+	return &forward, nil
+}
+
+// prepare 规范化并校验端口转发，检查与其他转发的冲突，生成规则
+func (s *PortForwardService) prepare(forward *models.PortForward, selfID uint) ([]models.ManagedRule, error) {
+	if err := normalizePortForward(forward); err != nil {
+		return nil, err
+	}
+	forward.GroupName = portForwardGroupPrefix + forward.Name
+
+	var others []models.PortForward
+	if err := config.DB.Where("id <> ?", selfID).Find(&others).Error; err != nil {
+		return nil, fmt.Errorf("failed to load port forwards: %v", err)
+	}
+	for _, other := range others {
+		if other.Name == forward.Name {
+			return nil, conflictf("port forward %s already exists", forward.Name)
+		}
+		if other.Enabled && forward.Enabled && portForwardsConflict(*forward, other) {
+			return nil, conflictf("port conflict with port forward %s (%s)", other.Name, describePortForward(other))
+		}
+	}
+
+	if forward.Hairpin && forward.HairpinSubnet == "" {
+		subnet, err := connectedSubnet(net.ParseIP(forward.InternalIP))
+		if err != nil {
+			return nil, err
+		}
+		forward.HairpinSubnet = subnet
+	}
+
+	return buildPortForwardRules(*forward)
+}
+
+// applyGroup 保存并应用端口转发对应的托管规则组
+func (s *PortForwardService) applyGroup(forward *models.PortForward, rules []models.ManagedRule, username string) error {
+	group := &models.ManagedRuleGroup{
+		Name:        forward.GroupName,
+		Description: fmt.Sprintf("端口转发 %s: %s", forward.Name, describePortForward(*forward)),
+		Source:      "port_forward",
+		Enabled:     forward.Enabled,
+		CreatedBy:   username,
+		Rules:       rules,
+	}
+	result, err := s.dockerUserService.SaveGroup(group)
+	if err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("failed to apply port forward rules: %s", strings.Join(result.Errors, "; "))
+	}
+	return nil
+}
+
+// removeGroup 删除托管规则组（同时从系统中移除规则）；组不存在时忽略
+func (s *PortForwardService) removeGroup(name string) error {
+	return s.dockerUserService.deleteGroupByName(name)
+}
+
+// groupStatuses 按名称索引端口转发对应的托管规则组状态
+func (s *PortForwardService) groupStatuses() (map[string]ManagedGroupStatus, error) {
+	statuses, err := s.dockerUserService.GetStatus()
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[string]ManagedGroupStatus)
+	for _, status := range statuses {
+		if strings.HasPrefix(status.Name, portForwardGroupPrefix) {
+			groups[status.Name] = status
+		}
+	}
+	return groups, nil
+}
+
+// portForwardStatus 合并端口转发和规则组状态，并给出配置提示
+func portForwardStatus(forward models.PortForward, groups map[string]ManagedGroupStatus) PortForwardStatus {
+	status := PortForwardStatus{PortForward: forward, Rules: []ManagedRuleStatus{}}
+	if group, ok := groups[forward.GroupName]; ok {
+		status.Rules = group.Rules
+		status.Missing = group.Missing
+	}
+	if forward.Enabled && !ipForwardEnabled() {
+		status.Warnings = append(status.Warnings, "net.ipv4.ip_forward 未开启，转发到其他主机的流量不会被转发")
+	}
+	return status
+}
+
+// normalizePortForward 校验端口转发配置并规范化地址和端口
+func normalizePortForward(forward *models.PortForward) error {
+	if !portForwardNamePattern.MatchString(forward.Name) {
+		return invalidf("invalid port forward name %q", forward.Name)
+	}
+	forward.Protocol = strings.ToLower(forward.Protocol)
+	if forward.Protocol == "" {
+		forward.Protocol = "tcp"
+	}
+	if len(portForwardProtocols(forward.Protocol)) == 0 {
+		return invalidf("invalid protocol %q", forward.Protocol)
+	}
+
+	if forward.ExternalPortStart < 1 || forward.ExternalPortStart > 65535 {
+		return invalidf("invalid external port %d", forward.ExternalPortStart)
+	}
+	if forward.ExternalPortEnd == forward.ExternalPortStart {
+		forward.ExternalPortEnd = 0
+	}
+	if forward.ExternalPortEnd != 0 && (forward.ExternalPortEnd < forward.ExternalPortStart || forward.ExternalPortEnd > 65535) {
+		return invalidf("invalid external port range %d-%d", forward.ExternalPortStart, forward.ExternalPortEnd)
+	}
+	if forward.InternalPort < 0 || forward.InternalPort > 65535 {
+		return invalidf("invalid internal port %d", forward.InternalPort)
+	}
+	if forward.InternalPort == forward.ExternalPortStart {
+		forward.InternalPort = 0
+	}
+	// DNAT 到端口范围时内核为每个连接任选一个端口，无法一一映射，因此范围转发只支持保持端口不变
+	if forward.ExternalPortEnd != 0 && forward.InternalPort != 0 {
+		return invalidf("port range forwards must keep the same internal ports")
+	}
+
+	// 接口名会拼接进规则参数，只允许内核接受的字符，防止带入额外的 iptables 参数
+	if forward.ExternalInterface != "" && !isValidInterfaceName(forward.ExternalInterface) {
+		return invalidf("invalid external interface %q", forward.ExternalInterface)
+	}
+
+	internal := net.ParseIP(forward.InternalIP)
+	if internal == nil || internal.To4() == nil {
+		return invalidf("invalid internal IPv4 address %q", forward.InternalIP)
+	}
+	forward.InternalIP = internal.String()
+	if forward.ExternalIP != "" {
+		external := net.ParseIP(forward.ExternalIP)
+		if external == nil || external.To4() == nil {
+			return invalidf("invalid external IPv4 address %q", forward.ExternalIP)
+		}
+		forward.ExternalIP = external.String()
+	}
+
+	sources, err := normalizeSourceList(forward.AllowedSources)
+	if err != nil {
+		return err
+	}
+	forward.AllowedSources = strings.Join(sources, ",")
+
+	if forward.HairpinSubnet != "" {
+		_, subnet, err := net.ParseCIDR(forward.HairpinSubnet)
+		if err != nil || subnet.IP.To4() == nil {
+			return invalidf("invalid hairpin subnet %q", forward.HairpinSubnet)
+		}
+		forward.HairpinSubnet = subnet.String()
+	}
+	if !forward.Hairpin {
+		forward.HairpinSubnet = ""
+	}
+	return nil
+}
+
+// normalizeSourceList 解析逗号或空白分隔的来源地址列表
+func normalizeSourceList(value string) ([]string, error) {
+	var sources []string
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\t' }) {
+		if strings.Contains(item, "/") {
+			_, network, err := net.ParseCIDR(item)
+			if err != nil || network.IP.To4() == nil {
+				return nil, invalidf("invalid source %q", item)
+			}
+			sources = append(sources, network.String())
+			continue
+		}
+		ip := net.ParseIP(item)
+		if ip == nil || ip.To4() == nil {
+			return nil, invalidf("invalid source %q", item)
+		}
+		sources = append(sources, ip.String())
+	}
+	return sources, nil
+}
+
+// portForwardProtocols 展开协议
+func portForwardProtocols(protocol string) []string {
+	switch protocol {
+	case "tcp", "udp":
+		return []string{protocol}
+	case "tcp+udp", "both":
+		return []string{"tcp", "udp"}
+	}
+	return nil
+}
+
+// buildPortForwardRules 生成端口转发的完整规则集（按协议展开，每个允许的来源一条）：
+//   - nat PREROUTING DNAT：外部接口/地址上的端口转到内部地址
+//   - nat OUTPUT DNAT（local_traffic）：本机访问外部地址时同样转发
+//   - filter FORWARD：放行经DNAT的新连接及其回程
+//   - nat PREROUTING DNAT + POSTROUTING MASQUERADE（hairpin）：内网客户端经外部地址访问时回流
+func buildPortForwardRules(forward models.PortForward) ([]models.ManagedRule, error) {
+	if forward.Hairpin && forward.HairpinSubnet == "" {
+		return nil, invalidf("hairpin requires a subnet")
+	}
+
+	externalPorts := strconv.Itoa(forward.ExternalPortStart)
+	internalPorts := externalPorts
+	destination := forward.InternalIP
+	if forward.ExternalPortEnd != 0 {
+		externalPorts += ":" + strconv.Itoa(forward.ExternalPortEnd)
+		internalPorts = externalPorts
+	} else if forward.InternalPort != 0 {
+		internalPorts = strconv.Itoa(forward.InternalPort)
+		destination += ":" + internalPorts
+	} else {
+		destination += ":" + externalPorts
+	}
+
+	// 未指定外部地址时只匹配发往本机地址的流量，避免误转发经过本机的流量
+	localDst := "-m addrtype --dst-type LOCAL"
+	if forward.ExternalIP != "" {
+		localDst = "-d " + forward.ExternalIP
+	}
+	inInterface := ""
+	if forward.ExternalInterface != "" {
+		inInterface = "-i " + forward.ExternalInterface + " "
+	}
+	sources, _ := normalizeSourceList(forward.AllowedSources)
+	sourceMatches := []string{""}
+	if len(sources) > 0 {
+		sourceMatches = nil
+		for _, source := range sources {
+			sourceMatches = append(sourceMatches, "-s "+source+" ")
+		}
+	}
+
+	var rules []models.ManagedRule
+	add := func(table, chain, spec string) {
+		rules = append(rules, models.ManagedRule{Table: table, Chain: chain, Spec: spec})
+	}
+
+	for _, protocol := range portForwardProtocols(forward.Protocol) {
+		match := fmt.Sprintf("-p %s --dport %s", protocol, externalPorts)
+		internalMatch := fmt.Sprintf("-d %s -p %s --dport %s", forward.InternalIP, protocol, internalPorts)
+
+		for _, source := range sourceMatches {
+			add("nat", "PREROUTING", fmt.Sprintf("%s%s%s %s -j DNAT --to-destination %s", inInterface, source, localDst, match, destination))
+		}
+		if forward.LocalTraffic {
+			outputDst := localDst
+			if forward.ExternalIP == "" {
+				outputDst = "! -d 127.0.0.0/8 " + localDst
+			}
+			add("nat", "OUTPUT", fmt.Sprintf("%s %s -j DNAT --to-destination %s", outputDst, match, destination))
+		}
+		for _, source := range sourceMatches {
+			add("filter", "FORWARD", fmt.Sprintf("%s%s%s -m conntrack --ctstate DNAT -j ACCEPT", inInterface, source, internalMatch))
+		}
+		add("filter", "FORWARD", fmt.Sprintf("-s %s -p %s --sport %s -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT", forward.InternalIP, protocol, internalPorts))
+
+		if forward.Hairpin {
+			// 外部接口或来源受限时，内网客户端不会命中上面的DNAT规则，需要单独的规则
+			if inInterface != "" || len(sources) > 0 {
+				add("nat", "PREROUTING", fmt.Sprintf("-s %s %s %s -j DNAT --to-destination %s", forward.HairpinSubnet, localDst, match, destination))
+				add("filter", "FORWARD", fmt.Sprintf("-s %s %s -m conntrack --ctstate DNAT -j ACCEPT", forward.HairpinSubnet, internalMatch))
+			}
+			add("nat", "POSTROUTING", fmt.Sprintf("-s %s %s -m conntrack --ctstate DNAT -j MASQUERADE", forward.HairpinSubnet, internalMatch))
+		}
+	}
+
+	for i := range rules {
+		rules[i].Position = i + 1
+	}
+	return rules, nil
+}
+
+// managedGroupCommands 生成托管规则组中各规则对应的iptables命令
+func managedGroupCommands(groupName string, rules []models.ManagedRule) []string {
+	commands := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		commands = append(commands, managedRuleCommand("-A", rule.Table, rule.Chain, 0, managedRuleArgs(groupName, rule)))
+	}
+	return commands
+}
+
+// portForwardsConflict 判断两个端口转发是否会匹配同一外部流量
+func portForwardsConflict(a, b models.PortForward) bool {
+	protocolOverlap := false
+	for _, pa := range portForwardProtocols(a.Protocol) {
+		for _, pb := range portForwardProtocols(b.Protocol) {
+			if pa == pb {
+				protocolOverlap = true
+			}
+		}
+	}
+	if !protocolOverlap {
+		return false
+	}
+
+	aEnd, bEnd := a.ExternalPortEnd, b.ExternalPortEnd
+	if aEnd == 0 {
+		aEnd = a.ExternalPortStart
+	}
+	if bEnd == 0 {
+		bEnd = b.ExternalPortStart
+	}
+	if a.ExternalPortStart > bEnd || b.ExternalPortStart > aEnd {
+		return false
+	}
+
+	if a.ExternalIP != "" && b.ExternalIP != "" && a.ExternalIP != b.ExternalIP {
+		return false
+	}
+	if a.ExternalInterface != "" && b.ExternalInterface != "" && a.ExternalInterface != b.ExternalInterface {
+		return false
+	}
+	return true
+}
+
+// describePortForward 端口转发的简短描述，如 "tcp eth0:8080 -> 10.0.0.5:80"
+func describePortForward(forward models.PortForward) string {
+	external := forward.ExternalIP
+	if external == "" {
+		external = "*"
+	}
+	if forward.ExternalInterface != "" {
+		external = forward.ExternalInterface + "/" + external
+	}
+	ports := strconv.Itoa(forward.ExternalPortStart)
+	if forward.ExternalPortEnd != 0 {
+		ports += "-" + strconv.Itoa(forward.ExternalPortEnd)
+	}
+	internalPort := ports
+	if forward.InternalPort != 0 {
+		internalPort = strconv.Itoa(forward.InternalPort)
+	}
+	return fmt.Sprintf("%s %s:%s -> %s:%s", forward.Protocol, external, ports, forward.InternalIP, internalPort)
+}
+
+// connectedSubnet 查找包含指定地址的本机直连网段，用于回流 SNAT
+func connectedSubnet(ip net.IP) (string, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return "", fmt.Errorf("failed to list interfaces: %v", err)
+	}
+	for _, iface := range interfaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if network, ok := addr.(*net.IPNet); ok && network.IP.To4() != nil && network.Contains(ip) {
+				_, subnet, _ := net.ParseCIDR(network.String())
+				return subnet.String(), nil
+			}
+		}
+	}
+	return "", invalidf("internal address %s is not on a directly connected subnet, set hairpin_subnet explicitly", ip)
+}
+
+// ipForwardEnabled 检查内核是否开启IPv4转发
+func ipForwardEnabled() bool {
+	data, err := os.ReadFile("/proc/sys/net/ipv4/ip_forward")
+	if err != nil {
+		return true
+	}
+	return strings.TrimSpace(string(data)) == "1"
+}
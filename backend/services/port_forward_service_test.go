@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"iptables-management-backend/models"
+)
+
+func TestNormalizePortForward(t *testing.T) {
+	forward := models.PortForward{
+		Name: "web", Protocol: "TCP", ExternalPortStart: 8080, ExternalPortEnd: 8080,
+		InternalIP: "10.0.0.5", InternalPort: 80, AllowedSources: "203.0.113.0/24, 198.51.100.7\n192.0.2.9/16",
+		HairpinSubnet: "10.0.0.9/24",
+	}
+	if err := normalizePortForward(&forward); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if forward.Protocol != "tcp" || forward.ExternalPortEnd != 0 || forward.InternalPort != 80 {
+		t.Errorf("unexpected normalized ports: %+v", forward)
+	}
+	if forward.AllowedSources != "203.0.113.0/24,198.51.100.7,192.0.0.0/16" {
+		t.Errorf("unexpected sources: %s", forward.AllowedSources)
+	}
+	if forward.HairpinSubnet != "" {
+		t.Errorf("hairpin subnet should be cleared when hairpin is disabled: %s", forward.HairpinSubnet)
+	}
+
+	invalid := []models.PortForward{
+		{Name: "bad name", ExternalPortStart: 80, InternalIP: "10.0.0.5"},
+		{Name: "p", Protocol: "icmp", ExternalPortStart: 80, InternalIP: "10.0.0.5"},
+		{Name: "p", ExternalPortStart: 0, InternalIP: "10.0.0.5"},
+		{Name: "p", ExternalPortStart: 100, ExternalPortEnd: 90, InternalIP: "10.0.0.5"},
+		{Name: "p", ExternalPortStart: 100, ExternalPortEnd: 110, InternalPort: 200, InternalIP: "10.0.0.5"},
+		{Name: "p", ExternalPortStart: 80, InternalIP: "fd00::5"},
+		{Name: "p", ExternalPortStart: 80, InternalIP: "10.0.0.5", ExternalIP: "eth0"},
+		{Name: "p", ExternalPortStart: 80, InternalIP: "10.0.0.5", AllowedSources: "10.0.0.0/33"},
+		{Name: "p", ExternalPortStart: 80, InternalIP: "10.0.0.5", ExternalInterface: "eth0 -j ACCEPT"},
+		{Name: "p", ExternalPortStart: 80, InternalIP: "10.0.0.5", ExternalInterface: "averyverylongname0"},
+	}
+	for _, forward := range invalid {
+		var validation *ValidationError
+		if err := normalizePortForward(&forward); !errors.As(err, &validation) {
+			t.Errorf("expected validation error for %+v, got %v", forward, err)
+		}
+	}
+}
+
+func TestBuildPortForwardRules(t *testing.T) {
+	forward := models.PortForward{
+		Name: "web", Protocol: "tcp", ExternalInterface: "eth0", ExternalPortStart: 8080,
+		InternalIP: "10.0.0.5", InternalPort: 80, AllowedSources: "203.0.113.0/24",
+		LocalTraffic: true, Hairpin: true, HairpinSubnet: "10.0.0.0/24",
+	}
+	rules, err := buildPortForwardRules(forward)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []string
+	for i, rule := range rules {
+		if rule.Position != i+1 {
+			t.Errorf("rule %d has position %d", i, rule.Position)
+		}
+		got = append(got, rule.Table+" "+rule.Chain+" "+rule.Spec)
+	}
+	want := []string{
+		"nat PREROUTING -i eth0 -s 203.0.113.0/24 -m addrtype --dst-type LOCAL -p tcp --dport 8080 -j DNAT --to-destination 10.0.0.5:80",
+		"nat OUTPUT ! -d 127.0.0.0/8 -m addrtype --dst-type LOCAL -p tcp --dport 8080 -j DNAT --to-destination 10.0.0.5:80",
+		"filter FORWARD -i eth0 -s 203.0.113.0/24 -d 10.0.0.5 -p tcp --dport 80 -m conntrack --ctstate DNAT -j ACCEPT",
+		"filter FORWARD -s 10.0.0.5 -p tcp --sport 80 -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT",
+		"nat PREROUTING -s 10.0.0.0/24 -m addrtype --dst-type LOCAL -p tcp --dport 8080 -j DNAT --to-destination 10.0.0.5:80",
+		"filter FORWARD -s 10.0.0.0/24 -d 10.0.0.5 -p tcp --dport 80 -m conntrack --ctstate DNAT -j ACCEPT",
+		"nat POSTROUTING -s 10.0.0.0/24 -d 10.0.0.5 -p tcp --dport 80 -m conntrack --ctstate DNAT -j MASQUERADE",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected rules:\n got: %q\nwant: %q", got, want)
+	}
+
+	// 端口范围、两种协议、指定外部地址
+	rangeForward := models.PortForward{
+		Name: "rtp", Protocol: "tcp+udp", ExternalIP: "192.0.2.1", ExternalPortStart: 10000, ExternalPortEnd: 10010, InternalIP: "10.0.0.6",
+	}
+	rules, err = buildPortForwardRules(rangeForward)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rules) != 6 {
+		t.Fatalf("expected 6 rules, got %d", len(rules))
+	}
+	if rules[0].Spec != "-d 192.0.2.1 -p tcp --dport 10000:10010 -j DNAT --to-destination 10.0.0.6" {
+		t.Errorf("unexpected range DNAT: %s", rules[0].Spec)
+	}
+	if rules[4].Spec != "-d 10.0.0.6 -p udp --dport 10000:10010 -m conntrack --ctstate DNAT -j ACCEPT" {
+		t.Errorf("unexpected udp FORWARD: %s", rules[4].Spec)
+	}
+
+	if _, err := buildPortForwardRules(models.PortForward{Hairpin: true}); err == nil {
+		t.Error("expected error for hairpin without subnet")
+	}
+}
+
+func TestPortForwardsConflict(t *testing.T) {
+	base := models.PortForward{Protocol: "tcp", ExternalPortStart: 8000, ExternalPortEnd: 8010}
+	tests := []struct {
+		name  string
+		other models.PortForward
+		want  bool
+	}{
+		{"overlapping range", models.PortForward{Protocol: "tcp", ExternalPortStart: 8010}, true},
+		{"adjacent port", models.PortForward{Protocol: "tcp", ExternalPortStart: 8011}, false},
+		{"other protocol", models.PortForward{Protocol: "udp", ExternalPortStart: 8005}, false},
+		{"both protocols", models.PortForward{Protocol: "tcp+udp", ExternalPortStart: 8005}, true},
+		{"specific address vs any", models.PortForward{Protocol: "tcp", ExternalIP: "192.0.2.1", ExternalPortStart: 8005}, true},
+		{"other interface", models.PortForward{Protocol: "tcp", ExternalInterface: "eth1", ExternalPortStart: 8005}, true},
+	}
+	for _, tt := range tests {
+		if got := portForwardsConflict(base, tt.other); got != tt.want {
+			t.Errorf("%s: conflict = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	a := models.PortForward{Protocol: "tcp", ExternalInterface: "eth0", ExternalPortStart: 80}
+	b := models.PortForward{Protocol: "tcp", ExternalInterface: "eth1", ExternalPortStart: 80}
+	if portForwardsConflict(a, b) {
+		t.Error("forwards on different interfaces should not conflict")
+	}
+}
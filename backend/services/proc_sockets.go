@@ -0,0 +1,303 @@
+package services
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"iptables-management-backend/models"
+)
+
+// tcpStates /proc/net/tcp 中 st 字段（十六进制）对应的状态
+var tcpStates = map[string]string{
+	"01": "ESTABLISHED",
+	"02": "SYN_SENT",
+	"03": "SYN_RECV",
+	"04": "FIN_WAIT1",
+	"05": "FIN_WAIT2",
+	"06": "TIME_WAIT",
+	"07": "CLOSE",
+	"08": "CLOSE_WAIT",
+	"09": "LAST_ACK",
+	"0A": "LISTEN",
+	"0B": "CLOSING",
+	"0C": "NEW_SYN_RECV",
+}
+
+// unixSocketTypes /proc/net/unix 中 Type 字段对应的套接字类型
+var unixSocketTypes = map[string]string{
+	"0001": "stream",
+	"0002": "dgram",
+	"0005": "seqpacket",
+}
+
+// unixSocketStates /proc/net/unix 中 St 字段对应的状态
+var unixSocketStates = map[string]string{
+	"01": "UNCONNECTED",
+	"02": "CONNECTING",
+	"03": "CONNECTED",
+	"04": "DISCONNECTING",
+}
+
+// unixAcceptCon __SO_ACCEPTCON 标志，表示监听中的unix套接字
+const unixAcceptCon = 0x10000
+
+// ConnectionFilter 套接字查询条件
+type ConnectionFilter struct {
+	Protocol  string // tcp, tcp6, udp, udp6, unix；tcp 同时匹配 tcp6
+	State     string
+	Port      int // 匹配本地或远端端口
+	Address   string
+	PID       int
+	Process   string
+	Container string // 容器ID前缀
+	NetNS     string // host 或 net:[inode]
+	Listening bool   // 仅监听中的套接字（TCP LISTEN、未连接的UDP、监听中的unix套接字）
+}
+
+// socketOwner 持有套接字的进程
+type socketOwner struct {
+	pid     int
+	process string
+}
+
+// parseProcNetSockets 解析 /proc/net/{tcp,tcp6,udp,udp6} 的内容
+func parseProcNetSockets(protocol, content string) []models.NetworkConnection {
+	var connections []models.NetworkConnection
+	isUDP := strings.HasPrefix(protocol, "udp")
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode ...
+		if len(fields) < 10 || fields[0] == "sl" {
+			continue
+		}
+
+		localIP, localPort, err := parseProcSocketAddress(fields[1])
+		if err != nil {
+			continue
+		}
+		remoteIP, remotePort, err := parseProcSocketAddress(fields[2])
+		if err != nil {
+			continue
+		}
+
+		state := tcpStates[strings.ToUpper(fields[3])]
+		if isUDP {
+			// UDP只有已连接(01)和未连接(07)两种有意义的状态
+			if fields[3] == "01" {
+				state = "ESTABLISHED"
+			} else {
+				state = "UNCONN"
+			}
+		}
+
+		queues := strings.SplitN(fields[4], ":", 2)
+		connection := models.NetworkConnection{
+			Protocol:       protocol,
+			LocalAddress:   net.JoinHostPort(localIP, strconv.Itoa(localPort)),
+			ForeignAddress: net.JoinHostPort(remoteIP, strconv.Itoa(remotePort)),
+			State:          state,
+			LocalIP:        localIP,
+			LocalPort:      localPort,
+			RemoteIP:       remoteIP,
+			RemotePort:     remotePort,
+		}
+		if len(queues) == 2 {
+			txQueue, _ := strconv.ParseUint(queues[0], 16, 64)
+			rxQueue, _ := strconv.ParseUint(queues[1], 16, 64)
+			connection.TxQueue = txQueue
+			connection.RxQueue = rxQueue
+		}
+		connection.UID, _ = strconv.Atoi(fields[7])
+		connection.Inode, _ = strconv.ParseUint(fields[9], 10, 64)
+		connections = append(connections, connection)
+	}
+	return connections
+}
+
+// parseProcNetUnix 解析 /proc/net/unix 的内容
+func parseProcNetUnix(content string) []models.NetworkConnection {
+	var connections []models.NetworkConnection
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Num RefCount Protocol Flags Type St Inode [Path]
+		if len(fields) < 7 || fields[0] == "Num" {
+			continue
+		}
+
+		flags, _ := strconv.ParseUint(fields[3], 16, 32)
+		state := unixSocketStates[fields[5]]
+		if flags&unixAcceptCon != 0 {
+			state = "LISTEN"
+		}
+
+		connection := models.NetworkConnection{
+			Protocol:   "unix",
+			State:      state,
+			SocketType: unixSocketTypes[fields[4]],
+		}
+		connection.Inode, _ = strconv.ParseUint(fields[6], 10, 64)
+		if len(fields) >= 8 {
+			connection.Path = fields[7]
+			connection.LocalAddress = fields[7]
+		}
+		connections = append(connections, connection)
+	}
+	return connections
+}
+
+// parseProcSocketAddress 解析 "0100007F:0016" 形式的地址（IP按32位字的本机字节序存储）
+func parseProcSocketAddress(value string) (string, int, error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return "", 0, fmt.Errorf("invalid socket address: %s", value)
+	}
+	raw, err := hex.DecodeString(parts[0])
+	if err != nil || (len(raw) != 4 && len(raw) != 16) {
+		return "", 0, fmt.Errorf("invalid socket address: %s", value)
+	}
+	port, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid socket port: %s", value)
+	}
+
+	ip := make(net.IP, len(raw))
+	for word := 0; word < len(raw); word += 4 {
+		nativeEndian.PutUint32(ip[word:word+4], bigEndianUint32(raw[word:word+4]))
+	}
+	// tcp6 中的 ::ffff:a.b.c.d 由 String() 输出为IPv4形式
+	return ip.String(), int(port), nil
+}
+
+// bigEndianUint32 按文本中的字节顺序读取32位字
+func bigEndianUint32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+// socketOwners 扫描 /proc/*/fd 建立套接字inode到进程的映射
+func socketOwners() map[uint64]socketOwner {
+	owners := make(map[uint64]socketOwner)
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return owners
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		fdDir := fmt.Sprintf("/proc/%d/fd", pid)
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+
+		var process string
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(target, "socket:[") {
+				continue
+			}
+			inode, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(target, "socket:["), "]"), 10, 64)
+			if err != nil {
+				continue
+			}
+			if _, exists := owners[inode]; exists {
+				continue
+			}
+			if process == "" {
+				if comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid)); err == nil {
+					process = strings.TrimSpace(string(comm))
+				}
+			}
+			owners[inode] = socketOwner{pid: pid, process: process}
+		}
+	}
+	return owners
+}
+
+// matches 判断套接字是否满足查询条件
+func (f ConnectionFilter) matches(connection models.NetworkConnection) bool {
+	if f.Protocol != "" {
+		protocol := strings.ToLower(f.Protocol)
+		if connection.Protocol != protocol && strings.TrimSuffix(connection.Protocol, "6") != protocol {
+			return false
+		}
+	}
+	if f.State != "" && !strings.EqualFold(f.State, connection.State) {
+		return false
+	}
+	if f.Listening && connection.State != "LISTEN" && connection.State != "UNCONN" {
+		return false
+	}
+	if f.Port != 0 && f.Port != connection.LocalPort && f.Port != connection.RemotePort {
+		return false
+	}
+	if f.Address != "" && f.Address != connection.LocalIP && f.Address != connection.RemoteIP {
+		return false
+	}
+	if f.PID != 0 && f.PID != connection.PID {
+		return false
+	}
+	if f.Process != "" && !strings.EqualFold(f.Process, connection.Process) {
+		return false
+	}
+	if f.Container != "" && (connection.ContainerID == "" || !strings.HasPrefix(connection.ContainerID, f.Container)) {
+		return false
+	}
+	if f.NetNS != "" && f.NetNS != connection.NetNS {
+		return false
+	}
+	return true
+}
+
+// procSocketTables 需要读取的 /proc/net 套接字表
+var procSocketTables = []string{"tcp", "tcp6", "udp", "udp6", "unix"}
+
+// readNamespaceSockets 读取一个网络命名空间的套接字表；没有进程的命名空间（ip netns）需要先切换进入
+func readNamespaceSockets(ns *netNamespace) ([]models.NetworkConnection, error) {
+	if len(ns.PIDs) > 0 {
+		return readProcSocketTables(fmt.Sprintf("/proc/%d/net", ns.PIDs[0]))
+	}
+
+	var connections []models.NetworkConnection
+	err := withNetNamespace(ns.Path, func() error {
+		var err error
+		// thread-self 跟随当前（已锁定）线程的网络命名空间
+		connections, err = readProcSocketTables("/proc/thread-self/net")
+		return err
+	})
+	return connections, err
+}
+
+// readProcSocketTables 读取目录下的 tcp/tcp6/udp/udp6/unix 表，内核未启用的协议（如IPv6）会被跳过
+func readProcSocketTables(dir string) ([]models.NetworkConnection, error) {
+	var connections []models.NetworkConnection
+	read := 0
+	for _, table := range procSocketTables {
+		content, err := os.ReadFile(filepath.Join(dir, table))
+		if err != nil {
+			continue
+		}
+		read++
+		if table == "unix" {
+			connections = append(connections, parseProcNetUnix(string(content))...)
+		} else {
+			connections = append(connections, parseProcNetSockets(table, string(content))...)
+		}
+	}
+	if read == 0 {
+		return nil, fmt.Errorf("failed to read socket tables in %s", dir)
+	}
+	return connections, nil
+}
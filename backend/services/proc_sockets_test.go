@@ -0,0 +1,137 @@
+package services
+
+import (
+	"testing"
+
+	"iptables-management-backend/models"
+)
+
+const sampleProcNetTCP = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 21457 1 0000000000000000 100 0 0 10 0
+   1: 0200A8C0:0016 0A00A8C0:D431 01 00000024:00000000 01:00000014 00000000  1000        0 31337 4 0000000000000000 20 4 29 10 -1
+`
+
+const sampleProcNetTCP6 = `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:0050 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 40001 1 0000000000000000 100 0 0 10 0
+   1: 0000000000000000FFFF00000100007F:1F90 0000000000000000FFFF00000100007F:A000 01 00000000:00000000 00:00000000 00000000    33        0 40002 1 0000000000000000 20 4 30 10 -1
+`
+
+const sampleProcNetUDP = `   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  100: 3500007F:0035 00000000:0000 07 00000000:00000000 00:00000000 00000000   101        0 5001 2 0000000000000000 0
+  101: 0200A8C0:A1B2 08080808:0035 01 00000000:00000000 00:00000000 00000000     0        0 5002 2 0000000000000000 0
+`
+
+const sampleProcNetUnix = `Num       RefCount Protocol Flags    Type St Inode Path
+0000000000000000: 00000002 00000000 00010000 0001 01 7001 /run/docker.sock
+0000000000000000: 00000003 00000000 00000000 0001 03 7002
+0000000000000000: 00000002 00000000 00000000 0002 01 7003 @/tmp/.X11-unix/X0
+`
+
+func TestParseProcSocketAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		wantIP   string
+		wantPort int
+	}{
+		{"ipv4 loopback", "0100007F:0CEA", "127.0.0.1", 3306},
+		{"ipv4 any", "00000000:0000", "0.0.0.0", 0},
+		{"ipv6 any", "00000000000000000000000000000000:0050", "::", 80},
+		{"ipv6 loopback", "00000000000000000000000001000000:0016", "::1", 22},
+		{"ipv4-mapped ipv6", "0000000000000000FFFF00000100007F:1F90", "127.0.0.1", 8080},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip, port, err := parseProcSocketAddress(tt.value)
+			if err != nil {
+				t.Fatalf("parseProcSocketAddress returned error: %v", err)
+			}
+			if ip != tt.wantIP || port != tt.wantPort {
+				t.Errorf("parseProcSocketAddress(%q) = %s:%d, want %s:%d", tt.value, ip, port, tt.wantIP, tt.wantPort)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"0100007F", "01007F:0016", "0100007F:XYZ"} {
+		if _, _, err := parseProcSocketAddress(invalid); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestParseProcNetSockets(t *testing.T) {
+	tcp := parseProcNetSockets("tcp", sampleProcNetTCP)
+	if len(tcp) != 2 {
+		t.Fatalf("expected 2 tcp sockets, got %d", len(tcp))
+	}
+	if tcp[0].State != "LISTEN" || tcp[0].LocalAddress != "127.0.0.1:3306" || tcp[0].Inode != 21457 {
+		t.Errorf("unexpected listening socket: %+v", tcp[0])
+	}
+	established := tcp[1]
+	if established.State != "ESTABLISHED" || established.RemoteIP != "192.168.0.10" || established.RemotePort != 54321 ||
+		established.TxQueue != 0x24 || established.UID != 1000 {
+		t.Errorf("unexpected established socket: %+v", established)
+	}
+
+	tcp6 := parseProcNetSockets("tcp6", sampleProcNetTCP6)
+	if len(tcp6) != 2 || tcp6[0].LocalAddress != "[::]:80" || tcp6[1].ForeignAddress != "127.0.0.1:40960" {
+		t.Errorf("unexpected tcp6 sockets: %+v", tcp6)
+	}
+
+	udp := parseProcNetSockets("udp", sampleProcNetUDP)
+	if len(udp) != 2 || udp[0].State != "UNCONN" || udp[0].LocalAddress != "127.0.0.53:53" || udp[1].State != "ESTABLISHED" {
+		t.Errorf("unexpected udp sockets: %+v", udp)
+	}
+
+	unix := parseProcNetUnix(sampleProcNetUnix)
+	if len(unix) != 3 {
+		t.Fatalf("expected 3 unix sockets, got %d", len(unix))
+	}
+	if unix[0].State != "LISTEN" || unix[0].Path != "/run/docker.sock" || unix[0].SocketType != "stream" {
+		t.Errorf("unexpected listening unix socket: %+v", unix[0])
+	}
+	if unix[1].State != "CONNECTED" || unix[1].Path != "" || unix[2].SocketType != "dgram" {
+		t.Errorf("unexpected unix sockets: %+v / %+v", unix[1], unix[2])
+	}
+}
+
+func TestConnectionFilterMatches(t *testing.T) {
+	var connections []models.NetworkConnection
+	connections = append(connections, parseProcNetSockets("tcp", sampleProcNetTCP)...)
+	connections = append(connections, parseProcNetSockets("tcp6", sampleProcNetTCP6)...)
+	connections = append(connections, parseProcNetSockets("udp", sampleProcNetUDP)...)
+	connections = append(connections, parseProcNetUnix(sampleProcNetUnix)...)
+	connections[0].PID, connections[0].Process, connections[0].NetNS = 812, "mysqld", "host"
+	connections[2].ContainerID, connections[2].NetNS = "3f2a9c1d8e7b", "net:[4026532301]"
+
+	tests := []struct {
+		name   string
+		filter ConnectionFilter
+		want   int
+	}{
+		{"no filter", ConnectionFilter{}, 9},
+		{"tcp includes tcp6", ConnectionFilter{Protocol: "tcp"}, 4},
+		{"tcp6 only", ConnectionFilter{Protocol: "tcp6"}, 2},
+		{"state", ConnectionFilter{State: "established"}, 3},
+		{"listening", ConnectionFilter{Listening: true}, 4},
+		{"port matches remote", ConnectionFilter{Port: 53}, 2},
+		{"address", ConnectionFilter{Address: "127.0.0.1"}, 2},
+		{"pid", ConnectionFilter{PID: 812}, 1},
+		{"process", ConnectionFilter{Process: "MySQLd"}, 1},
+		{"container prefix", ConnectionFilter{Container: "3f2a"}, 1},
+		{"netns", ConnectionFilter{NetNS: "host"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := 0
+			for _, connection := range connections {
+				if tt.filter.matches(connection) {
+					got++
+				}
+			}
+			if got != tt.want {
+				t.Errorf("matched %d connections, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,11 +5,12 @@ import (
 	"iptables-management-backend/config"
 	"iptables-management-backend/models"
 	"log"
-	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type RuleService struct{}
@@ -89,8 +90,7 @@ func (s *RuleService) GetSystemRules() ([]models.IPTablesRule, error) {
 
 // getTableRules 获取指定表的规则
 func (s *RuleService) getTableRules(tableName string) ([]models.IPTablesRule, error) {
-	cmd := exec.Command("iptables", "-t", tableName, "-L", "-n", "--line-numbers", "-v")
-	output, err := cmd.Output()
+	output, err := runCommand("iptables", "-t", tableName, "-L", "-n", "--line-numbers", "-v")
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute iptables command: %v", err)
 	}
@@ -172,6 +172,13 @@ func (s *RuleService) parseIPTablesOutput(output, tableName string) ([]models.IP
 				UpdatedAt:    time.Now(),
 			}
 
+			// iptables -L 中集合匹配显示为 "match-set NAME src"
+			if sets := parseSetReferences(extra); len(sets) > 0 {
+				rule.MatchSet = sets[0].Name
+				rule.MatchSetFlags = sets[0].Flags
+				rule.MatchSetNot = sets[0].Negate
+			}
+
 			rules = append(rules, rule)
 		}
 	}
@@ -261,16 +268,35 @@ func (s *RuleService) GetRuleByID(id uint) (*models.IPTablesRule, error) {
 
 // CreateRule 创建新规则
 func (s *RuleService) CreateRule(rule *models.IPTablesRule) error {
+	if err := s.validateMatchSet(rule); err != nil {
+		return err
+	}
 	// 生成规则文本
 	rule.RuleText = s.generateRuleText(rule)
 	return config.DB.Create(rule).Error
 }
 
 // UpdateRule 更新规则
-func (s *RuleService) UpdateRule(id uint, rule *models.IPTablesRule) error {
+// Updates 只写入非零字段，replaceMatchSet 为true时集合匹配字段整体覆盖，以便清空集合引用
+func (s *RuleService) UpdateRule(id uint, rule *models.IPTablesRule, replaceMatchSet bool) error {
+	if err := s.validateMatchSet(rule); err != nil {
+		return err
+	}
 	// 生成规则文本
 	rule.RuleText = s.generateRuleText(rule)
-	return config.DB.Model(&models.IPTablesRule{}).Where("id = ?", id).Updates(rule).Error
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.IPTablesRule{}).Where("id = ?", id).Updates(rule).Error; err != nil {
+			return err
+		}
+		if !replaceMatchSet {
+			return nil
+		}
+		return tx.Model(&models.IPTablesRule{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"match_set":       rule.MatchSet,
+			"match_set_flags": rule.MatchSetFlags,
+			"match_set_not":   rule.MatchSetNot,
+		}).Error
+	})
 }
 
 // DeleteRule 删除规则
@@ -317,11 +343,32 @@ func (s *RuleService) generateRuleText(rule *models.IPTablesRule) string {
 		ruleText += fmt.Sprintf(" --dport %s", rule.DestPort)
 	}
 
+	if rule.MatchSet != "" {
+		negate := ""
+		if rule.MatchSetNot {
+			negate = " !"
+		}
+		ruleText += fmt.Sprintf(" -m set%s --match-set %s %s", negate, rule.MatchSet, rule.MatchSetFlags)
+	}
+
 	ruleText += fmt.Sprintf(" -j %s", rule.Target)
 
 	return ruleText
 }
 
+// validateMatchSet 校验规则引用的ipset，方向标志缺省为src；未引用集合时清空方向标志和取反
+func (s *RuleService) validateMatchSet(rule *models.IPTablesRule) error {
+	if rule.MatchSet == "" {
+		rule.MatchSetFlags = ""
+		rule.MatchSetNot = false
+		return nil
+	}
+	if rule.MatchSetFlags == "" {
+		rule.MatchSetFlags = "src"
+	}
+	return NewIPSetService().ValidateMatchSet(rule.MatchSet, rule.MatchSetFlags)
+}
+
 // GetStatistics 获取统计信息
 func (s *RuleService) GetStatistics() (*models.Statistics, error) {
 	var totalRules int64
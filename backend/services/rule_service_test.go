@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"iptables-management-backend/config"
+	"iptables-management-backend/models"
+)
+
+func TestUpdateRuleClearsMatchSet(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	previous := config.DB
+	config.DB = db
+	defer func() { config.DB = previous }()
+	if err := config.AutoMigrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	service := NewRuleService()
+	rule := models.IPTablesRule{Table: "filter", ChainName: "INPUT", Target: "DROP", MatchSet: "blocklist", MatchSetFlags: "src", MatchSetNot: true}
+	if err := service.CreateRule(&rule); err != nil {
+		t.Fatalf("CreateRule returned error: %v", err)
+	}
+
+	// 未带 match_set 的部分更新保留集合引用
+	if err := service.UpdateRule(rule.ID, &models.IPTablesRule{Target: "REJECT"}, false); err != nil {
+		t.Fatalf("UpdateRule returned error: %v", err)
+	}
+	var stored models.IPTablesRule
+	db.First(&stored, rule.ID)
+	if stored.Target != "REJECT" || stored.MatchSet != "blocklist" || !stored.MatchSetNot {
+		t.Fatalf("unexpected rule after partial update: %+v", stored)
+	}
+
+	if err := service.UpdateRule(rule.ID, &models.IPTablesRule{MatchSet: ""}, true); err != nil {
+		t.Fatalf("UpdateRule returned error: %v", err)
+	}
+	stored = models.IPTablesRule{}
+	db.First(&stored, rule.ID)
+	if stored.MatchSet != "" || stored.MatchSetFlags != "" || stored.MatchSetNot || stored.Target != "REJECT" {
+		t.Errorf("expected match set fields to be cleared: %+v", stored)
+	}
+
+	var validation *ValidationError
+	err = service.UpdateRule(rule.ID, &models.IPTablesRule{MatchSet: "bad name"}, true)
+	if !errors.As(err, &validation) {
+		t.Errorf("expected a validation error for an invalid set name, got %v", err)
+	}
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"iptables-management-backend/config"
+	"iptables-management-backend/models"
+)
+
+const (
+	defaultRuleUsageDays   = 30
+	defaultRuleUsageRecent = 24 * time.Hour
+	defaultRuleUsageTop    = 20
+	maxRuleUsageTop        = 200
+)
+
+// RuleUsageQuery 规则使用情况报告的参数
+type RuleUsageQuery struct {
+	Since  time.Time     // 统计起点：在此之后没有命中的规则视为未使用
+	Until  time.Time     // 统计终点，默认当前时间
+	Recent time.Duration // 最近窗口：此前有命中、窗口内没有命中的规则视为已冷却
+	Table  string
+	Chain  string
+	Top    int // 最热规则的数量
+}
+
+// RuleUsage 单条规则在报告时间范围内的使用情况
+type RuleUsage struct {
+	Table            string     `json:"table"`
+	Chain            string     `json:"chain"`
+	Position         int        `json:"position"`
+	ChainRules       int        `json:"chain_rules"` // 所在链的规则数，结合序号判断是否值得前移
+	Key              string     `json:"key"`
+	Spec             string     `json:"spec"`
+	Target           string     `json:"target,omitempty"`
+	Comment          string     `json:"comment,omitempty"`
+	Packets          uint64     `json:"packets"` // 统计起点以来的命中数（跨计数器重置累计）
+	Bytes            uint64     `json:"bytes"`
+	RecentPackets    uint64     `json:"recent_packets"`
+	RecentBytes      uint64     `json:"recent_bytes"`
+	PacketsPerSecond float64    `json:"packets_per_second"` // 按有历史数据的时长计算
+	CurrentPackets   uint64     `json:"current_packets"`    // 内核当前计数（上次重置以来）
+	LastHitAt        *time.Time `json:"last_hit_at,omitempty"`
+	ObservedSince    *time.Time `json:"observed_since,omitempty"` // 有计数历史的起点
+	FullCoverage     bool       `json:"full_coverage"`            // 计数历史是否覆盖整个统计范围
+	Resets           int        `json:"resets"`
+	LastResetAt      *time.Time `json:"last_reset_at,omitempty"`
+}
+
+// RuleUsageReport 规则使用情况报告：未使用、已冷却和最热的规则
+type RuleUsageReport struct {
+	Since       time.Time   `json:"since"`
+	Until       time.Time   `json:"until"`
+	RecentSince time.Time   `json:"recent_since"`
+	Rules       int         `json:"rules"`       // 参与分析的规则数
+	Unmonitored []RuleUsage `json:"unmonitored"` // 尚未采样的规则（新添加或采样未启动）
+	Unused      []RuleUsage `json:"unused"`      // 统计起点以来没有命中
+	Cold        []RuleUsage `json:"cold"`        // 此前有命中，最近窗口内没有命中
+	Hot         []RuleUsage `json:"hot"`         // 命中数最多的规则
+}
+
+// ruleUsageCounters 单个序列的命中统计
+type ruleUsageCounters struct {
+	total   seriesSum
+	recent  seriesSum
+	lastHit int64 // 最后一个有命中的时间桶的开始时间（Unix秒），0表示样本保留期内没有命中
+}
+
+// GetRuleUsageReport 根据计数器历史生成规则使用情况报告，只包含当前规则集中存在的规则
+func (s *CounterService) GetRuleUsageReport(query RuleUsageQuery) (*RuleUsageReport, error) {
+	ruleset, err := LoadSavedRuleset()
+	if err != nil {
+		return nil, err
+	}
+
+	var seriesList []models.CounterSeries
+	if err := config.DB.Where(&models.CounterSeries{Kind: CounterKindRule, Table: query.Table, ChainName: query.Chain}).
+		Find(&seriesList).Error; err != nil {
+		return nil, fmt.Errorf("failed to query counter series: %v", err)
+	}
+	series := make(map[string]models.CounterSeries, len(seriesList))
+	ids := make([]uint, 0, len(seriesList))
+	for _, item := range seriesList {
+		series[item.Key] = item
+		ids = append(ids, item.ID)
+	}
+
+	counters := make(map[uint]ruleUsageCounters, len(ids))
+	if len(ids) > 0 {
+		totals, err := sumSeriesSamples(ids, query.Since, query.Until)
+		if err != nil {
+			return nil, err
+		}
+		recent, err := sumSeriesSamples(ids, query.Until.Add(-query.Recent), query.Until)
+		if err != nil {
+			return nil, err
+		}
+
+		// 各分辨率都写入了同样的增量，最细分辨率的时间桶开始时间最晚
+		var rows []struct {
+			SeriesID uint
+			LastHit  int64
+		}
+		err = config.DB.Model(&models.CounterSample{}).
+			Select("series_id, MAX(bucket_start) AS last_hit").
+			Where("series_id IN ? AND packets > 0 AND bucket_start < ?", ids, query.Until.Unix()).
+			Group("series_id").
+			Scan(&rows).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to query counter samples: %v", err)
+		}
+
+		for _, id := range ids {
+			counters[id] = ruleUsageCounters{total: totals[id], recent: recent[id]}
+		}
+		for _, row := range rows {
+			item := counters[row.SeriesID]
+			item.lastHit = row.LastHit
+			counters[row.SeriesID] = item
+		}
+	}
+
+	return buildRuleUsageReport(ruleset, series, counters, query), nil
+}
+
+// buildRuleUsageReport 把当前规则与计数器历史对应起来并分类
+func buildRuleUsageReport(ruleset *SavedRuleset, series map[string]models.CounterSeries, counters map[uint]ruleUsageCounters, query RuleUsageQuery) *RuleUsageReport {
+	recentSince := query.Until.Add(-query.Recent)
+	report := &RuleUsageReport{
+		Since:       query.Since,
+		Until:       query.Until,
+		RecentSince: recentSince,
+		Unmonitored: []RuleUsage{},
+		Unused:      []RuleUsage{},
+		Cold:        []RuleUsage{},
+		Hot:         []RuleUsage{},
+	}
+
+	for _, table := range ruleset.Tables {
+		if query.Table != "" && table.Name != query.Table {
+			continue
+		}
+		chainRules := make(map[string]int)
+		for _, rule := range table.Rules {
+			chainRules[rule.Chain]++
+		}
+
+		for _, rule := range table.Rules {
+			if query.Chain != "" && rule.Chain != query.Chain {
+				continue
+			}
+			report.Rules++
+			usage := RuleUsage{
+				Table:          rule.Table,
+				Chain:          rule.Chain,
+				Position:       rule.Position,
+				ChainRules:     chainRules[rule.Chain],
+				Key:            rule.Key,
+				Spec:           rule.Spec,
+				Target:         rule.Match.Target,
+				Comment:        rule.Match.Comment,
+				CurrentPackets: rule.Packets,
+			}
+
+			item, ok := series[rule.Key]
+			if !ok {
+				report.Unmonitored = append(report.Unmonitored, usage)
+				continue
+			}
+			stats := counters[item.ID]
+			// 计数历史从序列创建开始，最早只保留到统计终点的最长保留时长之前
+			historyStart := item.CreatedAt
+			if retained := query.Until.Add(-counterHistoryRetention()); retained.After(historyStart) {
+				historyStart = retained
+			}
+			observedSince := query.Since
+			if historyStart.After(observedSince) {
+				observedSince = historyStart
+			}
+			usage.ObservedSince = &observedSince
+			usage.FullCoverage = !historyStart.After(query.Since)
+			usage.Packets = stats.total.Packets
+			usage.Bytes = stats.total.Bytes
+			usage.RecentPackets = stats.recent.Packets
+			usage.RecentBytes = stats.recent.Bytes
+			if seconds := query.Until.Sub(observedSince).Seconds(); seconds > 0 {
+				usage.PacketsPerSecond = float64(usage.Packets) / seconds
+			}
+			if stats.lastHit > 0 {
+				lastHit := time.Unix(stats.lastHit, 0)
+				usage.LastHitAt = &lastHit
+			}
+			usage.Resets = item.Resets
+			usage.LastResetAt = item.LastResetAt
+
+			switch {
+			case usage.Packets == 0:
+				report.Unused = append(report.Unused, usage)
+			case usage.RecentPackets == 0 && item.CreatedAt.Before(recentSince):
+				report.Cold = append(report.Cold, usage)
+			}
+			if usage.Packets > 0 {
+				report.Hot = append(report.Hot, usage)
+			}
+		}
+	}
+
+	// 冷却时间最长的在前
+	sort.SliceStable(report.Cold, func(i, j int) bool {
+		a, b := report.Cold[i].LastHitAt, report.Cold[j].LastHitAt
+		return a != nil && (b == nil || a.Before(*b))
+	})
+	sort.SliceStable(report.Hot, func(i, j int) bool {
+		return report.Hot[i].Packets > report.Hot[j].Packets
+	})
+	if len(report.Hot) > query.Top {
+		report.Hot = report.Hot[:query.Top]
+	}
+	return report
+}
+
+// ParseRuleUsageQuery 解析报告参数：since 为RFC3339时间，未提供时取最近 days 天（默认30），不能早于计数历史的保留时长；
+// recent_hours 默认24；top 默认20
+func ParseRuleUsageQuery(sinceStr, daysStr, recentStr, topStr string) (RuleUsageQuery, error) {
+	query := RuleUsageQuery{Until: time.Now(), Recent: defaultRuleUsageRecent, Top: defaultRuleUsageTop}
+
+	if sinceStr != "" {
+		parsed, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			return query, fmt.Errorf("invalid since time: %v", err)
+		}
+		query.Since = parsed
+	} else {
+		days := float64(defaultRuleUsageDays)
+		if daysStr != "" {
+			parsed, err := strconv.ParseFloat(daysStr, 64)
+			if err != nil || parsed <= 0 {
+				return query, fmt.Errorf("invalid days: %s", daysStr)
+			}
+			days = parsed
+		}
+		query.Since = query.Until.Add(-time.Duration(days * 24 * float64(time.Hour)))
+	}
+	if !query.Since.Before(query.Until) {
+		return query, fmt.Errorf("invalid since time: must be in the past")
+	}
+	if retention := counterHistoryRetention(); query.Since.Before(query.Until.Add(-retention)) {
+		return query, fmt.Errorf("invalid since time: counter history is only kept for %d days", int(retention.Hours()/24))
+	}
+
+	if recentStr != "" {
+		hours, err := strconv.ParseFloat(recentStr, 64)
+		if err != nil || hours <= 0 {
+			return query, fmt.Errorf("invalid recent_hours: %s", recentStr)
+		}
+		query.Recent = time.Duration(hours * float64(time.Hour))
+	}
+	if query.Recent >= query.Until.Sub(query.Since) {
+		return query, fmt.Errorf("invalid recent_hours: recent window must be shorter than the report range")
+	}
+
+	if topStr != "" {
+		top, err := strconv.Atoi(topStr)
+		if err != nil || top <= 0 || top > maxRuleUsageTop {
+			return query, fmt.Errorf("invalid top: %s", topStr)
+		}
+		query.Top = top
+	}
+	return query, nil
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"iptables-management-backend/models"
+)
+
+func TestBuildRuleUsageReport(t *testing.T) {
+	ruleset, err := ParseIPTablesSave(`*filter
+:INPUT DROP [0:0]
+:FORWARD DROP [0:0]
+:OUTPUT ACCEPT [0:0]
+[9000:540000] -A INPUT -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT
+[0:0] -A INPUT -p tcp -m tcp --dport 23 -j ACCEPT
+[12:720] -A INPUT -p tcp -m tcp --dport 8080 -j ACCEPT
+[300:18000] -A INPUT -p tcp -m tcp --dport 22 -j ACCEPT
+[0:0] -A INPUT -p udp -m udp --dport 53 -j ACCEPT
+[5:300] -A FORWARD -i wg0 -o docker0 -j ACCEPT
+COMMIT
+*nat
+:PREROUTING ACCEPT [0:0]
+[1:60] -A PREROUTING -p tcp -m tcp --dport 80 -j DNAT --to-destination 172.17.0.2:80
+COMMIT
+`)
+	if err != nil {
+		t.Fatalf("ParseIPTablesSave returned error: %v", err)
+	}
+	input := ruleset.Table("filter").ChainRules("INPUT")
+	forward := ruleset.Table("filter").ChainRules("FORWARD")
+
+	until := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	query := RuleUsageQuery{Since: until.Add(-30 * 24 * time.Hour), Until: until, Recent: 24 * time.Hour, Top: 2}
+	old := query.Since.Add(-time.Hour)
+	reset := until.Add(-10 * 24 * time.Hour)
+	series := map[string]models.CounterSeries{
+		input[0].Key:   {ID: 1, Key: input[0].Key, CreatedAt: old, Resets: 1, LastResetAt: &reset},
+		input[1].Key:   {ID: 2, Key: input[1].Key, CreatedAt: old},
+		input[2].Key:   {ID: 3, Key: input[2].Key, CreatedAt: old},
+		input[3].Key:   {ID: 4, Key: input[3].Key, CreatedAt: old},
+		input[4].Key:   {ID: 5, Key: input[4].Key, CreatedAt: until.Add(-time.Hour)}, // 刚开始采样
+		forward[0].Key: {ID: 6, Key: forward[0].Key, CreatedAt: old},
+	}
+	counters := map[uint]ruleUsageCounters{
+		1: {total: seriesSum{Packets: 50000}, recent: seriesSum{Packets: 2000}, lastHit: until.Add(-time.Minute).Unix()},
+		3: {total: seriesSum{Packets: 12}, lastHit: until.Add(-20 * 24 * time.Hour).Unix()},
+		4: {total: seriesSum{Packets: 300}, recent: seriesSum{Packets: 10}, lastHit: until.Add(-time.Hour).Unix()},
+		6: {total: seriesSum{Packets: 5}, lastHit: until.Add(-25 * 24 * time.Hour).Unix()},
+	}
+
+	report := buildRuleUsageReport(ruleset, series, counters, query)
+	if report.Rules != 7 {
+		t.Errorf("analyzed %d rules, want 7", report.Rules)
+	}
+	if len(report.Unmonitored) != 1 || report.Unmonitored[0].Table != "nat" || report.Unmonitored[0].CurrentPackets != 1 {
+		t.Errorf("unexpected unmonitored rules: %+v", report.Unmonitored)
+	}
+
+	if len(report.Unused) != 2 || report.Unused[0].Position != 2 || !report.Unused[0].FullCoverage ||
+		report.Unused[1].Position != 5 || report.Unused[1].FullCoverage {
+		t.Errorf("unexpected unused rules: %+v", report.Unused)
+	}
+	if len(report.Cold) != 2 || report.Cold[0].Chain != "FORWARD" || report.Cold[1].Position != 3 {
+		t.Errorf("unexpected cold rules (coldest first): %+v", report.Cold)
+	}
+	if len(report.Hot) != 2 || report.Hot[0].Position != 1 || report.Hot[1].Position != 4 || report.Hot[1].ChainRules != 5 {
+		t.Errorf("unexpected hot rules: %+v", report.Hot)
+	}
+	if report.Hot[0].Resets != 1 || report.Hot[0].LastResetAt == nil || report.Hot[0].CurrentPackets != 9000 {
+		t.Errorf("expected reset tracking and current counter on hot rule: %+v", report.Hot[0])
+	}
+
+	// 序列创建早于保留时长时，计数历史只覆盖保留时长
+	long := query
+	long.Since = until.Add(-120 * 24 * time.Hour)
+	longSeries := map[string]models.CounterSeries{input[0].Key: {ID: 1, Key: input[0].Key, CreatedAt: until.Add(-200 * 24 * time.Hour)}}
+	if hot := buildRuleUsageReport(ruleset, longSeries, counters, long).Hot; len(hot) != 1 ||
+		hot[0].FullCoverage || !hot[0].ObservedSince.Equal(until.Add(-90*24*time.Hour)) {
+		t.Errorf("expected coverage limited to the retention window: %+v", hot)
+	}
+
+	query.Table, query.Chain = "filter", "FORWARD"
+	if filtered := buildRuleUsageReport(ruleset, series, counters, query); filtered.Rules != 1 || len(filtered.Cold) != 1 {
+		t.Errorf("unexpected filtered report: %+v", filtered)
+	}
+}
+
+func TestParseRuleUsageQuery(t *testing.T) {
+	query, err := ParseRuleUsageQuery("", "", "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if span := query.Until.Sub(query.Since); span != 30*24*time.Hour || query.Recent != 24*time.Hour || query.Top != 20 {
+		t.Errorf("unexpected defaults: span %v, %+v", span, query)
+	}
+
+	query, err = ParseRuleUsageQuery("", "7", "6", "5")
+	if err != nil || query.Until.Sub(query.Since) != 7*24*time.Hour || query.Recent != 6*time.Hour || query.Top != 5 {
+		t.Errorf("unexpected query: %+v, %v", query, err)
+	}
+
+	invalid := [][4]string{
+		{"yesterday", "", "", ""},
+		{time.Now().Add(time.Hour).Format(time.RFC3339), "", "", ""},
+		{"", "-1", "", ""},
+		{"", "120", "", ""},
+		{time.Now().Add(-100 * 24 * time.Hour).Format(time.RFC3339), "", "", ""},
+		{"", "1", "48", ""},
+		{"", "", "abc", ""},
+		{"", "", "", "1000"},
+	}
+	for _, args := range invalid {
+		if _, err := ParseRuleUsageQuery(args[0], args[1], args[2], args[3]); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
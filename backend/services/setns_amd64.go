@@ -0,0 +1,6 @@
+//go:build linux
+
+package services
+
+// sysSetns setns 系统调用号（amd64 的 syscall 包未导出）
+const sysSetns = 308
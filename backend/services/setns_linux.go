@@ -0,0 +1,8 @@
+//go:build linux && !amd64 && !386
+
+package services
+
+import "syscall"
+
+// sysSetns setns 系统调用号
+const sysSetns = syscall.SYS_SETNS
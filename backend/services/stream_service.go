@@ -0,0 +1,215 @@
+package services
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"iptables-management-backend/models"
+)
+
+type StreamService struct {
+	networkService *NetworkService
+	hub            *EventHub
+
+	lastPoll       time.Time
+	lastRules      map[string]SavedRule // 规则标识 -> 上次轮询的规则
+	lastInterfaces map[string]models.InterfaceStats
+}
+
+// NewStreamService 创建实时事件流服务实例
+func NewStreamService(networkService *NetworkService, hub *EventHub) *StreamService {
+	return &StreamService{
+		networkService: networkService,
+		hub:            hub,
+	}
+}
+
+// RuleCounterDelta 规则计数器在两次轮询间的增量
+type RuleCounterDelta struct {
+	Key          string `json:"key"`
+	Table        string `json:"table"`
+	Chain        string `json:"chain"`
+	Position     int    `json:"position"`
+	Target       string `json:"target"`
+	Comment      string `json:"comment,omitempty"`
+	Packets      uint64 `json:"packets"`
+	Bytes        uint64 `json:"bytes"`
+	DeltaPackets uint64 `json:"delta_packets"`
+	DeltaBytes   uint64 `json:"delta_bytes"`
+}
+
+// InterfaceRate 接口收发速率
+type InterfaceRate struct {
+	Interface          string  `json:"interface"`
+	RxBytesPerSecond   float64 `json:"rx_bytes_per_second"`
+	TxBytesPerSecond   float64 `json:"tx_bytes_per_second"`
+	RxPacketsPerSecond float64 `json:"rx_packets_per_second"`
+	TxPacketsPerSecond float64 `json:"tx_packets_per_second"`
+	RxBytes            int64   `json:"rx_bytes"`
+	TxBytes            int64   `json:"tx_bytes"`
+}
+
+// RulesetChange 规则集变化（新增或删除的规则）
+type RulesetChange struct {
+	Added   []SavedRule `json:"added"`
+	Removed []SavedRule `json:"removed"`
+}
+
+// Start 启动轮询，仅在有订阅者时读取计数器
+func (s *StreamService) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if s.hub.SubscriberCount() == 0 {
+				// 无订阅者时丢弃基线，重新订阅后不会推送积累的大增量
+				s.lastRules = nil
+				s.lastInterfaces = nil
+				continue
+			}
+			s.poll()
+		}
+	}()
+}
+
+// poll 轮询一次并发布变化
+func (s *StreamService) poll() {
+	now := time.Now()
+	elapsed := now.Sub(s.lastPoll).Seconds()
+	s.lastPoll = now
+
+	if ruleset, err := LoadSavedRuleset(); err != nil {
+		log.Printf("[WARN] Failed to load ruleset for streaming: %v", err)
+	} else {
+		s.publishRuleChanges(ruleset, now)
+	}
+
+	if stats, err := s.networkService.GetAllInterfaceStats(); err != nil {
+		log.Printf("[WARN] Failed to read interface stats for streaming: %v", err)
+	} else {
+		s.publishInterfaceRates(stats, elapsed, now)
+	}
+}
+
+// publishRuleChanges 发布规则计数增量和规则集变化
+func (s *StreamService) publishRuleChanges(ruleset *SavedRuleset, now time.Time) {
+	current := make(map[string]SavedRule)
+	for _, table := range ruleset.Tables {
+		for _, rule := range table.Rules {
+			current[rule.Key] = rule
+		}
+	}
+
+	previous := s.lastRules
+	s.lastRules = current
+	if previous == nil {
+		return
+	}
+
+	// 按表和链分组发布规则集变化，便于订阅者过滤
+	changes := make(map[[2]string]*RulesetChange)
+	changeFor := func(rule SavedRule) *RulesetChange {
+		groupKey := [2]string{rule.Table, rule.Chain}
+		if changes[groupKey] == nil {
+			changes[groupKey] = &RulesetChange{Added: []SavedRule{}, Removed: []SavedRule{}}
+		}
+		return changes[groupKey]
+	}
+
+	for key, rule := range current {
+		old, existed := previous[key]
+		if !existed {
+			change := changeFor(rule)
+			change.Added = append(change.Added, rule)
+			continue
+		}
+		if rule.Packets == old.Packets && rule.Bytes == old.Bytes {
+			continue
+		}
+		s.hub.Publish(StreamEvent{
+			Type:      EventTypeRuleCounter,
+			Timestamp: now,
+			Table:     rule.Table,
+			Chain:     rule.Chain,
+			Interface: ruleInterfaces(rule),
+			Data: RuleCounterDelta{
+				Key:          rule.Key,
+				Table:        rule.Table,
+				Chain:        rule.Chain,
+				Position:     rule.Position,
+				Target:       rule.Match.Target,
+				Comment:      rule.Match.Comment,
+				Packets:      rule.Packets,
+				Bytes:        rule.Bytes,
+				DeltaPackets: counterDelta(old.Packets, rule.Packets),
+				DeltaBytes:   counterDelta(old.Bytes, rule.Bytes),
+			},
+		})
+	}
+	for key, rule := range previous {
+		if _, exists := current[key]; !exists {
+			change := changeFor(rule)
+			change.Removed = append(change.Removed, rule)
+		}
+	}
+
+	for groupKey, change := range changes {
+		var interfaces []string
+		for _, rule := range append(append([]SavedRule{}, change.Added...), change.Removed...) {
+			interfaces = append(interfaces, ruleInterfaces(rule)...)
+		}
+		sort.Slice(change.Added, func(i, j int) bool { return change.Added[i].Position < change.Added[j].Position })
+		sort.Slice(change.Removed, func(i, j int) bool { return change.Removed[i].Position < change.Removed[j].Position })
+		s.hub.Publish(StreamEvent{
+			Type:      EventTypeRulesetChange,
+			Timestamp: now,
+			Table:     groupKey[0],
+			Chain:     groupKey[1],
+			Interface: interfaces,
+			Data:      change,
+		})
+	}
+}
+
+// publishInterfaceRates 发布接口速率变化
+func (s *StreamService) publishInterfaceRates(stats map[string]models.InterfaceStats, elapsed float64, now time.Time) {
+	previous := s.lastInterfaces
+	s.lastInterfaces = stats
+	if previous == nil || elapsed <= 0 {
+		return
+	}
+
+	for name, current := range stats {
+		old, ok := previous[name]
+		if !ok || (current.RxBytes == old.RxBytes && current.TxBytes == old.TxBytes) {
+			continue
+		}
+		s.hub.Publish(StreamEvent{
+			Type:      EventTypeInterfaceRate,
+			Timestamp: now,
+			Interface: []string{name},
+			Data: InterfaceRate{
+				Interface:          name,
+				RxBytesPerSecond:   float64(counterDelta(uint64(old.RxBytes), uint64(current.RxBytes))) / elapsed,
+				TxBytesPerSecond:   float64(counterDelta(uint64(old.TxBytes), uint64(current.TxBytes))) / elapsed,
+				RxPacketsPerSecond: float64(counterDelta(uint64(old.RxPackets), uint64(current.RxPackets))) / elapsed,
+				TxPacketsPerSecond: float64(counterDelta(uint64(old.TxPackets), uint64(current.TxPackets))) / elapsed,
+				RxBytes:            current.RxBytes,
+				TxBytes:            current.TxBytes,
+			},
+		})
+	}
+}
+
+// ruleInterfaces 规则涉及的入/出接口
+func ruleInterfaces(rule SavedRule) []string {
+	var interfaces []string
+	if rule.Match.InInterface != "" {
+		interfaces = append(interfaces, rule.Match.InInterface)
+	}
+	if rule.Match.OutInterface != "" {
+		interfaces = append(interfaces, rule.Match.OutInterface)
+	}
+	return interfaces
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// StreamTicketTTL 事件流票据有效期，票据只能在此时间内使用一次
+const StreamTicketTTL = 30 * time.Second
+
+// StreamTicket 一次性的事件流票据（EventSource 无法设置请求头，用它代替会话JWT放入URL）
+type StreamTicket struct {
+	Ticket    string    `json:"ticket"`
+	Username  string    `json:"-"`
+	Role      string    `json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// streamTickets 已签发、尚未使用的票据
+var streamTickets = struct {
+	sync.Mutex
+	tickets map[string]StreamTicket
+}{tickets: make(map[string]StreamTicket)}
+
+// IssueStreamTicket 为已认证的用户签发票据并清理过期票据
+func IssueStreamTicket(username, role string) (*StreamTicket, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate stream ticket: %v", err)
+	}
+	ticket := StreamTicket{
+		Ticket:    hex.EncodeToString(id),
+		Username:  username,
+		Role:      role,
+		ExpiresAt: time.Now().Add(StreamTicketTTL),
+	}
+
+	streamTickets.Lock()
+	defer streamTickets.Unlock()
+	now := time.Now()
+	for key, existing := range streamTickets.tickets {
+		if now.After(existing.ExpiresAt) {
+			delete(streamTickets.tickets, key)
+		}
+	}
+	streamTickets.tickets[ticket.Ticket] = ticket
+	return &ticket, nil
+}
+
+// ConsumeStreamTicket 校验并作废票据，票据不存在、已使用或已过期时返回错误
+func ConsumeStreamTicket(id string) (*StreamTicket, error) {
+	streamTickets.Lock()
+	defer streamTickets.Unlock()
+	ticket, ok := streamTickets.tickets[id]
+	if !ok {
+		return nil, fmt.Errorf("stream ticket not found or already used")
+	}
+	delete(streamTickets.tickets, id)
+	if time.Now().After(ticket.ExpiresAt) {
+		return nil, fmt.Errorf("stream ticket expired")
+	}
+	return &ticket, nil
+}
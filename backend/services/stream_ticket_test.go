@@ -0,0 +1,31 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStreamTicketSingleUse(t *testing.T) {
+	ticket, err := IssueStreamTicket("admin", "admin")
+	if err != nil {
+		t.Fatalf("IssueStreamTicket returned error: %v", err)
+	}
+
+	consumed, err := ConsumeStreamTicket(ticket.Ticket)
+	if err != nil || consumed.Username != "admin" || consumed.Role != "admin" {
+		t.Fatalf("unexpected ticket: %+v, %v", consumed, err)
+	}
+	if _, err := ConsumeStreamTicket(ticket.Ticket); err == nil {
+		t.Errorf("expected a used ticket to be rejected")
+	}
+
+	expired, _ := IssueStreamTicket("admin", "admin")
+	streamTickets.Lock()
+	entry := streamTickets.tickets[expired.Ticket]
+	entry.ExpiresAt = time.Now().Add(-time.Second)
+	streamTickets.tickets[expired.Ticket] = entry
+	streamTickets.Unlock()
+	if _, err := ConsumeStreamTicket(expired.Ticket); err == nil {
+		t.Errorf("expected an expired ticket to be rejected")
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"iptables-management-backend/utils"
 	"log"
-	"os/exec"
 	"strconv"
 	"strings"
 )
@@ -70,8 +69,7 @@ func (s *TableService) GetTableInfo(tableName string) (*TableInfo, error) {
 	log.Printf("[DEBUG] Getting table info for: %s", tableName)
 
 	// 执行 iptables -t <table> -L -n --line-numbers 命令
-	cmd := exec.Command("iptables", "-t", tableName, "-L", "-n", "--line-numbers", "-v")
-	output, err := cmd.Output()
+	output, err := runCommand("iptables", "-t", tableName, "-L", "-n", "--line-numbers", "-v")
 	if err != nil {
 		log.Printf("[ERROR] Failed to execute iptables command for table %s: %v", tableName, err)
 		return nil, fmt.Errorf("failed to get table info: %v", err)
@@ -93,15 +91,13 @@ func (s *TableService) GetTableInfo(tableName string) (*TableInfo, error) {
 func (s *TableService) GetChainVerbose(tableName, chainName string) (*ChainInfo, error) {
 	log.Printf("[DEBUG] Getting verbose info for chain %s in table %s", chainName, tableName)
 
-	var cmd *exec.Cmd
-	if tableName == "filter" {
+	args := []string{"-L", chainName, "-v"}
+	if tableName != "filter" {
 		// 对于filter表，可以省略-t参数
-		cmd = exec.Command("iptables", "-L", chainName, "-v")
-	} else {
-		cmd = exec.Command("iptables", "-t", tableName, "-L", chainName, "-v")
+		args = append([]string{"-t", tableName}, args...)
 	}
 
-	output, err := cmd.Output()
+	output, err := runCommand("iptables", args...)
 	if err != nil {
 		log.Printf("[ERROR] Failed to execute iptables verbose command: %v", err)
 		return nil, fmt.Errorf("failed to get chain verbose info: %v", err)
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// nodeColumns 节点导出的固定字段
+var nodeColumns = []string{
+	"id", "label", "type", "interface_name", "interface_type", "table_name", "chain_name",
+	"policy", "rule_count", "rule_number", "packets", "bytes", "layer", "x", "y",
+}
+
+// linkColumns 连接导出的固定字段
+var linkColumns = []string{
+	"id", "source", "target", "type", "label", "rule_text", "rule_number",
+	"chain_type", "action", "protocol", "port",
+}
+
+// nodeValues 节点固定字段的值（与 nodeColumns 对应）
+func nodeValues(node TopologyNode) []string {
+	return []string{
+		node.ID, node.Label, node.Type, node.InterfaceName, node.InterfaceType, node.TableName, node.ChainName,
+		node.Policy, strconv.Itoa(node.RuleCount), strconv.Itoa(node.RuleNumber), node.Packets, node.Bytes,
+		strconv.Itoa(node.Layer), strconv.Itoa(node.Position.X), strconv.Itoa(node.Position.Y),
+	}
+}
+
+// linkValues 连接固定字段的值（与 linkColumns 对应）
+func linkValues(link TopologyLink) []string {
+	return []string{
+		link.ID, link.Source, link.Target, link.Type, link.Label, link.RuleText, strconv.Itoa(link.RuleNumber),
+		link.ChainType, link.Action, link.Protocol, link.Port,
+	}
+}
+
+// propertyKeys 收集所有扩展属性名（排序后保证输出稳定）
+func propertyKeys(properties []map[string]string) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, props := range properties {
+		for key := range props {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func nodeProperties(topology *TopologyData) []map[string]string {
+	props := make([]map[string]string, len(topology.Nodes))
+	for i, node := range topology.Nodes {
+		props[i] = node.Properties
+	}
+	return props
+}
+
+func linkProperties(topology *TopologyData) []map[string]string {
+	props := make([]map[string]string, len(topology.Links))
+	for i, link := range topology.Links {
+		props[i] = link.Properties
+	}
+	return props
+}
+
+// ExportTopologyCSV 导出为zip，包含 nodes.csv、edges.csv 和 flows.csv
+func ExportTopologyCSV(topology *TopologyData) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	writeCSV := func(name string, rows [][]string) error {
+		file, err := archive.Create(name)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %v", name, err)
+		}
+		writer := csv.NewWriter(file)
+		if err := writer.WriteAll(rows); err != nil {
+			return fmt.Errorf("failed to write %s: %v", name, err)
+		}
+		return nil
+	}
+
+	// 节点
+	nodeProps := propertyKeys(nodeProperties(topology))
+	header := append([]string{}, nodeColumns...)
+	for _, key := range nodeProps {
+		header = append(header, "prop_"+key)
+	}
+	rows := [][]string{header}
+	for _, node := range topology.Nodes {
+		row := nodeValues(node)
+		for _, key := range nodeProps {
+			row = append(row, node.Properties[key])
+		}
+		rows = append(rows, row)
+	}
+	if err := writeCSV("nodes.csv", rows); err != nil {
+		return nil, err
+	}
+
+	// 连接
+	linkProps := propertyKeys(linkProperties(topology))
+	header = append([]string{}, linkColumns...)
+	for _, key := range linkProps {
+		header = append(header, "prop_"+key)
+	}
+	rows = [][]string{header}
+	for _, link := range topology.Links {
+		row := linkValues(link)
+		for _, key := range linkProps {
+			row = append(row, link.Properties[key])
+		}
+		rows = append(rows, row)
+	}
+	if err := writeCSV("edges.csv", rows); err != nil {
+		return nil, err
+	}
+
+	// 数据流
+	rows = [][]string{{"id", "name", "description", "color", "path"}}
+	for _, flow := range topology.Flow {
+		rows = append(rows, []string{flow.ID, flow.Name, flow.Description, flow.Color, strings.Join(flow.Path, " > ")})
+	}
+	if err := writeCSV("flows.csv", rows); err != nil {
+		return nil, err
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize zip: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ExportTopologyGraphML 导出为GraphML，包含节点和连接的全部字段及扩展属性
+func ExportTopologyGraphML(topology *TopologyData) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+
+	writeKey := func(id, domain, name, attrType string) {
+		fmt.Fprintf(&buf, `  <key id="%s" for="%s" attr.name="%s" attr.type="%s"/>`+"\n",
+			xmlEscape(id), domain, xmlEscape(name), attrType)
+	}
+
+	intColumns := map[string]bool{"rule_count": true, "rule_number": true, "layer": true, "x": true, "y": true}
+	for _, column := range nodeColumns[1:] {
+		attrType := "string"
+		if intColumns[column] {
+			attrType = "int"
+		}
+		writeKey("n_"+column, "node", column, attrType)
+	}
+	nodeProps := propertyKeys(nodeProperties(topology))
+	for _, key := range nodeProps {
+		writeKey("np_"+key, "node", "prop_"+key, "string")
+	}
+	for _, column := range linkColumns[3:] {
+		attrType := "string"
+		if column == "rule_number" {
+			attrType = "int"
+		}
+		writeKey("e_"+column, "edge", column, attrType)
+	}
+	linkProps := propertyKeys(linkProperties(topology))
+	for _, key := range linkProps {
+		writeKey("ep_"+key, "edge", "prop_"+key, "string")
+	}
+	writeKey("g_flows", "graph", "flows", "string")
+
+	writeData := func(indent, key, value string) {
+		if value == "" {
+			return
+		}
+		fmt.Fprintf(&buf, `%s<data key="%s">%s</data>`+"\n", indent, xmlEscape(key), xmlEscape(value))
+	}
+
+	buf.WriteString(`  <graph id="topology" edgedefault="directed">` + "\n")
+	if len(topology.Flow) > 0 {
+		flows, err := json.Marshal(topology.Flow)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode flows: %v", err)
+		}
+		writeData("    ", "g_flows", string(flows))
+	}
+
+	for _, node := range topology.Nodes {
+		fmt.Fprintf(&buf, `    <node id="%s">`+"\n", xmlEscape(node.ID))
+		values := nodeValues(node)
+		for i, column := range nodeColumns[1:] {
+			writeData("      ", "n_"+column, values[i+1])
+		}
+		for _, key := range nodeProps {
+			writeData("      ", "np_"+key, node.Properties[key])
+		}
+		buf.WriteString("    </node>\n")
+	}
+
+	for _, link := range topology.Links {
+		fmt.Fprintf(&buf, `    <edge id="%s" source="%s" target="%s">`+"\n",
+			xmlEscape(link.ID), xmlEscape(link.Source), xmlEscape(link.Target))
+		values := linkValues(link)
+		for i, column := range linkColumns[3:] {
+			writeData("      ", "e_"+column, values[i+3])
+		}
+		for _, key := range linkProps {
+			writeData("      ", "ep_"+key, link.Properties[key])
+		}
+		buf.WriteString("    </edge>\n")
+	}
+
+	buf.WriteString("  </graph>\n</graphml>\n")
+	return buf.Bytes(), nil
+}
+
+// xmlEscape 转义XML文本和属性值
+func xmlEscape(value string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(value))
+	return buf.String()
+}
+
+// ExportTopologyDOT 导出为Graphviz DOT，规则节点按表/链分组
+func ExportTopologyDOT(topology *TopologyData) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("digraph topology {\n")
+	buf.WriteString("  rankdir=LR;\n")
+	buf.WriteString("  node [fontname=\"Helvetica\", fontsize=10];\n")
+	buf.WriteString("  edge [fontname=\"Helvetica\", fontsize=8];\n\n")
+
+	// 按表/链分组到子图
+	clusters := make(map[string][]TopologyNode)
+	var clusterNames []string
+	var ungrouped []TopologyNode
+	for _, node := range topology.Nodes {
+		if node.Type == "interface" || node.ChainName == "" {
+			ungrouped = append(ungrouped, node)
+			continue
+		}
+		name := node.ChainName
+		if node.TableName != "" {
+			name = node.TableName + "/" + node.ChainName
+		}
+		if _, ok := clusters[name]; !ok {
+			clusterNames = append(clusterNames, name)
+		}
+		clusters[name] = append(clusters[name], node)
+	}
+
+	for _, node := range ungrouped {
+		writeDOTNode(&buf, "  ", node)
+	}
+	for i, name := range clusterNames {
+		fmt.Fprintf(&buf, "\n  subgraph cluster_%d {\n    label=%s;\n    style=rounded;\n", i, dotQuote(name))
+		for _, node := range clusters[name] {
+			writeDOTNode(&buf, "    ", node)
+		}
+		buf.WriteString("  }\n")
+	}
+
+	buf.WriteString("\n")
+	for _, link := range topology.Links {
+		label := link.Label
+		if label == "" {
+			label = link.Action
+		}
+		fmt.Fprintf(&buf, "  %s -> %s [label=%s, color=%s];\n",
+			dotQuote(link.Source), dotQuote(link.Target), dotQuote(label), dotQuote(dotActionColor(link.Action)))
+	}
+
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// writeDOTNode 输出单个节点
+func writeDOTNode(buf *bytes.Buffer, indent string, node TopologyNode) {
+	shape := "box"
+	switch node.Type {
+	case "interface":
+		shape = "component"
+	case "table":
+		shape = "folder"
+	case "chain":
+		shape = "ellipse"
+	case "rule":
+		shape = "note"
+	}
+
+	label := node.Label
+	if label == "" {
+		label = node.ID
+	}
+	if node.Packets != "" {
+		label += fmt.Sprintf("\n%s pkts / %s", node.Packets, node.Bytes)
+	}
+	fmt.Fprintf(buf, "%s%s [label=%s, shape=%s];\n", indent, dotQuote(node.ID), dotQuote(label), shape)
+}
+
+// dotActionColor 根据规则动作选择连线颜色
+func dotActionColor(action string) string {
+	switch strings.ToUpper(action) {
+	case "ACCEPT":
+		return "darkgreen"
+	case "DROP":
+		return "red"
+	case "REJECT":
+		return "orange"
+	default:
+		return "gray40"
+	}
+}
+
+// dotQuote 生成DOT双引号字符串
+func dotQuote(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + replacer.Replace(value) + `"`
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func sampleTopology() *TopologyData {
+	return &TopologyData{
+		Nodes: []TopologyNode{
+			{ID: "interface_eth0", Label: "eth0", Type: "interface", InterfaceName: "eth0", Properties: map[string]string{"state": "UP"}},
+			{ID: "rule_filter_INPUT_1", Label: `allow "ssh" <22>`, Type: "rule", TableName: "filter", ChainName: "INPUT",
+				RuleNumber: 1, Packets: "10", Bytes: "600", Properties: map[string]string{"protocol": "tcp"}},
+		},
+		Links: []TopologyLink{
+			{ID: "link_1", Source: "interface_eth0", Target: "rule_filter_INPUT_1", Type: "interface_rule", Action: "ACCEPT", Port: "22",
+				Properties: map[string]string{"direction": "in"}},
+		},
+		Flow: []FlowPath{
+			{ID: "flow_1", Name: "inbound", Path: []string{"interface_eth0", "rule_filter_INPUT_1"}, Color: "#00ff00"},
+		},
+	}
+}
+
+func TestExportTopologyCSV(t *testing.T) {
+	data, err := ExportTopologyCSV(sampleTopology())
+	if err != nil {
+		t.Fatalf("ExportTopologyCSV returned error: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+
+	files := make(map[string][][]string)
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", file.Name, err)
+		}
+		rows, err := csv.NewReader(reader).ReadAll()
+		reader.Close()
+		if err != nil {
+			t.Fatalf("invalid csv in %s: %v", file.Name, err)
+		}
+		files[file.Name] = rows
+	}
+
+	testCases := []struct {
+		file      string
+		rows      int
+		lastField string
+	}{
+		{"nodes.csv", 3, "prop_state"},
+		{"edges.csv", 2, "prop_direction"},
+		{"flows.csv", 2, "path"},
+	}
+	for _, tc := range testCases {
+		rows, ok := files[tc.file]
+		if !ok {
+			t.Errorf("%s missing from archive", tc.file)
+			continue
+		}
+		if len(rows) != tc.rows {
+			t.Errorf("%s: expected %d rows, got %d", tc.file, tc.rows, len(rows))
+		}
+		if header := rows[0]; header[len(header)-1] != tc.lastField {
+			t.Errorf("%s: expected last column %s, got %s", tc.file, tc.lastField, header[len(header)-1])
+		}
+	}
+
+	if got := files["nodes.csv"][2][1]; got != `allow "ssh" <22>` {
+		t.Errorf("label not preserved, got %q", got)
+	}
+}
+
+func TestExportTopologyGraphML(t *testing.T) {
+	data, err := ExportTopologyGraphML(sampleTopology())
+	if err != nil {
+		t.Fatalf("ExportTopologyGraphML returned error: %v", err)
+	}
+
+	var doc struct {
+		Keys []struct {
+			ID string `xml:"id,attr"`
+		} `xml:"key"`
+		Graph struct {
+			Nodes []struct {
+				ID   string `xml:"id,attr"`
+				Data []struct {
+					Key   string `xml:"key,attr"`
+					Value string `xml:",chardata"`
+				} `xml:"data"`
+			} `xml:"node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid GraphML: %v", err)
+	}
+
+	if len(doc.Graph.Nodes) != 2 || len(doc.Graph.Edges) != 1 {
+		t.Fatalf("expected 2 nodes and 1 edge, got %d and %d", len(doc.Graph.Nodes), len(doc.Graph.Edges))
+	}
+
+	found := false
+	for _, data := range doc.Graph.Nodes[1].Data {
+		if data.Key == "n_label" && data.Value == `allow "ssh" <22>` {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("label not round-tripped through GraphML")
+	}
+}
+
+func TestExportTopologyDOT(t *testing.T) {
+	dot := string(ExportTopologyDOT(sampleTopology()))
+
+	expected := []string{
+		"digraph topology {",
+		`"interface_eth0" [label="eth0", shape=component];`,
+		`label="filter/INPUT";`,
+		`label="allow \"ssh\" <22>\n10 pkts / 600"`,
+		`"interface_eth0" -> "rule_filter_INPUT_1" [label="ACCEPT", color="darkgreen"];`,
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(dot, fragment) {
+			t.Errorf("DOT output missing %q\n%s", fragment, dot)
+		}
+	}
+}
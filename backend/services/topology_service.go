@@ -13,6 +13,7 @@ import (
 type TopologyService struct {
 	tableService   *TableService
 	networkService *NetworkService
+	ipsetService   *IPSetService
 	cache          *TopologyCache
 }
 
@@ -36,6 +37,7 @@ func NewTopologyService() *TopologyService {
 	return &TopologyService{
 		tableService:   NewTableService(),
 		networkService: NewNetworkService(),
+		ipsetService:   NewIPSetService(),
 		cache:          NewTopologyCache(30 * time.Second), // 30秒缓存
 	}
 }
@@ -51,7 +53,7 @@ type TopologyData struct {
 type TopologyNode struct {
 	ID            string            `json:"id"`
 	Label         string            `json:"label"`
-	Type          string            `json:"type"` // interface, table, chain, rule
+	Type          string            `json:"type"` // interface, table, chain, rule, ipset
 	InterfaceName string            `json:"interface_name,omitempty"`
 	InterfaceType string            `json:"interface_type,omitempty"`
 	TableName     string            `json:"table_name,omitempty"`
@@ -71,7 +73,7 @@ type TopologyLink struct {
 	ID         string            `json:"id"`
 	Source     string            `json:"source"`
 	Target     string            `json:"target"`
-	Type       string            `json:"type"` // interface_rule, rule_interface, input, output, forward
+	Type       string            `json:"type"` // interface_rule, rule_interface, input, output, forward, match_set
 	Label      string            `json:"label,omitempty"`
 	RuleText   string            `json:"rule_text,omitempty"`
 	RuleNumber int               `json:"rule_number,omitempty"`
@@ -129,8 +131,10 @@ func (s *TopologyService) GetTopologyData() (*TopologyData, error) {
 
 	// 生成以网络接口为中心的拓扑
 	s.generateInterfaceNodes(interfaces, topology)
-	s.generateRuleNodes(tables, topology)
-	s.generateInterfaceRuleLinks(interfaces, tables, topology)
+	s.generateInterfaceLayerLinks(interfaces, topology)
+	ruleKeys := s.ruleNodeKeys(tables)
+	s.generateRuleNodes(tables, ruleKeys, topology)
+	s.generateInterfaceRuleLinks(interfaces, tables, ruleKeys, topology)
 	s.generateNetworkFlowPaths(topology)
 
 	// 数据验证和清理
@@ -388,6 +392,11 @@ func (c *TopologyCache) invalidate() {
 	c.timestamp = time.Time{}
 }
 
+// InvalidateCache 使拓扑缓存失效，下次获取时重新生成
+func (s *TopologyService) InvalidateCache() {
+	s.cache.invalidate()
+}
+
 // generateInterfaceNodes 生成网络接口节点
 func (s *TopologyService) generateInterfaceNodes(interfaces []models.NetworkInterface, topology *TopologyData) {
 	// 分层布局：外部网络在顶部，内部网络在底部
@@ -444,6 +453,20 @@ func (s *TopologyService) generateInterfaceNodes(interfaces []models.NetworkInte
 			},
 		}
 
+		// 添加内核链路信息
+		if iface.Kind != "" {
+			interfaceNode.Properties["kind"] = iface.Kind
+		}
+		if iface.Master != "" {
+			interfaceNode.Properties["master"] = iface.Master
+		}
+		if iface.Parent != "" {
+			interfaceNode.Properties["parent"] = iface.Parent
+		}
+		if iface.PeerAddress != "" {
+			interfaceNode.Properties["peer_address"] = iface.PeerAddress
+		}
+
 		// 添加IP地址信息
 		if len(iface.IPAddresses) > 0 {
 			interfaceNode.Properties["ip_addresses"] = strings.Join(iface.IPAddresses, ", ")
@@ -454,10 +477,38 @@ func (s *TopologyService) generateInterfaceNodes(interfaces []models.NetworkInte
 	}
 }
 
+// generateInterfaceLayerLinks 根据内核链路关系生成接口间连接（网桥端口、VLAN/veth的下层接口）
+func (s *TopologyService) generateInterfaceLayerLinks(interfaces []models.NetworkInterface, topology *TopologyData) {
+	for _, iface := range interfaces {
+		source := fmt.Sprintf("interface_%s", iface.Name)
+		if iface.Master != "" {
+			topology.Links = append(topology.Links, TopologyLink{
+				ID:     fmt.Sprintf("link_%s_master_%s", iface.Name, iface.Master),
+				Source: source,
+				Target: fmt.Sprintf("interface_%s", iface.Master),
+				Type:   "master",
+				Label:  "master",
+			})
+		}
+		// veth对在同一命名空间时两端互为下层接口，只保留一条连接
+		if iface.Parent != "" && !(iface.Kind == "veth" && iface.Parent < iface.Name) {
+			topology.Links = append(topology.Links, TopologyLink{
+				ID:     fmt.Sprintf("link_%s_parent_%s", iface.Name, iface.Parent),
+				Source: source,
+				Target: fmt.Sprintf("interface_%s", iface.Parent),
+				Type:   "parent",
+				Label:  iface.Kind,
+			})
+		}
+	}
+}
+
 // generateRuleNodes 生成重要的iptables规则节点
-func (s *TopologyService) generateRuleNodes(tables []TableInfo, topology *TopologyData) {
+func (s *TopologyService) generateRuleNodes(tables []TableInfo, ruleKeys map[string][]string, topology *TopologyData) {
 	ruleY := 250 // 规则层位置
 	ruleX := 100
+	setNodes := make(map[string]bool)
+	var setInfo map[string]models.IPSet
 
 	for _, table := range tables {
 		for _, chain := range table.Chains {
@@ -468,8 +519,9 @@ func (s *TopologyService) generateRuleNodes(tables []TableInfo, topology *Topolo
 
 			for i, rule := range chain.Rules {
 				if s.isImportantRule(rule) {
+					ruleKey := ruleKeys[table.TableName+"|"+chain.ChainName][i]
 					ruleNode := TopologyNode{
-						ID:         fmt.Sprintf("rule_%s_%s_%d", table.TableName, chain.ChainName, i),
+						ID:         ruleNodeID(table.TableName, chain.ChainName, ruleKey),
 						Label:      s.getRuleLabel(rule),
 						Type:       "rule",
 						TableName:  table.TableName,
@@ -492,8 +544,61 @@ func (s *TopologyService) generateRuleNodes(tables []TableInfo, topology *Topolo
 							"interface_out": s.extractInterface(rule.RuleText, "-o"),
 							"source_port":   s.extractPort(rule.RuleText, "--sport"),
 							"dest_port":     s.extractPort(rule.RuleText, "--dport"),
+							"rule_key":      ruleKey,
 						},
 					}
+
+					// 规则引用的ipset作为独立节点，多个规则共用同一集合节点
+					references := parseSetReferences(rule.RuleText)
+					if len(references) > 0 && setInfo == nil {
+						setInfo = s.loadIPSetInfo()
+					}
+					var matchSets []string
+					for _, ref := range references {
+						label := ref.Name + " " + ref.Flags
+						if ref.Negate {
+							label = "! " + label
+						}
+						matchSets = append(matchSets, label)
+
+						setID := "ipset_" + ref.Name
+						if !setNodes[ref.Name] {
+							setNodes[ref.Name] = true
+							setNode := TopologyNode{
+								ID:         setID,
+								Label:      ref.Name,
+								Type:       "ipset",
+								Layer:      2,
+								Position:   Position{X: ruleX, Y: ruleY + 120},
+								Properties: map[string]string{},
+							}
+							if set, ok := setInfo[ref.Name]; ok {
+								setNode.Properties["set_type"] = set.Type
+								setNode.Properties["entries"] = fmt.Sprintf("%d", set.EntryCount)
+								setNode.Properties["references"] = fmt.Sprintf("%d", set.References)
+							}
+							topology.Nodes = append(topology.Nodes, setNode)
+						}
+						topology.Links = append(topology.Links, TopologyLink{
+							ID:         fmt.Sprintf("link_%s_to_%s", ruleNode.ID, setID),
+							Source:     ruleNode.ID,
+							Target:     setID,
+							Type:       "match_set",
+							Label:      label,
+							ChainType:  chain.ChainName,
+							Action:     rule.Target,
+							RuleNumber: i + 1,
+							RuleText:   rule.RuleText,
+							Properties: map[string]string{
+								"flags":  ref.Flags,
+								"negate": fmt.Sprintf("%t", ref.Negate),
+							},
+						})
+					}
+					if len(matchSets) > 0 {
+						ruleNode.Properties["match_set"] = strings.Join(matchSets, ", ")
+					}
+
 					topology.Nodes = append(topology.Nodes, ruleNode)
 					ruleX += 150 // 水平间距
 				}
@@ -502,8 +607,81 @@ func (s *TopologyService) generateRuleNodes(tables []TableInfo, topology *Topolo
 	}
 }
 
+// ruleNodeKeys 加载 iptables-save 规则集并计算规则节点的稳定标识
+func (s *TopologyService) ruleNodeKeys(tables []TableInfo) map[string][]string {
+	ruleset, err := LoadSavedRuleset()
+	if err != nil {
+		log.Printf("[WARN] Failed to load ruleset for topology rule keys: %v", err)
+	}
+	return buildRuleNodeKeys(tables, ruleset)
+}
+
+// buildRuleNodeKeys 按规则内容（RuleKey）计算每条规则的稳定标识，键为 "表|链"。
+// 插入或删除规则不会改变其他规则的ID；iptables-save 与列表条数不一致时退回按列表文本计算，
+// 同一链中内容完全相同的规则追加出现次数区分
+func buildRuleNodeKeys(tables []TableInfo, ruleset *SavedRuleset) map[string][]string {
+	keys := make(map[string][]string)
+	for _, table := range tables {
+		var saved *SavedTable
+		if ruleset != nil {
+			saved = ruleset.Table(table.TableName)
+		}
+		for _, chain := range table.Chains {
+			var savedRules []SavedRule
+			if saved != nil {
+				savedRules = saved.ChainRules(chain.ChainName)
+			}
+			seen := make(map[string]int)
+			chainKeys := make([]string, len(chain.Rules))
+			for i, rule := range chain.Rules {
+				var key string
+				if len(savedRules) == len(chain.Rules) {
+					key = savedRules[i].Key
+				} else {
+					key = RuleKey(table.TableName, chain.ChainName, ruleListingSpec(rule.RuleText))
+				}
+				seen[key]++
+				if seen[key] > 1 {
+					key = fmt.Sprintf("%s_%d", key, seen[key])
+				}
+				chainKeys[i] = key
+			}
+			keys[table.TableName+"|"+chain.ChainName] = chainKeys
+		}
+	}
+	return keys
+}
+
+// ruleNodeID 规则节点ID，由表、链和规则标识组成
+func ruleNodeID(table, chain, key string) string {
+	return fmt.Sprintf("rule_%s_%s_%s", table, chain, key)
+}
+
+// ruleListingSpec 去掉 iptables -L -v --line-numbers 行首的序号和计数器，只保留规则内容
+func ruleListingSpec(ruleText string) string {
+	fields := strings.Fields(ruleText)
+	if len(fields) <= 3 {
+		return strings.Join(fields, " ")
+	}
+	return strings.Join(fields[3:], " ")
+}
+
+// loadIPSetInfo 读取集合概要用于拓扑节点，ipset不可用时返回空表
+func (s *TopologyService) loadIPSetInfo() map[string]models.IPSet {
+	info := make(map[string]models.IPSet)
+	sets, err := s.ipsetService.ListSets()
+	if err != nil {
+		log.Printf("[WARN] Failed to list ipsets for topology: %v", err)
+		return info
+	}
+	for _, set := range sets {
+		info[set.Name] = set
+	}
+	return info
+}
+
 // generateInterfaceRuleLinks 生成网络接口和规则之间的连接
-func (s *TopologyService) generateInterfaceRuleLinks(interfaces []models.NetworkInterface, tables []TableInfo, topology *TopologyData) {
+func (s *TopologyService) generateInterfaceRuleLinks(interfaces []models.NetworkInterface, tables []TableInfo, ruleKeys map[string][]string, topology *TopologyData) {
 	// 为每个规则创建与相关网络接口的连接
 	for _, table := range tables {
 		for _, chain := range table.Chains {
@@ -516,7 +694,7 @@ func (s *TopologyService) generateInterfaceRuleLinks(interfaces []models.Network
 					continue
 				}
 
-				ruleID := fmt.Sprintf("rule_%s_%s_%d", table.TableName, chain.ChainName, i)
+				ruleID := ruleNodeID(table.TableName, chain.ChainName, ruleKeys[table.TableName+"|"+chain.ChainName][i])
 
 				// 提取规则中的接口信息
 				inInterface := s.extractInterface(rule.RuleText, "-i")
@@ -828,6 +1006,11 @@ func (s *TopologyService) isImportantRule(rule RuleInfo) bool {
 		return true
 	}
 
+	// 显示引用ipset的规则
+	if strings.Contains(rule.RuleText, "match-set") {
+		return true
+	}
+
 	// 显示有端口指定的规则
 	if strings.Contains(rule.RuleText, "--dport") || strings.Contains(rule.RuleText, "--sport") {
 		return true
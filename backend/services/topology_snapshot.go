@@ -0,0 +1,343 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+
+	"iptables-management-backend/config"
+	"iptables-management-backend/models"
+	"iptables-management-backend/utils"
+)
+
+const (
+	SnapshotTriggerPeriodic = "periodic"
+	SnapshotTriggerManual   = "manual"
+)
+
+// TopologySnapshotDetail 快照及其拓扑数据
+type TopologySnapshotDetail struct {
+	models.TopologySnapshot
+	Topology *TopologyData `json:"topology"`
+}
+
+// TopologyDiff 两个拓扑之间的差异
+type TopologyDiff struct {
+	From           *models.TopologySnapshot `json:"from,omitempty"`
+	To             *models.TopologySnapshot `json:"to,omitempty"`
+	NodesAdded     []TopologyNode           `json:"nodes_added"`
+	NodesRemoved   []TopologyNode           `json:"nodes_removed"`
+	NodesChanged   []TopologyNodeChange     `json:"nodes_changed"`
+	LinksAdded     []TopologyLink           `json:"links_added"`
+	LinksRemoved   []TopologyLink           `json:"links_removed"`
+	LinksChanged   []TopologyLinkChange     `json:"links_changed"`
+	CounterChanges []TopologyCounterChange  `json:"counter_changes"`
+	HasChanges     bool                     `json:"has_changes"`
+}
+
+// TopologyNodeChange 节点属性变化（不含计数器）
+type TopologyNodeChange struct {
+	ID     string               `json:"id"`
+	Label  string               `json:"label"`
+	Fields map[string][2]string `json:"fields"` // 字段名 -> [旧值, 新值]
+}
+
+// TopologyLinkChange 连接属性变化
+type TopologyLinkChange struct {
+	ID     string               `json:"id"`
+	Fields map[string][2]string `json:"fields"`
+}
+
+// TopologyCounterChange 节点计数器变化
+type TopologyCounterChange struct {
+	ID            string `json:"id"`
+	Label         string `json:"label"`
+	PacketsBefore string `json:"packets_before"`
+	PacketsAfter  string `json:"packets_after"`
+	BytesBefore   string `json:"bytes_before"`
+	BytesAfter    string `json:"bytes_after"`
+	PacketsDelta  int64  `json:"packets_delta"`
+	BytesDelta    int64  `json:"bytes_delta"`
+}
+
+// StartSnapshotScheduler 定期保存拓扑快照并清理超过保留期的快照
+func (s *TopologyService) StartSnapshotScheduler(interval, retention time.Duration) {
+	go func() {
+		log.Printf("[INFO] Topology snapshot scheduler started, interval %v, retention %v", interval, retention)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := s.CreateSnapshot(SnapshotTriggerPeriodic, "", "system"); err != nil {
+				log.Printf("[ERROR] Failed to create periodic topology snapshot: %v", err)
+			}
+			if err := s.PruneSnapshots(retention); err != nil {
+				log.Printf("[ERROR] Failed to prune topology snapshots: %v", err)
+			}
+		}
+	}()
+}
+
+// CreateSnapshot 生成最新拓扑并保存为快照
+func (s *TopologyService) CreateSnapshot(trigger, note, username string) (*models.TopologySnapshot, error) {
+	// 快照需要反映当前状态，不使用缓存
+	s.InvalidateCache()
+	topology, err := s.GetTopologyData()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(topology)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode topology: %v", err)
+	}
+
+	snapshot := &models.TopologySnapshot{
+		Trigger:   trigger,
+		Note:      note,
+		CreatedBy: username,
+		NodeCount: len(topology.Nodes),
+		LinkCount: len(topology.Links),
+		Data:      string(data),
+	}
+	if err := config.DB.Create(snapshot).Error; err != nil {
+		return nil, fmt.Errorf("failed to save topology snapshot: %v", err)
+	}
+
+	log.Printf("[DEBUG] Saved topology snapshot %d (%s): %d nodes, %d links",
+		snapshot.ID, trigger, snapshot.NodeCount, snapshot.LinkCount)
+	return snapshot, nil
+}
+
+// ListSnapshots 列出时间范围内的快照（不含拓扑数据），按时间倒序
+func (s *TopologyService) ListSnapshots(from, to time.Time, limit int) ([]models.TopologySnapshot, error) {
+	query := config.DB.Omit("data").Order("created_at DESC")
+	if !from.IsZero() {
+		query = query.Where("created_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("created_at <= ?", to)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var snapshots []models.TopologySnapshot
+	if err := query.Find(&snapshots).Error; err != nil {
+		return nil, fmt.Errorf("failed to list topology snapshots: %v", err)
+	}
+	return snapshots, nil
+}
+
+// GetSnapshot 获取快照及其拓扑数据
+func (s *TopologyService) GetSnapshot(id uint) (*TopologySnapshotDetail, error) {
+	var snapshot models.TopologySnapshot
+	if err := config.DB.First(&snapshot, id).Error; err != nil {
+		return nil, fmt.Errorf("topology snapshot %d not found: %v", id, err)
+	}
+
+	var topology TopologyData
+	if err := json.Unmarshal([]byte(snapshot.Data), &topology); err != nil {
+		return nil, fmt.Errorf("failed to decode topology snapshot %d: %v", id, err)
+	}
+	return &TopologySnapshotDetail{TopologySnapshot: snapshot, Topology: &topology}, nil
+}
+
+// DeleteSnapshot 删除快照
+func (s *TopologyService) DeleteSnapshot(id uint) error {
+	result := config.DB.Delete(&models.TopologySnapshot{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete topology snapshot: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("topology snapshot %d not found", id)
+	}
+	return nil
+}
+
+// PruneSnapshots 清理超过保留期的定期快照（手动快照保留）
+func (s *TopologyService) PruneSnapshots(retention time.Duration) error {
+	return config.DB.Where("trigger_type = ? AND created_at < ?", SnapshotTriggerPeriodic, time.Now().Add(-retention)).
+		Delete(&models.TopologySnapshot{}).Error
+}
+
+// DiffSnapshots 比较两个快照；toID 为0时与当前拓扑比较
+func (s *TopologyService) DiffSnapshots(fromID, toID uint) (*TopologyDiff, error) {
+	from, err := s.GetSnapshot(fromID)
+	if err != nil {
+		return nil, err
+	}
+
+	var toSnapshot *models.TopologySnapshot
+	var toTopology *TopologyData
+	if toID == 0 {
+		s.InvalidateCache()
+		if toTopology, err = s.GetTopologyData(); err != nil {
+			return nil, err
+		}
+	} else {
+		to, err := s.GetSnapshot(toID)
+		if err != nil {
+			return nil, err
+		}
+		toSnapshot = &to.TopologySnapshot
+		toTopology = to.Topology
+	}
+
+	diff := DiffTopology(from.Topology, toTopology)
+	diff.From = &from.TopologySnapshot
+	diff.To = toSnapshot
+	return diff, nil
+}
+
+// DiffTopology 比较两个拓扑：新增/删除的节点和连接、属性变化和计数器变化
+func DiffTopology(before, after *TopologyData) *TopologyDiff {
+	diff := &TopologyDiff{
+		NodesAdded:     []TopologyNode{},
+		NodesRemoved:   []TopologyNode{},
+		NodesChanged:   []TopologyNodeChange{},
+		LinksAdded:     []TopologyLink{},
+		LinksRemoved:   []TopologyLink{},
+		LinksChanged:   []TopologyLinkChange{},
+		CounterChanges: []TopologyCounterChange{},
+	}
+
+	beforeNodes := make(map[string]TopologyNode, len(before.Nodes))
+	for _, node := range before.Nodes {
+		beforeNodes[node.ID] = node
+	}
+	afterNodes := make(map[string]bool, len(after.Nodes))
+	for _, node := range after.Nodes {
+		afterNodes[node.ID] = true
+		old, ok := beforeNodes[node.ID]
+		if !ok {
+			diff.NodesAdded = append(diff.NodesAdded, node)
+			continue
+		}
+
+		if old.Packets != node.Packets || old.Bytes != node.Bytes {
+			diff.CounterChanges = append(diff.CounterChanges, TopologyCounterChange{
+				ID:            node.ID,
+				Label:         node.Label,
+				PacketsBefore: old.Packets,
+				PacketsAfter:  node.Packets,
+				BytesBefore:   old.Bytes,
+				BytesAfter:    node.Bytes,
+				PacketsDelta:  int64(utils.ParsePacketCount(node.Packets)) - int64(utils.ParsePacketCount(old.Packets)),
+				BytesDelta:    int64(utils.ParsePacketCount(node.Bytes)) - int64(utils.ParsePacketCount(old.Bytes)),
+			})
+		}
+
+		if fields := diffNodeFields(old, node); len(fields) > 0 {
+			diff.NodesChanged = append(diff.NodesChanged, TopologyNodeChange{ID: node.ID, Label: node.Label, Fields: fields})
+		}
+	}
+	for _, node := range before.Nodes {
+		if !afterNodes[node.ID] {
+			diff.NodesRemoved = append(diff.NodesRemoved, node)
+		}
+	}
+
+	beforeLinks := make(map[string]TopologyLink, len(before.Links))
+	for _, link := range before.Links {
+		beforeLinks[link.ID] = link
+	}
+	afterLinks := make(map[string]bool, len(after.Links))
+	for _, link := range after.Links {
+		afterLinks[link.ID] = true
+		old, ok := beforeLinks[link.ID]
+		if !ok {
+			diff.LinksAdded = append(diff.LinksAdded, link)
+			continue
+		}
+		if fields := diffLinkFields(old, link); len(fields) > 0 {
+			diff.LinksChanged = append(diff.LinksChanged, TopologyLinkChange{ID: link.ID, Fields: fields})
+		}
+	}
+	for _, link := range before.Links {
+		if !afterLinks[link.ID] {
+			diff.LinksRemoved = append(diff.LinksRemoved, link)
+		}
+	}
+
+	sort.Slice(diff.CounterChanges, func(i, j int) bool {
+		return diff.CounterChanges[i].BytesDelta > diff.CounterChanges[j].BytesDelta
+	})
+
+	diff.HasChanges = len(diff.NodesAdded) > 0 || len(diff.NodesRemoved) > 0 || len(diff.NodesChanged) > 0 ||
+		len(diff.LinksAdded) > 0 || len(diff.LinksRemoved) > 0 || len(diff.LinksChanged) > 0 ||
+		len(diff.CounterChanges) > 0
+	return diff
+}
+
+// diffNodeFields 比较节点字段（计数器、布局位置和规则序号除外，规则节点按规则内容标识，
+// 前面插入或删除规则只改变序号，不应报告为变化）
+func diffNodeFields(old, current TopologyNode) map[string][2]string {
+	fields := make(map[string][2]string)
+	values := map[string][2]string{
+		"label":          {old.Label, current.Label},
+		"type":           {old.Type, current.Type},
+		"interface_type": {old.InterfaceType, current.InterfaceType},
+		"table_name":     {old.TableName, current.TableName},
+		"chain_name":     {old.ChainName, current.ChainName},
+		"policy":         {old.Policy, current.Policy},
+		"rule_count":     {fmt.Sprint(old.RuleCount), fmt.Sprint(current.RuleCount)},
+	}
+	for name, pair := range values {
+		if pair[0] != pair[1] {
+			fields[name] = pair
+		}
+	}
+	diffProperties(old.Properties, current.Properties, fields)
+	return fields
+}
+
+// diffLinkFields 比较连接字段（规则序号和规则文本中的计数器除外）
+func diffLinkFields(old, current TopologyLink) map[string][2]string {
+	fields := make(map[string][2]string)
+	values := map[string][2]string{
+		"source":    {old.Source, current.Source},
+		"target":    {old.Target, current.Target},
+		"type":      {old.Type, current.Type},
+		"label":     {old.Label, current.Label},
+		"rule_text": {ruleListingSpec(old.RuleText), ruleListingSpec(current.RuleText)},
+		"action":    {old.Action, current.Action},
+		"protocol":  {old.Protocol, current.Protocol},
+		"port":      {old.Port, current.Port},
+	}
+	for name, pair := range values {
+		if pair[0] != pair[1] {
+			fields[name] = pair
+		}
+	}
+	diffProperties(old.Properties, current.Properties, fields)
+	return fields
+}
+
+// positionalProperties 随规则位置变化的扩展属性，比较时忽略
+var positionalProperties = map[string]bool{"line_number": true, "rule_number": true}
+
+// diffProperties 比较扩展属性，结果以 "properties.<name>" 记录
+func diffProperties(old, current map[string]string, fields map[string][2]string) {
+	if reflect.DeepEqual(old, current) {
+		return
+	}
+	for key, value := range current {
+		if positionalProperties[key] {
+			continue
+		}
+		if old[key] != value {
+			fields["properties."+key] = [2]string{old[key], value}
+		}
+	}
+	for key, value := range old {
+		if positionalProperties[key] {
+			continue
+		}
+		if _, ok := current[key]; !ok {
+			fields["properties."+key] = [2]string{value, ""}
+		}
+	}
+}
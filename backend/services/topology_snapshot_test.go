@@ -0,0 +1,134 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDiffTopology(t *testing.T) {
+	before := &TopologyData{
+		Nodes: []TopologyNode{
+			{ID: "interface_eth0", Label: "eth0", Type: "interface"},
+			{ID: "rule_1", Label: "allow ssh", Type: "rule", Packets: "1K", Bytes: "60K", Properties: map[string]string{"protocol": "tcp"}},
+			{ID: "rule_2", Label: "drop all", Type: "rule", Packets: "0", Bytes: "0"},
+		},
+		Links: []TopologyLink{
+			{ID: "link_1", Source: "interface_eth0", Target: "rule_1", Action: "ACCEPT"},
+			{ID: "link_2", Source: "interface_eth0", Target: "rule_2", Action: "DROP"},
+		},
+	}
+	after := &TopologyData{
+		Nodes: []TopologyNode{
+			{ID: "interface_eth0", Label: "eth0", Type: "interface"},
+			{ID: "rule_1", Label: "allow ssh", Type: "rule", Packets: "1.5K", Bytes: "90K", Properties: map[string]string{"protocol": "udp"}},
+			{ID: "rule_3", Label: "allow web", Type: "rule"},
+		},
+		Links: []TopologyLink{
+			{ID: "link_1", Source: "interface_eth0", Target: "rule_1", Action: "REJECT"},
+			{ID: "link_3", Source: "interface_eth0", Target: "rule_3", Action: "ACCEPT"},
+		},
+	}
+
+	diff := DiffTopology(before, after)
+
+	if !diff.HasChanges {
+		t.Fatalf("expected changes")
+	}
+	if len(diff.NodesAdded) != 1 || diff.NodesAdded[0].ID != "rule_3" {
+		t.Errorf("unexpected added nodes: %+v", diff.NodesAdded)
+	}
+	if len(diff.NodesRemoved) != 1 || diff.NodesRemoved[0].ID != "rule_2" {
+		t.Errorf("unexpected removed nodes: %+v", diff.NodesRemoved)
+	}
+	if len(diff.NodesChanged) != 1 || diff.NodesChanged[0].Fields["properties.protocol"] != [2]string{"tcp", "udp"} {
+		t.Errorf("unexpected changed nodes: %+v", diff.NodesChanged)
+	}
+	if len(diff.LinksAdded) != 1 || len(diff.LinksRemoved) != 1 {
+		t.Errorf("expected 1 added and 1 removed link, got %d and %d", len(diff.LinksAdded), len(diff.LinksRemoved))
+	}
+	if len(diff.LinksChanged) != 1 || diff.LinksChanged[0].Fields["action"] != [2]string{"ACCEPT", "REJECT"} {
+		t.Errorf("unexpected changed links: %+v", diff.LinksChanged)
+	}
+
+	if len(diff.CounterChanges) != 1 {
+		t.Fatalf("expected 1 counter change, got %d", len(diff.CounterChanges))
+	}
+	change := diff.CounterChanges[0]
+	if change.PacketsDelta != 500 || change.BytesDelta != 30000 {
+		t.Errorf("unexpected counter deltas: %+v", change)
+	}
+}
+
+func TestDiffTopologyNoChanges(t *testing.T) {
+	topology := &TopologyData{
+		Nodes: []TopologyNode{{ID: "interface_lo", Label: "lo", Type: "interface"}},
+	}
+	if diff := DiffTopology(topology, topology); diff.HasChanges {
+		t.Errorf("expected no changes, got %+v", diff)
+	}
+}
+
+func TestBuildRuleNodeKeysStableAcrossInsert(t *testing.T) {
+	listing := func(lines ...string) []TableInfo {
+		chain := ChainInfo{ChainName: "INPUT"}
+		for i, line := range lines {
+			chain.Rules = append(chain.Rules, RuleInfo{LineNumber: i + 1, RuleText: fmt.Sprintf("%d 0 0 %s", i+1, line)})
+		}
+		return []TableInfo{{TableName: "filter", Chains: []ChainInfo{chain}}}
+	}
+	ssh := "ACCEPT tcp -- * * 0.0.0.0/0 0.0.0.0/0 tcp dpt:22"
+	web := "ACCEPT tcp -- * * 0.0.0.0/0 0.0.0.0/0 tcp dpt:80"
+	dns := "ACCEPT udp -- * * 0.0.0.0/0 0.0.0.0/0 udp dpt:53"
+
+	before, _ := ParseIPTablesSave("*filter\n:INPUT ACCEPT [0:0]\n" +
+		"[5:300] -A INPUT -p tcp -m tcp --dport 22 -j ACCEPT\n" +
+		"[0:0] -A INPUT -p tcp -m tcp --dport 80 -j ACCEPT\nCOMMIT\n")
+	after, _ := ParseIPTablesSave("*filter\n:INPUT ACCEPT [0:0]\n" +
+		"[0:0] -A INPUT -p udp -m udp --dport 53 -j ACCEPT\n" +
+		"[9:540] -A INPUT -p tcp -m tcp --dport 22 -j ACCEPT\n" +
+		"[0:0] -A INPUT -p tcp -m tcp --dport 80 -j ACCEPT\nCOMMIT\n")
+
+	for name, rulesets := range map[string][2]*SavedRuleset{
+		"saved":   {before, after},
+		"listing": {nil, nil},
+	} {
+		old := buildRuleNodeKeys(listing(ssh, web), rulesets[0])["filter|INPUT"]
+		current := buildRuleNodeKeys(listing(dns, ssh, web), rulesets[1])["filter|INPUT"]
+		if len(old) != 2 || len(current) != 3 {
+			t.Fatalf("%s: unexpected keys %v / %v", name, old, current)
+		}
+		if old[0] != current[1] || old[1] != current[2] {
+			t.Errorf("%s: inserting a rule changed existing keys: %v -> %v", name, old, current)
+		}
+		if current[0] == current[1] || current[0] == current[2] {
+			t.Errorf("%s: inserted rule reused an existing key: %v", name, current)
+		}
+	}
+
+	duplicates := buildRuleNodeKeys(listing(ssh, ssh), nil)["filter|INPUT"]
+	if duplicates[0] == duplicates[1] {
+		t.Errorf("identical rules should get distinct keys: %v", duplicates)
+	}
+}
+
+func TestDiffTopologyRuleInserted(t *testing.T) {
+	ruleNode := func(key string, number int) TopologyNode {
+		return TopologyNode{
+			ID:         ruleNodeID("filter", "INPUT", key),
+			Label:      key,
+			Type:       "rule",
+			RuleNumber: number,
+			Properties: map[string]string{"rule_key": key, "line_number": fmt.Sprint(number)},
+		}
+	}
+	before := &TopologyData{Nodes: []TopologyNode{ruleNode("ssh", 1), ruleNode("web", 2)}}
+	after := &TopologyData{Nodes: []TopologyNode{ruleNode("dns", 1), ruleNode("ssh", 2), ruleNode("web", 3)}}
+
+	diff := DiffTopology(before, after)
+	if len(diff.NodesAdded) != 1 || diff.NodesAdded[0].Label != "dns" {
+		t.Errorf("expected only the inserted rule to be added, got %+v", diff.NodesAdded)
+	}
+	if len(diff.NodesRemoved) != 0 || len(diff.NodesChanged) != 0 {
+		t.Errorf("shifted rules should not be reported: removed %+v, changed %+v", diff.NodesRemoved, diff.NodesChanged)
+	}
+}
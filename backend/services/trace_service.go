@@ -0,0 +1,774 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// traceCommentPrefix 跟踪会话安装的 TRACE 规则注释前缀，用于删除和启动时清理残留规则
+	traceCommentPrefix = "iptables-manager-trace:"
+	// traceLogSysctl 内核日志方式（iptables-legacy）输出 TRACE 需要为 IPv4 注册日志后端
+	traceLogSysctl = "net.netfilter.nf_log.2"
+
+	defaultTraceDuration  = 10 * time.Second
+	maxTraceDuration      = 120 * time.Second
+	defaultTraceMaxEvents = 500
+	maxTraceMaxEvents     = 5000
+	maxTraceSessions      = 20
+)
+
+// TraceFilter 跟踪的数据包过滤条件（五元组和入接口），至少需要一个地址、端口或接口条件
+type TraceFilter struct {
+	Protocol    string `json:"protocol"` // all, tcp, udp, icmp，默认 all
+	Source      string `json:"source,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	SourcePort  int    `json:"source_port,omitempty"`
+	DestPort    int    `json:"dest_port,omitempty"`
+	InInterface string `json:"in_interface,omitempty"` // 指定时只跟踪进入的流量，不跟踪本机发出的流量
+}
+
+// TraceRequest 创建跟踪会话的请求
+type TraceRequest struct {
+	TraceFilter
+	Duration  int `json:"duration"`   // 秒，默认10，最长120
+	MaxEvents int `json:"max_events"` // 达到后提前结束，默认500，最多5000
+}
+
+// TraceEvent 内核报告的一条跟踪记录：数据包经过的一条规则、链返回或默认策略
+type TraceEvent struct {
+	Time         time.Time `json:"time"`
+	PacketID     string    `json:"packet_id"`
+	Table        string    `json:"table"`
+	Chain        string    `json:"chain"`
+	Type         string    `json:"type"` // rule, return, policy
+	Position     int       `json:"position,omitempty"`
+	Spec         string    `json:"spec,omitempty"`
+	Verdict      string    `json:"verdict,omitempty"`
+	InInterface  string    `json:"in_interface,omitempty"`
+	OutInterface string    `json:"out_interface,omitempty"`
+	Protocol     string    `json:"protocol,omitempty"`
+	Source       string    `json:"source,omitempty"`
+	Destination  string    `json:"destination,omitempty"`
+	SourcePort   int       `json:"source_port,omitempty"`
+	DestPort     int       `json:"dest_port,omitempty"`
+}
+
+// TracePacket 一个数据包实际经过的规则路径
+type TracePacket struct {
+	ID          string       `json:"id"`
+	Protocol    string       `json:"protocol,omitempty"`
+	Source      string       `json:"source,omitempty"`
+	Destination string       `json:"destination,omitempty"`
+	SourcePort  int          `json:"source_port,omitempty"`
+	DestPort    int          `json:"dest_port,omitempty"`
+	InInterface string       `json:"in_interface,omitempty"`
+	Verdict     string       `json:"verdict,omitempty"` // 最后一个终结裁决
+	Steps       []TraceEvent `json:"steps"`
+}
+
+// TraceSession 一次有时限的跟踪会话
+type TraceSession struct {
+	ID         string        `json:"id"`
+	Backend    string        `json:"backend"` // legacy（内核日志）或 nft（xtables-monitor --trace）
+	Filter     TraceFilter   `json:"filter"`
+	Rules      []string      `json:"rules"`  // 安装的 TRACE 规则
+	Status     string        `json:"status"` // running, completed, stopped, failed
+	Error      string        `json:"error,omitempty"`
+	Warnings   []string      `json:"warnings,omitempty"`
+	MaxEvents  int           `json:"max_events"`
+	Truncated  bool          `json:"truncated"`
+	Events     []TraceEvent  `json:"events"`
+	Packets    []TracePacket `json:"packets"`
+	CreatedBy  string        `json:"created_by"`
+	StartedAt  time.Time     `json:"started_at"`
+	EndsAt     time.Time     `json:"ends_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+
+	ruleArgs [][]string
+	cancel   context.CancelFunc
+	stopped  bool
+	done     chan struct{}
+}
+
+// TraceService 基于内核 TRACE 目标的实时数据包跟踪服务，同一时间只允许一个会话
+type TraceService struct {
+	mutex    sync.Mutex
+	sessions map[string]*TraceSession
+	order    []string
+}
+
+// NewTraceService 创建跟踪服务实例
+func NewTraceService() *TraceService {
+	return &TraceService{
+		sessions: make(map[string]*TraceSession),
+	}
+}
+
+// StartSession 安装 TRACE 规则并在后台收集跟踪记录，到期、达到记录上限或被停止时自动删除规则
+func (s *TraceService) StartSession(request TraceRequest, username string) (*TraceSession, error) {
+	log.Printf("[DEBUG] TraceService.StartSession called with request: %+v", request)
+
+	duration, maxEvents, err := normalizeTraceRequest(&request)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, existing := range s.sessions {
+		if existing.Status == "running" {
+			return nil, conflictf("trace session %s is already running", existing.ID)
+		}
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	session := &TraceSession{
+		ID:        hex.EncodeToString(id),
+		Backend:   detectIPTablesBackend(),
+		Filter:    request.TraceFilter,
+		Status:    "running",
+		MaxEvents: maxEvents,
+		Events:    []TraceEvent{},
+		Packets:   []TracePacket{},
+		CreatedBy: username,
+		done:      make(chan struct{}),
+	}
+	session.ruleArgs = traceRuleArgs(session.Filter, traceCommentPrefix+session.ID)
+	for _, args := range session.ruleArgs {
+		session.Rules = append(session.Rules, "iptables "+JoinRuleArgs(args))
+	}
+
+	var restoreLog func()
+	if session.Backend == "legacy" {
+		var warning string
+		restoreLog, warning = enableTraceLogging()
+		if warning != "" {
+			session.Warnings = append(session.Warnings, warning)
+		}
+	}
+
+	// 先开始读取再安装规则，避免丢失最早的记录
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	lines, err := openTraceSource(ctx, session.Backend)
+	if err != nil {
+		cancel()
+		if restoreLog != nil {
+			restoreLog()
+		}
+		return nil, err
+	}
+	installed, err := installTraceRules(session.ruleArgs)
+	if err != nil {
+		cancel()
+		if restoreLog != nil {
+			restoreLog()
+		}
+		return nil, err
+	}
+
+	// 规则安装后获取快照，用于把记录中的规则序号或规则内容对应到规则
+	ruleset, err := LoadSavedRuleset()
+	if err != nil {
+		session.Warnings = append(session.Warnings, "无法获取规则集快照，记录中将缺少规则内容: "+err.Error())
+	}
+
+	session.cancel = cancel
+	session.StartedAt = time.Now()
+	session.EndsAt = session.StartedAt.Add(duration)
+	s.store(session)
+
+	go s.collect(ctx, session, lines, newTraceParser(session.Backend, ruleset), func() {
+		removeTraceRules(installed)
+		if restoreLog != nil {
+			restoreLog()
+		}
+	})
+
+	log.Printf("[INFO] Trace session %s started by %s (%s backend, %s): %v", session.ID, username, session.Backend, duration, session.Rules)
+	return session.snapshot(), nil
+}
+
+// GetSession 获取会话当前状态（运行中的会话包含已收集的记录）
+func (s *TraceService) GetSession(id string) (*TraceSession, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, notFoundf("trace session %s not found", id)
+	}
+	return session.snapshot(), nil
+}
+
+// WaitSession 等待会话结束并返回结果
+func (s *TraceService) WaitSession(id string) (*TraceSession, error) {
+	s.mutex.Lock()
+	session, ok := s.sessions[id]
+	s.mutex.Unlock()
+	if !ok {
+		return nil, notFoundf("trace session %s not found", id)
+	}
+	<-session.done
+	return s.GetSession(id)
+}
+
+// ListSessions 获取最近的会话（最新在前，不含记录明细）
+func (s *TraceService) ListSessions() []TraceSession {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	sessions := make([]TraceSession, 0, len(s.order))
+	for i := len(s.order) - 1; i >= 0; i-- {
+		session := s.sessions[s.order[i]].snapshot()
+		session.Events = nil
+		session.Packets = nil
+		sessions = append(sessions, *session)
+	}
+	return sessions
+}
+
+// StopSession 提前结束运行中的会话
+func (s *TraceService) StopSession(id string) (*TraceSession, error) {
+	s.mutex.Lock()
+	session, ok := s.sessions[id]
+	if ok && session.Status == "running" {
+		session.stopped = true
+		session.cancel()
+	}
+	s.mutex.Unlock()
+	if !ok {
+		return nil, notFoundf("trace session %s not found", id)
+	}
+	return s.WaitSession(id)
+}
+
+// CleanupStaleRules 删除之前进程退出时遗留的 TRACE 规则
+func (s *TraceService) CleanupStaleRules() {
+	ruleset, err := LoadSavedRuleset()
+	if err != nil {
+		log.Printf("[WARN] Failed to check for stale trace rules: %v", err)
+		return
+	}
+	table := ruleset.Table("raw")
+	if table == nil {
+		return
+	}
+	var stale [][]string
+	for _, rule := range table.Rules {
+		if strings.HasPrefix(rule.Match.Comment, traceCommentPrefix) {
+			stale = append(stale, append([]string{"-t", "raw", "-A", rule.Chain}, SplitRuleArgs(rule.Spec)...))
+		}
+	}
+	if len(stale) > 0 {
+		log.Printf("[INFO] Removing %d stale trace rules", len(stale))
+		removeTraceRules(stale)
+	}
+}
+
+// store 保存会话，只保留最近的会话
+func (s *TraceService) store(session *TraceSession) {
+	s.sessions[session.ID] = session
+	s.order = append(s.order, session.ID)
+	for len(s.order) > maxTraceSessions {
+		if oldest := s.sessions[s.order[0]]; oldest.Status == "running" {
+			break
+		}
+		delete(s.sessions, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// collect 收集跟踪记录直到会话结束，随后执行清理并按数据包汇总路径
+func (s *TraceService) collect(ctx context.Context, session *TraceSession, lines <-chan string, parser *traceParser, cleanup func()) {
+	defer close(session.done)
+
+	truncated := false
+	for !truncated {
+		line, ok := "", false
+		select {
+		case <-ctx.Done():
+		case line, ok = <-lines:
+		}
+		if !ok {
+			break
+		}
+		event, ok := parser.parse(line, time.Now())
+		if !ok {
+			continue
+		}
+		s.mutex.Lock()
+		session.Events = append(session.Events, event)
+		truncated = len(session.Events) >= session.MaxEvents
+		s.mutex.Unlock()
+	}
+	// 未到期、未停止且未达到上限时来源提前关闭，视为失败
+	sourceClosed := ctx.Err() == nil && !truncated
+	session.cancel()
+	cleanup()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	session.FinishedAt = &now
+	session.Truncated = truncated
+	session.Packets = groupTracePackets(session.Events)
+	switch {
+	case session.stopped:
+		session.Status = "stopped"
+	case sourceClosed:
+		session.Status = "failed"
+		session.Error = "trace source closed unexpectedly"
+	default:
+		session.Status = "completed"
+	}
+	log.Printf("[INFO] Trace session %s %s: %d events, %d packets", session.ID, session.Status, len(session.Events), len(session.Packets))
+}
+
+// snapshot 会话的副本（调用方需持有锁）
+func (session *TraceSession) snapshot() *TraceSession {
+	copied := *session
+	copied.Events = append([]TraceEvent{}, session.Events...)
+	copied.Packets = append([]TracePacket{}, session.Packets...)
+	copied.Warnings = append([]string(nil), session.Warnings...)
+	return &copied
+}
+
+// normalizeTraceRequest 校验过滤条件并返回会话时长和记录上限
+func normalizeTraceRequest(request *TraceRequest) (time.Duration, int, error) {
+	filter := &request.TraceFilter
+	filter.Protocol = strings.ToLower(filter.Protocol)
+	if filter.Protocol == "" {
+		filter.Protocol = "all"
+	}
+	switch filter.Protocol {
+	case "all", "tcp", "udp", "icmp":
+	default:
+		return 0, 0, invalidf("invalid protocol %s", filter.Protocol)
+	}
+	for _, address := range []*string{&filter.Source, &filter.Destination} {
+		*address = strings.TrimSpace(*address)
+		if *address == "" {
+			continue
+		}
+		if ip := net.ParseIP(*address); ip != nil && ip.To4() != nil {
+			continue
+		}
+		if ip, network, err := net.ParseCIDR(*address); err == nil && ip.To4() != nil {
+			*address = network.String()
+			continue
+		}
+		return 0, 0, invalidf("invalid address %s", *address)
+	}
+	for _, port := range []int{filter.SourcePort, filter.DestPort} {
+		if port < 0 || port > 65535 {
+			return 0, 0, invalidf("invalid port %d", port)
+		}
+		if port > 0 && filter.Protocol != "tcp" && filter.Protocol != "udp" {
+			return 0, 0, invalidf("invalid filter: ports require protocol tcp or udp")
+		}
+	}
+	if filter.InInterface != "" && !isValidInterfaceName(filter.InInterface) {
+		return 0, 0, invalidf("invalid interface %s", filter.InInterface)
+	}
+	// TRACE 会为每条规则产生记录，不允许跟踪全部流量
+	if filter.Source == "" && filter.Destination == "" && filter.SourcePort == 0 && filter.DestPort == 0 && filter.InInterface == "" {
+		return 0, 0, invalidf("invalid filter: at least one of source, destination, port or in_interface is required")
+	}
+
+	duration := defaultTraceDuration
+	if request.Duration < 0 || time.Duration(request.Duration)*time.Second > maxTraceDuration {
+		return 0, 0, invalidf("invalid duration %d: must be between 1 and %d seconds", request.Duration, int(maxTraceDuration.Seconds()))
+	} else if request.Duration > 0 {
+		duration = time.Duration(request.Duration) * time.Second
+	}
+	maxEvents := defaultTraceMaxEvents
+	if request.MaxEvents < 0 || request.MaxEvents > maxTraceMaxEvents {
+		return 0, 0, invalidf("invalid max_events %d: must be between 1 and %d", request.MaxEvents, maxTraceMaxEvents)
+	} else if request.MaxEvents > 0 {
+		maxEvents = request.MaxEvents
+	}
+	return duration, maxEvents, nil
+}
+
+// isValidInterfaceName 接口名只允许内核接受的字符（支持 iptables 的 + 通配）
+func isValidInterfaceName(name string) bool {
+	if len(name) > 15 {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.@+", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// traceRuleArgs 生成 raw 表的 TRACE 规则：PREROUTING 跟踪进入的流量，未指定入接口时 OUTPUT 跟踪本机发出的流量
+func traceRuleArgs(filter TraceFilter, comment string) [][]string {
+	match := func(withInterface bool) []string {
+		var args []string
+		if withInterface && filter.InInterface != "" {
+			args = append(args, "-i", filter.InInterface)
+		}
+		if filter.Source != "" {
+			args = append(args, "-s", filter.Source)
+		}
+		if filter.Destination != "" {
+			args = append(args, "-d", filter.Destination)
+		}
+		if filter.Protocol != "all" {
+			args = append(args, "-p", filter.Protocol)
+		}
+		if filter.SourcePort > 0 {
+			args = append(args, "--sport", strconv.Itoa(filter.SourcePort))
+		}
+		if filter.DestPort > 0 {
+			args = append(args, "--dport", strconv.Itoa(filter.DestPort))
+		}
+		return append(args, "-m", "comment", "--comment", comment, "-j", "TRACE")
+	}
+
+	rules := [][]string{append([]string{"-t", "raw", "-I", "PREROUTING", "1"}, match(true)...)}
+	if filter.InInterface == "" {
+		rules = append(rules, append([]string{"-t", "raw", "-I", "OUTPUT", "1"}, match(false)...))
+	}
+	return rules
+}
+
+// installTraceRules 安装 TRACE 规则，失败时撤销已安装的规则；返回用于删除的参数
+func installTraceRules(rules [][]string) ([][]string, error) {
+	var installed [][]string
+	for _, args := range rules {
+		if _, err := runCommand("iptables", args...); err != nil {
+			removeTraceRules(installed)
+			return nil, fmt.Errorf("failed to install trace rule: %s", commandError(err))
+		}
+		installed = append(installed, args)
+	}
+	return installed, nil
+}
+
+// removeTraceRules 删除 TRACE 规则
+func removeTraceRules(rules [][]string) {
+	for _, args := range rules {
+		deleteArgs := traceDeleteArgs(args)
+		if _, err := runCommand("iptables", deleteArgs...); err != nil {
+			log.Printf("[ERROR] Failed to remove trace rule %v: %s", deleteArgs, commandError(err))
+		}
+	}
+}
+
+// traceDeleteArgs 把 -t <table> -I <chain> <pos> 或 -t <table> -A <chain> 形式的参数转换为 -D <chain>
+func traceDeleteArgs(args []string) []string {
+	rest := args[4:]
+	if args[2] == "-I" {
+		rest = rest[1:]
+	}
+	return append([]string{args[0], args[1], "-D", args[3]}, rest...)
+}
+
+// detectIPTablesBackend 判断 iptables 使用的后端：nf_tables 后端的 TRACE 通过 nftrace 报告，legacy 后端写入内核日志
+func detectIPTablesBackend() string {
+	output, err := runCommand("iptables", "-V")
+	if err == nil && strings.Contains(string(output), "nf_tables") {
+		return "nft"
+	}
+	return "legacy"
+}
+
+// enableTraceLogging legacy 后端需要注册 IPv4 日志后端 TRACE 才会写入内核日志；返回恢复原设置的函数
+func enableTraceLogging() (func(), string) {
+	output, err := runCommand("sysctl", "-n", traceLogSysctl)
+	if err != nil {
+		return nil, "无法读取 " + traceLogSysctl + "，TRACE 记录可能不会写入内核日志"
+	}
+	previous := strings.TrimSpace(string(output))
+	if previous != "NONE" {
+		return nil, ""
+	}
+	if _, err := runCommand("sysctl", "-w", traceLogSysctl+"=nf_log_ipv4"); err != nil {
+		runCommand("modprobe", "nf_log_ipv4")
+		if _, err := runCommand("sysctl", "-w", traceLogSysctl+"=nf_log_ipv4"); err != nil {
+			return nil, "无法启用 nf_log_ipv4 日志后端，TRACE 记录可能不会写入内核日志: " + commandError(err)
+		}
+	}
+	return func() {
+		if _, err := runCommand("sysctl", "-w", traceLogSysctl+"="+previous); err != nil {
+			log.Printf("[WARN] Failed to restore %s: %s", traceLogSysctl, commandError(err))
+		}
+	}, ""
+}
+
+// openTraceSource 打开跟踪记录来源，ctx 结束时关闭：legacy 读取 /dev/kmsg 中的新记录，nft 运行 xtables-monitor --trace
+func openTraceSource(ctx context.Context, backend string) (<-chan string, error) {
+	if backend != "nft" {
+		return followKernelLog(ctx)
+	}
+
+	cmd := exec.CommandContext(ctx, "xtables-monitor", "--trace")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start xtables-monitor: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start xtables-monitor: %v", err)
+	}
+	lines := make(chan string, 256)
+	start := time.Now()
+	go func() {
+		defer close(lines)
+		scanTraceLines(ctx, stdout, lines)
+		err := cmd.Wait()
+		if ctx.Err() != nil {
+			err = nil
+		}
+		recordCommand("xtables-monitor", time.Since(start), err)
+	}()
+	return lines, nil
+}
+
+// scanTraceLines 按行读取命令输出
+func scanTraceLines(ctx context.Context, reader io.Reader, lines chan<- string) {
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		select {
+		case lines <- scanner.Text():
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// traceParser 把内核日志或 xtables-monitor 的输出解析为跟踪记录
+type traceParser struct {
+	backend string
+	ruleset *SavedRuleset
+	packets map[string]TraceEvent // nft: 跟踪ID -> 最近一次 PACKET 行中的数据包头
+}
+
+// newTraceParser 创建解析器，ruleset 为空时记录中不包含规则内容
+func newTraceParser(backend string, ruleset *SavedRuleset) *traceParser {
+	return &traceParser{backend: backend, ruleset: ruleset, packets: make(map[string]TraceEvent)}
+}
+
+// parse 解析一行输出，非跟踪记录返回 false
+func (p *traceParser) parse(line string, now time.Time) (TraceEvent, bool) {
+	if p.backend == "nft" {
+		return p.parseMonitorLine(line, now)
+	}
+	return p.parseKernelLine(line, now)
+}
+
+// parseKernelLine 解析内核日志记录，例如
+// "4,1234,5678,-;TRACE: filter:INPUT:rule:3 IN=eth0 OUT= SRC=10.0.0.2 DST=10.0.0.1 ... PROTO=TCP SPT=40000 DPT=22 ..."
+func (p *traceParser) parseKernelLine(line string, now time.Time) (TraceEvent, bool) {
+	index := strings.Index(line, "TRACE: ")
+	if index < 0 {
+		return TraceEvent{}, false
+	}
+	fields := strings.Fields(strings.SplitN(line[index+len("TRACE: "):], "\n", 2)[0])
+	if len(fields) == 0 {
+		return TraceEvent{}, false
+	}
+	parts := strings.Split(fields[0], ":")
+	if len(parts) != 4 {
+		return TraceEvent{}, false
+	}
+
+	event := TraceEvent{Time: now, Table: parts[0], Chain: parts[1], Type: parts[2]}
+	event.Position, _ = strconv.Atoi(parts[3])
+	ipID := applyTraceFields(&event, fields[1:])
+	// legacy 记录没有跟踪ID，用 NAT 前后不变的协议、源地址、源端口和IP标识区分数据包
+	event.PacketID = fmt.Sprintf("%s/%s:%d/%s", event.Protocol, event.Source, event.SourcePort, ipID)
+
+	switch event.Type {
+	case "rule":
+		if rule := p.ruleAt(event.Table, event.Chain, event.Position); rule != nil {
+			event.Spec = rule.Spec
+			event.Verdict = rule.Match.Target
+		}
+	case "policy":
+		event.Verdict = p.chainPolicy(event.Table, event.Chain)
+		// 内核报告的是策略之前经过的规则数，默认策略不对应规则
+		event.Position = 0
+	case "return":
+		event.Verdict = "RETURN"
+		event.Position = 0
+	}
+	return event, true
+}
+
+// parseMonitorLine 解析 xtables-monitor --trace 的输出，例如
+// "PACKET: 2 1a2b3c4d IN=eth0 SRC=10.0.0.2 DST=10.0.0.1 ... SPORT=40000 DPORT=22"
+// " TRACE: 2 1a2b3c4d filter:INPUT:rule:0x7:ACCEPT  -4 -t filter -A INPUT -i eth0 -p tcp --dport 22 -j ACCEPT"
+func (p *traceParser) parseMonitorLine(line string, now time.Time) (TraceEvent, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return TraceEvent{}, false
+	}
+	id := fields[2]
+
+	switch fields[0] {
+	case "PACKET:":
+		header := TraceEvent{}
+		applyTraceFields(&header, fields[3:])
+		p.packets[id] = header
+		return TraceEvent{}, false
+	case "TRACE:":
+	default:
+		return TraceEvent{}, false
+	}
+	if len(fields) < 4 {
+		return TraceEvent{}, false
+	}
+
+	event := p.packets[id]
+	event.Time, event.PacketID = now, id
+	parts := strings.Split(fields[3], ":")
+	if len(parts) < 3 {
+		return TraceEvent{}, false
+	}
+	event.Table, event.Chain, event.Type = parts[0], parts[1], parts[2]
+	verdicts := parts[3:]
+	if event.Type == "rule" && len(verdicts) > 0 {
+		verdicts = verdicts[1:] // 规则句柄
+	}
+	event.Verdict = strings.ToUpper(strings.Join(verdicts, ":"))
+
+	if event.Type == "rule" {
+		event.Spec = monitorRuleSpec(fields[4:])
+		event.Position = p.rulePosition(event.Table, event.Chain, event.Spec)
+	}
+	return event, true
+}
+
+// monitorRuleSpec 去掉 xtables-monitor 规则前的 -4/-6、-t 表和 -A 链
+func monitorRuleSpec(fields []string) string {
+	for len(fields) > 0 {
+		switch fields[0] {
+		case "-4", "-6":
+			fields = fields[1:]
+		case "-t", "-A":
+			if len(fields) < 2 {
+				return ""
+			}
+			fields = fields[2:]
+		default:
+			return strings.Join(fields, " ")
+		}
+	}
+	return ""
+}
+
+// applyTraceFields 解析 KEY=VALUE 形式的数据包头字段，返回IP标识
+func applyTraceFields(event *TraceEvent, fields []string) string {
+	ipID := ""
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "IN":
+			event.InInterface = value
+		case "OUT":
+			event.OutInterface = value
+		case "SRC":
+			event.Source = value
+		case "DST":
+			event.Destination = value
+		case "PROTO":
+			event.Protocol = strings.ToLower(value)
+		case "SPT", "SPORT":
+			event.SourcePort, _ = strconv.Atoi(value)
+		case "DPT", "DPORT":
+			event.DestPort, _ = strconv.Atoi(value)
+		case "ID":
+			ipID = value
+		}
+	}
+	return ipID
+}
+
+// ruleAt 按序号在快照中查找规则
+func (p *traceParser) ruleAt(table, chain string, position int) *SavedRule {
+	if p.ruleset == nil || position < 1 {
+		return nil
+	}
+	if savedTable := p.ruleset.Table(table); savedTable != nil {
+		if rules := savedTable.ChainRules(chain); position <= len(rules) {
+			return &rules[position-1]
+		}
+	}
+	return nil
+}
+
+// rulePosition 按规则内容在快照中查找序号，找不到时为0
+func (p *traceParser) rulePosition(table, chain, spec string) int {
+	if p.ruleset == nil || spec == "" {
+		return 0
+	}
+	savedTable := p.ruleset.Table(table)
+	if savedTable == nil {
+		return 0
+	}
+	normalized := JoinRuleArgs(SplitRuleArgs(spec))
+	for _, rule := range savedTable.ChainRules(chain) {
+		if JoinRuleArgs(SplitRuleArgs(rule.Spec)) == normalized {
+			return rule.Position
+		}
+	}
+	return 0
+}
+
+// chainPolicy 快照中链的默认策略
+func (p *traceParser) chainPolicy(table, chain string) string {
+	if p.ruleset == nil {
+		return ""
+	}
+	if savedTable := p.ruleset.Table(table); savedTable != nil {
+		if savedChain := savedTable.Chain(chain); savedChain != nil {
+			return savedChain.Policy
+		}
+	}
+	return ""
+}
+
+// groupTracePackets 按数据包汇总记录（保持首次出现的顺序），裁决取最后一个 ACCEPT/DROP/REJECT
+func groupTracePackets(events []TraceEvent) []TracePacket {
+	packets := []TracePacket{}
+	index := make(map[string]int)
+	for _, event := range events {
+		i, ok := index[event.PacketID]
+		if !ok {
+			i = len(packets)
+			index[event.PacketID] = i
+			packets = append(packets, TracePacket{
+				ID:          event.PacketID,
+				Protocol:    event.Protocol,
+				Source:      event.Source,
+				Destination: event.Destination,
+				SourcePort:  event.SourcePort,
+				DestPort:    event.DestPort,
+				InInterface: event.InInterface,
+			})
+		}
+		packets[i].Steps = append(packets[i].Steps, event)
+		switch event.Verdict {
+		case "ACCEPT", "DROP", "REJECT":
+			packets[i].Verdict = event.Verdict
+		}
+	}
+	return packets
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const sampleTraceRuleset = `*raw
+:PREROUTING ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+-A PREROUTING -p tcp -m tcp --dport 22 -m comment --comment iptables-manager-trace:abc -j TRACE
+COMMIT
+*filter
+:INPUT DROP [0:0]
+:FORWARD DROP [0:0]
+:OUTPUT ACCEPT [0:0]
+-A INPUT -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT
+-A INPUT -i eth0 -p tcp -m tcp --dport 22 -j ACCEPT
+COMMIT
+`
+
+func TestNormalizeTraceRequest(t *testing.T) {
+	request := TraceRequest{TraceFilter: TraceFilter{Protocol: "TCP", Source: "10.0.0.5/24", DestPort: 22}}
+	duration, maxEvents, err := normalizeTraceRequest(&request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if duration != defaultTraceDuration || maxEvents != defaultTraceMaxEvents {
+		t.Errorf("defaults = %s, %d", duration, maxEvents)
+	}
+	if request.Protocol != "tcp" || request.Source != "10.0.0.0/24" {
+		t.Errorf("unexpected normalized filter: %+v", request.TraceFilter)
+	}
+
+	invalid := []TraceRequest{
+		{},
+		{TraceFilter: TraceFilter{Protocol: "all"}},
+		{TraceFilter: TraceFilter{Protocol: "gre", DestPort: 22}},
+		{TraceFilter: TraceFilter{Protocol: "icmp", DestPort: 22}},
+		{TraceFilter: TraceFilter{Source: "fd00::1"}},
+		{TraceFilter: TraceFilter{Source: "not-an-address"}},
+		{TraceFilter: TraceFilter{InInterface: "eth0; reboot"}},
+		{TraceFilter: TraceFilter{InInterface: "eth0"}, Duration: 600},
+		{TraceFilter: TraceFilter{InInterface: "eth0"}, MaxEvents: 100000},
+	}
+	for _, request := range invalid {
+		var validation *ValidationError
+		if _, _, err := normalizeTraceRequest(&request); !errors.As(err, &validation) {
+			t.Errorf("expected validation error for %+v, got %v", request, err)
+		}
+	}
+}
+
+func TestTraceRuleArgs(t *testing.T) {
+	filter := TraceFilter{Protocol: "tcp", Source: "10.0.0.0/24", DestPort: 22}
+	rules := traceRuleArgs(filter, "iptables-manager-trace:abc")
+	want := [][]string{
+		{"-t", "raw", "-I", "PREROUTING", "1", "-s", "10.0.0.0/24", "-p", "tcp", "--dport", "22", "-m", "comment", "--comment", "iptables-manager-trace:abc", "-j", "TRACE"},
+		{"-t", "raw", "-I", "OUTPUT", "1", "-s", "10.0.0.0/24", "-p", "tcp", "--dport", "22", "-m", "comment", "--comment", "iptables-manager-trace:abc", "-j", "TRACE"},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("traceRuleArgs = %v, want %v", rules, want)
+	}
+
+	filter.InInterface = "wg0"
+	rules = traceRuleArgs(filter, "iptables-manager-trace:abc")
+	if len(rules) != 1 || rules[0][5] != "-i" || rules[0][6] != "wg0" {
+		t.Errorf("expected only PREROUTING rule with -i wg0, got %v", rules)
+	}
+
+	deleteArgs := traceDeleteArgs(rules[0])
+	wantDelete := []string{"-t", "raw", "-D", "PREROUTING", "-i", "wg0", "-s", "10.0.0.0/24", "-p", "tcp", "--dport", "22", "-m", "comment", "--comment", "iptables-manager-trace:abc", "-j", "TRACE"}
+	if !reflect.DeepEqual(deleteArgs, wantDelete) {
+		t.Errorf("traceDeleteArgs = %v, want %v", deleteArgs, wantDelete)
+	}
+	if got := traceDeleteArgs([]string{"-t", "raw", "-A", "OUTPUT", "-j", "TRACE"}); !reflect.DeepEqual(got, []string{"-t", "raw", "-D", "OUTPUT", "-j", "TRACE"}) {
+		t.Errorf("traceDeleteArgs(-A) = %v", got)
+	}
+}
+
+func TestTraceParserKernelLog(t *testing.T) {
+	ruleset, err := ParseIPTablesSave(sampleTraceRuleset)
+	if err != nil {
+		t.Fatalf("ParseIPTablesSave returned error: %v", err)
+	}
+	parser := newTraceParser("legacy", ruleset)
+	now := time.Now()
+
+	header := " IN=eth0 OUT= MAC=00:11:22:33:44:55:66:77:88:99:aa:bb:08:00 SRC=10.0.0.2 DST=192.168.1.10 LEN=60 TOS=0x00 PREC=0x00 TTL=64 ID=4321 DF PROTO=TCP SPT=40000 DPT=22 WINDOW=64240 RES=0x00 SYN URGP=0"
+	lines := []string{
+		"4,1001,5000,-;TRACE: raw:PREROUTING:rule:1" + header,
+		"4,1002,5001,-;TRACE: raw:PREROUTING:policy:2" + header,
+		"4,1003,5002,-;TRACE: filter:INPUT:rule:2" + header,
+		"6,1004,5003,-;eth0: link up",
+	}
+
+	var events []TraceEvent
+	for _, line := range lines {
+		if event, ok := parser.parse(line, now); ok {
+			events = append(events, event)
+		}
+	}
+	if len(events) != 3 {
+		t.Fatalf("parsed %d events, want 3: %+v", len(events), events)
+	}
+	if events[0].Verdict != "TRACE" || events[0].Position != 1 || events[0].Source != "10.0.0.2" || events[0].DestPort != 22 || events[0].Protocol != "tcp" {
+		t.Errorf("unexpected raw rule event: %+v", events[0])
+	}
+	if events[1].Type != "policy" || events[1].Verdict != "ACCEPT" || events[1].Position != 0 {
+		t.Errorf("unexpected policy event: %+v", events[1])
+	}
+	if events[2].Spec != "-i eth0 -p tcp -m tcp --dport 22 -j ACCEPT" || events[2].Verdict != "ACCEPT" {
+		t.Errorf("unexpected filter rule event: %+v", events[2])
+	}
+
+	packets := groupTracePackets(events)
+	if len(packets) != 1 || len(packets[0].Steps) != 3 || packets[0].Verdict != "ACCEPT" || packets[0].InInterface != "eth0" {
+		t.Errorf("unexpected packets: %+v", packets)
+	}
+}
+
+func TestTraceParserMonitor(t *testing.T) {
+	ruleset, err := ParseIPTablesSave(sampleTraceRuleset)
+	if err != nil {
+		t.Fatalf("ParseIPTablesSave returned error: %v", err)
+	}
+	parser := newTraceParser("nft", ruleset)
+	now := time.Now()
+
+	lines := []string{
+		"PACKET: 2 1a2b3c4d IN=eth0 MACSRC=00:11:22:33:44:55 MACDST=66:77:88:99:aa:bb MACPROTO=0800 SRC=10.0.0.2 DST=192.168.1.10 LEN=60 TOS=0x0 TTL=64 ID=4321DF SPORT=40000 DPORT=22 SYN",
+		" TRACE: 2 1a2b3c4d raw:PREROUTING:rule:0x3:CONTINUE  -4 -t raw -A PREROUTING -p tcp -m tcp --dport 22 -m comment --comment iptables-manager-trace:abc -j TRACE",
+		" TRACE: 2 1a2b3c4d raw:PREROUTING:policy:ACCEPT ",
+		"PACKET: 2 1a2b3c4d IN=eth0 SRC=10.0.0.2 DST=192.168.1.10 SPORT=40000 DPORT=22",
+		" TRACE: 2 1a2b3c4d filter:INPUT:rule:0x9:ACCEPT  -4 -t filter -A INPUT -i eth0 -p tcp -m tcp --dport 22 -j ACCEPT",
+		"PACKET: 2 5e6f7a8b IN=eth1 SRC=10.0.0.3 DST=192.168.1.10 SPORT=40001 DPORT=22",
+		" TRACE: 2 5e6f7a8b filter:INPUT:rule:0x4:JUMP:SERVICES  -4 -t filter -A INPUT -j SERVICES",
+		" TRACE: 2 5e6f7a8b filter:INPUT:policy:DROP ",
+	}
+
+	var events []TraceEvent
+	for _, line := range lines {
+		if event, ok := parser.parse(line, now); ok {
+			events = append(events, event)
+		}
+	}
+	if len(events) != 5 {
+		t.Fatalf("parsed %d events, want 5: %+v", len(events), events)
+	}
+	if events[0].Position != 1 || events[0].Verdict != "CONTINUE" || events[0].Source != "10.0.0.2" || events[0].InInterface != "eth0" {
+		t.Errorf("unexpected raw rule event: %+v", events[0])
+	}
+	if events[2].Position != 2 || events[2].Spec != "-i eth0 -p tcp -m tcp --dport 22 -j ACCEPT" || events[2].Verdict != "ACCEPT" {
+		t.Errorf("unexpected filter rule event: %+v", events[2])
+	}
+	if events[3].Verdict != "JUMP:SERVICES" || events[3].Position != 0 {
+		t.Errorf("unexpected jump event: %+v", events[3])
+	}
+
+	packets := groupTracePackets(events)
+	if len(packets) != 2 {
+		t.Fatalf("grouped %d packets, want 2", len(packets))
+	}
+	if packets[0].ID != "1a2b3c4d" || packets[0].Verdict != "ACCEPT" || len(packets[0].Steps) != 3 {
+		t.Errorf("unexpected first packet: %+v", packets[0])
+	}
+	if packets[1].Verdict != "DROP" || packets[1].InInterface != "eth1" {
+		t.Errorf("unexpected second packet: %+v", packets[1])
+	}
+}
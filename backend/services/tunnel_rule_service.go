@@ -0,0 +1,309 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"iptables-management-backend/config"
+	"iptables-management-backend/models"
+)
+
+// tunnelRuleGroupPrefix 生成规则对应的托管规则组名称前缀
+const tunnelRuleGroupPrefix = "generate-rules:"
+
+// TunnelRuleService 隧道与Docker网桥通信规则生成服务，可将结果作为托管规则组应用
+type TunnelRuleService struct {
+	networkService    *NetworkService
+	dockerUserService *DockerUserService
+}
+
+// TunnelRuleRequest 隧道Docker规则生成请求
+type TunnelRuleRequest struct {
+	TunnelInterface string `json:"tunnel_interface" binding:"required"`
+	DockerBridge    string `json:"docker_bridge" binding:"required"`
+	Direction       string `json:"direction"` // bidirectional, inbound（隧道到网桥）, outbound（网桥到隧道）
+	Protocol        string `json:"protocol"`  // tcp, udp, icmp, all
+	SourcePort      string `json:"source_port"`
+	DestPort        string `json:"dest_port"`
+	Action          string `json:"action"` // ACCEPT, DROP, REJECT
+	EnableNAT       bool   `json:"enable_nat"`
+	EnableLogging   bool   `json:"enable_logging"`
+	HostTraffic     bool   `json:"host_traffic"` // 同时生成本机与隧道间的 INPUT/OUTPUT 规则（按方向）
+	Apply           bool   `json:"apply"`        // 作为托管规则组保存并应用，任一规则失败时整体撤销
+}
+
+// TunnelRuleResult 生成（及应用）的规则
+type TunnelRuleResult struct {
+	TunnelInterface string               `json:"tunnel_interface"`
+	DockerBridge    string               `json:"docker_bridge"`
+	GroupName       string               `json:"group_name"`
+	Subnets         []string             `json:"subnets"`
+	Rules           []models.ManagedRule `json:"rules"`
+	Commands        []string             `json:"commands"`
+	Warnings        []string             `json:"warnings,omitempty"`
+	Applied         bool                 `json:"applied"`
+	Group           *ManagedGroupStatus  `json:"group,omitempty"`
+}
+
+// NewTunnelRuleService 创建隧道规则生成服务实例
+func NewTunnelRuleService(networkService *NetworkService, dockerUserService *DockerUserService) *TunnelRuleService {
+	return &TunnelRuleService{
+		networkService:    networkService,
+		dockerUserService: dockerUserService,
+	}
+}
+
+// GenerateRules 按网桥的实际子网生成规则；request.Apply 为 true 时保存为托管规则组并应用
+func (s *TunnelRuleService) GenerateRules(request TunnelRuleRequest, username string) (*TunnelRuleResult, error) {
+	if err := normalizeTunnelRuleRequest(&request); err != nil {
+		return nil, err
+	}
+
+	result := &TunnelRuleResult{
+		TunnelInterface: request.TunnelInterface,
+		DockerBridge:    request.DockerBridge,
+		GroupName:       tunnelRuleGroupPrefix + request.TunnelInterface + ":" + request.DockerBridge,
+		Subnets:         []string{},
+	}
+
+	subnets, err := s.bridgeSubnets(request.DockerBridge)
+	if err != nil {
+		return nil, err
+	}
+	result.Subnets = subnets
+	if request.EnableNAT {
+		switch {
+		case request.Direction == "inbound":
+			result.Warnings = append(result.Warnings, "NAT 只作用于网桥到隧道方向的流量，inbound 方向不生成 MASQUERADE 规则")
+		case len(subnets) == 0:
+			return nil, invalidf("no IPv4 subnet found for bridge %s, cannot generate NAT rules", request.DockerBridge)
+		}
+	}
+
+	result.Rules = buildTunnelRules(request, subnets)
+	result.Commands = managedGroupCommands(result.GroupName, result.Rules)
+	if !request.Apply {
+		return result, nil
+	}
+
+	if err := s.applyGroup(result, username); err != nil {
+		return nil, err
+	}
+	result.Applied = true
+	log.Printf("[INFO] Applied %d generated rules for %s <-> %s as group %s", len(result.Rules), request.TunnelInterface, request.DockerBridge, result.GroupName)
+
+	var group models.ManagedRuleGroup
+	if err := config.DB.Where("name = ?", result.GroupName).First(&group).Error; err == nil {
+		result.Group, _ = s.dockerUserService.GetGroupStatus(group.ID)
+	}
+	return result, nil
+}
+
+// bridgeSubnets 网桥的IPv4子网：优先使用Docker IPAM配置，否则取网桥上配置的地址
+func (s *TunnelRuleService) bridgeSubnets(bridgeName string) ([]string, error) {
+	bridges, err := s.networkService.GetDockerBridges()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get docker bridges: %v", err)
+	}
+	for _, bridge := range bridges {
+		if bridge.Name != bridgeName {
+			continue
+		}
+		subnets := []string{}
+		for _, ipam := range bridge.IPAMConfig.Config {
+			if _, ipNet, err := net.ParseCIDR(ipam.Subnet); err == nil && ipNet.IP.To4() != nil {
+				subnets = append(subnets, ipNet.String())
+			}
+		}
+		if len(subnets) == 0 {
+			if ipamConfig, err := s.networkService.getBridgeIPAMConfig(bridgeName); err == nil {
+				for _, ipam := range ipamConfig.Config {
+					subnets = append(subnets, ipam.Subnet)
+				}
+			}
+		}
+		return subnets, nil
+	}
+	return nil, notFoundf("docker bridge %s not found", bridgeName)
+}
+
+// applyGroup 以托管规则组保存并应用规则；任一规则应用失败时恢复到应用前的状态
+func (s *TunnelRuleService) applyGroup(result *TunnelRuleResult, username string) error {
+	var previous models.ManagedRuleGroup
+	existed := config.DB.Preload("Rules").Where("name = ?", result.GroupName).First(&previous).Error == nil
+
+	group := &models.ManagedRuleGroup{
+		Name:            result.GroupName,
+		Description:     fmt.Sprintf("隧道 %s 与Docker网桥 %s 的生成规则", result.TunnelInterface, result.DockerBridge),
+		Source:          "generate_rules",
+		TunnelInterface: result.TunnelInterface,
+		DockerBridge:    result.DockerBridge,
+		Enabled:         true,
+		CreatedBy:       username,
+		Rules:           append([]models.ManagedRule{}, result.Rules...),
+	}
+	reconcile, err := s.dockerUserService.SaveGroup(group)
+	if err == nil && len(reconcile.Errors) == 0 {
+		return nil
+	}
+	if err == nil {
+		err = fmt.Errorf("failed to apply generated rules: %s", strings.Join(reconcile.Errors, "; "))
+	}
+
+	if existed {
+		if _, restoreErr := s.dockerUserService.SaveGroup(&previous); restoreErr != nil {
+			log.Printf("[ERROR] Failed to restore managed rule group %s: %v", previous.Name, restoreErr)
+		}
+	} else if removeErr := s.dockerUserService.deleteGroupByName(result.GroupName); removeErr != nil {
+		log.Printf("[ERROR] Failed to remove managed rule group %s: %v", result.GroupName, removeErr)
+	}
+	return err
+}
+
+// normalizeTunnelRuleRequest 填充默认值并校验方向、协议、端口和动作
+func normalizeTunnelRuleRequest(request *TunnelRuleRequest) error {
+	if request.TunnelInterface == "" || request.DockerBridge == "" {
+		return invalidf("tunnel interface and docker bridge are required")
+	}
+	if request.TunnelInterface == request.DockerBridge {
+		return invalidf("invalid interfaces: tunnel interface and docker bridge are the same")
+	}
+
+	request.Direction = strings.ToLower(request.Direction)
+	if request.Direction == "" {
+		request.Direction = "bidirectional"
+	}
+	switch request.Direction {
+	case "bidirectional", "inbound", "outbound":
+	default:
+		return invalidf("invalid direction %s", request.Direction)
+	}
+
+	request.Protocol = strings.ToLower(request.Protocol)
+	if request.Protocol == "" {
+		request.Protocol = "all"
+	}
+	switch request.Protocol {
+	case "all", "tcp", "udp", "icmp":
+	default:
+		return invalidf("invalid protocol %s", request.Protocol)
+	}
+
+	request.Action = strings.ToUpper(request.Action)
+	if request.Action == "" {
+		request.Action = "ACCEPT"
+	}
+	switch request.Action {
+	case "ACCEPT", "DROP", "REJECT":
+	default:
+		return invalidf("invalid action %s", request.Action)
+	}
+
+	for _, port := range []*string{&request.SourcePort, &request.DestPort} {
+		if *port == "" {
+			continue
+		}
+		if request.Protocol != "tcp" && request.Protocol != "udp" {
+			return invalidf("invalid ports: ports require protocol tcp or udp")
+		}
+		normalized, err := normalizeRulePort(*port)
+		if err != nil {
+			return err
+		}
+		*port = normalized
+	}
+	return nil
+}
+
+// normalizeRulePort 校验端口或端口范围（a-b 或 a:b），返回 iptables 格式
+func normalizeRulePort(value string) (string, error) {
+	bounds := strings.SplitN(strings.Replace(strings.TrimSpace(value), "-", ":", 1), ":", 2)
+	ports := make([]int, 0, len(bounds))
+	for _, bound := range bounds {
+		port, err := strconv.Atoi(bound)
+		if err != nil || port < 1 || port > 65535 {
+			return "", invalidf("invalid port %q", value)
+		}
+		ports = append(ports, port)
+	}
+	if len(ports) == 2 {
+		if ports[0] > ports[1] {
+			return "", invalidf("invalid port range %q", value)
+		}
+		if ports[0] != ports[1] {
+			return fmt.Sprintf("%d:%d", ports[0], ports[1]), nil
+		}
+	}
+	return strconv.Itoa(ports[0]), nil
+}
+
+// buildTunnelRules 生成规则：转发规则放入 DOCKER-USER 链（先于Docker隔离链），
+// 放行时为每个方向生成端口对调的回包规则；可选本机 INPUT/OUTPUT 规则和按网桥子网的 MASQUERADE
+func buildTunnelRules(request TunnelRuleRequest, subnets []string) []models.ManagedRule {
+	var rules []models.ManagedRule
+	add := func(table, chain string, args ...string) {
+		rules = append(rules, models.ManagedRule{Position: len(rules) + 1, Table: table, Chain: chain, Spec: JoinRuleArgs(args)})
+	}
+
+	// match 协议和端口；reverse 为 true 时对调源和目标端口（回包方向）
+	match := func(reverse bool) []string {
+		var args []string
+		if request.Protocol != "all" {
+			args = append(args, "-p", request.Protocol)
+		}
+		sourcePort, destPort := request.SourcePort, request.DestPort
+		if reverse {
+			sourcePort, destPort = destPort, sourcePort
+		}
+		if sourcePort != "" {
+			args = append(args, "--sport", sourcePort)
+		}
+		if destPort != "" {
+			args = append(args, "--dport", destPort)
+		}
+		return args
+	}
+
+	withTarget := func(chain, logPrefix string, args []string) {
+		if request.EnableLogging {
+			add("filter", chain, append(append([]string{}, args...), "-j", "LOG", "--log-prefix", logPrefix)...)
+		}
+		add("filter", chain, append(append([]string{}, args...), "-j", request.Action)...)
+	}
+
+	forward := func(in, out, logPrefix string) {
+		withTarget(DockerUserChain, logPrefix, append([]string{"-i", in, "-o", out}, match(false)...))
+		if request.Action == "ACCEPT" {
+			reply := append([]string{"-i", out, "-o", in}, match(true)...)
+			add("filter", DockerUserChain, append(reply, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT")...)
+		}
+	}
+
+	inbound := request.Direction == "bidirectional" || request.Direction == "inbound"
+	outbound := request.Direction == "bidirectional" || request.Direction == "outbound"
+	if inbound {
+		forward(request.TunnelInterface, request.DockerBridge, "TUNNEL-TO-DOCKER: ")
+	}
+	if outbound {
+		forward(request.DockerBridge, request.TunnelInterface, "DOCKER-TO-TUNNEL: ")
+	}
+
+	if request.HostTraffic {
+		if inbound {
+			withTarget("INPUT", "TUNNEL-TO-HOST: ", append([]string{"-i", request.TunnelInterface}, match(false)...))
+		}
+		if outbound {
+			withTarget("OUTPUT", "HOST-TO-TUNNEL: ", append([]string{"-o", request.TunnelInterface}, match(false)...))
+		}
+	}
+
+	if request.EnableNAT && outbound {
+		for _, subnet := range subnets {
+			add("nat", "POSTROUTING", "-s", subnet, "-o", request.TunnelInterface, "-j", "MASQUERADE")
+		}
+	}
+	return rules
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeTunnelRuleRequest(t *testing.T) {
+	request := TunnelRuleRequest{TunnelInterface: "wg0", DockerBridge: "docker0", Protocol: "TCP", DestPort: "8000-8080", Action: "accept"}
+	if err := normalizeTunnelRuleRequest(&request); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if request.Direction != "bidirectional" || request.Protocol != "tcp" || request.Action != "ACCEPT" || request.DestPort != "8000:8080" {
+		t.Errorf("unexpected normalized request: %+v", request)
+	}
+
+	invalid := []TunnelRuleRequest{
+		{TunnelInterface: "wg0"},
+		{TunnelInterface: "wg0", DockerBridge: "wg0"},
+		{TunnelInterface: "wg0", DockerBridge: "docker0", Direction: "sideways"},
+		{TunnelInterface: "wg0", DockerBridge: "docker0", Protocol: "gre"},
+		{TunnelInterface: "wg0", DockerBridge: "docker0", Action: "MASQUERADE"},
+		{TunnelInterface: "wg0", DockerBridge: "docker0", DestPort: "80"},
+		{TunnelInterface: "wg0", DockerBridge: "docker0", Protocol: "udp", DestPort: "70000"},
+		{TunnelInterface: "wg0", DockerBridge: "docker0", Protocol: "udp", SourcePort: "90:80"},
+	}
+	for _, request := range invalid {
+		var validation *ValidationError
+		if err := normalizeTunnelRuleRequest(&request); !errors.As(err, &validation) {
+			t.Errorf("expected validation error for %+v, got %v", request, err)
+		}
+	}
+}
+
+func TestBuildTunnelRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		request TunnelRuleRequest
+		subnets []string
+		want    []string
+	}{
+		{
+			name:    "inbound with ports mirrors ports on return path",
+			request: TunnelRuleRequest{TunnelInterface: "wg0", DockerBridge: "br-1", Direction: "inbound", Protocol: "tcp", DestPort: "443", Action: "ACCEPT"},
+			want: []string{
+				"filter DOCKER-USER -i wg0 -o br-1 -p tcp --dport 443 -j ACCEPT",
+				"filter DOCKER-USER -i br-1 -o wg0 -p tcp --sport 443 -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT",
+			},
+		},
+		{
+			name: "outbound with logging, host traffic and NAT on real subnets",
+			request: TunnelRuleRequest{TunnelInterface: "wg0", DockerBridge: "docker0", Direction: "outbound", Protocol: "all", Action: "ACCEPT",
+				EnableLogging: true, EnableNAT: true, HostTraffic: true},
+			subnets: []string{"172.18.0.0/16", "10.10.0.0/24"},
+			want: []string{
+				`filter DOCKER-USER -i docker0 -o wg0 -j LOG --log-prefix "DOCKER-TO-TUNNEL: "`,
+				"filter DOCKER-USER -i docker0 -o wg0 -j ACCEPT",
+				"filter DOCKER-USER -i wg0 -o docker0 -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT",
+				`filter OUTPUT -o wg0 -j LOG --log-prefix "HOST-TO-TUNNEL: "`,
+				"filter OUTPUT -o wg0 -j ACCEPT",
+				"nat POSTROUTING -s 172.18.0.0/16 -o wg0 -j MASQUERADE",
+				"nat POSTROUTING -s 10.10.0.0/24 -o wg0 -j MASQUERADE",
+			},
+		},
+		{
+			name:    "drop has no return rules",
+			request: TunnelRuleRequest{TunnelInterface: "wg0", DockerBridge: "docker0", Direction: "bidirectional", Protocol: "udp", SourcePort: "53", Action: "DROP", EnableNAT: true},
+			subnets: []string{"172.17.0.0/16"},
+			want: []string{
+				"filter DOCKER-USER -i wg0 -o docker0 -p udp --sport 53 -j DROP",
+				"filter DOCKER-USER -i docker0 -o wg0 -p udp --sport 53 -j DROP",
+				"nat POSTROUTING -s 172.17.0.0/16 -o wg0 -j MASQUERADE",
+			},
+		},
+	}
+	for _, tt := range tests {
+		var got []string
+		for i, rule := range buildTunnelRules(tt.request, tt.subnets) {
+			if rule.Position != i+1 {
+				t.Errorf("%s: rule %d has position %d", tt.name, i, rule.Position)
+			}
+			got = append(got, rule.Table+" "+rule.Chain+" "+rule.Spec)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got: %q\nwant: %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeRulePort(t *testing.T) {
+	tests := map[string]string{"80": "80", " 8000-8080 ": "8000:8080", "10:20": "10:20", "22-22": "22"}
+	for input, want := range tests {
+		if got, err := normalizeRulePort(input); err != nil || got != want {
+			t.Errorf("normalizeRulePort(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "0", "http", "20-10", "1-2-3"} {
+		if _, err := normalizeRulePort(input); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
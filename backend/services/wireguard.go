@@ -0,0 +1,167 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	"iptables-management-backend/models"
+)
+
+// WireGuard generic netlink 接口（见 include/uapi/linux/wireguard.h）
+const (
+	wgGenlName      = "wireguard"
+	wgGenlVersion   = 1
+	wgCmdGetDevice  = 0
+	wgKeyLen        = 32
+	timespecSize    = 16
+	sockaddrIn4Size = 16
+	sockaddrIn6Size = 28
+
+	wgDeviceAttrIfname     = 2
+	wgDeviceAttrPublicKey  = 4
+	wgDeviceAttrListenPort = 6
+	wgDeviceAttrFWMark     = 7
+	wgDeviceAttrPeers      = 8
+
+	wgPeerAttrPublicKey           = 1
+	wgPeerAttrPresharedKey        = 2
+	wgPeerAttrEndpoint            = 4
+	wgPeerAttrPersistentKeepalive = 5
+	wgPeerAttrLastHandshakeTime   = 6
+	wgPeerAttrRxBytes             = 7
+	wgPeerAttrTxBytes             = 8
+	wgPeerAttrAllowedIPs          = 9
+
+	wgAllowedIPAttrFamily   = 1
+	wgAllowedIPAttrIPAddr   = 2
+	wgAllowedIPAttrCIDRMask = 3
+)
+
+// getWireGuardDevice 通过 WG_CMD_GET_DEVICE 读取WireGuard设备的对端信息
+func getWireGuardDevice(name string) (*models.WireGuardDevice, error) {
+	family, err := genetlinkFamilyID(wgGenlName)
+	if err != nil {
+		return nil, err
+	}
+
+	// WG_CMD_GET_DEVICE 只支持dump，对端较多时内核会拆成多条消息
+	attrs := encodeNetlinkAttr(wgDeviceAttrIfname, append([]byte(name), 0))
+	payloads, err := genetlinkRequest(family, wgCmdGetDevice, wgGenlVersion, nlmFDump, attrs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wireguard device %s: %v", name, err)
+	}
+	return parseWireGuardDevice(payloads), nil
+}
+
+// parseWireGuardDevice 合并 WG_CMD_GET_DEVICE 的多条应答；私钥不会被读取
+func parseWireGuardDevice(payloads [][]byte) *models.WireGuardDevice {
+	device := &models.WireGuardDevice{Peers: []models.WireGuardPeer{}}
+	for _, payload := range payloads {
+		attrs := parseNestedAttrs(payload)
+		if key := attrs[wgDeviceAttrPublicKey]; len(key) == wgKeyLen {
+			device.PublicKey = base64.StdEncoding.EncodeToString(key)
+		}
+		if port := attrs[wgDeviceAttrListenPort]; len(port) >= 2 {
+			device.ListenPort = int(nativeEndian.Uint16(port))
+		}
+		if mark := attrs[wgDeviceAttrFWMark]; len(mark) >= 4 {
+			device.FWMark = nativeEndian.Uint32(mark)
+		}
+
+		for _, entry := range parseAttrList(attrs[wgDeviceAttrPeers]) {
+			peer := parseWireGuardPeer(entry.Value)
+			// 同一对端的允许地址可能跨消息延续，此时后续消息中的对端只携带公钥和剩余地址
+			if n := len(device.Peers); n > 0 && device.Peers[n-1].PublicKey == peer.PublicKey {
+				device.Peers[n-1].AllowedIPs = append(device.Peers[n-1].AllowedIPs, peer.AllowedIPs...)
+				continue
+			}
+			device.Peers = append(device.Peers, peer)
+		}
+	}
+
+	sort.SliceStable(device.Peers, func(i, j int) bool {
+		return handshakeUnix(device.Peers[i].LastHandshake) > handshakeUnix(device.Peers[j].LastHandshake)
+	})
+	return device
+}
+
+// parseWireGuardPeer 解析单个 WGPEER_A_* 嵌套属性
+func parseWireGuardPeer(data []byte) models.WireGuardPeer {
+	attrs := parseNestedAttrs(data)
+	peer := models.WireGuardPeer{AllowedIPs: []string{}}
+
+	if key := attrs[wgPeerAttrPublicKey]; len(key) == wgKeyLen {
+		peer.PublicKey = base64.StdEncoding.EncodeToString(key)
+	}
+	if key := attrs[wgPeerAttrPresharedKey]; len(key) == wgKeyLen {
+		for _, b := range key {
+			if b != 0 {
+				peer.PresharedKey = true
+				break
+			}
+		}
+	}
+	peer.Endpoint = parseSockaddr(attrs[wgPeerAttrEndpoint])
+	if interval := attrs[wgPeerAttrPersistentKeepalive]; len(interval) >= 2 {
+		peer.PersistentKeepalive = int(nativeEndian.Uint16(interval))
+	}
+	// struct __kernel_timespec，未握手过时为0
+	if ts := attrs[wgPeerAttrLastHandshakeTime]; len(ts) >= timespecSize {
+		sec := int64(nativeEndian.Uint64(ts[0:8]))
+		nsec := int64(nativeEndian.Uint64(ts[8:16]))
+		if sec != 0 || nsec != 0 {
+			handshake := time.Unix(sec, nsec)
+			peer.LastHandshake = &handshake
+		}
+	}
+	if rx := attrs[wgPeerAttrRxBytes]; len(rx) >= 8 {
+		peer.RxBytes = int64(nativeEndian.Uint64(rx))
+	}
+	if tx := attrs[wgPeerAttrTxBytes]; len(tx) >= 8 {
+		peer.TxBytes = int64(nativeEndian.Uint64(tx))
+	}
+
+	for _, entry := range parseAttrList(attrs[wgPeerAttrAllowedIPs]) {
+		allowed := parseNestedAttrs(entry.Value)
+		addr, mask := allowed[wgAllowedIPAttrIPAddr], allowed[wgAllowedIPAttrCIDRMask]
+		if (len(addr) != net.IPv4len && len(addr) != net.IPv6len) || len(mask) < 1 {
+			continue
+		}
+		peer.AllowedIPs = append(peer.AllowedIPs, fmt.Sprintf("%s/%d", net.IP(addr).String(), mask[0]))
+	}
+	return peer
+}
+
+// parseSockaddr 解析 sockaddr_in/sockaddr_in6 为 host:port
+func parseSockaddr(data []byte) string {
+	if len(data) < 4 {
+		return ""
+	}
+	// sin_family 为本机字节序，端口为网络字节序
+	port := strconv.Itoa(int(binary.BigEndian.Uint16(data[2:4])))
+	switch nativeEndian.Uint16(data[0:2]) {
+	case syscall.AF_INET:
+		if len(data) >= sockaddrIn4Size {
+			return net.JoinHostPort(net.IP(data[4:8]).String(), port)
+		}
+	case syscall.AF_INET6:
+		if len(data) >= sockaddrIn6Size {
+			return net.JoinHostPort(net.IP(data[8:24]).String(), port)
+		}
+	}
+	return ""
+}
+
+// handshakeUnix 用于按最近握手排序，未握手的排在最后
+func handshakeUnix(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.UnixNano()
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testNested 将属性列表编码为嵌套属性负载
+func testNested(attrs ...[]byte) []byte {
+	return bytes.Join(attrs, nil)
+}
+
+func testU64(value uint64) []byte {
+	buf := make([]byte, 8)
+	nativeEndian.PutUint64(buf, value)
+	return buf
+}
+
+func testSockaddr4(ip string, port uint16) []byte {
+	buf := make([]byte, sockaddrIn4Size)
+	nativeEndian.PutUint16(buf[0:2], syscall.AF_INET)
+	binary.BigEndian.PutUint16(buf[2:4], port)
+	copy(buf[4:8], net.ParseIP(ip).To4())
+	return buf
+}
+
+func testAllowedIP(cidr string) []byte {
+	ip, ipNet, _ := net.ParseCIDR(cidr)
+	family, addr := uint16(syscall.AF_INET6), []byte(ip.To16())
+	if ip.To4() != nil {
+		family, addr = syscall.AF_INET, ip.To4()
+	}
+	ones, _ := ipNet.Mask.Size()
+	return testNested(
+		encodeNetlinkAttr(wgAllowedIPAttrFamily, testU16(family)),
+		encodeNetlinkAttr(wgAllowedIPAttrIPAddr, addr),
+		encodeNetlinkAttr(wgAllowedIPAttrCIDRMask, []byte{byte(ones)}),
+	)
+}
+
+func TestParseWireGuardDevice(t *testing.T) {
+	keyA := bytes.Repeat([]byte{0xaa}, wgKeyLen)
+	keyB := bytes.Repeat([]byte{0xbb}, wgKeyLen)
+	handshake := make([]byte, timespecSize)
+	nativeEndian.PutUint64(handshake[0:8], 1700000000)
+
+	peerA := testNested(
+		encodeNetlinkAttr(wgPeerAttrPublicKey, keyA),
+		encodeNetlinkAttr(wgPeerAttrPresharedKey, make([]byte, wgKeyLen)),
+		encodeNetlinkAttr(wgPeerAttrEndpoint, testSockaddr4("198.51.100.7", 51820)),
+		encodeNetlinkAttr(wgPeerAttrPersistentKeepalive, testU16(25)),
+		encodeNetlinkAttr(wgPeerAttrLastHandshakeTime, make([]byte, timespecSize)),
+		encodeNetlinkAttr(wgPeerAttrRxBytes, testU64(100)),
+		encodeNetlinkAttr(wgPeerAttrTxBytes, testU64(200)),
+		encodeNetlinkAttr(wgPeerAttrAllowedIPs|nlaFNested, testNested(
+			encodeNetlinkAttr(0|nlaFNested, testAllowedIP("10.8.0.2/32")),
+		)),
+	)
+	peerB := testNested(
+		encodeNetlinkAttr(wgPeerAttrPublicKey, keyB),
+		encodeNetlinkAttr(wgPeerAttrPresharedKey, bytes.Repeat([]byte{1}, wgKeyLen)),
+		encodeNetlinkAttr(wgPeerAttrLastHandshakeTime, handshake),
+		encodeNetlinkAttr(wgPeerAttrAllowedIPs|nlaFNested, testNested(
+			encodeNetlinkAttr(0|nlaFNested, testAllowedIP("10.9.0.0/24")),
+		)),
+	)
+	// 第二条消息延续 peerB 的允许地址
+	peerBContinued := testNested(
+		encodeNetlinkAttr(wgPeerAttrPublicKey, keyB),
+		encodeNetlinkAttr(wgPeerAttrAllowedIPs|nlaFNested, testNested(
+			encodeNetlinkAttr(0|nlaFNested, testAllowedIP("fd00::/64")),
+		)),
+	)
+
+	first := testNested(
+		encodeNetlinkAttr(wgDeviceAttrIfname, append([]byte("wg0"), 0)),
+		encodeNetlinkAttr(wgDeviceAttrPublicKey, keyA),
+		encodeNetlinkAttr(wgDeviceAttrListenPort, testU16(51820)),
+		encodeNetlinkAttr(wgDeviceAttrFWMark, testU32(0x51820)),
+		encodeNetlinkAttr(wgDeviceAttrPeers|nlaFNested, testNested(
+			encodeNetlinkAttr(0|nlaFNested, peerA),
+			encodeNetlinkAttr(1|nlaFNested, peerB),
+		)),
+	)
+	second := testNested(
+		encodeNetlinkAttr(wgDeviceAttrIfname, append([]byte("wg0"), 0)),
+		encodeNetlinkAttr(wgDeviceAttrPeers|nlaFNested, testNested(
+			encodeNetlinkAttr(0|nlaFNested, peerBContinued),
+		)),
+	)
+
+	device := parseWireGuardDevice([][]byte{first, second})
+	if device.ListenPort != 51820 || device.FWMark != 0x51820 || device.PublicKey != base64.StdEncoding.EncodeToString(keyA) {
+		t.Errorf("unexpected device: %+v", device)
+	}
+	if len(device.Peers) != 2 {
+		t.Fatalf("expected 2 peers, got %+v", device.Peers)
+	}
+
+	// 最近握手的对端排在前面
+	b, a := device.Peers[0], device.Peers[1]
+	if b.PublicKey != base64.StdEncoding.EncodeToString(keyB) || !b.PresharedKey || b.LastHandshake == nil || !b.LastHandshake.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("unexpected peer B: %+v", b)
+	}
+	if len(b.AllowedIPs) != 2 || b.AllowedIPs[0] != "10.9.0.0/24" || b.AllowedIPs[1] != "fd00::/64" {
+		t.Errorf("unexpected peer B allowed IPs: %v", b.AllowedIPs)
+	}
+	if a.Endpoint != "198.51.100.7:51820" || a.PresharedKey || a.LastHandshake != nil || a.PersistentKeepalive != 25 || a.RxBytes != 100 || a.TxBytes != 200 {
+		t.Errorf("unexpected peer A: %+v", a)
+	}
+	if len(a.AllowedIPs) != 1 || a.AllowedIPs[0] != "10.8.0.2/32" {
+		t.Errorf("unexpected peer A allowed IPs: %v", a.AllowedIPs)
+	}
+}
+
+func TestParseSockaddr(t *testing.T) {
+	v6 := make([]byte, sockaddrIn6Size)
+	nativeEndian.PutUint16(v6[0:2], syscall.AF_INET6)
+	binary.BigEndian.PutUint16(v6[2:4], 443)
+	copy(v6[8:24], net.ParseIP("2001:db8::5"))
+
+	tests := []struct {
+		data []byte
+		want string
+	}{
+		{testSockaddr4("192.0.2.1", 51820), "192.0.2.1:51820"},
+		{v6, "[2001:db8::5]:443"},
+		{nil, ""},
+		{testSockaddr4("192.0.2.1", 1)[:8], ""},
+	}
+	for _, tt := range tests {
+		if got := parseSockaddr(tt.data); got != tt.want {
+			t.Errorf("parseSockaddr(%v) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
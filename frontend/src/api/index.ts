@@ -55,6 +55,9 @@ export interface IPTablesRule {
   destination_port?: string
   interface_in?: string
   interface_out?: string
+  match_set?: string
+  match_set_flags?: string
+  match_set_not?: boolean
   rule_text?: string
   created_at?: string
   updated_at?: string
@@ -103,18 +106,53 @@ export interface TableInfo {
 
 export interface NetworkInterface {
   name: string
+  index: number
   type: string
+  kind?: string
   state: string
+  oper_state: string
   mac_address: string
   mtu: number
+  tx_queue_len: number
   is_up: boolean
   is_docker: boolean
+  docker_type?: string
   ip_addresses: string[]
+  peer_address?: string
+  master?: string
+  parent_index?: number
+  parent?: string
+  link_netnsid?: number
+  vlan?: {
+    id: number
+    protocol: string
+  }
+  vxlan?: {
+    vni: number
+    group?: string
+    local?: string
+    device?: string
+    dst_port: number
+  }
+  tun?: {
+    mode: 'tun' | 'tap'
+    owner: number
+    group: number
+    persist: boolean
+    multi_queue: boolean
+    vnet_hdr: boolean
+    queues?: number
+  }
   statistics: {
     rx_bytes: number
     tx_bytes: number
     rx_packets: number
     tx_packets: number
+    rx_errors: number
+    tx_errors: number
+    rx_dropped: number
+    tx_dropped: number
+    multicast: number
   }
 }
 
@@ -128,6 +166,60 @@ export interface ChainVerbose {
   references: number
 }
 
+export interface DockerPortMapping {
+  host_ip: string
+  host_port: string
+  protocol: string
+  container_ip: string
+  container_port: string
+  bridge?: string
+  exposed_on: string
+  container_id?: string
+  container_name?: string
+  image?: string
+  dnat_rule_key: string
+  dnat_packets: number
+  dnat_bytes: number
+  filter_rule_key?: string
+  filter_packets: number
+  filter_bytes: number
+  allowed: boolean
+}
+
+export interface WireGuardPeer {
+  public_key: string
+  preshared_key: boolean
+  endpoint?: string
+  allowed_ips: string[]
+  persistent_keepalive?: number
+  last_handshake?: string
+  rx_bytes: number
+  tx_bytes: number
+}
+
+export interface TunnelInterfaceInfo extends NetworkInterface {
+  tunnel_type: string
+  peer_address: string
+  local_address: string
+  encryption_type: 'wireguard' | 'ipsec' | 'none' | 'unknown'
+  underlay?: {
+    local?: string
+    remote?: string
+    key?: number
+    device?: string
+    port?: number
+    ttl?: number
+  }
+  wireguard?: {
+    public_key: string
+    listen_port: number
+    fwmark?: number
+    peers: WireGuardPeer[]
+  }
+  connected_bridges: string[]
+  related_rules: any[]
+}
+
 export interface DockerBridge {
   name: string
   id: string
@@ -161,28 +253,277 @@ export interface SpecialChain {
 export interface NetworkConnection {
   protocol: string
   local_address: string
-  local_port: number
-  remote_address: string
-  remote_port: number
+  foreign_address: string
   state: string
-  pid: number
-  process: string
+  local_ip?: string
+  local_port?: number
+  remote_ip?: string
+  remote_port?: number
+  tx_queue: number
+  rx_queue: number
+  uid: number
+  inode: number
+  socket_type?: string
+  path?: string
+  pid?: number
+  process?: string
+  container_id?: string
+  runtime?: string
+  netns: string
+}
+
+export interface NetworkConnectionQuery {
+  protocol?: string
+  state?: string
+  port?: number
+  address?: string
+  pid?: number
+  process?: string
+  container?: string
+  netns?: string
+  listening?: boolean
 }
 
 export interface Route {
   destination: string
   gateway: string
-  genmask: string
-  flags: string
   interface: string
+  source: string
   metric: number
+  family: 'inet' | 'inet6'
+  table: string
+  type: string
+  protocol?: string
+  scope?: string
+  flags?: string[]
+  nexthops?: { gateway?: string; interface: string; weight: number }[]
+}
+
+export interface RoutingRule {
+  priority: number
+  family: 'inet' | 'inet6'
+  not?: boolean
+  source: string
+  destination: string
+  fwmark?: string
+  fwmask?: string
+  iif?: string
+  oif?: string
+  ipproto?: string
+  source_port?: string
+  dest_port?: string
+  uid_range?: string
+  action: string
+  table?: string
+  goto?: number
+  suppress_prefixlength: number
 }
 
 // 拓扑图相关接口定义
+export interface IPSetEntry {
+  entry: string
+  timeout?: number
+  packets?: number
+  bytes?: number
+  comment?: string
+}
+
+export interface IPSet {
+  name: string
+  type: string
+  family: string
+  revision: number
+  hash_size: number
+  max_elem: number
+  timeout: number
+  has_timeout: boolean
+  counters: boolean
+  comment: boolean
+  size_in_memory: number
+  references: number
+  entry_count: number
+  referenced_by: string[]
+  entries: IPSetEntry[]
+}
+
+export interface IPSetImportResult {
+  set: string
+  imported: number
+  replaced: boolean
+  invalid: { line: number; value: string; error: string }[] | null
+}
+
+export interface PortForward {
+  id?: number
+  name: string
+  description?: string
+  protocol: 'tcp' | 'udp' | 'tcp+udp'
+  external_interface?: string
+  external_ip?: string
+  external_port_start: number
+  external_port_end?: number
+  internal_ip: string
+  internal_port?: number
+  allowed_sources?: string
+  local_traffic?: boolean
+  hairpin?: boolean
+  hairpin_subnet?: string
+  enabled?: boolean
+  group_name?: string
+  created_by?: string
+  created_at?: string
+  updated_at?: string
+}
+
+export interface PacketLog {
+  id: number
+  timestamp: string
+  source: string
+  prefix: string
+  action: 'drop' | 'reject' | 'accept' | 'log'
+  rule_table?: string
+  rule_chain?: string
+  rule_position?: number
+  rule_spec?: string
+  rule_key?: string
+  in_interface?: string
+  out_interface?: string
+  mac?: string
+  protocol: string
+  src_ip: string
+  dst_ip: string
+  src_port?: number
+  dst_port?: number
+  length?: number
+  ttl?: number
+  flags?: string
+  raw: string
+}
+
+export interface LearningProposal {
+  id: number
+  peer: string
+  hosts: string[]
+  packets: number
+  first_seen: string
+  last_seen: string
+  rule: IPTablesRule
+  rule_id?: number
+}
+
+export interface LearningServiceGroup {
+  service: string
+  chain: string
+  in_interface?: string
+  out_interface?: string
+  protocol: string
+  dest_port?: number
+  destination?: string
+  packets: number
+  proposals: LearningProposal[]
+}
+
+export interface LearningSession {
+  id: string
+  request: { chains: string[]; duration: number; nflog_group?: number; subnet_prefix: number; subnet_min_hosts: number }
+  target: 'LOG' | 'NFLOG'
+  rules: string[]
+  status: 'running' | 'completed' | 'stopped' | 'failed'
+  error?: string
+  warnings?: string[]
+  observed: number
+  dropped_flows: number
+  flows: { chain: string; in_interface?: string; out_interface?: string; protocol: string; source: string; destination: string; dest_port?: number; packets: number; first_seen: string; last_seen: string }[]
+  services: LearningServiceGroup[]
+  created_by: string
+  started_at: string
+  ends_at: string
+  finished_at?: string
+}
+
+export interface RuleUsage {
+  table: string
+  chain: string
+  position: number
+  chain_rules: number
+  key: string
+  spec: string
+  target?: string
+  comment?: string
+  packets: number
+  bytes: number
+  recent_packets: number
+  recent_bytes: number
+  packets_per_second: number
+  current_packets: number
+  last_hit_at?: string
+  observed_since?: string
+  full_coverage: boolean
+  resets: number
+  last_reset_at?: string
+}
+
+export interface RuleUsageReport {
+  since: string
+  until: string
+  recent_since: string
+  rules: number
+  unmonitored: RuleUsage[]
+  unused: RuleUsage[]
+  cold: RuleUsage[]
+  hot: RuleUsage[]
+}
+
+export interface PortForwardStatus extends PortForward {
+  rules: { table: string; chain: string; spec: string; command: string; present: boolean }[]
+  missing: number
+  warnings?: string[]
+}
+
+export interface ManagedRuleGroupStatus {
+  id: number
+  name: string
+  description: string
+  source: 'fix_connectivity' | 'generate_rules' | 'port_forward' | 'manual'
+  tunnel_interface?: string
+  docker_bridge?: string
+  enabled: boolean
+  created_by: string
+  last_applied_at?: string
+  rules: { table: string; chain: string; spec: string; command: string; present: boolean }[]
+  missing: number
+}
+
+export interface TunnelRuleRequest {
+  tunnel_interface: string
+  docker_bridge: string
+  direction?: 'bidirectional' | 'inbound' | 'outbound'
+  protocol?: 'all' | 'tcp' | 'udp' | 'icmp'
+  source_port?: string
+  dest_port?: string
+  action?: 'ACCEPT' | 'DROP' | 'REJECT'
+  enable_nat?: boolean
+  enable_logging?: boolean
+  host_traffic?: boolean
+  apply?: boolean
+}
+
+export interface TunnelRuleResult {
+  tunnel_interface: string
+  docker_bridge: string
+  group_name: string
+  subnets: string[]
+  rules: { position: number; table: string; chain: string; spec: string }[]
+  commands: string[]
+  warnings?: string[]
+  applied: boolean
+  group?: ManagedRuleGroupStatus
+}
+
 export interface TopologyNode {
   id: string
   label: string
-  type: 'interface' | 'table' | 'chain' | 'rule'
+  type: 'interface' | 'table' | 'chain' | 'rule' | 'ipset'
   interface_name?: string
   interface_type?: string
   table_name?: string
@@ -204,7 +545,7 @@ export interface TopologyLink {
   id: string
   source: string
   target: string
-  type: 'interface_rule' | 'rule_interface' | 'input' | 'output' | 'forward'
+  type: 'interface_rule' | 'rule_interface' | 'input' | 'output' | 'forward' | 'match_set'
   label?: string
   rule_text?: string
   rule_number?: number
@@ -281,14 +622,129 @@ export const networkAPI = {
   // 获取Docker网桥
   getDockerBridges: () => api.get<DockerBridge[]>('/docker/bridges'),
   
+  // 获取Docker发布端口映射
+  getDockerPorts: (params?: { protocol?: string; container?: string; bridge?: string; host_port?: string }) =>
+    api.get<{ ports: DockerPortMapping[]; total: number }>('/docker/ports', { params }),
+  
   // 获取网桥规则（新增）
   getBridgeRules: (bridgeName: string) => api.get<BridgeRule[]>(`/bridges/${bridgeName}/rules`),
   
   // 获取网络连接
-  getNetworkConnections: () => api.get<NetworkConnection[]>('/network/connections'),
+  getNetworkConnections: (params?: NetworkConnectionQuery) => api.get<NetworkConnection[]>('/network/connections', { params }),
+
+  // 获取监听服务暴露面报告
+  getExposureReport: (params?: { interface?: string; kind?: string; protocol?: string; port?: number; source?: string }) =>
+    api.get('/network/exposure', { params }),
   
   // 获取路由表
-  getRouteTable: () => api.get<Route[]>('/network/routes')
+  getRouteTable: (params?: { family?: string; table?: string }) => api.get<Route[]>('/network/routes', { params }),
+
+  // 获取策略路由规则
+  getRoutingRules: (family?: string) => api.get<RoutingRule[]>('/network/routes/rules', { params: { family } }),
+
+  // 解析数据包会使用的路由
+  resolveRoute: (params: { dst: string; src?: string; mark?: string; iif?: string; oif?: string; uid?: number; ipproto?: string; sport?: number; dport?: number }) =>
+    api.get('/network/routes/resolve', { params }),
+
+  // 分析两个接口、地址或网段之间双向的链路径和结果
+  analyzePath: (params: { from: string; to: string; protocol?: 'tcp' | 'udp' | 'icmp'; sport?: number; dport?: number }) =>
+    api.get('/network/path', { params }),
+
+  // 创建数据包跟踪会话（安装临时 TRACE 规则，到期自动删除）；wait 为 true 时等待会话结束
+  startTrace: (data: { protocol?: 'all' | 'tcp' | 'udp' | 'icmp'; source?: string; destination?: string; source_port?: number; dest_port?: number; in_interface?: string; duration?: number; max_events?: number }, wait = false) =>
+    api.post('/network/trace', data, { params: wait ? { wait: true } : undefined, timeout: wait ? 130000 : undefined }),
+
+  // 获取最近的跟踪会话
+  getTraceSessions: () =>
+    api.get('/network/trace'),
+
+  // 获取跟踪会话及已收集的记录
+  getTraceSession: (id: string) =>
+    api.get(`/network/trace/${id}`),
+
+  // 提前结束跟踪会话
+  stopTrace: (id: string) =>
+    api.post(`/network/trace/${id}/stop`)
+}
+
+// ipset管理API
+export const ipsetAPI = {
+  // 获取所有集合（不含条目）
+  getSets: () => api.get<{ sets: IPSet[] }>('/ipsets'),
+
+  // 获取集合详情和条目
+  getSet: (name: string, limit?: number) => api.get<IPSet>(`/ipsets/${name}`, { params: { limit } }),
+
+  // 创建集合
+  createSet: (data: { name: string; type: string; family?: string; timeout?: number; hash_size?: number; max_elem?: number; comment?: boolean; counters?: boolean }) =>
+    api.post<IPSet>('/ipsets', data),
+
+  // 批量导入条目（text/csv），replace 为 true 时原子替换
+  importEntries: (name: string, data: { content: string; format?: 'text' | 'csv'; timeout?: number; replace?: boolean }) =>
+    api.post<IPSetImportResult>(`/ipsets/${name}/import`, data),
+
+  // 添加条目
+  addEntry: (name: string, entry: IPSetEntry) => api.post(`/ipsets/${name}/entries`, entry),
+
+  // 删除条目
+  deleteEntry: (name: string, entry: string) => api.delete(`/ipsets/${name}/entries`, { params: { entry } }),
+
+  // 交换两个集合的内容
+  swapSets: (first: string, second: string) => api.post('/ipsets/swap', { first, second }),
+
+  // 删除集合
+  destroySet: (name: string) => api.delete(`/ipsets/${name}`)
+}
+
+// 端口转发API
+export const portForwardAPI = {
+  // 获取端口转发列表
+  getForwards: () => api.get<{ forwards: PortForwardStatus[] }>('/port-forwards'),
+
+  // 预览将生成的规则
+  previewForward: (data: PortForward) => api.post<{ commands: string[] }>('/port-forwards/preview', data),
+
+  // 创建端口转发
+  createForward: (data: PortForward) => api.post<PortForwardStatus>('/port-forwards', data),
+
+  // 修改端口转发
+  updateForward: (id: number, data: PortForward) => api.put<PortForwardStatus>(`/port-forwards/${id}`, data),
+
+  // 删除端口转发及其规则
+  deleteForward: (id: number) => api.delete(`/port-forwards/${id}`)
+}
+
+// 数据包日志API（LOG/NFLOG）
+export const packetLogAPI = {
+  // 搜索数据包日志
+  getLogs: (params?: { action?: string; prefix?: string; chain?: string; interface?: string; protocol?: string; src?: string; dst?: string; port?: number; q?: string; from?: string; to?: string; limit?: number; offset?: number }) =>
+    api.get<{ logs: PacketLog[]; total: number }>('/packet-logs', { params }),
+
+  // 最近一段时间的汇总和采集器状态
+  getSummary: (hours = 24) => api.get('/packet-logs/summary', { params: { hours } })
+}
+
+// 计数器历史API
+export const counterAPI = {
+  // 规则使用情况报告：未使用、已冷却和最热的规则
+  getRuleUsageReport: (params?: { since?: string; days?: number; recent_hours?: number; top?: number; table?: string; chain?: string }) =>
+    api.get<RuleUsageReport>('/counters/usage', { params })
+}
+
+// 托管规则组API
+export const ruleGroupAPI = {
+  // 获取托管规则组，可按来源过滤
+  getGroups: (source?: string) => api.get<{ groups: ManagedRuleGroupStatus[] }>('/rule-groups', { params: { source } }),
+
+  // 获取单个规则组及规则存在状态
+  getGroup: (id: number) => api.get<ManagedRuleGroupStatus>(`/rule-groups/${id}`),
+
+  // 删除规则组并移除其规则
+  deleteGroup: (id: number) => api.delete(`/rule-groups/${id}`),
+
+  // 生成隧道与网桥通信规则，apply 为 true 时作为托管规则组应用
+  generateTunnelRules: (data: TunnelRuleRequest) =>
+    api.post<{ success: boolean; generated_rules: string[]; result: TunnelRuleResult }>('/tunnel/generate-rules', data)
 }
 
 // 表管理API
@@ -331,7 +787,24 @@ export const rulesAPI = {
   deleteRule: (id: number) => api.delete(`/rules/${id}`),
   
   // 更新规则
-  updateRule: (id: number, rule: Partial<IPTablesRule>) => api.put(`/rules/${id}`, rule)
+  updateRule: (id: number, rule: Partial<IPTablesRule>) => api.put(`/rules/${id}`, rule),
+
+  // 启动学习模式：临时记录新建连接并生成放行规则建议
+  startLearning: (data: { chains?: string[]; duration?: number; nflog_group?: number; subnet_prefix?: number; subnet_min_hosts?: number }) =>
+    api.post<LearningSession>('/rules/learning', data),
+
+  // 最近的学习会话
+  getLearningSessions: () => api.get<{ sessions: LearningSession[] }>('/rules/learning'),
+
+  // 学习会话的流量汇总和建议规则
+  getLearningSession: (id: string) => api.get<LearningSession>(`/rules/learning/${id}`),
+
+  // 提前结束学习会话
+  stopLearning: (id: string) => api.post<LearningSession>(`/rules/learning/${id}/stop`),
+
+  // 创建选中的建议规则，不指定时创建全部
+  applyLearningProposals: (id: string, proposalIds?: number[]) =>
+    api.post<{ rules: IPTablesRule[] }>(`/rules/learning/${id}/apply`, { proposal_ids: proposalIds })
 }
 
 // 拓扑图API（新增）
@@ -358,11 +831,19 @@ export const topologyAPI = {
   // 强制刷新拓扑缓存
   refreshTopology: () => api.post<APIResponse<TopologyData>>('/topology/refresh'),
   
-  // 导出拓扑数据
-  exportTopology: (format: 'json' | 'csv' = 'json') => 
-    api.get(`/topology/export?format=${format}`, {
-      responseType: format === 'json' ? 'json' : 'text'
-    }),
+  // 导出拓扑数据（csv 为包含节点、连接、数据流的 zip）
+  exportTopology: (format: 'json' | 'csv' | 'graphml' | 'dot' = 'json', options?: TopologyOptions) => {
+    const params = new URLSearchParams({ format })
+
+    if (options?.protocol_filter) params.append('protocol', options.protocol_filter)
+    if (options?.chain_filter) params.append('chain', options.chain_filter)
+    if (options?.interface_filter) params.append('interface', options.interface_filter)
+    if (options?.rule_type_filter) params.append('rule_type', options.rule_type_filter)
+
+    return api.get(`/topology/export?${params.toString()}`, {
+      responseType: format === 'json' ? 'json' : format === 'csv' ? 'blob' : 'text'
+    })
+  },
   
   // 获取拓扑服务健康状态
   getTopologyHealth: () => api.get<APIResponse<TopologyHealth>>('/topology/health')